@@ -1,7 +1,9 @@
 package controller
 
 import (
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -31,6 +33,30 @@ func listAlertRule(c *gin.Context) ([]*model.AlertRule, error) {
 	return ar, nil
 }
 
+// List alert suppressions
+// @Summary List alert suppressions
+// @Security BearerAuth
+// @Schemes
+// @Description List alert rules that are waiting for debounce confirmation or in cooldown
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.AlertSuppression]
+// @Router /alert-rule/suppression [get]
+func listAlertSuppression(c *gin.Context) ([]*model.AlertSuppression, error) {
+	list := singleton.GetAlertSuppressions()
+
+	singleton.AlertsLock.RLock()
+	allowed := make(map[uint64]bool, len(singleton.Alerts))
+	for _, alert := range singleton.Alerts {
+		allowed[alert.ID] = alert.HasPermission(c)
+	}
+	singleton.AlertsLock.RUnlock()
+
+	return slices.DeleteFunc(list, func(s *model.AlertSuppression) bool {
+		return !allowed[s.AlertID]
+	}), nil
+}
+
 // Add Alert Rule
 // @Summary Add Alert Rule
 // @Security BearerAuth
@@ -57,9 +83,17 @@ func createAlertRule(c *gin.Context) (uint64, error) {
 	r.Rules = arf.Rules
 	r.FailTriggerTasks = arf.FailTriggerTasks
 	r.RecoverTriggerTasks = arf.RecoverTriggerTasks
+	r.Tags = model.NormalizeTags(arf.Tags)
 	r.NotificationGroupID = arf.NotificationGroupID
 	enable := arf.Enable
 	r.TriggerMode = arf.TriggerMode
+	r.Debounce = arf.Debounce
+	r.Cooldown = arf.Cooldown
+	r.EscalationPolicyID = arf.EscalationPolicyID
+	r.Expression = strings.TrimSpace(arf.Expression)
+	r.Severity = arf.Severity
+	r.NotifyResolved = arf.NotifyResolved
+	r.ParentID = arf.ParentID
 	r.Enable = &enable
 
 	if err := validateRule(c, &r); err != nil {
@@ -71,6 +105,7 @@ func createAlertRule(c *gin.Context) (uint64, error) {
 	}
 
 	singleton.OnRefreshOrAddAlert(&r)
+	recordAuditLog(c, model.AuditActionAlertRuleCreate, auditTarget("alert_rule", r.ID), nil, &r)
 	return r.ID, nil
 }
 
@@ -107,13 +142,25 @@ func updateAlertRule(c *gin.Context) (any, error) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
 
+	// 下面的赋值均替换字段本身，浅拷贝即可保留修改前的内容
+	before := r
 	r.Name = arf.Name
 	r.Rules = arf.Rules
 	r.FailTriggerTasks = arf.FailTriggerTasks
 	r.RecoverTriggerTasks = arf.RecoverTriggerTasks
+	r.Tags = model.NormalizeTags(arf.Tags)
 	r.NotificationGroupID = arf.NotificationGroupID
 	enable := arf.Enable
 	r.TriggerMode = arf.TriggerMode
+	r.Debounce = arf.Debounce
+	r.Cooldown = arf.Cooldown
+	r.EscalationPolicyID = arf.EscalationPolicyID
+	r.Expression = strings.TrimSpace(arf.Expression)
+	r.Severity = arf.Severity
+	if arf.NotifyResolved != nil {
+		r.NotifyResolved = arf.NotifyResolved
+	}
+	r.ParentID = arf.ParentID
 	r.Enable = &enable
 
 	if err := validateRule(c, &r); err != nil {
@@ -125,9 +172,140 @@ func updateAlertRule(c *gin.Context) (any, error) {
 	}
 
 	singleton.OnRefreshOrAddAlert(&r)
+	recordAuditLog(c, model.AuditActionAlertRuleUpdate, auditTarget("alert_rule", r.ID), &before, &r)
 	return r.ID, nil
 }
 
+// Batch enable or disable Alert rules
+// @Summary Batch enable or disable Alert rules
+// @Security BearerAuth
+// @Schemes
+// @Description Enable or disable Alert rules at once, returns the IDs whose state was changed
+// @Tags auth required
+// @Accept json
+// @param request body model.AlertRuleToggleForm true "AlertRuleToggleForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]uint64]
+// @Router /batch/alert-rule/toggle [post]
+func batchToggleAlertRule(c *gin.Context) ([]uint64, error) {
+	var tf model.AlertRuleToggleForm
+	if err := c.ShouldBindJSON(&tf); err != nil {
+		return nil, err
+	}
+
+	var ars []*model.AlertRule
+	if err := singleton.DB.Where("id in (?)", tf.IDs).Find(&ars).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	for _, a := range ars {
+		if !a.HasPermission(c) {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+
+	// 仅处理状态需要变化的规则
+	ars = slices.DeleteFunc(ars, func(a *model.AlertRule) bool {
+		return a.Enabled() == tf.Enable
+	})
+	changed := make([]uint64, 0, len(ars))
+	for _, a := range ars {
+		changed = append(changed, a.ID)
+	}
+	if len(changed) == 0 {
+		return changed, nil
+	}
+
+	if err := singleton.DB.Model(&model.AlertRule{}).Where("id in (?)", changed).Update("enable", tf.Enable).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	befores := make([]model.AlertRule, len(ars))
+	for i, a := range ars {
+		befores[i] = *a
+		enable := tf.Enable
+		a.Enable = &enable
+	}
+	singleton.OnRefreshOrAddAlerts(ars)
+
+	for i, a := range ars {
+		recordAuditLog(c, model.AuditActionAlertRuleUpdate, auditTarget("alert_rule", a.ID), &befores[i], a)
+	}
+	return changed, nil
+}
+
+// Test Alert Rule
+// @Summary Test Alert Rule
+// @Security BearerAuth
+// @Schemes
+// @Description Send a notification marked as test through the notification group of the alert rule, and report the delivery result of each recipient
+// @Tags auth required
+// @Accept json
+// @param id path uint true "Alert ID"
+// @param request body model.AlertRuleTestForm false "AlertRuleTestForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.NotificationDeliveryResult]
+// @Router /alert-rule/{id}/test [post]
+func testAlertRule(c *gin.Context) ([]model.NotificationDeliveryResult, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var tf model.AlertRuleTestForm
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&tf); err != nil {
+			return nil, err
+		}
+	}
+
+	var r model.AlertRule
+	if err := singleton.DB.First(&r, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("alert id %d does not exist", id)
+	}
+	if !r.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	singleton.NotificationsLock.RLock()
+	_, ok := singleton.NotificationList[r.NotificationGroupID]
+	singleton.NotificationsLock.RUnlock()
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("group id %d does not exist", r.NotificationGroupID)
+	}
+
+	// 使用服务器的快照填充模板，未指定时选择 ID 最小的有权限服务器
+	var server *model.Server
+	singleton.SortedServerLock.RLock()
+	for _, s := range singleton.SortedServerList {
+		if !s.HasPermission(c) {
+			continue
+		}
+		if tf.ServerID == 0 {
+			if server == nil || s.ID < server.ID {
+				server = s
+			}
+		} else if s.ID == tf.ServerID {
+			server = s
+			break
+		}
+	}
+	singleton.SortedServerLock.RUnlock()
+	if tf.ServerID != 0 && server == nil {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", tf.ServerID)
+	}
+
+	var curServer *model.Server
+	if server != nil {
+		curServer = &model.Server{}
+		copier.Copy(curServer, server)
+	}
+
+	results := singleton.TestAlertNotification(&r, curServer, tf.Resolved)
+	recordAuditLog(c, model.AuditActionAlertRuleTest, auditTarget("alert_rule", r.ID), nil, nil)
+	return results, nil
+}
+
 // Batch delete Alert rules
 // @Summary Batch delete Alert rules
 // @Security BearerAuth
@@ -161,6 +339,9 @@ func batchDeleteAlertRule(c *gin.Context) (any, error) {
 	}
 
 	singleton.OnDeleteAlert(ar)
+	for i := range ars {
+		recordAuditLog(c, model.AuditActionAlertRuleDelete, auditTarget("alert_rule", ars[i].ID), &ars[i], nil)
+	}
 	return nil, nil
 }
 
@@ -178,6 +359,31 @@ func validateRule(c *gin.Context, r *model.AlertRule) error {
 			}
 			singleton.ServerLock.RUnlock()
 
+			if !rule.KnownType() {
+				return singleton.Localizer.ErrorT("unknown rule type %s", rule.Type)
+			}
+			if rule.Min < 0 || rule.Max < 0 {
+				return singleton.Localizer.ErrorT("threshold of rule type %s can't be negative", rule.Type)
+			}
+			if rule.Target != "" && !rule.SupportsTarget() {
+				return singleton.Localizer.ErrorT("rule type %s does not support target", rule.Type)
+			}
+			if rule.Type == "custom" && !model.ValidCustomMetricName(rule.Target) {
+				return singleton.Localizer.ErrorT("invalid custom metric name %s", rule.Target)
+			}
+			switch rule.Condition {
+			case "":
+			case model.RuleConditionRate, model.RuleConditionDelta:
+				if !rule.SupportsChange() {
+					return singleton.Localizer.ErrorT("rule type %s does not support condition %s", rule.Type, rule.Condition)
+				}
+				if rule.Window < 1 || rule.Window > model.MaxRuleWindow {
+					return singleton.Localizer.ErrorT("window need to be between 1 and %d minutes, history is only kept in memory", model.MaxRuleWindow)
+				}
+			default:
+				return singleton.Localizer.ErrorT("unknown rule condition %s", rule.Condition)
+			}
+
 			if !rule.IsTransferDurationRule() {
 				if rule.Duration < 3 {
 					return singleton.Localizer.ErrorT("duration need to be at least 3")
@@ -197,5 +403,49 @@ func validateRule(c *gin.Context, r *model.AlertRule) error {
 	} else {
 		return singleton.Localizer.ErrorT("need to configure at least a single rule")
 	}
+
+	if r.Severity != "" && !model.ValidSeverity(r.Severity) {
+		return singleton.Localizer.ErrorT("unknown severity %s", r.Severity)
+	}
+
+	if r.Expression != "" {
+		if _, err := model.ParseAlertExpression(r.Expression, len(r.Rules)); err != nil {
+			return singleton.Localizer.ErrorT("invalid alert expression: %v", err)
+		}
+	}
+
+	if r.ParentID != 0 {
+		singleton.AlertsLock.RLock()
+		var parent *model.AlertRule
+		parents := make(map[uint64]uint64, len(singleton.Alerts))
+		for _, a := range singleton.Alerts {
+			parents[a.ID] = a.ParentID
+			if a.ID == r.ParentID {
+				parent = a
+			}
+		}
+		singleton.AlertsLock.RUnlock()
+		if parent == nil {
+			return singleton.Localizer.ErrorT("alert id %d does not exist", r.ParentID)
+		}
+		if !parent.HasPermission(c) {
+			return singleton.Localizer.ErrorT("permission denied")
+		}
+		if model.AlertDependencyCycle(r.ID, r.ParentID, parents) {
+			return singleton.Localizer.ErrorT("alert rule dependency can't form a cycle")
+		}
+	}
+
+	if r.EscalationPolicyID != 0 {
+		singleton.EscalationPolicyLock.RLock()
+		p, ok := singleton.EscalationPolicyMap[r.EscalationPolicyID]
+		singleton.EscalationPolicyLock.RUnlock()
+		if !ok {
+			return singleton.Localizer.ErrorT("escalation policy id %d does not exist", r.EscalationPolicyID)
+		}
+		if !p.HasPermission(c) {
+			return singleton.Localizer.ErrorT("permission denied")
+		}
+	}
 	return nil
 }
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List API tokens
+// @Summary List API tokens
+// @Security BearerAuth
+// @Schemes
+// @Description List API tokens of current user
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.ApiToken]
+// @Router /profile/token [get]
+func listApiToken(c *gin.Context) ([]model.ApiToken, error) {
+	var tokens []model.ApiToken
+	if err := singleton.DB.Where("user_id = ?", getUid(c)).Find(&tokens).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return tokens, nil
+}
+
+// Create API token
+// @Summary Create API token
+// @Security BearerAuth
+// @Schemes
+// @Description Create API token, the secret is only returned once
+// @Tags auth required
+// @Accept json
+// @param request body model.ApiTokenForm true "API Token Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ApiTokenResponse]
+// @Router /profile/token [post]
+func createApiToken(c *gin.Context) (*model.ApiTokenResponse, error) {
+	var tf model.ApiTokenForm
+	if err := c.ShouldBindJSON(&tf); err != nil {
+		return nil, err
+	}
+	if tf.Name == "" {
+		return nil, singleton.Localizer.ErrorT("token name can't be empty")
+	}
+	if tf.ExpireAt != nil && tf.ExpireAt.Before(time.Now()) {
+		return nil, singleton.Localizer.ErrorT("expire time must be in the future")
+	}
+
+	secret, err := utils.GenerateRandomString(40)
+	if err != nil {
+		return nil, err
+	}
+	secret = model.ApiTokenPrefix + secret
+
+	t := model.ApiToken{
+		Name:      tf.Name,
+		TokenHash: model.HashApiToken(secret),
+		ReadOnly:  tf.ReadOnly,
+		ExpireAt:  tf.ExpireAt,
+	}
+	t.UserID = getUid(c)
+
+	if err := singleton.DB.Create(&t).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.ApiTokenResponse{
+		ID:    t.ID,
+		Token: secret,
+	}, nil
+}
+
+// Delete API token
+// @Summary Delete API token
+// @Security BearerAuth
+// @Schemes
+// @Description Delete API token
+// @Tags auth required
+// @param id path uint true "Token ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /profile/token/{id} [delete]
+func deleteApiToken(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	result := singleton.DB.Unscoped().Delete(&model.ApiToken{}, "id = ? AND user_id = ?", id, getUid(c))
+	if result.Error != nil {
+		return nil, newGormError("%v", result.Error)
+	}
+	if result.RowsAffected < 1 {
+		return nil, singleton.Localizer.ErrorT("token id %d does not exist", id)
+	}
+
+	return nil, nil
+}
+
+// authenticateApiToken 根据请求头中的 API 令牌解析所属用户
+func authenticateApiToken(c *gin.Context, secret string) *model.User {
+	var t model.ApiToken
+	if err := singleton.DB.Where("token_hash = ?", model.HashApiToken(secret)).First(&t).Error; err != nil {
+		return nil
+	}
+	if t.Expired() {
+		return nil
+	}
+	if t.ReadOnly {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			return nil
+		}
+		c.Set(model.CtxKeyReadOnly, true)
+	}
+
+	var user model.User
+	if err := singleton.DB.First(&user, t.UserID).Error; err != nil {
+		return nil
+	}
+
+	// 降低写入频率，最后使用时间精确到分钟即可
+	now := time.Now()
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > time.Minute {
+		singleton.DB.Model(&t).Update("last_used_at", now)
+	}
+
+	return &user
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestReadOnlyApiTokenScope(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+	readOnly := testCreateApiToken(t, token, true)
+	full := testCreateApiToken(t, token, false)
+
+	cases := []struct {
+		name   string
+		secret string
+		method string
+		path   string
+		allow  bool
+	}{
+		{"read-only token lists servers", readOnly, http.MethodGet, "/api/v1/server", true},
+		{"read-only token writes", readOnly, http.MethodPost, "/api/v1/server-group", false},
+		{"full token lists servers", full, http.MethodGet, "/api/v1/server", true},
+	}
+	for _, tc := range cases {
+		code, resp := testApiTokenRequest(t, tc.secret, tc.method, tc.path, nil)
+		if testAllowed(code, resp) != tc.allow {
+			t.Errorf("%s: got status %d, response %+v", tc.name, code, resp)
+		}
+	}
+
+	// 以 GET 请求触发副作用的接口与 WebSocket 同样拒绝只读令牌
+	for _, path := range []string{
+		"/api/v1/cron/1/manual",
+		"/api/v1/online-user/batch-block",
+		"/api/v1/file?id=1",
+		"/api/v1/ws/file/1",
+		"/api/v1/ws/terminal/1",
+	} {
+		if code, _ := testApiTokenRequest(t, readOnly, http.MethodGet, path, nil); code != http.StatusForbidden {
+			t.Errorf("read-only token on %s: got status %d, want 403", path, code)
+		}
+	}
+}
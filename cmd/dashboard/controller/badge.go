@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/badge"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// 徽章的缓存时间，与服务监控统计的刷新频率相当
+const badgeMaxAge = 60
+
+// Service uptime badge
+// @Summary Service uptime badge
+// @Schemes
+// @Description SVG badge with the current state and 30-day uptime of a service, requires the badge token of the service
+// @Tags common
+// @Param id path uint true "Service ID"
+// @Param token query string true "Badge token of the service"
+// @Param label query string false "Label on the left, the service name by default"
+// @Param green query number false "Minimum uptime percentage shown in green, 99 by default"
+// @Param yellow query number false "Minimum uptime percentage shown in yellow, 95 by default, lower uptime is shown in red"
+// @Produce image/svg+xml
+// @Success 200 {string} string
+// @Router /monitor/{id}/badge.svg [get]
+func serveServiceBadge(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	singleton.ServiceSentinelShared.ServicesLock.RLock()
+	var token string
+	if s, ok := singleton.ServiceSentinelShared.Services[id]; ok {
+		token = s.BadgeToken
+	}
+	singleton.ServiceSentinelShared.ServicesLock.RUnlock()
+	// 令牌错误时与监控不存在的响应一致，避免泄露监控 ID
+	if token == "" || subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(token)) != 1 {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	stats, ok := singleton.ServiceSentinelShared.ServiceStats(id)
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	green, yellow := 99.0, 95.0
+	for key, v := range map[string]*float64{"green": &green, "yellow": &yellow} {
+		if q := c.Query(key); q != "" {
+			if *v, err = strconv.ParseFloat(q, 64); err != nil {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	b := &badge.Badge{
+		Label:   utils.IfOr(c.Query("label") != "", c.Query("label"), stats.ServiceName),
+		Message: "no data",
+		Color:   "lightgrey",
+	}
+	if stats.TotalUp+stats.TotalDown > 0 {
+		uptime := float64(stats.TotalUptime())
+		state := "up"
+		// 最近一段时间的在线率，全部失败时 GetStatusCode 返回无数据，需单独判断
+		if current := stats.CurrentUp + stats.CurrentDown; current > 0 {
+			if p := stats.CurrentUp * 100 / current; p == 0 || singleton.GetStatusCode(p) == singleton.StatusDown {
+				state = "down"
+			}
+		}
+		b.Message = fmt.Sprintf("%s %.2f%%", state, uptime)
+		switch {
+		case state == "down":
+			b.Color = "red"
+		case uptime >= green:
+			b.Color = "brightgreen"
+		case uptime >= yellow:
+			b.Color = "yellow"
+		default:
+			b.Color = "red"
+		}
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", badgeMaxAge))
+	c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", b.SVG())
+}
+
+// Generate service badge token
+// @Summary Generate service badge token
+// @Security BearerAuth
+// @Schemes
+// @Description Generate or rotate the token for the uptime badge of a service, the previous token stops working.
+// @Description The token is only returned here, service lists show it redacted
+// @Tags auth required
+// @Param id path uint true "Service ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[string]
+// @Router /service/{id}/badge-token [post]
+func createServiceBadgeToken(c *gin.Context) (string, error) {
+	token, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+	if err := setServiceBadgeToken(c, token); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Revoke service badge token
+// @Summary Revoke service badge token
+// @Security BearerAuth
+// @Schemes
+// @Description Revoke the token for the uptime badge of a service, disabling the badge
+// @Tags auth required
+// @Param id path uint true "Service ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /service/{id}/badge-token [delete]
+func deleteServiceBadgeToken(c *gin.Context) (any, error) {
+	return nil, setServiceBadgeToken(c, "")
+}
+
+func setServiceBadgeToken(c *gin.Context, token string) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return err
+	}
+	var m model.Service
+	if err := singleton.DB.First(&m, id).Error; err != nil {
+		return singleton.Localizer.ErrorT("service id %d does not exist", id)
+	}
+	if !m.HasPermission(c) {
+		return singleton.Localizer.ErrorT("permission denied")
+	}
+
+	m.BadgeToken = token
+	if err := singleton.DB.Model(&m).Update("badge_token", token).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	if err := singleton.ServiceSentinelShared.OnServiceUpdate(m); err != nil {
+		return err
+	}
+	singleton.ServiceSentinelShared.UpdateServiceList()
+	return nil
+}
@@ -26,19 +26,19 @@ import (
 
 func ServeWeb(frontendDist fs.FS) http.Handler {
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.Recovery(), requestID, accessLog)
 
+	// pprof 只通过需要超级管理员权限的 /api/v1/debug/pprof 开放
 	if singleton.Conf.Debug {
 		gin.SetMode(gin.DebugMode)
-		pprof.Register(r)
-	}
-	if singleton.Conf.Debug {
 		log.Printf("NEZHA>> Swagger(%s) UI available at http://localhost:%d/swagger/index.html", docs.SwaggerInfo.Version, singleton.Conf.ListenPort)
 		r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 	}
 
 	r.Use(waf.RealIp)
 	r.Use(waf.Waf)
+	r.Use(cors)
 	r.Use(recordPath)
 
 	routers(r, frontendDist)
@@ -54,88 +54,227 @@ func routers(r *gin.Engine, frontendDist fs.FS) {
 	if err := authMiddleware.MiddlewareInit(); err != nil {
 		log.Fatal("authMiddleware.MiddlewareInit Error:" + err.Error())
 	}
-	api := r.Group("api/v1")
-	api.POST("/login", authMiddleware.LoginHandler)
+	r.GET("/metrics", serveMetrics)
+	r.GET("/healthz", healthz)
+	r.GET("/readyz", readyz)
+
+	initRateLimiters()
 
-	optionalAuth := api.Group("", optionalAuthMiddleware(authMiddleware))
-	optionalAuth.GET("/ws/server", commonHandler(serverStream))
+	api := r.Group("api/v1")
+	api.POST("/login", authRateLimit, loginHandler(authMiddleware))
+	api.POST("/auth/refresh", authRateLimit, commonHandler(refreshAccessToken(authMiddleware)))
+	api.GET("/oauth2/login", authRateLimit, commonHandler(oauth2Login))
+	api.GET("/oauth2/callback", authRateLimit, commonHandler(oauth2Callback(authMiddleware)))
+	api.POST("/webauthn/login/begin", authRateLimit, commonHandler(beginWebAuthnLogin))
+	api.POST("/webauthn/login/finish", authRateLimit, commonHandler(finishWebAuthnLogin(authMiddleware)))
+	api.GET("/monitor/:id/badge.svg", serveServiceBadge)
+	api.GET("/status", serveStatusPage)
+	api.GET("/.well-known/jwks.json", serveJWKS)
+	api.POST("/webhook/:id/event", incomingWebhookAuth, commonHandler(receiveWebhookEvent))
+
+	// 站点配置不受角色的模块配置限制，其余接口在登录后同样按模块配置限制
+	api.GET("/setting", optionalAuthMiddleware(authMiddleware), tenantScope, commonHandler(listConfig))
+
+	optionalAuth := api.Group("", optionalAuthMiddleware(authMiddleware), tenantScope, moduleScope)
+	optionalAuth.GET("/ws/server", wsConnLimit, commonHandler(serverStream))
 	optionalAuth.GET("/server-group", commonHandler(listServerGroup))
 
 	optionalAuth.GET("/service", commonHandler(showService))
 	optionalAuth.GET("/service/:id", commonHandler(listServiceHistory))
 	optionalAuth.GET("/service/server", commonHandler(listServerWithServices))
 
-	optionalAuth.GET("/setting", commonHandler(listConfig))
-
-	auth := api.Group("", authMiddleware.MiddlewareFunc())
+	auth := api.Group("", authMiddlewareFunc(authMiddleware), tenantScope, moduleScope, writeRateLimit)
 
-	auth.GET("/refresh-token", authMiddleware.RefreshHandler)
+	auth.GET("/refresh-token", refreshHandler(authMiddleware))
 
-	auth.POST("/terminal", commonHandler(createTerminal))
-	auth.GET("/ws/terminal/:id", commonHandler(terminalStream))
+	auth.POST("/terminal", requirePermission(model.PermissionTerminal), commonHandler(createTerminal))
+	auth.GET("/ws/terminal/:id", requirePermission(model.PermissionTerminal), wsConnLimit, commonHandler(terminalStream))
+	auth.GET("/terminal/session", requireAdmin, pCommonHandler(listTerminalSession))
+	auth.GET("/terminal/session/:id/recording", requireAdmin, commonHandler(getTerminalRecording))
 
-	auth.GET("/file", commonHandler(createFM))
-	auth.GET("/ws/file/:id", commonHandler(fmStream))
+	auth.GET("/file", requirePermission(model.PermissionTerminal), commonHandler(createFM))
+	auth.GET("/ws/file/:id", requirePermission(model.PermissionTerminal), wsConnLimit, commonHandler(fmStream))
+	auth.GET("/file/:id/download", requirePermission(model.PermissionTerminal), commonHandler(downloadFile))
+	auth.POST("/file/:id/upload", requirePermission(model.PermissionTerminal), commonHandler(uploadFile))
 
 	auth.GET("/profile", commonHandler(getProfile))
-	auth.POST("/profile", commonHandler(updateProfile))
-	auth.GET("/user", adminHandler(listUser))
-	auth.POST("/user", adminHandler(createUser))
-	auth.POST("/batch-delete/user", adminHandler(batchDeleteUser))
+	auth.POST("/profile", denyImpersonation, commonHandler(updateProfile))
+	auth.GET("/profile/login-history", commonHandler(getLoginHistory))
+	auth.GET("/profile/preferences", commonHandler(getPreferences))
+	auth.PUT("/profile/preferences", denyImpersonation, commonHandler(updatePreferences))
+	auth.POST("/profile/2fa", denyImpersonation, commonHandler(enrollTwoFactor))
+	auth.POST("/profile/2fa/verify", denyImpersonation, authRateLimit, commonHandler(verifyTwoFactor))
+	auth.POST("/profile/logout-others", denyImpersonation, commonHandler(logoutOtherSessions(authMiddleware)))
+	auth.DELETE("/profile/impersonation", commonHandler(endImpersonation(authMiddleware)))
+	auth.GET("/profile/token", commonHandler(listApiToken))
+	auth.POST("/profile/token", denyImpersonation, commonHandler(createApiToken))
+	auth.DELETE("/profile/token/:id", denyImpersonation, commonHandler(deleteApiToken))
+	auth.GET("/profile/webauthn", commonHandler(listWebAuthnCredential))
+	auth.POST("/profile/webauthn/register/begin", denyImpersonation, commonHandler(beginWebAuthnRegistration))
+	auth.POST("/profile/webauthn/register/finish", denyImpersonation, commonHandler(finishWebAuthnRegistration))
+	auth.DELETE("/profile/webauthn/:id", denyImpersonation, commonHandler(deleteWebAuthnCredential))
+	auth.GET("/search", commonHandler(search))
+
+	auth.GET("/user", requirePermission(model.PermissionUser), pCommonHandler(listUser))
+	auth.POST("/user", requirePermission(model.PermissionUser), commonHandler(createUser))
+	auth.POST("/user/:id/permissions", requirePermission(model.PermissionUser), commonHandler(updateUserPermissions))
+	auth.POST("/user/:id/logout", requirePermission(model.PermissionUser), commonHandler(forceLogoutUser))
+	auth.POST("/user/:id/impersonate", requireAdmin, denyImpersonation, commonHandler(impersonateUser(authMiddleware)))
+	auth.POST("/user/:id/tenant", requireAdmin, commonHandler(setUserTenant))
+	auth.POST("/batch-delete/user", requirePermission(model.PermissionUser), commonHandler(batchDeleteUser))
+
+	auth.GET("/tenant", requireAdmin, commonHandler(listTenant))
+	auth.POST("/tenant", requireAdmin, commonHandler(createTenant))
+	auth.PATCH("/tenant/:id", requireAdmin, commonHandler(updateTenant))
+	auth.POST("/batch-delete/tenant", requireAdmin, commonHandler(batchDeleteTenant))
 
 	auth.GET("/service/list", listHandler(listService))
-	auth.POST("/service", commonHandler(createService))
-	auth.PATCH("/service/:id", commonHandler(updateService))
-	auth.POST("/batch-delete/service", commonHandler(batchDeleteService))
-
-	auth.POST("/server-group", commonHandler(createServerGroup))
-	auth.PATCH("/server-group/:id", commonHandler(updateServerGroup))
-	auth.POST("/batch-delete/server-group", commonHandler(batchDeleteServerGroup))
+	auth.POST("/service", requirePermission(model.PermissionService), commonHandler(createService))
+	auth.POST("/batch/service", requirePermission(model.PermissionService), commonHandler(batchCreateService))
+	auth.PATCH("/service/:id", requirePermission(model.PermissionService), commonHandler(updateService))
+	auth.GET("/service/:id/export", commonHandler(exportServiceHistory))
+	auth.POST("/batch-delete/service", requirePermission(model.PermissionService), commonHandler(batchDeleteService))
+	auth.POST("/service/:id/badge-token", requirePermission(model.PermissionService), commonHandler(createServiceBadgeToken))
+	auth.DELETE("/service/:id/badge-token", requirePermission(model.PermissionService), commonHandler(deleteServiceBadgeToken))
+
+	auth.POST("/server-group", requirePermission(model.PermissionServerWrite), commonHandler(createServerGroup))
+	auth.PATCH("/server-group/:id", requirePermission(model.PermissionServerWrite), commonHandler(updateServerGroup))
+	auth.POST("/batch-delete/server-group", requirePermission(model.PermissionServerWrite), commonHandler(batchDeleteServerGroup))
 
 	auth.GET("/notification-group", commonHandler(listNotificationGroup))
-	auth.POST("/notification-group", commonHandler(createNotificationGroup))
-	auth.PATCH("/notification-group/:id", commonHandler(updateNotificationGroup))
-	auth.POST("/batch-delete/notification-group", commonHandler(batchDeleteNotificationGroup))
-
-	auth.GET("/server", listHandler(listServer))
-	auth.PATCH("/server/:id", commonHandler(updateServer))
-	auth.POST("/batch-delete/server", commonHandler(batchDeleteServer))
-	auth.POST("/force-update/server", commonHandler(forceUpdateServer))
+	auth.POST("/notification-group", requirePermission(model.PermissionNotification), commonHandler(createNotificationGroup))
+	auth.PATCH("/notification-group/:id", requirePermission(model.PermissionNotification), commonHandler(updateNotificationGroup))
+	auth.POST("/batch-delete/notification-group", requirePermission(model.PermissionNotification), commonHandler(batchDeleteNotificationGroup))
+
+	auth.GET("/server", requirePermission(model.PermissionServerRead), pCommonHandler(listServer))
+	auth.GET("/server/agent-version", requirePermission(model.PermissionServerRead), commonHandler(getAgentVersionSummary))
+	auth.GET("/server/pending", requirePermission(model.PermissionServerRead), listHandler(listPendingServer))
+	auth.POST("/server/pending/:id/approve", requirePermission(model.PermissionServerWrite), commonHandler(approvePendingServer))
+	auth.POST("/server/pending/:id/reject", requirePermission(model.PermissionServerWrite), commonHandler(rejectPendingServer))
+	auth.POST("/server/import", requirePermission(model.PermissionServerWrite), commonHandler(importServer))
+	auth.GET("/server/:id", requirePermission(model.PermissionServerRead), commonHandler(getServer))
+	auth.PATCH("/server/:id", requirePermission(model.PermissionServerWrite), commonHandler(updateServer))
+	auth.POST("/batch-delete/server", requirePermission(model.PermissionServerWrite), commonHandler(batchDeleteServer))
+	auth.POST("/force-update/server", requirePermission(model.PermissionServerWrite), commonHandler(forceUpdateServer))
+	auth.GET("/server/:id/export", requirePermission(model.PermissionServerRead), commonHandler(exportServerTransfer))
+	auth.GET("/server/:id/metric/compare", requirePermission(model.PermissionServerRead), commonHandler(compareServerMetric))
+	auth.GET("/server/:id/custom-metric", requirePermission(model.PermissionServerRead), commonHandler(listServerCustomMetric))
+	auth.GET("/server/:id/custom-metric/:name", requirePermission(model.PermissionServerRead), commonHandler(getServerCustomMetric))
+	auth.GET("/report/uptime", requirePermission(model.PermissionServerRead), commonHandler(getUptimeReport))
+	auth.GET("/server/:id/events", requirePermission(model.PermissionServerRead), commonHandler(listServerEvents))
+	auth.GET("/server/:id/annotation", requirePermission(model.PermissionServerRead), commonHandler(listServerAnnotation))
+	auth.POST("/server/:id/owner", requireAdmin, commonHandler(setServerOwner))
+	auth.POST("/server/:id/restore", requirePermission(model.PermissionServerWrite), commonHandler(restoreServer))
+	auth.POST("/server/batch-group", requirePermission(model.PermissionServerWrite), commonHandler(batchGroupServer))
+	auth.POST("/server/:id/note", requirePermission(model.PermissionServerWrite), commonHandler(updateServerNote))
+	auth.POST("/server/:id/tags", requirePermission(model.PermissionServerWrite), commonHandler(updateServerTags))
+	auth.POST("/server/:id/maintenance", requirePermission(model.PermissionServerWrite), commonHandler(startServerMaintenance))
+	auth.DELETE("/server/:id/maintenance", requirePermission(model.PermissionServerWrite), commonHandler(stopServerMaintenance))
+
+	auth.GET("/server-tag", requirePermission(model.PermissionServerRead), commonHandler(listServerTag))
+	auth.POST("/server-tag/rename", requirePermission(model.PermissionServerWrite), commonHandler(renameServerTag))
+	auth.POST("/batch-delete/server-tag", requirePermission(model.PermissionServerWrite), commonHandler(batchDeleteServerTag))
 
 	auth.GET("/notification", listHandler(listNotification))
-	auth.POST("/notification", commonHandler(createNotification))
-	auth.PATCH("/notification/:id", commonHandler(updateNotification))
-	auth.POST("/batch-delete/notification", commonHandler(batchDeleteNotification))
+	auth.POST("/notification", requirePermission(model.PermissionNotification), commonHandler(createNotification))
+	auth.POST("/notification/test", requirePermission(model.PermissionNotification), commonHandler(testNotification))
+	auth.PATCH("/notification/:id", requirePermission(model.PermissionNotification), commonHandler(updateNotification))
+	auth.POST("/notification/:id/test", requirePermission(model.PermissionNotification), commonHandler(testSavedNotification))
+	auth.POST("/notification/:id/recipient", requirePermission(model.PermissionNotification), commonHandler(createNotificationRecipient))
+	auth.PATCH("/notification/:id/recipient/:rid", requirePermission(model.PermissionNotification), commonHandler(updateNotificationRecipient))
+	auth.DELETE("/notification/:id/recipient/:rid", requirePermission(model.PermissionNotification), commonHandler(deleteNotificationRecipient))
+	auth.POST("/batch-delete/notification", requirePermission(model.PermissionNotification), commonHandler(batchDeleteNotification))
+	auth.GET("/notification-log", pCommonHandler(listNotificationLog))
+	auth.GET("/notification-dead-letter", requireAdmin, pCommonHandler(listNotificationDeadLetter))
+
+	auth.GET("/mute-window", listHandler(listMuteWindow))
+	auth.GET("/mute-window/active", listHandler(listActiveMuteWindow))
+	auth.POST("/mute-window", requirePermission(model.PermissionNotification), commonHandler(createMuteWindow))
+	auth.PATCH("/mute-window/:id", requirePermission(model.PermissionNotification), commonHandler(updateMuteWindow))
+	auth.POST("/batch-delete/mute-window", requirePermission(model.PermissionNotification), commonHandler(batchDeleteMuteWindow))
+
+	auth.GET("/escalation-policy", listHandler(listEscalationPolicy))
+	auth.POST("/escalation-policy", requirePermission(model.PermissionAlertRule), commonHandler(createEscalationPolicy))
+	auth.PATCH("/escalation-policy/:id", requirePermission(model.PermissionAlertRule), commonHandler(updateEscalationPolicy))
+	auth.POST("/batch-delete/escalation-policy", requirePermission(model.PermissionAlertRule), commonHandler(batchDeleteEscalationPolicy))
 
 	auth.GET("/alert-rule", listHandler(listAlertRule))
-	auth.POST("/alert-rule", commonHandler(createAlertRule))
-	auth.PATCH("/alert-rule/:id", commonHandler(updateAlertRule))
-	auth.POST("/batch-delete/alert-rule", commonHandler(batchDeleteAlertRule))
+	auth.GET("/alert-rule/suppression", commonHandler(listAlertSuppression))
+	auth.POST("/alert-rule", requirePermission(model.PermissionAlertRule), commonHandler(createAlertRule))
+	auth.PATCH("/alert-rule/:id", requirePermission(model.PermissionAlertRule), commonHandler(updateAlertRule))
+	auth.POST("/alert-rule/:id/test", requirePermission(model.PermissionAlertRule), commonHandler(testAlertRule))
+	auth.POST("/batch-delete/alert-rule", requirePermission(model.PermissionAlertRule), commonHandler(batchDeleteAlertRule))
+	auth.POST("/batch/alert-rule/toggle", requirePermission(model.PermissionAlertRule), commonHandler(batchToggleAlertRule))
 
 	auth.GET("/cron", listHandler(listCron))
-	auth.POST("/cron", commonHandler(createCron))
-	auth.PATCH("/cron/:id", commonHandler(updateCron))
-	auth.GET("/cron/:id/manual", commonHandler(manualTriggerCron))
-	auth.POST("/batch-delete/cron", commonHandler(batchDeleteCron))
+	auth.POST("/cron", requirePermission(model.PermissionCron), commonHandler(createCron))
+	auth.PATCH("/cron/:id", requirePermission(model.PermissionCron), commonHandler(updateCron))
+	auth.GET("/cron/:id/manual", requirePermission(model.PermissionCron), denyReadOnly, commonHandler(manualTriggerCron))
+	auth.GET("/cron/:id/history", pCommonHandler(listCronHistory))
+	auth.GET("/cron/run/:id", commonHandler(getCronRun))
+	auth.GET("/ws/cron/history/:id", wsConnLimit, commonHandler(cronRunStream))
+	auth.POST("/batch-delete/cron", requirePermission(model.PermissionCron), commonHandler(batchDeleteCron))
+
+	auth.GET("/secret", requirePermission(model.PermissionServerRead), listHandler(listSecret))
+	auth.POST("/secret", requirePermission(model.PermissionServerWrite), commonHandler(createSecret))
+	auth.PATCH("/secret/:id", requirePermission(model.PermissionServerWrite), commonHandler(updateSecret))
+	auth.POST("/secret/rotate-key", requireAdmin, commonHandler(rotateSecretsKey))
+	auth.POST("/batch-delete/secret", requirePermission(model.PermissionServerWrite), commonHandler(batchDeleteSecret))
+
+	auth.GET("/incoming-webhook", listHandler(listIncomingWebhook))
+	auth.POST("/incoming-webhook", requirePermission(model.PermissionServerWrite), commonHandler(createIncomingWebhook))
+	auth.POST("/batch-delete/incoming-webhook", requirePermission(model.PermissionServerWrite), commonHandler(batchDeleteIncomingWebhook))
+
+	auth.GET("/enrollment-token", listHandler(listEnrollmentToken))
+	auth.POST("/enrollment-token", requirePermission(model.PermissionServerWrite), commonHandler(createEnrollmentToken))
+	auth.POST("/batch-delete/enrollment-token", requirePermission(model.PermissionServerWrite), commonHandler(batchDeleteEnrollmentToken))
 
 	auth.GET("/ddns", listHandler(listDDNS))
 	auth.GET("/ddns/providers", commonHandler(listProviders))
-	auth.POST("/ddns", commonHandler(createDDNS))
-	auth.PATCH("/ddns/:id", commonHandler(updateDDNS))
-	auth.POST("/batch-delete/ddns", commonHandler(batchDeleteDDNS))
+	auth.POST("/ddns", requirePermission(model.PermissionDDNS), commonHandler(createDDNS))
+	auth.PATCH("/ddns/:id", requirePermission(model.PermissionDDNS), commonHandler(updateDDNS))
+	auth.POST("/batch-delete/ddns", requirePermission(model.PermissionDDNS), commonHandler(batchDeleteDDNS))
 
 	auth.GET("/nat", listHandler(listNAT))
-	auth.POST("/nat", commonHandler(createNAT))
-	auth.PATCH("/nat/:id", commonHandler(updateNAT))
-	auth.POST("/batch-delete/nat", commonHandler(batchDeleteNAT))
+	auth.POST("/nat", requirePermission(model.PermissionNAT), commonHandler(createNAT))
+	auth.PATCH("/nat/:id", requirePermission(model.PermissionNAT), commonHandler(updateNAT))
+	auth.POST("/batch-delete/nat", requirePermission(model.PermissionNAT), commonHandler(batchDeleteNAT))
 
 	auth.GET("/waf", pCommonHandler(listBlockedAddress))
-	auth.POST("/batch-delete/waf", adminHandler(batchDeleteBlockedAddress))
+	auth.POST("/batch-delete/waf", requirePermission(model.PermissionWAF), commonHandler(batchDeleteBlockedAddress))
+	auth.GET("/waf/active", commonHandler(listActiveBlocks))
+	auth.GET("/waf/audit", requirePermission(model.PermissionWAF), pCommonHandler(listWAFAudit))
+	auth.GET("/waf/geo", commonHandler(listWAFGeo))
+	auth.POST("/waf/geo", requirePermission(model.PermissionWAF), commonHandler(createWAFGeo))
+	auth.POST("/batch-delete/waf/geo", requirePermission(model.PermissionWAF), commonHandler(batchDeleteWAFGeo))
 
 	auth.GET("/online-user", pCommonHandler(listOnlineUser))
-	auth.GET("/online-user/batch-block", adminHandler(batchBlockOnlineUser))
+	auth.GET("/online-user/batch-block", requirePermission(model.PermissionWAF), denyReadOnly, commonHandler(batchBlockOnlineUser))
+
+	auth.GET("/jwt-key", requireAdmin, commonHandler(listJWTKey))
+	auth.POST("/jwt-key/rotate", requireAdmin, commonHandler(rotateJWTKey))
 
-	auth.PATCH("/setting", adminHandler(updateConfig))
+	auth.GET("/database/stats", requireAdmin, commonHandler(getDatabaseStats))
+	auth.GET("/debug/stats", requireAdmin, commonHandler(getDebugStats))
+	if singleton.Conf.EnablePprof {
+		pprof.RouteRegister(auth.Group("", requireAdmin), "debug/pprof")
+	}
+	auth.GET("/audit-log", requireAdmin, pCommonHandler(listAuditLog))
+	auth.GET("/audit-log/verify", requireAdmin, commonHandler(verifyAuditLog))
+
+	auth.GET("/setting/password-policy", commonHandler(getPasswordPolicy))
+	auth.PATCH("/setting", requirePermission(model.PermissionSetting), commonHandler(updateConfig))
+	auth.GET("/setting/export", requirePermission(model.PermissionSetting), commonHandler(exportConfig))
+	auth.POST("/setting/import", requirePermission(model.PermissionSetting), commonHandler(importConfig))
+	auth.GET("/settings/notifications/mute", commonHandler(getNotificationMute))
+	auth.POST("/settings/notifications/mute", requireAdmin, commonHandler(setNotificationMute))
+
+	auth.GET("/status-page", commonHandler(getStatusPage))
+	auth.PATCH("/status-page", requirePermission(model.PermissionSetting), commonHandler(updateStatusPage))
+	auth.GET("/status-page/incident", pCommonHandler(listStatusIncident))
+	auth.POST("/status-page/incident", requirePermission(model.PermissionSetting), commonHandler(createStatusIncident))
+	auth.POST("/status-page/incident/:id/update", requirePermission(model.PermissionSetting), commonHandler(createStatusIncidentUpdate))
+	auth.POST("/batch-delete/status-page/incident", requirePermission(model.PermissionSetting), commonHandler(batchDeleteStatusIncident))
 
 	r.NoRoute(fallbackToFrontend(frontendDist))
 }
@@ -198,39 +337,134 @@ func commonHandler[T any](handler handlerFunc[T]) func(*gin.Context) {
 	}
 }
 
-func adminHandler[T any](handler handlerFunc[T]) func(*gin.Context) {
+// requirePermission 检查当前用户是否拥有全部指定权限
+func requirePermission(p uint64) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth, ok := c.Get(model.CtxKeyAuthorizedUser)
 		if !ok {
-			c.JSON(http.StatusOK, newErrorResponse(singleton.Localizer.ErrorT("unauthorized")))
+			c.AbortWithStatusJSON(http.StatusOK, newErrorResponse(singleton.Localizer.ErrorT("unauthorized")))
 			return
 		}
 
-		user := *auth.(*model.User)
-		if user.Role != model.RoleAdmin {
-			c.JSON(http.StatusOK, newErrorResponse(singleton.Localizer.ErrorT("permission denied")))
+		user := auth.(*model.User)
+		if !user.Can(p) || (p&readOnlyDeniedPermissions != 0 && c.GetBool(model.CtxKeyReadOnly)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, newErrorResponse(singleton.Localizer.ErrorT("permission denied")))
 			return
 		}
 
-		handle(c, handler)
+		c.Next()
 	}
 }
 
+// readOnlyDeniedPermissions 只读 API 令牌不能使用的权限，这些接口即使是 GET 请求也会修改数据或执行命令
+const readOnlyDeniedPermissions = model.PermissionServerWrite | model.PermissionTerminal
+
+// denyReadOnly 拒绝只读 API 令牌访问以 GET 请求触发副作用的接口
+func denyReadOnly(c *gin.Context) {
+	if c.GetBool(model.CtxKeyReadOnly) {
+		c.AbortWithStatusJSON(http.StatusForbidden, newErrorResponse(singleton.Localizer.ErrorT("permission denied")))
+		return
+	}
+	c.Next()
+}
+
+// requireAdmin 仅允许超级管理员访问，租户管理员不能访问影响全部租户的接口
+func requireAdmin(c *gin.Context) {
+	auth, ok := c.Get(model.CtxKeyAuthorizedUser)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusOK, newErrorResponse(singleton.Localizer.ErrorT("unauthorized")))
+		return
+	}
+
+	if !auth.(*model.User).IsSuperAdmin() {
+		c.AbortWithStatusJSON(http.StatusForbidden, newErrorResponse(singleton.Localizer.ErrorT("permission denied")))
+		return
+	}
+
+	c.Next()
+}
+
+// moduleRoutes 各模块的接口路径前缀（不含 /api/v1），不属于任何模块的接口不受角色配置限制
+var moduleRoutes = map[string][]string{
+	model.ModuleServer: {"/server", "/ws/server", "/server-group", "/server-tag", "/report", "/secret", "/incoming-webhook", "/enrollment-token",
+		"/batch-delete/server", "/batch-delete/server-group", "/batch-delete/server-tag", "/batch-delete/secret",
+		"/batch-delete/incoming-webhook", "/batch-delete/enrollment-token", "/force-update/server"},
+	model.ModuleService:      {"/service", "/batch/service", "/batch-delete/service"},
+	model.ModuleNotification: {"/notification", "/notification-group", "/notification-log", "/notification-dead-letter", "/mute-window", "/batch-delete/notification", "/batch-delete/notification-group", "/batch-delete/mute-window"},
+	model.ModuleAlertRule:    {"/alert-rule", "/escalation-policy", "/batch/alert-rule", "/batch-delete/alert-rule", "/batch-delete/escalation-policy"},
+	model.ModuleCron:         {"/cron", "/ws/cron", "/batch-delete/cron"},
+	model.ModuleDDNS:         {"/ddns", "/batch-delete/ddns"},
+	model.ModuleNAT:          {"/nat", "/batch-delete/nat"},
+	model.ModuleTerminal:     {"/terminal", "/ws/terminal", "/file", "/ws/file"},
+	model.ModuleUser:         {"/user", "/batch-delete/user"},
+	model.ModuleWAF:          {"/waf", "/online-user", "/batch-delete/waf"},
+	model.ModuleSetting:      {"/setting", "/settings", "/status-page", "/batch-delete/status-page"},
+}
+
+// routeModule 返回路由所属的模块，修改密码时需要读取的密码策略不属于任何模块
+func routeModule(path string) string {
+	path = strings.TrimPrefix(path, "/api/v1")
+	if path == "/setting/password-policy" {
+		return ""
+	}
+	for module, prefixes := range moduleRoutes {
+		for _, p := range prefixes {
+			if path == p || strings.HasPrefix(path, p+"/") {
+				return module
+			}
+		}
+	}
+	return ""
+}
+
+// moduleScope 当前用户的角色不可访问路由所属的模块时返回 403
+func moduleScope(c *gin.Context) {
+	auth, ok := c.Get(model.CtxKeyAuthorizedUser)
+	if !ok {
+		c.Next()
+		return
+	}
+	if module := routeModule(c.FullPath()); module != "" && !auth.(*model.User).CanAccessModule(&singleton.Conf.RoleModules, module) {
+		c.AbortWithStatusJSON(http.StatusForbidden, newErrorResponse(singleton.Localizer.ErrorT("permission denied")))
+		return
+	}
+	c.Next()
+}
+
+// canAccessModule 判断当前用户的角色是否可访问 module
+func canAccessModule(c *gin.Context, module string) bool {
+	auth, ok := c.Get(model.CtxKeyAuthorizedUser)
+	return ok && auth.(*model.User).CanAccessModule(&singleton.Conf.RoleModules, module)
+}
+
+// denyImpersonation 模拟登录期间不能修改被模拟用户的凭据与偏好，也不能再次发起模拟
+func denyImpersonation(c *gin.Context) {
+	if auth, ok := c.Get(model.CtxKeyAuthorizedUser); ok && auth.(*model.User).Impersonation != nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, newErrorResponse(singleton.Localizer.ErrorT("not allowed during impersonation")))
+		return
+	}
+	c.Next()
+}
+
 func handle[T any](c *gin.Context, handler handlerFunc[T]) {
 	data, err := handler(c)
+	// 处理函数已直接写入或中止响应，如文件导出
+	if err == nil && (c.Writer.Written() || c.IsAborted()) {
+		return
+	}
 	if err == nil {
 		c.JSON(http.StatusOK, model.CommonResponse[T]{Success: true, Data: data})
 		return
 	}
 	switch err.(type) {
 	case *gormError:
-		log.Printf("NEZHA>> gorm error: %v", err)
+		requestLogger(c).Error("gorm error", "error", err)
 		c.JSON(http.StatusOK, newErrorResponse(singleton.Localizer.ErrorT("database error")))
 		return
 	case *wsError:
 		// Connection is upgraded to WebSocket, so c.Writer is no longer usable
 		if msg := err.Error(); msg != "" {
-			log.Printf("NEZHA>> websocket error: %v", err)
+			requestLogger(c).Warn("websocket error", "error", err)
 		}
 		return
 	default:
@@ -274,6 +508,27 @@ func getUid(c *gin.Context) uint64 {
 	return user.ID
 }
 
+// getCursor 解析 cursor 查询参数，参数存在（可为空，表示第一页）时使用游标分页
+func getCursor(c *gin.Context) (cursor *model.Cursor, ok bool, err error) {
+	v, ok := c.GetQuery("cursor")
+	if !ok {
+		return nil, false, nil
+	}
+	if cursor, err = model.DecodeCursor(v); err != nil {
+		return nil, true, singleton.Localizer.ErrorT("invalid cursor")
+	}
+	return cursor, true, nil
+}
+
+// requestOrigin 返回浏览器访问面板时使用的源，反向代理终止 TLS 时需传递 X-Forwarded-Proto
+func requestOrigin(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
 func fallbackToFrontend(frontendDist fs.FS) func(*gin.Context) {
 	checkLocalFileOrFs := func(c *gin.Context, fs fs.FS, path string) bool {
 		if _, err := os.Stat(path); err == nil {
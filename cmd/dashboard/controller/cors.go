@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// cors 按配置的跨域策略为 /api/ 下的接口添加跨域响应头。
+// 在路由匹配前执行，以便预检请求在认证之前得到响应；
+// 总是回显匹配到的单个来源，而不是 *，来源不被允许时不添加任何跨域响应头
+func cors(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" || !strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Add("Vary", "Origin")
+	policy := singleton.CORSPolicy()
+	allowed, credentials := policy.Match(origin)
+	if !allowed {
+		return
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if c.Request.Method != http.MethodOptions || c.GetHeader("Access-Control-Request-Method") == "" {
+		return
+	}
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	header.Set("Access-Control-Allow-Methods", policy.Methods)
+	header.Set("Access-Control-Allow-Headers", policy.Headers)
+	header.Set("Access-Control-Max-Age", policy.MaxAge)
+	c.AbortWithStatus(http.StatusNoContent)
+}
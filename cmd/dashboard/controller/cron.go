@@ -1,9 +1,12 @@
 package controller
 
 import (
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/jinzhu/copier"
 
 	"github.com/nezhahq/nezha/model"
@@ -27,6 +30,15 @@ func listCron(c *gin.Context) ([]*model.Cron, error) {
 	if err := copier.Copy(&cr, &singleton.CronList); err != nil {
 		return nil, err
 	}
+	for _, task := range cr {
+		if task.TaskType != model.CronTypeCronTask || task.CronJobID == 0 {
+			continue
+		}
+		if entry := singleton.Cron.Entry(task.CronJobID); entry.Valid() && !entry.Next.IsZero() {
+			next := entry.Next.In(task.Location(singleton.Loc))
+			task.NextRunAt = &next
+		}
+	}
 	return cr, nil
 }
 
@@ -60,24 +72,41 @@ func createCron(c *gin.Context) (uint64, error) {
 	}
 	singleton.ServerLock.RUnlock()
 
+	if err := checkCronServerGroups(c, cf.ServerGroups); err != nil {
+		return 0, err
+	}
+	if err := checkSecrets(c, 0, cf.SecretIDs); err != nil {
+		return 0, err
+	}
+
 	cr.UserID = getUid(c)
 	cr.TaskType = cf.TaskType
 	cr.Name = cf.Name
 	cr.Scheduler = cf.Scheduler
 	cr.Command = cf.Command
 	cr.Servers = cf.Servers
+	cr.ServerGroups = cf.ServerGroups
+	cr.SecretIDs = cf.SecretIDs
 	cr.PushSuccessful = cf.PushSuccessful
 	cr.NotificationGroupID = cf.NotificationGroupID
 	cr.Cover = cf.Cover
+	cr.Timezone = cf.Timezone
+	cr.RedeliverOnReconnect = cf.RedeliverOnReconnect
 
 	if cr.TaskType == model.CronTypeCronTask && cr.Cover == model.CronCoverAlertTrigger {
 		return 0, singleton.Localizer.ErrorT("scheduled tasks cannot be triggered by alarms")
 	}
+	if cr.Timezone != "" && !model.ValidTimezone(cr.Timezone) {
+		return 0, singleton.Localizer.ErrorT("invalid timezone: %s", cr.Timezone)
+	}
+	if cr.Timezone != "" && cr.SchedulerHasTimezone() {
+		return 0, singleton.Localizer.ErrorT("scheduler already specifies a timezone")
+	}
 
 	// 对于计划任务类型，需要更新CronJob
 	var err error
 	if cf.TaskType == model.CronTypeCronTask {
-		if cr.CronJobID, err = singleton.Cron.AddFunc(cr.Scheduler, singleton.CronTrigger(&cr)); err != nil {
+		if cr.CronJobID, err = singleton.Cron.AddFunc(cr.Spec(), singleton.CronTrigger(&cr)); err != nil {
 			return 0, err
 		}
 	}
@@ -126,6 +155,13 @@ func updateCron(c *gin.Context) (any, error) {
 	}
 	singleton.ServerLock.RUnlock()
 
+	if err := checkCronServerGroups(c, cf.ServerGroups); err != nil {
+		return nil, err
+	}
+	if err := checkSecrets(c, 0, cf.SecretIDs); err != nil {
+		return nil, err
+	}
+
 	var cr model.Cron
 	if err := singleton.DB.First(&cr, id).Error; err != nil {
 		return nil, singleton.Localizer.ErrorT("task id %d does not exist", id)
@@ -140,17 +176,27 @@ func updateCron(c *gin.Context) (any, error) {
 	cr.Scheduler = cf.Scheduler
 	cr.Command = cf.Command
 	cr.Servers = cf.Servers
+	cr.ServerGroups = cf.ServerGroups
+	cr.SecretIDs = cf.SecretIDs
 	cr.PushSuccessful = cf.PushSuccessful
 	cr.NotificationGroupID = cf.NotificationGroupID
 	cr.Cover = cf.Cover
+	cr.Timezone = cf.Timezone
+	cr.RedeliverOnReconnect = cf.RedeliverOnReconnect
 
 	if cr.TaskType == model.CronTypeCronTask && cr.Cover == model.CronCoverAlertTrigger {
 		return nil, singleton.Localizer.ErrorT("scheduled tasks cannot be triggered by alarms")
 	}
+	if cr.Timezone != "" && !model.ValidTimezone(cr.Timezone) {
+		return nil, singleton.Localizer.ErrorT("invalid timezone: %s", cr.Timezone)
+	}
+	if cr.Timezone != "" && cr.SchedulerHasTimezone() {
+		return nil, singleton.Localizer.ErrorT("scheduler already specifies a timezone")
+	}
 
 	// 对于计划任务类型，需要更新CronJob
 	if cf.TaskType == model.CronTypeCronTask {
-		if cr.CronJobID, err = singleton.Cron.AddFunc(cr.Scheduler, singleton.CronTrigger(&cr)); err != nil {
+		if cr.CronJobID, err = singleton.Cron.AddFunc(cr.Spec(), singleton.CronTrigger(&cr)); err != nil {
 			return nil, err
 		}
 	}
@@ -168,34 +214,242 @@ func updateCron(c *gin.Context) (any, error) {
 // @Summary Trigger schedule task
 // @Security BearerAuth
 // @Schemes
-// @Description Trigger schedule task
+// @Description Run a schedule task immediately, returns IDs of the recorded runs
 // @Tags auth required
 // @Accept json
 // @param id path uint true "Task ID"
 // @Produce json
-// @Success 200 {object} model.CommonResponse[any]
+// @Success 200 {object} model.CommonResponse[[]uint64]
 // @Router /cron/{id}/manual [get]
-func manualTriggerCron(c *gin.Context) (any, error) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
+func manualTriggerCron(c *gin.Context) ([]uint64, error) {
+	cr, err := getCronWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return singleton.ManualTrigger(c.Request.Context(), cr), nil
+}
+
+// List schedule task history
+// @Summary List schedule task history
+// @Security BearerAuth
+// @Schemes
+// @Description List execution history of a schedule task, newest first
+// @Tags auth required
+// @param id path uint true "Task ID"
+// @Param server_id query uint false "Server ID"
+// @Param status query uint false "0: running, 1: success, 2: failure, 3: server offline"
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Produce json
+// @Success 200 {object} model.PaginatedResponse[[]model.CronHistory, model.CronHistory]
+// @Router /cron/{id}/history [get]
+func listCronHistory(c *gin.Context) (*model.Value[[]*model.CronHistory], error) {
+	cr, err := getCronWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := singleton.DB.Model(&model.CronHistory{}).Where("cron_id = ?", cr.ID)
+	if sid := c.Query("server_id"); sid != "" {
+		id, err := strconv.ParseUint(sid, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("server_id = ?", id)
+	}
+	if status := c.Query("status"); status != "" {
+		st, err := strconv.ParseUint(status, 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("status = ?", st)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	var history []*model.CronHistory
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&history).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.Value[[]*model.CronHistory]{
+		Value: history,
+		Pagination: model.Pagination{
+			Offset: offset,
+			Limit:  limit,
+			Total:  total,
+		},
+	}, nil
+}
+
+// Get schedule task run
+// @Summary Get schedule task run
+// @Security BearerAuth
+// @Schemes
+// @Description Get status of a schedule task run, delivery is 1 while waiting for the agent to acknowledge, 2 once delivered and 3 if not acknowledged in time
+// @Tags auth required
+// @param id path uint true "Run ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.CronHistory]
+// @Router /cron/run/{id} [get]
+func getCronRun(c *gin.Context) (*model.CronHistory, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var h model.CronHistory
+	if err := singleton.DB.First(&h, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("run id %d does not exist", id)
+	}
+	singleton.ServerLock.RLock()
+	server, ok := singleton.ServerList[h.ServerID]
+	singleton.ServerLock.RUnlock()
+	if !ok || !server.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	return &h, nil
+}
+
+func checkCronServerGroups(c *gin.Context, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var groups []model.ServerGroup
+	if err := singleton.DB.Find(&groups, "id in (?)", ids).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	for _, id := range ids {
+		idx := slices.IndexFunc(groups, func(g model.ServerGroup) bool { return g.ID == id })
+		if idx < 0 {
+			return singleton.Localizer.ErrorT("group id %d does not exist", id)
+		}
+		if !groups[idx].HasPermission(c) {
+			return singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+	return nil
+}
+
+func getCronWithPermission(c *gin.Context) (*model.Cron, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
 	singleton.CronLock.RLock()
 	cr, ok := singleton.Crons[id]
+	singleton.CronLock.RUnlock()
 	if !ok {
-		singleton.CronLock.RUnlock()
 		return nil, singleton.Localizer.ErrorT("task id %d does not exist", id)
 	}
-	singleton.CronLock.RUnlock()
-
 	if !cr.HasPermission(c) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
+	return cr, nil
+}
 
-	singleton.ManualTrigger(cr)
-	return nil, nil
+// Schedule task run output stream
+// @Summary Schedule task run output stream
+// @Security BearerAuth
+// @Schemes
+// @Description Websocket stream of stdout/stderr of a schedule task run, the recent output is replayed on attach and the last message has done set
+// @Tags auth required
+// @param id path uint true "Run ID"
+// @Produce json
+// @Success 200 {object} model.TaskOutputEvent
+// @Router /ws/cron/history/{id} [get]
+func cronRunStream(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var h model.CronHistory
+	if err := singleton.DB.First(&h, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("run id %d does not exist", id)
+	}
+	singleton.ServerLock.RLock()
+	server, ok := singleton.ServerList[h.ServerID]
+	singleton.ServerLock.RUnlock()
+	if !ok || !server.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	conn, err := upgradeWebSocket(c)
+	if err != nil {
+		return nil, newWsError("%v", err)
+	}
+	defer closeWebSocket(conn)
+
+	backlog, ch, cancel, ok := singleton.SubscribeTaskOutput(h.ID)
+	if !ok {
+		// 执行已结束较久或面板重启过，只能返回保存的输出
+		var events []*model.TaskOutputEvent
+		if h.Output != "" {
+			events = append(events, &model.TaskOutputEvent{Stream: model.CommandOutputStdout, Data: h.Output})
+		}
+		done := &model.TaskOutputEvent{Done: true, Status: h.Status}
+		if h.Status == model.CronRunStatusRunning {
+			done.Error = "live output is not available"
+		}
+		for _, e := range append(events, done) {
+			if err := wsWriteJSON(conn, e); err != nil {
+				break
+			}
+		}
+		return nil, newWsError("")
+	}
+	defer cancel()
+
+	// 客户端断开后停止订阅
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	for _, e := range backlog {
+		if err := wsWriteJSON(conn, e); err != nil {
+			return nil, newWsError("")
+		}
+	}
+
+	ticker := time.NewTicker(time.Second * 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return nil, newWsError("")
+			}
+			if err := wsWriteJSON(conn, e); err != nil {
+				return nil, newWsError("")
+			}
+		case <-ticker.C:
+			if err := wsWrite(conn, websocket.PingMessage, []byte{}); err != nil {
+				return nil, newWsError("")
+			}
+		}
+	}
 }
 
 // Batch delete schedule tasks
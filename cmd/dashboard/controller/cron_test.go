@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-uuid"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// testTaskStream 记录下发给 Agent 的任务
+type testTaskStream struct {
+	pb.NezhaService_RequestTaskServer
+	mu    sync.Mutex
+	tasks []*pb.Task
+}
+
+func (s *testTaskStream) Send(task *pb.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, task)
+	return nil
+}
+
+func (s *testTaskStream) sent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tasks)
+}
+
+// testCreateOnlineServer 创建一台在线的服务器，返回记录下发任务的连接
+func testCreateOnlineServer(t *testing.T, uid uint64) (*model.Server, *testTaskStream) {
+	t.Helper()
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &model.Server{Name: "online", UUID: id}
+	s.UserID = uid
+	s.OwnerID = uid
+	if err := singleton.DB.Create(s).Error; err != nil {
+		t.Fatal(err)
+	}
+	stream := &testTaskStream{}
+	s.Host = &model.Host{}
+	s.State = &model.HostState{}
+	s.GeoIP = &model.GeoIP{}
+	s.TaskStream = stream
+	singleton.ServerLock.Lock()
+	singleton.ServerList[s.ID] = s
+	singleton.ServerUUIDToID[s.UUID] = s.ID
+	singleton.ServerLock.Unlock()
+	singleton.ReSortServer()
+	t.Cleanup(func() {
+		singleton.OnServerDelete([]uint64{s.ID})
+		singleton.ReSortServer()
+	})
+	return s, stream
+}
+
+func TestCommandDelivery(t *testing.T) {
+	admin, token := testCreateUser(t, model.RoleAdmin, 0)
+	s, stream := testCreateOnlineServer(t, admin.ID)
+
+	form := model.CronForm{Name: "delivery", Scheduler: "0 0 0 1 1 *", Command: "true", Servers: []uint64{s.ID}}
+	code, resp := testRequest(t, token, http.MethodPost, "/api/v1/cron", form)
+	if !testAllowed(code, resp) {
+		t.Fatalf("create cron: got status %d, response %+v", code, resp)
+	}
+	var cronID uint64
+	if err := json.Unmarshal(resp.Data, &cronID); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func() model.CronHistory {
+		t.Helper()
+		code, resp := testRequest(t, token, http.MethodGet, fmt.Sprintf("/api/v1/cron/%d/manual", cronID), nil)
+		if !testAllowed(code, resp) {
+			t.Fatalf("trigger cron: got status %d, response %+v", code, resp)
+		}
+		var runs []uint64
+		if err := json.Unmarshal(resp.Data, &runs); err != nil || len(runs) != 1 {
+			t.Fatalf("trigger cron: runs %s", resp.Data)
+		}
+		var h model.CronHistory
+		if err := singleton.DB.First(&h, runs[0]).Error; err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	// 未声明支持确认的 Agent 不跟踪投递状态，不会因长时间无输出被判定为丢失
+	singleton.SetCommandAckSupport(s.ID, false)
+	if h := run(); h.Delivery != 0 {
+		t.Fatalf("agent without ack support: got delivery %d, want untracked", h.Delivery)
+	}
+
+	singleton.SetCommandAckSupport(s.ID, true)
+	h := run()
+	if h.Delivery != model.CommandDeliveryPending {
+		t.Fatalf("agent with ack support: got delivery %d, want pending", h.Delivery)
+	}
+	if n := stream.sent(); n != 2 {
+		t.Fatalf("got %d tasks sent, want 2", n)
+	}
+
+	// 未开启重新下发时重连不会重复执行
+	singleton.RedeliverCommands(s.ID)
+	if n := stream.sent(); n != 2 {
+		t.Fatalf("redelivered without opt-in: got %d tasks sent, want 2", n)
+	}
+
+	form.RedeliverOnReconnect = true
+	if code, resp := testRequest(t, token, http.MethodPatch, fmt.Sprintf("/api/v1/cron/%d", cronID), form); !testAllowed(code, resp) {
+		t.Fatalf("update cron: got status %d, response %+v", code, resp)
+	}
+	singleton.RedeliverCommands(s.ID)
+	if n := stream.sent(); n != 3 {
+		t.Fatalf("redelivered with opt-in: got %d tasks sent, want 3", n)
+	}
+	if err := singleton.DB.First(&h, h.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if h.DeliveryTries != 2 {
+		t.Fatalf("got %d delivery tries, want 2", h.DeliveryTries)
+	}
+
+	singleton.AckCommand(s.ID, cronID)
+	if err := singleton.DB.First(&h, h.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if h.Delivery != model.CommandDeliveryDelivered {
+		t.Fatalf("after ack: got delivery %d, want delivered", h.Delivery)
+	}
+}
+
+func TestCronSchedulerTimezoneConflict(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+
+	// 表达式自带时区时不能再设置时区字段，以免注册到调度器的表达式重复添加前缀
+	form := model.CronForm{Name: "tz", TaskType: model.CronTypeCronTask, Scheduler: "CRON_TZ=UTC 0 0 9 * * *", Command: "true", Timezone: "Asia/Shanghai"}
+	conflict := singleton.Localizer.T("scheduler already specifies a timezone")
+	if code, resp := testRequest(t, token, http.MethodPost, "/api/v1/cron", form); testAllowed(code, resp) || resp.Error != conflict {
+		t.Fatalf("create cron with two timezones: got status %d, response %+v", code, resp)
+	}
+
+	form.Timezone = ""
+	code, resp := testRequest(t, token, http.MethodPost, "/api/v1/cron", form)
+	if !testAllowed(code, resp) {
+		t.Fatalf("create cron: got status %d, response %+v", code, resp)
+	}
+	var cronID uint64
+	if err := json.Unmarshal(resp.Data, &cronID); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		testRequest(t, token, http.MethodPost, "/api/v1/batch-delete/cron", []uint64{cronID})
+	})
+
+	form.Timezone = "Asia/Shanghai"
+	if code, resp := testRequest(t, token, http.MethodPatch, fmt.Sprintf("/api/v1/cron/%d", cronID), form); testAllowed(code, resp) || resp.Error != conflict {
+		t.Fatalf("update cron with two timezones: got status %d, response %+v", code, resp)
+	}
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Get database stats
+// @Summary Get database stats
+// @Security BearerAuth
+// @Schemes
+// @Description Get database size and row counts of history tables, along with rows deleted by the last pruning
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.DatabaseStats]
+// @Router /database/stats [get]
+func getDatabaseStats(c *gin.Context) (*model.DatabaseStats, error) {
+	stats, err := singleton.GetDatabaseStats()
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return stats, nil
+}
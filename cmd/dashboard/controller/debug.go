@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Get debug stats
+// @Summary Get debug stats
+// @Security BearerAuth
+// @Schemes
+// @Description Get goroutine count, memory and GC stats, active websockets and database connection pool stats of the dashboard.
+// @Description pprof is available under /debug/pprof for admins when enable_pprof is set in the config file.
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.DebugStats]
+// @Router /debug/stats [get]
+func getDebugStats(c *gin.Context) (*model.DebugStats, error) {
+	stats, err := singleton.GetDebugStats()
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func TestDebugStats(t *testing.T) {
+	_, adminToken := testCreateUser(t, model.RoleAdmin, 0)
+	_, memberToken := testCreateUser(t, model.RoleMember, model.PermissionAll)
+	tenantAdmin, tenantAdminToken := testCreateUser(t, model.RoleAdmin, model.TenantPermissions)
+	tenant := model.Tenant{Name: "debug"}
+	if err := singleton.DB.Create(&tenant).Error; err != nil {
+		t.Fatal(err)
+	}
+	testMoveToTenant(t, tenantAdmin, tenant.ID)
+
+	code, resp := testRequest(t, adminToken, http.MethodGet, "/api/v1/debug/stats", nil)
+	if !testAllowed(code, resp) {
+		t.Fatalf("admin: got status %d, response %+v", code, resp)
+	}
+	var stats model.DebugStats
+	if err := json.Unmarshal(resp.Data, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.GoVersion == "" || stats.Goroutines == 0 {
+		t.Fatalf("got stats %+v", stats)
+	}
+
+	for name, token := range map[string]string{
+		"guest":        "",
+		"member":       memberToken,
+		"tenant admin": tenantAdminToken,
+	} {
+		if code, resp := testRequest(t, token, http.MethodGet, "/api/v1/debug/stats", nil); testAllowed(code, resp) {
+			t.Errorf("%s can read debug stats", name)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/copier"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List escalation policies
+// @Summary List escalation policies
+// @Security BearerAuth
+// @Schemes
+// @Description List alert escalation policies
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.EscalationPolicy]
+// @Router /escalation-policy [get]
+func listEscalationPolicy(c *gin.Context) ([]*model.EscalationPolicy, error) {
+	singleton.EscalationPolicyLock.RLock()
+	defer singleton.EscalationPolicyLock.RUnlock()
+
+	var p []*model.EscalationPolicy
+	if err := copier.Copy(&p, &singleton.EscalationPolicyListSorted); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Add escalation policy
+// @Summary Add escalation policy
+// @Security BearerAuth
+// @Schemes
+// @Description Add alert escalation policy
+// @Tags auth required
+// @Accept json
+// @param request body model.EscalationPolicyForm true "Escalation Policy Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /escalation-policy [post]
+func createEscalationPolicy(c *gin.Context) (uint64, error) {
+	var ef model.EscalationPolicyForm
+	if err := c.ShouldBindJSON(&ef); err != nil {
+		return 0, err
+	}
+
+	var p model.EscalationPolicy
+	if err := applyEscalationPolicyForm(c, &p, &ef); err != nil {
+		return 0, err
+	}
+	p.UserID = getUid(c)
+
+	if err := singleton.DB.Create(&p).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	singleton.OnEscalationPolicyUpdate(&p)
+	return p.ID, nil
+}
+
+// Edit escalation policy
+// @Summary Edit escalation policy
+// @Security BearerAuth
+// @Schemes
+// @Description Edit alert escalation policy
+// @Tags auth required
+// @Accept json
+// @param id path uint true "Escalation Policy ID"
+// @param request body model.EscalationPolicyForm true "Escalation Policy Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /escalation-policy/{id} [patch]
+func updateEscalationPolicy(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var ef model.EscalationPolicyForm
+	if err := c.ShouldBindJSON(&ef); err != nil {
+		return nil, err
+	}
+
+	var p model.EscalationPolicy
+	if err := singleton.DB.First(&p, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("escalation policy id %d does not exist", id)
+	}
+
+	if !p.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	if err := applyEscalationPolicyForm(c, &p, &ef); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Save(&p).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.OnEscalationPolicyUpdate(&p)
+	return nil, nil
+}
+
+// Batch delete escalation policies
+// @Summary Batch delete escalation policies
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete alert escalation policies, alert rules using them stop escalating
+// @Tags auth required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/escalation-policy [post]
+func batchDeleteEscalationPolicy(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	singleton.EscalationPolicyLock.RLock()
+	for _, id := range ids {
+		if p, ok := singleton.EscalationPolicyMap[id]; ok {
+			if !p.HasPermission(c) {
+				singleton.EscalationPolicyLock.RUnlock()
+				return nil, singleton.Localizer.ErrorT("permission denied")
+			}
+		}
+	}
+	singleton.EscalationPolicyLock.RUnlock()
+
+	if err := singleton.DB.Unscoped().Delete(&model.EscalationPolicy{}, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.OnEscalationPolicyDelete(ids)
+	return nil, nil
+}
+
+func applyEscalationPolicyForm(c *gin.Context, p *model.EscalationPolicy, ef *model.EscalationPolicyForm) error {
+	p.Name = ef.Name
+	p.Steps = ef.Steps
+
+	if err := p.Validate(); err != nil {
+		return singleton.Localizer.ErrorT("invalid escalation policy: %v", err)
+	}
+
+	for _, step := range p.Steps {
+		var ng model.NotificationGroup
+		if err := singleton.DB.First(&ng, step.NotificationGroupID).Error; err != nil {
+			return singleton.Localizer.ErrorT("group id %d does not exist", step.NotificationGroupID)
+		}
+		if !ng.HasPermission(c) {
+			return singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// 导出时每写入多少行刷新一次缓冲区
+const exportFlushInterval = 500
+
+type exportRecord interface {
+	CSVRecord() []string
+}
+
+// Export service history
+// @Summary Export service history
+// @Security BearerAuth
+// @Schemes
+// @Description Export stored history of a service in csv or json
+// @Tags auth required
+// @Param id path uint true "Service ID"
+// @Param format query string false "csv or json, defaults to csv"
+// @Param from query int false "Start timestamp in seconds"
+// @Param to query int false "End timestamp in seconds"
+// @Param server_id query uint false "Only history reported by this server"
+// @Produce json
+// @Produce text/csv
+// @Success 200 {array} model.ServiceHistoryExportItem
+// @Router /service/{id}/export [get]
+func exportServiceHistory(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	singleton.ServiceSentinelShared.ServicesLock.RLock()
+	service, ok := singleton.ServiceSentinelShared.Services[id]
+	singleton.ServiceSentinelShared.ServicesLock.RUnlock()
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("service id %d does not exist", id)
+	}
+	if !service.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	query, err := exportTimeRange(c, singleton.DB.Model(&model.ServiceHistory{}).Where("service_id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if sid := c.Query("server_id"); sid != "" {
+		serverID, err := strconv.ParseUint(sid, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("server_id = ?", serverID)
+	}
+
+	return nil, streamExport(c, fmt.Sprintf("service-%d", id), model.ServiceHistoryExportHeader, query, model.NewServiceHistoryExportItem)
+}
+
+// Export server transfer history
+// @Summary Export server transfer history
+// @Security BearerAuth
+// @Schemes
+// @Description Export hourly transfer records of a server in csv or json
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param format query string false "csv or json, defaults to csv"
+// @Param from query int false "Start timestamp in seconds"
+// @Param to query int false "End timestamp in seconds"
+// @Produce json
+// @Produce text/csv
+// @Success 200 {array} model.TransferExportItem
+// @Router /server/{id}/export [get]
+func exportServerTransfer(c *gin.Context) (any, error) {
+	server, err := getServerWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := exportTimeRange(c, singleton.DB.Model(&model.Transfer{}).Where("server_id = ?", server.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, streamExport(c, fmt.Sprintf("server-%d-transfer", server.ID), model.TransferExportHeader, query, model.NewTransferExportItem)
+}
+
+func exportTimeRange(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	query, err := queryTimeRange(c, query)
+	if err != nil {
+		return nil, err
+	}
+	return query.Order("created_at"), nil
+}
+
+// queryTimeRange 按 from、to 查询参数（Unix 秒）筛选 created_at
+func queryTimeRange(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if from := c.Query("from"); from != "" {
+		ts, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("created_at >= ?", time.Unix(ts, 0))
+	}
+	if to := c.Query("to"); to != "" {
+		ts, err := strconv.ParseInt(to, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("created_at <= ?", time.Unix(ts, 0))
+	}
+	return query, nil
+}
+
+// streamExport 逐行读取查询结果并写入响应，避免大范围导出时将全部记录载入内存
+// 开始写入响应后的错误只记录日志，此时已无法再返回错误信息
+func streamExport[T any, R exportRecord](c *gin.Context, name string, header []string, query *gorm.DB, convert func(*T) R) error {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		return singleton.Localizer.ErrorT("unsupported export format: %s", format)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return newGormError("%v", err)
+	}
+	defer rows.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", name, format))
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	c.Status(200)
+
+	cw := csv.NewWriter(c.Writer)
+	write := func(i int, r R) error {
+		if format == "csv" {
+			return cw.Write(r.CSVRecord())
+		}
+		b, err := utils.Json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			c.Writer.WriteString(",")
+		}
+		_, err = c.Writer.Write(b)
+		return err
+	}
+
+	if format == "csv" {
+		cw.Write(header)
+	} else {
+		c.Writer.WriteString("[")
+	}
+
+	var i int
+	for rows.Next() {
+		var item T
+		if err := singleton.DB.ScanRows(rows, &item); err != nil {
+			requestLogger(c).Error("export failed", "name", name, "error", err)
+			break
+		}
+		if err := write(i, convert(&item)); err != nil {
+			requestLogger(c).Error("export failed", "name", name, "error", err)
+			break
+		}
+		i++
+		if i%exportFlushInterval == 0 {
+			cw.Flush()
+			c.Writer.Flush()
+		}
+	}
+
+	if format == "json" {
+		c.Writer.WriteString("]")
+	}
+	cw.Flush()
+	c.Writer.Flush()
+	return nil
+}
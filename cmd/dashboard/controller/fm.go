@@ -1,7 +1,19 @@
 package controller
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -43,22 +55,11 @@ func createFM(c *gin.Context) (*model.CreateFMResponse, error) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
 
-	streamId, err := uuid.GenerateUUID()
+	streamId, err := startFMTask(server)
 	if err != nil {
 		return nil, err
 	}
-
-	rpc.NezhaHandlerSingleton.CreateStream(streamId)
-
-	fmData, _ := utils.Json.Marshal(&model.TaskFM{
-		StreamID: streamId,
-	})
-	if err := server.TaskStream.Send(&proto.Task{
-		Type: model.TaskTypeFM,
-		Data: string(fmData),
-	}); err != nil {
-		return nil, err
-	}
+	requestLogger(c).Debug("file manager task sent", "stream_id", streamId, "server_id", server.ID)
 
 	return &model.CreateFMResponse{
 		SessionID: streamId,
@@ -79,11 +80,11 @@ func fmStream(c *gin.Context) (any, error) {
 	}
 	defer rpc.NezhaHandlerSingleton.CloseStream(streamId)
 
-	wsConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	wsConn, err := upgradeWebSocket(c)
 	if err != nil {
 		return nil, newWsError("%v", err)
 	}
-	defer wsConn.Close()
+	defer closeWebSocket(wsConn)
 	conn := websocketx.NewConn(wsConn)
 
 	go func() {
@@ -96,7 +97,7 @@ func fmStream(c *gin.Context) (any, error) {
 		}
 	}()
 
-	if err = rpc.NezhaHandlerSingleton.UserConnected(streamId, conn); err != nil {
+	if err = rpc.NezhaHandlerSingleton.UserConnected(streamId, &fmUploadGuard{ReadWriteCloser: conn, limit: singleton.Conf.FMMaxFileSize}); err != nil {
 		return nil, newWsError("%v", err)
 	}
 
@@ -106,3 +107,301 @@ func fmStream(c *gin.Context) (any, error) {
 
 	return nil, newWsError("")
 }
+
+// Download file
+// @Summary Download file
+// @Security BearerAuth
+// @Schemes
+// @Description Download a file from the server, supports a single byte range for resuming
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param path query string true "Absolute file path"
+// @Param Range header string false "e.g. bytes=1024-"
+// @Produce application/octet-stream
+// @Success 200 {string} string "file content"
+// @Success 206 {string} string "partial file content"
+// @Router /file/{id}/download [get]
+func downloadFile(c *gin.Context) (any, error) {
+	path := c.Query("path")
+	if path == "" {
+		return nil, singleton.Localizer.ErrorT("file path can't be empty")
+	}
+	server, err := getConnectedServer(c)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := openFMStream(c, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	r := bufio.NewReaderSize(conn, fmBufferSize)
+	conn.SetDeadline(time.Now().Add(fmResponseTimeout))
+	if _, err := conn.Write(append([]byte{model.FMOpDownload}, path...)); err != nil {
+		return nil, err
+	}
+	if err := readFMReply(conn, r, model.FMMagicFile); err != nil {
+		return nil, err
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := int64(binary.BigEndian.Uint64(header[:]))
+
+	start, length, partial, ok := parseByteRange(c.GetHeader("Range"), size)
+	if !ok {
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", size))
+		c.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+		return nil, nil
+	}
+
+	// Agent 总是从头发送文件，跳过范围之前的内容
+	conn.SetDeadline(time.Time{})
+	if _, err := io.CopyN(io.Discard, r, start); err != nil {
+		return nil, err
+	}
+
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+	c.Header("Content-Length", strconv.FormatInt(length, 10))
+	c.Header("Content-Type", "application/octet-stream")
+	if partial {
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, size))
+		c.Status(http.StatusPartialContent)
+	} else {
+		c.Status(http.StatusOK)
+	}
+	// 空文件不会写入内容，先写入响应头，避免 handle 再写入 JSON
+	c.Writer.WriteHeaderNow()
+	if _, err := io.CopyN(c.Writer, r, length); err != nil {
+		// 已开始写入响应，只能中断连接，客户端可以从已下载的位置继续
+		requestLogger(c).Warn("file download interrupted", "file", path, "server_id", server.ID, "error", err)
+	}
+	return nil, nil
+}
+
+// Upload file
+// @Summary Upload file
+// @Security BearerAuth
+// @Schemes
+// @Description Upload the request body to a file on the server, returns the size and sha256 of the received content
+// @Tags auth required
+// @Accept application/octet-stream
+// @Param id path uint true "Server ID"
+// @Param path query string true "Absolute file path"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.FMUploadResponse]
+// @Router /file/{id}/upload [post]
+func uploadFile(c *gin.Context) (*model.FMUploadResponse, error) {
+	path := c.Query("path")
+	if path == "" {
+		return nil, singleton.Localizer.ErrorT("file path can't be empty")
+	}
+	// Agent 需要预先知道文件大小，在读取请求体之前检查
+	size := c.Request.ContentLength
+	if size < 0 {
+		return nil, singleton.Localizer.ErrorT("content length is required")
+	}
+	if size > singleton.Conf.FMMaxFileSize {
+		return nil, singleton.Localizer.ErrorT("file exceeds the maximum size of %d bytes", singleton.Conf.FMMaxFileSize)
+	}
+	server, err := getConnectedServer(c)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := openFMStream(c, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	header := make([]byte, 9, 9+len(path))
+	header[0] = model.FMOpUpload
+	binary.BigEndian.PutUint64(header[1:], uint64(size))
+	if _, err := conn.Write(append(header, path...)); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	buf := make([]byte, fmBufferSize)
+	n, err := io.CopyBuffer(conn, io.TeeReader(io.LimitReader(c.Request.Body, size), hash), buf)
+	if err != nil {
+		return nil, err
+	}
+	if n < size {
+		return nil, singleton.Localizer.ErrorT("upload incomplete: received %d of %d bytes", n, size)
+	}
+
+	conn.SetDeadline(time.Now().Add(fmResponseTimeout))
+	if err := readFMReply(conn, bufio.NewReader(conn), model.FMMagicUploaded); err != nil {
+		return nil, err
+	}
+
+	return &model.FMUploadResponse{
+		Size:   n,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+const (
+	fmBufferSize      = 1024 * 1024
+	fmResponseTimeout = 30 * time.Second
+)
+
+// startFMTask 通知 Agent 建立文件管理会话，返回流 ID
+func startFMTask(server *model.Server) (string, error) {
+	streamId, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+
+	rpc.NezhaHandlerSingleton.CreateStream(streamId)
+
+	fmData, _ := utils.Json.Marshal(&model.TaskFM{
+		StreamID: streamId,
+	})
+	if err := server.TaskStream.Send(&proto.Task{
+		Type: model.TaskTypeFM,
+		Data: string(fmData),
+	}); err != nil {
+		rpc.NezhaHandlerSingleton.CloseStream(streamId)
+		return "", err
+	}
+	return streamId, nil
+}
+
+// openFMStream 为一次 HTTP 文件传输建立文件管理会话，返回的连接关闭时结束会话
+func openFMStream(c *gin.Context, server *model.Server) (net.Conn, error) {
+	streamId, err := startFMTask(server)
+	if err != nil {
+		return nil, err
+	}
+
+	userConn, conn := net.Pipe()
+	if err := rpc.NezhaHandlerSingleton.UserConnected(streamId, userConn); err != nil {
+		rpc.NezhaHandlerSingleton.CloseStream(streamId)
+		return nil, err
+	}
+	// gin.Context 在请求结束后会被复用，需提前取得 logger
+	logger := requestLogger(c).With("stream_id", streamId, "server_id", server.ID)
+	logger.Debug("file manager task sent")
+	go func() {
+		if err := rpc.NezhaHandlerSingleton.StartStream(streamId, time.Second*10); err != nil {
+			logger.Warn("file transfer stream closed", "error", err)
+		}
+		// Agent 未连接或会话结束时关闭连接，使读写立即返回
+		conn.Close()
+		rpc.NezhaHandlerSingleton.CloseStream(streamId)
+	}()
+	return conn, nil
+}
+
+// readFMReply 读取 Agent 的回复，前缀不是 expected 时返回 Agent 给出的错误
+func readFMReply(conn net.Conn, r *bufio.Reader, expected []byte) error {
+	magic := make([]byte, len(expected))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return singleton.Localizer.ErrorT("no response from the agent: %v", err)
+	}
+	if bytes.Equal(magic, expected) {
+		return nil
+	}
+	if bytes.Equal(magic, model.FMMagicError) {
+		// 错误信息与前缀在同一条消息中，读取当前已收到的内容即可
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		msg := make([]byte, 4096)
+		n, _ := r.Read(msg)
+		return errors.New(string(msg[:n]))
+	}
+	return singleton.Localizer.ErrorT("unexpected response from the agent")
+}
+
+// parseByteRange 解析单个 bytes 范围，header 为空时返回整个文件
+func parseByteRange(header string, size int64) (start, length int64, partial, ok bool) {
+	if header == "" {
+		return 0, size, false, true
+	}
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, false
+	}
+
+	end := size - 1
+	if first == "" {
+		// bytes=-N 表示最后 N 个字节
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, false
+		}
+		start = max(size-n, 0)
+	} else {
+		var err error
+		if start, err = strconv.ParseInt(first, 10, 64); err != nil || start < 0 {
+			return 0, 0, false, false
+		}
+		if last != "" {
+			e, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || e < start {
+				return 0, 0, false, false
+			}
+			end = min(e, end)
+		}
+	}
+	if start >= size {
+		return 0, 0, false, false
+	}
+	return start, end - start + 1, true, true
+}
+
+func getConnectedServer(c *gin.Context) (*model.Server, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	singleton.ServerLock.RLock()
+	server := singleton.ServerList[id]
+	singleton.ServerLock.RUnlock()
+	if server == nil || server.TaskStream == nil {
+		return nil, singleton.Localizer.ErrorT("server not found or not connected")
+	}
+	if !server.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	return server, nil
+}
+
+// fmUploadGuard 检查网页文件管理中上传的文件大小，超过限制时返回错误并结束会话
+type fmUploadGuard struct {
+	io.ReadWriteCloser
+	limit     int64
+	remaining int64 // 当前上传尚未转发的字节数，期间的数据均为文件内容
+}
+
+func (g *fmUploadGuard) Read(p []byte) (int, error) {
+	n, err := g.ReadWriteCloser.Read(p)
+	if n == 0 {
+		return n, err
+	}
+	if g.remaining > 0 {
+		g.remaining -= int64(n)
+		return n, err
+	}
+	if p[0] == model.FMOpUpload && n >= 9 {
+		size := int64(binary.BigEndian.Uint64(p[1:9]))
+		if g.limit > 0 && size > g.limit {
+			msg := singleton.Localizer.Tf("file exceeds the maximum size of %d bytes", g.limit)
+			g.ReadWriteCloser.Write(append(bytes.Clone(model.FMMagicError), msg...))
+			return 0, io.EOF
+		}
+		g.remaining = size
+	}
+	return n, err
+}
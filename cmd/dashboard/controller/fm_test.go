@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseByteRange(t *testing.T) {
+	cases := []struct {
+		header  string
+		start   int64
+		length  int64
+		partial bool
+		ok      bool
+	}{
+		{"", 0, 100, false, true},
+		{"bytes=0-", 0, 100, true, true},
+		{"bytes=10-19", 10, 10, true, true},
+		{"bytes=90-200", 90, 10, true, true},
+		{"bytes=-30", 70, 30, true, true},
+		{"bytes=-300", 0, 100, true, true},
+		{"bytes=100-", 0, 0, false, false},
+		{"bytes=20-10", 0, 0, false, false},
+		{"bytes=0-1,5-6", 0, 0, false, false},
+		{"items=0-1", 0, 0, false, false},
+		{"bytes=-0", 0, 0, false, false},
+	}
+	for _, c := range cases {
+		start, length, partial, ok := parseByteRange(c.header, 100)
+		if start != c.start || length != c.length || partial != c.partial || ok != c.ok {
+			t.Errorf("%q: expected %d %d %v %v, got %d %d %v %v", c.header, c.start, c.length, c.partial, c.ok, start, length, partial, ok)
+		}
+	}
+}
+
+func TestHandleRawResponse(t *testing.T) {
+	cases := []struct {
+		name    string
+		handler handlerFunc[any]
+		code    int
+	}{
+		{"range not satisfiable", func(c *gin.Context) (any, error) {
+			c.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+			return nil, nil
+		}, http.StatusRequestedRangeNotSatisfiable},
+		{"empty file", func(c *gin.Context) (any, error) {
+			c.Status(http.StatusOK)
+			c.Writer.WriteHeaderNow()
+			return nil, nil
+		}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		handle(c, tc.handler)
+		if w.Code != tc.code || w.Body.Len() != 0 {
+			t.Errorf("%s: got status %d, body %q", tc.name, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+var (
+	healthBufferPool  = sync.Pool{New: func() any { b := make([]byte, 0, 128); return &b }}
+	healthContentType = []string{"application/json; charset=utf-8"}
+)
+
+// Liveness probe
+// @Summary Liveness probe
+// @Schemes
+// @Description Returns 200 when the database is reachable, 503 otherwise
+// @Tags common
+// @Produce json
+// @Success 200 {object} model.HealthStatus
+// @Failure 503 {object} model.HealthStatus
+// @Router /healthz [get]
+func healthz(c *gin.Context) {
+	h := singleton.CheckHealth(c.Request.Context())
+	writeHealth(c, &h, h.Database)
+}
+
+// Readiness probe
+// @Summary Readiness probe
+// @Schemes
+// @Description Returns 200 once startup has finished, the database is reachable and the agent listener is up, 503 otherwise
+// @Tags common
+// @Produce json
+// @Success 200 {object} model.HealthStatus
+// @Failure 503 {object} model.HealthStatus
+// @Router /readyz [get]
+func readyz(c *gin.Context) {
+	h := singleton.CheckHealth(c.Request.Context())
+	writeHealth(c, &h, h.Ready && h.Database && h.Listener)
+}
+
+// writeHealth 手动拼接 JSON 并复用缓冲区，避免探针请求产生内存分配
+func writeHealth(c *gin.Context, h *model.HealthStatus, ok bool) {
+	code, status := http.StatusOK, "ok"
+	if !ok {
+		code, status = http.StatusServiceUnavailable, "unavailable"
+	}
+
+	bp := healthBufferPool.Get().(*[]byte)
+	b := append((*bp)[:0], `{"status":"`...)
+	b = append(b, status...)
+	b = append(b, `","database":`...)
+	b = strconv.AppendBool(b, h.Database)
+	b = append(b, `,"listener":`...)
+	b = strconv.AppendBool(b, h.Listener)
+	b = append(b, `,"ready":`...)
+	b = strconv.AppendBool(b, h.Ready)
+	b = append(b, `,"agents":`...)
+	b = strconv.AppendInt(b, int64(h.Agents), 10)
+	b = append(b, '}')
+
+	c.Writer.Header()["Content-Type"] = healthContentType
+	c.Writer.WriteHeader(code)
+	c.Writer.Write(b)
+
+	*bp = b
+	healthBufferPool.Put(bp)
+}
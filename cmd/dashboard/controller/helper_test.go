@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+var (
+	testEnvOnce   sync.Once
+	testRouter    http.Handler
+	testJWT       *jwt.GinJWTMiddleware
+	testUserCount int
+)
+
+// testDashboard 初始化使用临时数据库的面板，返回完整的路由，同一进程内只初始化一次
+func testDashboard(t *testing.T) http.Handler {
+	t.Helper()
+	testEnvOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "nezha-controller-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		singleton.InitFrontendTemplates()
+		singleton.InitConfigFromPath(filepath.Join(dir, "config.yaml"))
+		singleton.Conf.RateLimit.Allowlist = "0.0.0.0/0,::/0"
+		singleton.InitTimezoneAndCache()
+		// 与 model 包的测试一样，设置 NZ_TEST_POSTGRES_DSN 后使用 PostgreSQL
+		if dsn := os.Getenv("NZ_TEST_POSTGRES_DSN"); dsn != "" {
+			singleton.Conf.Database.Type = model.DatabasePostgres
+			singleton.Conf.Database.DSN = testPostgresDSN(t, dsn)
+		}
+		singleton.InitDBFromPath(filepath.Join(dir, "sqlite.db"))
+		singleton.LoadSingleton()
+		// 不执行服务监控任务，只处理测试中直接上报的结果
+		singleton.NewServiceSentinel(make(chan model.Service, 16))
+
+		testRouter = ServeWeb(fstest.MapFS{})
+		if testJWT, err = jwt.New(initParams()); err != nil {
+			t.Fatal(err)
+		}
+		if err := testJWT.MiddlewareInit(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return testRouter
+}
+
+// testPostgresSchema 控制器测试在 PostgreSQL 中使用的 schema，与并行运行的 model 包测试的表互不影响
+const testPostgresSchema = "nezha_controller_test"
+
+// testPostgresDSN 重建测试使用的 schema，返回以其为 search_path 的 DSN。
+// schema 中已有的表会被删除，需使用专门用于测试的数据库
+func testPostgresDSN(t *testing.T, dsn string) string {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{"DROP SCHEMA IF EXISTS " + testPostgresSchema + " CASCADE", "CREATE SCHEMA " + testPostgresSchema} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + testPostgresSchema
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "search_path=" + testPostgresSchema
+}
+
+// testCreateUser 创建用户并返回该用户的访问令牌
+func testCreateUser(t *testing.T, role uint8, permissions uint64) (*model.User, string) {
+	t.Helper()
+	testDashboard(t)
+	testUserCount++
+	u := &model.User{
+		Username:    fmt.Sprintf("test-user-%d", testUserCount),
+		Role:        role,
+		Permissions: permissions,
+	}
+	if err := singleton.DB.Create(u).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 带默认值的字段创建时会忽略零值
+	if err := singleton.DB.Model(u).Update("permissions", permissions).Error; err != nil {
+		t.Fatal(err)
+	}
+	singleton.OnUserUpdate(u)
+	return u, testToken(t, u)
+}
+
+// testToken 为用户开启新的会话并签发访问令牌
+func testToken(t *testing.T, u *model.User) string {
+	t.Helper()
+	if err := startSession(u); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := generateToken(testJWT, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// testRequest 以 token 发起请求，返回状态码与解析后的响应
+func testRequest(t *testing.T, token, method, path string, body any) (int, model.CommonResponse[json.RawMessage]) {
+	t.Helper()
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return testServe(t, header, method, path, body)
+}
+
+// testApiTokenRequest 以 API 令牌发起请求
+func testApiTokenRequest(t *testing.T, secret, method, path string, body any) (int, model.CommonResponse[json.RawMessage]) {
+	t.Helper()
+	header := http.Header{}
+	header.Set(model.ApiTokenHeader, secret)
+	return testServe(t, header, method, path, body)
+}
+
+func testServe(t *testing.T, header http.Header, method, path string, body any) (int, model.CommonResponse[json.RawMessage]) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testDashboard(t).ServeHTTP(w, req)
+
+	var resp model.CommonResponse[json.RawMessage]
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+// testCreateApiToken 为用户创建 API 令牌并返回令牌明文
+func testCreateApiToken(t *testing.T, token string, readOnly bool) string {
+	t.Helper()
+	code, resp := testRequest(t, token, http.MethodPost, "/api/v1/profile/token", model.ApiTokenForm{Name: "test", ReadOnly: readOnly})
+	if !testAllowed(code, resp) {
+		t.Fatalf("create api token: status %d, response %+v", code, resp)
+	}
+	var tr model.ApiTokenResponse
+	if err := json.Unmarshal(resp.Data, &tr); err != nil {
+		t.Fatal(err)
+	}
+	return tr.Token
+}
+
+// testAllowed 判断请求是否执行成功
+func testAllowed(code int, resp model.CommonResponse[json.RawMessage]) bool {
+	return code == http.StatusOK && resp.Success
+}
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/cmd/dashboard/controller/waf"
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+const ctxKeyIncomingWebhook = "ckiw"
+
+// List incoming webhooks
+// @Summary List incoming webhooks
+// @Security BearerAuth
+// @Schemes
+// @Description List incoming webhooks
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.IncomingWebhook]
+// @Router /incoming-webhook [get]
+func listIncomingWebhook(c *gin.Context) ([]*model.IncomingWebhook, error) {
+	var webhooks []*model.IncomingWebhook
+	if err := singleton.DB.Find(&webhooks).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return webhooks, nil
+}
+
+// Create incoming webhook
+// @Summary Create incoming webhook
+// @Security BearerAuth
+// @Schemes
+// @Description Create incoming webhook, the secret is only returned once
+// @Tags auth required
+// @Accept json
+// @param request body model.IncomingWebhookForm true "Incoming Webhook Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.IncomingWebhookResponse]
+// @Router /incoming-webhook [post]
+func createIncomingWebhook(c *gin.Context) (*model.IncomingWebhookResponse, error) {
+	var wf model.IncomingWebhookForm
+	if err := c.ShouldBindJSON(&wf); err != nil {
+		return nil, err
+	}
+	if wf.Name == "" {
+		return nil, singleton.Localizer.ErrorT("webhook name can't be empty")
+	}
+
+	secret, err := utils.GenerateRandomString(40)
+	if err != nil {
+		return nil, err
+	}
+	secret = model.IncomingWebhookSecretPrefix + secret
+
+	w := model.IncomingWebhook{
+		Name:       wf.Name,
+		SecretHash: model.HashApiToken(secret),
+	}
+	w.UserID = getUid(c)
+	if err := singleton.DB.Create(&w).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.IncomingWebhookResponse{
+		ID:     w.ID,
+		Secret: secret,
+	}, nil
+}
+
+// Batch delete incoming webhooks
+// @Summary Batch delete incoming webhooks
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete incoming webhooks, annotations pushed by them are kept
+// @Tags auth required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/incoming-webhook [post]
+func batchDeleteIncomingWebhook(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	var webhooks []model.IncomingWebhook
+	if err := singleton.DB.Find(&webhooks, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	for _, w := range webhooks {
+		if !w.HasPermission(c) {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+
+	if err := singleton.DB.Unscoped().Delete(&model.IncomingWebhook{}, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
+
+// incomingWebhookAuth 校验传入 Webhook 的密钥，并按 Webhook 限制推送频率
+func incomingWebhookAuth(c *gin.Context) {
+	ip := c.GetString(model.CtxKeyRealIPStr)
+	var w model.IncomingWebhook
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	secret := c.GetHeader(model.IncomingWebhookSecretHeader)
+	if secret == "" || singleton.DB.First(&w, id).Error != nil ||
+		subtle.ConstantTimeCompare([]byte(model.HashApiToken(secret)), []byte(w.SecretHash)) != 1 {
+		if err := model.BlockIP(singleton.DB, ip, model.WAFBlockReasonTypeBruteForceToken, model.BlockIDToken); err != nil {
+			waf.ShowBlockPage(c, err)
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, newErrorResponse(singleton.Localizer.ErrorT("unauthorized")))
+		return
+	}
+	model.ClearIP(singleton.DB, ip, model.BlockIDToken)
+
+	if !singleton.Conf.RateLimit.Disabled {
+		if ok, wait := webhookRateLimiter.Allow(utils.Itoa(w.ID), time.Now()); !ok {
+			abortRateLimited(c, wait)
+			return
+		}
+	}
+
+	c.Set(ctxKeyIncomingWebhook, &w)
+	c.Next()
+}
+
+// Push event to incoming webhook
+// @Summary Push event to incoming webhook
+// @Schemes
+// @Description Record an annotation on a server or server group, such as a deployment, authenticated by the X-Webhook-Secret header.
+// @Description The webhook owner must have access to the server or group.
+// @Tags common
+// @Accept json
+// @Param id path uint true "Webhook ID"
+// @Param X-Webhook-Secret header string true "Webhook secret"
+// @param request body model.AnnotationForm true "Event"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.Annotation]
+// @Router /webhook/{id}/event [post]
+func receiveWebhookEvent(c *gin.Context) (*model.Annotation, error) {
+	w := c.MustGet(ctxKeyIncomingWebhook).(*model.IncomingWebhook)
+
+	var af model.AnnotationForm
+	if err := c.ShouldBindJSON(&af); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	at, err := af.Validate(now)
+	if err != nil {
+		return nil, err
+	}
+	if !webhookCanAnnotate(w.UserID, &af) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	a := model.Annotation{
+		WebhookID:     w.ID,
+		ServerID:      af.ServerID,
+		ServerGroupID: af.ServerGroupID,
+		Time:          at,
+		Title:         af.Title,
+		Text:          af.Text,
+	}
+	a.UserID = w.UserID
+	if err := singleton.DB.Create(&a).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if w.LastUsedAt == nil || now.Sub(*w.LastUsedAt) > time.Minute {
+		singleton.DB.Model(w).Update("last_used_at", now)
+	}
+	return &a, nil
+}
+
+// webhookCanAnnotate Webhook 的所有者是否可以访问事件关联的服务器或分组
+func webhookCanAnnotate(uid uint64, af *model.AnnotationForm) bool {
+	if af.ServerID != 0 {
+		singleton.ServerLock.RLock()
+		server, ok := singleton.ServerList[af.ServerID]
+		singleton.ServerLock.RUnlock()
+		return ok && singleton.ServerAccessibleBy(uid, server)
+	}
+
+	var group model.ServerGroup
+	if err := singleton.DB.First(&group, af.ServerGroupID).Error; err != nil {
+		return false
+	}
+	return group.UserID == uid || singleton.UserAdministers(uid, group.UserID)
+}
+
+// List server annotations
+// @Summary List server annotations
+// @Security BearerAuth
+// @Schemes
+// @Description List annotations of a server and its groups in the time range, defaults to the last 24 hours, use the same range as the metric history to overlay them
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param from query int false "Start timestamp in seconds"
+// @Param to query int false "End timestamp in seconds"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.Annotation]
+// @Router /server/{id}/annotation [get]
+func listServerAnnotation(c *gin.Context) ([]model.Annotation, error) {
+	server, err := getServerWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	for key, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := c.Query(key); v != "" {
+			ts, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			*t = time.Unix(ts, 0)
+		}
+	}
+	if !from.Before(to) {
+		return nil, singleton.Localizer.ErrorT("invalid time range")
+	}
+
+	query := singleton.DB.Where("server_id = ?", server.ID)
+	if groups := singleton.GetServerGroups(server.ID); len(groups) > 0 {
+		query = query.Or("server_group_id IN (?)", groups)
+	}
+	var annotations []model.Annotation
+	if err := singleton.DB.Where(query).Where("time >= ? AND time < ?", from, to).
+		Order("time").Find(&annotations).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return annotations, nil
+}
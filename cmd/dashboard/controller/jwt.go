@@ -2,11 +2,15 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
+	gojwt "github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
@@ -16,44 +20,151 @@ import (
 	"github.com/nezhahq/nezha/service/singleton"
 )
 
+const (
+	jwtClaimTokenVersion = "ver"
+	// 登录会话 ID，与刷新令牌链相同，用于闲置超时
+	jwtClaimSession = "sid"
+	// 模拟登录的管理员、其令牌版本与模拟的到期时间
+	jwtClaimImpersonator        = "imp"
+	jwtClaimImpersonatorVersion = "imp_ver"
+	jwtClaimImpersonationExpire = "imp_exp"
+
+	refreshTokenCookie     = "nz-refresh"
+	refreshTokenCookiePath = "/api/v1/auth/refresh"
+
+	// 登录成功的用户，供 LoginResponse 签发刷新令牌
+	ctxKeyLoginUser = "cklu"
+	// 访问令牌因会话闲置超时被拒绝
+	ctxKeySessionIdle = "cksi"
+)
+
 func initParams() *jwt.GinJWTMiddleware {
 	return &jwt.GinJWTMiddleware{
 		Realm:       singleton.Conf.SiteName,
-		Key:         []byte(singleton.Conf.JWTSecretKey),
+		KeyFunc:     singleton.JWTVerifyKey, // 按 kid 选择校验密钥，令牌由 generateToken 签发
 		CookieName:  "nz-jwt",
 		SendCookie:  true,
-		Timeout:     time.Hour,
+		Timeout:     singleton.AccessTokenTTL,
 		MaxRefresh:  time.Hour,
 		IdentityKey: model.CtxKeyAuthorizedUser,
 		PayloadFunc: payloadFunc(),
 
-		IdentityHandler: identityHandler(),
-		Authenticator:   authenticator(),
-		Authorizator:    authorizator(),
-		Unauthorized:    unauthorized(),
-		TokenLookup:     "header: Authorization, query: token, cookie: nz-jwt",
-		TokenHeadName:   "Bearer",
-		TimeFunc:        time.Now,
+		IdentityHandler:       identityHandler(),
+		Authenticator:         authenticator(),
+		Authorizator:          authorizator(),
+		Unauthorized:          unauthorized(),
+		HTTPStatusMessageFunc: httpStatusMessage(),
+		TokenLookup:           "header: Authorization, query: token, cookie: nz-jwt",
+		TokenHeadName:         "Bearer",
+		TimeFunc:              time.Now,
 
 		LoginResponse: func(c *gin.Context, code int, token string, expire time.Time) {
-			c.JSON(http.StatusOK, model.CommonResponse[model.LoginResponse]{
+			resp := &model.LoginResponse{
+				Token:  token,
+				Expire: expire.Format(time.RFC3339),
+			}
+			if user, ok := c.Value(ctxKeyLoginUser).(*model.User); ok {
+				// 签发失败时仍返回访问令牌，与只支持单一令牌的客户端行为一致
+				if err := attachRefreshToken(c, resp, user); err != nil {
+					requestLogger(c).Error("issue refresh token failed", "error", err)
+				}
+			}
+			c.JSON(http.StatusOK, model.CommonResponse[*model.LoginResponse]{
 				Success: true,
-				Data: model.LoginResponse{
-					Token:  token,
-					Expire: expire.Format(time.RFC3339),
-				},
+				Data:    resp,
 			})
 		},
 		RefreshResponse: refreshResponse,
 	}
 }
 
+// generateToken 使用当前签名密钥签发访问令牌，代替 mw.TokenGenerator 以支持非对称算法与密钥轮换
+func generateToken(mw *jwt.GinJWTMiddleware, data any) (string, time.Time, error) {
+	return signClaims(mw, mw.PayloadFunc(data))
+}
+
+func signClaims(mw *jwt.GinJWTMiddleware, claims jwt.MapClaims) (string, time.Time, error) {
+	now := mw.TimeFunc()
+	expire := now.Add(mw.Timeout)
+	claims["exp"] = expire.Unix()
+	claims["orig_iat"] = now.Unix()
+	token, err := singleton.SignJWT(gojwt.MapClaims(claims))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expire, nil
+}
+
+// tokenIssuedAt 返回访问令牌的签发时间，续期时更新
+func tokenIssuedAt(claims map[string]any) time.Time {
+	iat, _ := claims["orig_iat"].(float64)
+	return time.Unix(int64(iat), 0)
+}
+
+// loginHandler 与 mw.LoginHandler 相同，但使用 generateToken 签发令牌
+func loginHandler(mw *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := mw.Authenticator(c)
+		if err != nil {
+			mw.Unauthorized(c, http.StatusUnauthorized, mw.HTTPStatusMessageFunc(err, c))
+			c.Abort()
+			return
+		}
+
+		token, expire, err := generateToken(mw, data)
+		if err != nil {
+			mw.Unauthorized(c, http.StatusUnauthorized, mw.HTTPStatusMessageFunc(jwt.ErrFailedTokenCreation, c))
+			c.Abort()
+			return
+		}
+		mw.SetCookie(c, token)
+		mw.LoginResponse(c, http.StatusOK, token, expire)
+	}
+}
+
+// refreshHandler 与 mw.RefreshHandler 相同，续期的令牌使用当前签名密钥
+func refreshHandler(mw *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := mw.CheckIfTokenExpire(c)
+		if err != nil {
+			mw.Unauthorized(c, http.StatusUnauthorized, mw.HTTPStatusMessageFunc(err, c))
+			c.Abort()
+			return
+		}
+		if sid, _ := claims[jwtClaimSession].(string); singleton.SessionIdleExpired(sid, tokenIssuedAt(claims)) {
+			c.Set(ctxKeySessionIdle, true)
+			mw.Unauthorized(c, http.StatusUnauthorized, mw.HTTPStatusMessageFunc(jwt.ErrExpiredToken, c))
+			c.Abort()
+			return
+		}
+
+		token, expire, err := signClaims(mw, jwt.MapClaims(claims))
+		if err != nil {
+			mw.Unauthorized(c, http.StatusUnauthorized, mw.HTTPStatusMessageFunc(jwt.ErrFailedTokenCreation, c))
+			c.Abort()
+			return
+		}
+		mw.SetCookie(c, token)
+		mw.RefreshResponse(c, http.StatusOK, token, expire)
+	}
+}
+
 func payloadFunc() func(data interface{}) jwt.MapClaims {
 	return func(data interface{}) jwt.MapClaims {
-		if v, ok := data.(string); ok {
-			return jwt.MapClaims{
-				model.CtxKeyAuthorizedUser: v,
+		if v, ok := data.(*model.User); ok {
+			claims := jwt.MapClaims{
+				model.CtxKeyAuthorizedUser: utils.Itoa(v.ID),
+				jwtClaimTokenVersion:       v.TokenVersion,
+			}
+			if v.SessionID != "" {
+				claims[jwtClaimSession] = v.SessionID
 			}
+			if imp := v.Impersonation; imp != nil {
+				claims[jwtClaimImpersonator] = utils.Itoa(imp.UserID)
+				claims[jwtClaimImpersonatorVersion] = imp.TokenVersion
+				claims[jwtClaimImpersonationExpire] = imp.ExpireAt.Unix()
+			}
+			return claims
 		}
 		return jwt.MapClaims{}
 	}
@@ -62,15 +173,66 @@ func payloadFunc() func(data interface{}) jwt.MapClaims {
 func identityHandler() func(c *gin.Context) interface{} {
 	return func(c *gin.Context) interface{} {
 		claims := jwt.ExtractClaims(c)
-		userId := claims[model.CtxKeyAuthorizedUser].(string)
+		uid, _ := claims[model.CtxKeyAuthorizedUser].(string)
+		userId, err := strconv.ParseUint(uid, 10, 64)
+		if err != nil {
+			return nil
+		}
+		// 旧版本签发的令牌没有版本号，视为 0
+		version, _ := claims[jwtClaimTokenVersion].(float64)
+		if !singleton.CheckTokenVersion(userId, uint64(version)) {
+			return nil
+		}
+		// 任何通过认证的请求都视为会话的一次活动
+		sid, _ := claims[jwtClaimSession].(string)
+		if !singleton.TouchSession(sid, tokenIssuedAt(claims)) {
+			c.Set(ctxKeySessionIdle, true)
+			if err := singleton.RevokeRefreshTokenFamily(sid); err != nil {
+				requestLogger(c).Error("revoke idle session failed", "error", err)
+			}
+			return nil
+		}
 		var user model.User
 		if err := singleton.DB.First(&user, userId).Error; err != nil {
 			return nil
 		}
+		user.SessionID = sid
+		if _, ok := claims[jwtClaimImpersonator]; ok {
+			imp := impersonationFromClaims(claims)
+			if imp == nil {
+				return nil
+			}
+			user.Impersonation = imp
+		}
 		return &user
 	}
 }
 
+// impersonationFromClaims 校验模拟登录：未到期，且发起人仍为管理员、会话未被撤销
+func impersonationFromClaims(claims jwt.MapClaims) *model.Impersonation {
+	uid, _ := claims[jwtClaimImpersonator].(string)
+	adminID, err := strconv.ParseUint(uid, 10, 64)
+	if err != nil {
+		return nil
+	}
+	version, _ := claims[jwtClaimImpersonatorVersion].(float64)
+	expire, _ := claims[jwtClaimImpersonationExpire].(float64)
+	expireAt := time.Unix(int64(expire), 0)
+	if time.Now().After(expireAt) || !singleton.CheckTokenVersion(adminID, uint64(version)) {
+		return nil
+	}
+	var admin model.User
+	if err := singleton.DB.Select("id", "username", "role", "tenant_id").First(&admin, adminID).Error; err != nil || !admin.IsSuperAdmin() {
+		return nil
+	}
+	return &model.Impersonation{
+		UserID:       admin.ID,
+		Username:     admin.Username,
+		ExpireAt:     expireAt,
+		TokenVersion: uint64(version),
+	}
+}
+
 // User Login
 // @Summary user login
 // @Schemes
@@ -89,21 +251,56 @@ func authenticator() func(c *gin.Context) (interface{}, error) {
 
 		var user model.User
 		realip := c.GetString(model.CtxKeyRealIPStr)
-		if err := singleton.DB.Select("id", "password").Where("username = ?", loginVals.Username).First(&user).Error; err != nil {
+		if remaining := singleton.CheckLoginLocked(loginVals.Username, realip); remaining > 0 {
+			seconds := int(math.Ceil(remaining.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			return nil, &loginError{singleton.Localizer.ErrorT("account temporarily locked, please retry after %d seconds", seconds)}
+		}
+		if err := singleton.DB.Select("id", "password", "two_factor", "two_factor_secret", "two_factor_last_step", "two_factor_recovery_codes_raw", "token_version", "role", "oidc_subject").Where("username = ?", loginVals.Username).First(&user).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeLoginFail, model.BlockIDUnknownUser)
 			}
+			singleton.RecordLoginFailure(c.Request.Context(), loginVals.Username, realip)
 			return nil, jwt.ErrFailedAuthentication
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginVals.Password)); err != nil {
 			model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeLoginFail, int64(user.ID))
+			singleton.RecordLoginFailure(c.Request.Context(), loginVals.Username, realip)
 			return nil, jwt.ErrFailedAuthentication
 		}
 
+		// 禁用密码登录后仅保留未关联 OIDC 的管理员，避免提供方不可用时无法登录
+		if singleton.Conf.OIDC.Enabled() && singleton.Conf.OIDC.DisablePasswordLogin &&
+			(user.Role != model.RoleAdmin || user.OIDCSubject != "") {
+			return nil, &loginError{singleton.Localizer.ErrorT("password login is disabled, please sign in with SSO")}
+		}
+
+		if user.TwoFactor {
+			if loginVals.OTP == "" {
+				return nil, &loginError{singleton.Localizer.ErrorT("two-factor authentication code required")}
+			}
+			if !user.VerifyTwoFactor(loginVals.OTP) {
+				model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeLoginFail, int64(user.ID))
+				singleton.RecordLoginFailure(c.Request.Context(), loginVals.Username, realip)
+				return nil, jwt.ErrFailedAuthentication
+			}
+			if err := singleton.DB.Model(&user).Select("two_factor_last_step", "two_factor_recovery_codes_raw").Updates(&user).Error; err != nil {
+				return nil, jwt.ErrFailedAuthentication
+			}
+		}
+
+		if err := startSession(&user); err != nil {
+			return nil, jwt.ErrFailedTokenCreation
+		}
+		singleton.ResetLoginFailure(loginVals.Username, realip)
+		if err := singleton.RecordLogin(user.ID, realip, c.Request.UserAgent()); err != nil {
+			requestLogger(c).Error("record login failed", "error", err)
+		}
 		model.ClearIP(singleton.DB, realip, model.BlockIDUnknownUser)
 		model.ClearIP(singleton.DB, realip, int64(user.ID))
-		return utils.Itoa(user.ID), nil
+		c.Set(ctxKeyLoginUser, &user)
+		return &user, nil
 	}
 }
 
@@ -114,11 +311,29 @@ func authorizator() func(data interface{}, c *gin.Context) bool {
 	}
 }
 
+// loginError 会原样返回给前端，其余认证错误统一为 ApiErrorUnauthorized
+type loginError struct {
+	error
+}
+
+func httpStatusMessage() func(e error, c *gin.Context) string {
+	return func(e error, c *gin.Context) string {
+		var le *loginError
+		if errors.As(e, &le) {
+			return le.Error()
+		}
+		if c.GetBool(ctxKeySessionIdle) {
+			return singleton.Localizer.T("session expired due to inactivity")
+		}
+		return "ApiErrorUnauthorized"
+	}
+}
+
 func unauthorized() func(c *gin.Context, code int, message string) {
 	return func(c *gin.Context, code int, message string) {
 		c.JSON(http.StatusOK, model.CommonResponse[any]{
 			Success: false,
-			Error:   "ApiErrorUnauthorized",
+			Error:   message,
 		})
 	}
 }
@@ -127,12 +342,14 @@ func unauthorized() func(c *gin.Context, code int, message string) {
 // @Summary Refresh token
 // @Security BearerAuth
 // @Schemes
-// @Description Refresh token
+// @Description Refresh an unexpired access token, deprecated in favor of /auth/refresh
 // @Tags auth required
+// @Deprecated
 // @Produce json
 // @Success 200 {object} model.CommonResponse[model.LoginResponse]
 // @Router /refresh-token [get]
 func refreshResponse(c *gin.Context, code int, token string, expire time.Time) {
+	c.Header("Deprecation", "true")
 	c.JSON(http.StatusOK, model.CommonResponse[model.LoginResponse]{
 		Success: true,
 		Data: model.LoginResponse{
@@ -142,8 +359,142 @@ func refreshResponse(c *gin.Context, code int, token string, expire time.Time) {
 	})
 }
 
+// Refresh access token
+// @Summary Refresh access token
+// @Schemes
+// @Description Exchange a refresh token for a new access token and refresh token, the old refresh token becomes invalid.
+// @Description Reusing a rotated refresh token revokes all refresh tokens issued from the same login.
+// @Accept json
+// @param request body model.RefreshTokenRequest false "Refresh token, read from cookie if omitted"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.LoginResponse]
+// @Router /auth/refresh [post]
+func refreshAccessToken(mw *jwt.GinJWTMiddleware) handlerFunc[*model.LoginResponse] {
+	return func(c *gin.Context) (*model.LoginResponse, error) {
+		var req model.RefreshTokenRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				return nil, err
+			}
+		}
+		if req.RefreshToken == "" {
+			req.RefreshToken, _ = c.Cookie(refreshTokenCookie)
+		}
+		if req.RefreshToken == "" {
+			return nil, singleton.Localizer.ErrorT("invalid refresh token")
+		}
+
+		user, refreshToken, refreshExpire, err := singleton.RotateRefreshToken(c.Request.Context(), req.RefreshToken)
+		if err != nil {
+			setRefreshTokenCookie(c, "", time.Time{})
+			return nil, err
+		}
+
+		token, expire, err := generateToken(mw, user)
+		if err != nil {
+			return nil, err
+		}
+		mw.SetCookie(c, token)
+		setRefreshTokenCookie(c, refreshToken, refreshExpire)
+
+		return &model.LoginResponse{
+			Token:         token,
+			Expire:        expire.Format(time.RFC3339),
+			RefreshToken:  refreshToken,
+			RefreshExpire: refreshExpire.Format(time.RFC3339),
+		}, nil
+	}
+}
+
+// startSession 为登录成功的用户分配新的会话 ID
+func startSession(user *model.User) error {
+	sid, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+	user.SessionID = sid
+	return nil
+}
+
+// issueSession 为登录成功的用户签发访问令牌与新的刷新令牌链
+func issueSession(c *gin.Context, mw *jwt.GinJWTMiddleware, user *model.User) (*model.LoginResponse, error) {
+	if err := startSession(user); err != nil {
+		return nil, err
+	}
+	token, expire, err := generateToken(mw, user)
+	if err != nil {
+		return nil, err
+	}
+	mw.SetCookie(c, token)
+
+	resp := &model.LoginResponse{
+		Token:  token,
+		Expire: expire.Format(time.RFC3339),
+	}
+	if err := attachRefreshToken(c, resp, user); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func attachRefreshToken(c *gin.Context, resp *model.LoginResponse, user *model.User) error {
+	refreshToken, refreshExpire, err := singleton.IssueRefreshToken(singleton.DB, user, user.SessionID)
+	if err != nil {
+		return err
+	}
+	setRefreshTokenCookie(c, refreshToken, refreshExpire)
+	resp.RefreshToken = refreshToken
+	resp.RefreshExpire = refreshExpire.Format(time.RFC3339)
+	return nil
+}
+
+// setRefreshTokenCookie 刷新令牌只发送给刷新接口，token 为空时删除 Cookie
+func setRefreshTokenCookie(c *gin.Context, token string, expire time.Time) {
+	maxAge := -1
+	if token != "" {
+		maxAge = int(time.Until(expire).Seconds())
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(refreshTokenCookie, token, maxAge, refreshTokenCookiePath, "", c.Request.TLS != nil, true)
+}
+
+// authMiddlewareFunc 优先使用 API 令牌认证，未携带令牌时回退到 JWT
+func authMiddlewareFunc(mw *jwt.GinJWTMiddleware) func(c *gin.Context) {
+	jwtMiddleware := mw.MiddlewareFunc()
+	return func(c *gin.Context) {
+		secret := c.GetHeader(model.ApiTokenHeader)
+		if secret == "" {
+			jwtMiddleware(c)
+			return
+		}
+
+		user := authenticateApiToken(c, secret)
+		if user == nil {
+			if err := model.BlockIP(singleton.DB, c.GetString(model.CtxKeyRealIPStr), model.WAFBlockReasonTypeBruteForceToken, model.BlockIDToken); err != nil {
+				waf.ShowBlockPage(c, err)
+				return
+			}
+			mw.Unauthorized(c, http.StatusUnauthorized, mw.HTTPStatusMessageFunc(jwt.ErrForbidden, c))
+			c.Abort()
+			return
+		}
+
+		model.ClearIP(singleton.DB, c.GetString(model.CtxKeyRealIPStr), model.BlockIDToken)
+		c.Set(mw.IdentityKey, user)
+		c.Next()
+	}
+}
+
 func optionalAuthMiddleware(mw *jwt.GinJWTMiddleware) func(c *gin.Context) {
 	return func(c *gin.Context) {
+		if secret := c.GetHeader(model.ApiTokenHeader); secret != "" {
+			if user := authenticateApiToken(c, secret); user != nil {
+				c.Set(mw.IdentityKey, user)
+			}
+			c.Next()
+			return
+		}
+
 		claims, err := mw.GetClaimsFromJWT(c)
 		if err != nil {
 			return
@@ -174,7 +525,7 @@ func optionalAuthMiddleware(mw *jwt.GinJWTMiddleware) func(c *gin.Context) {
 		if identity != nil {
 			model.ClearIP(singleton.DB, c.GetString(model.CtxKeyRealIPStr), model.BlockIDToken)
 			c.Set(mw.IdentityKey, identity)
-		} else {
+		} else if !c.GetBool(ctxKeySessionIdle) {
 			if err := model.BlockIP(singleton.DB, c.GetString(model.CtxKeyRealIPStr), model.WAFBlockReasonTypeBruteForceToken, model.BlockIDToken); err != nil {
 				waf.ShowBlockPage(c, err)
 				return
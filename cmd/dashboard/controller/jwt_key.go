@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// JSON Web Key Set
+// @Summary JSON Web Key Set
+// @Schemes
+// @Description Public keys for verifying access tokens signed with RS256 or ES256, including retired keys whose tokens have not expired yet. Empty when using HS256.
+// @Tags common
+// @Produce json
+// @Success 200 {object} model.JWKSet
+// @Router /.well-known/jwks.json [get]
+func serveJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, singleton.JWKS())
+}
+
+// List JWT signing keys
+// @Summary List JWT signing keys
+// @Security BearerAuth
+// @Schemes
+// @Description List the current signing key and retired keys still accepted for verification, the first one is the current key
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.JWTKey]
+// @Router /jwt-key [get]
+func listJWTKey(c *gin.Context) ([]*model.JWTKey, error) {
+	return singleton.JWTKeys(), nil
+}
+
+// Rotate JWT signing key
+// @Summary Rotate JWT signing key
+// @Security BearerAuth
+// @Schemes
+// @Description Generate a new signing key with the configured algorithm, tokens signed with the retired key stay valid until they expire
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.JWTKey]
+// @Router /jwt-key/rotate [post]
+func rotateJWTKey(c *gin.Context) (*model.JWTKey, error) {
+	k, err := singleton.RotateJWTKey()
+	if err != nil {
+		return nil, err
+	}
+
+	recordAuditLog(c, model.AuditActionJWTKeyRotate, auditTarget("jwt_key", k.ID), nil, gin.H{"kid": k.KID, "algorithm": k.Algorithm})
+	return k, nil
+}
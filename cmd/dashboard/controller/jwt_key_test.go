@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func TestJWTKeyEncryptedAtRest(t *testing.T) {
+	u, token := testCreateUser(t, model.RoleAdmin, 0)
+	alg := singleton.Conf.JWTAlgorithm
+	singleton.Conf.JWTAlgorithm = model.JWTAlgorithmES256
+	t.Cleanup(func() {
+		singleton.Conf.JWTAlgorithm = alg
+		if _, err := singleton.RotateJWTKey(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// 旧版本以明文保存的私钥在加载时加密
+	legacy, err := model.GenerateJWTKey(model.JWTAlgorithmES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	legacy.RetiredAt = &now
+	if err := singleton.DB.Create(legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rotate := func(token string) string {
+		t.Helper()
+		code, resp := testRequest(t, token, http.MethodPost, "/api/v1/jwt-key/rotate", nil)
+		if !testAllowed(code, resp) {
+			t.Fatalf("rotate jwt key: got status %d, response %+v", code, resp)
+		}
+		var k model.JWTKey
+		if err := json.Unmarshal(resp.Data, &k); err != nil {
+			t.Fatal(err)
+		}
+		return k.KID
+	}
+	kid := rotate(token)
+
+	sealed := func(master string) map[string]string {
+		t.Helper()
+		key, keyID := model.DeriveSecretKey(master)
+		plaintexts := make(map[string]string)
+		for _, kid := range []string{legacy.KID, kid} {
+			var k model.JWTKey
+			if err := singleton.DB.Where("kid = ?", kid).First(&k).Error; err != nil {
+				t.Fatal(err)
+			}
+			if k.KeyID != keyID || strings.Contains(k.PrivateKey, "PRIVATE KEY") {
+				t.Fatalf("jwt key %s not encrypted with the secrets key", kid)
+			}
+			plaintext, err := model.DecryptSecret(key, k.PrivateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintexts[kid] = plaintext
+		}
+		return plaintexts
+	}
+	before := sealed(singleton.Conf.SecretsKey)
+	if before[legacy.KID] != legacy.PrivateKey {
+		t.Fatal("legacy jwt key changed when encrypted")
+	}
+
+	// 轮换主密钥时重新加密，重新加载后仍可使用新密钥签发的令牌
+	if code, resp := testRequest(t, token, http.MethodPost, "/api/v1/secret/rotate-key", model.SecretKeyRotateForm{}); !testAllowed(code, resp) {
+		t.Fatalf("rotate secrets key: got status %d, response %+v", code, resp)
+	}
+	after := sealed(singleton.Conf.SecretsKey)
+	for kid, plaintext := range before {
+		if after[kid] != plaintext {
+			t.Fatalf("jwt key %s changed when re-encrypted", kid)
+		}
+	}
+	signed := testToken(t, u)
+	rotate(token)
+	if code, resp := testRequest(t, signed, http.MethodGet, "/api/v1/jwt-key", nil); !testAllowed(code, resp) {
+		t.Fatalf("token signed with key %s rejected after reload: got status %d, response %+v", kid, code, resp)
+	}
+}
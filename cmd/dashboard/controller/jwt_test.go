@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// testRefresh 使用刷新令牌换取新的令牌，失败时返回 nil
+func testRefresh(t *testing.T, refreshToken string) *model.LoginResponse {
+	t.Helper()
+	code, resp := testRequest(t, "", http.MethodPost, "/api/v1/auth/refresh", model.RefreshTokenRequest{RefreshToken: refreshToken})
+	if !testAllowed(code, resp) {
+		return nil
+	}
+	var lr model.LoginResponse
+	if err := json.Unmarshal(resp.Data, &lr); err != nil {
+		t.Fatal(err)
+	}
+	return &lr
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	u, _ := testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+	first, _, err := singleton.IssueRefreshToken(singleton.DB, u, u.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := testRefresh(t, first)
+	if rotated == nil || rotated.RefreshToken == "" || rotated.RefreshToken == first {
+		t.Fatalf("refresh should rotate the token, got %+v", rotated)
+	}
+	if code, resp := testRequest(t, rotated.Token, http.MethodGet, "/api/v1/profile", nil); !testAllowed(code, resp) {
+		t.Fatalf("refreshed access token rejected: status %d", code)
+	}
+
+	// 已轮换的令牌被重复使用时撤销整条令牌链，新令牌同样失效
+	if testRefresh(t, first) != nil {
+		t.Fatal("reused refresh token should be rejected")
+	}
+	if testRefresh(t, rotated.RefreshToken) != nil {
+		t.Fatal("token family should be revoked after reuse")
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	timeout := singleton.Conf.SessionIdleTimeout
+	singleton.Conf.SessionIdleTimeout = 1
+	t.Cleanup(func() { singleton.Conf.SessionIdleTimeout = timeout })
+
+	u, token := testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+	refreshToken, _, err := singleton.IssueRefreshToken(singleton.DB, u, u.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := testRequest(t, token, http.MethodGet, "/api/v1/profile", nil); !testAllowed(code, resp) {
+		t.Fatalf("active session rejected: status %d", code)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if code, resp := testRequest(t, token, http.MethodGet, "/api/v1/profile", nil); testAllowed(code, resp) {
+		t.Fatal("idle session should be rejected")
+	}
+	if testRefresh(t, refreshToken) != nil {
+		t.Fatal("refresh token of an idle session should be revoked")
+	}
+}
+
+func TestSessionIdleTimeoutAfterRestart(t *testing.T) {
+	u, _ := testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+	timeout := singleton.Conf.SessionIdleTimeout
+	singleton.Conf.SessionIdleTimeout = 60
+	t.Cleanup(func() { singleton.Conf.SessionIdleTimeout = timeout })
+
+	// 面板重启后内存中没有会话的记录，以令牌的签发时间判断是否闲置超时
+	idle, _, err := singleton.IssueRefreshToken(singleton.DB, u, u.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := singleton.DB.Model(&model.RefreshToken{}).Where("token_hash = ?", model.HashRefreshToken(idle)).
+		Update("created_at", time.Now().Add(-2*time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if testRefresh(t, idle) != nil {
+		t.Fatal("refresh token unused for longer than the idle timeout should be rejected")
+	}
+
+	testJWT.TimeFunc = func() time.Time { return time.Now().Add(-2 * time.Minute) }
+	token := testToken(t, u)
+	testJWT.TimeFunc = time.Now
+	if code, resp := testRequest(t, token, http.MethodGet, "/api/v1/profile", nil); testAllowed(code, resp) {
+		t.Fatal("access token issued before the idle timeout should be rejected")
+	}
+
+	u, token = testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+	fresh, _, err := singleton.IssueRefreshToken(singleton.DB, u, u.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := testRequest(t, token, http.MethodGet, "/api/v1/profile", nil); !testAllowed(code, resp) {
+		t.Fatalf("new session rejected: status %d", code)
+	}
+	if testRefresh(t, fresh) == nil {
+		t.Fatal("refresh token of an active session rejected")
+	}
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Prometheus metrics
+// @Summary Prometheus metrics
+// @Schemes
+// @Description Server states and dashboard counters in Prometheus text exposition format, requires the metrics_token configured in config.yaml
+// @Tags common
+// @Param Authorization header string true "Bearer <metrics_token>"
+// @Produce plain
+// @Success 200 {string} string
+// @Router /metrics [get]
+func serveMetrics(c *gin.Context) {
+	token := singleton.Conf.MetricsToken
+	if token == "" {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	buf := singleton.GetMetricsBuffer()
+	defer singleton.PutMetricsBuffer(buf)
+	singleton.WriteMetrics(buf)
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
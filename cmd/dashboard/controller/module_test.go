@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func TestRouteModule(t *testing.T) {
+	cases := map[string]string{
+		"/api/v1/cron":                       model.ModuleCron,
+		"/api/v1/cron/:id/history":           model.ModuleCron,
+		"/api/v1/ws/server":                  model.ModuleServer,
+		"/api/v1/ws/cron/history/:id":        model.ModuleCron,
+		"/api/v1/batch-delete/cron":          model.ModuleCron,
+		"/api/v1/user/:id/permissions":       model.ModuleUser,
+		"/api/v1/batch-delete/server-tag":    model.ModuleServer,
+		"/api/v1/notification-group/:id":     model.ModuleNotification,
+		"/api/v1/batch/alert-rule/toggle":    model.ModuleAlertRule,
+		"/api/v1/setting/password-policy":    "",
+		"/api/v1/profile":                    "",
+		"/api/v1/search":                     "",
+		"/api/v1/services-that-do-not-exist": "",
+	}
+	for path, want := range cases {
+		if got := routeModule(path); got != want {
+			t.Errorf("routeModule(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestModuleScopeOnOptionalAuthRoutes(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+	singleton.Conf.RoleModules.Member = model.RoleModules{Modules: []string{model.ModuleCron}}
+	t.Cleanup(func() { singleton.Conf.RoleModules.Member = model.RoleModules{} })
+
+	// 登录后隐藏的模块即使允许游客访问也返回 403，站点配置不受限制
+	for path, allow := range map[string]bool{
+		"/api/v1/ws/server":      false,
+		"/api/v1/server-group":   false,
+		"/api/v1/service":        false,
+		"/api/v1/service/1":      false,
+		"/api/v1/service/server": false,
+		"/api/v1/setting":        true,
+	} {
+		code, resp := testRequest(t, token, http.MethodGet, path, nil)
+		if (code != http.StatusForbidden) != allow {
+			t.Errorf("%s: got status %d, response %+v", path, code, resp)
+		}
+	}
+
+	// 游客不受角色配置限制
+	if code, resp := testRequest(t, "", http.MethodGet, "/api/v1/service", nil); !testAllowed(code, resp) {
+		t.Errorf("guest: got status %d, response %+v", code, resp)
+	}
+}
@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/copier"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List mute windows
+// @Summary List mute windows
+// @Security BearerAuth
+// @Schemes
+// @Description List notification mute windows
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.MuteWindow]
+// @Router /mute-window [get]
+func listMuteWindow(c *gin.Context) ([]*model.MuteWindow, error) {
+	singleton.MuteWindowLock.RLock()
+	defer singleton.MuteWindowLock.RUnlock()
+
+	var w []*model.MuteWindow
+	if err := copier.Copy(&w, &singleton.MuteWindowListSorted); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// List active mute windows
+// @Summary List active mute windows
+// @Security BearerAuth
+// @Schemes
+// @Description List mute windows which are currently suppressing notifications
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.MuteWindow]
+// @Router /mute-window/active [get]
+func listActiveMuteWindow(c *gin.Context) ([]*model.MuteWindow, error) {
+	return singleton.GetActiveMuteWindows(), nil
+}
+
+// Add mute window
+// @Summary Add mute window
+// @Security BearerAuth
+// @Schemes
+// @Description Add notification mute window
+// @Tags auth required
+// @Accept json
+// @param request body model.MuteWindowForm true "Mute Window Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /mute-window [post]
+func createMuteWindow(c *gin.Context) (uint64, error) {
+	var mf model.MuteWindowForm
+	if err := c.ShouldBindJSON(&mf); err != nil {
+		return 0, err
+	}
+
+	var w model.MuteWindow
+	if err := applyMuteWindowForm(c, &w, &mf); err != nil {
+		return 0, err
+	}
+	w.UserID = getUid(c)
+
+	if err := singleton.DB.Create(&w).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	singleton.OnMuteWindowUpdate(&w)
+	return w.ID, nil
+}
+
+// Edit mute window
+// @Summary Edit mute window
+// @Security BearerAuth
+// @Schemes
+// @Description Edit notification mute window
+// @Tags auth required
+// @Accept json
+// @param id path uint true "Mute Window ID"
+// @param request body model.MuteWindowForm true "Mute Window Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /mute-window/{id} [patch]
+func updateMuteWindow(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var mf model.MuteWindowForm
+	if err := c.ShouldBindJSON(&mf); err != nil {
+		return nil, err
+	}
+
+	var w model.MuteWindow
+	if err := singleton.DB.First(&w, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("mute window id %d does not exist", id)
+	}
+
+	if !w.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	if err := applyMuteWindowForm(c, &w, &mf); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Save(&w).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.OnMuteWindowUpdate(&w)
+	return nil, nil
+}
+
+// Batch delete mute windows
+// @Summary Batch delete mute windows
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete notification mute windows
+// @Tags auth required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/mute-window [post]
+func batchDeleteMuteWindow(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	singleton.MuteWindowLock.RLock()
+	for _, id := range ids {
+		if w, ok := singleton.MuteWindowMap[id]; ok {
+			if !w.HasPermission(c) {
+				singleton.MuteWindowLock.RUnlock()
+				return nil, singleton.Localizer.ErrorT("permission denied")
+			}
+		}
+	}
+	singleton.MuteWindowLock.RUnlock()
+
+	if err := singleton.DB.Unscoped().Delete(&model.MuteWindow{}, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.OnMuteWindowDelete(ids)
+	return nil, nil
+}
+
+func applyMuteWindowForm(c *gin.Context, w *model.MuteWindow, mf *model.MuteWindowForm) error {
+	// 不限通知组与服务器的窗口会静音所有用户的通知
+	if mf.ServerID == 0 && mf.NotificationGroupID == 0 {
+		auth := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+		if !auth.IsSuperAdmin() {
+			return singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+
+	if mf.ServerID != 0 {
+		singleton.ServerLock.RLock()
+		server, ok := singleton.ServerList[mf.ServerID]
+		singleton.ServerLock.RUnlock()
+		if !ok {
+			return singleton.Localizer.ErrorT("server id %d does not exist", mf.ServerID)
+		}
+		if !server.HasPermission(c) {
+			return singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+
+	if mf.NotificationGroupID != 0 {
+		var ng model.NotificationGroup
+		if err := singleton.DB.First(&ng, mf.NotificationGroupID).Error; err != nil {
+			return singleton.Localizer.ErrorT("group id %d does not exist", mf.NotificationGroupID)
+		}
+		if !ng.HasPermission(c) {
+			return singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+
+	w.Name = mf.Name
+	w.Type = mf.Type
+	w.NotificationGroupID = mf.NotificationGroupID
+	w.ServerID = mf.ServerID
+	w.StartAt = mf.StartAt
+	w.EndAt = mf.EndAt
+	w.StartTime = mf.StartTime
+	w.EndTime = mf.EndTime
+	w.Weekdays = mf.Weekdays
+	w.Timezone = mf.Timezone
+
+	if err := w.Validate(); err != nil {
+		return singleton.Localizer.ErrorT("invalid mute window: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func TestGlobalMuteWindowRequiresSuperAdmin(t *testing.T) {
+	_, adminToken := testCreateUser(t, model.RoleAdmin, 0)
+	member, memberToken := testCreateUser(t, model.RoleMember, model.PermissionNotification)
+
+	ng := model.NotificationGroup{Name: "member group"}
+	ng.UserID = member.ID
+	if err := singleton.DB.Create(&ng).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	form := model.MuteWindowForm{Name: "nightly", Type: model.MuteWindowTypeDaily, StartTime: "00:00", EndTime: "23:59", Timezone: "UTC"}
+	if code, resp := testRequest(t, memberToken, http.MethodPost, "/api/v1/mute-window", form); testAllowed(code, resp) {
+		t.Fatal("member should not create a mute window covering all notification groups")
+	}
+
+	form.NotificationGroupID = ng.ID
+	if code, resp := testRequest(t, memberToken, http.MethodPost, "/api/v1/mute-window", form); !testAllowed(code, resp) {
+		t.Fatalf("member should mute own notification group, got status %d, response %+v", code, resp)
+	}
+
+	form.NotificationGroupID = 0
+	code, resp := testRequest(t, adminToken, http.MethodPost, "/api/v1/mute-window", form)
+	if !testAllowed(code, resp) {
+		t.Fatalf("super admin should create a global mute window, got status %d, response %+v", code, resp)
+	}
+	var id uint64
+	if err := json.Unmarshal(resp.Data, &id); err != nil {
+		t.Fatal(err)
+	}
+	// 全天生效的全局静音窗口会抑制之后测试中的通知
+	t.Cleanup(func() {
+		if code, resp := testRequest(t, adminToken, http.MethodPost, "/api/v1/batch-delete/mute-window", []uint64{id}); !testAllowed(code, resp) {
+			t.Errorf("delete mute window: got status %d, response %+v", code, resp)
+		}
+	})
+}
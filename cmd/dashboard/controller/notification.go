@@ -1,11 +1,15 @@
 package controller
 
 import (
+	"maps"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/copier"
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/i18n"
 	"github.com/nezhahq/nezha/service/singleton"
 	"gorm.io/gorm"
 )
@@ -27,6 +31,9 @@ func listNotification(c *gin.Context) ([]*model.Notification, error) {
 	if err := copier.Copy(&notifications, &singleton.NotificationListSorted); err != nil {
 		return nil, err
 	}
+	for _, n := range notifications {
+		n.RedactSecrets()
+	}
 	return notifications, nil
 }
 
@@ -49,25 +56,8 @@ func createNotification(c *gin.Context) (uint64, error) {
 
 	var n model.Notification
 	n.UserID = getUid(c)
-	n.Name = nf.Name
-	n.RequestMethod = nf.RequestMethod
-	n.RequestType = nf.RequestType
-	n.RequestHeader = nf.RequestHeader
-	n.RequestBody = nf.RequestBody
-	n.URL = nf.URL
-	verifyTLS := nf.VerifyTLS
-	n.VerifyTLS = &verifyTLS
-
-	ns := model.NotificationServerBundle{
-		Notification: &n,
-		Server:       nil,
-		Loc:          singleton.Loc,
-	}
-	// 未勾选跳过检查
-	if !nf.SkipCheck {
-		if err := ns.Send(singleton.Localizer.T("a test message")); err != nil {
-			return 0, err
-		}
+	if err := applyNotificationForm(&n, &nf, nil); err != nil {
+		return 0, err
 	}
 
 	if err := singleton.DB.Create(&n).Error; err != nil {
@@ -111,25 +101,9 @@ func updateNotification(c *gin.Context) (any, error) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
 
-	n.Name = nf.Name
-	n.RequestMethod = nf.RequestMethod
-	n.RequestType = nf.RequestType
-	n.RequestHeader = nf.RequestHeader
-	n.RequestBody = nf.RequestBody
-	n.URL = nf.URL
-	verifyTLS := nf.VerifyTLS
-	n.VerifyTLS = &verifyTLS
-
-	ns := model.NotificationServerBundle{
-		Notification: &n,
-		Server:       nil,
-		Loc:          singleton.Loc,
-	}
-	// 未勾选跳过检查
-	if !nf.SkipCheck {
-		if err := ns.Send(singleton.Localizer.T("a test message")); err != nil {
-			return nil, err
-		}
+	old := n
+	if err := applyNotificationForm(&n, &nf, &old); err != nil {
+		return nil, err
 	}
 
 	if err := singleton.DB.Save(&n).Error; err != nil {
@@ -141,6 +115,178 @@ func updateNotification(c *gin.Context) (any, error) {
 	return nil, nil
 }
 
+// Test notification
+// @Summary Test notification
+// @Security BearerAuth
+// @Schemes
+// @Description Send a test message with the given configuration without saving it
+// @Tags auth required
+// @Accept json
+// @param request body model.NotificationForm true "NotificationForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /notification/test [post]
+func testNotification(c *gin.Context) (any, error) {
+	var nf model.NotificationForm
+	if err := c.ShouldBindJSON(&nf); err != nil {
+		return nil, err
+	}
+
+	var n model.Notification
+	nf.SkipCheck = false
+	if err := applyNotificationForm(&n, &nf, nil); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// Test saved notification
+// @Summary Test saved notification
+// @Security BearerAuth
+// @Schemes
+// @Description Send a test message with a saved notification, including credentials that are redacted when read back
+// @Tags auth required
+// @Param id path uint true "Notification ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.NotificationDeliveryResult]
+// @Router /notification/{id}/test [post]
+func testSavedNotification(c *gin.Context) (model.NotificationDeliveryResult, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return model.NotificationDeliveryResult{}, err
+	}
+
+	var n model.Notification
+	if err := singleton.DB.First(&n, id).Error; err != nil {
+		return model.NotificationDeliveryResult{}, singleton.Localizer.ErrorT("notification id %d does not exist", id)
+	}
+	if !n.HasPermission(c) {
+		return model.NotificationDeliveryResult{}, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	ns := singleton.NotificationBundle(&n)
+	err = ns.Send(singleton.Localizer.T("a test message"))
+	result := model.NotificationDeliveryResult{
+		NotificationID:   n.ID,
+		NotificationName: n.Name,
+		Success:          err == nil,
+		StatusCode:       ns.StatusCode,
+		DeliveryStatus:   ns.DeliveryStatus,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+// List notification logs
+// @Summary List notification logs
+// @Security BearerAuth
+// @Schemes
+// @Description List recent notification send results
+// @Tags auth required
+// @Param notification query uint false "Notification ID"
+// @Param failed query bool false "Only failed sends"
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Produce json
+// @Success 200 {object} model.PaginatedResponse[[]model.NotificationLog, model.NotificationLog]
+// @Router /notification-log [get]
+func listNotificationLog(c *gin.Context) (*model.Value[[]*model.NotificationLog], error) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := singleton.DB.Model(&model.NotificationLog{}).Scopes(singleton.UserScope(c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)))
+	if nid := c.Query("notification"); nid != "" {
+		id, err := strconv.ParseUint(nid, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("notification_id = ?", id)
+	}
+	if failed, _ := strconv.ParseBool(c.Query("failed")); failed {
+		query = query.Where("success = ?", false)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	var logs []*model.NotificationLog
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.Value[[]*model.NotificationLog]{
+		Value: logs,
+		Pagination: model.Pagination{
+			Offset: offset,
+			Limit:  limit,
+			Total:  total,
+		},
+	}, nil
+}
+
+// List notification dead letters
+// @Summary List notification dead letters
+// @Security BearerAuth
+// @Schemes
+// @Description List notifications that still failed after exhausting their retries
+// @Tags admin required
+// @Param notification query uint false "Notification ID"
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Produce json
+// @Success 200 {object} model.PaginatedResponse[[]model.NotificationDeadLetter, model.NotificationDeadLetter]
+// @Router /notification-dead-letter [get]
+func listNotificationDeadLetter(c *gin.Context) (*model.Value[[]*model.NotificationDeadLetter], error) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := singleton.DB.Model(&model.NotificationDeadLetter{})
+	if nid := c.Query("notification"); nid != "" {
+		id, err := strconv.ParseUint(nid, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("notification_id = ?", id)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	var letters []*model.NotificationDeadLetter
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&letters).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.Value[[]*model.NotificationDeadLetter]{
+		Value: letters,
+		Pagination: model.Pagination{
+			Offset: offset,
+			Limit:  limit,
+			Total:  total,
+		},
+	}, nil
+}
+
 // Batch delete notifications
 // @Summary Batch delete notifications
 // @Security BearerAuth
@@ -175,6 +321,9 @@ func batchDeleteNotification(c *gin.Context) (any, error) {
 		if err := tx.Unscoped().Delete(&model.NotificationGroupNotification{}, "notification_id in (?)", n).Error; err != nil {
 			return err
 		}
+		if err := tx.Unscoped().Delete(&model.NotificationRecipient{}, "notification_id in (?)", n).Error; err != nil {
+			return err
+		}
 		return nil
 	})
 
@@ -186,3 +335,224 @@ func batchDeleteNotification(c *gin.Context) (any, error) {
 	singleton.UpdateNotificationList()
 	return nil, nil
 }
+
+// Add notification recipient
+// @Summary Add notification recipient
+// @Security BearerAuth
+// @Schemes
+// @Description Add a recipient to the notification, the value is a webhook url, telegram chat id, slack channel or email addresses depending on the notification type
+// @Tags auth required
+// @Accept json
+// @Param id path uint true "Notification ID"
+// @param request body model.NotificationRecipientForm true "NotificationRecipientForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /notification/{id}/recipient [post]
+func createNotificationRecipient(c *gin.Context) (uint64, error) {
+	n, err := getNotificationForRecipient(c)
+	if err != nil {
+		return 0, err
+	}
+	var rf model.NotificationRecipientForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return 0, err
+	}
+
+	r := model.NotificationRecipient{NotificationID: n.ID, Enabled: true}
+	r.UserID = n.UserID
+	if err := applyNotificationRecipientForm(n, &r, &rf); err != nil {
+		return 0, err
+	}
+	if err := singleton.DB.Create(&r).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	refreshNotification(n)
+	return r.ID, nil
+}
+
+// Edit notification recipient
+// @Summary Edit notification recipient
+// @Security BearerAuth
+// @Schemes
+// @Description Edit a recipient of the notification, including enabling or disabling it
+// @Tags auth required
+// @Accept json
+// @Param id path uint true "Notification ID"
+// @Param rid path uint true "Recipient ID"
+// @param request body model.NotificationRecipientForm true "NotificationRecipientForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /notification/{id}/recipient/{rid} [patch]
+func updateNotificationRecipient(c *gin.Context) (any, error) {
+	n, err := getNotificationForRecipient(c)
+	if err != nil {
+		return nil, err
+	}
+	rid, err := strconv.ParseUint(c.Param("rid"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	var rf model.NotificationRecipientForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return nil, err
+	}
+
+	var r model.NotificationRecipient
+	if err := singleton.DB.Where("notification_id = ?", n.ID).First(&r, rid).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("recipient id %d does not exist", rid)
+	}
+	if err := applyNotificationRecipientForm(n, &r, &rf); err != nil {
+		return nil, err
+	}
+	if err := singleton.DB.Save(&r).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	refreshNotification(n)
+	return nil, nil
+}
+
+// Delete notification recipient
+// @Summary Delete notification recipient
+// @Security BearerAuth
+// @Schemes
+// @Description Delete a recipient of the notification
+// @Tags auth required
+// @Param id path uint true "Notification ID"
+// @Param rid path uint true "Recipient ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /notification/{id}/recipient/{rid} [delete]
+func deleteNotificationRecipient(c *gin.Context) (any, error) {
+	n, err := getNotificationForRecipient(c)
+	if err != nil {
+		return nil, err
+	}
+	rid, err := strconv.ParseUint(c.Param("rid"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	res := singleton.DB.Unscoped().Delete(&model.NotificationRecipient{}, "id = ? AND notification_id = ?", rid, n.ID)
+	if res.Error != nil {
+		return nil, newGormError("%v", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, singleton.Localizer.ErrorT("recipient id %d does not exist", rid)
+	}
+
+	refreshNotification(n)
+	return nil, nil
+}
+
+// getNotificationForRecipient 返回路径中当前用户有权限的通知方式的副本
+func getNotificationForRecipient(c *gin.Context) (*model.Notification, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	singleton.NotificationsLock.RLock()
+	defer singleton.NotificationsLock.RUnlock()
+	n, ok := singleton.NotificationMap[id]
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("notification id %d does not exist", id)
+	}
+	if !n.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	nc := *n
+	return &nc, nil
+}
+
+// refreshNotification 接收方变化后重新加载通知方式
+func refreshNotification(n *model.Notification) {
+	singleton.OnRefreshOrAddNotification(n)
+	singleton.UpdateNotificationList()
+}
+
+// applyNotificationRecipientForm 将表单写入接收方，未勾选跳过检查时向该接收方发送测试消息
+func applyNotificationRecipientForm(n *model.Notification, r *model.NotificationRecipient, rf *model.NotificationRecipientForm) error {
+	if err := n.ValidateRecipient(rf.Value); err != nil {
+		return singleton.Localizer.ErrorT("invalid notification: %v", err)
+	}
+	r.Name = rf.Name
+	r.Value = rf.Value
+	if rf.Enabled != nil {
+		r.Enabled = *rf.Enabled
+	}
+
+	if rf.SkipCheck {
+		return nil
+	}
+	ns := singleton.NotificationBundle(n.WithRecipient(r.Value))
+	return ns.Send(singleton.Localizer.T("a test message"))
+}
+
+// applyNotificationForm 将表单写入通知方式，编辑时 old 为修改前的通知方式，用于还原被隐去的凭据。
+// 未勾选跳过检查时发送测试消息
+func applyNotificationForm(n *model.Notification, nf *model.NotificationForm, old *model.Notification) error {
+	n.Name = nf.Name
+	n.Type = nf.Type
+	n.RequestMethod = nf.RequestMethod
+	n.RequestType = nf.RequestType
+	n.RequestHeader = nf.RequestHeader
+	n.RequestBody = nf.RequestBody
+	n.URL = nf.URL
+	verifyTLS := nf.VerifyTLS
+	n.VerifyTLS = &verifyTLS
+	n.BotToken = nf.BotToken
+	n.ChatID = nf.ChatID
+	n.Channel = nf.Channel
+	n.Secret = nf.Secret
+	n.SMTPHost = nf.SMTPHost
+	n.SMTPPort = nf.SMTPPort
+	n.SMTPSecurity = nf.SMTPSecurity
+	n.SMTPUsername = nf.SMTPUsername
+	n.SMTPPassword = nf.SMTPPassword
+	n.EmailFrom = nf.EmailFrom
+	n.EmailTo = nf.EmailTo
+	n.EmailCc = nf.EmailCc
+	n.EmailSubject = nf.EmailSubject
+	n.TwilioAccountSID = strings.TrimSpace(nf.TwilioAccountSID)
+	n.TwilioAuthToken = nf.TwilioAuthToken
+	n.TwilioFrom = strings.TrimSpace(nf.TwilioFrom)
+	n.TwilioTo = nf.TwilioTo
+	n.Template = nf.Template
+	n.Language = strings.Replace(nf.Language, "-", "_", 1)
+	n.Templates = make(map[string]string, len(nf.Templates))
+	for lang, t := range nf.Templates {
+		n.Templates[strings.Replace(lang, "-", "_", 1)] = t
+	}
+	n.RetryMaxAttempts = nf.RetryMaxAttempts
+	n.RetryBackoff = nf.RetryBackoff
+	n.Severities = nil
+	for _, s := range nf.Severities {
+		if !model.ValidSeverity(s) {
+			return singleton.Localizer.ErrorT("unknown severity %s", s)
+		}
+		if !slices.Contains(n.Severities, s) {
+			n.Severities = append(n.Severities, s)
+		}
+	}
+	for _, lang := range append([]string{n.Language}, slices.Collect(maps.Keys(n.Templates))...) {
+		if _, ok := i18n.Languages[lang]; lang != "" && !ok {
+			return singleton.Localizer.ErrorT("unsupported language: %s", lang)
+		}
+	}
+
+	if old != nil {
+		n.RestoreSecrets(old)
+	}
+	if err := n.Validate(); err != nil {
+		return singleton.Localizer.ErrorT("invalid notification: %v", err)
+	}
+
+	// 未勾选跳过检查
+	if nf.SkipCheck {
+		return nil
+	}
+	ns := singleton.NotificationBundle(n)
+	return ns.Send(singleton.Localizer.T("a test message"))
+}
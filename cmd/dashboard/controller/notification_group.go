@@ -25,6 +25,9 @@ func listNotificationGroup(c *gin.Context) ([]*model.NotificationGroupResponseIt
 	if err := singleton.DB.Find(&ng).Error; err != nil {
 		return nil, err
 	}
+	ng = slices.DeleteFunc(ng, func(g model.NotificationGroup) bool {
+		return !g.HasPermission(c)
+	})
 
 	var ngn []model.NotificationGroupNotification
 	if err := singleton.DB.Find(&ngn).Error; err != nil {
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// testCreateNotificationGroup 创建指向 url 的 Webhook 通知方式及只包含它的通知组，返回两者的 ID
+func testCreateNotificationGroup(t *testing.T, token, url string, attempts uint8, backoff uint32) (uint64, uint64) {
+	t.Helper()
+	code, resp := testRequest(t, token, http.MethodPost, "/api/v1/notification", model.NotificationForm{
+		Name: "webhook", Type: model.NotificationTypeWebhook, URL: url,
+		RequestMethod: model.NotificationRequestMethodGET, SkipCheck: true,
+		RetryMaxAttempts: attempts, RetryBackoff: backoff,
+	})
+	if !testAllowed(code, resp) {
+		t.Fatalf("create notification: got status %d, response %+v", code, resp)
+	}
+	var nid uint64
+	if err := json.Unmarshal(resp.Data, &nid); err != nil {
+		t.Fatal(err)
+	}
+
+	code, resp = testRequest(t, token, http.MethodPost, "/api/v1/notification-group", model.NotificationGroupForm{Name: "webhook", Notifications: []uint64{nid}})
+	if !testAllowed(code, resp) {
+		t.Fatalf("create notification group: got status %d, response %+v", code, resp)
+	}
+	var gid uint64
+	if err := json.Unmarshal(resp.Data, &gid); err != nil {
+		t.Fatal(err)
+	}
+	return nid, gid
+}
+
+// testDeadLetters 等待通知方式的死信数量达到 want 并返回
+func testDeadLetters(t *testing.T, token string, notificationID uint64, want int) []*model.NotificationDeadLetter {
+	t.Helper()
+	var letters []*model.NotificationDeadLetter
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		code, resp := testRequest(t, token, http.MethodGet, fmt.Sprintf("/api/v1/notification-dead-letter?notification=%d", notificationID), nil)
+		if !testAllowed(code, resp) {
+			t.Fatalf("list dead letters: got status %d, response %+v", code, resp)
+		}
+		var v model.Value[[]*model.NotificationDeadLetter]
+		if err := json.Unmarshal(resp.Data, &v); err != nil {
+			t.Fatal(err)
+		}
+		if letters = v.Value; len(letters) >= want {
+			return letters
+		}
+	}
+	t.Fatalf("got %d dead letters, want %d", len(letters), want)
+	return nil
+}
+
+func TestNotificationRetryDeadLetter(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+
+	var (
+		mu   sync.Mutex
+		hits []time.Time
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits = append(hits, time.Now())
+		mu.Unlock()
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	nid, gid := testCreateNotificationGroup(t, token, srv.URL, 3, 1)
+	singleton.SendNotification(gid, "retry", nil)
+
+	// 每次失败后等待的时间加倍，尝试 3 次后记为死信
+	letters := testDeadLetters(t, token, nid, 1)
+	if len(letters) != 1 || letters[0].Attempts != 3 || letters[0].Message != "retry" {
+		t.Fatalf("got dead letters %+v, want one after 3 attempts", letters)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hits) != 3 {
+		t.Fatalf("got %d attempts, want 3", len(hits))
+	}
+	if d := hits[1].Sub(hits[0]); d < time.Second {
+		t.Fatalf("first retry after %v, want at least 1s", d)
+	}
+	if d := hits[2].Sub(hits[1]); d < 2*time.Second {
+		t.Fatalf("second retry after %v, want at least 2s", d)
+	}
+}
+
+func TestNotificationQueueOverflow(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+
+	started := make(chan struct{}, 4)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	defer srv.Close()
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	defer unblock()
+
+	nid, gid := testCreateNotificationGroup(t, token, srv.URL, 1, 0)
+
+	// 占满所有 worker 与队列后，新的通知不阻塞调用方而是记为死信
+	for range 4 {
+		singleton.SendNotification(gid, "busy", nil)
+	}
+	for range 4 {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("notification workers not busy")
+		}
+	}
+	sent := make(chan struct{})
+	go func() {
+		for range 257 {
+			singleton.SendNotification(gid, "overflow", nil)
+		}
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sending blocked on a full queue")
+	}
+
+	letters := testDeadLetters(t, token, nid, 1)
+	if len(letters) != 1 || letters[0].Attempts != 0 || letters[0].Error != "notification queue is full" {
+		t.Fatalf("got dead letters %+v, want one dropped from the full queue", letters)
+	}
+
+	// 等待排队的通知发送完毕，以免之后的测试与发送记录的写入争用数据库
+	unblock()
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		var count int64
+		if err := singleton.DB.Model(&model.NotificationLog{}).Where("notification_id = ?", nid).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count == 4+256 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d sent notifications, want %d", count, 4+256)
+		}
+	}
+}
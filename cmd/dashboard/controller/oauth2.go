@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/oidc"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+const (
+	oidcStateCookie = "nz-oidc-state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+type oidcLoginState struct {
+	Nonce    string
+	Verifier string
+}
+
+// OIDC login
+// @Summary OIDC login
+// @Schemes
+// @Description Redirect to the configured OpenID Connect provider
+// @Success 302
+// @Router /oauth2/login [get]
+func oauth2Login(c *gin.Context) (any, error) {
+	provider := singleton.OIDCProvider(oidcRedirectURL(c))
+	if provider == nil {
+		return nil, singleton.Localizer.ErrorT("OIDC login is not enabled")
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := oidc.RandomString()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	state, login := values[0], oidcLoginState{Nonce: values[1], Verifier: values[2]}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, login.Nonce, login.Verifier)
+	if err != nil {
+		return nil, singleton.Localizer.ErrorT("OIDC provider error: %v", err)
+	}
+
+	singleton.Cache.Set("oidc:"+state, login, oidcStateTTL)
+	// state 同时写入 Cookie，回调时校验以确认登录由当前浏览器发起
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(oidcStateTTL.Seconds()), "/api/v1/oauth2", "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+	return nil, nil
+}
+
+// OIDC callback
+// @Summary OIDC callback
+// @Schemes
+// @Description Sign in with the authorization code returned by the OpenID Connect provider, users are linked by subject or verified email and created on first login
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 302
+// @Router /oauth2/callback [get]
+func oauth2Callback(mw *jwt.GinJWTMiddleware) handlerFunc[any] {
+	return func(c *gin.Context) (any, error) {
+		provider := singleton.OIDCProvider(oidcRedirectURL(c))
+		if provider == nil {
+			return nil, singleton.Localizer.ErrorT("OIDC login is not enabled")
+		}
+		if e := c.Query("error"); e != "" {
+			return nil, singleton.Localizer.ErrorT("OIDC provider error: %v", e+" "+c.Query("error_description"))
+		}
+
+		state := c.Query("state")
+		cookie, _ := c.Cookie(oidcStateCookie)
+		c.SetCookie(oidcStateCookie, "", -1, "/api/v1/oauth2", "", c.Request.TLS != nil, true)
+		v, ok := singleton.Cache.Get("oidc:" + state)
+		if !ok || state == "" || cookie != state {
+			return nil, singleton.Localizer.ErrorT("invalid or expired OIDC login state")
+		}
+		singleton.Cache.Delete("oidc:" + state)
+		login := v.(oidcLoginState)
+
+		claims, err := provider.Exchange(c.Request.Context(), c.Query("code"), login.Verifier, login.Nonce)
+		if err != nil {
+			return nil, singleton.Localizer.ErrorT("OIDC provider error: %v", err)
+		}
+
+		user, err := singleton.OIDCLogin(claims)
+		if err != nil {
+			return nil, err
+		}
+
+		realip := c.GetString(model.CtxKeyRealIPStr)
+		if err := singleton.RecordLogin(user.ID, realip, c.Request.UserAgent()); err != nil {
+			return nil, newGormError("%v", err)
+		}
+
+		if _, err := issueSession(c, mw, user); err != nil {
+			return nil, err
+		}
+		c.Redirect(http.StatusFound, "/dashboard/")
+		return nil, nil
+	}
+}
+
+// oidcRedirectURL 按当前请求的域名生成回调地址
+func oidcRedirectURL(c *gin.Context) string {
+	return requestOrigin(c) + "/api/v1/oauth2/callback"
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/ratelimit"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+var (
+	authRateLimiter    *ratelimit.Limiter
+	writeRateLimiter   *ratelimit.Limiter
+	webhookRateLimiter *ratelimit.Limiter
+	rateLimitAllowlist []netip.Prefix
+)
+
+func initRateLimiters() {
+	conf := singleton.Conf.RateLimit
+	authRateLimiter = ratelimit.New(conf.AuthPerMinute, conf.AuthBurst)
+	writeRateLimiter = ratelimit.New(conf.WritePerMinute, conf.WriteBurst)
+	webhookRateLimiter = ratelimit.New(conf.WebhookPerMinute, conf.WebhookBurst)
+
+	rateLimitAllowlist = nil
+	for _, s := range strings.Split(conf.Allowlist, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				log.Printf("NEZHA>> invalid rate limit allowlist entry %q", s)
+				continue
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		rateLimitAllowlist = append(rateLimitAllowlist, p)
+	}
+}
+
+// authRateLimit 限制登录等认证接口的请求频率
+func authRateLimit(c *gin.Context) {
+	applyRateLimit(c, authRateLimiter)
+}
+
+// writeRateLimit 限制写操作的请求频率，只读请求不计数
+func writeRateLimit(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		c.Next()
+		return
+	}
+	applyRateLimit(c, writeRateLimiter)
+}
+
+func applyRateLimit(c *gin.Context, limiter *ratelimit.Limiter) {
+	if singleton.Conf.RateLimit.Disabled || limiter == nil {
+		c.Next()
+		return
+	}
+
+	ip := c.GetString(model.CtxKeyRealIPStr)
+	if ip == "" {
+		ip = c.RemoteIP()
+	}
+	if rateLimitExempt(ip) {
+		c.Next()
+		return
+	}
+
+	ok, wait := limiter.Allow(ip, time.Now())
+	if ok {
+		c.Next()
+		return
+	}
+	abortRateLimited(c, wait)
+}
+
+func abortRateLimited(c *gin.Context, wait time.Duration) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, newErrorResponse(singleton.Localizer.ErrorT("too many requests, please retry after %d seconds", retryAfter)))
+}
+
+// rateLimitExempt 回环地址（面板内部调用）与白名单中的 IP 不受限制
+func rateLimitExempt(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() {
+		return true
+	}
+	for _, p := range rateLimitAllowlist {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
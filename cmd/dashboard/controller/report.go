@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Get uptime report
+// @Summary Get uptime report
+// @Security BearerAuth
+// @Schemes
+// @Description Availability of each server and the overall rollup computed from online/offline events, defaults to the current month
+// @Tags auth required
+// @Param from query int false "Start timestamp in seconds"
+// @Param to query int false "End timestamp in seconds"
+// @Param group query uint false "Server group ID"
+// @Param exclude_maintenance query bool false "Exclude maintenance windows from the statistics"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.UptimeReport]
+// @Router /report/uptime [get]
+func getUptimeReport(c *gin.Context) (*model.UptimeReport, error) {
+	now := time.Now().In(singleton.Loc)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, singleton.Loc)
+	to := now
+	for key, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := c.Query(key); v != "" {
+			ts, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			*t = time.Unix(ts, 0)
+		}
+	}
+	if !from.Before(to) {
+		return nil, singleton.Localizer.ErrorT("invalid time range")
+	}
+	// 尚未到来的时间不计入在线率
+	if to.After(now) {
+		to = now
+	}
+
+	var gid uint64
+	if v := c.Query("group"); v != "" {
+		var err error
+		if gid, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, err
+		}
+		singleton.ServerGroupLock.RLock()
+		_, ok := singleton.ServerGroupNames[gid]
+		singleton.ServerGroupLock.RUnlock()
+		if !ok {
+			return nil, singleton.Localizer.ErrorT("group id %d does not exist", gid)
+		}
+	}
+	excludeMaintenance, _ := strconv.ParseBool(c.Query("exclude_maintenance"))
+
+	singleton.SortedServerLock.RLock()
+	servers := make([]*model.Server, 0, len(singleton.SortedServerList))
+	for _, s := range singleton.SortedServerList {
+		if gid != 0 && !singleton.ServerInGroup(s.ID, gid) {
+			continue
+		}
+		servers = append(servers, s)
+	}
+	singleton.SortedServerLock.RUnlock()
+
+	report, err := singleton.ServerUptimeReport(filter(c, servers), from, to, excludeMaintenance)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// 沿用反向代理传递的关联 ID 时只接受较短的常见字符，避免日志注入
+var requestIDPattern = regexp.MustCompile(`^[\w.-]{1,64}$`)
+
+// requestID 为请求分配关联 ID 并写入响应头，请求已带有合法的 X-Request-ID 时沿用。
+// 关联 ID 保存在请求的 context 中，传递 c.Request.Context() 的后续调用记录的日志都带有该 ID
+func requestID(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if !requestIDPattern.MatchString(id) {
+		id, _ = utils.GenerateRandomString(16)
+	}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), model.CtxKeyRequestID{}, id))
+	c.Header(requestIDHeader, id)
+	c.Next()
+}
+
+// accessLog 请求结束后记录访问日志
+func accessLog(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	ip := c.GetString(model.CtxKeyRealIPStr)
+	if ip == "" {
+		ip = c.ClientIP()
+	}
+	level := slog.LevelInfo
+	if c.Writer.Status() >= 500 {
+		level = slog.LevelError
+	}
+	requestLogger(c).LogAttrs(c.Request.Context(), level, "access",
+		slog.String("method", c.Request.Method),
+		slog.Int("status", c.Writer.Status()),
+		slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+		slog.String("ip", ip),
+		slog.Int("size", c.Writer.Size()),
+	)
+}
+
+// requestLogger 返回记录请求关联 ID、路径与当前用户的 logger
+func requestLogger(c *gin.Context) *slog.Logger {
+	l := singleton.Logger(c.Request.Context()).With("path", c.Request.URL.Path)
+	if auth, ok := c.Get(model.CtxKeyAuthorizedUser); ok {
+		l = l.With("user_id", auth.(*model.User).ID)
+	}
+	return l
+}
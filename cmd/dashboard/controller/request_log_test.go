@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestID)
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, singleton.RequestID(c.Request.Context()))
+	})
+
+	for header, reuse := range map[string]bool{
+		"":                         false,
+		"abc-123_x.y":              true,
+		"bad id\nforged=1":         false,
+		string(make([]byte, 65)):   false,
+		"0123456789abcdef01234567": true,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(requestIDHeader, header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		id := w.Header().Get(requestIDHeader)
+		if id == "" || w.Body.String() != id {
+			t.Fatalf("request id %q is not propagated to the request context, got %q", id, w.Body.String())
+		}
+		if (id == header) != reuse {
+			t.Errorf("header %q: got request id %q", header, id)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+	"github.com/nezhahq/nezha/service/rpc"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// testAgentContext 返回携带 Agent 认证信息的 gRPC 请求 context
+func testAgentContext(secret, uuid string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("client_secret", secret, "client_uuid", uuid))
+}
+
+// testStateStream 模拟 Agent 的状态上报连接，states 关闭时连接断开
+type testStateStream struct {
+	pb.NezhaService_ReportSystemStateServer
+	ctx      context.Context
+	states   chan *pb.State
+	receipts chan struct{}
+}
+
+func (s *testStateStream) Context() context.Context { return s.ctx }
+
+func (s *testStateStream) Recv() (*pb.State, error) {
+	state, ok := <-s.states
+	if !ok {
+		return nil, io.EOF
+	}
+	return state, nil
+}
+
+func (s *testStateStream) Send(*pb.Receipt) error {
+	s.receipts <- struct{}{}
+	return nil
+}
+
+func TestReportSystemStateKeepsServerListCache(t *testing.T) {
+	u, _ := testCreateUser(t, model.RoleAdmin, 0)
+	s, _ := testCreateOnlineServer(t, u.ID)
+	ttl := singleton.Conf.ServerListCacheTTL
+	singleton.Conf.ServerListCacheTTL = 60
+	t.Cleanup(func() { singleton.Conf.ServerListCacheTTL = ttl })
+
+	stream := &testStateStream{
+		ctx:      testAgentContext(u.AgentSecret, s.UUID),
+		states:   make(chan *pb.State),
+		receipts: make(chan struct{}),
+	}
+	done := make(chan error)
+	go func() { done <- rpc.NewNezhaHandler().ReportSystemState(stream) }()
+	report := func(load float64) {
+		t.Helper()
+		stream.states <- &pb.State{Load1: load}
+		select {
+		case <-stream.receipts:
+		case err := <-done:
+			t.Fatalf("report state: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("report state: no receipt")
+		}
+	}
+	report(1)
+
+	var builds int
+	build := func() ([]byte, error) {
+		builds++
+		return []byte("servers"), nil
+	}
+	if _, err := singleton.CachedServerList(t.Name(), build); err != nil {
+		t.Fatal(err)
+	}
+
+	// 状态上报只更新服务器状态，依靠缓存的有效期刷新，不使缓存失效
+	for i := range 3 {
+		report(float64(i + 2))
+	}
+	if _, err := singleton.CachedServerList(t.Name(), build); err != nil {
+		t.Fatal(err)
+	}
+	if builds != 1 {
+		t.Fatalf("server list rebuilt %d times, want the cached copy", builds)
+	}
+
+	close(stream.states)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewServerNotification(t *testing.T) {
+	u, token := testCreateUser(t, model.RoleAdmin, 0)
+
+	messages := make(chan string, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("m")
+	}))
+	defer srv.Close()
+	_, gid := testCreateNotificationGroup(t, token, srv.URL+"/?m=#NEZHA#", 1, 0)
+
+	groupID := singleton.Conf.NewServerNotificationGroupID
+	t.Cleanup(func() { singleton.Conf.NewServerNotificationGroupID = groupID })
+	register := func() string {
+		t.Helper()
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sid, code := testAgentAuth(t, u.AgentSecret, id)
+		if code != codes.OK {
+			t.Fatalf("auto register: got code %v", code)
+		}
+		t.Cleanup(func() {
+			singleton.OnServerDelete([]uint64{sid})
+			singleton.ReSortServer()
+		})
+		singleton.ServerLock.RLock()
+		defer singleton.ServerLock.RUnlock()
+		return singleton.ServerList[sid].Name
+	}
+	receive := func() string {
+		t.Helper()
+		select {
+		case m := <-messages:
+			return m
+		case <-time.After(5 * time.Second):
+			t.Fatal("no new server notification")
+			return ""
+		}
+	}
+
+	// 未设置通知组时不提醒，设置后以密钥自动注册与审批通过注册申请的服务器均提醒
+	singleton.Conf.NewServerNotificationGroupID = 0
+	silent := register()
+	singleton.Conf.NewServerNotificationGroupID = gid
+	name := register()
+	if m := receive(); !strings.Contains(m, name) || strings.Contains(m, silent) {
+		t.Fatalf("auto register: got notification %q, want one for %s", m, name)
+	}
+
+	code, resp := testRequest(t, token, http.MethodPost, "/api/v1/enrollment-token", model.EnrollmentTokenForm{Name: "notify"})
+	if !testAllowed(code, resp) {
+		t.Fatalf("create token: got status %d, response %+v", code, resp)
+	}
+	var et model.EnrollmentTokenResponse
+	if err := json.Unmarshal(resp.Data, &et); err != nil {
+		t.Fatal(err)
+	}
+	e, _ := testEnroll(t, et.Token)
+	if code, resp := testRequest(t, token, http.MethodPost, fmt.Sprintf("/api/v1/server/pending/%d/approve", e.ID), nil); !testAllowed(code, resp) {
+		t.Fatalf("approve enrollment: got status %d, response %+v", code, resp)
+	}
+	if m := receive(); !strings.Contains(m, singleton.Localizer.Tf("Enrollment token: %s", "notify")) {
+		t.Fatalf("approve enrollment: got notification %q, want the enrollment token name", m)
+	}
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+const (
+	searchDefaultLimit = 5
+	searchMaxLimit     = 50
+)
+
+// Search
+// @Summary Search
+// @Security BearerAuth
+// @Schemes
+// @Description Case-insensitive substring search across server names, alert rule names, notification names and cron task names and commands, only resources the user can access are returned
+// @Tags auth required
+// @Param q query string true "Keyword"
+// @Param limit query uint false "Max results per category, 5 by default and 50 at most"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.SearchResponse]
+// @Router /search [get]
+func search(c *gin.Context) (*model.SearchResponse, error) {
+	q := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	if q == "" {
+		return nil, singleton.Localizer.ErrorT("search keyword can't be empty")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = searchDefaultLimit
+	}
+	limit = min(limit, searchMaxLimit)
+
+	contains := func(s string) bool {
+		return strings.Contains(strings.ToLower(s), q)
+	}
+
+	var resp model.SearchResponse
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	// 角色不可访问的模块不参与搜索
+	if user.Can(model.PermissionServerRead) && canAccessModule(c, model.ModuleServer) {
+		singleton.SortedServerLock.RLock()
+		resp.Servers = searchList(c, singleton.SortedServerList, limit, model.SearchTypeServer, func(s *model.Server) (string, string) {
+			if contains(s.Name) {
+				return s.Name, "name"
+			}
+			return "", ""
+		})
+		singleton.SortedServerLock.RUnlock()
+	}
+
+	if canAccessModule(c, model.ModuleAlertRule) {
+		singleton.AlertsLock.RLock()
+		resp.AlertRules = searchList(c, singleton.Alerts, limit, model.SearchTypeAlertRule, func(r *model.AlertRule) (string, string) {
+			if contains(r.Name) {
+				return r.Name, "name"
+			}
+			return "", ""
+		})
+		singleton.AlertsLock.RUnlock()
+	}
+
+	if canAccessModule(c, model.ModuleNotification) {
+		singleton.NotificationSortedLock.RLock()
+		resp.Notifications = searchList(c, singleton.NotificationListSorted, limit, model.SearchTypeNotification, func(n *model.Notification) (string, string) {
+			if contains(n.Name) {
+				return n.Name, "name"
+			}
+			return "", ""
+		})
+		singleton.NotificationSortedLock.RUnlock()
+	}
+
+	if canAccessModule(c, model.ModuleCron) {
+		singleton.CronLock.RLock()
+		resp.Crons = searchList(c, singleton.CronList, limit, model.SearchTypeCron, func(cr *model.Cron) (string, string) {
+			switch {
+			case contains(cr.Name):
+				return cr.Name, "name"
+			case contains(cr.Command):
+				return cr.Name, "command"
+			}
+			return "", ""
+		})
+		singleton.CronLock.RUnlock()
+	}
+
+	return &resp, nil
+}
+
+// searchList 返回有权限且匹配的前 limit 项，match 返回展示的名称与匹配的字段，未匹配时字段为空
+func searchList[S ~[]E, E model.CommonInterface](c *gin.Context, s S, limit int, typ string, match func(E) (string, string)) model.SearchCategory {
+	category := model.SearchCategory{Items: make([]model.SearchResultItem, 0)}
+	for _, e := range s {
+		name, field := match(e)
+		if field == "" || !e.HasPermission(c) {
+			continue
+		}
+		if len(category.Items) == limit {
+			category.More = true
+			break
+		}
+		category.Items = append(category.Items, model.SearchResultItem{
+			Type:  typ,
+			ID:    e.GetID(),
+			Name:  name,
+			Field: field,
+		})
+	}
+	return category
+}
@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List server secrets
+// @Summary List server secrets
+// @Security BearerAuth
+// @Schemes
+// @Description List server secrets, values are never returned
+// @Tags auth required
+// @Param server_id query uint false "Server ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.Secret]
+// @Router /secret [get]
+func listSecret(c *gin.Context) ([]*model.Secret, error) {
+	query := singleton.DB.Order("id")
+	if sid := c.Query("server_id"); sid != "" {
+		id, err := strconv.ParseUint(sid, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("server_id = ?", id)
+	}
+
+	var secrets []*model.Secret
+	if err := query.Find(&secrets).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return secrets, nil
+}
+
+// Create server secret
+// @Summary Create server secret
+// @Security BearerAuth
+// @Schemes
+// @Description Create server secret, the value is encrypted and cannot be read back
+// @Tags auth required
+// @Accept json
+// @param request body model.SecretForm true "Secret Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /secret [post]
+func createSecret(c *gin.Context) (uint64, error) {
+	var sf model.SecretForm
+	if err := c.ShouldBindJSON(&sf); err != nil {
+		return 0, err
+	}
+	if sf.Value == "" {
+		return 0, singleton.Localizer.ErrorT("secret value can't be empty")
+	}
+
+	var s model.Secret
+	if err := applySecretForm(c, &s, &sf); err != nil {
+		return 0, err
+	}
+	s.UserID = getUid(c)
+
+	if err := singleton.DB.Create(&s).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	recordAuditLog(c, model.AuditActionSecretCreate, auditTarget("secret", s.ID), nil, &s)
+	return s.ID, nil
+}
+
+// Edit server secret
+// @Summary Edit server secret
+// @Security BearerAuth
+// @Schemes
+// @Description Edit server secret, an empty value keeps the stored one
+// @Tags auth required
+// @Accept json
+// @param id path uint true "Secret ID"
+// @param request body model.SecretForm true "Secret Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /secret/{id} [patch]
+func updateSecret(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var sf model.SecretForm
+	if err := c.ShouldBindJSON(&sf); err != nil {
+		return nil, err
+	}
+
+	var s model.Secret
+	if err := singleton.DB.First(&s, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("secret id %d does not exist", id)
+	}
+	if !s.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	// 密钥不可转移到其他服务器
+	if sf.ServerID == 0 {
+		sf.ServerID = s.ServerID
+	}
+	if sf.ServerID != s.ServerID {
+		return nil, singleton.Localizer.ErrorT("a secret cannot be moved to another server")
+	}
+
+	before := s
+	if err := applySecretForm(c, &s, &sf); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Save(&s).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	recordAuditLog(c, model.AuditActionSecretUpdate, auditTarget("secret", s.ID), &before, &s)
+	return nil, nil
+}
+
+// Batch delete server secrets
+// @Summary Batch delete server secrets
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete server secrets
+// @Tags auth required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/secret [post]
+func batchDeleteSecret(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	var secrets []model.Secret
+	if err := singleton.DB.Find(&secrets, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	for _, s := range secrets {
+		if !s.HasPermission(c) {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+
+	if err := singleton.DB.Unscoped().Delete(&model.Secret{}, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	for i := range secrets {
+		recordAuditLog(c, model.AuditActionSecretDelete, auditTarget("secret", secrets[i].ID), &secrets[i], nil)
+	}
+	return nil, nil
+}
+
+// Rotate secrets key
+// @Summary Rotate secrets key
+// @Security BearerAuth
+// @Schemes
+// @Description Re-encrypt all server secrets and stored JWT signing keys with a new master key
+// @Tags auth required
+// @Accept json
+// @param request body model.SecretKeyRotateForm true "Rotate Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /secret/rotate-key [post]
+func rotateSecretsKey(c *gin.Context) (any, error) {
+	var rf model.SecretKeyRotateForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return nil, err
+	}
+
+	if err := singleton.RotateSecretsKey(c.Request.Context(), rf.Key); err != nil {
+		return nil, err
+	}
+
+	recordAuditLog(c, model.AuditActionSecretRotate, "secret", nil, nil)
+	return nil, nil
+}
+
+func applySecretForm(c *gin.Context, s *model.Secret, sf *model.SecretForm) error {
+	singleton.ServerLock.RLock()
+	server, ok := singleton.ServerList[sf.ServerID]
+	singleton.ServerLock.RUnlock()
+	if !ok {
+		return singleton.Localizer.ErrorT("server id %d does not exist", sf.ServerID)
+	}
+	if !server.HasPermission(c) {
+		return singleton.Localizer.ErrorT("permission denied")
+	}
+
+	if !model.SecretNamePattern.MatchString(sf.Name) {
+		return singleton.Localizer.ErrorT("invalid secret name: %s", sf.Name)
+	}
+	if len(sf.Value) > model.MaxSecretSize {
+		return singleton.Localizer.ErrorT("secret value is too large")
+	}
+
+	var duplicated int64
+	if err := singleton.DB.Model(&model.Secret{}).Where("server_id = ? AND name = ? AND id <> ?", sf.ServerID, sf.Name, s.ID).
+		Count(&duplicated).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	if duplicated > 0 {
+		return singleton.Localizer.ErrorT("secret %s already exists on this server", sf.Name)
+	}
+
+	s.ServerID = sf.ServerID
+	s.Name = sf.Name
+	s.Description = sf.Description
+	if sf.Value != "" {
+		return singleton.SealSecret(s, sf.Value)
+	}
+	return nil
+}
+
+// checkSecrets 校验引用的密钥存在且当前用户有权使用，serverID 非零时要求密钥属于该服务器
+func checkSecrets(c *gin.Context, serverID uint64, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var secrets []model.Secret
+	if err := singleton.DB.Find(&secrets, "id in (?)", ids).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	for _, id := range ids {
+		idx := slices.IndexFunc(secrets, func(s model.Secret) bool { return s.ID == id })
+		if idx < 0 || (serverID != 0 && secrets[idx].ServerID != serverID) {
+			return singleton.Localizer.ErrorT("secret id %d does not exist", id)
+		}
+		if !secrets[idx].HasPermission(c) {
+			return singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+	return nil
+}
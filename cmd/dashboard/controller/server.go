@@ -1,7 +1,11 @@
 package controller
 
 import (
+	"cmp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/copier"
@@ -19,18 +23,212 @@ import (
 // @Schemes
 // @Description List server
 // @Tags auth required
+// @Param group query uint false "Server group ID"
+// @Param tag query []string false "Only servers with all given tags"
+// @Param search query string false "Search by name and tags"
+// @Param sort query string false "Sort by name, last_active, cpu or memory"
+// @Param order query string false "asc or desc"
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Param cursor query string false "Page cursor ordered by ID, pass an empty value for the first page and next_cursor afterwards, offset and sort are ignored"
+// @Param trashed query bool false "List deleted servers in the trash instead"
 // @Produce json
-// @Success 200 {object} model.CommonResponse[[]model.Server]
+// @Success 200 {object} model.PaginatedResponse[[]model.Server, model.Server]
 // @Router /server [get]
-func listServer(c *gin.Context) ([]*model.Server, error) {
-	singleton.SortedServerLock.RLock()
-	defer singleton.SortedServerLock.RUnlock()
+func listServer(c *gin.Context) (*model.Value[[]*model.Server], error) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	tags := model.NormalizeTags(c.QueryArray("tag"))
 
 	var ssl []*model.Server
-	if err := copier.Copy(&ssl, &singleton.SortedServerList); err != nil {
+	if c.Query("trashed") == "true" {
+		if ssl, err = singleton.TrashedServers(); err != nil {
+			return nil, newGormError("%v", err)
+		}
+		if len(tags) > 0 {
+			ssl = slices.DeleteFunc(ssl, func(s *model.Server) bool {
+				return slices.ContainsFunc(tags, func(tag string) bool {
+					return !slices.Contains(s.Tags, tag)
+				})
+			})
+		}
+	} else {
+		singleton.SortedServerLock.RLock()
+		err = copier.Copy(&ssl, &singleton.SortedServerList)
+		if err == nil && len(tags) > 0 {
+			ssl = slices.DeleteFunc(ssl, func(s *model.Server) bool {
+				return !singleton.ServerHasTags(s.ID, tags)
+			})
+		}
+		singleton.SortedServerLock.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ssl = slices.DeleteFunc(ssl, func(s *model.Server) bool {
+		return !canViewServer(c, s)
+	})
+	for _, s := range ssl {
+		s.Connection = singleton.ServerConnectionOf(s.ID)
+	}
+
+	if group := c.Query("group"); group != "" {
+		gid, err := strconv.ParseUint(group, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ssl = slices.DeleteFunc(ssl, func(s *model.Server) bool {
+			return !singleton.ServerInGroup(s.ID, gid)
+		})
+	}
+
+	if search := strings.ToLower(c.Query("search")); search != "" {
+		ssl = slices.DeleteFunc(ssl, func(s *model.Server) bool {
+			return !strings.Contains(strings.ToLower(s.Name), search) &&
+				!slices.ContainsFunc(s.Tags, func(tag string) bool {
+					return strings.Contains(strings.ToLower(tag), search)
+				})
+		})
+	}
+
+	cursor, useCursor, err := getCursor(c)
+	if err != nil {
+		return nil, err
+	}
+	if useCursor {
+		return pageServerAfter(ssl, cursor, limit)
+	}
+
+	if sortBy := c.Query("sort"); sortBy != "" {
+		if err := singleton.SortServerList(ssl, sortBy, c.Query("order") == "desc"); err != nil {
+			return nil, err
+		}
+	}
+
+	total := len(ssl)
+	return &model.Value[[]*model.Server]{
+		Value: ssl[min(offset, total):min(offset+limit, total)],
+		Pagination: model.Pagination{
+			Offset: offset,
+			Limit:  limit,
+			Total:  int64(total),
+		},
+	}, nil
+}
+
+// pageServerAfter 按 ID 游标分页，翻页期间增删服务器不会导致重复或遗漏
+func pageServerAfter(ssl []*model.Server, cursor *model.Cursor, limit int) (*model.Value[[]*model.Server], error) {
+	slices.SortFunc(ssl, func(a, b *model.Server) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	start := 0
+	if cursor != nil {
+		after, err := strconv.ParseUint(cursor.ID, 10, 64)
+		if err != nil {
+			return nil, singleton.Localizer.ErrorT("invalid cursor")
+		}
+		start, _ = slices.BinarySearchFunc(ssl, after, func(s *model.Server, id uint64) int {
+			return cmp.Compare(s.ID, id)
+		})
+		if start < len(ssl) && ssl[start].ID == after {
+			start++
+		}
+	}
+	end := min(start+limit, len(ssl))
+
+	p := model.Pagination{Limit: limit, Total: int64(len(ssl))}
+	if end < len(ssl) && end > start {
+		p.NextCursor = (&model.Cursor{ID: strconv.FormatUint(ssl[end-1].ID, 10)}).Encode()
+	}
+	return &model.Value[[]*model.Server]{Value: ssl[start:end], Pagination: p}, nil
+}
+
+// Get server
+// @Summary Get server
+// @Security BearerAuth
+// @Schemes
+// @Description Get a server with its host information and state, including per-mountpoint disk usage and per-interface network stats
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.Server]
+// @Router /server/{id} [get]
+func getServer(c *gin.Context) (*model.Server, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
 		return nil, err
 	}
-	return ssl, nil
+
+	singleton.ServerLock.RLock()
+	server, ok := singleton.ServerList[id]
+	singleton.ServerLock.RUnlock()
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	if !canViewServer(c, server) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	sc := *server
+	sc.EffectiveOfflineTimeout = uint64(server.GetOfflineTimeout(singleton.Conf.ServerOfflineTimeout) / time.Second)
+	sc.Connection = singleton.ServerConnectionOf(server.ID)
+	return &sc, nil
+}
+
+// Agent version summary
+// @Summary Agent version summary
+// @Security BearerAuth
+// @Schemes
+// @Description Version distribution of agents on servers visible to current user, newest first, servers not reporting a version are counted as "unknown"
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.AgentVersionSummary]
+// @Router /server/agent-version [get]
+func getAgentVersionSummary(c *gin.Context) (model.AgentVersionSummary, error) {
+	summary := model.AgentVersionSummary{MinVersion: singleton.Conf.MinAgentVersion}
+	versions := make(map[string]*model.AgentVersionCount)
+
+	singleton.SortedServerLock.RLock()
+	for _, s := range singleton.SortedServerList {
+		if !s.HasPermission(c) {
+			continue
+		}
+		v, ok := versions[s.AgentVersion]
+		if !ok {
+			v = &model.AgentVersionCount{Version: s.AgentVersion, Outdated: s.AgentOutdated(summary.MinVersion)}
+			versions[s.AgentVersion] = v
+		}
+		v.Count++
+		v.Servers = append(v.Servers, s.ID)
+	}
+	singleton.SortedServerLock.RUnlock()
+
+	summary.Versions = make([]model.AgentVersionCount, 0, len(versions))
+	for _, v := range versions {
+		summary.Versions = append(summary.Versions, *v)
+	}
+	slices.SortFunc(summary.Versions, func(a, b model.AgentVersionCount) int {
+		// 无法解析的版本排在最后
+		if r, ok := utils.CompareVersion(b.Version, a.Version); ok && r != 0 {
+			return r
+		}
+		_, aok := utils.ParseVersion(a.Version)
+		_, bok := utils.ParseVersion(b.Version)
+		if aok != bok {
+			return utils.IfOr(aok, -1, 1)
+		}
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return summary, nil
 }
 
 // Edit server
@@ -76,12 +274,17 @@ func updateServer(c *gin.Context) (any, error) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
 
+	if len(sf.Note) > model.MaxServerNoteSize {
+		return nil, singleton.Localizer.ErrorT("note exceeds the size limit of %d bytes", model.MaxServerNoteSize)
+	}
+
 	s.Name = sf.Name
 	s.DisplayIndex = sf.DisplayIndex
 	s.Note = sf.Note
 	s.PublicNote = sf.PublicNote
 	s.HideForGuest = sf.HideForGuest
 	s.EnableDDNS = sf.EnableDDNS
+	s.OfflineTimeout = sf.OfflineTimeout
 	s.DDNSProfiles = sf.DDNSProfiles
 	ddnsProfilesRaw, err := utils.Json.Marshal(s.DDNSProfiles)
 	if err != nil {
@@ -102,11 +305,167 @@ func updateServer(c *gin.Context) (any, error) {
 	return nil, nil
 }
 
+// Set server note
+// @Summary Set server note
+// @Security BearerAuth
+// @Schemes
+// @Description Replace the markdown note of a server, visible to users who can see the server
+// @Tags auth required
+// @Accept json
+// @Param id path uint true "Server ID"
+// @Param body body model.ServerNoteForm true "ServerNoteForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server/{id}/note [post]
+func updateServerNote(c *gin.Context) (any, error) {
+	server, err := getServerWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var nf model.ServerNoteForm
+	if err := c.ShouldBindJSON(&nf); err != nil {
+		return nil, err
+	}
+	if len(nf.Note) > model.MaxServerNoteSize {
+		return nil, singleton.Localizer.ErrorT("note exceeds the size limit of %d bytes", model.MaxServerNoteSize)
+	}
+
+	singleton.ServerLock.RLock()
+	before := model.ServerNoteForm{Note: server.Note}
+	singleton.ServerLock.RUnlock()
+
+	if err := singleton.UpdateServerNote(server.ID, nf.Note); err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	recordAuditLog(c, model.AuditActionServerNote, auditTarget("server", server.ID), before, nf)
+	return nil, nil
+}
+
+// Start server maintenance
+// @Summary Start server maintenance
+// @Security BearerAuth
+// @Schemes
+// @Description Put a server into maintenance mode, alert rules are not evaluated until it ends
+// @Tags auth required
+// @Accept json
+// @Param id path uint true "Server ID"
+// @Param body body model.ServerMaintenanceForm true "ServerMaintenanceForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server/{id}/maintenance [post]
+func startServerMaintenance(c *gin.Context) (any, error) {
+	server, err := getServerWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var mf model.ServerMaintenanceForm
+	if err := c.ShouldBindJSON(&mf); err != nil {
+		return nil, err
+	}
+	if mf.Duration == 0 {
+		return nil, singleton.Localizer.ErrorT("maintenance duration is required")
+	}
+
+	until := time.Now().Add(time.Duration(mf.Duration) * time.Second)
+	if err := singleton.SetServerMaintenance([]uint64{server.ID}, &until, strings.TrimSpace(mf.Reason)); err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
+
+// Stop server maintenance
+// @Summary Stop server maintenance
+// @Security BearerAuth
+// @Schemes
+// @Description End maintenance mode of a server immediately
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server/{id}/maintenance [delete]
+func stopServerMaintenance(c *gin.Context) (any, error) {
+	server, err := getServerWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := singleton.SetServerMaintenance([]uint64{server.ID}, nil, ""); err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
+
+// Set server owner
+// @Summary Set server owner
+// @Security BearerAuth
+// @Schemes
+// @Description Transfer a server to another user, members only see servers they own or that are in their server groups
+// @Tags admin required
+// @Accept json
+// @Param id path uint true "Server ID"
+// @Param body body model.ServerOwnerForm true "ServerOwnerForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server/{id}/owner [post]
+func setServerOwner(c *gin.Context) (any, error) {
+	server, err := getServerWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var of model.ServerOwnerForm
+	if err := c.ShouldBindJSON(&of); err != nil {
+		return nil, err
+	}
+
+	singleton.UserLock.RLock()
+	_, ok := singleton.UserInfoMap[of.UserID]
+	singleton.UserLock.RUnlock()
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("user id %d does not exist", of.UserID)
+	}
+
+	before := server.OwnerID
+	if err := singleton.SetServerOwner(server.ID, of.UserID); err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	recordAuditLog(c, model.AuditActionServerOwner, auditTarget("server", server.ID),
+		map[string]uint64{"user_id": before}, map[string]uint64{"user_id": of.UserID})
+	return nil, nil
+}
+
+// canViewServer 当前用户是否可以查看服务器，成员可查看自己所属或共享给自己的服务器
+func canViewServer(c *gin.Context, s *model.Server) bool {
+	return s.HasPermission(c) || singleton.ServerSharedWith(s.ID, getUid(c))
+}
+
+func getServerWithPermission(c *gin.Context) (*model.Server, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	singleton.ServerLock.RLock()
+	server, ok := singleton.ServerList[id]
+	singleton.ServerLock.RUnlock()
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	if !server.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	return server, nil
+}
+
 // Batch delete server
 // @Summary Batch delete server
 // @Security BearerAuth
 // @Schemes
-// @Description Batch delete server
+// @Description Move servers to the trash, they can be restored before being permanently deleted after the configured retention days
 // @Tags auth required
 // @Accept json
 // @param request body []uint64 true "id list"
@@ -130,8 +489,9 @@ func batchDeleteServer(c *gin.Context) (any, error) {
 	}
 	singleton.ServerLock.RUnlock()
 
+	// 移入回收站，流量记录保留至彻底删除
 	err := singleton.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Unscoped().Delete(&model.Server{}, "id in (?)", servers).Error; err != nil {
+		if err := tx.Delete(&model.Server{}, "id in (?)", servers).Error; err != nil {
 			return err
 		}
 		if err := tx.Unscoped().Delete(&model.ServerGroupServer{}, "server_id in (?)", servers).Error; err != nil {
@@ -154,7 +514,6 @@ func batchDeleteServer(c *gin.Context) (any, error) {
 			}
 		}
 	}
-	singleton.DB.Unscoped().Delete(&model.Transfer{}, "server_id in (?)", servers)
 	singleton.AlertsLock.Unlock()
 
 	singleton.OnServerDelete(servers)
@@ -163,6 +522,99 @@ func batchDeleteServer(c *gin.Context) (any, error) {
 	return nil, nil
 }
 
+// Restore server
+// @Summary Restore server
+// @Security BearerAuth
+// @Schemes
+// @Description Restore a deleted server from the trash, its groups are not restored
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server/{id}/restore [post]
+func restoreServer(c *gin.Context) (any, error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var s model.Server
+	if err := singleton.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&s, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+
+	if !s.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	if err := singleton.RestoreServer(&s); err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
+
+// List server events
+// @Summary List server events
+// @Security BearerAuth
+// @Schemes
+// @Description List online/offline transitions of a server and its uptime in the time range, defaults to the last 24 hours
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param from query int false "Start timestamp in seconds"
+// @Param to query int false "End timestamp in seconds"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ServerEventResponse]
+// @Router /server/{id}/events [get]
+func listServerEvents(c *gin.Context) (*model.ServerEventResponse, error) {
+	server, err := getServerWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	for key, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := c.Query(key); v != "" {
+			ts, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			*t = time.Unix(ts, 0)
+		}
+	}
+	if !from.Before(to) {
+		return nil, singleton.Localizer.ErrorT("invalid time range")
+	}
+
+	// 范围开始前的最后一次状态变化决定了开始时的状态
+	var prev *model.ServerEvent
+	var last model.ServerEvent
+	err = singleton.DB.Where("server_id = ? AND created_at < ?", server.ID, from).Order("created_at DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if last.ID != 0 {
+		prev = &last
+	}
+
+	events := make([]model.ServerEvent, 0)
+	if err := singleton.DB.Where("server_id = ? AND created_at >= ? AND created_at < ?", server.ID, from, to).
+		Order("created_at").Find(&events).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	// 尚未到来的时间不计入在线率
+	end := to
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	return &model.ServerEventResponse{
+		Events: events,
+		Uptime: model.ServerUptime(prev, events, from, end),
+	}, nil
+}
+
 // Force update Agent
 // @Summary Force update Agent
 // @Security BearerAuth
@@ -193,6 +645,7 @@ func forceUpdateServer(c *gin.Context) (*model.ForceUpdateResponse, error) {
 			if err := server.TaskStream.Send(&pb.Task{
 				Type: model.TaskTypeUpgrade,
 			}); err != nil {
+				requestLogger(c).Warn("failed to send upgrade task", "server_id", sid, "error", err)
 				forceUpdateResp.Failure = append(forceUpdateResp.Failure, sid)
 			} else {
 				forceUpdateResp.Success = append(forceUpdateResp.Success, sid)
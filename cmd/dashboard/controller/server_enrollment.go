@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List enrollment tokens
+// @Summary List enrollment tokens
+// @Security BearerAuth
+// @Schemes
+// @Description List enrollment tokens
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.EnrollmentToken]
+// @Router /enrollment-token [get]
+func listEnrollmentToken(c *gin.Context) ([]*model.EnrollmentToken, error) {
+	var tokens []*model.EnrollmentToken
+	if err := singleton.DB.Find(&tokens).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return tokens, nil
+}
+
+// Create enrollment token
+// @Summary Create enrollment token
+// @Security BearerAuth
+// @Schemes
+// @Description Create a token that agents use as client secret to self-register, the token is only returned once.
+// @Description Servers registered with it wait in the pending queue until approved.
+// @Tags auth required
+// @Accept json
+// @param request body model.EnrollmentTokenForm true "Enrollment Token Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.EnrollmentTokenResponse]
+// @Router /enrollment-token [post]
+func createEnrollmentToken(c *gin.Context) (*model.EnrollmentTokenResponse, error) {
+	var tf model.EnrollmentTokenForm
+	if err := c.ShouldBindJSON(&tf); err != nil {
+		return nil, err
+	}
+	if tf.Name == "" {
+		return nil, singleton.Localizer.ErrorT("token name can't be empty")
+	}
+
+	token, err := utils.GenerateRandomString(40)
+	if err != nil {
+		return nil, err
+	}
+	token = model.EnrollmentTokenPrefix + token
+
+	t := model.EnrollmentToken{
+		Name:      tf.Name,
+		TokenHash: model.HashApiToken(token),
+		SingleUse: tf.SingleUse,
+	}
+	t.UserID = getUid(c)
+	if err := singleton.DB.Create(&t).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.EnrollmentTokenResponse{
+		ID:    t.ID,
+		Token: token,
+	}, nil
+}
+
+// Batch delete enrollment tokens
+// @Summary Batch delete enrollment tokens
+// @Security BearerAuth
+// @Schemes
+// @Description Revoke enrollment tokens, pending enrollments made with them are rejected and servers registered with them can no longer connect
+// @Tags auth required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/enrollment-token [post]
+func batchDeleteEnrollmentToken(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	var tokens []model.EnrollmentToken
+	if err := singleton.DB.Find(&tokens, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	for _, t := range tokens {
+		if !t.HasPermission(c) {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+
+	if err := singleton.RevokeEnrollmentTokens(ids); err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
+
+// List pending servers
+// @Summary List pending servers
+// @Security BearerAuth
+// @Schemes
+// @Description List servers registered with enrollment tokens that are waiting for approval or have been rejected
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.ServerEnrollment]
+// @Router /server/pending [get]
+func listPendingServer(c *gin.Context) ([]*model.ServerEnrollment, error) {
+	var enrollments []*model.ServerEnrollment
+	if err := singleton.DB.Where("status != ?", model.ServerEnrollmentApproved).Find(&enrollments).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return enrollments, nil
+}
+
+// Approve pending server
+// @Summary Approve pending server
+// @Security BearerAuth
+// @Schemes
+// @Description Approve a pending or rejected registration, the server joins the server list and the agent can connect
+// @Tags auth required
+// @Param id path uint true "Enrollment ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /server/pending/{id}/approve [post]
+func approvePendingServer(c *gin.Context) (uint64, error) {
+	e, err := getServerEnrollment(c)
+	if err != nil {
+		return 0, err
+	}
+	if e.Status == model.ServerEnrollmentApproved {
+		return 0, singleton.Localizer.ErrorT("server has been approved")
+	}
+	singleton.ServerLock.RLock()
+	_, exists := singleton.ServerUUIDToID[e.UUID]
+	singleton.ServerLock.RUnlock()
+	if exists || singleton.IsServerTrashed(e.UUID) {
+		return 0, singleton.Localizer.ErrorT("server uuid %s already exists", e.UUID)
+	}
+
+	before := *e
+	s, err := singleton.ApproveServerEnrollment(c.Request.Context(), e)
+	if err != nil {
+		if errors.Is(err, singleton.ErrEnrollmentTokenRevoked) {
+			return 0, singleton.Localizer.ErrorT("enrollment token has been revoked")
+		}
+		return 0, newGormError("%v", err)
+	}
+	recordAuditLog(c, model.AuditActionServerApprove, auditTarget("server_enrollment", e.ID), &before, e)
+	return s.ID, nil
+}
+
+// Reject pending server
+// @Summary Reject pending server
+// @Security BearerAuth
+// @Schemes
+// @Description Reject a pending registration, the agent is refused until the registration is approved
+// @Tags auth required
+// @Param id path uint true "Enrollment ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server/pending/{id}/reject [post]
+func rejectPendingServer(c *gin.Context) (any, error) {
+	e, err := getServerEnrollment(c)
+	if err != nil {
+		return nil, err
+	}
+	if e.Status != model.ServerEnrollmentPending {
+		return nil, singleton.Localizer.ErrorT("server is not pending approval")
+	}
+
+	before := *e
+	if err := singleton.DB.Model(e).Update("status", model.ServerEnrollmentRejected).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	recordAuditLog(c, model.AuditActionServerReject, auditTarget("server_enrollment", e.ID), &before, e)
+	return nil, nil
+}
+
+func getServerEnrollment(c *gin.Context) (*model.ServerEnrollment, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var e model.ServerEnrollment
+	if err := singleton.DB.First(&e, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("enrollment id %d does not exist", id)
+	}
+	if !e.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	return &e, nil
+}
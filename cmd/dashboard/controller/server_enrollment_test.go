@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/go-uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/rpc"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// testAgentAuth 以 secret 与 uuid 模拟 Agent 连接，返回认证结果的状态码
+func testAgentAuth(t *testing.T, secret, uuid string) (uint64, codes.Code) {
+	t.Helper()
+	id, err := rpc.NewNezhaHandler().Auth.Check(testAgentContext(secret, uuid))
+	return id, status.Code(err)
+}
+
+// testEnroll 以注册令牌发起注册申请，返回申请与 Agent 的 UUID
+func testEnroll(t *testing.T, token string) (*model.ServerEnrollment, string) {
+	t.Helper()
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, code := testAgentAuth(t, token, id); code != codes.PermissionDenied {
+		t.Fatalf("enroll: got code %v, want pending", code)
+	}
+	var e model.ServerEnrollment
+	if err := singleton.DB.Where("uuid = ?", id).First(&e).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		singleton.ServerLock.RLock()
+		sid, ok := singleton.ServerUUIDToID[id]
+		singleton.ServerLock.RUnlock()
+		if ok {
+			singleton.OnServerDelete([]uint64{sid})
+			singleton.ReSortServer()
+		}
+	})
+	return &e, id
+}
+
+func TestRevokeEnrollmentToken(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+	code, resp := testRequest(t, token, http.MethodPost, "/api/v1/enrollment-token", model.EnrollmentTokenForm{Name: "revoke"})
+	if !testAllowed(code, resp) {
+		t.Fatalf("create token: got status %d, response %+v", code, resp)
+	}
+	var et model.EnrollmentTokenResponse
+	if err := json.Unmarshal(resp.Data, &et); err != nil {
+		t.Fatal(err)
+	}
+
+	approved, approvedUUID := testEnroll(t, et.Token)
+	pending, _ := testEnroll(t, et.Token)
+	approve := func(e *model.ServerEnrollment) bool {
+		t.Helper()
+		code, resp := testRequest(t, token, http.MethodPost, fmt.Sprintf("/api/v1/server/pending/%d/approve", e.ID), nil)
+		return testAllowed(code, resp)
+	}
+	if !approve(approved) {
+		t.Fatal("approve enrollment failed")
+	}
+	if _, code := testAgentAuth(t, et.Token, approvedUUID); code != codes.OK {
+		t.Fatalf("approved server: got code %v", code)
+	}
+
+	if code, resp := testRequest(t, token, http.MethodPost, "/api/v1/batch-delete/enrollment-token", []uint64{et.ID}); !testAllowed(code, resp) {
+		t.Fatalf("revoke token: got status %d, response %+v", code, resp)
+	}
+
+	// 撤销后待审批的申请被拒绝且不能再审批，已审批的服务器不能再以该令牌连接
+	if err := singleton.DB.First(pending, pending.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if pending.Status != model.ServerEnrollmentRejected {
+		t.Fatalf("pending enrollment: got status %s, want rejected", pending.Status)
+	}
+	if approve(pending) {
+		t.Fatal("enrollment approved after its token was revoked")
+	}
+	if _, code := testAgentAuth(t, et.Token, approvedUUID); code == codes.OK {
+		t.Fatal("approved server still authenticates with a revoked token")
+	}
+}
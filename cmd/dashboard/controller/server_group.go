@@ -25,6 +25,12 @@ func listServerGroup(c *gin.Context) ([]*model.ServerGroupResponseItem, error) {
 	if err := singleton.DB.Find(&sg).Error; err != nil {
 		return nil, err
 	}
+	_, authorized := c.Get(model.CtxKeyAuthorizedUser)
+	if authorized {
+		sg = slices.DeleteFunc(sg, func(g model.ServerGroup) bool {
+			return !g.HasPermission(c)
+		})
+	}
 
 	groupServers := make(map[uint64][]uint64, 0)
 	var sgs []model.ServerGroupServer
@@ -38,12 +44,36 @@ func listServerGroup(c *gin.Context) ([]*model.ServerGroupResponseItem, error) {
 		groupServers[s.ServerGroupId] = append(groupServers[s.ServerGroupId], s.ServerId)
 	}
 
+	singleton.ServerLock.RLock()
+	defer singleton.ServerLock.RUnlock()
+
 	var sgRes []*model.ServerGroupResponseItem
 	for _, s := range sg {
-		sgRes = append(sgRes, &model.ServerGroupResponseItem{
+		item := &model.ServerGroupResponseItem{
 			Group:   s,
-			Servers: groupServers[s.ID],
-		})
+			Servers: make([]uint64, 0, len(groupServers[s.ID])),
+		}
+		for _, sid := range groupServers[s.ID] {
+			server, ok := singleton.ServerList[sid]
+			// 游客只能看到未对游客隐藏的服务器
+			if !authorized && (!ok || server.HideForGuest) {
+				continue
+			}
+			item.Servers = append(item.Servers, sid)
+			if !ok {
+				continue
+			}
+			if server.IsOnline(singleton.Conf.ServerOfflineTimeout) {
+				item.Online++
+			} else {
+				item.Offline++
+			}
+		}
+		// 游客不能看到不含可见服务器的分组
+		if !authorized && len(item.Servers) == 0 {
+			continue
+		}
+		sgRes = append(sgRes, item)
 	}
 
 	return sgRes, nil
@@ -113,6 +143,7 @@ func createServerGroup(c *gin.Context) (uint64, error) {
 		return 0, newGormError("%v", err)
 	}
 
+	singleton.UpdateServerGroupMembership()
 	return sg.ID, nil
 }
 
@@ -199,6 +230,7 @@ func updateServerGroup(c *gin.Context) (any, error) {
 		return nil, newGormError("%v", err)
 	}
 
+	singleton.UpdateServerGroupMembership()
 	return nil, nil
 }
 
@@ -244,5 +276,81 @@ func batchDeleteServerGroup(c *gin.Context) (any, error) {
 		return nil, newGormError("%v", err)
 	}
 
+	singleton.UpdateServerGroupMembership()
 	return nil, nil
 }
+
+// Batch move servers to group
+// @Summary Batch move servers to group
+// @Security BearerAuth
+// @Schemes
+// @Description Move servers into the target group in one transaction, removing them from their other groups. A null group_id removes the servers from all groups. Servers that do not exist or are not permitted are reported in failure.
+// @Tags auth required
+// @Accept json
+// @param request body model.BatchServerGroupForm true "BatchServerGroupForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.BatchServerGroupResponse]
+// @Router /server/batch-group [post]
+func batchGroupServer(c *gin.Context) (*model.BatchServerGroupResponse, error) {
+	var bf model.BatchServerGroupForm
+	if err := c.ShouldBindJSON(&bf); err != nil {
+		return nil, err
+	}
+	slices.Sort(bf.Servers)
+	bf.Servers = slices.Compact(bf.Servers)
+
+	if bf.GroupID != nil {
+		var sgDB model.ServerGroup
+		if err := singleton.DB.First(&sgDB, *bf.GroupID).Error; err != nil {
+			return nil, singleton.Localizer.ErrorT("group id %d does not exist", *bf.GroupID)
+		}
+		if !sgDB.HasPermission(c) {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+
+	resp := new(model.BatchServerGroupResponse)
+	singleton.ServerLock.RLock()
+	for _, sid := range bf.Servers {
+		if server, ok := singleton.ServerList[sid]; ok && server.HasPermission(c) {
+			resp.Success = append(resp.Success, sid)
+		} else {
+			resp.Failure = append(resp.Failure, sid)
+		}
+	}
+	singleton.ServerLock.RUnlock()
+
+	if len(resp.Success) == 0 {
+		return resp, nil
+	}
+
+	uid := getUid(c)
+
+	err := singleton.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&model.ServerGroupServer{}, "server_id in (?)", resp.Success).Error; err != nil {
+			return err
+		}
+		if bf.GroupID == nil {
+			return nil
+		}
+
+		for _, s := range resp.Success {
+			if err := tx.Create(&model.ServerGroupServer{
+				Common: model.Common{
+					UserID: uid,
+				},
+				ServerGroupId: *bf.GroupID,
+				ServerId:      s,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	singleton.UpdateServerGroupMembership()
+	return resp, nil
+}
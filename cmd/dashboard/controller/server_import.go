@@ -0,0 +1,286 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/go-uuid"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// Import servers
+// @Summary Import servers
+// @Security BearerAuth
+// @Schemes
+// @Description Create servers from a CSV file with the header name,group,tags,note,enrollment_token, only name is required and tags are separated by ";".
+// @Description Rows with an enrollment token create pending registrations that join the server list once approved, other rows create servers directly.
+// @Description Malformed rows are reported in the result without aborting the others. Rows named after an existing server are skipped or update its group, tags and note according to on_conflict.
+// @Tags auth required
+// @Accept text/csv
+// @Param on_conflict query string false "skip (default) or update"
+// @param request body string true "CSV file"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.ServerImportResult]
+// @Router /server/import [post]
+func importServer(c *gin.Context) ([]*model.ServerImportResult, error) {
+	onConflict := c.DefaultQuery("on_conflict", model.ServerImportSkip)
+	if onConflict != model.ServerImportSkip && onConflict != model.ServerImportUpdate {
+		return nil, singleton.Localizer.ErrorT("invalid on_conflict policy: %s", onConflict)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, model.MaxServerImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > model.MaxServerImportSize {
+		return nil, singleton.Localizer.ErrorT("file exceeds the size limit of %d bytes", model.MaxServerImportSize)
+	}
+	rows, err := model.ParseServerImportCSV(bytes.NewReader(data))
+	if err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid csv file: %v", err)
+	}
+
+	im, err := newServerImporter(c, onConflict)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*model.ServerImportResult, 0, len(rows))
+	for _, row := range rows {
+		r := &model.ServerImportResult{Line: row.Line, Name: row.Name}
+		if err := im.importRow(&row, r); err != nil {
+			r.Status = model.ServerImportFailed
+			r.Error = err.Error()
+		}
+		results = append(results, r)
+	}
+
+	if im.serversChanged {
+		singleton.ReSortServer()
+	}
+	if im.groupsChanged {
+		singleton.UpdateServerGroupMembership()
+	}
+	recordAuditLog(c, model.AuditActionServerImport, "server", nil, results)
+	return results, nil
+}
+
+// serverImporter 保存一次导入中查询过的服务器、分组与令牌，并记录文件中已出现的名称
+type serverImporter struct {
+	c          *gin.Context
+	uid        uint64
+	onConflict string
+
+	servers     map[string][]*model.Server
+	enrollments map[string]*model.ServerEnrollment // 导入后尚未审批的注册申请
+	groups      map[string]uint64
+	tokens      map[string]*model.EnrollmentToken
+	seen        map[string]bool
+
+	serversChanged bool
+	groupsChanged  bool
+}
+
+func newServerImporter(c *gin.Context, onConflict string) (*serverImporter, error) {
+	im := &serverImporter{
+		c:           c,
+		uid:         getUid(c),
+		onConflict:  onConflict,
+		servers:     make(map[string][]*model.Server),
+		enrollments: make(map[string]*model.ServerEnrollment),
+		groups:      make(map[string]uint64),
+		tokens:      make(map[string]*model.EnrollmentToken),
+		seen:        make(map[string]bool),
+	}
+
+	singleton.ServerLock.RLock()
+	for _, s := range singleton.ServerList {
+		if s.HasPermission(c) {
+			im.servers[s.Name] = append(im.servers[s.Name], s)
+		}
+	}
+	singleton.ServerLock.RUnlock()
+
+	var enrollments []*model.ServerEnrollment
+	if err := singleton.DB.Where("status = ? AND name != ''", model.ServerEnrollmentPending).
+		Order("id").Find(&enrollments).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	for _, e := range enrollments {
+		if _, ok := im.enrollments[e.Name]; !ok && e.HasPermission(c) {
+			im.enrollments[e.Name] = e
+		}
+	}
+
+	var groups []model.ServerGroup
+	if err := singleton.DB.Order("id").Find(&groups).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	for _, g := range groups {
+		if _, ok := im.groups[g.Name]; !ok && g.HasPermission(c) {
+			im.groups[g.Name] = g.ID
+		}
+	}
+	return im, nil
+}
+
+func (im *serverImporter) importRow(row *model.ServerImportRow, r *model.ServerImportResult) error {
+	if row.Err != nil {
+		return row.Err
+	}
+	if im.seen[row.Name] {
+		return singleton.Localizer.ErrorT("duplicate name %s in file", row.Name)
+	}
+	im.seen[row.Name] = true
+
+	var groupID uint64
+	if row.Group != "" {
+		var ok bool
+		if groupID, ok = im.groups[row.Group]; !ok {
+			return singleton.Localizer.ErrorT("group %s does not exist", row.Group)
+		}
+	}
+	var token *model.EnrollmentToken
+	if row.EnrollmentToken != "" {
+		var err error
+		if token, err = im.token(row.EnrollmentToken); err != nil {
+			return err
+		}
+	}
+
+	existing := im.servers[row.Name]
+	if len(existing) > 1 {
+		return singleton.Localizer.ErrorT("multiple servers are named %s", row.Name)
+	}
+	if len(existing) == 1 {
+		r.ServerID, r.UUID = existing[0].ID, existing[0].UUID
+		if im.onConflict == model.ServerImportSkip {
+			r.Status = model.ServerImportSkipped
+			return nil
+		}
+		r.Status = model.ServerImportUpdated
+		return im.updateServer(existing[0], row, groupID)
+	}
+	if e, ok := im.enrollments[row.Name]; ok {
+		r.UUID = e.UUID
+		if im.onConflict == model.ServerImportSkip {
+			r.Status = model.ServerImportSkipped
+			return nil
+		}
+		r.Status = model.ServerImportUpdated
+		return im.updateEnrollment(e, row, groupID)
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return err
+	}
+
+	if token != nil {
+		e := model.ServerEnrollment{
+			UUID:       id,
+			TokenID:    token.ID,
+			Status:     model.ServerEnrollmentPending,
+			SecretHash: token.TokenHash,
+			Name:       row.Name,
+			Note:       row.Note,
+			Tags:       row.Tags,
+			GroupID:    groupID,
+		}
+		e.UserID = token.UserID
+		tags, err := utils.Json.Marshal(e.Tags)
+		if err != nil {
+			return err
+		}
+		e.TagsRaw = string(tags)
+		if err := singleton.CreateServerEnrollment(&e, token); err != nil {
+			if errors.Is(err, singleton.ErrEnrollmentTokenUsed) {
+				return singleton.Localizer.ErrorT("enrollment token has been used")
+			}
+			return newGormError("%v", err)
+		}
+		im.enrollments[e.Name] = &e
+		r.UUID = e.UUID
+		r.Status = model.ServerImportPending
+		return nil
+	}
+
+	s := &model.Server{Name: row.Name, UUID: id, Note: row.Note, Tags: row.Tags}
+	s.UserID = im.uid
+	if err := singleton.ImportServer(s, groupID); err != nil {
+		return newGormError("%v", err)
+	}
+	im.servers[s.Name] = []*model.Server{s}
+	im.serversChanged = true
+	im.groupsChanged = im.groupsChanged || groupID != 0
+	r.ServerID, r.UUID = s.ID, s.UUID
+	r.Status = model.ServerImportCreated
+	return nil
+}
+
+// token 查找注册令牌，只能使用自己有权管理的令牌
+func (im *serverImporter) token(secret string) (*model.EnrollmentToken, error) {
+	hash := model.HashApiToken(secret)
+	if t, ok := im.tokens[hash]; ok {
+		return t, nil
+	}
+	var t model.EnrollmentToken
+	if err := singleton.DB.Where("token_hash = ?", hash).Limit(1).Find(&t).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if t.ID == 0 || !t.HasPermission(im.c) {
+		return nil, singleton.Localizer.ErrorT("invalid enrollment token")
+	}
+	im.tokens[hash] = &t
+	return &t, nil
+}
+
+// updateServer 按导入的行更新重名的服务器，为空的列保持原值，分组只加入不移出
+func (im *serverImporter) updateServer(s *model.Server, row *model.ServerImportRow, groupID uint64) error {
+	if len(row.Tags) > 0 {
+		if err := singleton.UpdateServerTags(map[uint64][]string{s.ID: row.Tags}); err != nil {
+			return newGormError("%v", err)
+		}
+	}
+	if row.Note != "" {
+		if err := singleton.UpdateServerNote(s.ID, row.Note); err != nil {
+			return newGormError("%v", err)
+		}
+	}
+	if groupID != 0 {
+		if err := singleton.AddServerToGroup(s.ID, groupID, im.uid); err != nil {
+			return newGormError("%v", err)
+		}
+		im.groupsChanged = true
+	}
+	return nil
+}
+
+// updateEnrollment 按导入的行更新重名的待审批注册申请，为空的列保持原值
+func (im *serverImporter) updateEnrollment(e *model.ServerEnrollment, row *model.ServerImportRow, groupID uint64) error {
+	updates := make(map[string]any)
+	if len(row.Tags) > 0 {
+		tags, err := utils.Json.Marshal(row.Tags)
+		if err != nil {
+			return err
+		}
+		updates["tags_raw"] = string(tags)
+	}
+	if row.Note != "" {
+		updates["note"] = row.Note
+	}
+	if groupID != 0 {
+		updates["group_id"] = groupID
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := singleton.DB.Model(e).Updates(updates).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	return nil
+}
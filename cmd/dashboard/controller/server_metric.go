@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// 未指定聚合区间时，按对比时长分成约该数量的区间
+const metricCompareDefaultBuckets = 200
+
+// Compare server metric
+// @Summary Compare server metric
+// @Security BearerAuth
+// @Schemes
+// @Description Aggregate a metric of a server in two time ranges into buckets aligned by offset from each range's start, so they can be overlaid. Ranges of different lengths are truncated to the shorter one. Only metrics with stored history are supported.
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param metric query string true "transfer_in, transfer_out, service_delay or custom"
+// @Param service query uint false "Service ID, required for service_delay"
+// @Param name query string false "Custom metric name, required for custom"
+// @Param a_from query int true "Start timestamp of the first range in seconds"
+// @Param a_to query int true "End timestamp of the first range in seconds"
+// @Param b_from query int true "Start timestamp of the second range in seconds"
+// @Param b_to query int true "End timestamp of the second range in seconds"
+// @Param interval query string false "Bucket size such as 30m, 1h or 1d"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.MetricCompareResponse]
+// @Router /server/{id}/metric/compare [get]
+func compareServerMetric(c *gin.Context) (*model.MetricCompareResponse, error) {
+	server, err := getServerWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var aFrom, aTo, bFrom, bTo time.Time
+	for key, t := range map[string]*time.Time{"a_from": &aFrom, "a_to": &aTo, "b_from": &bFrom, "b_to": &bTo} {
+		ts, err := strconv.ParseInt(c.Query(key), 10, 64)
+		if err != nil {
+			return nil, singleton.Localizer.ErrorT("invalid %s", key)
+		}
+		*t = time.Unix(ts, 0)
+	}
+	duration, truncated, err := model.MetricCompareRange(aFrom, aTo, bFrom, bTo)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := max(duration/metricCompareDefaultBuckets, time.Minute).Truncate(time.Minute)
+	if v := c.Query("interval"); v != "" {
+		if interval, err = model.ParseHistoryInterval(v); err != nil {
+			return nil, err
+		}
+	}
+
+	metric := c.Query("metric")
+	var load func(from, to time.Time) ([]model.MetricPoint, error)
+	switch metric {
+	case model.MetricTransferIn, model.MetricTransferOut:
+		load = func(from, to time.Time) ([]model.MetricPoint, error) {
+			var transfers []model.Transfer
+			if err := singleton.DB.Where("server_id = ? AND created_at >= ? AND created_at < ?", server.ID, from, to).
+				Order("created_at").Find(&transfers).Error; err != nil {
+				return nil, err
+			}
+			points := make([]model.MetricPoint, 0, len(transfers))
+			for _, t := range transfers {
+				v := t.In
+				if metric == model.MetricTransferOut {
+					v = t.Out
+				}
+				points = append(points, model.MetricPoint{At: t.CreatedAt, Value: float64(v)})
+			}
+			return points, nil
+		}
+	case model.MetricServiceDelay:
+		serviceID, err := strconv.ParseUint(c.Query("service"), 10, 64)
+		if err != nil {
+			return nil, singleton.Localizer.ErrorT("invalid %s", "service")
+		}
+		load = func(from, to time.Time) ([]model.MetricPoint, error) {
+			var histories []model.ServiceHistory
+			if err := singleton.DB.Select("created_at, avg_delay").
+				Where("server_id = ? AND service_id = ? AND created_at >= ? AND created_at < ?", server.ID, serviceID, from, to).
+				Order("created_at").Find(&histories).Error; err != nil {
+				return nil, err
+			}
+			points := make([]model.MetricPoint, 0, len(histories))
+			for _, h := range histories {
+				points = append(points, model.MetricPoint{At: h.CreatedAt, Value: float64(h.AvgDelay)})
+			}
+			return points, nil
+		}
+	case model.MetricCustom:
+		name := c.Query("name")
+		if !model.ValidCustomMetricName(name) {
+			return nil, singleton.Localizer.ErrorT("invalid custom metric name %s", name)
+		}
+		load = func(from, to time.Time) ([]model.MetricPoint, error) {
+			histories, err := loadCustomMetricHistory(server.ID, name, from, to)
+			if err != nil {
+				return nil, err
+			}
+			points := make([]model.MetricPoint, 0, len(histories))
+			for _, h := range histories {
+				points = append(points, model.MetricPoint{At: h.CreatedAt, Value: h.Value})
+			}
+			return points, nil
+		}
+	default:
+		return nil, singleton.Localizer.ErrorT("metric %s has no stored history", metric)
+	}
+
+	a, err := load(aFrom, aFrom.Add(duration))
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	b, err := load(bFrom, bFrom.Add(duration))
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	buckets, err := model.CompareMetricSeries(a, b, aFrom, bFrom, duration, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.MetricCompareResponse{
+		Metric:    metric,
+		Interval:  int64(interval / time.Second),
+		AFrom:     aFrom.Unix(),
+		BFrom:     bFrom.Unix(),
+		Duration:  int64(duration / time.Second),
+		Truncated: truncated,
+		Buckets:   buckets,
+	}, nil
+}
+
+// List server custom metrics
+// @Summary List server custom metrics
+// @Security BearerAuth
+// @Schemes
+// @Description List names of custom metrics reported by the agent of a server, including those with stored history only
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]string]
+// @Router /server/{id}/custom-metric [get]
+func listServerCustomMetric(c *gin.Context) ([]string, error) {
+	server, err := getServerWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := singleton.CustomMetricNames(server)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return names, nil
+}
+
+// Get server custom metric history
+// @Summary Get server custom metric history
+// @Security BearerAuth
+// @Schemes
+// @Description Get the per-minute history of a custom metric in the time range, defaults to the last 24 hours
+// @Tags auth required
+// @Param id path uint true "Server ID"
+// @Param name path string true "Custom metric name"
+// @Param from query int false "Start timestamp in seconds"
+// @Param to query int false "End timestamp in seconds"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.CustomMetricHistory]
+// @Router /server/{id}/custom-metric/{name} [get]
+func getServerCustomMetric(c *gin.Context) ([]model.CustomMetricHistory, error) {
+	server, err := getServerWithPermission(c)
+	if err != nil {
+		return nil, err
+	}
+	name := c.Param("name")
+	if !model.ValidCustomMetricName(name) {
+		return nil, singleton.Localizer.ErrorT("invalid custom metric name %s", name)
+	}
+
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	for key, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := c.Query(key); v != "" {
+			ts, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			*t = time.Unix(ts, 0)
+		}
+	}
+	if !from.Before(to) {
+		return nil, singleton.Localizer.ErrorT("invalid time range")
+	}
+
+	histories, err := loadCustomMetricHistory(server.ID, name, from, to)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return histories, nil
+}
+
+func loadCustomMetricHistory(serverID uint64, name string, from, to time.Time) ([]model.CustomMetricHistory, error) {
+	var histories []model.CustomMetricHistory
+	if err := singleton.DB.Where("server_id = ? AND name = ? AND created_at >= ? AND created_at < ?", serverID, name, from, to).
+		Order("created_at").Find(&histories).Error; err != nil {
+		return nil, err
+	}
+	return histories, nil
+}
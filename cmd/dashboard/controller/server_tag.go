@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// List server tags
+// @Summary List server tags
+// @Security BearerAuth
+// @Schemes
+// @Description List tags of servers visible to current user
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.ServerTagResponseItem]
+// @Router /server-tag [get]
+func listServerTag(c *gin.Context) ([]model.ServerTagResponseItem, error) {
+	counts := make(map[string]int)
+
+	singleton.SortedServerLock.RLock()
+	for _, s := range singleton.SortedServerList {
+		if !s.HasPermission(c) {
+			continue
+		}
+		for _, tag := range s.Tags {
+			counts[tag]++
+		}
+	}
+	singleton.SortedServerLock.RUnlock()
+
+	res := make([]model.ServerTagResponseItem, 0, len(counts))
+	for tag, count := range counts {
+		res = append(res, model.ServerTagResponseItem{Tag: tag, Count: count})
+	}
+	slices.SortFunc(res, func(a, b model.ServerTagResponseItem) int {
+		return cmp.Compare(a.Tag, b.Tag)
+	})
+	return res, nil
+}
+
+// Set server tags
+// @Summary Set server tags
+// @Security BearerAuth
+// @Schemes
+// @Description Replace all tags of a server
+// @Tags auth required
+// @Accept json
+// @Param id path uint true "Server ID"
+// @Param body body []string true "tags"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server/{id}/tags [post]
+func updateServerTags(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	if err := c.ShouldBindJSON(&tags); err != nil {
+		return nil, err
+	}
+
+	singleton.ServerLock.RLock()
+	server, ok := singleton.ServerList[id]
+	singleton.ServerLock.RUnlock()
+	if !ok {
+		return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+	}
+	if !server.HasPermission(c) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+
+	if err := singleton.UpdateServerTags(map[uint64][]string{id: model.NormalizeTags(tags)}); err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return nil, nil
+}
+
+// Rename server tag
+// @Summary Rename server tag
+// @Security BearerAuth
+// @Schemes
+// @Description Rename a tag on all servers of current user
+// @Tags auth required
+// @Accept json
+// @Param body body model.ServerTagRenameForm true "ServerTagRenameForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /server-tag/rename [post]
+func renameServerTag(c *gin.Context) (any, error) {
+	var rf model.ServerTagRenameForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return nil, err
+	}
+	if tags := model.NormalizeTags([]string{rf.To}); len(tags) == 0 {
+		return nil, singleton.Localizer.ErrorT("tag can't be empty")
+	} else {
+		rf.To = tags[0]
+	}
+
+	return nil, cascadeServerTags(c, func(tags []string) []string {
+		for i, tag := range tags {
+			if tag == rf.From {
+				tags[i] = rf.To
+			}
+		}
+		return tags
+	}, rf.From)
+}
+
+// Batch delete server tag
+// @Summary Batch delete server tag
+// @Security BearerAuth
+// @Schemes
+// @Description Remove tags from all servers of current user
+// @Tags auth required
+// @Accept json
+// @Param body body []string true "tags"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/server-tag [post]
+func batchDeleteServerTag(c *gin.Context) (any, error) {
+	var tags []string
+	if err := c.ShouldBindJSON(&tags); err != nil {
+		return nil, err
+	}
+
+	return nil, cascadeServerTags(c, func(t []string) []string {
+		return slices.DeleteFunc(t, func(tag string) bool {
+			return slices.Contains(tags, tag)
+		})
+	}, tags...)
+}
+
+// cascadeServerTags 对当前用户有权限且带有指定标签的服务器批量修改标签
+func cascadeServerTags(c *gin.Context, modify func([]string) []string, tags ...string) error {
+	updates := make(map[uint64][]string)
+
+	singleton.SortedServerLock.RLock()
+	for _, s := range singleton.SortedServerList {
+		if !s.HasPermission(c) || !slices.ContainsFunc(tags, s.HasTag) {
+			continue
+		}
+		updates[s.ID] = model.NormalizeTags(modify(slices.Clone(s.Tags)))
+	}
+	singleton.SortedServerLock.RUnlock()
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if err := singleton.UpdateServerTags(updates); err != nil {
+		return newGormError("%v", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func TestSetServerOwner(t *testing.T) {
+	_, adminToken := testCreateUser(t, model.RoleAdmin, 0)
+	oldOwner, _ := testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+	newOwner, _ := testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+	s, _ := testCreateOnlineServer(t, oldOwner.ID)
+
+	sg := model.ServerGroup{Name: "old owner group"}
+	sg.UserID = oldOwner.ID
+	if err := singleton.DB.Create(&sg).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := singleton.DB.Create(&model.ServerGroupServer{ServerGroupId: sg.ID, ServerId: s.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	singleton.UpdateServerGroupMembership()
+
+	path := fmt.Sprintf("/api/v1/server/%d/owner", s.ID)
+	if code, resp := testRequest(t, adminToken, http.MethodPost, path, model.ServerOwnerForm{UserID: newOwner.ID}); !testAllowed(code, resp) {
+		t.Fatalf("set owner: got status %d, response %+v", code, resp)
+	}
+
+	// 读者持有的旧服务器不被修改
+	if s.UserID != oldOwner.ID {
+		t.Fatal("shared server modified in place")
+	}
+	singleton.ServerLock.RLock()
+	current := singleton.ServerList[s.ID]
+	singleton.ServerLock.RUnlock()
+	if current.UserID != newOwner.ID || current.OwnerID != newOwner.ID {
+		t.Fatalf("got owner %d, want %d", current.UserID, newOwner.ID)
+	}
+	if singleton.ServerAccessibleBy(oldOwner.ID, current) {
+		t.Fatal("previous owner keeps access through own server group")
+	}
+	if !singleton.ServerAccessibleBy(newOwner.ID, current) {
+		t.Fatal("new owner can't access server")
+	}
+}
+
+func TestSetServerMaintenance(t *testing.T) {
+	admin, token := testCreateUser(t, model.RoleAdmin, 0)
+	s, _ := testCreateOnlineServer(t, admin.ID)
+
+	path := fmt.Sprintf("/api/v1/server/%d/maintenance", s.ID)
+	if code, resp := testRequest(t, token, http.MethodPost, path, model.ServerMaintenanceForm{Duration: 3600, Reason: "upgrade"}); !testAllowed(code, resp) {
+		t.Fatalf("start maintenance: got status %d, response %+v", code, resp)
+	}
+
+	// 只持有排序列表锁的读者拿到的旧服务器不被修改，排序列表指向新的副本
+	if s.MaintenanceUntil != nil || s.MaintenanceReason != "" {
+		t.Fatal("shared server modified in place")
+	}
+	singleton.SortedServerLock.RLock()
+	i := slices.IndexFunc(singleton.SortedServerList, func(ss *model.Server) bool { return ss.ID == s.ID })
+	current := singleton.SortedServerList[i]
+	singleton.SortedServerLock.RUnlock()
+	if current.MaintenanceUntil == nil || current.MaintenanceReason != "upgrade" {
+		t.Fatalf("maintenance not applied: %+v", current)
+	}
+
+	if code, resp := testRequest(t, token, http.MethodDelete, path, nil); !testAllowed(code, resp) {
+		t.Fatalf("stop maintenance: got status %d, response %+v", code, resp)
+	}
+	if current.MaintenanceUntil == nil {
+		t.Fatal("shared server modified in place")
+	}
+	singleton.ServerLock.RLock()
+	current = singleton.ServerList[s.ID]
+	singleton.ServerLock.RUnlock()
+	if current.MaintenanceUntil != nil {
+		t.Fatal("maintenance not stopped")
+	}
+}
+
+func TestPurgeServersDeletesSecrets(t *testing.T) {
+	_, adminToken := testCreateUser(t, model.RoleAdmin, 0)
+	member, _ := testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+	s, _ := testCreateOnlineServer(t, member.ID)
+
+	secret := model.Secret{ServerID: s.ID, Name: "TOKEN", Ciphertext: "ciphertext"}
+	secret.UserID = member.ID
+	if err := singleton.DB.Create(&secret).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 删除用户时彻底删除其服务器，服务器的密钥一并删除
+	if code, resp := testRequest(t, adminToken, http.MethodPost, "/api/v1/batch-delete/user", []uint64{member.ID}); !testAllowed(code, resp) {
+		t.Fatalf("delete user: got status %d, response %+v", code, resp)
+	}
+	var count int64
+	if err := singleton.DB.Unscoped().Model(&model.Secret{}).Where("server_id = ?", s.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("got %d orphaned secrets", count)
+	}
+}
+
+func TestUpdateServerNote(t *testing.T) {
+	admin, token := testCreateUser(t, model.RoleAdmin, 0)
+	s, _ := testCreateOnlineServer(t, admin.ID)
+
+	path := fmt.Sprintf("/api/v1/server/%d/note", s.ID)
+	if code, resp := testRequest(t, token, http.MethodPost, path, model.ServerNoteForm{Note: "runbook: https://example.com"}); !testAllowed(code, resp) {
+		t.Fatalf("update note: got status %d, response %+v", code, resp)
+	}
+
+	// 读者持有的旧服务器不被修改，排序列表指向新的副本
+	if s.Note != "" {
+		t.Fatal("shared server modified in place")
+	}
+	singleton.SortedServerLock.RLock()
+	i := slices.IndexFunc(singleton.SortedServerList, func(ss *model.Server) bool { return ss.ID == s.ID })
+	current := singleton.SortedServerList[i]
+	singleton.SortedServerLock.RUnlock()
+	if current.Note != "runbook: https://example.com" {
+		t.Fatalf("got note %q", current.Note)
+	}
+}
@@ -1,12 +1,15 @@
 package controller
 
 import (
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/copier"
+	"golang.org/x/net/http/httpguts"
 
 	"github.com/nezhahq/nezha/model"
 	"github.com/nezhahq/nezha/service/singleton"
@@ -61,17 +64,27 @@ func listService(c *gin.Context) ([]*model.Service, error) {
 	if err := copier.Copy(&ss, singleton.ServiceSentinelShared.ServiceList); err != nil {
 		return nil, err
 	}
+	for _, s := range ss {
+		s.RedactSecrets()
+	}
 
 	return ss, nil
 }
 
+// 不超过该时长的查询范围忽略聚合区间，返回原始数据点
+const serviceHistoryRawRange = 6 * time.Hour
+
 // List service histories by server id
 // @Summary List service histories by server id
 // @Security BearerAuth
 // @Schemes
-// @Description List service histories by server id
+// @Description List service histories by server id, points in ranges longer than 6 hours can be aggregated into buckets aligned to local time.
+// @Description Latency percentiles (p50/p90/p95/p99) of each service are computed over all points in the range regardless of aggregation.
 // @Tags common
-// @param id path uint true "Server ID"
+// @param id path uint true "Server ID, 0 for services probed by the dashboard"
+// @Param from query int false "Unix timestamp in seconds, 24 hours ago by default"
+// @Param to query int false "Unix timestamp in seconds, now by default"
+// @Param interval query string false "Bucket size such as 30m, 1h or 1d, returns min/avg/max delay per bucket"
 // @Produce json
 // @Success 200 {object} model.CommonResponse[[]model.ServiceInfos]
 // @Router /service/{id} [get]
@@ -82,23 +95,51 @@ func listServiceHistory(c *gin.Context) ([]*model.ServiceInfos, error) {
 		return nil, err
 	}
 
-	singleton.ServerLock.RLock()
-	server, ok := singleton.ServerList[id]
-	if !ok {
-		return nil, singleton.Localizer.ErrorT("server not found")
+	// 服务器 ID 为 0 时返回面板探测的延迟记录
+	if id != 0 {
+		singleton.ServerLock.RLock()
+		server, ok := singleton.ServerList[id]
+		if !ok {
+			singleton.ServerLock.RUnlock()
+			return nil, singleton.Localizer.ErrorT("server not found")
+		}
+
+		_, isMember := c.Get(model.CtxKeyAuthorizedUser)
+		authorized := isMember // TODO || isViewPasswordVerfied
+
+		if server.HideForGuest && !authorized {
+			singleton.ServerLock.RUnlock()
+			return nil, singleton.Localizer.ErrorT("unauthorized")
+		}
+		singleton.ServerLock.RUnlock()
 	}
 
-	_, isMember := c.Get(model.CtxKeyAuthorizedUser)
-	authorized := isMember // TODO || isViewPasswordVerfied
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	for key, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := c.Query(key); v != "" {
+			ts, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			*t = time.Unix(ts, 0)
+		}
+	}
 
-	if server.HideForGuest && !authorized {
-		return nil, singleton.Localizer.ErrorT("unauthorized")
+	var interval time.Duration
+	if v := c.Query("interval"); v != "" {
+		if interval, err = model.ParseHistoryInterval(v); err != nil {
+			return nil, err
+		}
+		// 短时间范围的原始数据点不多，直接返回
+		if to.Sub(from) <= serviceHistoryRawRange {
+			interval = 0
+		}
 	}
-	singleton.ServerLock.RUnlock()
 
 	var serviceHistories []*model.ServiceHistory
 	if err := singleton.DB.Model(&model.ServiceHistory{}).Select("service_id, created_at, server_id, avg_delay").
-		Where("server_id = ?", id).Where("created_at >= ?", time.Now().Add(-24*time.Hour)).Order("service_id, created_at").
+		Scopes(reporterLatencyScope(id)).Where("created_at >= ? AND created_at <= ?", from, to).Order("service_id, created_at").
 		Scan(&serviceHistories).Error; err != nil {
 		return nil, err
 	}
@@ -110,6 +151,8 @@ func listServiceHistory(c *gin.Context) ([]*model.ServiceInfos, error) {
 
 	var sortedServiceIDs []uint64
 	resultMap := make(map[uint64]*model.ServiceInfos)
+	buckets := make(map[uint64]*model.HistoryAggregate) // [ServiceID] -> 当前区间
+	samplers := make(map[uint64]*model.LatencySampler)
 	for _, history := range serviceHistories {
 		infos, ok := resultMap[history.ServiceID]
 		if !ok {
@@ -117,23 +160,55 @@ func listServiceHistory(c *gin.Context) ([]*model.ServiceInfos, error) {
 				ServiceID:   history.ServiceID,
 				ServerID:    history.ServerID,
 				ServiceName: singleton.ServiceSentinelShared.Services[history.ServiceID].Name,
-				ServerName:  singleton.ServerList[history.ServerID].Name,
+				ServerName:  singleton.ReporterName(history.ServerID),
 			}
 			resultMap[history.ServiceID] = infos
 			sortedServiceIDs = append(sortedServiceIDs, history.ServiceID)
+			samplers[history.ServiceID] = &model.LatencySampler{}
+		}
+		samplers[history.ServiceID].Add(float64(history.AvgDelay))
+		if interval == 0 {
+			infos.CreatedAt = append(infos.CreatedAt, history.CreatedAt.Truncate(time.Minute).Unix()*1000)
+			infos.AvgDelay = append(infos.AvgDelay, history.AvgDelay)
+			continue
+		}
+
+		bucket := model.HistoryBucketStart(history.CreatedAt, interval, singleton.Loc).Unix() * 1000
+		last := len(infos.CreatedAt) - 1
+		if last < 0 || infos.CreatedAt[last] != bucket {
+			infos.CreatedAt = append(infos.CreatedAt, bucket)
+			infos.AvgDelay = append(infos.AvgDelay, 0)
+			infos.MinDelay = append(infos.MinDelay, 0)
+			infos.MaxDelay = append(infos.MaxDelay, 0)
+			buckets[history.ServiceID] = &model.HistoryAggregate{}
+			last++
 		}
-		infos.CreatedAt = append(infos.CreatedAt, history.CreatedAt.Truncate(time.Minute).Unix()*1000)
-		infos.AvgDelay = append(infos.AvgDelay, history.AvgDelay)
+		agg := buckets[history.ServiceID]
+		agg.Add(float64(history.AvgDelay))
+		infos.AvgDelay[last] = float32(agg.Avg)
+		infos.MinDelay[last] = float32(agg.Min)
+		infos.MaxDelay[last] = float32(agg.Max)
 	}
 
 	ret := make([]*model.ServiceInfos, 0, len(sortedServiceIDs))
 	for _, id := range sortedServiceIDs {
+		resultMap[id].Latency = samplers[id].Percentiles()
 		ret = append(ret, resultMap[id])
 	}
 
 	return ret, nil
 }
 
+// reporterLatencyScope 限定为监测点的延迟记录，监测点为面板时排除同样以 0 记录的汇总记录
+func reporterLatencyScope(serverID uint64) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if serverID == 0 {
+			return tx.Where("server_id = 0 AND up = 0 AND down = 0")
+		}
+		return tx.Where("server_id = ?", serverID)
+	}
+}
+
 // List server with service
 // @Summary List server with service
 // @Security BearerAuth
@@ -147,7 +222,7 @@ func listServerWithServices(c *gin.Context) ([]uint64, error) {
 	var serverIdsWithService []uint64
 	if err := singleton.DB.Model(&model.ServiceHistory{}).
 		Select("distinct(server_id)").
-		Where("server_id != 0").
+		Where("server_id != 0 OR (up = 0 AND down = 0)").
 		Find(&serverIdsWithService).Error; err != nil {
 		return nil, newGormError("%v", err)
 	}
@@ -157,6 +232,11 @@ func listServerWithServices(c *gin.Context) ([]uint64, error) {
 
 	var ret []uint64
 	for _, id := range serverIdsWithService {
+		// 面板探测的延迟记录
+		if id == 0 {
+			ret = append(ret, id)
+			continue
+		}
 		singleton.ServerLock.RLock()
 		server, ok := singleton.ServerList[id]
 		if !ok {
@@ -190,31 +270,15 @@ func createService(c *gin.Context) (uint64, error) {
 		return 0, err
 	}
 
-	uid := getUid(c)
-
-	var m model.Service
-	m.UserID = uid
-	m.Name = mf.Name
-	m.Target = strings.TrimSpace(mf.Target)
-	m.Type = mf.Type
-	m.SkipServers = mf.SkipServers
-	m.Cover = mf.Cover
-	m.Notify = mf.Notify
-	m.NotificationGroupID = mf.NotificationGroupID
-	m.Duration = mf.Duration
-	m.LatencyNotify = mf.LatencyNotify
-	m.MinLatency = mf.MinLatency
-	m.MaxLatency = mf.MaxLatency
-	m.EnableShowInService = mf.EnableShowInService
-	m.EnableTriggerTask = mf.EnableTriggerTask
-	m.RecoverTriggerTasks = mf.RecoverTriggerTasks
-	m.FailTriggerTasks = mf.FailTriggerTasks
-
-	if err := validateServers(c, &m); err != nil {
+	m, err := newServiceFromForm(getUid(c), &mf)
+	if err != nil {
+		return 0, err
+	}
+	if err := validateServers(c, m); err != nil {
 		return 0, err
 	}
 
-	if err := singleton.DB.Create(&m).Error; err != nil {
+	if err := singleton.DB.Create(m).Error; err != nil {
 		return 0, newGormError("%v", err)
 	}
 
@@ -223,7 +287,6 @@ func createService(c *gin.Context) (uint64, error) {
 		skipServers = append(skipServers, k)
 	}
 
-	var err error
 	if m.Cover == 0 {
 		err = singleton.DB.Unscoped().Delete(&model.ServiceHistory{}, "service_id = ? and server_id in (?)", m.ID, skipServers).Error
 	} else {
@@ -233,7 +296,7 @@ func createService(c *gin.Context) (uint64, error) {
 		return 0, err
 	}
 
-	if err := singleton.ServiceSentinelShared.OnServiceUpdate(m); err != nil {
+	if err := singleton.ServiceSentinelShared.OnServiceUpdate(*m); err != nil {
 		return 0, err
 	}
 
@@ -241,6 +304,86 @@ func createService(c *gin.Context) (uint64, error) {
 	return m.ID, nil
 }
 
+// Batch create service from a template
+// @Summary Batch create service from a template
+// @Security BearerAuth
+// @Schemes
+// @Description Create a service for each target from a template, "{{target}}" in the template name and target is replaced by the target. Invalid targets are reported and skipped, the rest are created in one transaction. With dry_run nothing is created.
+// @Tags auth required
+// @Accept json
+// @param request body model.ServiceBatchForm true "ServiceBatchForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ServiceBatchResult]
+// @Router /batch/service [post]
+func batchCreateService(c *gin.Context) (*model.ServiceBatchResult, error) {
+	var bf model.ServiceBatchForm
+	if err := c.ShouldBindJSON(&bf); err != nil {
+		return nil, err
+	}
+	if len(bf.Targets) == 0 {
+		return nil, singleton.Localizer.ErrorT("targets are required")
+	}
+	if len(bf.Targets) > model.MaxServiceBatchTargets {
+		return nil, singleton.Localizer.ErrorT("too many targets, at most %d", model.MaxServiceBatchTargets)
+	}
+	if err := validateServers(c, &model.Service{SkipServers: bf.Template.SkipServers}); err != nil {
+		return nil, err
+	}
+
+	uid := getUid(c)
+	res := &model.ServiceBatchResult{DryRun: bf.DryRun, Created: make([]model.ServiceBatchItem, 0, len(bf.Targets))}
+	var services []*model.Service
+	var targets []string
+	seen := make(map[string]bool, len(bf.Targets))
+	for _, target := range bf.Targets {
+		target = strings.TrimSpace(target)
+		var err error
+		var m *model.Service
+		if target == "" {
+			err = singleton.Localizer.ErrorT("target is empty")
+		} else if seen[target] {
+			err = singleton.Localizer.ErrorT("duplicate target")
+		} else {
+			seen[target] = true
+			mf := bf.Expand(target)
+			if m, err = newServiceFromForm(uid, &mf); err == nil {
+				err = validateServiceTarget(m)
+			}
+		}
+		if err != nil {
+			res.Failed = append(res.Failed, model.ServiceBatchFailure{Target: target, Error: err.Error()})
+			continue
+		}
+		services = append(services, m)
+		targets = append(targets, target)
+	}
+
+	if !bf.DryRun && len(services) > 0 {
+		err := singleton.DB.Transaction(func(tx *gorm.DB) error {
+			for _, m := range services {
+				if err := tx.Create(m).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, newGormError("%v", err)
+		}
+		for _, m := range services {
+			if err := singleton.ServiceSentinelShared.OnServiceUpdate(*m); err != nil {
+				return nil, err
+			}
+		}
+		singleton.ServiceSentinelShared.UpdateServiceList()
+	}
+
+	for i, m := range services {
+		res.Created = append(res.Created, model.ServiceBatchItem{ID: m.ID, Target: targets[i], Name: m.Name, URL: m.Target})
+	}
+	return res, nil
+}
+
 // Update service
 // @Summary Update service
 // @Security BearerAuth
@@ -271,6 +414,7 @@ func updateService(c *gin.Context) (any, error) {
 	if !m.HasPermission(c) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
+	old := m
 
 	m.Name = mf.Name
 	m.Target = strings.TrimSpace(mf.Target)
@@ -283,6 +427,24 @@ func updateService(c *gin.Context) (any, error) {
 	m.LatencyNotify = mf.LatencyNotify
 	m.MinLatency = mf.MinLatency
 	m.MaxLatency = mf.MaxLatency
+	m.CertExpireDays = mf.CertExpireDays
+	m.Keyword = mf.Keyword
+	m.KeywordRegex = mf.KeywordRegex
+	m.KeywordInvert = mf.KeywordInvert
+	m.KeywordIgnoreCase = mf.KeywordIgnoreCase
+	if a := singleton.HTTPAssertionOf(&m); a != nil {
+		if _, err := a.Matcher(); err != nil {
+			return nil, singleton.Localizer.ErrorT("invalid keyword assertion: %v", err)
+		}
+	}
+	m.Headers = mf.Headers
+	m.AuthType = mf.AuthType
+	m.AuthUsername = mf.AuthUsername
+	m.AuthSecret = mf.AuthSecret
+	if err := validateServiceRequest(&m); err != nil {
+		return nil, err
+	}
+	m.RestoreSecrets(&old)
 	m.EnableShowInService = mf.EnableShowInService
 	m.EnableTriggerTask = mf.EnableTriggerTask
 	m.RecoverTriggerTasks = mf.RecoverTriggerTasks
@@ -360,6 +522,59 @@ func batchDeleteService(c *gin.Context) (any, error) {
 	return nil, nil
 }
 
+// newServiceFromForm 根据表单生成新的服务监控并检查请求设置
+func newServiceFromForm(uid uint64, mf *model.ServiceForm) (*model.Service, error) {
+	var m model.Service
+	m.UserID = uid
+	m.Name = mf.Name
+	m.Target = strings.TrimSpace(mf.Target)
+	m.Type = mf.Type
+	m.SkipServers = mf.SkipServers
+	m.Cover = mf.Cover
+	m.Notify = mf.Notify
+	m.NotificationGroupID = mf.NotificationGroupID
+	m.Duration = mf.Duration
+	m.LatencyNotify = mf.LatencyNotify
+	m.MinLatency = mf.MinLatency
+	m.MaxLatency = mf.MaxLatency
+	m.CertExpireDays = mf.CertExpireDays
+	m.Keyword = mf.Keyword
+	m.KeywordRegex = mf.KeywordRegex
+	m.KeywordInvert = mf.KeywordInvert
+	m.KeywordIgnoreCase = mf.KeywordIgnoreCase
+	if a := singleton.HTTPAssertionOf(&m); a != nil {
+		if _, err := a.Matcher(); err != nil {
+			return nil, singleton.Localizer.ErrorT("invalid keyword assertion: %v", err)
+		}
+	}
+	m.Headers = mf.Headers
+	m.AuthType = mf.AuthType
+	m.AuthUsername = mf.AuthUsername
+	m.AuthSecret = mf.AuthSecret
+	if err := validateServiceRequest(&m); err != nil {
+		return nil, err
+	}
+	m.EnableShowInService = mf.EnableShowInService
+	m.EnableTriggerTask = mf.EnableTriggerTask
+	m.RecoverTriggerTasks = mf.RecoverTriggerTasks
+	m.FailTriggerTasks = mf.FailTriggerTasks
+	return &m, nil
+}
+
+// validateServiceTarget 检查批量创建时代入模板后的监控目标，HTTP 监控须为 http(s) 地址
+func validateServiceTarget(m *model.Service) error {
+	if m.Name == "" || m.Target == "" {
+		return singleton.Localizer.ErrorT("name and target are required")
+	}
+	if m.Type == model.TaskTypeHTTPGet {
+		u, err := url.Parse(m.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return singleton.Localizer.ErrorT("invalid target: %s", m.Target)
+		}
+	}
+	return nil
+}
+
 func validateServers(c *gin.Context, ss *model.Service) error {
 	singleton.ServerLock.RLock()
 	defer singleton.ServerLock.RUnlock()
@@ -374,3 +589,37 @@ func validateServers(c *gin.Context, ss *model.Service) error {
 
 	return nil
 }
+
+// validateServiceRequest 检查 HTTP 监控的请求头与认证，请求头名称统一为规范形式
+func validateServiceRequest(m *model.Service) error {
+	if len(m.Headers) > 0 {
+		headers := make(map[string]string, len(m.Headers))
+		for k, v := range m.Headers {
+			if !httpguts.ValidHeaderFieldName(k) || !httpguts.ValidHeaderFieldValue(v) {
+				return singleton.Localizer.ErrorT("invalid header: %s", k)
+			}
+			k = http.CanonicalHeaderKey(k)
+			if _, ok := headers[k]; ok {
+				return singleton.Localizer.ErrorT("duplicate header: %s", k)
+			}
+			headers[k] = v
+		}
+		m.Headers = headers
+	}
+
+	switch m.AuthType {
+	case "":
+		m.AuthUsername, m.AuthSecret = "", ""
+		return nil
+	case model.ServiceAuthBasic, model.ServiceAuthBearer:
+	default:
+		return singleton.Localizer.ErrorT("unsupported auth type: %s", m.AuthType)
+	}
+	if _, ok := m.Headers["Authorization"]; ok {
+		return singleton.Localizer.ErrorT("authorization header conflicts with auth type")
+	}
+	if m.AuthSecret == "" || (m.AuthType == model.ServiceAuthBasic && m.AuthUsername == "") {
+		return singleton.Localizer.ErrorT("auth credentials are required")
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// testCreateService 创建服务监控并返回 ID
+func testCreateService(t *testing.T, token string, form model.ServiceForm) uint64 {
+	t.Helper()
+	code, resp := testRequest(t, token, http.MethodPost, "/api/v1/service", form)
+	if !testAllowed(code, resp) {
+		t.Fatalf("create service: got status %d, response %+v", code, resp)
+	}
+	var id uint64
+	if err := json.Unmarshal(resp.Data, &id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// testReporterLatency 等待面板探测的延迟记录写入并返回延迟图表的数据
+func testReporterLatency(t *testing.T, token string, serviceID uint64) *model.ServiceInfos {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		code, resp := testRequest(t, token, http.MethodGet, "/api/v1/service/0", nil)
+		if !testAllowed(code, resp) {
+			t.Fatalf("list dashboard latency: got status %d, response %+v", code, resp)
+		}
+		var infos []*model.ServiceInfos
+		if len(resp.Data) > 0 {
+			if err := json.Unmarshal(resp.Data, &infos); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if i := slices.IndexFunc(infos, func(info *model.ServiceInfos) bool { return info.ServiceID == serviceID }); i >= 0 {
+			return infos[i]
+		}
+	}
+	t.Fatalf("no latency recorded for service %d", serviceID)
+	return nil
+}
+
+func TestDashboardProbeLatencyHistory(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+	id := testCreateService(t, token, model.ServiceForm{
+		Name: "grpc", Type: model.TaskTypeGRPCHealth, Target: "203.0.113.1:50051", Duration: 3600,
+	})
+
+	// 面板探测的结果与 Agent 上报的结果一样按监测点记录延迟，监测点 ID 为 0
+	for range singleton.Conf.AvgPingCount {
+		singleton.ServiceSentinelShared.Dispatch(singleton.ReportData{
+			Data: &pb.TaskResult{Id: id, Type: model.TaskTypeGRPCHealth, Delay: 10, Successful: true},
+		})
+	}
+	info := testReporterLatency(t, token, id)
+	if len(info.AvgDelay) != 1 || info.AvgDelay[0] != 10 {
+		t.Fatalf("got latency %v, want [10]", info.AvgDelay)
+	}
+	if info.ServerName != singleton.Localizer.T("Dashboard") {
+		t.Fatalf("got reporter %q", info.ServerName)
+	}
+
+	code, resp := testRequest(t, token, http.MethodGet, "/api/v1/service/server", nil)
+	if !testAllowed(code, resp) {
+		t.Fatalf("list servers with service: got status %d, response %+v", code, resp)
+	}
+	var servers []uint64
+	if err := json.Unmarshal(resp.Data, &servers); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(servers, 0) {
+		t.Fatalf("dashboard reporter missing from %v", servers)
+	}
+}
+
+func TestDNSMismatchReasonInHistory(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+	id := testCreateService(t, token, model.ServiceForm{
+		Name: "dns", Type: model.TaskTypeDNS, Target: "example.com A 192.0.2.1", Duration: 3600,
+	})
+
+	const reason = "answer mismatch: got 192.0.2.2, want 192.0.2.1"
+	for i := range singleton.Conf.AvgPingCount {
+		r := &pb.TaskResult{Id: id, Type: model.TaskTypeDNS, Delay: 5, Successful: true, Data: "192.0.2.1"}
+		if i == 0 {
+			r.Successful, r.Data = false, reason
+		}
+		singleton.ServiceSentinelShared.Dispatch(singleton.ReportData{Data: r})
+	}
+	if info := testReporterLatency(t, token, id); len(info.AvgDelay) != 1 || info.AvgDelay[0] != 5 {
+		t.Fatalf("got latency %v, want [5]", info.AvgDelay)
+	}
+
+	var h model.ServiceHistory
+	if err := singleton.DB.Where("service_id = ? AND server_id = 0 AND up = 0 AND down = 0", id).First(&h).Error; err != nil {
+		t.Fatal(err)
+	}
+	if h.Data != reason {
+		t.Fatalf("got data %q, want the mismatch reason", h.Data)
+	}
+}
+
+func TestServiceBadgeTokenRedacted(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+	id := testCreateService(t, token, model.ServiceForm{
+		Name: "badge", Type: model.TaskTypeTCPPing, Target: "203.0.113.1:80", Duration: 3600,
+	})
+
+	code, resp := testRequest(t, token, http.MethodPost, fmt.Sprintf("/api/v1/service/%d/badge-token", id), nil)
+	if !testAllowed(code, resp) {
+		t.Fatalf("create badge token: got status %d, response %+v", code, resp)
+	}
+	var badgeToken string
+	if err := json.Unmarshal(resp.Data, &badgeToken); err != nil {
+		t.Fatal(err)
+	}
+
+	// 徽章令牌只在生成时返回，列表中隐去
+	code, resp = testRequest(t, token, http.MethodGet, "/api/v1/service/list", nil)
+	if !testAllowed(code, resp) {
+		t.Fatalf("list services: got status %d, response %+v", code, resp)
+	}
+	var services []*model.Service
+	if err := json.Unmarshal(resp.Data, &services); err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(services, func(s *model.Service) bool { return s.ID == id })
+	if i < 0 || services[i].BadgeToken != model.RedactedValue {
+		t.Fatalf("badge token not redacted in service list: %s", resp.Data)
+	}
+
+	w := httptest.NewRecorder()
+	testDashboard(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/monitor/%d/badge.svg?token=%s", id, badgeToken), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("badge with token: got status %d", w.Code)
+	}
+}
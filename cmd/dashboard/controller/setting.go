@@ -3,10 +3,13 @@ package controller
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/geoip"
+	"github.com/nezhahq/nezha/pkg/utils"
 	"github.com/nezhahq/nezha/service/singleton"
 )
 
@@ -41,10 +44,24 @@ func listConfig(c *gin.Context) (model.SettingResponse, error) {
 	}
 
 	conf.Config.Language = strings.Replace(conf.Config.Language, "_", "-", -1)
+	conf.OIDCLogin = singleton.Conf.OIDC.Enabled()
 
 	return conf, nil
 }
 
+// Get password policy
+// @Summary Get password policy
+// @Security BearerAuth
+// @Schemes
+// @Description Get the active password policy
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.PasswordPolicy]
+// @Router /setting/password-policy [get]
+func getPasswordPolicy(c *gin.Context) (model.PasswordPolicy, error) {
+	return singleton.Conf.PasswordPolicy, nil
+}
+
 // Edit config
 // @Summary Edit config
 // @Security BearerAuth
@@ -73,28 +90,301 @@ func updateConfig(c *gin.Context) (any, error) {
 	if !userTemplateValid {
 		return nil, errors.New("invalid user template")
 	}
+	// 自定义代码会注入到所有用户的页面中，只有超级管理员可以修改
+	if sf.CustomCode != singleton.Conf.CustomCode || sf.CustomCodeDashboard != singleton.Conf.CustomCodeDashboard {
+		if !c.MustGet(model.CtxKeyAuthorizedUser).(*model.User).IsSuperAdmin() {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+	// 跨域策略决定哪些站点可以携带登录凭据访问接口，同样只有超级管理员可以修改
+	if sf.CORS != nil && *sf.CORS != singleton.Conf.CORS {
+		if !c.MustGet(model.CtxKeyAuthorizedUser).(*model.User).IsSuperAdmin() {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
 
-	singleton.Conf.Language = strings.Replace(sf.Language, "-", "_", -1)
+	if sf.MinAgentVersion != "" {
+		if _, ok := utils.ParseVersion(sf.MinAgentVersion); !ok {
+			return nil, singleton.Localizer.ErrorT("invalid agent version: %s", sf.MinAgentVersion)
+		}
+	}
+	if _, err := utils.ParsePrefixList(sf.TrustedProxies); err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid trusted proxies: %v", err)
+	}
+	if sf.SessionIdleTimeout != nil && *sf.SessionIdleTimeout < 0 {
+		return nil, singleton.Localizer.ErrorT("session idle timeout can't be negative")
+	}
+	if sf.TransferRetention != nil && *sf.TransferRetention < 0 {
+		return nil, singleton.Localizer.ErrorT("retention days can't be negative")
+	}
+	if sf.PasswordPolicy != nil && sf.PasswordPolicy.MinLength < 1 {
+		return nil, singleton.Localizer.ErrorT("password minimum length must be at least 1")
+	}
+	if sf.CORS != nil {
+		if _, err := sf.CORS.Policy(); err != nil {
+			return nil, singleton.Localizer.ErrorT("invalid cors policy: %v", err)
+		}
+	}
+	if err := geoip.SetDatabases(sf.GeoIPCityDatabase, sf.GeoIPASNDatabase); err != nil {
+		geoip.SetDatabases(singleton.Conf.GeoIPCityDatabase, singleton.Conf.GeoIPASNDatabase)
+		return nil, singleton.Localizer.ErrorT("failed to load geoip database: %v", err)
+	}
 
-	singleton.Conf.EnableIPChangeNotification = sf.EnableIPChangeNotification
-	singleton.Conf.EnablePlainIPInNotification = sf.EnablePlainIPInNotification
-	singleton.Conf.Cover = sf.Cover
-	singleton.Conf.InstallHost = sf.InstallHost
-	singleton.Conf.IgnoredIPNotification = sf.IgnoredIPNotification
-	singleton.Conf.IPChangeNotificationGroupID = sf.IPChangeNotificationGroupID
-	singleton.Conf.SiteName = sf.SiteName
-	singleton.Conf.DNSServers = sf.DNSServers
-	singleton.Conf.CustomCode = sf.CustomCode
-	singleton.Conf.CustomCodeDashboard = sf.CustomCodeDashboard
-	singleton.Conf.RealIPHeader = sf.RealIPHeader
-	singleton.Conf.TLS = sf.TLS
-	singleton.Conf.UserTemplate = sf.UserTemplate
+	// 全部校验通过后在副本上修改，保存成功才替换当前配置，避免配置只更新了一部分
+	var before model.SettingForm
+	if err := singleton.UpdateConf(func(conf *model.Config) error {
+		before = settingAuditSummary(conf)
+		conf.Language = strings.Replace(sf.Language, "-", "_", -1)
 
-	if err := singleton.Conf.Save(); err != nil {
+		conf.EnableIPChangeNotification = sf.EnableIPChangeNotification
+		conf.EnablePlainIPInNotification = sf.EnablePlainIPInNotification
+		conf.Cover = sf.Cover
+		conf.InstallHost = sf.InstallHost
+		conf.IgnoredIPNotification = sf.IgnoredIPNotification
+		conf.IPChangeNotificationGroupID = sf.IPChangeNotificationGroupID
+		conf.SiteName = sf.SiteName
+		conf.DNSServers = sf.DNSServers
+		conf.CustomCode = sf.CustomCode
+		conf.CustomCodeDashboard = sf.CustomCodeDashboard
+		conf.MinAgentVersion = sf.MinAgentVersion
+		conf.OutdatedAgentNotificationGroupID = sf.OutdatedAgentNotificationGroupID
+		conf.NewServerNotificationGroupID = sf.NewServerNotificationGroupID
+		conf.RealIPHeader = sf.RealIPHeader
+		conf.TrustedProxies = sf.TrustedProxies
+		conf.TLS = sf.TLS
+		conf.UserTemplate = sf.UserTemplate
+		if sf.LoginLockoutThreshold > 0 {
+			conf.LoginLockoutThreshold = sf.LoginLockoutThreshold
+		}
+		if sf.LoginLockoutWindow > 0 {
+			conf.LoginLockoutWindow = sf.LoginLockoutWindow
+		}
+		if sf.CronOutputLimit > 0 {
+			conf.CronOutputLimit = sf.CronOutputLimit
+		}
+		if sf.CronHistoryRetention > 0 {
+			conf.CronHistoryRetention = sf.CronHistoryRetention
+		}
+		if sf.CommandAckTimeout > 0 {
+			conf.CommandAckTimeout = sf.CommandAckTimeout
+		}
+		if sf.ServerOfflineTimeout > 0 {
+			conf.ServerOfflineTimeout = sf.ServerOfflineTimeout
+		}
+		if sf.ServerTrashRetention > 0 {
+			conf.ServerTrashRetention = sf.ServerTrashRetention
+		}
+		if sf.NotificationDedupWindow != 0 {
+			conf.NotificationDedupWindow = sf.NotificationDedupWindow
+		}
+		if sf.ServerListCacheTTL != 0 {
+			conf.ServerListCacheTTL = sf.ServerListCacheTTL
+		}
+		if sf.FMMaxFileSize > 0 {
+			conf.FMMaxFileSize = sf.FMMaxFileSize
+		}
+		if sf.TerminalRecordingLimit > 0 {
+			conf.TerminalRecordingLimit = sf.TerminalRecordingLimit
+		}
+		if sf.TerminalRecordingRetention > 0 {
+			conf.TerminalRecordingRetention = sf.TerminalRecordingRetention
+		}
+		if sf.ServiceHistoryRetention > 0 {
+			conf.ServiceHistoryRetention = sf.ServiceHistoryRetention
+		}
+		if sf.ServiceHistoryDetailRetention > 0 {
+			conf.ServiceHistoryDetailRetention = sf.ServiceHistoryDetailRetention
+		}
+		if sf.SessionIdleTimeout != nil {
+			conf.SessionIdleTimeout = *sf.SessionIdleTimeout
+		}
+		if sf.TransferRetention != nil {
+			conf.TransferRetention = *sf.TransferRetention
+		}
+		if sf.MaxCustomMetrics > 0 {
+			conf.MaxCustomMetrics = sf.MaxCustomMetrics
+		}
+		if sf.CustomMetricRetention > 0 {
+			conf.CustomMetricRetention = sf.CustomMetricRetention
+		}
+		if sf.PasswordPolicy != nil {
+			conf.PasswordPolicy = *sf.PasswordPolicy
+		}
+		if sf.CORS != nil {
+			conf.CORS = *sf.CORS
+		}
+		conf.GeoIPCityDatabase = sf.GeoIPCityDatabase
+		conf.GeoIPASNDatabase = sf.GeoIPASNDatabase
+		return nil
+	}); err != nil {
+		geoip.SetDatabases(singleton.Conf.GeoIPCityDatabase, singleton.Conf.GeoIPASNDatabase)
 		return nil, newGormError("%v", err)
 	}
 
+	singleton.OnTrustedProxiesUpdate(singleton.Conf.TrustedProxies)
+	singleton.OnCORSUpdate(singleton.Conf.CORS)
 	singleton.OnNameserverUpdate()
+	singleton.InvalidateServerListCache()
 	singleton.OnUpdateLang(singleton.Conf.Language)
+	recordAuditLog(c, model.AuditActionSettingUpdate, "setting", before, settingAuditSummary(singleton.Conf))
 	return nil, nil
 }
+
+// settingAuditSummary 审计日志中记录的可编辑配置项，不含密钥
+func settingAuditSummary(conf *model.Config) model.SettingForm {
+	policy, transferRetention, corsConf := conf.PasswordPolicy, conf.TransferRetention, conf.CORS
+	sessionIdleTimeout := conf.SessionIdleTimeout
+	return model.SettingForm{
+		DNSServers:                       conf.DNSServers,
+		IgnoredIPNotification:            conf.IgnoredIPNotification,
+		IPChangeNotificationGroupID:      conf.IPChangeNotificationGroupID,
+		Cover:                            conf.Cover,
+		SiteName:                         conf.SiteName,
+		Language:                         conf.Language,
+		InstallHost:                      conf.InstallHost,
+		CustomCode:                       conf.CustomCode,
+		CustomCodeDashboard:              conf.CustomCodeDashboard,
+		RealIPHeader:                     conf.RealIPHeader,
+		TrustedProxies:                   conf.TrustedProxies,
+		UserTemplate:                     conf.UserTemplate,
+		LoginLockoutThreshold:            conf.LoginLockoutThreshold,
+		LoginLockoutWindow:               conf.LoginLockoutWindow,
+		SessionIdleTimeout:               &sessionIdleTimeout,
+		CronOutputLimit:                  conf.CronOutputLimit,
+		CronHistoryRetention:             conf.CronHistoryRetention,
+		CommandAckTimeout:                conf.CommandAckTimeout,
+		ServerTrashRetention:             conf.ServerTrashRetention,
+		ServerOfflineTimeout:             conf.ServerOfflineTimeout,
+		NotificationDedupWindow:          conf.NotificationDedupWindow,
+		ServerListCacheTTL:               conf.ServerListCacheTTL,
+		MinAgentVersion:                  conf.MinAgentVersion,
+		OutdatedAgentNotificationGroupID: conf.OutdatedAgentNotificationGroupID,
+		NewServerNotificationGroupID:     conf.NewServerNotificationGroupID,
+		FMMaxFileSize:                    conf.FMMaxFileSize,
+		TerminalRecordingLimit:           conf.TerminalRecordingLimit,
+		TerminalRecordingRetention:       conf.TerminalRecordingRetention,
+		ServiceHistoryRetention:          conf.ServiceHistoryRetention,
+		ServiceHistoryDetailRetention:    conf.ServiceHistoryDetailRetention,
+		TransferRetention:                &transferRetention,
+		PasswordPolicy:                   &policy,
+		CORS:                             &corsConf,
+		GeoIPCityDatabase:                conf.GeoIPCityDatabase,
+		GeoIPASNDatabase:                 conf.GeoIPASNDatabase,
+		TLS:                              conf.TLS,
+		EnableIPChangeNotification:       conf.EnableIPChangeNotification,
+		EnablePlainIPInNotification:      conf.EnablePlainIPInNotification,
+	}
+}
+
+// Export dashboard configuration
+// @Summary Export dashboard configuration
+// @Security BearerAuth
+// @Schemes
+// @Description Export servers, services, alert rules, notifications and cron tasks as a bundle
+// @Tags auth required
+// @Param redact query bool false "Redact notification secrets"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ConfigBundle]
+// @Router /setting/export [get]
+func exportConfig(c *gin.Context) (*model.ConfigBundle, error) {
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	bundle, err := singleton.ExportConfigBundle(user, c.Query("redact") == "true")
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return bundle, nil
+}
+
+// Import dashboard configuration
+// @Summary Import dashboard configuration
+// @Security BearerAuth
+// @Schemes
+// @Description Import a configuration bundle, objects with the same name (servers by UUID) are updated
+// @Tags auth required
+// @Accept json
+// @Param dry_run query bool false "Only report the changes"
+// @Param body body model.ConfigBundle true "ConfigBundle"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.ConfigImportResult]
+// @Router /setting/import [post]
+func importConfig(c *gin.Context) (*model.ConfigImportResult, error) {
+	var bundle model.ConfigBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		return nil, err
+	}
+
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	// 设置权限之外还需要配置包中每类对象的管理权限，否则可借助导入创建计划任务等对象
+	if !user.Can(bundle.RequiredPermissions()) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	dryRun := c.Query("dry_run") == "true"
+	result, err := singleton.ImportConfigBundle(c.Request.Context(), &bundle, user, dryRun)
+	if err != nil {
+		return nil, singleton.Localizer.ErrorT("invalid config bundle: %v", err)
+	}
+	if !dryRun {
+		recordAuditLog(c, model.AuditActionSettingImport, "setting", nil, result)
+	}
+	return result, nil
+}
+
+// Get notification mute
+// @Summary Get notification mute
+// @Security BearerAuth
+// @Schemes
+// @Description Get the state of the global notification mute and who enabled it
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.NotificationMuteResponse]
+// @Router /settings/notifications/mute [get]
+func getNotificationMute(c *gin.Context) (*model.NotificationMuteResponse, error) {
+	m := singleton.GetNotificationMute()
+	return &model.NotificationMuteResponse{
+		NotificationMute: m,
+		Active:           m.Active(time.Now()),
+	}, nil
+}
+
+// Set notification mute
+// @Summary Set notification mute
+// @Security BearerAuth
+// @Schemes
+// @Description Suppress delivery of all notifications until disabled or the duration elapses, alerts are still evaluated
+// @Tags admin required
+// @Accept json
+// @Param body body model.NotificationMuteForm true "NotificationMuteForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.NotificationMuteResponse]
+// @Router /settings/notifications/mute [post]
+func setNotificationMute(c *gin.Context) (*model.NotificationMuteResponse, error) {
+	var mf model.NotificationMuteForm
+	if err := c.ShouldBindJSON(&mf); err != nil {
+		return nil, err
+	}
+
+	before := singleton.GetNotificationMute()
+	var m model.NotificationMute
+	action := model.AuditActionNotificationUnmute
+	if mf.Enabled {
+		now := time.Now()
+		user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+		m = model.NotificationMute{
+			Enabled:   true,
+			Reason:    mf.Reason,
+			UserID:    user.ID,
+			Username:  user.Username,
+			StartedAt: now.Unix(),
+		}
+		if mf.Duration > 0 {
+			m.Until = now.Add(time.Duration(mf.Duration) * time.Second).Unix()
+		}
+		action = model.AuditActionNotificationMute
+	}
+	if err := singleton.SetNotificationMute(m); err != nil {
+		return nil, err
+	}
+
+	recordAuditLog(c, action, "setting", before, m)
+	return getNotificationMute(c)
+}
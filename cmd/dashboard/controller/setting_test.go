@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func TestUpdateConfigIsAtomic(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+	before := singleton.Conf.TrustedProxies
+
+	// 可信代理合法但跨域策略非法，整个请求应被拒绝且不修改任何配置
+	form := settingAuditSummary(singleton.Conf)
+	form.SiteName = "changed"
+	form.TrustedProxies = "10.0.0.0/8"
+	form.CORS = &model.CORS{AllowedMethods: "GET,not a method"}
+	if code, resp := testRequest(t, token, http.MethodPatch, "/api/v1/setting", form); testAllowed(code, resp) {
+		t.Fatal("invalid cors policy should be rejected")
+	}
+	if singleton.Conf.TrustedProxies != before || singleton.Conf.SiteName == "changed" {
+		t.Fatalf("config partially updated: trusted proxies %q, site name %q", singleton.Conf.TrustedProxies, singleton.Conf.SiteName)
+	}
+
+	form.CORS = nil
+	if code, resp := testRequest(t, token, http.MethodPatch, "/api/v1/setting", form); !testAllowed(code, resp) {
+		t.Fatalf("valid update: got status %d, response %+v", code, resp)
+	}
+	if singleton.Conf.TrustedProxies != "10.0.0.0/8" || singleton.Conf.SiteName != "changed" {
+		t.Fatal("valid update not applied")
+	}
+}
+
+func TestSettingCORSRequiresSuperAdmin(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleMember, model.PermissionSetting)
+	before := singleton.Conf.CORS
+
+	// 未修改跨域策略时可以保存其他设置
+	form := settingAuditSummary(singleton.Conf)
+	if code, resp := testRequest(t, token, http.MethodPatch, "/api/v1/setting", form); !testAllowed(code, resp) {
+		t.Fatalf("update without cors change: got status %d, response %+v", code, resp)
+	}
+
+	form.CORS = &model.CORS{AllowedOrigins: "https://evil.example", AllowCredentials: true}
+	if code, resp := testRequest(t, token, http.MethodPatch, "/api/v1/setting", form); testAllowed(code, resp) {
+		t.Fatal("member with setting permission should not change cors policy")
+	}
+	if singleton.Conf.CORS != before {
+		t.Fatalf("cors policy changed to %+v", singleton.Conf.CORS)
+	}
+}
+
+func TestConcurrentConfigWrites(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+	t.Cleanup(func() { singleton.SetNotificationMute(model.NotificationMute{}) })
+
+	// 修改设置与开启静音并发进行时，设置的副本不能覆盖刚写入的静音状态
+	form := settingAuditSummary(singleton.Conf)
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if code, resp := testRequest(t, token, http.MethodPatch, "/api/v1/setting", form); !testAllowed(code, resp) {
+					t.Errorf("update setting: got status %d, response %+v", code, resp)
+					return
+				}
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(done)
+
+	for i := range 100 {
+		reason := fmt.Sprintf("mute %d", i)
+		if err := singleton.SetNotificationMute(model.NotificationMute{Enabled: true, Reason: reason}); err != nil {
+			t.Fatal(err)
+		}
+		// 静音写入后的设置修改同样不能覆盖静音状态
+		code, resp := testRequest(t, token, http.MethodPatch, "/api/v1/setting", form)
+		if !testAllowed(code, resp) {
+			t.Fatalf("update setting: got status %d, response %+v", code, resp)
+		}
+		if m := singleton.GetNotificationMute(); m.Reason != reason {
+			t.Fatalf("mute reverted by a concurrent setting update: got reason %q, want %q", m.Reason, reason)
+		}
+	}
+}
+
+func TestImportConfigRequiresResourcePermissions(t *testing.T) {
+	_, settingOnly := testCreateUser(t, model.RoleMember, model.PermissionSetting)
+	_, withCron := testCreateUser(t, model.RoleMember, model.PermissionSetting|model.PermissionCron)
+
+	bundle := model.ConfigBundle{
+		Version: model.ConfigBundleVersion,
+		Crons:   []*model.Cron{{Common: model.Common{ID: 1}, Name: "imported", Scheduler: "0 0 0 1 1 *", Command: "id"}},
+	}
+	if code, resp := testRequest(t, settingOnly, http.MethodPost, "/api/v1/setting/import?dry_run=true", bundle); testAllowed(code, resp) {
+		t.Fatal("member without cron permission should not import crons")
+	}
+	if code, resp := testRequest(t, withCron, http.MethodPost, "/api/v1/setting/import?dry_run=true", bundle); !testAllowed(code, resp) {
+		t.Fatalf("member with cron permission: got status %d, response %+v", code, resp)
+	}
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func TestFlushPingHistory(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+	id := testCreateService(t, token, model.ServiceForm{
+		Name: "flush", Type: model.TaskTypeTCPPing, Target: "203.0.113.1:80", Duration: 3600,
+	})
+
+	// 尚未凑满 AvgPingCount 次的延迟在退出时写入
+	singleton.ServiceSentinelShared.Dispatch(singleton.ReportData{
+		Data: &pb.TaskResult{Id: id, Type: model.TaskTypeTCPPing, Delay: 7, Successful: true},
+	})
+	var h model.ServiceHistory
+	for deadline := time.Now().Add(5 * time.Second); h.ID == 0; time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("pending latency not flushed")
+		}
+		singleton.ServiceSentinelShared.FlushPingHistory()
+		if err := singleton.DB.Where("service_id = ? AND server_id = 0 AND up = 0 AND down = 0", id).Limit(1).Find(&h).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if h.AvgDelay != 7 {
+		t.Fatalf("got delay %v, want 7", h.AvgDelay)
+	}
+
+	// 已写入的数据不重复写入
+	singleton.ServiceSentinelShared.FlushPingHistory()
+	var count int64
+	if err := singleton.DB.Model(&model.ServiceHistory{}).Where("service_id = ?", id).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("got %d history rows, want 1", count)
+	}
+}
+
+func TestCloseWebSockets(t *testing.T) {
+	InitUpgrader()
+	srv := httptest.NewServer(testDashboard(t))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/ws/server", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 退出时向客户端发送关闭帧
+	if n := CloseWebSockets(); n != 1 {
+		t.Fatalf("closed %d connections, want 1", n)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("got %v, want a going away close frame", err)
+	}
+}
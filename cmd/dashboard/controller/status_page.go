@@ -0,0 +1,326 @@
+package controller
+
+import (
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body{font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;max-width:760px;margin:2rem auto;padding:0 1rem;color:#222}
+.banner{padding:1rem;border-radius:6px;color:#fff;font-weight:600}
+.operational,.up{background:#3ba55c}.degraded{background:#e3a008}.outage,.down{background:#e05d44}.no_data{background:#9f9f9f}
+ul{list-style:none;padding:0}li{display:flex;justify-content:space-between;padding:.6rem 0;border-bottom:1px solid #eee}
+.tag{color:#fff;border-radius:4px;padding:0 .5rem;font-size:.85rem}.muted{color:#888;font-size:.85rem}
+</style></head><body>
+<h1>{{.Title}}</h1>{{with .Description}}<p>{{.}}</p>{{end}}
+<div class="banner {{.Status}}">{{.Status}}</div>
+{{with .Servers}}<h2>Servers</h2><ul>{{range .}}<li><span>{{.Name}}{{if .InMaintenance}} <span class="muted">maintenance</span>{{end}}</span><span class="tag {{.Status}}">{{.Status}}</span></li>{{end}}</ul>{{end}}
+{{with .Services}}<h2>Services</h2><ul>{{range .}}<li><span>{{.Name}} <span class="muted">{{printf "%.2f" .Uptime}}%</span></span><span class="tag {{.Status}}">{{.Status}}</span></li>{{end}}</ul>{{end}}
+{{with .Incidents}}<h2>Incidents</h2>{{range .}}<h3>{{.Title}} <span class="muted">{{.Status}}</span></h3>
+{{range .Updates}}<p><b>{{.Status}}</b> {{.Message}} <span class="muted">{{.CreatedAt.Format "2006-01-02 15:04 MST"}}</span></p>{{end}}{{end}}{{end}}
+<p class="muted">{{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+</body></html>`))
+
+// Public status page
+// @Summary Public status page
+// @Schemes
+// @Description Current states of the servers and services selected for the status page and recent incidents, no login required
+// @Tags common
+// @Param format query string false "html to render the page instead of returning json"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.StatusPageResponse]
+// @Router /status [get]
+func serveStatusPage(c *gin.Context) {
+	if !singleton.GetStatusPage().Enabled {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=10")
+	if c.Query("format") != "html" {
+		commonHandler(getStatusPageData)(c)
+		return
+	}
+
+	data, err := singleton.StatusPageData()
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	statusPageTemplate.Execute(c.Writer, data)
+}
+
+func getStatusPageData(c *gin.Context) (*model.StatusPageResponse, error) {
+	data, err := singleton.StatusPageData()
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return data, nil
+}
+
+// Get status page
+// @Summary Get status page
+// @Security BearerAuth
+// @Schemes
+// @Description Get the status page config
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.StatusPage]
+// @Router /status-page [get]
+func getStatusPage(c *gin.Context) (model.StatusPage, error) {
+	return singleton.GetStatusPage(), nil
+}
+
+// Edit status page
+// @Summary Edit status page
+// @Security BearerAuth
+// @Schemes
+// @Description Edit the status page config, only the selected servers and services are shown on the public status page
+// @Tags auth required
+// @Accept json
+// @Param body body model.StatusPageForm true "StatusPageForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /status-page [patch]
+func updateStatusPage(c *gin.Context) (any, error) {
+	var sf model.StatusPageForm
+	if err := c.ShouldBindJSON(&sf); err != nil {
+		return nil, err
+	}
+
+	// 只能公开有权限管理的服务器与服务，已经公开的保持不变
+	before := singleton.GetStatusPage()
+	singleton.ServerLock.RLock()
+	for _, id := range sf.Servers {
+		server, ok := singleton.ServerList[id]
+		if !ok {
+			singleton.ServerLock.RUnlock()
+			return nil, singleton.Localizer.ErrorT("server id %d does not exist", id)
+		}
+		if !server.HasPermission(c) && !slices.Contains(before.Servers, id) {
+			singleton.ServerLock.RUnlock()
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+	singleton.ServerLock.RUnlock()
+	singleton.ServiceSentinelShared.ServicesLock.RLock()
+	for _, id := range sf.Services {
+		service, ok := singleton.ServiceSentinelShared.Services[id]
+		if !ok {
+			singleton.ServiceSentinelShared.ServicesLock.RUnlock()
+			return nil, singleton.Localizer.ErrorT("service id %d does not exist", id)
+		}
+		if !service.HasPermission(c) && !slices.Contains(before.Services, id) {
+			singleton.ServiceSentinelShared.ServicesLock.RUnlock()
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+	}
+	singleton.ServiceSentinelShared.ServicesLock.RUnlock()
+
+	page := model.StatusPage{
+		Enabled:     sf.Enabled,
+		Title:       sf.Title,
+		Description: sf.Description,
+		Servers:     sf.Servers,
+		Services:    sf.Services,
+	}
+	if err := singleton.SetStatusPage(page); err != nil {
+		return nil, err
+	}
+	recordAuditLog(c, model.AuditActionStatusPageUpdate, "status_page", before, page)
+	return nil, nil
+}
+
+// List status incidents
+// @Summary List status incidents
+// @Security BearerAuth
+// @Schemes
+// @Description List incidents posted to the status page with their updates, newest first
+// @Tags auth required
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Produce json
+// @Success 200 {object} model.PaginatedResponse[[]model.StatusIncident, model.StatusIncident]
+// @Router /status-page/incident [get]
+func listStatusIncident(c *gin.Context) (*model.Value[[]*model.StatusIncident], error) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	var total int64
+	if err := singleton.DB.Model(&model.StatusIncident{}).Count(&total).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	incidents, err := singleton.LoadStatusIncidents(false, limit, offset)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.Value[[]*model.StatusIncident]{
+		Value: incidents,
+		Pagination: model.Pagination{
+			Offset: offset,
+			Limit:  limit,
+			Total:  total,
+		},
+	}, nil
+}
+
+// Create status incident
+// @Summary Create status incident
+// @Security BearerAuth
+// @Schemes
+// @Description Post an incident to the status page, the message is recorded as its first update
+// @Tags auth required
+// @Accept json
+// @Param body body model.StatusIncidentForm true "StatusIncidentForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /status-page/incident [post]
+func createStatusIncident(c *gin.Context) (uint64, error) {
+	var f model.StatusIncidentForm
+	if err := c.ShouldBindJSON(&f); err != nil {
+		return 0, err
+	}
+	if f.Title == "" {
+		return 0, singleton.Localizer.ErrorT("incident title can't be empty")
+	}
+	if f.Status == "" {
+		f.Status = model.IncidentStatusInvestigating
+	}
+
+	var incident model.StatusIncident
+	incident.UserID = getUid(c)
+	incident.Title = f.Title
+	err := singleton.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&incident).Error; err != nil {
+			return newGormError("%v", err)
+		}
+		return addStatusIncidentUpdate(c, tx, &incident, f.Status, f.Message)
+	})
+	if err != nil {
+		return 0, err
+	}
+	singleton.InvalidateStatusPage()
+	return incident.ID, nil
+}
+
+// Update status incident
+// @Summary Update status incident
+// @Security BearerAuth
+// @Schemes
+// @Description Post a progress update to an incident, setting status to resolved resolves it
+// @Tags auth required
+// @Accept json
+// @Param id path uint true "Incident ID"
+// @Param body body model.StatusIncidentUpdateForm true "StatusIncidentUpdateForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /status-page/incident/{id}/update [post]
+func createStatusIncidentUpdate(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	var f model.StatusIncidentUpdateForm
+	if err := c.ShouldBindJSON(&f); err != nil {
+		return nil, err
+	}
+
+	var incident model.StatusIncident
+	if err := singleton.DB.First(&incident, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("incident id %d does not exist", id)
+	}
+	if f.Status == "" {
+		f.Status = incident.Status
+	}
+	err = singleton.DB.Transaction(func(tx *gorm.DB) error {
+		return addStatusIncidentUpdate(c, tx, &incident, f.Status, f.Message)
+	})
+	if err != nil {
+		return nil, err
+	}
+	singleton.InvalidateStatusPage()
+	return nil, nil
+}
+
+// Batch delete status incidents
+// @Summary Batch delete status incidents
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete status incidents and their updates
+// @Tags auth required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/status-page/incident [post]
+func batchDeleteStatusIncident(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	err := singleton.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&model.StatusIncidentUpdate{}, "incident_id in (?)", ids).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.StatusIncident{}, "id in (?)", ids).Error
+	})
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	singleton.InvalidateStatusPage()
+	return nil, nil
+}
+
+// addStatusIncidentUpdate 记录事件的一条进展，并同步事件的状态与解决时间
+func addStatusIncidentUpdate(c *gin.Context, tx *gorm.DB, incident *model.StatusIncident, status, message string) error {
+	if !model.ValidIncidentStatus(status) {
+		return singleton.Localizer.ErrorT("invalid incident status: %s", status)
+	}
+	if message == "" {
+		return singleton.Localizer.ErrorT("incident message can't be empty")
+	}
+
+	if err := tx.Create(&model.StatusIncidentUpdate{
+		IncidentID: incident.ID,
+		UserID:     getUid(c),
+		Status:     status,
+		Message:    message,
+	}).Error; err != nil {
+		return newGormError("%v", err)
+	}
+
+	incident.Status = status
+	switch {
+	case status == model.IncidentStatusResolved && incident.ResolvedAt == nil:
+		now := time.Now()
+		incident.ResolvedAt = &now
+	case status != model.IncidentStatusResolved:
+		incident.ResolvedAt = nil
+	}
+	if err := tx.Save(incident).Error; err != nil {
+		return newGormError("%v", err)
+	}
+	return nil
+}
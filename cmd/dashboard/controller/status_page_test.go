@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+func TestStatusPageTemplate(t *testing.T) {
+	data := &model.StatusPageResponse{
+		Title:    "Example <status>",
+		Status:   model.StatusPageDegraded,
+		Servers:  []*model.StatusPageServer{{ID: 1, Name: "web", Status: model.ComponentUp}},
+		Services: []*model.StatusPageService{{ID: 2, Name: "api", Status: model.ComponentDegraded, Uptime: 99.5}},
+		Incidents: []*model.StatusIncident{{
+			Title:   "Slow responses",
+			Status:  model.IncidentStatusInvestigating,
+			Updates: []*model.StatusIncidentUpdate{{Status: model.IncidentStatusInvestigating, Message: "we're investigating", CreatedAt: time.Now()}},
+		}},
+		GeneratedAt: time.Now(),
+	}
+
+	var b strings.Builder
+	if err := statusPageTemplate.Execute(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"Example &lt;status&gt;", "99.50%", "we&#39;re investigating", `class="tag degraded"`} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("rendered page does not contain %q", s)
+		}
+	}
+}
+
+func TestStatusPageRequiresItemPermission(t *testing.T) {
+	member, token := testCreateUser(t, model.RoleMember, model.PermissionSetting)
+	other, _ := testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+	own, _ := testCreateOnlineServer(t, member.ID)
+	foreign, _ := testCreateOnlineServer(t, other.ID)
+
+	form := model.StatusPageForm{Enabled: true, Title: "status", Servers: []uint64{own.ID, foreign.ID}}
+	if code, resp := testRequest(t, token, http.MethodPatch, "/api/v1/status-page", form); testAllowed(code, resp) {
+		t.Fatal("member should not publish another user's server")
+	}
+	form.Servers = []uint64{own.ID}
+	if code, resp := testRequest(t, token, http.MethodPatch, "/api/v1/status-page", form); !testAllowed(code, resp) {
+		t.Fatalf("publish own server: got status %d, response %+v", code, resp)
+	}
+	t.Cleanup(func() {
+		testRequest(t, token, http.MethodPatch, "/api/v1/status-page", model.StatusPageForm{})
+	})
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// tenantScope 根据登录用户注入租户范围，HasPermission 据此限制租户管理员只能访问本租户的数据
+func tenantScope(c *gin.Context) {
+	if auth, ok := c.Get(model.CtxKeyAuthorizedUser); ok {
+		c.Set(model.CtxKeyTenantScope, singleton.TenantScopeOf(auth.(*model.User)))
+	}
+	c.Next()
+}
+
+func getTenantScope(c *gin.Context) *model.TenantScope {
+	scope, _ := c.Get(model.CtxKeyTenantScope)
+	s, _ := scope.(*model.TenantScope)
+	return s
+}
+
+// List tenants
+// @Summary List tenants
+// @Security BearerAuth
+// @Schemes
+// @Description List tenants
+// @Tags admin required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.Tenant]
+// @Router /tenant [get]
+func listTenant(c *gin.Context) ([]model.Tenant, error) {
+	var tenants []model.Tenant
+	if err := singleton.DB.Find(&tenants).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return tenants, nil
+}
+
+// Create tenant
+// @Summary Create tenant
+// @Security BearerAuth
+// @Schemes
+// @Description Create tenant
+// @Tags admin required
+// @Accept json
+// @param request body model.TenantForm true "Tenant Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /tenant [post]
+func createTenant(c *gin.Context) (uint64, error) {
+	var tf model.TenantForm
+	if err := c.ShouldBindJSON(&tf); err != nil {
+		return 0, err
+	}
+	if tf.Name == "" {
+		return 0, singleton.Localizer.ErrorT("tenant name can't be empty")
+	}
+
+	t := model.Tenant{Name: tf.Name}
+	t.UserID = getUid(c)
+	if err := singleton.DB.Create(&t).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+
+	recordAuditLog(c, model.AuditActionTenantCreate, auditTarget("tenant", t.ID), nil, &t)
+	return t.ID, nil
+}
+
+// Edit tenant
+// @Summary Edit tenant
+// @Security BearerAuth
+// @Schemes
+// @Description Rename tenant
+// @Tags admin required
+// @Accept json
+// @param id path uint true "Tenant ID"
+// @param request body model.TenantForm true "Tenant Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /tenant/{id} [patch]
+func updateTenant(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var tf model.TenantForm
+	if err := c.ShouldBindJSON(&tf); err != nil {
+		return nil, err
+	}
+	if tf.Name == "" {
+		return nil, singleton.Localizer.ErrorT("tenant name can't be empty")
+	}
+
+	var t model.Tenant
+	if err := singleton.DB.First(&t, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("tenant id %d does not exist", id)
+	}
+
+	before := t
+	t.Name = tf.Name
+	if err := singleton.DB.Save(&t).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	recordAuditLog(c, model.AuditActionTenantUpdate, auditTarget("tenant", t.ID), &before, &t)
+	return nil, nil
+}
+
+// Batch delete tenants
+// @Summary Batch delete tenants
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete tenants, tenants that still have users can't be deleted
+// @Tags admin required
+// @Accept json
+// @param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/tenant [post]
+func batchDeleteTenant(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := singleton.DB.Model(&model.User{}).Where("tenant_id IN (?)", ids).Count(&count).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if count > 0 {
+		return nil, singleton.Localizer.ErrorT("tenant still has %d users", count)
+	}
+
+	var tenants []model.Tenant
+	if err := singleton.DB.Find(&tenants, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if err := singleton.DB.Unscoped().Delete(&model.Tenant{}, "id in (?)", ids).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	for i := range tenants {
+		recordAuditLog(c, model.AuditActionTenantDelete, auditTarget("tenant", tenants[i].ID), &tenants[i], nil)
+	}
+	return nil, nil
+}
+
+// Set user tenant
+// @Summary Set user tenant
+// @Security BearerAuth
+// @Schemes
+// @Description Move a user into a tenant, optionally as its admin. The data owned by the user moves with it.
+// @Tags admin required
+// @Accept json
+// @param id path uint true "User ID"
+// @param request body model.UserTenantForm true "Tenant Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /user/{id}/tenant [post]
+func setUserTenant(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var tf model.UserTenantForm
+	if err := c.ShouldBindJSON(&tf); err != nil {
+		return nil, err
+	}
+	if tf.TenantID == 0 && tf.Admin {
+		return nil, singleton.Localizer.ErrorT("only tenant members can be made admin")
+	}
+	if !singleton.TenantExists(tf.TenantID) {
+		return nil, singleton.Localizer.ErrorT("tenant id %d does not exist", tf.TenantID)
+	}
+	if id == getUid(c) {
+		return nil, singleton.Localizer.ErrorT("can't change the tenant of yourself")
+	}
+
+	var u model.User
+	if err := singleton.DB.Omit("password").First(&u, id).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("user id %d does not exist", id)
+	}
+	if u.IsSuperAdmin() {
+		return nil, singleton.Localizer.ErrorT("can't move a super admin into a tenant")
+	}
+
+	before := userAuditSummary(&u)
+	u.TenantID = tf.TenantID
+	u.Role = model.RoleMember
+	if tf.Admin {
+		u.Role = model.RoleAdmin
+	}
+	if err := singleton.DB.Model(&u).Updates(map[string]any{"tenant_id": u.TenantID, "role": u.Role}).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	singleton.OnUserUpdate(&u)
+
+	recordAuditLog(c, model.AuditActionUserTenant, auditTarget("user", u.ID), before, userAuditSummary(&u))
+	return nil, nil
+}
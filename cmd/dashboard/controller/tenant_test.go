@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+// testMoveToTenant 将用户移入租户
+func testMoveToTenant(t *testing.T, u *model.User, tenantID uint64) {
+	t.Helper()
+	u.TenantID = tenantID
+	if err := singleton.DB.Model(u).Update("tenant_id", tenantID).Error; err != nil {
+		t.Fatal(err)
+	}
+	singleton.OnUserUpdate(u)
+}
+
+func TestGroupListTenantIsolation(t *testing.T) {
+	tenantAdmin, tenantAdminToken := testCreateUser(t, model.RoleAdmin, model.TenantPermissions)
+	tenantMember, _ := testCreateUser(t, model.RoleMember, model.PermissionServerWrite|model.PermissionNotification)
+	outsider, outsiderToken := testCreateUser(t, model.RoleMember, model.PermissionServerWrite|model.PermissionNotification)
+
+	tenant := model.Tenant{Name: "isolated"}
+	if err := singleton.DB.Create(&tenant).Error; err != nil {
+		t.Fatal(err)
+	}
+	testMoveToTenant(t, tenantAdmin, tenant.ID)
+	testMoveToTenant(t, tenantMember, tenant.ID)
+
+	groups := make(map[uint64][2]uint64) // [UserID] -> [ServerGroupID, NotificationGroupID]
+	for _, u := range []*model.User{tenantMember, outsider} {
+		sg := model.ServerGroup{Name: u.Username}
+		sg.UserID = u.ID
+		ng := model.NotificationGroup{Name: u.Username}
+		ng.UserID = u.ID
+		if err := singleton.DB.Create(&sg).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := singleton.DB.Create(&ng).Error; err != nil {
+			t.Fatal(err)
+		}
+		groups[u.ID] = [2]uint64{sg.ID, ng.ID}
+	}
+
+	list := func(token string) (serverGroups, notificationGroups []uint64) {
+		t.Helper()
+		code, resp := testRequest(t, token, http.MethodGet, "/api/v1/server-group", nil)
+		if !testAllowed(code, resp) {
+			t.Fatalf("list server groups: got status %d, response %+v", code, resp)
+		}
+		// 列表为空时不返回 data
+		var sg []model.ServerGroupResponseItem
+		if len(resp.Data) > 0 {
+			if err := json.Unmarshal(resp.Data, &sg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, item := range sg {
+			serverGroups = append(serverGroups, item.Group.ID)
+		}
+		if token == "" {
+			return serverGroups, nil
+		}
+
+		code, resp = testRequest(t, token, http.MethodGet, "/api/v1/notification-group", nil)
+		if !testAllowed(code, resp) {
+			t.Fatalf("list notification groups: got status %d, response %+v", code, resp)
+		}
+		var ng []model.NotificationGroupResponseItem
+		if err := json.Unmarshal(resp.Data, &ng); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range ng {
+			notificationGroups = append(notificationGroups, item.Group.ID)
+		}
+		return serverGroups, notificationGroups
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		owner uint64
+		allow bool
+	}{
+		{"tenant admin lists tenant member groups", tenantAdminToken, tenantMember.ID, true},
+		{"tenant admin lists outsider groups", tenantAdminToken, outsider.ID, false},
+		{"outsider lists own groups", outsiderToken, outsider.ID, true},
+		{"outsider lists tenant member groups", outsiderToken, tenantMember.ID, false},
+	}
+	for _, tc := range cases {
+		sg, ng := list(tc.token)
+		if slices.Contains(sg, groups[tc.owner][0]) != tc.allow {
+			t.Errorf("%s: server groups %v", tc.name, sg)
+		}
+		if slices.Contains(ng, groups[tc.owner][1]) != tc.allow {
+			t.Errorf("%s: notification groups %v", tc.name, ng)
+		}
+	}
+
+	// 游客只能看到含有可见服务器的分组
+	if sg, _ := list(""); slices.Contains(sg, groups[outsider.ID][0]) {
+		t.Errorf("guest lists empty server group: %v", sg)
+	}
+}
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -41,15 +43,37 @@ func createTerminal(c *gin.Context) (*model.CreateTerminalResponse, error) {
 		return nil, singleton.Localizer.ErrorT("permission denied")
 	}
 
+	if err := checkSecrets(c, server.ID, createTerminalReq.SecretIDs); err != nil {
+		return nil, err
+	}
+	env, err := singleton.ResolveSecrets(server.ID, createTerminalReq.SecretIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	streamId, err := uuid.GenerateUUID()
 	if err != nil {
 		return nil, err
 	}
 
+	session := model.TerminalSession{
+		UserID:     getUid(c),
+		SessionID:  streamId,
+		ServerID:   server.ID,
+		ServerName: server.Name,
+		IP:         c.GetString(model.CtxKeyRealIPStr),
+		Recorded:   !createTerminalReq.NoRecord,
+		StartedAt:  time.Now(),
+	}
+	if err := singleton.DB.Create(&session).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
 	rpc.NezhaHandlerSingleton.CreateStream(streamId)
 
 	terminalData, _ := utils.Json.Marshal(&model.TerminalTask{
 		StreamID: streamId,
+		Env:      env,
 	})
 	if err := server.TaskStream.Send(&proto.Task{
 		Type: model.TaskTypeTerminalGRPC,
@@ -57,6 +81,7 @@ func createTerminal(c *gin.Context) (*model.CreateTerminalResponse, error) {
 	}); err != nil {
 		return nil, err
 	}
+	requestLogger(c).Debug("terminal task sent", "stream_id", streamId, "server_id", server.ID)
 
 	return &model.CreateTerminalResponse{
 		SessionID:  streamId,
@@ -77,13 +102,21 @@ func terminalStream(c *gin.Context) (any, error) {
 	if _, err := rpc.NezhaHandlerSingleton.GetStream(streamId); err != nil {
 		return nil, err
 	}
+
+	var session model.TerminalSession
+	if err := singleton.DB.Where("session_id = ?", streamId).First(&session).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("terminal session %s does not exist", streamId)
+	}
+	if u := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User); session.UserID != u.ID && !singleton.UserAdministers(u.ID, session.UserID) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
 	defer rpc.NezhaHandlerSingleton.CloseStream(streamId)
 
-	wsConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	wsConn, err := upgradeWebSocket(c)
 	if err != nil {
 		return nil, newWsError("%v", err)
 	}
-	defer wsConn.Close()
+	defer closeWebSocket(wsConn)
 	conn := websocketx.NewConn(wsConn)
 
 	go func() {
@@ -96,7 +129,7 @@ func terminalStream(c *gin.Context) (any, error) {
 		}
 	}()
 
-	if err = rpc.NezhaHandlerSingleton.UserConnected(streamId, conn); err != nil {
+	if err = rpc.NezhaHandlerSingleton.UserConnected(streamId, singleton.RecordTerminalSession(&session, conn)); err != nil {
 		return nil, newWsError("%v", err)
 	}
 
@@ -106,3 +139,104 @@ func terminalStream(c *gin.Context) (any, error) {
 
 	return nil, newWsError("")
 }
+
+// List terminal sessions
+// @Summary List terminal sessions
+// @Security BearerAuth
+// @Schemes
+// @Description List who opened web terminals on which servers, newest first
+// @Tags admin required
+// @Param server_id query uint false "Server ID"
+// @Param user_id query uint false "User ID"
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Produce json
+// @Success 200 {object} model.PaginatedResponse[[]model.TerminalSession, model.TerminalSession]
+// @Router /terminal/session [get]
+func listTerminalSession(c *gin.Context) (*model.Value[[]*model.TerminalSession], error) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := singleton.DB.Model(&model.TerminalSession{})
+	for _, key := range []string{"server_id", "user_id"} {
+		if v := c.Query(key); v != "" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			query = query.Where(key+" = ?", id)
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	var sessions []*model.TerminalSession
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&sessions).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.Value[[]*model.TerminalSession]{
+		Value: sessions,
+		Pagination: model.Pagination{
+			Offset: offset,
+			Limit:  limit,
+			Total:  total,
+		},
+	}, nil
+}
+
+// Get terminal session recording
+// @Summary Get terminal session recording
+// @Security BearerAuth
+// @Schemes
+// @Description Stream the recording of a terminal session in asciicast v2 format
+// @Tags admin required
+// @Param id path string true "Session UUID"
+// @Produce application/x-asciicast
+// @Success 200 {string} string "asciicast v2"
+// @Router /terminal/session/{id}/recording [get]
+func getTerminalRecording(c *gin.Context) (any, error) {
+	var session model.TerminalSession
+	if err := singleton.DB.Where("session_id = ?", c.Param("id")).First(&session).Error; err != nil {
+		return nil, singleton.Localizer.ErrorT("terminal session %s does not exist", c.Param("id"))
+	}
+	if !session.Recorded {
+		return nil, singleton.Localizer.ErrorT("terminal session %s was not recorded", session.SessionID)
+	}
+
+	rows, err := singleton.DB.Model(&model.TerminalRecordingChunk{}).Where("session_id = ?", session.ID).Order("id").Rows()
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	defer rows.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.cast", session.SessionID))
+	c.Header("Content-Type", "application/x-asciicast")
+	c.Status(200)
+	c.Writer.Write(model.CastHeader(&session))
+
+	// 逐段写入并刷新，播放器可以边下载边播放
+	for rows.Next() {
+		var chunk model.TerminalRecordingChunk
+		if err := singleton.DB.ScanRows(rows, &chunk); err != nil {
+			requestLogger(c).Error("failed to stream terminal recording", "session_id", session.SessionID, "error", err)
+			break
+		}
+		if _, err := c.Writer.WriteString(chunk.Data); err != nil {
+			break
+		}
+		c.Writer.Flush()
+	}
+	c.Writer.Flush()
+	return nil, nil
+}
@@ -1,16 +1,27 @@
 package controller
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
 	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
 	"github.com/nezhahq/nezha/service/singleton"
 )
 
+const twoFactorRecoveryCodeCount = 10
+
 // Get profile
 // @Summary Get profile
 // @Security BearerAuth
@@ -25,9 +36,13 @@ func getProfile(c *gin.Context) (*model.Profile, error) {
 	if !ok {
 		return nil, singleton.Localizer.ErrorT("unauthorized")
 	}
+	user := auth.(*model.User)
 	return &model.Profile{
-		User:    *auth.(*model.User),
-		LoginIP: c.GetString(model.CtxKeyRealIPStr),
+		User:           *user,
+		LoginIP:        c.GetString(model.CtxKeyRealIPStr),
+		ImpersonatedBy: user.Impersonation,
+		Modules:        user.VisibleModules(&singleton.Conf.RoleModules),
+		Landing:        singleton.Conf.RoleModules.Of(user.Role).Landing,
 	}, nil
 }
 
@@ -58,11 +73,25 @@ func updateProfile(c *gin.Context) (any, error) {
 		return nil, singleton.Localizer.ErrorT("incorrect password")
 	}
 
+	if err := singleton.ValidatePassword(pf.NewPassword); err != nil {
+		return nil, err
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(pf.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
 
+	if pf.DisableTwoFactor && user.TwoFactor {
+		if !user.VerifyTwoFactor(pf.TwoFactorCode) {
+			return nil, singleton.Localizer.ErrorT("invalid two-factor authentication code")
+		}
+		user.TwoFactor = false
+		user.TwoFactorSecret = ""
+		user.TwoFactorLastStep = 0
+		user.TwoFactorRecoveryCodes = nil
+	}
+
 	user.Username = pf.NewUsername
 	user.Password = string(hash)
 	if err := singleton.DB.Save(&user).Error; err != nil {
@@ -72,21 +101,269 @@ func updateProfile(c *gin.Context) (any, error) {
 	return nil, nil
 }
 
+// Get login history
+// @Summary Get login history
+// @Security BearerAuth
+// @Schemes
+// @Description Get recent logins of current user
+// @Tags auth required
+// @Param limit query uint false "Number of records, default 10"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.LoginHistory]
+// @Router /profile/login-history [get]
+func getLoginHistory(c *gin.Context) ([]model.LoginHistory, error) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	limit = min(limit, model.MaxLoginHistory)
+
+	var history []model.LoginHistory
+	if err := singleton.DB.Where("user_id = ?", getUid(c)).Order("id desc").Limit(limit).Find(&history).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return history, nil
+}
+
+// Get UI preferences
+// @Summary Get UI preferences
+// @Security BearerAuth
+// @Schemes
+// @Description Get UI preferences of current user, an empty object is returned if not set
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[object]
+// @Router /profile/preferences [get]
+func getPreferences(c *gin.Context) (json.RawMessage, error) {
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if len(user.Settings) == 0 {
+		return json.RawMessage("{}"), nil
+	}
+	return user.Settings, nil
+}
+
+// Update UI preferences
+// @Summary Update UI preferences
+// @Security BearerAuth
+// @Schemes
+// @Description Replace UI preferences of current user, the body must be a JSON object no larger than 16 KiB
+// @Tags auth required
+// @Accept json
+// @param request body object true "Preferences"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /profile/preferences [put]
+func updatePreferences(c *gin.Context) (any, error) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, model.MaxUserSettingsSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > model.MaxUserSettingsSize {
+		return nil, singleton.Localizer.ErrorT("preferences exceed the size limit of %d bytes", model.MaxUserSettingsSize)
+	}
+
+	var settings map[string]any
+	if err := json.Unmarshal(data, &settings); err != nil || settings == nil {
+		return nil, singleton.Localizer.ErrorT("preferences must be a JSON object")
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, err
+	}
+
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if err := singleton.DB.Model(user).Update("settings_raw", buf.String()).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return nil, nil
+}
+
+// Enroll two-factor authentication
+// @Summary Enroll two-factor authentication
+// @Security BearerAuth
+// @Schemes
+// @Description Generate a new TOTP secret, 2FA takes effect after verification
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.TwoFactorEnrollResponse]
+// @Router /profile/2fa [post]
+func enrollTwoFactor(c *gin.Context) (*model.TwoFactorEnrollResponse, error) {
+	user := *c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if user.TwoFactor {
+		return nil, singleton.Localizer.ErrorT("two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := singleton.DB.Model(&user).Update("two_factor_secret", secret).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.TwoFactorEnrollResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(singleton.Conf.SiteName, user.Username, secret),
+	}, nil
+}
+
+// Verify two-factor authentication
+// @Summary Verify two-factor authentication
+// @Security BearerAuth
+// @Schemes
+// @Description Confirm a TOTP code to enable 2FA, returns recovery codes which are only shown once
+// @Tags auth required
+// @Accept json
+// @param request body model.TwoFactorVerifyForm true "TOTP code"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]string]
+// @Router /profile/2fa/verify [post]
+func verifyTwoFactor(c *gin.Context) ([]string, error) {
+	var tf model.TwoFactorVerifyForm
+	if err := c.ShouldBindJSON(&tf); err != nil {
+		return nil, err
+	}
+
+	user := *c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if user.TwoFactor {
+		return nil, singleton.Localizer.ErrorT("two-factor authentication is already enabled")
+	}
+	if user.TwoFactorSecret == "" {
+		return nil, singleton.Localizer.ErrorT("two-factor authentication is not enrolled")
+	}
+
+	user.TwoFactorRecoveryCodes = nil
+	if !user.VerifyTwoFactor(tf.Code) {
+		return nil, singleton.Localizer.ErrorT("invalid two-factor authentication code")
+	}
+
+	codes := make([]string, 0, twoFactorRecoveryCodeCount)
+	hashes := make([]string, 0, twoFactorRecoveryCodeCount)
+	for range twoFactorRecoveryCodeCount {
+		code, err := utils.GenerateRandomString(10)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, string(hash))
+	}
+
+	user.TwoFactor = true
+	user.TwoFactorRecoveryCodes = hashes
+	if err := singleton.DB.Save(&user).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return codes, nil
+}
+
 // List user
 // @Summary List user
 // @Security BearerAuth
 // @Schemes
 // @Description List user
 // @Tags admin required
+// @Param role query uint false "Only users of the role, 0 for admin and 1 for member"
+// @Param username query string false "Only users whose username contains the keyword, case insensitive"
+// @Param order query string false "Order by id (default) or username" Enums(id, username)
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Param cursor query string false "Page cursor, pass an empty value for the first page and next_cursor afterwards, offset is ignored"
 // @Produce json
-// @Success 200 {object} model.CommonResponse[[]model.User]
+// @Success 200 {object} model.PaginatedResponse[[]model.User, model.User]
 // @Router /user [get]
-func listUser(c *gin.Context) ([]model.User, error) {
-	var users []model.User
-	if err := singleton.DB.Omit("password").Find(&users).Error; err != nil {
+func listUser(c *gin.Context) (*model.Value[[]model.User], error) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := singleton.DB.Model(&model.User{})
+	if scope := getTenantScope(c); scope != nil {
+		query = query.Where("id IN ?", slices.Collect(maps.Keys(scope.Users)))
+	}
+	if v := c.Query("role"); v != "" {
+		role, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("role = ?", role)
+	}
+	if v := strings.TrimSpace(c.Query("username")); v != "" {
+		// SQLite 的 LIKE 不区分大小写而 PostgreSQL 区分，统一转换为小写后匹配
+		query = query.Where(`LOWER(username) LIKE ? ESCAPE '\'`, likeContains(strings.ToLower(v)))
+	}
+
+	// 用户名唯一，按用户名排序同样稳定
+	column := "id"
+	switch order := c.Query("order"); order {
+	case "", "id":
+	case "username":
+		column = "username"
+	default:
+		return nil, singleton.Localizer.ErrorT("unknown order %s", order)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	cursor, useCursor, err := getCursor(c)
+	if err != nil {
 		return nil, err
 	}
-	return users, nil
+	if cursor != nil {
+		var after any = cursor.ID
+		if column == "id" {
+			if after, err = strconv.ParseUint(cursor.ID, 10, 64); err != nil {
+				return nil, singleton.Localizer.ErrorT("invalid cursor")
+			}
+		}
+		query = query.Where(column+" > ?", after)
+	}
+	// 游标分页多取一条以判断是否还有下一页
+	fetch := limit
+	if useCursor {
+		fetch++
+	} else {
+		query = query.Offset(offset)
+	}
+
+	// Agent 密钥可以冒充用户连接 Agent，只在用户本人的资料中返回
+	var users []model.User
+	if err := query.Omit("password", "agent_secret", "oidc_subject").Order(column).Limit(fetch).Find(&users).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	p := model.Pagination{Limit: limit, Total: total}
+	if !useCursor {
+		p.Offset = offset
+	} else if len(users) > limit {
+		users = users[:limit]
+		last := users[limit-1]
+		p.NextCursor = (&model.Cursor{ID: utils.IfOr(column == "id", strconv.FormatUint(last.ID, 10), last.Username)}).Encode()
+	}
+	for i := range users {
+		users[i].Permissions = users[i].EffectivePermissions()
+	}
+	return &model.Value[[]model.User]{Value: users, Pagination: p}, nil
+}
+
+// likeContains 返回匹配包含 s 的 LIKE 模式，s 中的通配符按字面匹配，需配合 ESCAPE '\' 使用
+func likeContains(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s) + "%"
 }
 
 // Create user
@@ -106,16 +383,37 @@ func createUser(c *gin.Context) (uint64, error) {
 		return 0, err
 	}
 
-	if len(uf.Password) < 6 {
-		return 0, singleton.Localizer.ErrorT("password length must be greater than 6")
+	if err := singleton.ValidatePassword(uf.Password); err != nil {
+		return 0, err
 	}
 	if uf.Username == "" {
 		return 0, singleton.Localizer.ErrorT("username can't be empty")
 	}
 
+	// 创建的用户属于创建者的租户，仅超级管理员可以指定租户
+	tenantID := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User).TenantID
+	if uf.TenantID != nil {
+		if scope := getTenantScope(c); scope != nil && *uf.TenantID != scope.TenantID {
+			return 0, singleton.Localizer.ErrorT("permission denied")
+		}
+		if !singleton.TenantExists(*uf.TenantID) {
+			return 0, singleton.Localizer.ErrorT("tenant id %d does not exist", *uf.TenantID)
+		}
+		tenantID = *uf.TenantID
+	}
+
 	var u model.User
 	u.Username = uf.Username
 	u.Role = model.RoleMember
+	u.TenantID = tenantID
+	// 默认权限中创建者没有的权限不授予新用户
+	u.Permissions = model.DefaultMemberPermissions & grantablePermissions(c)
+	if uf.Permissions != nil {
+		if err := checkGrantablePermissions(c, *uf.Permissions); err != nil {
+			return 0, err
+		}
+		u.Permissions = *uf.Permissions & model.PermissionAll
+	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(uf.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -123,14 +421,226 @@ func createUser(c *gin.Context) (uint64, error) {
 	}
 	u.Password = string(hash)
 
-	if err := singleton.DB.Create(&u).Error; err != nil {
+	if err := singleton.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&u).Error; err != nil {
+			return err
+		}
+		// 带默认值的字段创建时会忽略零值
+		if u.Permissions == 0 {
+			return tx.Model(&u).Update("permissions", 0).Error
+		}
+		return nil
+	}); err != nil {
 		return 0, err
 	}
 
 	singleton.OnUserUpdate(&u)
+	recordAuditLog(c, model.AuditActionUserCreate, auditTarget("user", u.ID), nil, userAuditSummary(&u))
 	return u.ID, nil
 }
 
+// Update user permissions
+// @Summary Update user permissions
+// @Security BearerAuth
+// @Schemes
+// @Description Update permission bits of a member, admins always have all permissions
+// @Tags admin required
+// @Accept json
+// @param id path uint true "User ID"
+// @param request body model.UserPermissionForm true "Permission Request"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /user/{id}/permissions [post]
+func updateUserPermissions(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var pf model.UserPermissionForm
+	if err := c.ShouldBindJSON(&pf); err != nil {
+		return nil, err
+	}
+
+	var u model.User
+	if err := singleton.DB.First(&u, id).Error; err != nil || !getTenantScope(c).Contains(u.ID) {
+		return nil, singleton.Localizer.ErrorT("user id %d does not exist", id)
+	}
+	if err := checkUserManageable(c, &u); err != nil {
+		return nil, err
+	}
+	if err := checkGrantablePermissions(c, pf.Permissions); err != nil {
+		return nil, err
+	}
+
+	before := userAuditSummary(&u)
+	if err := singleton.DB.Model(&u).Update("permissions", pf.Permissions&model.PermissionAll).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	recordAuditLog(c, model.AuditActionUserPermissions, auditTarget("user", u.ID), before, userAuditSummary(&u))
+	return nil, nil
+}
+
+// Force logout user
+// @Summary Force logout user
+// @Security BearerAuth
+// @Schemes
+// @Description Revoke all sessions and API tokens of a user
+// @Tags admin required
+// @param id path uint true "User ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /user/{id}/logout [post]
+func forceLogoutUser(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var u model.User
+	if err := singleton.DB.Omit("password").First(&u, id).Error; err != nil || !getTenantScope(c).Contains(id) {
+		return nil, singleton.Localizer.ErrorT("user id %d does not exist", id)
+	}
+	if err := checkUserManageable(c, &u); err != nil {
+		return nil, err
+	}
+	// API 令牌不受令牌版本约束，需一并删除
+	if err := singleton.DB.Unscoped().Delete(&model.ApiToken{}, "user_id = ?", id).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	if _, err := singleton.RevokeUserSessions(id); err != nil {
+		return nil, err
+	}
+
+	recordAuditLog(c, model.AuditActionUserLogout, auditTarget("user", id), nil, nil)
+	return nil, nil
+}
+
+// Impersonate user
+// @Summary Impersonate user
+// @Security BearerAuth
+// @Schemes
+// @Description Issue a time-limited access token that acts as the user, actions during impersonation are recorded under the admin in the audit log.
+// @Description Impersonating another admin requires allow_admin_impersonation in the config file.
+// @Tags admin required
+// @param id path uint true "User ID"
+// @param request body model.ImpersonateForm false "Impersonation options"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.LoginResponse]
+// @Router /user/{id}/impersonate [post]
+func impersonateUser(mw *jwt.GinJWTMiddleware) handlerFunc[*model.LoginResponse] {
+	return func(c *gin.Context) (*model.LoginResponse, error) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		var form model.ImpersonateForm
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&form); err != nil {
+				return nil, err
+			}
+		}
+		duration := model.DefaultImpersonationDuration
+		if form.Duration != 0 {
+			duration = time.Duration(form.Duration) * time.Second
+			if duration < 0 || duration > model.MaxImpersonationDuration {
+				return nil, singleton.Localizer.ErrorT("impersonation duration must be between 1 and %d seconds", int(model.MaxImpersonationDuration.Seconds()))
+			}
+		}
+
+		admin := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+		if admin.ID == id {
+			return nil, singleton.Localizer.ErrorT("cannot impersonate yourself")
+		}
+		var user model.User
+		if err := singleton.DB.First(&user, id).Error; err != nil {
+			return nil, singleton.Localizer.ErrorT("user id %d does not exist", id)
+		}
+		if user.Role == model.RoleAdmin && !singleton.Conf.AllowAdminImpersonation {
+			return nil, singleton.Localizer.ErrorT("impersonating an admin is not allowed")
+		}
+
+		user.Impersonation = &model.Impersonation{
+			UserID:       admin.ID,
+			Username:     admin.Username,
+			ExpireAt:     time.Now().Add(duration).Truncate(time.Second),
+			TokenVersion: admin.TokenVersion,
+		}
+		// 模拟登录沿用管理员的会话，闲置超时同样适用
+		user.SessionID = admin.SessionID
+		token, expire, err := generateToken(mw, &user)
+		if err != nil {
+			return nil, err
+		}
+		mw.SetCookie(c, token)
+
+		recordAuditLog(c, model.AuditActionUserImpersonate, auditTarget("user", id), nil, user.Impersonation)
+		return &model.LoginResponse{
+			Token:  token,
+			Expire: utils.IfOr(expire.Before(user.Impersonation.ExpireAt), expire, user.Impersonation.ExpireAt).Format(time.RFC3339),
+		}, nil
+	}
+}
+
+// End impersonation
+// @Summary End impersonation
+// @Security BearerAuth
+// @Schemes
+// @Description End impersonation and return a new access token of the admin who started it
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.LoginResponse]
+// @Router /profile/impersonation [delete]
+func endImpersonation(mw *jwt.GinJWTMiddleware) handlerFunc[*model.LoginResponse] {
+	return func(c *gin.Context) (*model.LoginResponse, error) {
+		user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+		if user.Impersonation == nil {
+			return nil, singleton.Localizer.ErrorT("not impersonating")
+		}
+
+		var admin model.User
+		if err := singleton.DB.First(&admin, user.Impersonation.UserID).Error; err != nil {
+			return nil, newGormError("%v", err)
+		}
+		admin.SessionID = user.SessionID
+		token, expire, err := generateToken(mw, &admin)
+		if err != nil {
+			return nil, err
+		}
+		mw.SetCookie(c, token)
+
+		recordAuditLog(c, model.AuditActionUserImpersonateEnd, auditTarget("user", user.ID), nil, nil)
+		return &model.LoginResponse{
+			Token:  token,
+			Expire: expire.Format(time.RFC3339),
+		}, nil
+	}
+}
+
+// Logout other sessions
+// @Summary Logout other sessions
+// @Security BearerAuth
+// @Schemes
+// @Description Revoke all sessions and refresh tokens of current user except the current one, returns new tokens for current session
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.LoginResponse]
+// @Router /profile/logout-others [post]
+func logoutOtherSessions(mw *jwt.GinJWTMiddleware) handlerFunc[*model.LoginResponse] {
+	return func(c *gin.Context) (*model.LoginResponse, error) {
+		user := *c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+
+		version, err := singleton.RevokeUserSessions(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		user.TokenVersion = version
+
+		return issueSession(c, mw, &user)
+	}
+}
+
 // Batch delete users
 // @Summary Batch delete users
 // @Security BearerAuth
@@ -152,8 +662,63 @@ func batchDeleteUser(c *gin.Context) (any, error) {
 		return nil, singleton.Localizer.ErrorT("can't delete yourself")
 	}
 
-	err := singleton.OnUserDelete(ids, newGormError)
-	return nil, err
+	var users []model.User
+	if err := singleton.DB.Omit("password").Where("id in (?)", ids).Find(&users).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	scope := getTenantScope(c)
+	for _, u := range users {
+		if !scope.Contains(u.ID) {
+			return nil, singleton.Localizer.ErrorT("permission denied")
+		}
+		if err := checkUserManageable(c, &u); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := singleton.OnUserDelete(ids, newGormError); err != nil {
+		return nil, err
+	}
+
+	for i := range users {
+		recordAuditLog(c, model.AuditActionUserDelete, auditTarget("user", users[i].ID), userAuditSummary(&users[i]), nil)
+	}
+	return nil, nil
+}
+
+// checkUserManageable 被授予用户管理权限的成员不能修改自己与管理员，只有超级管理员可以
+func checkUserManageable(c *gin.Context, target *model.User) error {
+	auth := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	if auth.IsSuperAdmin() {
+		return nil
+	}
+	if target.ID == auth.ID || target.Role == model.RoleAdmin {
+		return singleton.Localizer.ErrorT("permission denied")
+	}
+	return nil
+}
+
+// grantablePermissions 当前用户可以授予其他用户的权限，即自己拥有的权限
+func grantablePermissions(c *gin.Context) uint64 {
+	return c.MustGet(model.CtxKeyAuthorizedUser).(*model.User).EffectivePermissions()
+}
+
+// checkGrantablePermissions 不能授予自己没有的权限
+func checkGrantablePermissions(c *gin.Context, p uint64) error {
+	if p&model.PermissionAll&^grantablePermissions(c) != 0 {
+		return singleton.Localizer.ErrorT("permission denied")
+	}
+	return nil
+}
+
+// userAuditSummary 审计日志中记录的用户信息，不含密码等凭据
+func userAuditSummary(u *model.User) gin.H {
+	return gin.H{
+		"username":    u.Username,
+		"role":        u.Role,
+		"tenant_id":   u.TenantID,
+		"permissions": u.EffectivePermissions(),
+	}
 }
 
 // List online users
@@ -162,8 +727,11 @@ func batchDeleteUser(c *gin.Context) (any, error) {
 // @Schemes
 // @Description List online users
 // @Tags auth required
+// @Param user_id query uint false "Only sessions of the user"
+// @Param anonymous query bool false "Only anonymous (true) or logged in (false) sessions"
 // @Param limit query uint false "Page limit"
 // @Param offset query uint false "Page offset"
+// @Param cursor query string false "Page cursor, pass an empty value for the first page and next_cursor afterwards, offset is ignored"
 // @Produce json
 // @Success 200 {object} model.PaginatedResponse[[]model.OnlineUser, model.OnlineUser]
 // @Router /online-user [get]
@@ -178,12 +746,42 @@ func listOnlineUser(c *gin.Context) (*model.Value[[]*model.OnlineUser], error) {
 		offset = 0
 	}
 
+	filter := model.OnlineUserFilter{Tenant: getTenantScope(c)}
+	if v := c.Query("user_id"); v != "" {
+		uid, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		filter.UserID = &uid
+	}
+	if v := c.Query("anonymous"); v != "" {
+		anonymous, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+		filter.Anonymous = &anonymous
+	}
+
+	cursor, useCursor, err := getCursor(c)
+	if err != nil {
+		return nil, err
+	}
+	if useCursor {
+		users, next, total := singleton.GetOnlineUsersAfter(cursor, limit, &filter)
+		p := model.Pagination{Limit: limit, Total: int64(total)}
+		if next != nil {
+			p.NextCursor = next.Encode()
+		}
+		return &model.Value[[]*model.OnlineUser]{Value: users, Pagination: p}, nil
+	}
+
+	users, total := singleton.GetOnlineUsers(limit, offset, &filter)
 	return &model.Value[[]*model.OnlineUser]{
-		Value: singleton.GetOnlineUsers(limit, offset),
+		Value: users,
 		Pagination: model.Pagination{
 			Offset: offset,
 			Limit:  limit,
-			Total:  int64(singleton.GetOnlineUserCount()),
+			Total:  int64(total),
 		},
 	}, nil
 }
@@ -192,10 +790,12 @@ func listOnlineUser(c *gin.Context) (*model.Value[[]*model.OnlineUser], error) {
 // @Summary Batch block online user
 // @Security BearerAuth
 // @Schemes
-// @Description Batch block online user
+// @Description Batch block online user, or the countries / ASNs they connect from
 // @Tags admin required
 // @Accept json
-// @Param request body []string true "block list"
+// @Param request body []string true "IP or CIDR list"
+// @Param scope query string false "ip (default), country or asn"
+// @Param duration query uint false "Block duration in minutes for IPs and CIDRs, permanent if omitted"
 // @Produce json
 // @Success 200 {object} model.CommonResponse[any]
 // @Router /online-user/batch-block [patch]
@@ -205,9 +805,44 @@ func batchBlockOnlineUser(c *gin.Context) (any, error) {
 		return nil, err
 	}
 
-	if err := singleton.BlockByIPs(list); err != nil {
-		return nil, newGormError("%v", err)
+	var ttl time.Duration
+	if d := c.Query("duration"); d != "" {
+		minutes, err := strconv.ParseUint(d, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ttl = time.Duration(minutes) * time.Minute
+	}
+
+	var geoType uint8
+	switch c.Query("scope") {
+	case "", "ip":
+		if _, _, err := singleton.SplitIPAndRanges(list); err != nil {
+			return nil, err
+		}
+		if err := singleton.BlockByIPs(c.Request.Context(), list, ttl, getUid(c)); err != nil {
+			return nil, newGormError("%v", err)
+		}
+		recordAuditLog(c, model.AuditActionBlock, "waf", nil, gin.H{"addresses": list, "duration": ttl.String()})
+		return nil, nil
+	case "country":
+		geoType = model.WAFGeoTypeCountry
+	case "asn":
+		geoType = model.WAFGeoTypeASN
+	default:
+		return nil, singleton.Localizer.ErrorT("invalid block type")
 	}
 
+	values := make([]string, 0, len(list))
+	for _, ip := range list {
+		v := singleton.GeoValueOf(geoType, ip)
+		if v == "" {
+			return nil, singleton.Localizer.ErrorT("no geoip data for %s", ip)
+		}
+		values = append(values, v)
+	}
+	if err := blockGeo(c, geoType, values); err != nil {
+		return nil, err
+	}
 	return nil, nil
 }
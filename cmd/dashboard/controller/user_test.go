@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func TestUserManagementEscalation(t *testing.T) {
+	admin, adminToken := testCreateUser(t, model.RoleAdmin, 0)
+	manager, managerToken := testCreateUser(t, model.RoleMember, model.PermissionUser|model.PermissionServerRead)
+	member, _ := testCreateUser(t, model.RoleMember, model.PermissionServerRead)
+
+	cases := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		body   any
+		allow  bool
+	}{
+		{"grant self all permissions", managerToken, http.MethodPost, fmt.Sprintf("/api/v1/user/%d/permissions", manager.ID), model.UserPermissionForm{Permissions: model.PermissionAll}, false},
+		{"grant a permission the manager lacks", managerToken, http.MethodPost, fmt.Sprintf("/api/v1/user/%d/permissions", member.ID), model.UserPermissionForm{Permissions: model.PermissionSetting}, false},
+		{"grant owned permissions", managerToken, http.MethodPost, fmt.Sprintf("/api/v1/user/%d/permissions", member.ID), model.UserPermissionForm{Permissions: model.PermissionServerRead}, true},
+		{"change admin permissions", managerToken, http.MethodPost, fmt.Sprintf("/api/v1/user/%d/permissions", admin.ID), model.UserPermissionForm{Permissions: 0}, false},
+		{"force logout admin", managerToken, http.MethodPost, fmt.Sprintf("/api/v1/user/%d/logout", admin.ID), nil, false},
+		{"force logout member", managerToken, http.MethodPost, fmt.Sprintf("/api/v1/user/%d/logout", member.ID), nil, true},
+		{"delete admin", managerToken, http.MethodPost, "/api/v1/batch-delete/user", []uint64{admin.ID}, false},
+		{"create user with extra permissions", managerToken, http.MethodPost, "/api/v1/user", map[string]any{"username": "escalated", "password": "Passw0rd-Long-123", "permissions": model.PermissionAll}, false},
+		{"super admin grants any permission", adminToken, http.MethodPost, fmt.Sprintf("/api/v1/user/%d/permissions", member.ID), model.UserPermissionForm{Permissions: model.PermissionSetting}, true},
+	}
+	for _, tc := range cases {
+		code, resp := testRequest(t, tc.token, tc.method, tc.path, tc.body)
+		if testAllowed(code, resp) != tc.allow {
+			t.Errorf("%s: got status %d, response %+v", tc.name, code, resp)
+		}
+	}
+
+	var u model.User
+	if err := singleton.DB.First(&u, manager.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if u.Permissions != manager.Permissions {
+		t.Fatalf("manager permissions changed to %d", u.Permissions)
+	}
+}
+
+func TestSettingCustomCodeRequiresSuperAdmin(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleMember, model.PermissionSetting)
+	form := settingAuditSummary(singleton.Conf)
+	form.CustomCode = "<script>alert(1)</script>"
+	if code, resp := testRequest(t, token, http.MethodPatch, "/api/v1/setting", form); testAllowed(code, resp) {
+		t.Fatal("member with setting permission should not change custom code")
+	}
+	if singleton.Conf.CustomCode != "" {
+		t.Fatalf("custom code changed to %q", singleton.Conf.CustomCode)
+	}
+}
+
+func TestForceLogoutRevokesApiTokens(t *testing.T) {
+	_, adminToken := testCreateUser(t, model.RoleAdmin, 0)
+	member, memberToken := testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+	secret := testCreateApiToken(t, memberToken, false)
+
+	if code, resp := testApiTokenRequest(t, secret, http.MethodGet, "/api/v1/profile", nil); !testAllowed(code, resp) {
+		t.Fatalf("api token should work before logout, got status %d", code)
+	}
+	if code, resp := testRequest(t, adminToken, http.MethodPost, fmt.Sprintf("/api/v1/user/%d/logout", member.ID), nil); !testAllowed(code, resp) {
+		t.Fatalf("force logout: got status %d, response %+v", code, resp)
+	}
+
+	if code, resp := testRequest(t, memberToken, http.MethodGet, "/api/v1/profile", nil); testAllowed(code, resp) {
+		t.Fatal("session token should be revoked")
+	}
+	if code, resp := testApiTokenRequest(t, secret, http.MethodGet, "/api/v1/profile", nil); testAllowed(code, resp) {
+		t.Fatal("api token should be revoked")
+	}
+}
+
+func TestImpersonationExpiryAndRevocation(t *testing.T) {
+	admin, adminToken := testCreateUser(t, model.RoleAdmin, 0)
+	member, _ := testCreateUser(t, model.RoleMember, model.DefaultMemberPermissions)
+
+	code, resp := testRequest(t, adminToken, http.MethodPost, fmt.Sprintf("/api/v1/user/%d/impersonate", member.ID), nil)
+	if !testAllowed(code, resp) {
+		t.Fatalf("impersonate: got status %d, response %+v", code, resp)
+	}
+	var lr model.LoginResponse
+	if err := json.Unmarshal(resp.Data, &lr); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := testRequest(t, lr.Token, http.MethodGet, "/api/v1/profile", nil); !testAllowed(code, resp) {
+		t.Fatalf("impersonation token rejected: status %d", code)
+	}
+
+	// 到期后即使访问令牌仍在有效期内也不能继续使用
+	expired := *member
+	expired.SessionID = admin.SessionID
+	expired.Impersonation = &model.Impersonation{UserID: admin.ID, ExpireAt: time.Now().Add(-time.Second), TokenVersion: admin.TokenVersion}
+	expiredToken, _, err := generateToken(testJWT, &expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := testRequest(t, expiredToken, http.MethodGet, "/api/v1/profile", nil); testAllowed(code, resp) {
+		t.Fatal("expired impersonation token should be rejected")
+	}
+
+	// 撤销发起人的会话后模拟登录随之失效
+	if _, err := singleton.RevokeUserSessions(admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := testRequest(t, lr.Token, http.MethodGet, "/api/v1/profile", nil); testAllowed(code, resp) {
+		t.Fatal("impersonation should end when the admin's sessions are revoked")
+	}
+}
+
+func TestListUserHidesSecrets(t *testing.T) {
+	admin, _ := testCreateUser(t, model.RoleAdmin, 0)
+	if err := singleton.DB.Model(admin).Updates(map[string]any{"agent_secret": "admin-agent-secret", "oidc_subject": "admin-sub"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, token := testCreateUser(t, model.RoleMember, model.PermissionUser)
+
+	code, resp := testRequest(t, token, http.MethodGet, "/api/v1/user?limit=1000", nil)
+	if !testAllowed(code, resp) {
+		t.Fatalf("list users: got status %d, response %+v", code, resp)
+	}
+	if body := string(resp.Data); strings.Contains(body, "admin-agent-secret") || strings.Contains(body, "admin-sub") {
+		t.Fatal("user list leaks agent secret or oidc subject")
+	}
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"slices"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -58,7 +59,7 @@ func listBlockedAddress(c *gin.Context) (*model.Value[[]*model.WAF], error) {
 // @Description Edit server
 // @Tags admin required
 // @Accept json
-// @Param request body []string true "block list"
+// @Param request body []string true "IP or CIDR list"
 // @Produce json
 // @Success 200 {object} model.CommonResponse[any]
 // @Router /batch-delete/waf [patch]
@@ -68,9 +69,176 @@ func batchDeleteBlockedAddress(c *gin.Context) (any, error) {
 		return nil, err
 	}
 
-	if err := model.BatchClearIP(singleton.DB, list); err != nil {
+	ips, prefixes, err := singleton.SplitIPAndRanges(list)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.BatchClearIP(singleton.DB, ips); err != nil {
 		return nil, newGormError("%v", err)
 	}
+	if len(prefixes) > 0 {
+		if err := singleton.ClearRanges(prefixes); err != nil {
+			return nil, newGormError("%v", err)
+		}
+	}
+	singleton.RecordWAFAudit(c.Request.Context(), auditOperator(c), model.WAFAuditActionUnblock, list, 0)
+	recordAuditLog(c, model.AuditActionUnblock, "waf", gin.H{"addresses": list}, nil)
 
 	return nil, nil
 }
+
+// List active blocks
+// @Summary List active blocks
+// @Security BearerAuth
+// @Schemes
+// @Description List IPs and CIDR ranges that are currently blocked, with their source
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.WAFBlock]
+// @Router /waf/active [get]
+func listActiveBlocks(c *gin.Context) ([]*model.WAFBlock, error) {
+	list, err := singleton.ActiveBlocks()
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return list, nil
+}
+
+// List country and ASN blocks
+// @Summary List country and ASN blocks
+// @Security BearerAuth
+// @Schemes
+// @Description List country and ASN blocks
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.WAFGeo]
+// @Router /waf/geo [get]
+func listWAFGeo(c *gin.Context) ([]*model.WAFGeo, error) {
+	var rules []*model.WAFGeo
+	if err := singleton.DB.Order("id").Find(&rules).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return rules, nil
+}
+
+// Add country or ASN block
+// @Summary Add country or ASN block
+// @Security BearerAuth
+// @Schemes
+// @Description Add country or ASN block
+// @Tags admin required
+// @Accept json
+// @Param request body model.WAFGeoForm true "WAFGeoForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /waf/geo [post]
+func createWAFGeo(c *gin.Context) (any, error) {
+	var gf model.WAFGeoForm
+	if err := c.ShouldBindJSON(&gf); err != nil {
+		return nil, err
+	}
+
+	value, err := singleton.NormalizeWAFGeoValue(gf.Type, gf.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := blockGeo(c, gf.Type, []string{value}); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// Batch delete country and ASN blocks
+// @Summary Batch delete country and ASN blocks
+// @Security BearerAuth
+// @Schemes
+// @Description Batch delete country and ASN blocks
+// @Tags admin required
+// @Accept json
+// @Param request body []uint64 true "id list"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /batch-delete/waf/geo [post]
+func batchDeleteWAFGeo(c *gin.Context) (any, error) {
+	var ids []uint64
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		return nil, err
+	}
+
+	rules, err := singleton.DeleteWAFGeoRules(ids)
+	if err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	addresses := make([]string, 0, len(rules))
+	for _, r := range rules {
+		addresses = append(addresses, r.String())
+	}
+	singleton.RecordWAFAudit(c.Request.Context(), auditOperator(c), model.WAFAuditActionUnblock, addresses, 0)
+	recordAuditLog(c, model.AuditActionUnblock, "waf", gin.H{"addresses": addresses}, nil)
+	return nil, nil
+}
+
+// blockGeo 添加封禁规则，拒绝封禁当前请求来源所在的国家或 ASN
+func blockGeo(c *gin.Context, t uint8, values []string) error {
+	ip := c.GetString(model.CtxKeyRealIPStr)
+	if ip == "" {
+		ip = c.RemoteIP()
+	}
+	if self := singleton.GeoValueOf(t, ip); self != "" && slices.Contains(values, self) {
+		return singleton.Localizer.ErrorT("cannot block the country or ASN you are connecting from")
+	}
+	if err := singleton.AddWAFGeoRules(t, values); err != nil {
+		return newGormError("%v", err)
+	}
+
+	addresses := make([]string, 0, len(values))
+	for _, v := range values {
+		addresses = append(addresses, (&model.WAFGeo{Type: t, Value: v}).String())
+	}
+	singleton.RecordWAFAudit(c.Request.Context(), auditOperator(c), model.WAFAuditActionBlock, addresses, 0)
+	recordAuditLog(c, model.AuditActionBlock, "waf", nil, gin.H{"addresses": addresses})
+	return nil
+}
+
+// List block audit log
+// @Summary List block audit log
+// @Security BearerAuth
+// @Schemes
+// @Description List who created or removed manual blocks and when
+// @Tags auth required
+// @Param limit query uint false "Page limit"
+// @Param offset query uint false "Page offset"
+// @Produce json
+// @Success 200 {object} model.PaginatedResponse[[]model.WAFAudit, model.WAFAudit]
+// @Router /waf/audit [get]
+func listWAFAudit(c *gin.Context) (*model.Value[[]*model.WAFAudit], error) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 25
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	var logs []*model.WAFAudit
+	if err := singleton.DB.Order("id DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	var total int64
+	if err := singleton.DB.Model(&model.WAFAudit{}).Count(&total).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+
+	return &model.Value[[]*model.WAFAudit]{
+		Value: logs,
+		Pagination: model.Pagination{
+			Offset: offset,
+			Limit:  limit,
+			Total:  total,
+		},
+	}, nil
+}
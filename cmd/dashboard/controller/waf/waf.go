@@ -2,13 +2,13 @@ package waf
 
 import (
 	_ "embed"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/nezhahq/nezha/model"
-	"github.com/nezhahq/nezha/pkg/utils"
 	"github.com/nezhahq/nezha/service/singleton"
 )
 
@@ -27,13 +27,13 @@ func RealIp(c *gin.Context) {
 		return
 	}
 
+	// 来自非可信代理的请求不需要请求头，直接使用对端地址
 	vals := c.Request.Header.Get(singleton.Conf.RealIPHeader)
-	if vals == "" {
-		c.AbortWithStatusJSON(http.StatusOK, model.CommonResponse[any]{Success: false, Error: "real ip header not found"})
-		return
-	}
-	ip, err := utils.GetIPFromHeader(vals)
+	ip, err := singleton.ClientIP(vals, c.RemoteIP())
 	if err != nil {
+		if vals == "" {
+			err = errors.New("real ip header not found")
+		}
 		c.AbortWithStatusJSON(http.StatusOK, model.CommonResponse[any]{Success: false, Error: err.Error()})
 		return
 	}
@@ -42,7 +42,12 @@ func RealIp(c *gin.Context) {
 }
 
 func Waf(c *gin.Context) {
-	if err := model.CheckIP(singleton.DB, c.GetString(model.CtxKeyRealIPStr)); err != nil {
+	ip := c.GetString(model.CtxKeyRealIPStr)
+	if err := model.CheckIP(singleton.DB, ip); err != nil {
+		ShowBlockPage(c, err)
+		return
+	}
+	if err := singleton.CheckIPBlocked(ip); err != nil {
 		ShowBlockPage(c, err)
 		return
 	}
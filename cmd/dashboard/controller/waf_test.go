@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+func TestBlockOnlineUserKeepsPermanentBlock(t *testing.T) {
+	_, token := testCreateUser(t, model.RoleAdmin, 0)
+	const ip = "203.0.113.7"
+
+	for _, path := range []string{"/api/v1/online-user/batch-block", "/api/v1/online-user/batch-block?duration=10"} {
+		if code, resp := testRequest(t, token, http.MethodGet, path, []string{ip}); !testAllowed(code, resp) {
+			t.Fatalf("%s: got status %d, response %+v", path, code, resp)
+		}
+	}
+
+	ipBinary, _ := utils.IPStringToBinary(ip)
+	var w model.WAF
+	if err := singleton.DB.First(&w, "ip = ? AND block_identifier = ?", ipBinary, model.BlockIDManual).Error; err != nil {
+		t.Fatal(err)
+	}
+	if w.ExpireAt != 0 || w.Count != 2 {
+		t.Fatalf("permanent block changed: expire at %d, count %d", w.ExpireAt, w.Count)
+	}
+
+}
@@ -0,0 +1,320 @@
+package controller
+
+import (
+	"encoding/base64"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/webauthn"
+	"github.com/nezhahq/nezha/service/singleton"
+)
+
+const webAuthnTimeout = 5 * time.Minute
+
+// relyingParty 依赖方 ID 为访问面板时使用的域名
+func relyingParty(c *gin.Context) *webauthn.RelyingParty {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return &webauthn.RelyingParty{ID: host, Origin: requestOrigin(c)}
+}
+
+func webAuthnDescriptors(creds []model.WebAuthnCredential) []model.WebAuthnCredentialDescriptor {
+	list := make([]model.WebAuthnCredentialDescriptor, 0, len(creds))
+	for _, cred := range creds {
+		list = append(list, model.WebAuthnCredentialDescriptor{Type: "public-key", ID: cred.CredentialID})
+	}
+	return list
+}
+
+// decodeBase64URL 兼容带填充与不带填充的 base64url
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// List WebAuthn credentials
+// @Summary List WebAuthn credentials
+// @Security BearerAuth
+// @Schemes
+// @Description List passkeys and security keys of current user
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[[]model.WebAuthnCredential]
+// @Router /profile/webauthn [get]
+func listWebAuthnCredential(c *gin.Context) ([]model.WebAuthnCredential, error) {
+	var creds []model.WebAuthnCredential
+	if err := singleton.DB.Where("user_id = ?", getUid(c)).Find(&creds).Error; err != nil {
+		return nil, newGormError("%v", err)
+	}
+	return creds, nil
+}
+
+// Begin WebAuthn registration
+// @Summary Begin WebAuthn registration
+// @Security BearerAuth
+// @Schemes
+// @Description Get the options for navigator.credentials.create(), the challenge is valid for 5 minutes
+// @Tags auth required
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.WebAuthnCreationOptions]
+// @Router /profile/webauthn/register/begin [post]
+func beginWebAuthnRegistration(c *gin.Context) (*model.WebAuthnCreationOptions, error) {
+	user := c.MustGet(model.CtxKeyAuthorizedUser).(*model.User)
+	creds, err := listWebAuthnCredential(c)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	singleton.Cache.Set("webauthn:register:"+strconv.FormatUint(user.ID, 10), challenge, webAuthnTimeout)
+
+	rp := relyingParty(c)
+	params := make([]model.WebAuthnCredentialParameter, 0, len(webauthn.SupportedAlgorithms))
+	for _, alg := range webauthn.SupportedAlgorithms {
+		params = append(params, model.WebAuthnCredentialParameter{Type: "public-key", Alg: alg})
+	}
+	return &model.WebAuthnCreationOptions{
+		Challenge: challenge,
+		RP:        model.WebAuthnRelyingParty{ID: rp.ID, Name: singleton.Conf.SiteName},
+		User: model.WebAuthnUserEntity{
+			ID:          base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(user.ID, 10))),
+			Name:        user.Username,
+			DisplayName: user.Username,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            webAuthnTimeout.Milliseconds(),
+		Attestation:        "none",
+		ExcludeCredentials: webAuthnDescriptors(creds),
+		AuthenticatorSelection: model.WebAuthnAuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+	}, nil
+}
+
+// Finish WebAuthn registration
+// @Summary Finish WebAuthn registration
+// @Security BearerAuth
+// @Schemes
+// @Description Save the credential returned by navigator.credentials.create()
+// @Tags auth required
+// @Accept json
+// @param request body model.WebAuthnRegisterForm true "WebAuthnRegisterForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[uint64]
+// @Router /profile/webauthn/register/finish [post]
+func finishWebAuthnRegistration(c *gin.Context) (uint64, error) {
+	var rf model.WebAuthnRegisterForm
+	if err := c.ShouldBindJSON(&rf); err != nil {
+		return 0, err
+	}
+	if rf.Name == "" {
+		return 0, singleton.Localizer.ErrorT("credential name can't be empty")
+	}
+
+	uid := getUid(c)
+	key := "webauthn:register:" + strconv.FormatUint(uid, 10)
+	challenge, ok := singleton.Cache.Get(key)
+	if !ok {
+		return 0, singleton.Localizer.ErrorT("webauthn challenge expired, please retry")
+	}
+	singleton.Cache.Delete(key)
+
+	clientData, err := decodeBase64URL(rf.Response.ClientDataJSON)
+	if err != nil {
+		return 0, err
+	}
+	attestation, err := decodeBase64URL(rf.Response.AttestationObject)
+	if err != nil {
+		return 0, err
+	}
+
+	cred, err := relyingParty(c).VerifyRegistration(challenge.(string), clientData, attestation)
+	if err != nil {
+		return 0, singleton.Localizer.ErrorT("webauthn verification failed: %v", err)
+	}
+
+	wc := model.WebAuthnCredential{
+		Name:         rf.Name,
+		CredentialID: base64.RawURLEncoding.EncodeToString(cred.ID),
+		PublicKey:    cred.PublicKey,
+		SignCount:    cred.SignCount,
+	}
+	wc.UserID = uid
+	if err := singleton.DB.Create(&wc).Error; err != nil {
+		return 0, newGormError("%v", err)
+	}
+	return wc.ID, nil
+}
+
+// Delete WebAuthn credential
+// @Summary Delete WebAuthn credential
+// @Security BearerAuth
+// @Schemes
+// @Description Remove a lost or unused passkey of current user
+// @Tags auth required
+// @param id path uint true "Credential ID"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[any]
+// @Router /profile/webauthn/{id} [delete]
+func deleteWebAuthnCredential(c *gin.Context) (any, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	result := singleton.DB.Where("id = ? AND user_id = ?", id, getUid(c)).Delete(&model.WebAuthnCredential{})
+	if result.Error != nil {
+		return nil, newGormError("%v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, singleton.Localizer.ErrorT("credential id %d does not exist", id)
+	}
+	return nil, nil
+}
+
+// Begin WebAuthn login
+// @Summary Begin WebAuthn login
+// @Schemes
+// @Description Get the options for navigator.credentials.get(), omit username to sign in with a discoverable passkey
+// @Accept json
+// @param request body model.WebAuthnLoginBeginForm false "WebAuthnLoginBeginForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.WebAuthnLoginBeginResponse]
+// @Router /webauthn/login/begin [post]
+func beginWebAuthnLogin(c *gin.Context) (*model.WebAuthnLoginBeginResponse, error) {
+	var bf model.WebAuthnLoginBeginForm
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&bf); err != nil {
+			return nil, err
+		}
+	}
+
+	// 用户不存在时同样返回空列表，避免探测用户名
+	var creds []model.WebAuthnCredential
+	if bf.Username != "" {
+		if err := singleton.DB.Where("user_id = (?)",
+			singleton.DB.Model(&model.User{}).Select("id").Where("username = ?", bf.Username),
+		).Find(&creds).Error; err != nil {
+			return nil, newGormError("%v", err)
+		}
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	session, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	singleton.Cache.Set("webauthn:login:"+session, challenge, webAuthnTimeout)
+
+	return &model.WebAuthnLoginBeginResponse{
+		Session: session,
+		Options: model.WebAuthnRequestOptions{
+			Challenge:        challenge,
+			RPID:             relyingParty(c).ID,
+			Timeout:          webAuthnTimeout.Milliseconds(),
+			AllowCredentials: webAuthnDescriptors(creds),
+			UserVerification: "preferred",
+		},
+	}, nil
+}
+
+// Finish WebAuthn login
+// @Summary Finish WebAuthn login
+// @Schemes
+// @Description Sign in with the assertion returned by navigator.credentials.get(), returns the same token as password login
+// @Accept json
+// @param request body model.WebAuthnLoginForm true "WebAuthnLoginForm"
+// @Produce json
+// @Success 200 {object} model.CommonResponse[model.LoginResponse]
+// @Router /webauthn/login/finish [post]
+func finishWebAuthnLogin(mw *jwt.GinJWTMiddleware) handlerFunc[*model.LoginResponse] {
+	return func(c *gin.Context) (*model.LoginResponse, error) {
+		var lf model.WebAuthnLoginForm
+		if err := c.ShouldBindJSON(&lf); err != nil {
+			return nil, err
+		}
+
+		key := "webauthn:login:" + lf.Session
+		challenge, ok := singleton.Cache.Get(key)
+		if !ok || lf.Session == "" {
+			return nil, singleton.Localizer.ErrorT("webauthn challenge expired, please retry")
+		}
+		singleton.Cache.Delete(key)
+
+		realip := c.GetString(model.CtxKeyRealIPStr)
+		rawID, err := decodeBase64URL(lf.ID)
+		if err != nil {
+			return nil, err
+		}
+		var wc model.WebAuthnCredential
+		if err := singleton.DB.Where("credential_id = ?", base64.RawURLEncoding.EncodeToString(rawID)).First(&wc).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeLoginFail, model.BlockIDUnknownUser)
+				return nil, singleton.Localizer.ErrorT("webauthn login failed")
+			}
+			return nil, newGormError("%v", err)
+		}
+		if lf.Response.UserHandle != "" {
+			if handle, err := decodeBase64URL(lf.Response.UserHandle); err != nil || string(handle) != strconv.FormatUint(wc.UserID, 10) {
+				return nil, singleton.Localizer.ErrorT("webauthn login failed")
+			}
+		}
+
+		clientData, err := decodeBase64URL(lf.Response.ClientDataJSON)
+		if err != nil {
+			return nil, err
+		}
+		authData, err := decodeBase64URL(lf.Response.AuthenticatorData)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := decodeBase64URL(lf.Response.Signature)
+		if err != nil {
+			return nil, err
+		}
+
+		count, err := relyingParty(c).VerifyAssertion(challenge.(string), &webauthn.Credential{
+			ID:        rawID,
+			PublicKey: wc.PublicKey,
+			SignCount: wc.SignCount,
+		}, clientData, authData, signature)
+		if err != nil {
+			model.BlockIP(singleton.DB, realip, model.WAFBlockReasonTypeLoginFail, int64(wc.UserID))
+			requestLogger(c).Warn("webauthn login verification failed", "error", err)
+			return nil, singleton.Localizer.ErrorT("webauthn login failed")
+		}
+
+		var user model.User
+		if err := singleton.DB.First(&user, wc.UserID).Error; err != nil {
+			return nil, singleton.Localizer.ErrorT("webauthn login failed")
+		}
+
+		now := time.Now()
+		if err := singleton.DB.Model(&wc).Updates(map[string]any{"sign_count": count, "last_used_at": now}).Error; err != nil {
+			return nil, newGormError("%v", err)
+		}
+		if err := singleton.RecordLogin(user.ID, realip, c.Request.UserAgent()); err != nil {
+			requestLogger(c).Error("record login failed", "error", err)
+		}
+		model.ClearIP(singleton.DB, realip, model.BlockIDUnknownUser)
+		model.ClearIP(singleton.DB, realip, int64(user.ID))
+
+		return issueSession(c, mw, &user)
+	}
+}
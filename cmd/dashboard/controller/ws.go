@@ -5,6 +5,8 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -14,12 +16,19 @@ import (
 	"golang.org/x/sync/singleflight"
 
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/geoip"
 	"github.com/nezhahq/nezha/pkg/utils"
 	"github.com/nezhahq/nezha/service/singleton"
 )
 
 var upgrader *websocket.Upgrader
 
+// 已建立的 WebSocket 连接，面板退出时向客户端发送关闭帧
+var (
+	wsConns     = make(map[*websocket.Conn]struct{})
+	wsConnsLock sync.Mutex
+)
+
 func InitUpgrader() {
 	var checkOrigin func(r *http.Request) bool
 
@@ -59,6 +68,53 @@ func InitUpgrader() {
 	}
 }
 
+// upgradeWebSocket 升级为 WebSocket 连接并登记，面板退出后不再接受新连接。
+// 连接需通过 closeWebSocket 关闭，只读 API 令牌不能建立连接
+func upgradeWebSocket(c *gin.Context) (*websocket.Conn, error) {
+	if c.GetBool(model.CtxKeyReadOnly) {
+		return nil, singleton.Localizer.ErrorT("permission denied")
+	}
+	select {
+	case <-singleton.ShuttingDown():
+		return nil, singleton.Localizer.ErrorT("dashboard is shutting down")
+	default:
+	}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return nil, err
+	}
+	wsConnsLock.Lock()
+	wsConns[conn] = struct{}{}
+	wsConnsLock.Unlock()
+	return conn, nil
+}
+
+func closeWebSocket(conn *websocket.Conn) {
+	wsConnsLock.Lock()
+	delete(wsConns, conn)
+	wsConnsLock.Unlock()
+	conn.Close()
+}
+
+// CloseWebSockets 向所有 WebSocket 客户端发送关闭帧并断开连接，返回关闭的连接数
+func CloseWebSockets() int {
+	wsConnsLock.Lock()
+	conns := make([]*websocket.Conn, 0, len(wsConns))
+	for conn := range wsConns {
+		conns = append(conns, conn)
+	}
+	clear(wsConns)
+	wsConnsLock.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for _, conn := range conns {
+		conn.WriteControl(websocket.CloseMessage, msg, deadline)
+		conn.Close()
+	}
+	return len(conns)
+}
+
 func equalASCIIFold(s, t string) bool {
 	for s != "" && t != "" {
 		sr, size := utf8.DecodeRuneInString(s)
@@ -97,8 +153,10 @@ func checkSameOrigin(r *http.Request) bool {
 // @Summary Websocket server stream
 // @tags common
 // @Schemes
-// @Description Websocket server stream
+// @Description Websocket server stream, send a model.StreamServerFilter as a text message to update the subscription at any time
 // @security BearerAuth
+// @Param group query []uint false "Only servers in any of the groups"
+// @Param tag query []string false "Only servers with all given tags, logged in users only"
 // @Produce json
 // @Success 200 {object} model.StreamServerData
 // @Router /ws/server [get]
@@ -108,36 +166,71 @@ func serverStream(c *gin.Context) (any, error) {
 		return nil, newWsError("%v", err)
 	}
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := upgradeWebSocket(c)
 	if err != nil {
 		return nil, newWsError("%v", err)
 	}
-	defer conn.Close()
+	defer closeWebSocket(conn)
 
 	userIp := c.GetString(model.CtxKeyRealIPStr)
 	if userIp == "" {
 		userIp = c.RemoteIP()
 	}
 
-	singleton.AddOnlineUser(connId, &model.OnlineUser{
+	onlineUser := &model.OnlineUser{
 		IP:          userIp,
+		Geo:         geoip.LookupLocation(userIp),
 		ConnectedAt: time.Now(),
 		Conn:        conn,
-	})
+		Anonymous:   true,
+	}
+	u, authorized := c.Get(model.CtxKeyAuthorizedUser)
+	if authorized {
+		user := u.(*model.User)
+		onlineUser.UserID, onlineUser.Username, onlineUser.Anonymous = user.ID, user.Username, false
+	}
+	singleton.AddOnlineUser(connId, onlineUser)
 	defer singleton.RemoveOnlineUser(connId)
 
+	filter := &model.StreamServerFilter{Tags: c.QueryArray("tag")}
+	for _, v := range c.QueryArray("group") {
+		gid, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, newWsError("%v", err)
+		}
+		filter.Groups = append(filter.Groups, gid)
+	}
+	setStreamFilter(connId, filter, authorized)
+
+	// 读取客户端发送的订阅条件，同时处理控制帧
+	conn.SetReadLimit(streamFilterMaxSize)
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				conn.Close()
+				return
+			}
+			var f model.StreamServerFilter
+			if err := utils.Json.Unmarshal(data, &f); err != nil {
+				continue
+			}
+			setStreamFilter(connId, &f, authorized)
+		}
+	}()
+
 	count := 0
 	for {
-		stat, err := getServerStat(c, count == 0)
+		stat, err := getServerStat(c, count == 0, singleton.GetOnlineUserFilter(connId))
 		if err != nil {
 			continue
 		}
-		if err := conn.WriteMessage(websocket.TextMessage, stat); err != nil {
+		if err := wsWrite(conn, websocket.TextMessage, stat); err != nil {
 			break
 		}
 		count += 1
 		if count%4 == 0 {
-			err = conn.WriteMessage(websocket.PingMessage, []byte{})
+			err = wsWrite(conn, websocket.PingMessage, []byte{})
 			if err != nil {
 				break
 			}
@@ -147,46 +240,134 @@ func serverStream(c *gin.Context) (any, error) {
 	return nil, newWsError("")
 }
 
+const streamFilterMaxSize = 4096
+
+// 客户端超过该时间仍未能接收消息时断开，避免慢速客户端拖住推送
+const wsWriteTimeout = time.Second * 10
+
+func wsWrite(conn *websocket.Conn, messageType int, data []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteMessage(messageType, data)
+}
+
+func wsWriteJSON(conn *websocket.Conn, v any) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(v)
+}
+
+// wsConnLimit 限制同时保持的 WebSocket 连接数，超出时完成握手后以 1013 (Try Again Later) 关闭，
+// 便于客户端区分连接数限制与网络错误
+func wsConnLimit(c *gin.Context) {
+	ip := c.GetString(model.CtxKeyRealIPStr)
+	if ip == "" {
+		ip = c.RemoteIP()
+	}
+
+	release, ok := singleton.AcquireWebSocketConn(ip, rateLimitExempt(ip))
+	if !ok {
+		c.Abort()
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many connections"), time.Now().Add(time.Second))
+		return
+	}
+	defer release()
+	c.Next()
+}
+
+// setStreamFilter 更新连接的订阅条件，游客看不到标签，按标签筛选会泄露标签信息，因此忽略
+func setStreamFilter(connId string, filter *model.StreamServerFilter, authorized bool) {
+	if !authorized {
+		filter.Tags = nil
+	}
+	filter.Tags = model.NormalizeTags(filter.Tags)
+	if filter.Empty() {
+		filter = nil
+	}
+	singleton.SetOnlineUserFilter(connId, filter)
+}
+
 var requestGroup singleflight.Group
 
-func getServerStat(c *gin.Context, withPublicNote bool) ([]byte, error) {
-	_, isMember := c.Get(model.CtxKeyAuthorizedUser)
+// connectionPushWindow 连接状态变化后持续推送的时长，确保各客户端都能收到
+const connectionPushWindow = 10 * time.Second
+
+// getServerStat 序列化推送数据，filter 只在当前用户可见的服务器中进一步筛选
+func getServerStat(c *gin.Context, withPublicNote bool, filter *model.StreamServerFilter) ([]byte, error) {
+	u, isMember := c.Get(model.CtxKeyAuthorizedUser)
 	authorized := isMember // TODO || isViewPasswordVerfied
-	v, err, _ := requestGroup.Do(fmt.Sprintf("serverStats::%t", authorized), func() (interface{}, error) {
-		singleton.SortedServerLock.RLock()
-		defer singleton.SortedServerLock.RUnlock()
-
-		var serverList []*model.Server
-		if authorized {
-			serverList = singleton.SortedServerList
-		} else {
-			serverList = singleton.SortedServerListForGuest
-		}
-
-		servers := make([]model.StreamServer, 0, len(serverList))
-		for _, server := range serverList {
-			var countryCode string
-			if server.GeoIP != nil {
-				countryCode = server.GeoIP.CountryCode
-			}
-			servers = append(servers, model.StreamServer{
-				ID:           server.ID,
-				Name:         server.Name,
-				PublicNote:   utils.IfOr(withPublicNote, server.PublicNote, ""),
-				DisplayIndex: server.DisplayIndex,
-				Host:         utils.IfOr(authorized, server.Host, server.Host.Filter()),
-				State:        server.State,
-				CountryCode:  countryCode,
-				LastActive:   server.LastActive,
-			})
-		}
-
-		return utils.Json.Marshal(model.StreamServerData{
-			Now:     time.Now().Unix() * 1000,
-			Online:  singleton.GetOnlineUserCount(),
-			Servers: servers,
+	// 成员与租户管理员只能看到可访问的服务器，为 0 时不限制
+	var viewer uint64
+	if isMember && !u.(*model.User).IsSuperAdmin() {
+		viewer = u.(*model.User).ID
+	}
+	key := fmt.Sprintf("serverStats::%t::%t::%d::%s", authorized, withPublicNote, viewer, filter.Key())
+	v, err, _ := requestGroup.Do(key, func() (interface{}, error) {
+		return singleton.CachedServerList(key, func() ([]byte, error) {
+			return marshalServerStat(authorized, withPublicNote, viewer, filter)
 		})
 	})
 
 	return v.([]byte), err
 }
+
+// marshalServerStat 生成推送数据，viewer 为 0 时不按用户筛选
+func marshalServerStat(authorized, withPublicNote bool, viewer uint64, filter *model.StreamServerFilter) ([]byte, error) {
+	singleton.SortedServerLock.RLock()
+	defer singleton.SortedServerLock.RUnlock()
+
+	var serverList []*model.Server
+	if authorized {
+		serverList = singleton.SortedServerList
+	} else {
+		serverList = singleton.SortedServerListForGuest
+	}
+
+	now := time.Now()
+	servers := make([]model.StreamServer, 0, len(serverList))
+	for _, server := range serverList {
+		if viewer != 0 && !singleton.ServerAccessibleBy(viewer, server) {
+			continue
+		}
+		groups := singleton.GetServerGroups(server.ID)
+		if !filter.Match(server, groups) {
+			continue
+		}
+		var countryCode string
+		if server.GeoIP != nil {
+			countryCode = server.GeoIP.CountryCode
+		}
+		ss := model.StreamServer{
+			ID:           server.ID,
+			Name:         server.Name,
+			PublicNote:   utils.IfOr(withPublicNote, server.PublicNote, ""),
+			DisplayIndex: server.DisplayIndex,
+			Host:         utils.IfOr(authorized, server.Host, server.Host.Filter()),
+			State:        server.State.Summary(),
+			CountryCode:  countryCode,
+			LastActive:   server.LastActive,
+			Groups:       groups,
+			Tags:         utils.IfOr(authorized, server.Tags, nil),
+		}
+		// 连接状态变化不频繁，只在第一个数据包与变化后的一段时间内推送
+		if conn := singleton.ServerConnectionOf(server.ID); conn != nil && (withPublicNote || now.Sub(conn.ChangedAt) < connectionPushWindow) {
+			ss.Connection = conn
+		}
+		if server.InMaintenance(now) {
+			ss.InMaintenance = true
+			ss.MaintenanceUntil = server.MaintenanceUntil
+			ss.MaintenanceReason = utils.IfOr(authorized, server.MaintenanceReason, "")
+		}
+		servers = append(servers, ss)
+	}
+
+	return utils.Json.Marshal(model.StreamServerData{
+		Now:     now.Unix() * 1000,
+		Online:  singleton.GetOnlineUserCount(),
+		Servers: servers,
+	})
+}
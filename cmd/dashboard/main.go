@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"embed"
 	"flag"
 	"fmt"
@@ -21,6 +22,7 @@ import (
 	"github.com/nezhahq/nezha/cmd/dashboard/controller"
 	"github.com/nezhahq/nezha/cmd/dashboard/rpc"
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/tlsx"
 	"github.com/nezhahq/nezha/proto"
 	"github.com/nezhahq/nezha/service/singleton"
 )
@@ -28,7 +30,7 @@ import (
 type DashboardCliParam struct {
 	Version          bool   // 当前版本号
 	ConfigFile       string // 配置文件路径
-	DatebaseLocation string // Sqlite3 数据库文件路径
+	DatebaseLocation string // Sqlite3 数据库文件路径，配置使用 PostgreSQL 时忽略
 }
 
 var (
@@ -60,8 +62,43 @@ func initSystem() {
 	// 启动 singleton 包下的所有服务
 	singleton.LoadSingleton()
 
-	// 每天的3:30 对 监控记录 和 流量记录 进行清理
-	if _, err := singleton.Cron.AddFunc("0 30 3 * * *", singleton.CleanServiceHistory); err != nil {
+	// 每小时的30分 对 监控记录 和 流量记录 进行清理，每次只需删除新过期的少量记录
+	if _, err := singleton.Cron.AddFunc("0 30 * * * *", singleton.CleanServiceHistory); err != nil {
+		panic(err)
+	}
+
+	// 每分钟清理到期的封禁
+	if _, err := singleton.Cron.AddFunc("0 * * * * *", singleton.CleanExpiredBlocks); err != nil {
+		panic(err)
+	}
+
+	// 每分钟结束到期的服务器维护模式
+	if _, err := singleton.Cron.AddFunc("0 * * * * *", singleton.ClearExpiredMaintenance); err != nil {
+		panic(err)
+	}
+
+	// 每小时彻底删除回收站中超过保留天数的服务器
+	if _, err := singleton.Cron.AddFunc("0 10 * * * *", singleton.PurgeTrashedServers); err != nil {
+		panic(err)
+	}
+
+	// 每10秒检查服务器在线状态变化
+	if _, err := singleton.Cron.AddFunc("*/10 * * * * *", singleton.CheckServerStates); err != nil {
+		panic(err)
+	}
+
+	// 每10秒推进断线服务器的重连状态
+	if _, err := singleton.Cron.AddFunc("*/10 * * * * *", singleton.CheckAgentConnections); err != nil {
+		panic(err)
+	}
+
+	// 每小时清理过期的刷新令牌
+	if _, err := singleton.Cron.AddFunc("0 20 * * * *", singleton.CleanExpiredRefreshTokens); err != nil {
+		panic(err)
+	}
+
+	// 每分钟撤销闲置超时会话的刷新令牌
+	if _, err := singleton.Cron.AddFunc("0 * * * * *", singleton.CleanIdleSessions); err != nil {
 		panic(err)
 	}
 
@@ -69,6 +106,11 @@ func initSystem() {
 	if _, err := singleton.Cron.AddFunc("0 0 * * * *", singleton.RecordTransferHourlyUsage); err != nil {
 		panic(err)
 	}
+
+	// 每分钟记录自定义指标
+	if _, err := singleton.Cron.AddFunc("0 * * * * *", singleton.RecordCustomMetrics); err != nil {
+		panic(err)
+	}
 }
 
 // @title           Nezha Monitoring API
@@ -95,7 +137,7 @@ func initSystem() {
 func main() {
 	flag.BoolVar(&dashboardCliParam.Version, "v", false, "查看当前版本号")
 	flag.StringVar(&dashboardCliParam.ConfigFile, "c", "data/config.yaml", "配置文件路径")
-	flag.StringVar(&dashboardCliParam.DatebaseLocation, "db", "data/sqlite.db", "Sqlite3数据库文件路径")
+	flag.StringVar(&dashboardCliParam.DatebaseLocation, "db", "data/sqlite.db", "Sqlite3数据库文件路径，配置使用 PostgreSQL 时忽略")
 	flag.Parse()
 
 	if dashboardCliParam.Version {
@@ -130,19 +172,82 @@ func main() {
 	http2Server := &http2.Server{}
 	muxServer := &http.Server{Handler: h2c.NewHandler(muxHandler, http2Server), ReadHeaderTimeout: time.Second * 5}
 
+	var agentServer *http.Server
+	var agentListener net.Listener
+	if singleton.Conf.AgentTLS.Enabled() {
+		agentServer, agentListener, err = newAgentTLSServer(grpcHandler)
+		if err != nil {
+			log.Fatalf("NEZHA>> 启动 Agent mTLS 监听失败: %v", err)
+		}
+	}
+	singleton.SetReady(true)
+
+	graceful.DefaultShutdownTimeout = time.Duration(singleton.Conf.ShutdownTimeout) * time.Second
 	if err := graceful.Graceful(func() error {
+		if agentServer != nil {
+			go func() {
+				log.Printf("NEZHA>> Agent mTLS::START ON %s", agentListener.Addr())
+				if err := agentServer.Serve(agentListener); err != nil && err != http.ErrServerClosed {
+					log.Printf("NEZHA>> Agent mTLS: %v", err)
+				}
+			}()
+		}
 		log.Printf("NEZHA>> Dashboard::START ON %s:%d", singleton.Conf.ListenHost, singleton.Conf.ListenPort)
+		singleton.SetAgentListenerUp(true)
+		defer singleton.SetAgentListenerUp(false)
 		return muxServer.Serve(l)
 	}, func(c context.Context) error {
 		log.Println("NEZHA>> Graceful::START")
-		singleton.RecordTransferHourlyUsage()
+		singleton.SetReady(false)
+		// 先停止报警检测并写入未持久化的数据，避免关闭连接后产生大量离线报警
+		singleton.Shutdown(c)
+		log.Printf("NEZHA>> Graceful::关闭 %d 个 WebSocket 连接", controller.CloseWebSockets())
+		if agentServer != nil {
+			agentServer.Shutdown(c)
+		}
+		err := muxServer.Shutdown(c)
+		grpcHandler.Stop()
 		log.Println("NEZHA>> Graceful::END")
-		return muxServer.Shutdown(c)
+		return err
 	}); err != nil {
 		log.Printf("NEZHA>> ERROR: %v", err)
 	}
 }
 
+// newAgentTLSServer 在单独的端口上为 Agent 提供 mTLS 连接，只处理 gRPC 请求
+func newAgentTLSServer(grpcHandler http.Handler) (*http.Server, net.Listener, error) {
+	conf := singleton.Conf.AgentTLS
+	clientAuth := tls.RequireAndVerifyClientCert
+	if conf.AllowSecretFallback {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	reloader, err := tlsx.NewReloader(conf.CertFile, conf.KeyFile, conf.ClientCAFile, clientAuth)
+	if err != nil {
+		return nil, nil, err
+	}
+	l, err := tls.Listen("tcp", fmt.Sprintf("%s:%d", singleton.Conf.ListenHost, conf.ListenPort), reloader.TLSConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor == 2 && r.Header.Get("Content-Type") == "application/grpc" &&
+				strings.HasPrefix(r.URL.Path, "/"+proto.NezhaService_ServiceDesc.ServiceName) {
+				grpcHandler.ServeHTTP(w, r)
+				return
+			}
+			http.NotFound(w, r)
+		}),
+		ReadHeaderTimeout: time.Second * 5,
+	}
+	if err := http2.ConfigureServer(server, &http2.Server{}); err != nil {
+		l.Close()
+		return nil, nil, err
+	}
+	return server, l, nil
+}
+
 func newHTTPandGRPCMux(httpHandler http.Handler, grpcHandler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		natConfig := singleton.GetNATConfigByDomain(r.Host)
@@ -32,6 +32,9 @@ func waf(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handl
 	if err := model.CheckIP(singleton.DB, realip); err != nil {
 		return nil, err
 	}
+	if err := singleton.CheckIPBlocked(realip); err != nil {
+		return nil, err
+	}
 	return handler(ctx, req)
 }
 
@@ -55,13 +58,16 @@ func getRealIp(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
 			return nil, fmt.Errorf("connecting ip not found")
 		}
 	} else {
-		vals := metadata.ValueFromIncomingContext(ctx, singleton.Conf.RealIPHeader)
-		if len(vals) == 0 {
-			return nil, fmt.Errorf("real ip header not found")
+		var header string
+		if vals := metadata.ValueFromIncomingContext(ctx, singleton.Conf.RealIPHeader); len(vals) > 0 {
+			header = vals[0]
 		}
 		var err error
-		ip, err = utils.GetIPFromHeader(vals[0])
+		ip, err = singleton.ClientIP(header, connectingIp)
 		if err != nil {
+			if header == "" {
+				return nil, fmt.Errorf("real ip header not found")
+			}
 			return nil, err
 		}
 	}
@@ -77,6 +83,10 @@ func getRealIp(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
 func DispatchTask(serviceSentinelDispatchBus <-chan model.Service) {
 	workedServerIndex := 0
 	for task := range serviceSentinelDispatchBus {
+		if task.ProbedByDashboard() {
+			go singleton.ServiceSentinelShared.Probe(task)
+			continue
+		}
 		round := 0
 		endIndex := workedServerIndex
 		singleton.SortedServerLock.RLock()
@@ -101,15 +111,8 @@ func DispatchTask(serviceSentinelDispatchBus <-chan model.Service) {
 			}
 			if task.Cover == model.ServiceCoverIgnoreAll && task.SkipServers[singleton.SortedServerList[workedServerIndex].ID] {
 				server := singleton.SortedServerList[workedServerIndex]
-				singleton.UserLock.RLock()
-				var role uint8
-				if u, ok := singleton.UserInfoMap[server.UserID]; !ok {
-					role = model.RoleMember
-				} else {
-					role = u.Role
-				}
-				singleton.UserLock.RUnlock()
-				if task.UserID == server.UserID || role == model.RoleAdmin {
+				// 管理员的服务器可以执行所管理租户内其他用户的监控任务
+				if task.UserID == server.UserID || singleton.UserAdministers(server.UserID, task.UserID) {
 					singleton.SortedServerList[workedServerIndex].TaskStream.Send(task.PB())
 				}
 				workedServerIndex++
@@ -117,15 +120,8 @@ func DispatchTask(serviceSentinelDispatchBus <-chan model.Service) {
 			}
 			if task.Cover == model.ServiceCoverAll && !task.SkipServers[singleton.SortedServerList[workedServerIndex].ID] {
 				server := singleton.SortedServerList[workedServerIndex]
-				singleton.UserLock.RLock()
-				var role uint8
-				if u, ok := singleton.UserInfoMap[server.UserID]; !ok {
-					role = model.RoleMember
-				} else {
-					role = u.Role
-				}
-				singleton.UserLock.RUnlock()
-				if task.UserID == server.UserID || role == model.RoleAdmin {
+				// 管理员的服务器可以执行所管理租户内其他用户的监控任务
+				if task.UserID == server.UserID || singleton.UserAdministers(server.UserID, task.UserID) {
 					singleton.SortedServerList[workedServerIndex].TaskStream.Send(task.PB())
 				}
 				workedServerIndex++
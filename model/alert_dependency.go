@@ -0,0 +1,41 @@
+package model
+
+// AlertDependencyCycle 判断规则 id 以 parentID 为父规则时是否形成循环依赖，
+// parents 为其他报警规则的父规则，新建的规则 id 为 0
+func AlertDependencyCycle(id, parentID uint64, parents map[uint64]uint64) bool {
+	seen := map[uint64]bool{id: true}
+	for p := parentID; p != 0; p = parents[p] {
+		if seen[p] {
+			return true
+		}
+		seen[p] = true
+	}
+	return false
+}
+
+// SortAlertRulesByDependency 返回父规则排在子规则之前的报警规则，其余规则保持原有顺序。
+// 父规则不存在时忽略依赖
+func SortAlertRulesByDependency(rules []*AlertRule) []*AlertRule {
+	byID := make(map[uint64]*AlertRule, len(rules))
+	for _, r := range rules {
+		byID[r.ID] = r
+	}
+	sorted := make([]*AlertRule, 0, len(rules))
+	visited := make(map[uint64]bool, len(rules))
+	var visit func(r *AlertRule)
+	visit = func(r *AlertRule) {
+		if visited[r.ID] {
+			return
+		}
+		// 先标记再访问父规则，即使存在循环依赖也能结束
+		visited[r.ID] = true
+		if p, ok := byID[r.ParentID]; ok {
+			visit(p)
+		}
+		sorted = append(sorted, r)
+	}
+	for _, r := range rules {
+		visit(r)
+	}
+	return sorted
+}
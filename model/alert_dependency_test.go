@@ -0,0 +1,46 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAlertDependencyCycle(t *testing.T) {
+	// 3 -> 2 -> 1
+	parents := map[uint64]uint64{2: 1, 3: 2}
+	cases := []struct {
+		id, parent uint64
+		cycle      bool
+	}{
+		{0, 3, false},
+		{4, 3, false},
+		{1, 0, false},
+		{1, 1, true},
+		{1, 3, true},
+		{1, 2, true},
+		{2, 3, true},
+		{3, 1, false},
+	}
+	for _, c := range cases {
+		if got := AlertDependencyCycle(c.id, c.parent, parents); got != c.cycle {
+			t.Errorf("rule %d with parent %d: got cycle %v", c.id, c.parent, got)
+		}
+	}
+}
+
+func TestSortAlertRulesByDependency(t *testing.T) {
+	rule := func(id, parent uint64) *AlertRule {
+		r := &AlertRule{ParentID: parent}
+		r.ID = id
+		return r
+	}
+	rules := []*AlertRule{rule(1, 3), rule(2, 0), rule(3, 4), rule(4, 0), rule(5, 9), rule(6, 7), rule(7, 6)}
+
+	var got []uint64
+	for _, r := range SortAlertRulesByDependency(rules) {
+		got = append(got, r.ID)
+	}
+	if want := []uint64{4, 3, 1, 2, 5, 7, 6}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
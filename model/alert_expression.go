@@ -0,0 +1,210 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	alertExprRef = iota
+	alertExprAnd
+	alertExprOr
+	alertExprNot
+)
+
+// AlertExpression 组合报警条件的表达式树，叶子节点为报警规则中条件的序号
+type AlertExpression struct {
+	op       int
+	ref      int // 从 0 开始的条件下标
+	children []*AlertExpression
+}
+
+// Eval 传入各条件是否处于报警状态，返回组合后的报警状态
+func (e *AlertExpression) Eval(failing []bool) bool {
+	switch e.op {
+	case alertExprAnd:
+		for _, c := range e.children {
+			if !c.Eval(failing) {
+				return false
+			}
+		}
+		return true
+	case alertExprOr:
+		for _, c := range e.children {
+			if c.Eval(failing) {
+				return true
+			}
+		}
+		return false
+	case alertExprNot:
+		return !e.children[0].Eval(failing)
+	}
+	return failing[e.ref]
+}
+
+// Refs 返回表达式引用的全部条件下标
+func (e *AlertExpression) Refs() []int {
+	if e.op == alertExprRef {
+		return []int{e.ref}
+	}
+	var refs []int
+	for _, c := range e.children {
+		refs = append(refs, c.Refs()...)
+	}
+	return refs
+}
+
+type alertExprToken struct {
+	text string
+	pos  int // 从 1 开始的字符位置，用于错误提示
+}
+
+// ParseAlertExpression 解析组合报警表达式。
+//
+// 操作数为条件在 rules 中从 1 开始的序号，表示该条件处于报警状态；
+// 支持 AND / OR / NOT（不区分大小写，也可写作 && / || / !）与括号，优先级 NOT > AND > OR。
+// 例如 "(1 AND 2) OR 3" 表示条件 1、2 同时报警，或条件 3 报警时触发。
+func ParseAlertExpression(s string, ruleCount int) (*AlertExpression, error) {
+	tokens, err := tokenizeAlertExpression(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	p := &alertExprParser{tokens: tokens, ruleCount: ruleCount}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+	return e, nil
+}
+
+func tokenizeAlertExpression(s string) ([]alertExprToken, error) {
+	var tokens []alertExprToken
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '!':
+			tokens = append(tokens, alertExprToken{text: string(r), pos: i + 1})
+			i++
+		case r == '&' || r == '|':
+			if i+1 >= len(runes) || runes[i+1] != r {
+				return nil, fmt.Errorf("unexpected %q at position %d", string(r), i+1)
+			}
+			tokens = append(tokens, alertExprToken{text: string([]rune{r, r}), pos: i + 1})
+			i += 2
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, alertExprToken{text: string(runes[start:i]), pos: start + 1})
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", string(r), i+1)
+		}
+	}
+	return tokens, nil
+}
+
+type alertExprParser struct {
+	tokens    []alertExprToken
+	idx       int
+	ruleCount int
+}
+
+func (p *alertExprParser) peek() *alertExprToken {
+	if p.idx >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.idx]
+}
+
+// accept 下一个记号为给定的任一运算符时消费它
+func (p *alertExprParser) accept(ops ...string) bool {
+	t := p.peek()
+	if t == nil {
+		return false
+	}
+	for _, op := range ops {
+		if strings.EqualFold(t.text, op) {
+			p.idx++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *alertExprParser) parseOr() (*AlertExpression, error) {
+	return p.parseBinary(alertExprOr, p.parseAnd, "OR", "||")
+}
+
+func (p *alertExprParser) parseAnd() (*AlertExpression, error) {
+	return p.parseBinary(alertExprAnd, p.parseNot, "AND", "&&")
+}
+
+func (p *alertExprParser) parseBinary(op int, next func() (*AlertExpression, error), ops ...string) (*AlertExpression, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	children := []*AlertExpression{left}
+	for p.accept(ops...) {
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &AlertExpression{op: op, children: children}, nil
+}
+
+func (p *alertExprParser) parseNot() (*AlertExpression, error) {
+	if p.accept("NOT", "!") {
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &AlertExpression{op: alertExprNot, children: []*AlertExpression{child}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *alertExprParser) parsePrimary() (*AlertExpression, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	if t.text == "(" {
+		p.idx++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ')' for '(' at position %d", t.pos)
+		}
+		return e, nil
+	}
+
+	n, err := strconv.Atoi(t.text)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected %q at position %d, expected a rule number", t.text, t.pos)
+	}
+	if n < 1 || n > p.ruleCount {
+		return nil, fmt.Errorf("rule %d at position %d does not exist, there are %d rules", n, t.pos, p.ruleCount)
+	}
+	p.idx++
+	return &AlertExpression{op: alertExprRef, ref: n - 1}, nil
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAlertExpression(t *testing.T) {
+	cases := []struct {
+		expr    string
+		failing []bool
+		want    bool
+	}{
+		{"1 AND 2", []bool{true, true, false}, true},
+		{"1 and 2", []bool{true, false, false}, false},
+		{"(1 && 2) || 3", []bool{false, false, true}, true},
+		{"1 OR 2 AND 3", []bool{true, false, false}, true},
+		{"NOT 1 AND 2", []bool{false, true, false}, true},
+		{"!(1 || 2)", []bool{false, true, false}, false},
+	}
+	for _, c := range cases {
+		e, err := ParseAlertExpression(c.expr, 3)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.expr, err)
+		}
+		if got := e.Eval(c.failing); got != c.want {
+			t.Fatalf("%s: expected %v, got %v", c.expr, c.want, got)
+		}
+	}
+
+	errCases := map[string]string{
+		"":          "empty",
+		"1 AND":     "unexpected end",
+		"(1 OR 2":   "missing ')'",
+		"1 2":       `unexpected "2" at position 3`,
+		"4":         "rule 4 at position 1 does not exist",
+		"1 & 2":     `unexpected "&" at position 3`,
+		"cpu AND 1": `unexpected "cpu" at position 1`,
+	}
+	for expr, want := range errCases {
+		if _, err := ParseAlertExpression(expr, 3); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected error containing %q, got %v", expr, want, err)
+		}
+	}
+}
+
+func TestAlertRuleCheckExpression(t *testing.T) {
+	r := &AlertRule{
+		Rules:      []*Rule{{Type: "cpu", Duration: 3}, {Type: "memory", Duration: 3}},
+		Expression: "1 OR 2",
+	}
+	// 每个采样点为各条件是否通过
+	points := [][]bool{{true, false}, {true, false}}
+	if _, passed := r.Check(points); !passed {
+		t.Fatal("expected pass while samples are insufficient")
+	}
+	points = append(points, []bool{true, false})
+	if _, passed := r.Check(points); passed {
+		t.Fatal("expected OR expression to fire when one condition fails")
+	}
+
+	r.Expression = ""
+	if _, passed := r.Check(points); !passed {
+		t.Fatal("expected rule without expression to require all conditions to fail")
+	}
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// AlertIncident 报警规则在一台服务器上的一次报警，触发与恢复通知通过 ID 关联
+type AlertIncident struct {
+	ID         string
+	StartedAt  time.Time
+	ResolvedAt time.Time
+	Peaks      map[int]float64 // [rule_index] -> 报警期间观测到的最严重的值
+}
+
+func NewAlertIncident(alertID, serverID uint64, now time.Time) *AlertIncident {
+	return &AlertIncident{
+		ID:        fmt.Sprintf("%d-%d-%d", alertID, serverID, now.Unix()),
+		StartedAt: now,
+		Peaks:     make(map[int]float64),
+	}
+}
+
+// Observe 记录报警规则各条件在该服务器上最近一次的值，
+// 只设置了下限的条件记录最小值，其余记录最大值
+func (i *AlertIncident) Observe(r *AlertRule, serverID uint64) {
+	for idx, rule := range r.Rules {
+		v, ok := rule.ObservedValue(serverID)
+		if !ok {
+			continue
+		}
+		peak, seen := i.Peaks[idx]
+		lower := rule.Max == 0 && rule.Min > 0
+		if !seen || (lower && v < peak) || (!lower && v > peak) {
+			i.Peaks[idx] = v
+		}
+	}
+}
+
+// Duration 返回报警持续的时长，未恢复时计算到 now
+func (i *AlertIncident) Duration(now time.Time) time.Duration {
+	end := i.ResolvedAt
+	if end.IsZero() {
+		end = now
+	}
+	return end.Sub(i.StartedAt).Truncate(time.Second)
+}
+
+// DescribePeak 返回各条件的峰值，如 cpu 95.20, memory +12.50% in 30m
+func (i *AlertIncident) DescribePeak(r *AlertRule) string {
+	var peaks []string
+	for idx, rule := range r.Rules {
+		v, ok := i.Peaks[idx]
+		if !ok {
+			continue
+		}
+		metric := rule.Type
+		if rule.Target != "" {
+			metric += "[" + rule.Target + "]"
+		}
+		if rule.IsChangeRule() {
+			unit := utils.IfOr(rule.Condition == RuleConditionRate, "%", "")
+			peaks = append(peaks, fmt.Sprintf("%s %+.2f%s in %dm", metric, v, unit, rule.Window))
+		} else {
+			peaks = append(peaks, fmt.Sprintf("%s %.2f", metric, v))
+		}
+	}
+	return strings.Join(peaks, ", ")
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertIncidentPeak(t *testing.T) {
+	r := &AlertRule{Rules: []*Rule{
+		{Type: "cpu", Max: 80},
+		{Type: "memory", Min: 10},
+		{Type: "offline", Duration: 3},
+	}}
+	start := time.Unix(1700000000, 0)
+	inc := NewAlertIncident(3, 7, start)
+	if inc.ID != "3-7-1700000000" {
+		t.Fatalf("unexpected incident id: %s", inc.ID)
+	}
+
+	for _, v := range [][2]float64{{85, 8}, {97.5, 5}, {90, 9}} {
+		r.Rules[0].observeValue(7, v[0])
+		r.Rules[1].observeValue(7, v[1])
+		inc.Observe(r, 7)
+	}
+	if got := inc.DescribePeak(r); got != "cpu 97.50, memory 5.00" {
+		t.Fatalf("unexpected peak: %s", got)
+	}
+
+	if d := inc.Duration(start.Add(90*time.Second + time.Millisecond)); d != 90*time.Second {
+		t.Fatalf("unexpected duration: %s", d)
+	}
+	inc.ResolvedAt = start.Add(time.Minute)
+	if d := inc.Duration(start.Add(time.Hour)); d != time.Minute {
+		t.Fatalf("resolved incident should stop at ResolvedAt, got %s", d)
+	}
+
+	ns := NotificationServerBundle{
+		Notification: &Notification{Template: "#ALERT.INCIDENT# #ALERT.DURATION# #ALERT.PEAK#"},
+		Alert:        r.WithIncident(inc),
+		Loc:          time.UTC,
+	}
+	if got := ns.render(msg); got != "3-7-1700000000 1m0s cpu 97.50, memory 5.00" {
+		t.Fatalf("unexpected render result: %s", got)
+	}
+}
+
+func TestAlertRuleNotifiesResolved(t *testing.T) {
+	r := &AlertRule{}
+	if !r.NotifiesResolved() {
+		t.Fatal("resolved notifications should be enabled by default")
+	}
+	disabled := false
+	r.NotifyResolved = &disabled
+	if r.NotifiesResolved() {
+		t.Fatal("resolved notifications should be disabled")
+	}
+}
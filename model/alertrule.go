@@ -1,6 +1,11 @@
 package model
 
 import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
 	"github.com/nezhahq/nezha/pkg/utils"
 	"gorm.io/gorm"
 )
@@ -10,6 +15,17 @@ const (
 	ModeOnetimeTrigger = 1
 )
 
+// 报警的严重程度，通知方式可只接收部分严重程度的报警
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+func ValidSeverity(s string) bool {
+	return s == SeverityInfo || s == SeverityWarning || s == SeverityCritical
+}
+
 type AlertRule struct {
 	Common
 	Name                   string   `json:"name"`
@@ -22,6 +38,28 @@ type AlertRule struct {
 	Rules                  []*Rule  `gorm:"-" json:"rules"`
 	FailTriggerTasks       []uint64 `gorm:"-" json:"fail_trigger_tasks"`    // 失败时执行的触发任务id
 	RecoverTriggerTasks    []uint64 `gorm:"-" json:"recover_trigger_tasks"` // 恢复时执行的触发任务id
+	TagsRaw                string   `gorm:"default:'[]'" json:"-"`
+	Tags                   []string `gorm:"-" json:"tags,omitempty"` // 仅检查带有任一标签的服务器，为空时检查全部
+	Debounce               uint64   `json:"debounce,omitempty"`      // 状态变化需持续的检查次数，0 或 1 表示立即通知
+	Cooldown               uint64   `json:"cooldown,omitempty"`      // 同一服务器两次通知的最短间隔 (秒)
+	// 报警升级策略，报警持续未恢复时逐级追加通知
+	EscalationPolicyID uint64 `json:"escalation_policy_id,omitempty"`
+	// 组合条件表达式，以序号引用 Rules 中的条件，为空时所有条件均报警才触发
+	Expression string `json:"expression,omitempty"`
+	// 严重程度，触发与恢复通知只发送给接收该严重程度的通知方式
+	Severity string `gorm:"default:'warning'" json:"severity,omitempty" enums:"info,warning,critical"`
+	// 是否发送恢复通知，未设置时发送
+	NotifyResolved *bool `gorm:"default:true" json:"notify_resolved,omitempty"`
+	// 依赖的父报警规则，父规则在同一服务器上检查未通过时不发送本规则的报警
+	ParentID uint64 `json:"parent_id,omitempty"`
+
+	// 触发时各变化条件计算出的变化，供通知模板使用，见 WithRate
+	Rate string `gorm:"-" json:"-"`
+	// 通知所属的报警事件，供通知模板使用，见 WithIncident
+	Incident *AlertIncident `gorm:"-" json:"-"`
+
+	expr       *AlertExpression
+	exprSource string
 }
 
 func (r *AlertRule) BeforeSave(tx *gorm.DB) error {
@@ -40,6 +78,11 @@ func (r *AlertRule) BeforeSave(tx *gorm.DB) error {
 	} else {
 		r.RecoverTriggerTasksRaw = string(data)
 	}
+	if data, err := utils.Json.Marshal(r.Tags); err != nil {
+		return err
+	} else {
+		r.TagsRaw = string(data)
+	}
 	return nil
 }
 
@@ -54,9 +97,118 @@ func (r *AlertRule) AfterFind(tx *gorm.DB) error {
 	if err = utils.Json.Unmarshal([]byte(r.RecoverTriggerTasksRaw), &r.RecoverTriggerTasks); err != nil {
 		return err
 	}
+	if r.TagsRaw != "" {
+		if err = utils.Json.Unmarshal([]byte(r.TagsRaw), &r.Tags); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Targets 报警规则是否作用于该服务器
+func (r *AlertRule) Targets(server *Server) bool {
+	if len(r.Tags) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.Tags, server.HasTag)
+}
+
+// Describe 返回规则涉及的指标与阈值，供通知模板使用
+func (r *AlertRule) Describe() (metrics, thresholds string) {
+	var m, t []string
+	for _, rule := range r.Rules {
+		metric := rule.Type
+		if rule.Target != "" {
+			metric += "[" + rule.Target + "]"
+		}
+		m = append(m, metric)
+		var bounds []string
+		if rule.IsChangeRule() {
+			unit := utils.IfOr(rule.Condition == RuleConditionRate, "%", "")
+			if rule.Min > 0 {
+				bounds = append(bounds, fmt.Sprintf("-%g%s", rule.Min, unit))
+			}
+			if rule.Max > 0 {
+				bounds = append(bounds, fmt.Sprintf("+%g%s", rule.Max, unit))
+			}
+			if len(bounds) > 0 {
+				t = append(t, fmt.Sprintf("%s %s %s in %dm", metric, rule.Condition, strings.Join(bounds, " "), rule.Window))
+			}
+			continue
+		}
+		if rule.Min > 0 {
+			bounds = append(bounds, fmt.Sprintf("min %g", rule.Min))
+		}
+		if rule.Max > 0 {
+			bounds = append(bounds, fmt.Sprintf("max %g", rule.Max))
+		}
+		if len(bounds) > 0 {
+			t = append(t, metric+" "+strings.Join(bounds, " "))
+		}
+	}
+	return strings.Join(m, ", "), strings.Join(t, ", ")
+}
+
+// DedupKey 通知去重的键：通知组、服务器、涉及的指标（去重排序后）与报警或恢复。
+// 报警规则不同但键相同的通知视为同一事件，指标或服务器不同的通知不会合并。
+func (r *AlertRule) DedupKey(serverID uint64, resolved bool) string {
+	var metrics []string
+	for _, rule := range r.Rules {
+		metric := rule.Type
+		if rule.Target != "" {
+			metric += "[" + rule.Target + "]"
+		}
+		metrics = append(metrics, metric)
+	}
+	slices.Sort(metrics)
+	severity := "incident"
+	if resolved {
+		severity = "resolved"
+	}
+	return fmt.Sprintf("%d|%d|%s|%s", r.NotificationGroupID, serverID, strings.Join(slices.Compact(metrics), ","), severity)
+}
+
+// WithRate 返回附带各变化条件在该服务器上最近一次计算结果的副本，
+// 通知在其他 goroutine 中发送，不能直接读取报警器持续更新的采样
+func (r *AlertRule) WithRate(serverID uint64) *AlertRule {
+	var rates []string
+	for _, rule := range r.Rules {
+		if desc, ok := rule.DescribeChange(serverID); ok {
+			rates = append(rates, desc)
+		}
+	}
+	if len(rates) == 0 {
+		return r
+	}
+	c := *r
+	c.Rate = strings.Join(rates, ", ")
+	return &c
+}
+
+// WithIncident 返回附带报警事件快照的副本
+func (r *AlertRule) WithIncident(incident *AlertIncident) *AlertRule {
+	c := *r
+	if incident != nil {
+		inc := *incident
+		inc.Peaks = maps.Clone(incident.Peaks)
+		c.Incident = &inc
+	}
+	return &c
+}
+
+// NotifiesResolved 是否在报警恢复时发送通知
+func (r *AlertRule) NotifiesResolved() bool {
+	return r.NotifyResolved == nil || *r.NotifyResolved
+}
+
+// GetSeverity 返回报警的严重程度，未设置时为 warning
+func (r *AlertRule) GetSeverity() string {
+	if r.Severity == "" {
+		return SeverityWarning
+	}
+	return r.Severity
+}
+
 func (r *AlertRule) Enabled() bool {
 	return r.Enable != nil && *r.Enable
 }
@@ -77,40 +229,18 @@ func (r *AlertRule) Snapshot(cycleTransferStats *CycleTransferStats, server *Ser
 
 // Check 传入包含当前报警规则下所有type检查结果 返回报警持续时间与是否通过报警检查(通过则返回true)
 func (r *AlertRule) Check(points [][]bool) (maxDuration int, passed bool) {
+	if r.Expression != "" {
+		return r.checkExpression(points)
+	}
+
 	failCount := 0 // 检查未通过的个数
 
 	for i, rule := range r.Rules {
-		if rule.IsTransferDurationRule() {
-			// 循环区间流量报警
-			if maxDuration < 1 {
-				maxDuration = 1
-			}
-			for j := len(points[i]) - 1; j >= 0; j-- {
-				if !points[i][j] {
-					failCount++
-					break
-				}
-			}
-		} else {
-			// 常规报警
-			duration := int(rule.Duration)
-			if duration > maxDuration {
-				maxDuration = duration
-			}
-			if len(points) < duration {
-				continue
-			}
-
-			total, fail := 0.0, 0.0
-			for j := len(points) - duration; j < len(points); j++ {
-				total++
-				if !points[j][i] {
-					fail++
-				}
-			}
-			// 当70%以上的采样点未通过规则判断时 才认为当前检查未通过
-			if fail/total > 0.7 {
-				failCount++
+		duration, failing, _ := rule.check(i, points)
+		maxDuration = max(maxDuration, duration)
+		if failing {
+			failCount++
+			if !rule.IsTransferDurationRule() {
 				break
 			}
 		}
@@ -118,3 +248,57 @@ func (r *AlertRule) Check(points [][]bool) (maxDuration int, passed bool) {
 	// 仅当所有检查均未通过时 返回false
 	return maxDuration, failCount != len(r.Rules)
 }
+
+// checkExpression 按组合表达式判断各条件，被引用的条件采样不足时视为通过
+func (r *AlertRule) checkExpression(points [][]bool) (maxDuration int, passed bool) {
+	if r.expr == nil || r.exprSource != r.Expression {
+		expr, err := ParseAlertExpression(r.Expression, len(r.Rules))
+		if err != nil {
+			return 0, true
+		}
+		r.expr, r.exprSource = expr, r.Expression
+	}
+
+	failing := make([]bool, len(r.Rules))
+	ready := make([]bool, len(r.Rules))
+	for i, rule := range r.Rules {
+		var duration int
+		duration, failing[i], ready[i] = rule.check(i, points)
+		maxDuration = max(maxDuration, duration)
+	}
+	for _, i := range r.expr.Refs() {
+		if !ready[i] {
+			return maxDuration, true
+		}
+	}
+	return maxDuration, !r.expr.Eval(failing)
+}
+
+// check 判断第 i 个条件是否处于报警状态，返回需保留的采样数与采样是否充足
+func (u *Rule) check(i int, points [][]bool) (duration int, failing, ready bool) {
+	if u.IsTransferDurationRule() {
+		// 循环区间流量报警
+		for j := len(points[i]) - 1; j >= 0; j-- {
+			if !points[i][j] {
+				return 1, true, true
+			}
+		}
+		return 1, false, true
+	}
+
+	// 常规报警
+	duration = int(u.Duration)
+	if len(points) < duration {
+		return duration, false, false
+	}
+
+	total, fail := 0.0, 0.0
+	for j := len(points) - duration; j < len(points); j++ {
+		total++
+		if !points[j][i] {
+			fail++
+		}
+	}
+	// 当70%以上的采样点未通过规则判断时 才认为当前检查未通过
+	return duration, fail/total > 0.7, true
+}
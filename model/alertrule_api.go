@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type AlertRuleForm struct {
 	Name                string   `json:"name" minLength:"1"`
 	Rules               []*Rule  `json:"rules"`
@@ -8,4 +10,52 @@ type AlertRuleForm struct {
 	NotificationGroupID uint64   `json:"notification_group_id"`
 	TriggerMode         uint8    `json:"trigger_mode" default:"0"`
 	Enable              bool     `json:"enable" validate:"optional"`
+	Tags                []string `json:"tags,omitempty" validate:"optional"`     // 仅检查带有任一标签的服务器
+	Debounce            uint64   `json:"debounce,omitempty" validate:"optional"` // 状态变化需持续的检查次数
+	Cooldown            uint64   `json:"cooldown,omitempty" validate:"optional"` // 同一服务器两次通知的最短间隔 (秒)
+	EscalationPolicyID  uint64   `json:"escalation_policy_id,omitempty" validate:"optional"`
+	Expression          string   `json:"expression,omitempty" validate:"optional"`                             // 组合条件，如 (1 AND 2) OR 3
+	Severity            string   `json:"severity,omitempty" enums:"info,warning,critical" validate:"optional"` // 默认 warning
+	NotifyResolved      *bool    `json:"notify_resolved,omitempty" validate:"optional"`                        // 是否发送恢复通知，默认发送
+	ParentID            uint64   `json:"parent_id,omitempty" validate:"optional"`                              // 父规则检查未通过时抑制本规则的报警
+}
+
+type AlertRuleToggleForm struct {
+	IDs    []uint64 `json:"ids"`
+	Enable bool     `json:"enable"`
+}
+
+const (
+	AlertSuppressionPending  = "pending"
+	AlertSuppressionCooldown = "cooldown"
+	// 被父报警规则抑制
+	AlertSuppressionParent = "parent"
+)
+
+// AlertSuppression 报警规则在某台服务器上被防抖或冷却抑制的状态
+type AlertSuppression struct {
+	AlertID        uint64    `json:"alert_id"`
+	ServerID       uint64    `json:"server_id"`
+	State          string    `json:"state" enums:"pending,cooldown,parent"`
+	Failing        bool      `json:"failing"`                   // 当前检查结果是否为失败
+	PendingCycles  uint64    `json:"pending_cycles,omitempty"`  // 新状态已持续的检查次数
+	RequiredCycles uint64    `json:"required_cycles,omitempty"` // 触发通知所需的检查次数
+	CooldownUntil  time.Time `json:"cooldown_until,omitempty"`
+	ParentID       uint64    `json:"parent_id,omitempty"` // 抑制本规则的父规则
+}
+
+type AlertRuleTestForm struct {
+	ServerID uint64 `json:"server_id,omitempty" validate:"optional"` // 用于填充模板的服务器，默认为第一台有权限的服务器
+	Resolved bool   `json:"resolved,omitempty" validate:"optional"`  // 是否模拟恢复通知
+}
+
+// NotificationDeliveryResult 通知方式某个接收方的发送结果
+type NotificationDeliveryResult struct {
+	NotificationID   uint64 `json:"notification_id"`
+	NotificationName string `json:"notification_name"`
+	RecipientID      uint64 `json:"recipient_id,omitempty"` // 0 表示主接收方
+	Success          bool   `json:"success"`
+	StatusCode       int    `json:"status_code,omitempty"`
+	DeliveryStatus   string `json:"delivery_status,omitempty"` // 服务商返回的投递状态
+	Error            string `json:"error,omitempty"`
 }
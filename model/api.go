@@ -1,5 +1,12 @@
 package model
 
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
 const (
 	ApiErrorUnauthorized = 10001
 )
@@ -7,6 +14,8 @@ const (
 type LoginRequest struct {
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
+	// 启用两步验证的用户需提供 TOTP 验证码或恢复码
+	OTP string `json:"otp,omitempty"`
 }
 
 type CommonResponse[T any] struct {
@@ -30,9 +39,48 @@ type Pagination struct {
 	Offset int   `json:"offset,omitempty"`
 	Limit  int   `json:"limit,omitempty"`
 	Total  int64 `json:"total,omitempty"`
+	// 游标分页时下一页的游标，没有更多数据时为空
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Cursor 游标分页的位置，即上一页最后一条记录的排序键，编码后对客户端不透明
+type Cursor struct {
+	Key int64  `json:"k,omitempty"`
+	ID  string `json:"i"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func (c *Cursor) Encode() string {
+	data, _ := utils.Json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor 解析游标，空字符串表示第一页，返回 nil
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := utils.Json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
 }
 
 type LoginResponse struct {
 	Token  string `json:"token,omitempty"`
 	Expire string `json:"expire,omitempty"`
+	// 刷新令牌，用于在访问令牌过期后通过 /auth/refresh 换取新的令牌对
+	RefreshToken  string `json:"refresh_token,omitempty"`
+	RefreshExpire string `json:"refresh_expire,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	// 为空时从 Cookie 中读取
+	RefreshToken string `json:"refresh_token,omitempty" validate:"optional"`
 }
@@ -0,0 +1,20 @@
+package model
+
+import "testing"
+
+func TestCursor(t *testing.T) {
+	c := &Cursor{Key: 1700000000000000000, ID: "a1b2"}
+	got, err := DecodeCursor(c.Encode())
+	if err != nil || *got != *c {
+		t.Fatalf("got %v, %v, want %v", got, err, c)
+	}
+
+	if got, err := DecodeCursor(""); got != nil || err != nil {
+		t.Errorf("empty cursor: got %v, %v", got, err)
+	}
+	for _, s := range []string{"!!", "bm90LWpzb24", "e30"} {
+		if _, err := DecodeCursor(s); err != ErrInvalidCursor {
+			t.Errorf("%q: got %v, want ErrInvalidCursor", s, err)
+		}
+	}
+}
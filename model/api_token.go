@@ -0,0 +1,32 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	ApiTokenHeader = "X-Api-Token"
+	ApiTokenPrefix = "nzt_"
+)
+
+type ApiToken struct {
+	Common
+	Name      string `json:"name"`
+	TokenHash string `json:"-" gorm:"uniqueIndex;type:char(64)"`
+	// 只读令牌仅能访问 GET 请求，且不能访问有副作用的接口与 WebSocket
+	ReadOnly   bool       `json:"read_only,omitempty"`
+	ExpireAt   *time.Time `json:"expire_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func (t *ApiToken) Expired() bool {
+	return t.ExpireAt != nil && time.Now().After(*t.ExpireAt)
+}
+
+// HashApiToken 数据库中只保存令牌的哈希
+func HashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+type ApiTokenForm struct {
+	Name     string     `json:"name,omitempty" minLength:"1"`
+	ReadOnly bool       `json:"read_only,omitempty" validate:"optional"`
+	ExpireAt *time.Time `json:"expire_at,omitempty" validate:"optional"`
+}
+
+type ApiTokenResponse struct {
+	ID uint64 `json:"id,omitempty"`
+	// 令牌明文仅在创建时返回一次
+	Token string `json:"token,omitempty"`
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+const (
+	AuditActionUserCreate         = "user.create"
+	AuditActionUserDelete         = "user.delete"
+	AuditActionUserPermissions    = "user.permissions"
+	AuditActionUserLogout         = "user.logout"
+	AuditActionUserImpersonate    = "user.impersonate"
+	AuditActionUserImpersonateEnd = "user.impersonate_end"
+	AuditActionUserTenant         = "user.tenant"
+	AuditActionTenantCreate       = "tenant.create"
+	AuditActionTenantUpdate       = "tenant.update"
+	AuditActionTenantDelete       = "tenant.delete"
+	AuditActionBlock              = "waf.block"
+	AuditActionUnblock            = "waf.unblock"
+	AuditActionSettingUpdate      = "setting.update"
+	AuditActionSettingImport      = "setting.import"
+	AuditActionNotificationMute   = "notification.mute"
+	AuditActionNotificationUnmute = "notification.unmute"
+	AuditActionStatusPageUpdate   = "status_page.update"
+	AuditActionAlertRuleCreate    = "alert_rule.create"
+	AuditActionAlertRuleUpdate    = "alert_rule.update"
+	AuditActionAlertRuleDelete    = "alert_rule.delete"
+	AuditActionAlertRuleTest      = "alert_rule.test"
+	AuditActionServerOwner        = "server.owner"
+	AuditActionServerNote         = "server.note"
+	AuditActionServerApprove      = "server.approve"
+	AuditActionServerReject       = "server.reject"
+	AuditActionServerImport       = "server.import"
+	AuditActionSecretCreate       = "secret.create"
+	AuditActionSecretUpdate       = "secret.update"
+	AuditActionSecretDelete       = "secret.delete"
+	AuditActionSecretRotate       = "secret.rotate"
+	AuditActionJWTKeyRotate       = "jwt_key.rotate"
+)
+
+// AuditLog 管理操作的审计记录，只追加不修改。
+// 每条记录的 Hash 覆盖记录内容与上一条记录的 Hash，删除或篡改任意记录都会使之后的哈希链校验失败。
+type AuditLog struct {
+	ID        uint64    `gorm:"primaryKey" json:"id,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at,omitempty"`
+	UserID    uint64    `gorm:"index" json:"user_id,omitempty"` // 操作人
+	IP        string    `json:"ip,omitempty"`
+	Action    string    `gorm:"index" json:"action,omitempty"`
+	Target    string    `json:"target,omitempty"`                  // 如 user:1、alert_rule:2
+	Before    string    `gorm:"type:text" json:"before,omitempty"` // 操作前的 JSON 摘要
+	After     string    `gorm:"type:text" json:"after,omitempty"`  // 操作后的 JSON 摘要
+	PrevHash  string    `gorm:"type:char(64)" json:"prev_hash,omitempty"`
+	Hash      string    `gorm:"type:char(64)" json:"hash,omitempty"`
+
+	// 模拟登录期间的操作记录在发起模拟的管理员名下，此处为被模拟的用户
+	ImpersonatedUserID uint64 `json:"impersonated_user_id,omitempty"`
+}
+
+func (a *AuditLog) TableName() string {
+	return "nz_audit_log"
+}
+
+// ComputeHash 计算记录的哈希，时间取毫秒精度以免数据库存储时丢失精度
+func (a *AuditLog) ComputeHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		a.PrevHash,
+		strconv.FormatInt(a.CreatedAt.UnixMilli(), 10),
+		strconv.FormatUint(a.UserID, 10),
+		a.IP,
+		a.Action,
+		a.Target,
+		a.Before,
+		a.After,
+	} {
+		// 以长度前缀分隔字段，避免不同字段拼接后产生相同内容
+		h.Write([]byte(strconv.Itoa(len(field))))
+		h.Write([]byte{':'})
+		h.Write([]byte(field))
+	}
+	// 新增字段仅在非零时参与计算，保持已有记录的哈希不变
+	if a.ImpersonatedUserID != 0 {
+		field := strconv.FormatUint(a.ImpersonatedUserID, 10)
+		h.Write([]byte("imp:" + strconv.Itoa(len(field)) + ":" + field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
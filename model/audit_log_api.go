@@ -0,0 +1,6 @@
+package model
+
+type AuditLogVerifyResponse struct {
+	Valid    bool   `json:"valid"`
+	BrokenID uint64 `json:"broken_id,omitempty" validate:"optional"` // 第一条校验失败的记录
+}
@@ -9,11 +9,16 @@ import (
 const (
 	CtxKeyAuthorizedUser = "ckau"
 	CtxKeyRealIPStr      = "ckri"
+	CtxKeyTenantScope    = "ckts"
+	CtxKeyReadOnly       = "ckro" // 请求使用只读 API 令牌认证
 )
 
 type CtxKeyRealIP struct{}
 type CtxKeyConnectingIP struct{}
 
+// CtxKeyRequestID 请求关联 ID，保存在请求的 context 中，随之传递给后续调用
+type CtxKeyRequestID struct{}
+
 type Common struct {
 	ID        uint64    `gorm:"primaryKey" json:"id,omitempty"`
 	CreatedAt time.Time `gorm:"index;<-:create" json:"created_at,omitempty"`
@@ -40,7 +45,13 @@ func (c *Common) HasPermission(ctx *gin.Context) bool {
 
 	user := *auth.(*User)
 	if user.Role == RoleAdmin {
-		return true
+		if user.IsSuperAdmin() {
+			return true
+		}
+		// 租户管理员只能管理本租户用户的数据，未注入范围时拒绝访问
+		scope, _ := ctx.Get(CtxKeyTenantScope)
+		s, ok := scope.(*TenantScope)
+		return ok && s != nil && s.Contains(c.UserID)
 	}
 
 	return user.ID == c.UserID
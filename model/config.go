@@ -1,10 +1,13 @@
 package model
 
 import (
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	kyaml "github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env"
@@ -21,15 +24,28 @@ const (
 	ConfigCoverIgnoreAll
 )
 
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+const (
+	DatabaseSQLite   = "sqlite"
+	DatabasePostgres = "postgres"
+)
+
 type Config struct {
 	Debug        bool   `mapstructure:"debug" json:"debug,omitempty"`                   // debug模式开关
 	RealIPHeader string `mapstructure:"real_ip_header" json:"real_ip_header,omitempty"` // 真实IP
+	// 可信的反向代理（IP 或 CIDR，多个用逗号分隔），配置后仅信任来自这些地址的真实 IP 请求头
+	TrustedProxies string `mapstructure:"trusted_proxies" json:"trusted_proxies,omitempty"`
 
 	Language       string `mapstructure:"language" json:"language"` // 系统语言，默认 zh_CN
 	SiteName       string `mapstructure:"site_name" json:"site_name"`
 	UserTemplate   string `mapstructure:"user_template" json:"user_template,omitempty"`
 	AdminTemplate  string `mapstructure:"admin_template" json:"admin_template,omitempty"`
 	JWTSecretKey   string `mapstructure:"jwt_secret_key" json:"jwt_secret_key,omitempty"`
+	JWTAlgorithm   string `mapstructure:"jwt_algorithm" json:"jwt_algorithm,omitempty"` // HS256（默认）、RS256 或 ES256，修改后重启生效
 	AgentSecretKey string `mapstructure:"agent_secret_key" json:"agent_secret_key,omitempty"`
 	ListenPort     uint   `mapstructure:"listen_port" json:"listen_port,omitempty"`
 	ListenHost     string `mapstructure:"listen_host" json:"listen_host,omitempty"`
@@ -52,10 +68,203 @@ type Config struct {
 	CustomCode          string `mapstructure:"custom_code" json:"custom_code,omitempty"`
 	CustomCodeDashboard string `mapstructure:"custom_code_dashboard" json:"custom_code_dashboard,omitempty"`
 
+	// 登录失败锁定：窗口期（秒）内失败达到阈值后临时锁定账户与来源 IP
+	LoginLockoutThreshold int `mapstructure:"login_lockout_threshold" json:"login_lockout_threshold,omitempty"`
+	LoginLockoutWindow    int `mapstructure:"login_lockout_window" json:"login_lockout_window,omitempty"`
+
+	// 允许管理员模拟登录其他管理员，仅通过配置文件设置
+	AllowAdminImpersonation bool `mapstructure:"allow_admin_impersonation" json:"-"`
+
+	PasswordPolicy PasswordPolicy `mapstructure:"password_policy" json:"password_policy"`
+
+	// 数据库，默认使用 -db 参数指定的 SQLite 数据库，仅通过配置文件设置
+	Database DatabaseConfig `mapstructure:"database" json:"-"`
+
+	RateLimit RateLimit `mapstructure:"rate_limit" json:"rate_limit"`
+
+	WebSocketLimit WebSocketLimit `mapstructure:"websocket_limit" json:"websocket_limit"`
+
+	CORS CORS `mapstructure:"cors" json:"cors"`
+
+	// 按角色隐藏功能模块并设置默认页面，仅通过配置文件设置
+	RoleModules RoleModulesConfig `mapstructure:"role_modules" json:"-"`
+
+	// Agent 连接的 mTLS，仅通过配置文件设置
+	AgentTLS AgentTLS `mapstructure:"agent_tls" json:"-"`
+
+	// OIDC 单点登录，含客户端密钥，不通过接口返回
+	OIDC OIDC `mapstructure:"oidc" json:"-"`
+
+	// 加密服务器密钥的主密钥，环境变量 NZ_SECRETS_KEY 优先，均未设置时自动生成
+	SecretsKey string `mapstructure:"secrets_key" json:"-"`
+
+	// /metrics 的访问令牌，为空时不开放该接口
+	MetricsToken string `mapstructure:"metrics_token" json:"-"`
+	// 允许管理员通过 /api/v1/debug/pprof 访问 pprof，仅通过配置文件设置
+	EnablePprof bool `mapstructure:"enable_pprof" json:"-"`
+
+	// 计划任务执行记录：单次输出保存的最大字节数与保留天数
+	CronOutputLimit      int `mapstructure:"cron_output_limit" json:"cron_output_limit,omitempty"`
+	CronHistoryRetention int `mapstructure:"cron_history_retention" json:"cron_history_retention,omitempty"`
+	// Agent 确认收到计划任务的超时时间（秒），超时未确认的执行标记为失败
+	CommandAckTimeout int `mapstructure:"command_ack_timeout" json:"command_ack_timeout,omitempty"`
+
+	// 合并同一事件的报警通知的时间窗口（秒），负数表示不合并
+	NotificationDedupWindow int `mapstructure:"notification_dedup_window" json:"notification_dedup_window,omitempty"`
+
+	// 全局静音，通过专门的接口开启与查询
+	NotificationMute NotificationMute `mapstructure:"notification_mute" json:"-"`
+
+	// 公开状态页，通过专门的接口配置
+	StatusPage StatusPage `mapstructure:"status_page" json:"-"`
+
+	// 文件管理上传文件的最大字节数
+	FMMaxFileSize int64 `mapstructure:"fm_max_file_size" json:"fm_max_file_size,omitempty"`
+
+	// 终端录制：单个会话保存的最大字节数与保留天数
+	TerminalRecordingLimit     int `mapstructure:"terminal_recording_limit" json:"terminal_recording_limit,omitempty"`
+	TerminalRecordingRetention int `mapstructure:"terminal_recording_retention" json:"terminal_recording_retention,omitempty"`
+
+	// 监控记录保留天数：所有监测点的汇总记录与各监测点的明细记录
+	ServiceHistoryRetention       int `mapstructure:"service_history_retention" json:"service_history_retention,omitempty"`
+	ServiceHistoryDetailRetention int `mapstructure:"service_history_detail_retention" json:"service_history_detail_retention,omitempty"`
+	// 流量记录至少保留的天数，为 0 时仅保留报警规则统计周期所需的记录
+	TransferRetention int `mapstructure:"transfer_retention" json:"transfer_retention,omitempty"`
+	// 每台服务器最多保留的自定义指标数与自定义指标历史的保留天数
+	MaxCustomMetrics      int `mapstructure:"max_custom_metrics" json:"max_custom_metrics,omitempty"`
+	CustomMetricRetention int `mapstructure:"custom_metric_retention" json:"custom_metric_retention,omitempty"`
+
+	// 超过该时间（秒）未上报视为离线，可按服务器单独覆盖
+	ServerOfflineTimeout int `mapstructure:"server_offline_timeout" json:"server_offline_timeout,omitempty"`
+
+	// Agent 最低版本，低于该版本的 Agent 上报时向通知组发送提醒，为空时不检查
+	MinAgentVersion                  string `mapstructure:"min_agent_version" json:"min_agent_version,omitempty"`
+	OutdatedAgentNotificationGroupID uint64 `mapstructure:"outdated_agent_notification_group_id" json:"outdated_agent_notification_group_id,omitempty"`
+
+	// 新服务器首次注册或注册申请审批通过时提醒的通知组，为 0 时不提醒
+	NewServerNotificationGroupID uint64 `mapstructure:"new_server_notification_group_id" json:"new_server_notification_group_id,omitempty"`
+
+	// 服务器实时推送数据的缓存时长（秒），负数表示不缓存
+	ServerListCacheTTL int `mapstructure:"server_list_cache_ttl" json:"server_list_cache_ttl,omitempty"`
+
+	// 日志级别（debug、info、warn、error）与格式（text、json），标准库 log 输出的日志视为 info 级别
+	LogLevel  string `mapstructure:"log_level" json:"log_level,omitempty"`
+	LogFormat string `mapstructure:"log_format" json:"log_format,omitempty"`
+
+	// 面板退出时等待报警检测、数据写入与连接关闭的最长时间（秒）
+	ShutdownTimeout int `mapstructure:"shutdown_timeout" json:"shutdown_timeout,omitempty"`
+
+	// 登录会话闲置超过该时间（秒）后失效，与令牌有效期无关，为 0 时不限制。API 令牌不受影响
+	SessionIdleTimeout int `mapstructure:"session_idle_timeout" json:"session_idle_timeout,omitempty"`
+
+	// 已删除的服务器在回收站中保留的天数
+	ServerTrashRetention int `mapstructure:"server_trash_retention" json:"server_trash_retention,omitempty"`
+
+	// MaxMind 格式的城市库与 ASN 库路径，用于查询在线用户与服务器的城市、ASN
+	GeoIPCityDatabase string `mapstructure:"geoip_city_database" json:"geoip_city_database,omitempty"`
+	GeoIPASNDatabase  string `mapstructure:"geoip_asn_database" json:"geoip_asn_database,omitempty"`
+
 	k        *koanf.Koanf `json:"-"`
 	filePath string       `json:"-"`
 }
 
+// PasswordPolicy 密码强度策略
+type PasswordPolicy struct {
+	MinLength        int  `mapstructure:"min_length" json:"min_length"`
+	RequireUppercase bool `mapstructure:"require_uppercase" json:"require_uppercase,omitempty"`
+	RequireLowercase bool `mapstructure:"require_lowercase" json:"require_lowercase,omitempty"`
+	RequireDigit     bool `mapstructure:"require_digit" json:"require_digit,omitempty"`
+	RequireSymbol    bool `mapstructure:"require_symbol" json:"require_symbol,omitempty"`
+	// 额外禁止使用的密码（逗号分隔），内置常见弱密码始终生效
+	Denylist string `mapstructure:"denylist" json:"denylist,omitempty"`
+}
+
+// DatabaseConfig 使用的数据库，切换后不会迁移原有数据
+type DatabaseConfig struct {
+	Type string `mapstructure:"type" json:"type,omitempty"` // sqlite（默认）或 postgres
+	// PostgreSQL 连接串，如 host=localhost user=nezha password=nezha dbname=nezha sslmode=disable
+	DSN string `mapstructure:"dsn" json:"dsn,omitempty"`
+}
+
+// RateLimit 按来源 IP 的请求频率限制，登录类接口与其他写操作分别计数
+type RateLimit struct {
+	Disabled       bool `mapstructure:"disabled" json:"disabled,omitempty"`
+	AuthPerMinute  int  `mapstructure:"auth_per_minute" json:"auth_per_minute,omitempty"`
+	AuthBurst      int  `mapstructure:"auth_burst" json:"auth_burst,omitempty"`
+	WritePerMinute int  `mapstructure:"write_per_minute" json:"write_per_minute,omitempty"`
+	WriteBurst     int  `mapstructure:"write_burst" json:"write_burst,omitempty"`
+	// 每个传入 Webhook 单独计数
+	WebhookPerMinute int `mapstructure:"webhook_per_minute" json:"webhook_per_minute,omitempty"`
+	WebhookBurst     int `mapstructure:"webhook_burst" json:"webhook_burst,omitempty"`
+	// 不受限制的 IP 或 CIDR（多个用逗号分隔），回环地址始终不受限制
+	Allowlist string `mapstructure:"allowlist" json:"allowlist,omitempty"`
+}
+
+// WebSocketLimit 同时保持的 WebSocket 连接数，按来源 IP 与总数分别限制，负数表示不限制。
+// 回环地址与请求频率限制白名单中的 IP 只受总数限制
+type WebSocketLimit struct {
+	PerIP int `mapstructure:"per_ip" json:"per_ip,omitempty"`
+	Total int `mapstructure:"total" json:"total,omitempty"`
+}
+
+// NotificationMute 全局静音，生效期间暂停发送所有通知，报警规则仍正常评估与记录状态
+type NotificationMute struct {
+	Enabled   bool   `mapstructure:"enabled" json:"enabled"`
+	Until     int64  `mapstructure:"until" json:"until,omitempty"` // 自动解除的时间戳（秒），为 0 表示需手动解除
+	Reason    string `mapstructure:"reason" json:"reason,omitempty"`
+	UserID    uint64 `mapstructure:"user_id" json:"user_id,omitempty"` // 开启者
+	Username  string `mapstructure:"username" json:"username,omitempty"`
+	StartedAt int64  `mapstructure:"started_at" json:"started_at,omitempty"` // 时间戳（秒）
+}
+
+// Active 判断静音在 now 时是否生效
+func (m *NotificationMute) Active(now time.Time) bool {
+	return m.Enabled && (m.Until == 0 || now.Unix() < m.Until)
+}
+
+// AgentTLS 在单独的端口上为 Agent 提供 mTLS 连接，ListenPort 为 0 时不启用。
+// Agent 的客户端证书须由 ClientCAFile 中的 CA 签发，且 CN 为该服务器的 UUID；
+// 证书文件更新后自动重新加载，轮换证书无需重启
+type AgentTLS struct {
+	ListenPort   uint   `mapstructure:"listen_port"`
+	CertFile     string `mapstructure:"cert_file"` // 面板的服务端证书
+	KeyFile      string `mapstructure:"key_file"`
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// 允许未出示客户端证书的 Agent 继续使用密钥认证，关闭时所有端口上的 Agent 都必须出示证书
+	AllowSecretFallback bool `mapstructure:"allow_secret_fallback"`
+}
+
+func (t *AgentTLS) Enabled() bool {
+	return t.ListenPort != 0
+}
+
+// SecretAllowed 是否接受仅使用密钥认证的 Agent
+func (t *AgentTLS) SecretAllowed() bool {
+	return !t.Enabled() || t.AllowSecretFallback
+}
+
+// OIDC 单点登录配置，Issuer 为空时不启用
+type OIDC struct {
+	Issuer       string `mapstructure:"issuer"` // 提供方地址，如 https://example.okta.com
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	Scopes       string `mapstructure:"scopes"` // 多个用逗号分隔，默认 openid,email,profile
+	// 回调地址，需与提供方登记的一致，为空时按请求的域名生成 /api/v1/oauth2/callback
+	RedirectURL string `mapstructure:"redirect_url"`
+	// 新建用户的角色，admin 或 member（默认）
+	DefaultRole string `mapstructure:"default_role"`
+	// 分组声明名称，默认 groups；属于 AdminGroups（多个用逗号分隔）中任一分组的用户每次登录时设为管理员
+	GroupsClaim string `mapstructure:"groups_claim"`
+	AdminGroups string `mapstructure:"admin_groups"`
+	// 禁用密码登录，仅保留未关联 OIDC 的管理员（如初始管理员）用于应急
+	DisablePasswordLogin bool `mapstructure:"disable_password_login"`
+}
+
+// Enabled 是否已配置 OIDC 登录
+func (o *OIDC) Enabled() bool {
+	return o.Issuer != "" && o.ClientID != ""
+}
+
 // Read 读取配置文件并应用
 func (c *Config) Read(path string, frontendTemplates []FrontendTemplate) error {
 	c.k = koanf.New(".")
@@ -112,6 +321,103 @@ func (c *Config) Read(path string, frontendTemplates []FrontendTemplate) error {
 	if c.Cover == 0 {
 		c.Cover = 1
 	}
+	if c.LoginLockoutThreshold == 0 {
+		c.LoginLockoutThreshold = 5
+	}
+	if c.LoginLockoutWindow == 0 {
+		c.LoginLockoutWindow = 600
+	}
+	if c.CronOutputLimit == 0 {
+		c.CronOutputLimit = 64 * 1024
+	}
+	if c.CronHistoryRetention == 0 {
+		c.CronHistoryRetention = 30
+	}
+	if c.CommandAckTimeout == 0 {
+		c.CommandAckTimeout = 30
+	}
+	if c.NotificationDedupWindow == 0 {
+		c.NotificationDedupWindow = 10
+	}
+	if c.FMMaxFileSize == 0 {
+		c.FMMaxFileSize = 1024 * 1024 * 1024
+	}
+	if c.TerminalRecordingLimit == 0 {
+		c.TerminalRecordingLimit = 10 * 1024 * 1024
+	}
+	if c.TerminalRecordingRetention == 0 {
+		c.TerminalRecordingRetention = 30
+	}
+	if c.ServiceHistoryRetention == 0 {
+		c.ServiceHistoryRetention = 30
+	}
+	if c.ServiceHistoryDetailRetention == 0 {
+		c.ServiceHistoryDetailRetention = 1
+	}
+	if c.MaxCustomMetrics == 0 {
+		c.MaxCustomMetrics = 64
+	}
+	if c.CustomMetricRetention == 0 {
+		c.CustomMetricRetention = 7
+	}
+	if c.ServerOfflineTimeout == 0 {
+		c.ServerOfflineTimeout = DefaultServerOfflineTimeout
+	}
+	if c.ServerListCacheTTL == 0 {
+		c.ServerListCacheTTL = 1
+	}
+	if c.ServerTrashRetention == 0 {
+		c.ServerTrashRetention = 7
+	}
+	if c.ShutdownTimeout == 0 {
+		c.ShutdownTimeout = 15
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = "info"
+	}
+	if c.LogFormat == "" {
+		c.LogFormat = LogFormatText
+	}
+	if c.Database.Type == "" {
+		c.Database.Type = DatabaseSQLite
+	}
+	if c.RateLimit.AuthPerMinute == 0 {
+		c.RateLimit.AuthPerMinute = 10
+	}
+	if c.RateLimit.AuthBurst == 0 {
+		c.RateLimit.AuthBurst = 5
+	}
+	if c.RateLimit.WritePerMinute == 0 {
+		c.RateLimit.WritePerMinute = 120
+	}
+	if c.RateLimit.WriteBurst == 0 {
+		c.RateLimit.WriteBurst = 30
+	}
+	if c.RateLimit.WebhookPerMinute == 0 {
+		c.RateLimit.WebhookPerMinute = 60
+	}
+	if c.RateLimit.WebhookBurst == 0 {
+		c.RateLimit.WebhookBurst = 10
+	}
+	// 同一 IP 可能是共享出口或打开了多个标签页，默认限制较宽松
+	if c.WebSocketLimit.PerIP == 0 {
+		c.WebSocketLimit.PerIP = 32
+	}
+	if c.WebSocketLimit.Total == 0 {
+		c.WebSocketLimit.Total = 1024
+	}
+	if c.OIDC.Scopes == "" {
+		c.OIDC.Scopes = "openid,email,profile"
+	}
+	if c.OIDC.GroupsClaim == "" {
+		c.OIDC.GroupsClaim = "groups"
+	}
+	if c.PasswordPolicy.MinLength == 0 {
+		c.PasswordPolicy.MinLength = 6
+	}
+	if c.JWTAlgorithm == "" {
+		c.JWTAlgorithm = JWTAlgorithmHS256
+	}
 	if c.JWTSecretKey == "" {
 		c.JWTSecretKey, err = utils.GenerateRandomString(1024)
 		if err != nil {
@@ -122,6 +428,16 @@ func (c *Config) Read(path string, frontendTemplates []FrontendTemplate) error {
 		}
 	}
 
+	if c.SecretsKey == "" && os.Getenv(SecretsKeyEnv) == "" {
+		c.SecretsKey, err = utils.GenerateRandomString(64)
+		if err != nil {
+			return err
+		}
+		if err = c.Save(); err != nil {
+			return err
+		}
+	}
+
 	if c.AgentSecretKey == "" {
 		c.AgentSecretKey, err = utils.GenerateRandomString(32)
 		if err != nil {
@@ -132,10 +448,36 @@ func (c *Config) Read(path string, frontendTemplates []FrontendTemplate) error {
 		}
 	}
 
+	if err = c.RoleModules.Validate(); err != nil {
+		return err
+	}
+	if _, err = c.SlogLevel(); err != nil {
+		return fmt.Errorf("invalid log_level: %s", c.LogLevel)
+	}
+	if c.LogFormat != LogFormatText && c.LogFormat != LogFormatJSON {
+		return fmt.Errorf("invalid log_format: %s", c.LogFormat)
+	}
+	switch c.Database.Type {
+	case DatabaseSQLite:
+	case DatabasePostgres:
+		if c.Database.DSN == "" {
+			return fmt.Errorf("database.dsn is required for %s", c.Database.Type)
+		}
+	default:
+		return fmt.Errorf("invalid database.type: %s", c.Database.Type)
+	}
+
 	c.updateIgnoredIPNotificationID()
 	return nil
 }
 
+// SlogLevel 解析配置的日志级别
+func (c *Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(c.LogLevel))
+	return level, err
+}
+
 // updateIgnoredIPNotificationID 更新用于判断服务器ID是否属于特定服务器的map
 func (c *Config) updateIgnoredIPNotificationID() {
 	c.IgnoredIPNotificationServerIDs = make(map[uint64]bool)
@@ -0,0 +1,220 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ConfigBundleVersion 当前导出的配置包版本，导入时接受不高于该版本的配置包
+const ConfigBundleVersion = 1
+
+// RedactedValue 导出时被隐去的敏感字段
+const RedactedValue = "******"
+
+// ConfigBundle 用于在实例间迁移的完整面板配置
+type ConfigBundle struct {
+	Version            uint32                           `json:"version"`
+	ExportedAt         time.Time                        `json:"exported_at"`
+	Redacted           bool                             `json:"redacted,omitempty"`
+	Servers            []*Server                        `json:"servers"`
+	Services           []*Service                       `json:"services"`
+	AlertRules         []*AlertRule                     `json:"alert_rules"`
+	Notifications      []*Notification                  `json:"notifications"`
+	NotificationGroups []*NotificationGroupResponseItem `json:"notification_groups"`
+	Crons              []*Cron                          `json:"crons"`
+}
+
+// ConfigImportChange 导入时对单个对象的变更
+type ConfigImportChange struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"` // create、update
+	OldID  uint64 `json:"old_id"` // 配置包中的 ID
+	NewID  uint64 `json:"new_id"` // 导入后的 ID，预演时新建对象的 ID 仅供参考
+}
+
+type ConfigImportResult struct {
+	DryRun   bool                  `json:"dry_run"`
+	Changes  []*ConfigImportChange `json:"changes"`
+	Warnings []string              `json:"warnings,omitempty"`
+}
+
+// Redact 隐去通知方式中的令牌、地址、请求头与短信凭据，以及服务监控中的凭据
+func (b *ConfigBundle) Redact() {
+	b.Redacted = true
+	for _, n := range b.Notifications {
+		n.URL = redact(n.URL)
+		n.BotToken = redact(n.BotToken)
+		n.RequestHeader = redact(n.RequestHeader)
+		n.Secret = redact(n.Secret)
+		n.RedactSecrets()
+	}
+	for _, s := range b.Services {
+		s.RedactSecrets()
+	}
+}
+
+// RequiredPermissions 返回导入配置包所需的权限，包含的每类对象都需要对应的管理权限
+func (b *ConfigBundle) RequiredPermissions() uint64 {
+	var p uint64
+	if len(b.Servers) > 0 {
+		p |= PermissionServerWrite
+	}
+	if len(b.Services) > 0 {
+		p |= PermissionService
+	}
+	if len(b.AlertRules) > 0 {
+		p |= PermissionAlertRule
+	}
+	if len(b.Notifications) > 0 || len(b.NotificationGroups) > 0 {
+		p |= PermissionNotification
+	}
+	if len(b.Crons) > 0 {
+		p |= PermissionCron
+	}
+	return p
+}
+
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	return RedactedValue
+}
+
+type idSet map[uint64]bool
+
+func collectIDs[T any](kind string, items []T, id func(T) uint64) (idSet, error) {
+	set := make(idSet, len(items))
+	for _, item := range items {
+		i := id(item)
+		if i == 0 || set[i] {
+			return nil, fmt.Errorf("%s: invalid or duplicate id %d", kind, i)
+		}
+		set[i] = true
+	}
+	return set, nil
+}
+
+func (s idSet) check(kind string, owner string, ids ...uint64) error {
+	for _, id := range ids {
+		if id != 0 && !s[id] {
+			return fmt.Errorf("%s %s references missing %d", owner, kind, id)
+		}
+	}
+	return nil
+}
+
+func keys(m map[uint64]bool) []uint64 {
+	ids := make([]uint64, 0, len(m))
+	for k := range m {
+		ids = append(ids, k)
+	}
+	return ids
+}
+
+// Validate 检查配置包版本与内部引用，任何引用缺失都视为配置包不完整
+func (b *ConfigBundle) Validate() error {
+	if b.Version == 0 {
+		return errors.New("missing bundle version")
+	}
+	if b.Version > ConfigBundleVersion {
+		return fmt.Errorf("bundle version %d is newer than supported version %d", b.Version, ConfigBundleVersion)
+	}
+
+	servers, err := collectIDs("server", b.Servers, func(s *Server) uint64 { return s.ID })
+	if err != nil {
+		return err
+	}
+	notifications, err := collectIDs("notification", b.Notifications, func(n *Notification) uint64 { return n.ID })
+	if err != nil {
+		return err
+	}
+	groups, err := collectIDs("notification group", b.NotificationGroups, func(g *NotificationGroupResponseItem) uint64 { return g.Group.ID })
+	if err != nil {
+		return err
+	}
+	crons, err := collectIDs("cron", b.Crons, func(c *Cron) uint64 { return c.ID })
+	if err != nil {
+		return err
+	}
+	if _, err := collectIDs("service", b.Services, func(s *Service) uint64 { return s.ID }); err != nil {
+		return err
+	}
+	if _, err := collectIDs("alert rule", b.AlertRules, func(r *AlertRule) uint64 { return r.ID }); err != nil {
+		return err
+	}
+
+	for _, s := range b.Servers {
+		if s.UUID == "" {
+			return fmt.Errorf("server %d has no uuid", s.ID)
+		}
+	}
+	for _, n := range b.Notifications {
+		if n.Name == "" {
+			return fmt.Errorf("notification %d has no name", n.ID)
+		}
+	}
+	for _, g := range b.NotificationGroups {
+		if err := notifications.check("notification", "notification group "+g.Group.Name, g.Notifications...); err != nil {
+			return err
+		}
+	}
+	parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	for _, c := range b.Crons {
+		owner := "cron " + c.Name
+		if c.Timezone != "" && !ValidTimezone(c.Timezone) {
+			return fmt.Errorf("%s: invalid timezone %s", owner, c.Timezone)
+		}
+		if c.TaskType == CronTypeCronTask {
+			if _, err := parser.Parse(c.Spec()); err != nil {
+				return fmt.Errorf("%s: %v", owner, err)
+			}
+		}
+		if err := servers.check("server", owner, c.Servers...); err != nil {
+			return err
+		}
+		if err := groups.check("notification group", owner, c.NotificationGroupID); err != nil {
+			return err
+		}
+	}
+	for _, s := range b.Services {
+		owner := "service " + s.Name
+		if err := servers.check("server", owner, keys(s.SkipServers)...); err != nil {
+			return err
+		}
+		if err := groups.check("notification group", owner, s.NotificationGroupID); err != nil {
+			return err
+		}
+		if err := crons.check("cron", owner, slices.Concat(s.FailTriggerTasks, s.RecoverTriggerTasks)...); err != nil {
+			return err
+		}
+	}
+	for _, r := range b.AlertRules {
+		owner := "alert rule " + r.Name
+		for _, rule := range r.Rules {
+			if rule.IsTransferDurationRule() && rule.CycleStart == nil {
+				return fmt.Errorf("%s: cycle rule without cycle start", owner)
+			}
+			if err := servers.check("server", owner, keys(rule.Ignore)...); err != nil {
+				return err
+			}
+		}
+		if r.Expression != "" {
+			if _, err := ParseAlertExpression(r.Expression, len(r.Rules)); err != nil {
+				return fmt.Errorf("%s: invalid expression: %w", owner, err)
+			}
+		}
+		if err := groups.check("notification group", owner, r.NotificationGroupID); err != nil {
+			return err
+		}
+		if err := crons.check("cron", owner, slices.Concat(r.FailTriggerTasks, r.RecoverTriggerTasks)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// CORS 接口的跨域策略，AllowedOrigins 为空时仅允许同源访问
+type CORS struct {
+	// 允许的来源（如 https://example.com，多个用逗号分隔），* 表示任意来源
+	AllowedOrigins string `mapstructure:"allowed_origins" json:"allowed_origins,omitempty"`
+	// 允许的方法与请求头（多个用逗号分隔），为空时使用默认值
+	AllowedMethods string `mapstructure:"allowed_methods" json:"allowed_methods,omitempty"`
+	AllowedHeaders string `mapstructure:"allowed_headers" json:"allowed_headers,omitempty"`
+	// 允许携带 Cookie 等凭据，仅对明确列出的来源生效，* 匹配的来源不携带凭据
+	AllowCredentials bool `mapstructure:"allow_credentials" json:"allow_credentials,omitempty"`
+	// 预检结果的缓存时长（秒），为 0 时使用默认值
+	MaxAge int `mapstructure:"max_age" json:"max_age,omitempty"`
+}
+
+const (
+	corsDefaultMethods = "GET,POST,PUT,PATCH,DELETE"
+	corsDefaultHeaders = "Authorization,Content-Type"
+	corsDefaultMaxAge  = 600
+)
+
+// CORSPolicy 解析后的跨域策略
+type CORSPolicy struct {
+	origins     map[string]bool
+	anyOrigin   bool
+	credentials bool
+
+	Methods string
+	Headers string
+	MaxAge  string
+}
+
+func splitCORSList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// normalizeOrigin 将来源规范为小写的 scheme://host[:port]
+func normalizeOrigin(origin string) (string, error) {
+	u, err := url.Parse(strings.TrimSuffix(origin, "/"))
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return "", fmt.Errorf("invalid origin %s, use scheme://host[:port]", origin)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// Policy 校验并解析跨域策略
+func (c *CORS) Policy() (*CORSPolicy, error) {
+	p := &CORSPolicy{
+		origins:     make(map[string]bool),
+		credentials: c.AllowCredentials,
+		Methods:     corsDefaultMethods,
+		Headers:     corsDefaultHeaders,
+		MaxAge:      strconv.Itoa(corsDefaultMaxAge),
+	}
+	for _, v := range splitCORSList(c.AllowedOrigins) {
+		if v == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		origin, err := normalizeOrigin(v)
+		if err != nil {
+			return nil, err
+		}
+		p.origins[origin] = true
+	}
+
+	if methods := splitCORSList(c.AllowedMethods); len(methods) > 0 {
+		for i, m := range methods {
+			if !isCORSToken(m) {
+				return nil, fmt.Errorf("invalid method %s", m)
+			}
+			methods[i] = strings.ToUpper(m)
+		}
+		p.Methods = strings.Join(methods, ",")
+	}
+	if headers := splitCORSList(c.AllowedHeaders); len(headers) > 0 {
+		for _, h := range headers {
+			if !isCORSToken(h) {
+				return nil, fmt.Errorf("invalid header %s", h)
+			}
+		}
+		p.Headers = strings.Join(headers, ",")
+	}
+	if c.MaxAge < 0 {
+		return nil, errors.New("max age can't be negative")
+	}
+	if c.MaxAge > 0 {
+		p.MaxAge = strconv.Itoa(c.MaxAge)
+	}
+	return p, nil
+}
+
+// isCORSToken 方法名与请求头名只能包含字母、数字与连字符
+func isCORSToken(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Match 判断来源是否被允许，以及是否允许该来源携带凭据
+func (p *CORSPolicy) Match(origin string) (allowed, credentials bool) {
+	if p == nil || origin == "" {
+		return false, false
+	}
+	o, err := normalizeOrigin(origin)
+	if err != nil {
+		return false, false
+	}
+	if p.origins[o] {
+		return true, p.credentials
+	}
+	return p.anyOrigin, false
+}
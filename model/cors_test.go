@@ -0,0 +1,56 @@
+package model
+
+import "testing"
+
+func TestCORSPolicy(t *testing.T) {
+	// 默认仅允许同源
+	p, err := (&CORS{}).Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed, _ := p.Match("https://example.com"); allowed {
+		t.Fatal("empty policy should not allow any origin")
+	}
+	if p.Methods != corsDefaultMethods || p.Headers != corsDefaultHeaders || p.MaxAge != "600" {
+		t.Fatalf("unexpected defaults: %+v", p)
+	}
+
+	p, err = (&CORS{
+		AllowedOrigins:   "https://App.example.com/, http://localhost:5173, *",
+		AllowedMethods:   "get,post",
+		AllowCredentials: true,
+	}).Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		origin      string
+		allowed     bool
+		credentials bool
+	}{
+		{"https://app.example.com", true, true},
+		{"http://localhost:5173", true, true},
+		{"http://localhost:3000", true, false}, // 仅由 * 匹配，不携带凭据
+		{"null", false, false},
+	}
+	for _, c := range cases {
+		allowed, credentials := p.Match(c.origin)
+		if allowed != c.allowed || credentials != c.credentials {
+			t.Errorf("Match(%s) = %v, %v, want %v, %v", c.origin, allowed, credentials, c.allowed, c.credentials)
+		}
+	}
+	if p.Methods != "GET,POST" {
+		t.Errorf("unexpected methods %s", p.Methods)
+	}
+
+	for _, c := range []CORS{
+		{AllowedOrigins: "example.com"},
+		{AllowedOrigins: "https://example.com/app"},
+		{AllowedHeaders: "X-Token: 1"},
+		{MaxAge: -1},
+	} {
+		if _, err := c.Policy(); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
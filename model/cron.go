@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"github.com/nezhahq/nezha/pkg/utils"
@@ -16,6 +17,20 @@ const (
 	CronTypeTriggerTask = 1
 )
 
+const (
+	CronRunStatusRunning = iota
+	CronRunStatusSuccess
+	CronRunStatusFailure
+	CronRunStatusOffline
+)
+
+// 计划任务下发后的投递状态，为 0 表示未下发（服务器离线）、Agent 不支持确认或记录早于投递确认功能
+const (
+	CommandDeliveryPending   = iota + 1 // 已下发，等待 Agent 确认
+	CommandDeliveryDelivered            // Agent 已确认收到
+	CommandDeliveryLost                 // 超时未确认
+)
+
 type Cron struct {
 	Common
 	Name                string    `json:"name"`
@@ -29,8 +44,59 @@ type Cron struct {
 	LastResult          bool      `json:"last_result,omitempty"`      // 最后一次执行结果
 	Cover               uint8     `json:"cover"`                      // 计划任务覆盖范围 (0:仅覆盖特定服务器 1:仅忽略特定服务器 2:由触发该计划任务的服务器执行)
 
-	CronJobID  cron.EntryID `gorm:"-" json:"cron_job_id,omitempty"`
-	ServersRaw string       `json:"-"`
+	// 按该时区解析执行时间，为空时使用面板的时区
+	Timezone string `json:"timezone,omitempty"`
+	// 下次执行时间，以任务的时区表示，仅列表返回
+	NextRunAt *time.Time `gorm:"-" json:"next_run_at,omitempty"`
+
+	// 指定的服务器分组，成员在执行时解析，与 Servers 一同决定覆盖范围
+	ServerGroups []uint64 `gorm:"-" json:"server_groups,omitempty"`
+
+	// 引用的服务器密钥，每台服务器只获得属于自己的密钥，以环境变量提供
+	SecretIDs []uint64 `gorm:"-" json:"secret_ids,omitempty"`
+
+	// Agent 重连后重新下发未确认的执行，默认关闭，避免非幂等的命令被重复执行
+	RedeliverOnReconnect bool `json:"redeliver_on_reconnect,omitempty"`
+
+	CronJobID       cron.EntryID `gorm:"-" json:"cron_job_id,omitempty"`
+	ServersRaw      string       `json:"-"`
+	ServerGroupsRaw string       `gorm:"default:'[]'" json:"-"`
+	SecretIDsRaw    string       `gorm:"default:'[]'" json:"-"`
+}
+
+// ValidTimezone 判断 name 是否为时区数据库中的时区
+func ValidTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// Spec 返回注册到调度器的表达式，设置了时区时按该时区解析，夏令时切换由调度器处理
+func (c *Cron) Spec() string {
+	if c.Timezone == "" {
+		return c.Scheduler
+	}
+	return "CRON_TZ=" + c.Timezone + " " + c.Scheduler
+}
+
+// SchedulerHasTimezone 判断表达式是否自带 TZ= 或 CRON_TZ= 前缀，此时不能再设置 Timezone，否则 Spec 会重复添加前缀
+func (c *Cron) SchedulerHasTimezone() bool {
+	scheduler := strings.TrimSpace(c.Scheduler)
+	return strings.HasPrefix(scheduler, "TZ=") || strings.HasPrefix(scheduler, "CRON_TZ=")
+}
+
+// Location 返回任务的时区，未设置或无效时返回 fallback
+func (c *Cron) Location(fallback *time.Location) *time.Location {
+	if c.Timezone == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return fallback
+	}
+	return loc
 }
 
 func (c *Cron) BeforeSave(tx *gorm.DB) error {
@@ -39,9 +105,58 @@ func (c *Cron) BeforeSave(tx *gorm.DB) error {
 	} else {
 		c.ServersRaw = string(data)
 	}
+	if data, err := utils.Json.Marshal(c.ServerGroups); err != nil {
+		return err
+	} else {
+		c.ServerGroupsRaw = string(data)
+	}
+	if data, err := utils.Json.Marshal(c.SecretIDs); err != nil {
+		return err
+	} else {
+		c.SecretIDsRaw = string(data)
+	}
 	return nil
 }
 
 func (c *Cron) AfterFind(tx *gorm.DB) error {
-	return utils.Json.Unmarshal([]byte(c.ServersRaw), &c.Servers)
+	if err := utils.Json.Unmarshal([]byte(c.ServersRaw), &c.Servers); err != nil {
+		return err
+	}
+	if c.ServerGroupsRaw != "" {
+		if err := utils.Json.Unmarshal([]byte(c.ServerGroupsRaw), &c.ServerGroups); err != nil {
+			return err
+		}
+	}
+	if c.SecretIDsRaw != "" {
+		return utils.Json.Unmarshal([]byte(c.SecretIDsRaw), &c.SecretIDs)
+	}
+	return nil
+}
+
+// CronHistory 计划任务在单台服务器上的一次执行记录
+type CronHistory struct {
+	Common
+	CronID     uint64     `json:"cron_id" gorm:"index"`
+	ServerID   uint64     `json:"server_id" gorm:"index"`
+	Status     uint8      `json:"status"` // 0:执行中 1:成功 2:失败 3:服务器离线
+	Manual     bool       `json:"manual,omitempty"`
+	Overlapped bool       `json:"overlapped,omitempty"` // 触发时上一次执行尚未结束
+	StartedAt  time.Time  `json:"started_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	Output     string     `json:"output,omitempty" gorm:"type:longtext"`
+	Truncated  bool       `json:"truncated,omitempty"`
+	RequestID  string     `json:"request_id,omitempty"` // 手动执行时所属请求的关联 ID，用于对照日志
+
+	Delivery      uint8      `json:"delivery,omitempty"` // 1:等待确认 2:已送达 3:超时未确认
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	DeliveryTries uint8      `json:"delivery_tries,omitempty"` // 下发次数，开启重新下发的计划任务在 Agent 重连后会重新下发未确认的执行
+}
+
+// SetOutput 保存执行输出，超过 limit 字节时截断
+func (h *CronHistory) SetOutput(output string, limit int) {
+	if limit > 0 && len(output) > limit {
+		output = strings.ToValidUTF8(output[:limit], "")
+		h.Truncated = true
+	}
+	h.Output = output
 }
@@ -6,7 +6,22 @@ type CronForm struct {
 	Scheduler           string   `json:"scheduler,omitempty"`
 	Command             string   `json:"command,omitempty" validate:"optional"`
 	Servers             []uint64 `json:"servers,omitempty"`
+	ServerGroups        []uint64 `json:"server_groups,omitempty" validate:"optional"`
 	Cover               uint8    `json:"cover,omitempty" default:"0"`
 	PushSuccessful      bool     `json:"push_successful,omitempty" validate:"optional"`
 	NotificationGroupID uint64   `json:"notification_group_id,omitempty"`
+	SecretIDs           []uint64 `json:"secret_ids,omitempty" validate:"optional"` // 引用的服务器密钥
+	Timezone            string   `json:"timezone,omitempty" validate:"optional"`   // 时区数据库中的时区名，为空时使用面板的时区
+	// Agent 重连后重新下发未确认的执行，任务可能被重复执行，只应对幂等的命令开启
+	RedeliverOnReconnect bool `json:"redeliver_on_reconnect,omitempty" validate:"optional"`
+}
+
+// TaskOutputEvent 推送给浏览器的计划任务执行输出，Done 为 true 时表示执行结束
+type TaskOutputEvent struct {
+	Stream   string `json:"stream,omitempty" enums:"stdout,stderr"`
+	Data     string `json:"data,omitempty"`
+	Done     bool   `json:"done,omitempty"`
+	Status   uint8  `json:"status,omitempty"` // 结束时的执行状态，同 CronHistory.Status
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
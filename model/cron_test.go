@@ -0,0 +1,52 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestCronSpecTimezone(t *testing.T) {
+	if !ValidTimezone("America/New_York") || ValidTimezone("Mars/Base") || ValidTimezone("Local") || ValidTimezone("") {
+		t.Fatal("unexpected timezone validation result")
+	}
+
+	c := &Cron{Scheduler: "0 0 9 * * *"}
+	if c.Spec() != "0 0 9 * * *" || c.Location(time.UTC) != time.UTC {
+		t.Fatal("task without timezone should use the scheduler as is")
+	}
+
+	c.Timezone = "America/New_York"
+	parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	schedule, err := parser.Parse(c.Spec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 夏令时开始前后，当地 9 点分别对应 UTC 14 点与 13 点
+	for from, want := range map[string]string{
+		"2024-03-09T15:00:00Z": "2024-03-10T13:00:00Z",
+		"2024-03-08T15:00:00Z": "2024-03-09T14:00:00Z",
+	} {
+		ts, _ := time.Parse(time.RFC3339, from)
+		if got := schedule.Next(ts).UTC().Format(time.RFC3339); got != want {
+			t.Errorf("next run after %s = %s, want %s", from, got, want)
+		}
+	}
+	if got := c.Location(time.UTC).String(); got != "America/New_York" {
+		t.Fatalf("unexpected location: %s", got)
+	}
+}
+
+func TestCronSchedulerHasTimezone(t *testing.T) {
+	for scheduler, want := range map[string]bool{
+		"0 0 9 * * *":                        false,
+		"TZ=UTC 0 0 9 * * *":                 true,
+		" CRON_TZ=Asia/Shanghai 0 0 9 * * *": true,
+	} {
+		c := &Cron{Scheduler: scheduler}
+		if got := c.SchedulerHasTimezone(); got != want {
+			t.Errorf("SchedulerHasTimezone(%q) = %v, want %v", scheduler, got, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"math"
+	"regexp"
+	"time"
+
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+// CustomMetricTTL 超过该时长未再上报的自定义指标被移除，释放数量配额
+const CustomMetricTTL = 24 * time.Hour
+
+var customMetricNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.:-]{0,63}$`)
+
+// CustomMetric Agent 插件上报的自定义指标的最近一次取值
+type CustomMetric struct {
+	Value     float64   `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CustomMetricHistory 自定义指标按分钟记录的历史
+type CustomMetricHistory struct {
+	ID        uint64    `gorm:"primaryKey" json:"-"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	ServerID  uint64    `gorm:"index:idx_custom_metric_server_name" json:"-"`
+	Name      string    `gorm:"index:idx_custom_metric_server_name" json:"-"`
+	Value     float64   `json:"value"`
+}
+
+// ValidCustomMetricName 指标名称以字母或下划线开头，仅包含字母、数字与 _.:-，最长 64 个字符
+func ValidCustomMetricName(name string) bool {
+	return customMetricNameRe.MatchString(name)
+}
+
+// MergeCustomMetrics 将本次上报的自定义指标合并到已有的指标中，本次未上报的指标保留原值直至过期。
+// 名称不合法或值不是有限数的指标被忽略，已有指标数达到 limit 时新名称被丢弃
+func MergeCustomMetrics(prev map[string]CustomMetric, reported []*pb.State_CustomMetric, limit int, now time.Time) map[string]CustomMetric {
+	if len(prev) == 0 && len(reported) == 0 {
+		return nil
+	}
+	merged := make(map[string]CustomMetric, len(prev))
+	for name, m := range prev {
+		if now.Sub(m.UpdatedAt) < CustomMetricTTL {
+			merged[name] = m
+		}
+	}
+	for _, r := range reported {
+		name, value := r.GetName(), r.GetValue()
+		if !ValidCustomMetricName(name) || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		if _, ok := merged[name]; !ok && len(merged) >= limit {
+			continue
+		}
+		merged[name] = CustomMetric{Value: value, UpdatedAt: now}
+	}
+	return merged
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+func TestMergeCustomMetrics(t *testing.T) {
+	now := time.Now()
+	prev := map[string]CustomMetric{
+		"queue_depth":    {Value: 3, UpdatedAt: now.Add(-time.Minute)},
+		"cache.hit_rate": {Value: 0.9, UpdatedAt: now.Add(-time.Minute)},
+		"stale":          {Value: 1, UpdatedAt: now.Add(-CustomMetricTTL)},
+	}
+	merged := MergeCustomMetrics(prev, []*pb.State_CustomMetric{
+		{Name: "queue_depth", Value: 5},
+		{Name: "bad name", Value: 1},
+		{Name: "nan", Value: math.NaN()},
+		{Name: "new_one", Value: 1},
+		{Name: "new_two", Value: 2},
+	}, 3, now)
+
+	if m := merged["queue_depth"]; m.Value != 5 || !m.UpdatedAt.Equal(now) {
+		t.Errorf("reported metric should be updated, got %+v", m)
+	}
+	if m := merged["cache.hit_rate"]; m.Value != 0.9 {
+		t.Errorf("unreported metric should keep its value, got %+v", m)
+	}
+	for _, name := range []string{"stale", "bad name", "nan", "new_two"} {
+		if _, ok := merged[name]; ok {
+			t.Errorf("metric %s should be dropped", name)
+		}
+	}
+	if len(merged) != 3 {
+		t.Errorf("metrics should be bounded to the limit, got %d", len(merged))
+	}
+
+	state := PB2State((&HostState{CustomMetrics: merged}).PB())
+	if state.CustomMetrics != nil {
+		t.Error("PB2State should leave custom metrics to be merged")
+	}
+	server := &Server{Host: &Host{}, State: &HostState{CustomMetrics: merged}}
+	if (&Rule{Type: "custom", Target: "queue_depth", Max: 4}).Snapshot(nil, server, nil) {
+		t.Error("custom metric above threshold should fail")
+	}
+	if !(&Rule{Type: "custom", Target: "missing", Max: 4}).Snapshot(nil, server, nil) {
+		t.Error("missing custom metric should pass")
+	}
+}
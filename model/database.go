@@ -0,0 +1,76 @@
+package model
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// databaseModels 需要迁移表结构的模型
+var databaseModels = []any{
+	Server{}, User{}, ServerGroup{}, NotificationGroup{},
+	Notification{}, AlertRule{}, Service{}, NotificationGroupNotification{},
+	ServiceHistory{}, Cron{}, Transfer{}, ServerGroupServer{},
+	NAT{}, DDNSProfile{}, NotificationGroupNotification{},
+	WAF{}, ApiToken{}, LoginHistory{}, MuteWindow{}, NotificationLog{}, NotificationDeadLetter{},
+	WAFGeo{}, WAFRange{}, WAFAudit{}, CronHistory{},
+	EscalationPolicy{}, AuditLog{}, WebAuthnCredential{},
+	NotificationRecipient{}, ServerEvent{},
+	ServerMaintenance{}, RefreshToken{}, Secret{},
+	TerminalSession{}, TerminalRecordingChunk{},
+	StatusIncident{}, StatusIncidentUpdate{},
+	IncomingWebhook{}, Annotation{}, Tenant{},
+	JWTKey{}, EnrollmentToken{}, ServerEnrollment{},
+	CustomMetricHistory{},
+}
+
+// MigrateDatabase 迁移所有表结构，SQLite 与 PostgreSQL 使用相同的模型
+func MigrateDatabase(db *gorm.DB) error {
+	if err := adaptSchema(db, databaseModels...); err != nil {
+		return err
+	}
+	return db.AutoMigrate(databaseModels...)
+}
+
+// adaptSchema 将表结构中 PostgreSQL 不支持的列类型替换为对应的类型，需在 AutoMigrate 前调用。
+// 表结构解析后缓存在 db 中，后续的迁移与查询均使用修改后的类型，SQLite 的表结构保持不变
+func adaptSchema(db *gorm.DB, models ...any) error {
+	if db.Dialector.Name() != DatabasePostgres {
+		return nil
+	}
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			return err
+		}
+		for _, field := range stmt.Schema.Fields {
+			field.DataType = postgresDataType(field.DataType)
+		}
+	}
+	return nil
+}
+
+// postgresDataType 返回 PostgreSQL 中对应的列类型。
+// char(n) 在 PostgreSQL 中读取时以空格补齐长度，会导致密码哈希等校验失败，改用 varchar(n)
+func postgresDataType(t schema.DataType) schema.DataType {
+	s := strings.ToLower(string(t))
+	switch {
+	case s == "longtext":
+		return "text"
+	case strings.HasPrefix(s, "binary("):
+		return "bytea"
+	case strings.HasPrefix(s, "char("):
+		return schema.DataType("var" + s)
+	}
+	return t
+}
+
+// TimeCondition 返回时间列与参数比较的条件，op 为比较运算符。
+// SQLite 以文本保存时间，时区不同时无法直接比较，需转换为 UTC 后比较
+func TimeCondition(db *gorm.DB, column, op string) string {
+	if db.Dialector.Name() == DatabaseSQLite {
+		return "datetime(" + column + ") " + op + " datetime(?)"
+	}
+	return column + " " + op + " ?"
+}
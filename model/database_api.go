@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+type DatabaseTableStats struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	// 最近一次清理删除的行数
+	Pruned int64 `json:"pruned"`
+}
+
+type DatabaseStats struct {
+	Size     int64                `json:"size"`      // 字节
+	FreeSize int64                `json:"free_size"` // 空闲页占用的字节，VACUUM 后可回收
+	Tables   []DatabaseTableStats `json:"tables"`
+	// 最近一次清理历史记录的时间，启动后未清理时为空
+	LastPrunedAt *time.Time `json:"last_pruned_at,omitempty" validate:"optional"`
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 默认使用临时的 SQLite 数据库，设置 NZ_TEST_POSTGRES_DSN 后使用 PostgreSQL。
+// PostgreSQL 数据库中的表会在测试前后被删除，需使用专门用于测试的数据库
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dialector := sqlite.Open(filepath.Join(t.TempDir(), "sqlite.db"))
+	if dsn := os.Getenv("NZ_TEST_POSTGRES_DSN"); dsn != "" {
+		dialector = postgres.Open(dsn)
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Dialector.Name() == DatabasePostgres {
+		drop := func() {
+			if err := db.Migrator().DropTable(databaseModels...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		drop()
+		t.Cleanup(drop)
+	}
+	// 再次迁移时不应修改已有的表结构
+	for range 2 {
+		if err := MigrateDatabase(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestDatabaseUser(t *testing.T) {
+	db := openTestDB(t)
+
+	hash := "$2a$10$" + strings.Repeat("x", 53)
+	u := User{Username: "Admin", Password: hash}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	var got User
+	if err := db.Where("username = ?", "Admin").First(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Password != hash {
+		t.Fatalf("password hash is changed: %q", got.Password)
+	}
+	// 用户名区分大小写
+	if err := db.Where("username = ?", "admin").First(&got).Error; err != gorm.ErrRecordNotFound {
+		t.Fatalf("username should be case sensitive, got %v", err)
+	}
+
+	var users []User
+	if err := db.Omit("password").Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Password != "" || users[0].Username != "Admin" {
+		t.Fatalf("unexpected users: %+v", users)
+	}
+
+	// 自增 ID 不受已删除记录影响
+	if err := db.Delete(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	next := User{Username: "member"}
+	if err := db.Create(&next).Error; err != nil {
+		t.Fatal(err)
+	}
+	if next.ID <= u.ID {
+		t.Fatalf("id %d is reused after %d", next.ID, u.ID)
+	}
+}
+
+func TestDatabaseWAF(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := CheckIP(db, "2001:db8::1"); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := BlockIP(db, "2001:db8::1", WAFBlockReasonTypeLoginFail, BlockIDUnknownUser); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var w WAF
+	if err := db.First(&w).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(w.IP) != 16 || w.Count != 2 {
+		t.Fatalf("unexpected waf record: %+v", w)
+	}
+	if err := CheckIP(db, "2001:db8::1"); err == nil {
+		t.Fatal("blocked ip is not checked")
+	}
+	if err := CheckIP(db, "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDatabaseTimeCondition(t *testing.T) {
+	db := openTestDB(t)
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		tr := Transfer{ServerID: 1, In: 10, Out: 1}
+		tr.CreatedAt = start.Add(time.Duration(i) * time.Hour)
+		if err := db.Create(&tr).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 参数与记录的时区不同时仍按时间先后比较
+	loc := time.FixedZone("UTC+8", 8*3600)
+	for from, want := range map[time.Time]uint64{
+		start.In(loc):                    33,
+		start.Add(time.Hour).In(loc):     22,
+		start.Add(3 * time.Hour).In(loc): 0,
+	} {
+		var res NResult
+		if err := db.Model(&Transfer{}).Select("SUM(? + ?) AS n", clause.Column{Name: "in"}, clause.Column{Name: "out"}).
+			Where(TimeCondition(db, "created_at", ">=")+" AND server_id = ?", from, 1).Scan(&res).Error; err != nil {
+			t.Fatal(err)
+		}
+		if res.N != want {
+			t.Errorf("transfer since %s: got %v, want %d", from, res.N, want)
+		}
+	}
+}
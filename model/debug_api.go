@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// DebugStats 面板运行状态，用于排查面板自身的性能问题
+type DebugStats struct {
+	GoVersion  string           `json:"go_version"`
+	NumCPU     int              `json:"num_cpu"`
+	Goroutines int              `json:"goroutines"`
+	Memory     DebugMemoryStats `json:"memory"`
+	GC         DebugGCStats     `json:"gc"`
+	WebSockets int              `json:"websockets"` // 包括服务器状态推送、终端、文件管理与计划任务输出
+	DB         DebugDBStats     `json:"db"`
+}
+
+// DebugMemoryStats 内存占用，单位为字节
+type DebugMemoryStats struct {
+	Sys          uint64 `json:"sys"` // 从操作系统获取的内存
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapInuse    uint64 `json:"heap_inuse"`
+	HeapIdle     uint64 `json:"heap_idle"`
+	HeapReleased uint64 `json:"heap_released"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	StackInuse   uint64 `json:"stack_inuse"`
+	TotalAlloc   uint64 `json:"total_alloc"` // 累计分配的字节
+}
+
+type DebugGCStats struct {
+	NumGC       uint32     `json:"num_gc"`
+	NextGC      uint64     `json:"next_gc"` // 下次 GC 的目标堆大小
+	LastGC      *time.Time `json:"last_gc,omitempty" validate:"optional"`
+	PauseTotal  float64    `json:"pause_total_ms"`
+	LastPause   float64    `json:"last_pause_ms"`
+	CPUFraction float64    `json:"cpu_fraction"` // 启动以来 GC 占用的 CPU 比例
+}
+
+// DebugDBStats 数据库连接池状态
+type DebugDBStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDuration       int64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
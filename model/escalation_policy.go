@@ -0,0 +1,58 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// EscalationStep 报警持续未恢复达到 After 分钟后追加通知的通知组
+type EscalationStep struct {
+	After               uint64 `json:"after"` // 报警触发后经过的分钟数
+	NotificationGroupID uint64 `json:"notification_group_id"`
+}
+
+// EscalationPolicy 报警升级策略，报警规则自身的通知组之后按顺序逐级追加通知
+type EscalationPolicy struct {
+	Common
+	Name     string            `json:"name"`
+	StepsRaw string            `gorm:"default:'[]'" json:"-"`
+	Steps    []*EscalationStep `gorm:"-" json:"steps"`
+}
+
+func (p *EscalationPolicy) BeforeSave(tx *gorm.DB) error {
+	if data, err := utils.Json.Marshal(p.Steps); err != nil {
+		return err
+	} else {
+		p.StepsRaw = string(data)
+	}
+	return nil
+}
+
+func (p *EscalationPolicy) AfterFind(tx *gorm.DB) error {
+	if p.StepsRaw == "" {
+		return nil
+	}
+	return utils.Json.Unmarshal([]byte(p.StepsRaw), &p.Steps)
+}
+
+// Validate 每一级需指定通知组，且等待时间严格递增
+func (p *EscalationPolicy) Validate() error {
+	if len(p.Steps) == 0 {
+		return errors.New("steps can't be empty")
+	}
+	var prev uint64
+	for i, s := range p.Steps {
+		if s == nil || s.NotificationGroupID == 0 {
+			return fmt.Errorf("step %d has no notification group", i+1)
+		}
+		if s.After == 0 || s.After <= prev {
+			return fmt.Errorf("step %d must wait longer than the previous step", i+1)
+		}
+		prev = s.After
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+package model
+
+type EscalationPolicyForm struct {
+	Name  string            `json:"name" minLength:"1"`
+	Steps []*EscalationStep `json:"steps"`
+}
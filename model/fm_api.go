@@ -1,5 +1,24 @@
 package model
 
+// 文件管理会话中用户发往 Agent 的操作，由首个字节区分
+const (
+	FMOpListDir byte = iota
+	FMOpDownload
+	FMOpUpload
+)
+
+// Agent 返回内容的前缀
+var (
+	FMMagicFile     = []byte("NZTD") // 其后为 8 字节大端序的文件大小与文件内容
+	FMMagicUploaded = []byte("NZUP") // 上传完成
+	FMMagicError    = []byte("NERR") // 其后为错误信息
+)
+
 type CreateFMResponse struct {
 	SessionID string `json:"session_id,omitempty"`
 }
+
+type FMUploadResponse struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
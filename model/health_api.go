@@ -0,0 +1,10 @@
+package model
+
+// HealthStatus 存活与就绪探针的结果，不包含错误详情
+type HealthStatus struct {
+	Status   string `json:"status" enums:"ok,unavailable"`
+	Database bool   `json:"database"` // 数据库可连接
+	Listener bool   `json:"listener"` // Agent 监听已启动
+	Ready    bool   `json:"ready"`    // 启动完成且未开始关闭
+	Agents   int    `json:"agents"`   // 正在上报的 Agent 数量
+}
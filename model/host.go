@@ -2,7 +2,10 @@ package model
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 
+	"github.com/nezhahq/nezha/pkg/geoip"
 	pb "github.com/nezhahq/nezha/proto"
 )
 
@@ -17,6 +20,23 @@ type SensorTemperature struct {
 	Temperature float64
 }
 
+// DiskState 单个挂载点的磁盘用量
+type DiskState struct {
+	Mountpoint string `json:"mountpoint"`
+	Fstype     string `json:"fstype,omitempty"`
+	Total      uint64 `json:"total"`
+	Used       uint64 `json:"used"`
+}
+
+// NetInterfaceState 单个网卡的流量与速率
+type NetInterfaceState struct {
+	Name        string `json:"name"`
+	InTransfer  uint64 `json:"in_transfer,omitempty"`
+	OutTransfer uint64 `json:"out_transfer,omitempty"`
+	InSpeed     uint64 `json:"in_speed,omitempty"`
+	OutSpeed    uint64 `json:"out_speed,omitempty"`
+}
+
 type HostState struct {
 	CPU            float64             `json:"cpu,omitempty"`
 	MemUsed        uint64              `json:"mem_used,omitempty"`
@@ -35,6 +55,42 @@ type HostState struct {
 	ProcessCount   uint64              `json:"process_count,omitempty"`
 	Temperatures   []SensorTemperature `json:"temperatures,omitempty"`
 	GPU            []float64           `json:"gpu,omitempty"`
+	// 按挂载点与网卡的明细，汇总数据仍保留在上方字段中
+	Disks         []DiskState         `json:"disks,omitempty"`
+	NetInterfaces []NetInterfaceState `json:"net_interfaces,omitempty"`
+	// Agent 插件上报的自定义指标，按名称合并，见 MergeCustomMetrics
+	CustomMetrics map[string]CustomMetric `json:"custom_metrics,omitempty"`
+}
+
+// Summary 返回不含挂载点与网卡明细的状态，用于实时推送
+func (s *HostState) Summary() *HostState {
+	if s == nil || (len(s.Disks) == 0 && len(s.NetInterfaces) == 0) {
+		return s
+	}
+	summary := *s
+	summary.Disks = nil
+	summary.NetInterfaces = nil
+	return &summary
+}
+
+// Disk 按挂载点查找磁盘用量
+func (s *HostState) Disk(mountpoint string) (DiskState, bool) {
+	for _, d := range s.Disks {
+		if d.Mountpoint == mountpoint {
+			return d, true
+		}
+	}
+	return DiskState{}, false
+}
+
+// NetInterface 按名称查找网卡
+func (s *HostState) NetInterface(name string) (NetInterfaceState, bool) {
+	for _, n := range s.NetInterfaces {
+		if n.Name == name {
+			return n, true
+		}
+	}
+	return NetInterfaceState{}, false
 }
 
 func (s *HostState) PB() *pb.State {
@@ -46,6 +102,34 @@ func (s *HostState) PB() *pb.State {
 		})
 	}
 
+	var disks []*pb.State_Disk
+	for _, d := range s.Disks {
+		disks = append(disks, &pb.State_Disk{
+			Mountpoint: d.Mountpoint,
+			Fstype:     d.Fstype,
+			Total:      d.Total,
+			Used:       d.Used,
+		})
+	}
+	var nics []*pb.State_NetInterface
+	for _, n := range s.NetInterfaces {
+		nics = append(nics, &pb.State_NetInterface{
+			Name:        n.Name,
+			InTransfer:  n.InTransfer,
+			OutTransfer: n.OutTransfer,
+			InSpeed:     n.InSpeed,
+			OutSpeed:    n.OutSpeed,
+		})
+	}
+
+	var metrics []*pb.State_CustomMetric
+	for _, name := range slices.Sorted(maps.Keys(s.CustomMetrics)) {
+		metrics = append(metrics, &pb.State_CustomMetric{
+			Name:  name,
+			Value: s.CustomMetrics[name].Value,
+		})
+	}
+
 	return &pb.State{
 		Cpu:            s.CPU,
 		MemUsed:        s.MemUsed,
@@ -64,9 +148,13 @@ func (s *HostState) PB() *pb.State {
 		ProcessCount:   s.ProcessCount,
 		Temperatures:   ts,
 		Gpu:            s.GPU,
+		Disks:          disks,
+		NetInterfaces:  nics,
+		CustomMetrics:  metrics,
 	}
 }
 
+// PB2State 转换上报的状态，自定义指标需与已有指标合并，不在此转换
 func PB2State(s *pb.State) HostState {
 	var ts []SensorTemperature
 	for _, t := range s.GetTemperatures() {
@@ -76,6 +164,26 @@ func PB2State(s *pb.State) HostState {
 		})
 	}
 
+	var disks []DiskState
+	for _, d := range s.GetDisks() {
+		disks = append(disks, DiskState{
+			Mountpoint: d.GetMountpoint(),
+			Fstype:     d.GetFstype(),
+			Total:      d.GetTotal(),
+			Used:       d.GetUsed(),
+		})
+	}
+	var nics []NetInterfaceState
+	for _, n := range s.GetNetInterfaces() {
+		nics = append(nics, NetInterfaceState{
+			Name:        n.GetName(),
+			InTransfer:  n.GetInTransfer(),
+			OutTransfer: n.GetOutTransfer(),
+			InSpeed:     n.GetInSpeed(),
+			OutSpeed:    n.GetOutSpeed(),
+		})
+	}
+
 	return HostState{
 		CPU:            s.GetCpu(),
 		MemUsed:        s.GetMemUsed(),
@@ -94,6 +202,8 @@ func PB2State(s *pb.State) HostState {
 		ProcessCount:   s.GetProcessCount(),
 		Temperatures:   ts,
 		GPU:            s.GetGpu(),
+		Disks:          disks,
+		NetInterfaces:  nics,
 	}
 }
 
@@ -173,8 +283,9 @@ func (p *IP) Join() string {
 }
 
 type GeoIP struct {
-	IP          IP     `json:"ip,omitempty"`
-	CountryCode string `json:"country_code,omitempty"`
+	IP          IP              `json:"ip,omitempty"`
+	CountryCode string          `json:"country_code,omitempty"`
+	Geo         *geoip.Location `json:"geo"` // 未配置 GeoIP 数据库或查询不到时为 null
 }
 
 func PB2GeoIP(p *pb.GeoIP) GeoIP {
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	IncomingWebhookSecretHeader = "X-Webhook-Secret"
+	IncomingWebhookSecretPrefix = "nzw_"
+)
+
+// 注解标题与内容的长度上限（字节）
+const (
+	MaxAnnotationTitleSize = 256
+	MaxAnnotationTextSize  = 4096
+)
+
+// IncomingWebhook 外部系统推送事件的入口，密钥只保存哈希
+type IncomingWebhook struct {
+	Common
+	Name       string     `json:"name"`
+	SecretHash string     `json:"-" gorm:"type:char(64)"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Annotation 外部系统推送的事件，如部署开始，关联到服务器或分组，用于在历史图表中标注
+type Annotation struct {
+	Common
+	WebhookID     uint64    `json:"webhook_id,omitempty" gorm:"index"`
+	ServerID      uint64    `json:"server_id,omitempty" gorm:"index"`       // 为 0 时关联到分组
+	ServerGroupID uint64    `json:"server_group_id,omitempty" gorm:"index"` // 分组内的所有服务器共用该注解
+	Time          time.Time `json:"time" gorm:"index"`                      // 事件发生的时间
+	Title         string    `json:"title"`
+	Text          string    `json:"text,omitempty" gorm:"type:text"`
+}
+
+// Validate 检查推送的事件，返回事件时间，未指定时使用 now
+func (f *AnnotationForm) Validate(now time.Time) (time.Time, error) {
+	if (f.ServerID == 0) == (f.ServerGroupID == 0) {
+		return time.Time{}, errors.New("exactly one of server_id and server_group_id is required")
+	}
+	if f.Title == "" || len(f.Title) > MaxAnnotationTitleSize {
+		return time.Time{}, errors.New("title is required and must be at most 256 bytes")
+	}
+	if len(f.Text) > MaxAnnotationTextSize {
+		return time.Time{}, errors.New("text must be at most 4096 bytes")
+	}
+	if f.Time == 0 {
+		return now, nil
+	}
+	t := time.Unix(f.Time, 0)
+	// 允许补录过去的事件，但不接受明显超前的时间
+	if t.After(now.Add(time.Hour)) {
+		return time.Time{}, errors.New("time is too far in the future")
+	}
+	return t, nil
+}
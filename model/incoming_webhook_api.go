@@ -0,0 +1,19 @@
+package model
+
+type IncomingWebhookForm struct {
+	Name string `json:"name,omitempty" minLength:"1"`
+}
+
+type IncomingWebhookResponse struct {
+	ID uint64 `json:"id,omitempty"`
+	// 密钥明文仅在创建时返回一次，推送时放在 X-Webhook-Secret 请求头中
+	Secret string `json:"secret,omitempty"`
+}
+
+type AnnotationForm struct {
+	ServerID      uint64 `json:"server_id,omitempty" validate:"optional"`
+	ServerGroupID uint64 `json:"server_group_id,omitempty" validate:"optional"`
+	Time          int64  `json:"time,omitempty" validate:"optional"` // 时间戳（秒），默认为接收时间
+	Title         string `json:"title,omitempty"`
+	Text          string `json:"text,omitempty" validate:"optional"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnnotationFormValidate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		name string
+		form AnnotationForm
+		ok   bool
+	}{
+		{"server", AnnotationForm{ServerID: 1, Title: "deploy"}, true},
+		{"group", AnnotationForm{ServerGroupID: 1, Title: "deploy"}, true},
+		{"both", AnnotationForm{ServerID: 1, ServerGroupID: 1, Title: "deploy"}, false},
+		{"neither", AnnotationForm{Title: "deploy"}, false},
+		{"no title", AnnotationForm{ServerID: 1}, false},
+		{"long title", AnnotationForm{ServerID: 1, Title: strings.Repeat("a", MaxAnnotationTitleSize+1)}, false},
+		{"long text", AnnotationForm{ServerID: 1, Title: "deploy", Text: strings.Repeat("a", MaxAnnotationTextSize+1)}, false},
+		{"past", AnnotationForm{ServerID: 1, Title: "deploy", Time: now.Add(-24 * time.Hour).Unix()}, true},
+		{"future", AnnotationForm{ServerID: 1, Title: "deploy", Time: now.Add(2 * time.Hour).Unix()}, false},
+	}
+	for _, c := range cases {
+		if _, err := c.form.Validate(now); (err == nil) != c.ok {
+			t.Errorf("%s: got error %v, want ok %v", c.name, err, c.ok)
+		}
+	}
+
+	at, _ := (&AnnotationForm{ServerID: 1, Title: "deploy"}).Validate(now)
+	if !at.Equal(now) {
+		t.Errorf("default time = %v, want %v", at, now)
+	}
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmES256 = "ES256"
+)
+
+// JWTConfigKeyID 配置文件中的 jwt_secret_key，使用它签发的令牌不带 kid，与旧版本签发的令牌一致
+const JWTConfigKeyID = "config"
+
+// JWTKeyRetention 密钥停用后仍接受其签发的令牌的时长，不短于访问令牌有效期与可刷新时长之和
+const JWTKeyRetention = 2 * time.Hour
+
+// JWTKey 访问令牌的签名密钥，同一时间只有一个未停用的密钥用于签发
+type JWTKey struct {
+	Common
+	KID       string `json:"kid" gorm:"column:kid;uniqueIndex"`
+	Algorithm string `json:"algorithm"`
+	// PEM 编码的 PKCS #8 私钥或 HMAC 密钥，入库时以主密钥加密，配置文件中的密钥不入库
+	PrivateKey string `json:"-" gorm:"type:text"`
+	// 加密私钥所用主密钥的指纹，为空时 PrivateKey 为旧版本保存的明文
+	KeyID     string     `json:"-"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+
+	signKey   any
+	verifyKey any
+}
+
+func ValidJWTAlgorithm(alg string) bool {
+	switch alg {
+	case JWTAlgorithmHS256, JWTAlgorithmRS256, JWTAlgorithmES256:
+		return true
+	}
+	return false
+}
+
+// GenerateJWTKey 生成指定算法的新密钥
+func GenerateJWTKey(alg string) (*JWTKey, error) {
+	kid, err := utils.GenerateRandomString(16)
+	if err != nil {
+		return nil, err
+	}
+	k := &JWTKey{KID: kid, Algorithm: alg}
+
+	var priv any
+	switch alg {
+	case JWTAlgorithmHS256:
+		if k.PrivateKey, err = utils.GenerateRandomString(64); err != nil {
+			return nil, err
+		}
+		return k, k.Init()
+	case JWTAlgorithmRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case JWTAlgorithmES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	k.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	return k, k.Init()
+}
+
+// Init 解析私钥，签名与校验前调用
+func (k *JWTKey) Init() error {
+	if k.Algorithm == JWTAlgorithmHS256 {
+		if k.PrivateKey == "" {
+			return errors.New("empty jwt secret key")
+		}
+		k.signKey, k.verifyKey = []byte(k.PrivateKey), []byte(k.PrivateKey)
+		return nil
+	}
+
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil {
+		return fmt.Errorf("jwt key %s: invalid PEM", k.KID)
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("jwt key %s: %w", k.KID, err)
+	}
+	switch p := priv.(type) {
+	case *rsa.PrivateKey:
+		if k.Algorithm != JWTAlgorithmRS256 {
+			break
+		}
+		k.signKey, k.verifyKey = p, &p.PublicKey
+		return nil
+	case *ecdsa.PrivateKey:
+		if k.Algorithm != JWTAlgorithmES256 || p.Curve != elliptic.P256() {
+			break
+		}
+		k.signKey, k.verifyKey = p, &p.PublicKey
+		return nil
+	}
+	return fmt.Errorf("jwt key %s: key type does not match %s", k.KID, k.Algorithm)
+}
+
+// Sign 签发令牌，配置文件中的密钥之外都在头部写入 kid
+func (k *JWTKey) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.Algorithm), claims)
+	if k.KID != JWTConfigKeyID {
+		token.Header["kid"] = k.KID
+	}
+	return token.SignedString(k.signKey)
+}
+
+// VerifyKey 返回校验 alg 签名的密钥，算法不一致时拒绝，以免将公钥当作 HMAC 密钥
+func (k *JWTKey) VerifyKey(alg string) (any, error) {
+	if alg != k.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method %s", alg)
+	}
+	return k.verifyKey, nil
+}
+
+// JWK 返回公钥的 JWK 表示，对称密钥返回 nil
+func (k *JWTKey) JWK() *JWK {
+	jwk := &JWK{Kid: k.KID, Use: "sig", Alg: k.Algorithm}
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32)))
+	default:
+		return nil
+	}
+	return jwk
+}
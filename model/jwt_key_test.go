@@ -0,0 +1,66 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestJWTKeySignAndVerify(t *testing.T) {
+	for _, alg := range []string{JWTAlgorithmHS256, JWTAlgorithmRS256, JWTAlgorithmES256} {
+		k, err := GenerateJWTKey(alg)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		// 从数据库加载的密钥重新解析
+		stored := &JWTKey{KID: k.KID, Algorithm: k.Algorithm, PrivateKey: k.PrivateKey}
+		if err := stored.Init(); err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+
+		signed, err := k.Sign(jwt.MapClaims{"sub": "1"})
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		token, err := jwt.Parse(signed, func(token *jwt.Token) (any, error) {
+			if token.Header["kid"] != k.KID {
+				t.Errorf("%s: kid = %v, want %s", alg, token.Header["kid"], k.KID)
+			}
+			return stored.VerifyKey(token.Method.Alg())
+		})
+		if err != nil || !token.Valid {
+			t.Fatalf("%s: verify failed: %v", alg, err)
+		}
+
+		if jwk := k.JWK(); (jwk == nil) != (alg == JWTAlgorithmHS256) {
+			t.Errorf("%s: unexpected jwk %+v", alg, jwk)
+		}
+	}
+}
+
+func TestJWTKeyRejectsAlgorithmConfusion(t *testing.T) {
+	k, err := GenerateJWTKey(JWTAlgorithmRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.VerifyKey(JWTAlgorithmHS256); err == nil {
+		t.Fatal("RS256 key should not verify HS256 tokens")
+	}
+
+	// 配置文件中的密钥签发的令牌不带 kid
+	config := &JWTKey{KID: JWTConfigKeyID, Algorithm: JWTAlgorithmHS256, PrivateKey: "secret"}
+	if err := config.Init(); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := config.Sign(jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(signed, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := token.Header["kid"]; ok {
+		t.Fatal("config key should not set kid")
+	}
+}
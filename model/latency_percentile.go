@@ -0,0 +1,84 @@
+package model
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// MaxLatencySamples 计算分位数时保留的样本数上限，超过后以蓄水池抽样保留样本，分位数为估算值
+const MaxLatencySamples = 10000
+
+// LatencyPercentiles 一段时间内延迟的分布，毫秒
+type LatencyPercentiles struct {
+	Count   int     `json:"count"`             // 参与计算的样本数
+	Sampled bool    `json:"sampled,omitempty"` // 样本数超过上限，分位数由抽样估算，平均值仍为精确值
+	Avg     float64 `json:"avg"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P95     float64 `json:"p95"`
+	P99     float64 `json:"p99"`
+}
+
+// LatencySampler 以有限内存累计延迟样本，样本数不超过 MaxLatencySamples 时分位数为精确值
+type LatencySampler struct {
+	count   int
+	avg     float64
+	samples []float64
+	rng     *rand.Rand
+}
+
+// Add 加入一个样本，非正数与 NaN 表示未测得延迟，不参与计算
+func (s *LatencySampler) Add(v float64) {
+	if !(v > 0) || math.IsInf(v, 0) {
+		return
+	}
+	s.count++
+	s.avg += (v - s.avg) / float64(s.count)
+	if len(s.samples) < MaxLatencySamples {
+		s.samples = append(s.samples, v)
+		return
+	}
+	// 固定种子，相同的数据多次查询得到相同的结果
+	if s.rng == nil {
+		s.rng = rand.New(rand.NewPCG(1, 2))
+	}
+	if i := s.rng.IntN(s.count); i < MaxLatencySamples {
+		s.samples[i] = v
+	}
+}
+
+// Percentiles 返回累计样本的分位数，没有样本时返回 nil
+func (s *LatencySampler) Percentiles() *LatencyPercentiles {
+	if s.count == 0 {
+		return nil
+	}
+	sorted := slices.Clone(s.samples)
+	slices.Sort(sorted)
+	return &LatencyPercentiles{
+		Count:   s.count,
+		Sampled: s.count > len(sorted),
+		Avg:     s.avg,
+		P50:     Quantile(sorted, 0.5),
+		P90:     Quantile(sorted, 0.9),
+		P95:     Quantile(sorted, 0.95),
+		P99:     Quantile(sorted, 0.99),
+	}
+}
+
+// Quantile 返回已排序样本的 q 分位数，在相邻的两个样本间线性插值，
+// 因此样本很少时结果不会超出样本的范围，只有一个样本时各分位数均为该样本
+func Quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	if lo < 0 {
+		return sorted[0]
+	}
+	return sorted[lo] + (sorted[lo+1]-sorted[lo])*(pos-float64(lo))
+}
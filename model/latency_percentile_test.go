@@ -0,0 +1,67 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLatencySamplerSparse(t *testing.T) {
+	var s LatencySampler
+	if s.Percentiles() != nil {
+		t.Fatal("percentiles of no samples should be nil")
+	}
+	s.Add(0)
+	s.Add(math.NaN())
+	s.Add(-1)
+	if s.Percentiles() != nil {
+		t.Fatal("missing latencies should be ignored")
+	}
+
+	s.Add(42)
+	p := s.Percentiles()
+	if p.Count != 1 || p.Avg != 42 || p.P50 != 42 || p.P99 != 42 {
+		t.Fatalf("single sample: %+v", p)
+	}
+
+	s.Add(10)
+	p = s.Percentiles()
+	if p.P50 != 26 || p.P90 != 38.8 || p.P99 > 42 || p.Avg != 26 {
+		t.Fatalf("two samples: %+v", p)
+	}
+}
+
+func TestLatencySamplerExact(t *testing.T) {
+	var s LatencySampler
+	for i := 100; i >= 1; i-- {
+		s.Add(float64(i))
+	}
+	p := s.Percentiles()
+	if p.Sampled || p.Count != 100 || p.Avg != 50.5 {
+		t.Fatalf("unexpected summary: %+v", p)
+	}
+	for got, want := range map[float64]float64{p.P50: 50.5, p.P90: 90.1, p.P95: 95.05, p.P99: 99.01} {
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLatencySamplerBounded(t *testing.T) {
+	var s LatencySampler
+	n := MaxLatencySamples * 10
+	for i := 1; i <= n; i++ {
+		s.Add(float64(i))
+	}
+	if len(s.samples) != MaxLatencySamples {
+		t.Fatalf("expected %d samples, got %d", MaxLatencySamples, len(s.samples))
+	}
+	p := s.Percentiles()
+	if !p.Sampled || p.Count != n || p.Avg != float64(n+1)/2 {
+		t.Fatalf("unexpected summary: %+v", p)
+	}
+	for got, q := range map[float64]float64{p.P50: 0.5, p.P90: 0.9, p.P99: 0.99} {
+		if math.Abs(got/float64(n)-q) > 0.02 {
+			t.Errorf("estimated p%v = %v is too far from %v", q*100, got, q*float64(n))
+		}
+	}
+}
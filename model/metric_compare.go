@@ -0,0 +1,76 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// 可对比的服务器指标，只有持久化了历史记录的指标可以对比
+const (
+	MetricTransferIn   = "transfer_in"   // 每小时入站流量（字节）
+	MetricTransferOut  = "transfer_out"  // 每小时出站流量（字节）
+	MetricServiceDelay = "service_delay" // 该服务器上报的服务监控延迟（毫秒），需指定服务
+	MetricCustom       = "custom"        // Agent 上报的自定义指标，需指定名称
+)
+
+// MaxMetricCompareBuckets 对比结果的最大区间数
+const MaxMetricCompareBuckets = 1000
+
+type MetricPoint struct {
+	At    time.Time
+	Value float64
+}
+
+type MetricCompareBucket struct {
+	Offset int64             `json:"offset"`      // 相对于各时间范围起点的偏移（秒）
+	A      *HistoryAggregate `json:"a,omitempty"` // 区间内无数据时为空
+	B      *HistoryAggregate `json:"b,omitempty"`
+}
+
+type MetricCompareResponse struct {
+	Metric    string                `json:"metric"`
+	Interval  int64                 `json:"interval"` // 聚合区间（秒）
+	AFrom     int64                 `json:"a_from"`   // 时间戳（秒）
+	BFrom     int64                 `json:"b_from"`
+	Duration  int64                 `json:"duration"`            // 实际对比的时长（秒）
+	Truncated bool                  `json:"truncated,omitempty"` // 两个时间范围长度不同，已按较短的截断
+	Buckets   []MetricCompareBucket `json:"buckets"`
+}
+
+// MetricCompareRange 对比的两个时间范围，按较短的范围截断，返回对比的时长与是否截断
+func MetricCompareRange(aFrom, aTo, bFrom, bTo time.Time) (time.Duration, bool, error) {
+	a, b := aTo.Sub(aFrom), bTo.Sub(bFrom)
+	if a <= 0 || b <= 0 {
+		return 0, false, errors.New("time range end must be after start")
+	}
+	return min(a, b), a != b, nil
+}
+
+// CompareMetricSeries 按相对于各自起点的偏移将两组数据点聚合到相同的区间，超出 duration 的数据点被忽略
+func CompareMetricSeries(a, b []MetricPoint, aFrom, bFrom time.Time, duration, interval time.Duration) ([]MetricCompareBucket, error) {
+	n := int((duration + interval - 1) / interval)
+	if n > MaxMetricCompareBuckets {
+		return nil, errors.New("too many buckets, use a larger interval")
+	}
+	buckets := make([]MetricCompareBucket, n)
+	for i := range buckets {
+		buckets[i].Offset = int64(time.Duration(i) * interval / time.Second)
+	}
+
+	add := func(points []MetricPoint, from time.Time, slot func(*MetricCompareBucket) **HistoryAggregate) {
+		for _, p := range points {
+			offset := p.At.Sub(from)
+			if offset < 0 || offset >= duration {
+				continue
+			}
+			agg := slot(&buckets[offset/interval])
+			if *agg == nil {
+				*agg = &HistoryAggregate{}
+			}
+			(*agg).Add(p.Value)
+		}
+	}
+	add(a, aFrom, func(b *MetricCompareBucket) **HistoryAggregate { return &b.A })
+	add(b, bFrom, func(b *MetricCompareBucket) **HistoryAggregate { return &b.B })
+	return buckets, nil
+}
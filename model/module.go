@@ -0,0 +1,92 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+)
+
+// 可按角色隐藏的功能模块，隐藏后该模块的接口对该角色返回 403
+const (
+	ModuleServer       = "server"
+	ModuleService      = "service"
+	ModuleNotification = "notification"
+	ModuleAlertRule    = "alert-rule"
+	ModuleCron         = "cron"
+	ModuleDDNS         = "ddns"
+	ModuleNAT          = "nat"
+	ModuleTerminal     = "terminal"
+	ModuleUser         = "user"
+	ModuleWAF          = "waf"
+	ModuleSetting      = "setting"
+)
+
+// Module 功能模块及进入该模块所需的权限，为 0 时只要模块可见即可访问，数据仍按归属筛选
+type Module struct {
+	Name       string
+	Permission uint64
+}
+
+var Modules = []Module{
+	{ModuleServer, PermissionServerRead},
+	{ModuleService, 0},
+	{ModuleNotification, 0},
+	{ModuleAlertRule, 0},
+	{ModuleCron, 0},
+	{ModuleDDNS, 0},
+	{ModuleNAT, 0},
+	{ModuleTerminal, PermissionTerminal},
+	{ModuleUser, PermissionUser},
+	{ModuleWAF, 0},
+	{ModuleSetting, 0},
+}
+
+// RoleModules 角色可访问的模块与登录后默认打开的页面
+type RoleModules struct {
+	Modules []string `mapstructure:"modules" json:"modules,omitempty"` // 为空时不限制
+	Landing string   `mapstructure:"landing" json:"landing,omitempty"` // 前端路由，为空时由前端决定
+}
+
+// Allows 判断角色是否可访问 module
+func (r *RoleModules) Allows(module string) bool {
+	return len(r.Modules) == 0 || slices.Contains(r.Modules, module)
+}
+
+// RoleModulesConfig 按角色配置的模块可见性，仅通过配置文件设置
+type RoleModulesConfig struct {
+	Admin  RoleModules `mapstructure:"admin" json:"admin,omitempty"`
+	Member RoleModules `mapstructure:"member" json:"member,omitempty"`
+}
+
+func (c *RoleModulesConfig) Of(role uint8) *RoleModules {
+	if role == RoleAdmin {
+		return &c.Admin
+	}
+	return &c.Member
+}
+
+func (c *RoleModulesConfig) Validate() error {
+	for _, r := range []*RoleModules{&c.Admin, &c.Member} {
+		for _, m := range r.Modules {
+			if !slices.ContainsFunc(Modules, func(e Module) bool { return e.Name == m }) {
+				return fmt.Errorf("role_modules: unknown module %s", m)
+			}
+		}
+	}
+	return nil
+}
+
+// CanAccessModule 判断用户的角色是否可访问 module，超级管理员不受角色配置限制，避免配置错误后无法恢复
+func (u *User) CanAccessModule(conf *RoleModulesConfig, module string) bool {
+	return u.IsSuperAdmin() || conf.Of(u.Role).Allows(module)
+}
+
+// VisibleModules 返回用户可访问且拥有所需权限的模块，供前端渲染导航
+func (u *User) VisibleModules(conf *RoleModulesConfig) []string {
+	modules := make([]string, 0, len(Modules))
+	for _, m := range Modules {
+		if u.CanAccessModule(conf, m.Name) && u.Can(m.Permission) {
+			modules = append(modules, m.Name)
+		}
+	}
+	return modules
+}
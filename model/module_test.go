@@ -0,0 +1,37 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUserVisibleModules(t *testing.T) {
+	conf := &RoleModulesConfig{Member: RoleModules{Modules: []string{ModuleServer, ModuleCron, ModuleUser}}}
+
+	member := &User{Role: RoleMember, Permissions: DefaultMemberPermissions}
+	if got := member.VisibleModules(conf); !slices.Equal(got, []string{ModuleServer, ModuleCron}) {
+		t.Fatalf("unexpected member modules: %v", got)
+	}
+	if member.CanAccessModule(conf, ModuleService) {
+		t.Fatal("hidden module should not be accessible")
+	}
+
+	// 未配置时与原有权限一致
+	if got := member.VisibleModules(&RoleModulesConfig{}); slices.Contains(got, ModuleUser) || len(got) != len(Modules)-1 {
+		t.Fatalf("unexpected default member modules: %v", got)
+	}
+
+	conf.Admin.Modules = []string{ModuleServer}
+	superAdmin := &User{Role: RoleAdmin}
+	if got := superAdmin.VisibleModules(conf); len(got) != len(Modules) {
+		t.Fatalf("super admin should see all modules, got %v", got)
+	}
+	tenantAdmin := &User{Role: RoleAdmin, TenantID: 1}
+	if tenantAdmin.CanAccessModule(conf, ModuleCron) {
+		t.Fatal("tenant admin should follow the admin role config")
+	}
+
+	if err := (&RoleModulesConfig{Member: RoleModules{Modules: []string{"tenant"}}}).Validate(); err == nil {
+		t.Fatal("unknown module should be rejected")
+	}
+}
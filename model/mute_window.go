@@ -0,0 +1,143 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const (
+	_ uint8 = iota
+	MuteWindowTypeOnce
+	MuteWindowTypeDaily
+	MuteWindowTypeWeekly
+)
+
+// MuteWindow 静音窗口，生效期间抑制匹配的通知，但不影响报警规则本身
+type MuteWindow struct {
+	Common
+	Name string `json:"name"`
+	Type uint8  `json:"type"` // 1:一次性 2:每天 3:每周
+	// 为 0 时匹配全部通知组/服务器
+	NotificationGroupID uint64 `json:"notification_group_id"`
+	ServerID            uint64 `json:"server_id"`
+
+	// 一次性窗口的起止时间
+	StartAt *time.Time `json:"start_at,omitempty"`
+	EndAt   *time.Time `json:"end_at,omitempty"`
+
+	// 周期窗口的起止时刻，格式为 HH:MM，结束时刻早于开始时刻时表示跨天
+	StartTime   string `json:"start_time,omitempty"`
+	EndTime     string `json:"end_time,omitempty"`
+	WeekdaysRaw string `gorm:"default:'[]'" json:"-"`
+	Weekdays    []int  `gorm:"-" json:"weekdays,omitempty"` // 0 为周日
+	Timezone    string `json:"timezone,omitempty"`
+
+	loc *time.Location
+}
+
+func (m *MuteWindow) BeforeSave(tx *gorm.DB) error {
+	if data, err := utils.Json.Marshal(m.Weekdays); err != nil {
+		return err
+	} else {
+		m.WeekdaysRaw = string(data)
+	}
+	return nil
+}
+
+func (m *MuteWindow) AfterFind(tx *gorm.DB) error {
+	if m.WeekdaysRaw == "" {
+		return nil
+	}
+	return utils.Json.Unmarshal([]byte(m.WeekdaysRaw), &m.Weekdays)
+}
+
+// Validate 校验窗口配置并加载时区
+func (m *MuteWindow) Validate() error {
+	switch m.Type {
+	case MuteWindowTypeOnce:
+		if m.StartAt == nil || m.EndAt == nil || !m.EndAt.After(*m.StartAt) {
+			return fmt.Errorf("invalid window range")
+		}
+		return nil
+	case MuteWindowTypeDaily, MuteWindowTypeWeekly:
+	default:
+		return fmt.Errorf("unknown window type %d", m.Type)
+	}
+
+	if _, err := parseClock(m.StartTime); err != nil {
+		return err
+	}
+	if _, err := parseClock(m.EndTime); err != nil {
+		return err
+	}
+	for _, d := range m.Weekdays {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("invalid weekday %d", d)
+		}
+	}
+	if m.Type == MuteWindowTypeWeekly && len(m.Weekdays) == 0 {
+		return fmt.Errorf("weekdays can't be empty")
+	}
+
+	loc, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return err
+	}
+	m.loc = loc
+	return nil
+}
+
+// Matches 窗口是否覆盖指定通知组与服务器
+func (m *MuteWindow) Matches(notificationGroupID, serverID uint64) bool {
+	if m.NotificationGroupID != 0 && m.NotificationGroupID != notificationGroupID {
+		return false
+	}
+	if m.ServerID != 0 && m.ServerID != serverID {
+		return false
+	}
+	return true
+}
+
+// Active 窗口在给定时间是否生效
+func (m *MuteWindow) Active(t time.Time) bool {
+	if m.Type == MuteWindowTypeOnce {
+		return m.StartAt != nil && m.EndAt != nil && !t.Before(*m.StartAt) && t.Before(*m.EndAt)
+	}
+
+	if m.loc == nil {
+		if err := m.Validate(); err != nil {
+			return false
+		}
+	}
+
+	start, _ := parseClock(m.StartTime)
+	end, _ := parseClock(m.EndTime)
+	local := t.In(m.loc)
+	now := local.Hour()*60 + local.Minute()
+	today := int(local.Weekday())
+	yesterday := (today + 6) % 7
+
+	if start <= end {
+		return now >= start && now < end && m.onDay(today)
+	}
+	// 跨天窗口：今天开始后的部分，或前一天开始延续到今天的部分
+	return (now >= start && m.onDay(today)) || (now < end && m.onDay(yesterday))
+}
+
+func (m *MuteWindow) onDay(weekday int) bool {
+	return m.Type == MuteWindowTypeDaily || slices.Contains(m.Weekdays, weekday)
+}
+
+// parseClock 将 HH:MM 解析为当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
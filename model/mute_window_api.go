@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+type MuteWindowForm struct {
+	Name                string     `json:"name" minLength:"1"`
+	Type                uint8      `json:"type"`
+	NotificationGroupID uint64     `json:"notification_group_id,omitempty" validate:"optional"`
+	ServerID            uint64     `json:"server_id,omitempty" validate:"optional"`
+	StartAt             *time.Time `json:"start_at,omitempty" validate:"optional"`
+	EndAt               *time.Time `json:"end_at,omitempty" validate:"optional"`
+	StartTime           string     `json:"start_time,omitempty" validate:"optional"`
+	EndTime             string     `json:"end_time,omitempty" validate:"optional"`
+	Weekdays            []int      `json:"weekdays,omitempty" validate:"optional"`
+	Timezone            string     `json:"timezone,omitempty" validate:"optional"`
+}
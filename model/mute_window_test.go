@@ -0,0 +1,56 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMuteWindowActive(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	cases := []struct {
+		window MuteWindow
+		at     time.Time
+		active bool
+	}{
+		{MuteWindow{Type: MuteWindowTypeOnce, StartAt: &start, EndAt: &end}, start.Add(time.Minute * 30), true},
+		{MuteWindow{Type: MuteWindowTypeOnce, StartAt: &start, EndAt: &end}, end, false},
+		{MuteWindow{Type: MuteWindowTypeDaily, StartTime: "02:00", EndTime: "04:00", Timezone: "UTC"},
+			time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), true},
+		{MuteWindow{Type: MuteWindowTypeDaily, StartTime: "02:00", EndTime: "04:00", Timezone: "UTC"},
+			time.Date(2024, 1, 1, 4, 0, 0, 0, time.UTC), false},
+		// 时区换算：UTC 18:30 为上海时间次日 02:30
+		{MuteWindow{Type: MuteWindowTypeDaily, StartTime: "02:00", EndTime: "04:00", Timezone: "Asia/Shanghai"},
+			time.Date(2024, 1, 1, 18, 30, 0, 0, time.UTC), true},
+		// 跨天窗口：周一 23:00 至周二 01:00
+		{MuteWindow{Type: MuteWindowTypeWeekly, StartTime: "23:00", EndTime: "01:00", Weekdays: []int{1}, Timezone: "UTC"},
+			time.Date(2024, 1, 2, 0, 30, 0, 0, time.UTC), true},
+		{MuteWindow{Type: MuteWindowTypeWeekly, StartTime: "23:00", EndTime: "01:00", Weekdays: []int{1}, Timezone: "UTC"},
+			time.Date(2024, 1, 3, 0, 30, 0, 0, time.UTC), false},
+	}
+
+	for i, c := range cases {
+		if active := c.window.Active(c.at); active != c.active {
+			t.Errorf("case %d: expected %t, but got %t", i, c.active, active)
+		}
+	}
+}
+
+func TestNotificationMuteActive(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		mute   NotificationMute
+		active bool
+	}{
+		{NotificationMute{}, false},
+		{NotificationMute{Enabled: true}, true},
+		{NotificationMute{Enabled: true, Until: now.Unix() + 60}, true},
+		{NotificationMute{Enabled: true, Until: now.Unix()}, false},
+	}
+	for i, c := range cases {
+		if got := c.mute.Active(now); got != c.active {
+			t.Errorf("case %d: got %v, want %v", i, got, c.active)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -22,23 +23,196 @@ const (
 	_ = iota
 	NotificationRequestMethodGET
 	NotificationRequestMethodPOST
+	NotificationRequestMethodPUT
+	NotificationRequestMethodPATCH
+)
+
+const (
+	NotificationTypeWebhook = iota
+	NotificationTypeTelegram
+	NotificationTypeSlack
+	NotificationTypeSignedWebhook
+	NotificationTypeEmail
+	NotificationTypeTwilio
+)
+
+const (
+	// 触发频率限制时最多重试的次数与单次最长等待时间
+	notificationMaxRetries    = 3
+	notificationMaxRetryAfter = time.Minute
+
+	// 发送失败后重新排队的次数上限，以及未设置时首次重试前的等待时长与等待时长的上限
+	NotificationMaxAttemptsLimit = 10
+	notificationDefaultBackoff   = 10 * time.Second
+	notificationMaxBackoff       = time.Hour
 )
 
 type NotificationServerBundle struct {
 	Notification *Notification
 	Server       *Server
-	Loc          *time.Location
+	Alert        *AlertRule
+	// 报警规则的恢复通知
+	Resolved bool
+	Loc      *time.Location
+	// 面板语言，通知方式未指定语言或缺少对应翻译时使用
+	DefaultLanguage string
+	// 将 msgid 翻译为指定语言，缺少翻译时返回 msgid，为空时不翻译
+	Translate func(lang, msgid string) string
+	// 最近一次请求的响应状态码，由 Webhook 类通知方式填写
+	StatusCode int
+	// 服务商返回的投递状态，如 Twilio 各号码的短信状态
+	DeliveryStatus string
+	// 同一条通知各次尝试共用的 ID，签名 Webhook 将其写入请求体，接收方可据此去重
+	DeliveryID string
+	// 已发送成功的收件号码，重试同一条通知时跳过，避免部分成功时重复发送
+	Delivered map[string]bool
 }
 
 type Notification struct {
 	Common
 	Name          string `json:"name"`
+	Type          uint8  `json:"type"`
 	URL           string `json:"url"`
 	RequestMethod uint8  `json:"request_method"`
 	RequestType   uint8  `json:"request_type"`
 	RequestHeader string `json:"request_header" gorm:"type:longtext"`
 	RequestBody   string `json:"request_body" gorm:"type:longtext"`
 	VerifyTLS     *bool  `json:"verify_tls,omitempty"`
+	// Telegram
+	BotToken string `json:"bot_token,omitempty"`
+	ChatID   string `json:"chat_id,omitempty"`
+	// Slack / Mattermost，为空时使用 Incoming Webhook 的默认频道
+	Channel string `json:"channel,omitempty"`
+	// 签名 Webhook 的共享密钥，用于计算 HMAC-SHA256 签名
+	Secret string `json:"secret,omitempty"`
+	// 邮件（SMTP），多个收件人以逗号分隔，用户名为空时不进行认证
+	SMTPHost     string `json:"smtp_host,omitempty"`
+	SMTPPort     uint16 `json:"smtp_port,omitempty"`     // 为 0 时按加密方式使用 25、587 或 465
+	SMTPSecurity uint8  `json:"smtp_security,omitempty"` // 0 明文，1 STARTTLS，2 TLS
+	SMTPUsername string `json:"smtp_username,omitempty"`
+	SMTPPassword string `json:"smtp_password,omitempty"`
+	EmailFrom    string `json:"email_from,omitempty"`
+	EmailTo      string `json:"email_to,omitempty"`
+	EmailCc      string `json:"email_cc,omitempty"`
+	// 邮件主题，支持占位符，为空时使用消息的第一行
+	EmailSubject string `json:"email_subject,omitempty"`
+	// Twilio 短信，号码使用 E.164 格式，多个收件号码以逗号分隔；Auth Token 读取时被隐去
+	TwilioAccountSID string `json:"twilio_account_sid,omitempty"`
+	TwilioAuthToken  string `json:"twilio_auth_token,omitempty"`
+	TwilioFrom       string `json:"twilio_from,omitempty"`
+	TwilioTo         string `json:"twilio_to,omitempty"`
+	// 消息模板，为空时直接发送原始通知内容，支持与请求体相同的占位符以及 text/template 的条件与辅助函数
+	Template string `json:"template,omitempty" gorm:"type:longtext"`
+	// 通知语言，为空时使用面板语言
+	Language string `json:"language,omitempty"`
+	// 按语言区分的消息模板，缺少对应语言时依次使用面板语言的模板与 Template
+	TemplatesRaw string            `gorm:"type:longtext;default:'{}'" json:"-"`
+	Templates    map[string]string `gorm:"-" json:"templates,omitempty" validate:"optional"`
+
+	// 只接收这些严重程度的报警通知，为空时接收全部；报警规则之外的通知不受影响
+	SeveritiesRaw string   `gorm:"default:'[]'" json:"-"`
+	Severities    []string `gorm:"-" json:"severities,omitempty" validate:"optional"`
+
+	// 额外的接收方，主接收方之外逐个发送
+	Recipients []NotificationRecipient `json:"recipients,omitempty" gorm:"-" validate:"optional"`
+
+	// 发送失败后的重试策略：最多尝试的次数（含首次发送，为 0 或 1 时不重试），
+	// 以及首次重试前等待的秒数（为 0 时使用 10 秒），之后每次重试的等待时长加倍
+	RetryMaxAttempts uint8  `json:"retry_max_attempts,omitempty"`
+	RetryBackoff     uint32 `json:"retry_backoff,omitempty"`
+}
+
+// NotificationLog 通知发送记录，用于排查发送失败
+type NotificationLog struct {
+	Common
+	NotificationID uint64 `json:"notification_id,omitempty" gorm:"index"`
+	RecipientID    uint64 `json:"recipient_id,omitempty"` // 为 0 时表示主接收方
+	Success        bool   `json:"success,omitempty"`
+	StatusCode     int    `json:"status_code,omitempty"`
+	Message        string `json:"message,omitempty" gorm:"type:longtext"`
+	Error          string `json:"error,omitempty" gorm:"type:longtext"`
+	DeliveryStatus string `json:"delivery_status,omitempty"` // 服务商返回的投递状态
+	DeliveryID     string `json:"delivery_id,omitempty"`     // 同一条通知的各次尝试相同
+	Attempt        int    `json:"attempt,omitempty"`         // 第几次尝试，从 1 开始
+}
+
+// NotificationDeadLetter 重试次数用尽仍未发送成功的通知
+type NotificationDeadLetter struct {
+	Common
+	NotificationID uint64 `json:"notification_id,omitempty" gorm:"index"`
+	RecipientID    uint64 `json:"recipient_id,omitempty"` // 为 0 时表示主接收方
+	DeliveryID     string `json:"delivery_id,omitempty"`
+	Attempts       int    `json:"attempts,omitempty"`
+	Message        string `json:"message,omitempty" gorm:"type:longtext"`
+	Error          string `json:"error,omitempty" gorm:"type:longtext"` // 最后一次尝试的错误
+}
+
+// MaxAttempts 返回一条通知最多尝试发送的次数
+func (n *Notification) MaxAttempts() int {
+	return max(int(n.RetryMaxAttempts), 1)
+}
+
+// RetryDelay 返回第 attempt 次尝试失败后，下一次重试前等待的时长
+func (n *Notification) RetryDelay(attempt int) time.Duration {
+	delay := notificationDefaultBackoff
+	if n.RetryBackoff > 0 {
+		delay = time.Duration(n.RetryBackoff) * time.Second
+	}
+	for i := 1; i < attempt && delay < notificationMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, notificationMaxBackoff)
+}
+
+// RedactSecrets 隐去读取时不返回的凭据
+func (n *Notification) RedactSecrets() {
+	n.TwilioAuthToken = redact(n.TwilioAuthToken)
+}
+
+// RestoreSecrets 提交的凭据为隐去的占位符时沿用 old 中的值
+func (n *Notification) RestoreSecrets(old *Notification) {
+	if n.TwilioAuthToken == RedactedValue {
+		n.TwilioAuthToken = old.TwilioAuthToken
+	}
+}
+
+// AcceptsSeverity 判断通知方式是否接收该严重程度的报警通知
+func (n *Notification) AcceptsSeverity(severity string) bool {
+	return len(n.Severities) == 0 || slices.Contains(n.Severities, severity)
+}
+
+// Validate 检查不同类型通知方式的必填项
+func (n *Notification) Validate() error {
+	if n.RetryMaxAttempts > NotificationMaxAttemptsLimit {
+		return fmt.Errorf("retry max attempts can't exceed %d", NotificationMaxAttemptsLimit)
+	}
+	if err := n.validateTemplates(); err != nil {
+		return err
+	}
+	switch n.Type {
+	case NotificationTypeWebhook, NotificationTypeSlack:
+		if n.URL == "" {
+			return errors.New("url is required")
+		}
+	case NotificationTypeTelegram:
+		if n.BotToken == "" || n.ChatID == "" {
+			return errors.New("bot token and chat id are required")
+		}
+	case NotificationTypeSignedWebhook:
+		if n.URL == "" || n.Secret == "" {
+			return errors.New("url and secret are required")
+		}
+		if n.RequestMethod == NotificationRequestMethodGET {
+			return errors.New("signed webhook requires a request body, GET is not allowed")
+		}
+	case NotificationTypeEmail:
+		return n.validateEmail()
+	case NotificationTypeTwilio:
+		return n.validateTwilio()
+	default:
+		return errors.New("unsupported notification type")
+	}
+	return nil
 }
 
 func (ns *NotificationServerBundle) reqURL(message string) string {
@@ -54,6 +228,10 @@ func (n *Notification) reqMethod() (string, error) {
 		return http.MethodPost, nil
 	case NotificationRequestMethodGET:
 		return http.MethodGet, nil
+	case NotificationRequestMethodPUT:
+		return http.MethodPut, nil
+	case NotificationRequestMethodPATCH:
+		return http.MethodPatch, nil
 	}
 	return "", errors.New("不支持的请求方式")
 }
@@ -108,14 +286,53 @@ func (n *Notification) setRequestHeader(req *http.Request) error {
 	return nil
 }
 
-func (ns *NotificationServerBundle) Send(message string) error {
-	var client *http.Client
-	n := ns.Notification
+func (n *Notification) httpClient() *http.Client {
 	if n.VerifyTLS != nil && *n.VerifyTLS {
-		client = utils.HttpClient
-	} else {
-		client = utils.HttpClientSkipTlsVerify
+		return utils.HttpClient
+	}
+	return utils.HttpClientSkipTlsVerify
+}
+
+// Send 按通知方式的类型发送通知
+func (ns *NotificationServerBundle) Send(message string) error {
+	switch ns.Notification.Type {
+	case NotificationTypeTelegram:
+		return ns.sendTelegram(ns.render(message))
+	case NotificationTypeSlack:
+		return ns.sendSlack(ns.render(message))
+	case NotificationTypeSignedWebhook:
+		return ns.sendSignedWebhook(ns.render(message))
+	case NotificationTypeEmail:
+		return ns.sendEmail(ns.render(message))
+	case NotificationTypeTwilio:
+		return ns.sendTwilio(ns.render(message))
+	}
+	return ns.sendWebhook(message)
+}
+
+// retryOnRateLimit 执行请求，被限流时按返回的等待时长重试
+func retryOnRateLimit(do func() (time.Duration, error)) error {
+	for i := 0; ; i++ {
+		retryAfter, err := do()
+		if retryAfter == 0 || i >= notificationMaxRetries {
+			return err
+		}
+		time.Sleep(min(retryAfter, notificationMaxRetryAfter))
 	}
+}
+
+// render 使用消息模板生成最终发送的文本
+func (ns *NotificationServerBundle) render(message string) string {
+	template := ns.template()
+	if template == "" {
+		return message
+	}
+	return ns.renderTemplate(template, message)
+}
+
+func (ns *NotificationServerBundle) sendWebhook(message string) error {
+	n := ns.Notification
+	client := n.httpClient()
 
 	reqBody, err := ns.reqBody(message)
 	if err != nil {
@@ -145,6 +362,7 @@ func (ns *NotificationServerBundle) Send(message string) error {
 	defer func() {
 		_ = resp.Body.Close()
 	}()
+	ns.StatusCode = resp.StatusCode
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		body, _ := io.ReadAll(resp.Body)
@@ -164,8 +382,29 @@ func (ns *NotificationServerBundle) replaceParamsInString(str string, message st
 		}
 	}
 
+	// 先展开本地化文本，译文中的占位符随后一并替换
+	str = ns.localize(str, mod)
+
+	now := time.Now()
 	str = strings.ReplaceAll(str, "#NEZHA#", mod(message))
-	str = strings.ReplaceAll(str, "#DATETIME#", mod(time.Now().In(ns.Loc).String()))
+	str = strings.ReplaceAll(str, "#DATETIME#", mod(now.In(ns.Loc).String()))
+	str = strings.ReplaceAll(str, "#TIMESTAMP#", mod(fmt.Sprintf("%d", now.Unix())))
+
+	if ns.Alert != nil {
+		metrics, thresholds := ns.Alert.Describe()
+		str = strings.ReplaceAll(str, "#ALERT.NAME#", mod(ns.Alert.Name))
+		str = strings.ReplaceAll(str, "#ALERT.METRIC#", mod(metrics))
+		str = strings.ReplaceAll(str, "#ALERT.THRESHOLD#", mod(thresholds))
+		str = strings.ReplaceAll(str, "#ALERT.RATE#", mod(ns.Alert.Rate))
+		str = strings.ReplaceAll(str, "#ALERT.SEVERITY#", mod(ns.Alert.GetSeverity()))
+		var incidentID, duration, peak string
+		if inc := ns.Alert.Incident; inc != nil {
+			incidentID, duration, peak = inc.ID, inc.Duration(now).String(), inc.DescribePeak(ns.Alert)
+		}
+		str = strings.ReplaceAll(str, "#ALERT.INCIDENT#", mod(incidentID))
+		str = strings.ReplaceAll(str, "#ALERT.DURATION#", mod(duration))
+		str = strings.ReplaceAll(str, "#ALERT.PEAK#", mod(peak))
+	}
 
 	if ns.Server != nil {
 		str = strings.ReplaceAll(str, "#SERVER.NAME#", mod(ns.Server.Name))
@@ -192,24 +431,7 @@ func (ns *NotificationServerBundle) replaceParamsInString(str string, message st
 		str = strings.ReplaceAll(str, "#SERVER.TCPCONNCOUNT#", mod(fmt.Sprintf("%d", ns.Server.State.TcpConnCount)))
 		str = strings.ReplaceAll(str, "#SERVER.UDPCONNCOUNT#", mod(fmt.Sprintf("%d", ns.Server.State.UdpConnCount)))
 
-		var ipv4, ipv6, validIP string
-		ipList := strings.Split(ns.Server.GeoIP.IP.Join(), "/")
-		if len(ipList) > 1 {
-			// 双栈
-			ipv4 = ipList[0]
-			ipv6 = ipList[1]
-			validIP = ipv4
-		} else if len(ipList) == 1 {
-			// 仅ipv4|ipv6
-			if strings.IndexByte(ipList[0], ':') != -1 {
-				ipv6 = ipList[0]
-				validIP = ipv6
-			} else {
-				ipv4 = ipList[0]
-				validIP = ipv4
-			}
-		}
-
+		validIP, ipv4, ipv6 := serverIPs(ns.Server)
 		str = strings.ReplaceAll(str, "#SERVER.IP#", mod(validIP))
 		str = strings.ReplaceAll(str, "#SERVER.IPV4#", mod(ipv4))
 		str = strings.ReplaceAll(str, "#SERVER.IPV6#", mod(ipv6))
@@ -217,3 +439,27 @@ func (ns *NotificationServerBundle) replaceParamsInString(str string, message st
 
 	return str
 }
+
+// serverIPs 返回服务器的 IP，双栈时优先使用 IPv4
+func serverIPs(s *Server) (validIP, ipv4, ipv6 string) {
+	if s.GeoIP == nil {
+		return
+	}
+	ipList := strings.Split(s.GeoIP.IP.Join(), "/")
+	if len(ipList) > 1 {
+		// 双栈
+		ipv4 = ipList[0]
+		ipv6 = ipList[1]
+		validIP = ipv4
+	} else if len(ipList) == 1 {
+		// 仅ipv4|ipv6
+		if strings.IndexByte(ipList[0], ':') != -1 {
+			ipv6 = ipList[0]
+			validIP = ipv6
+		} else {
+			ipv4 = ipList[0]
+			validIP = ipv4
+		}
+	}
+	return
+}
@@ -2,11 +2,51 @@ package model
 
 type NotificationForm struct {
 	Name          string `json:"name,omitempty" minLength:"1"`
+	Type          uint8  `json:"type,omitempty" validate:"optional"`
 	URL           string `json:"url,omitempty"`
 	RequestMethod uint8  `json:"request_method,omitempty"`
 	RequestType   uint8  `json:"request_type,omitempty"`
 	RequestHeader string `json:"request_header,omitempty"`
 	RequestBody   string `json:"request_body,omitempty"`
 	VerifyTLS     bool   `json:"verify_tls,omitempty" validate:"optional"`
-	SkipCheck     bool   `json:"skip_check,omitempty" validate:"optional"`
+	BotToken      string `json:"bot_token,omitempty" validate:"optional"`
+	ChatID        string `json:"chat_id,omitempty" validate:"optional"`
+	Channel       string `json:"channel,omitempty" validate:"optional"`
+	Secret        string `json:"secret,omitempty" validate:"optional"`
+	SMTPHost      string `json:"smtp_host,omitempty" validate:"optional"`
+	SMTPPort      uint16 `json:"smtp_port,omitempty" validate:"optional"`
+	SMTPSecurity  uint8  `json:"smtp_security,omitempty" validate:"optional"`
+	SMTPUsername  string `json:"smtp_username,omitempty" validate:"optional"`
+	SMTPPassword  string `json:"smtp_password,omitempty" validate:"optional"`
+	EmailFrom     string `json:"email_from,omitempty" validate:"optional"`
+	EmailTo       string `json:"email_to,omitempty" validate:"optional"`
+	EmailCc       string `json:"email_cc,omitempty" validate:"optional"`
+	EmailSubject  string `json:"email_subject,omitempty" validate:"optional"`
+	// Twilio 短信，编辑时 Auth Token 传入读取到的占位符表示不修改
+	TwilioAccountSID string `json:"twilio_account_sid,omitempty" validate:"optional"`
+	TwilioAuthToken  string `json:"twilio_auth_token,omitempty" validate:"optional"`
+	TwilioFrom       string `json:"twilio_from,omitempty" validate:"optional"`
+	TwilioTo         string `json:"twilio_to,omitempty" validate:"optional"` // 多个号码以逗号分隔
+	Template         string `json:"template,omitempty" validate:"optional"`  // 支持 {{ if }} 等 text/template 语法，保存时检查
+	Language         string `json:"language,omitempty" validate:"optional"`  // 为空时使用面板语言
+	SkipCheck        bool   `json:"skip_check,omitempty" validate:"optional"`
+
+	// 只接收这些严重程度的报警通知，为空时接收全部
+	Severities []string `json:"severities,omitempty" enums:"info,warning,critical" validate:"optional"`
+
+	// 按语言区分的消息模板，如 {"en_US": "...", "zh_CN": "..."}
+	Templates map[string]string `json:"templates,omitempty" validate:"optional"`
+
+	// 最多尝试发送的次数（含首次发送），为 0 或 1 时不重试
+	RetryMaxAttempts uint8 `json:"retry_max_attempts,omitempty" maximum:"10" validate:"optional"`
+	// 首次重试前等待的秒数，之后每次加倍，为 0 时使用 10 秒
+	RetryBackoff uint32 `json:"retry_backoff,omitempty" validate:"optional"`
+}
+
+type NotificationRecipientForm struct {
+	Name  string `json:"name,omitempty" validate:"optional"`
+	Value string `json:"value" minLength:"1"`
+	// 不填时默认启用
+	Enabled   *bool `json:"enabled,omitempty" validate:"optional"`
+	SkipCheck bool  `json:"skip_check,omitempty" validate:"optional"`
 }
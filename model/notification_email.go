@@ -0,0 +1,230 @@
+package model
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 邮件加密方式
+const (
+	EmailSecurityNone uint8 = iota // 明文，仅建议用于本机中继
+	EmailSecuritySTARTTLS
+	EmailSecurityTLS
+)
+
+const (
+	emailDialTimeout = 10 * time.Second
+	// 单次发送（含握手、认证与传输）的最长时间
+	emailSendTimeout = 30 * time.Second
+	// 未填写主题时，取消息第一行作为主题的最大长度
+	emailSubjectMaxRunes = 78
+)
+
+// emailPort 未填写端口时按加密方式使用常用端口
+func (n *Notification) emailPort() int {
+	if n.SMTPPort != 0 {
+		return int(n.SMTPPort)
+	}
+	switch n.SMTPSecurity {
+	case EmailSecuritySTARTTLS:
+		return 587
+	case EmailSecurityTLS:
+		return 465
+	}
+	return 25
+}
+
+// validateEmail 检查 SMTP 配置与收件人地址
+func (n *Notification) validateEmail() error {
+	if n.SMTPHost == "" {
+		return errors.New("smtp host is required")
+	}
+	if n.SMTPSecurity > EmailSecurityTLS {
+		return errors.New("unsupported smtp security")
+	}
+	if _, err := mail.ParseAddress(n.EmailFrom); err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	if _, err := mail.ParseAddressList(n.EmailTo); err != nil {
+		return fmt.Errorf("invalid to address: %w", err)
+	}
+	if n.EmailCc != "" {
+		if _, err := mail.ParseAddressList(n.EmailCc); err != nil {
+			return fmt.Errorf("invalid cc address: %w", err)
+		}
+	}
+	return nil
+}
+
+func (ns *NotificationServerBundle) sendEmail(message string) error {
+	n := ns.Notification
+	from, err := mail.ParseAddress(n.EmailFrom)
+	if err != nil {
+		return fmt.Errorf("smtp: invalid from address: %w", err)
+	}
+	to, err := mail.ParseAddressList(n.EmailTo)
+	if err != nil {
+		return fmt.Errorf("smtp: invalid to address: %w", err)
+	}
+	var cc []*mail.Address
+	if n.EmailCc != "" {
+		if cc, err = mail.ParseAddressList(n.EmailCc); err != nil {
+			return fmt.Errorf("smtp: invalid cc address: %w", err)
+		}
+	}
+
+	subject := emailSubject(message)
+	if n.EmailSubject != "" {
+		subject = strings.Join(strings.Fields(ns.replaceParamsInString(n.EmailSubject, message, nil)), " ")
+	}
+	data := buildEmail(from, to, cc, subject, message, time.Now().In(ns.Loc))
+
+	client, err := n.dialSMTP()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if n.SMTPUsername != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.SMTPUsername, n.SMTPPassword, n.SMTPHost)); err != nil {
+			return fmt.Errorf("smtp: authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp: sender %s rejected: %w", from.Address, err)
+	}
+	for _, rcpt := range append(to, cc...) {
+		if err := client.Rcpt(rcpt.Address); err != nil {
+			return fmt.Errorf("smtp: recipient %s rejected: %w", rcpt.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp: message rejected: %w", err)
+	}
+	// 服务器已接收邮件，断开连接失败不视为发送失败，以免重试时重复发送
+	_ = client.Quit()
+	return nil
+}
+
+// dialSMTP 连接 SMTP 服务器，按配置使用隐式 TLS 或 STARTTLS
+func (n *Notification) dialSMTP() (*smtp.Client, error) {
+	addr := net.JoinHostPort(n.SMTPHost, strconv.Itoa(n.emailPort()))
+	tlsConfig := &tls.Config{
+		ServerName:         n.SMTPHost,
+		InsecureSkipVerify: n.VerifyTLS == nil || !*n.VerifyTLS,
+	}
+
+	dialer := &net.Dialer{Timeout: emailDialTimeout}
+	var conn net.Conn
+	var err error
+	if n.SMTPSecurity == EmailSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smtp: connect %s: %w", addr, err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(emailSendTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, n.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp: %w", err)
+	}
+	if n.SMTPSecurity == EmailSecuritySTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("smtp: server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp: starttls: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// emailSubject 未配置主题模板时取消息的第一行作为主题，过长时截断
+func emailSubject(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > emailSubjectMaxRunes {
+		s = string(r[:emailSubjectMaxRunes-1]) + "…"
+	}
+	return s
+}
+
+// buildEmail 生成 UTF-8 纯文本邮件，主题按 RFC 2047 编码，正文使用 base64 传输编码
+func buildEmail(from *mail.Address, to, cc []*mail.Address, subject, body string, now time.Time) []byte {
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		buf.WriteString(k)
+		buf.WriteString(": ")
+		buf.WriteString(v)
+		buf.WriteString("\r\n")
+	}
+	joinAddresses := func(list []*mail.Address) string {
+		s := make([]string, len(list))
+		for i, a := range list {
+			s[i] = a.String()
+		}
+		return strings.Join(s, ", ")
+	}
+
+	header("From", from.String())
+	header("To", joinAddresses(to))
+	if len(cc) > 0 {
+		header("Cc", joinAddresses(cc))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", emailMessageID(from))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "base64")
+	buf.WriteString("\r\n")
+
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+func emailMessageID(from *mail.Address) string {
+	domain := "nezha"
+	if _, d, ok := strings.Cut(from.Address, "@"); ok {
+		domain = d
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain)
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+var (
+	// notificationLocalizePattern 匹配模板中的 #T:msgid# 本地化占位符
+	notificationLocalizePattern = regexp.MustCompile(`#T:([^#\r\n]+)#`)
+	// notificationParamPattern 匹配 #NEZHA#、#SERVER.NAME# 等普通占位符
+	notificationParamPattern = regexp.MustCompile(`#[A-Z0-9.]+#`)
+)
+
+func (n *Notification) BeforeSave(tx *gorm.DB) error {
+	if data, err := utils.Json.Marshal(n.Templates); err != nil {
+		return err
+	} else {
+		n.TemplatesRaw = string(data)
+	}
+	if data, err := utils.Json.Marshal(n.Severities); err != nil {
+		return err
+	} else {
+		n.SeveritiesRaw = string(data)
+	}
+	return nil
+}
+
+func (n *Notification) AfterFind(tx *gorm.DB) error {
+	if n.SeveritiesRaw != "" {
+		if err := utils.Json.Unmarshal([]byte(n.SeveritiesRaw), &n.Severities); err != nil {
+			return err
+		}
+	}
+	if n.TemplatesRaw == "" {
+		return nil
+	}
+	return utils.Json.Unmarshal([]byte(n.TemplatesRaw), &n.Templates)
+}
+
+// language 通知使用的语言，未指定时为面板语言
+func (ns *NotificationServerBundle) language() string {
+	if ns.Notification.Language != "" {
+		return ns.Notification.Language
+	}
+	return ns.DefaultLanguage
+}
+
+// template 选择通知语言的模板，缺少时依次回退到面板语言的模板与默认模板
+func (ns *NotificationServerBundle) template() string {
+	n := ns.Notification
+	for _, lang := range [...]string{ns.language(), ns.DefaultLanguage} {
+		if t := n.Templates[lang]; lang != "" && t != "" {
+			return t
+		}
+	}
+	return n.Template
+}
+
+// translate 将 msgid 翻译为通知语言，缺少翻译时回退到面板语言，仍缺少时使用 msgid 原文
+func (ns *NotificationServerBundle) translate(msgid string) string {
+	if ns.Translate == nil {
+		return msgid
+	}
+	lang := ns.language()
+	if t := ns.Translate(lang, msgid); t != msgid {
+		return t
+	}
+	if ns.DefaultLanguage != "" && ns.DefaultLanguage != lang {
+		return ns.Translate(ns.DefaultLanguage, msgid)
+	}
+	return msgid
+}
+
+// localize 将 #T:msgid# 替换为译文，译文按所在位置转义，其中的占位符保留原样以便随后替换
+func (ns *NotificationServerBundle) localize(str string, mod func(string) string) string {
+	return notificationLocalizePattern.ReplaceAllStringFunc(str, func(s string) string {
+		text := ns.translate(notificationLocalizePattern.FindStringSubmatch(s)[1])
+		var b strings.Builder
+		var last int
+		for _, loc := range notificationParamPattern.FindAllStringIndex(text, -1) {
+			b.WriteString(mod(text[last:loc[0]]))
+			b.WriteString(text[loc[0]:loc[1]])
+			last = loc[1]
+		}
+		b.WriteString(mod(text[last:]))
+		return b.String()
+	})
+}
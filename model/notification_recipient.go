@@ -0,0 +1,61 @@
+package model
+
+import (
+	"errors"
+	"net/mail"
+	"net/url"
+)
+
+// NotificationRecipient 通知方式的额外接收方，与主接收方共用通知方式的其余配置。
+// Value 按通知方式类型分别为 Webhook 地址、Telegram Chat ID、Slack 频道、收件人邮箱或短信号码（后两者可以逗号分隔多个）。
+type NotificationRecipient struct {
+	Common
+	NotificationID uint64 `json:"notification_id" gorm:"index"`
+	Name           string `json:"name,omitempty"`
+	Value          string `json:"value"`
+	Enabled        bool   `json:"enabled"`
+}
+
+// ValidateRecipient 检查接收方是否适用于该通知方式
+func (n *Notification) ValidateRecipient(value string) error {
+	if value == "" {
+		return errors.New("recipient is required")
+	}
+	switch n.Type {
+	case NotificationTypeWebhook, NotificationTypeSignedWebhook:
+		u, err := url.Parse(value)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.New("recipient must be an http or https url")
+		}
+	case NotificationTypeEmail:
+		if _, err := mail.ParseAddressList(value); err != nil {
+			return err
+		}
+	case NotificationTypeTwilio:
+		return validatePhoneNumbers(value)
+	}
+	return nil
+}
+
+// WithRecipient 返回将主接收方替换为 value 的通知方式副本
+func (n *Notification) WithRecipient(value string) *Notification {
+	nc := *n
+	nc.Recipients = nil
+	switch n.Type {
+	case NotificationTypeTelegram:
+		nc.ChatID = value
+	case NotificationTypeSlack:
+		nc.Channel = value
+	case NotificationTypeEmail:
+		// 额外的收件人单独发送，不再抄送
+		nc.EmailTo, nc.EmailCc = value, ""
+	case NotificationTypeTwilio:
+		nc.TwilioTo = value
+	default:
+		nc.URL = value
+	}
+	return &nc
+}
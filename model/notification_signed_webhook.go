@@ -0,0 +1,132 @@
+package model
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const (
+	SignedWebhookTimestampHeader = "X-Nezha-Timestamp"
+	SignedWebhookSignatureHeader = "X-Nezha-Signature"
+
+	// 服务端返回 5xx 时首次重试前的等待时长，之后每次翻倍
+	signedWebhookBackoff = time.Second
+)
+
+type signedWebhookEvent struct {
+	Event     string                     `json:"event"`        // alert / resolved / notification
+	ID        string                     `json:"id,omitempty"` // 重试同一条通知时不变，可用于去重
+	Message   string                     `json:"message"`
+	Timestamp int64                      `json:"timestamp"`
+	Alert     *signedWebhookEventSubject `json:"alert,omitempty"`
+	Server    *signedWebhookEventSubject `json:"server,omitempty"`
+}
+
+type signedWebhookEventSubject struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+}
+
+func (ns *NotificationServerBundle) signedWebhookEvent(message string) signedWebhookEvent {
+	ev := signedWebhookEvent{
+		Event:     "notification",
+		ID:        ns.DeliveryID,
+		Message:   message,
+		Timestamp: time.Now().Unix(),
+	}
+	if ns.Alert != nil {
+		ev.Event = "alert"
+		if ns.Resolved {
+			ev.Event = "resolved"
+		}
+		ev.Alert = &signedWebhookEventSubject{ID: ns.Alert.ID, Name: ns.Alert.Name}
+	}
+	if ns.Server != nil {
+		ev.Server = &signedWebhookEventSubject{ID: ns.Server.ID, Name: ns.Server.Name}
+	}
+	return ev
+}
+
+// SignWebhook 计算签名 Webhook 的签名。
+//
+// 签名内容为 X-Nezha-Timestamp 请求头的值（十进制 Unix 秒）、一个英文句点 "."
+// 与原始请求体字节依次拼接，即 HMAC-SHA256(secret, timestamp + "." + body)，
+// 结果以小写十六进制编码并加上 "sha256=" 前缀放入 X-Nezha-Signature 请求头。
+// 接收方应使用收到的原始请求体计算签名后做常数时间比较，并拒绝时间戳偏差过大的请求以防重放。
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (ns *NotificationServerBundle) sendSignedWebhook(message string) error {
+	n := ns.Notification
+	method, err := n.reqMethod()
+	if err != nil {
+		return err
+	}
+
+	body, err := utils.Json.Marshal(ns.signedWebhookEvent(message))
+	if err != nil {
+		return err
+	}
+
+	backoff := signedWebhookBackoff
+	return retryOnRateLimit(func() (time.Duration, error) {
+		req, err := http.NewRequest(method, n.URL, bytes.NewReader(body))
+		if err != nil {
+			return 0, err
+		}
+		if err := n.setRequestHeader(req); err != nil {
+			return 0, err
+		}
+		// 每次尝试使用新的时间戳重新签名
+		ts := time.Now().Unix()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(SignedWebhookTimestampHeader, strconv.FormatInt(ts, 10))
+		req.Header.Set(SignedWebhookSignatureHeader, SignWebhook(n.Secret, ts, body))
+
+		status, retryAfter, err := doSignedWebhookRequest(n.httpClient(), req)
+		ns.StatusCode = status
+		if retryAfter == 0 && status >= http.StatusInternalServerError {
+			retryAfter = backoff
+			backoff *= 2
+		}
+		return retryAfter, err
+	})
+}
+
+// doSignedWebhookRequest 发送一次请求，返回响应状态码与被限流时需要等待的时长
+func doSignedWebhookRequest(client *http.Client, req *http.Request) (int, time.Duration, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, 0, nil
+	}
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("%d@%s %s", resp.StatusCode, resp.Status, string(raw))
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return resp.StatusCode, time.Duration(max(retryAfter, 1)) * time.Second, err
+	}
+	return resp.StatusCode, 0, err
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const (
+	slackColorInfo     = "#439FE0"
+	slackColorDanger   = "danger"
+	slackColorResolved = "good"
+)
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackAttachment struct {
+	Color    string       `json:"color"`
+	Fallback string       `json:"fallback"`
+	Text     string       `json:"text"` // Mattermost 不支持 blocks
+	Blocks   []slackBlock `json:"blocks"`
+	Ts       int64        `json:"ts"`
+}
+
+type slackPayload struct {
+	Channel     string            `json:"channel,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// slackColor 报警触发为红色，恢复为绿色，其余通知为蓝色
+func (ns *NotificationServerBundle) slackColor() string {
+	switch {
+	case ns.Alert == nil:
+		return slackColorInfo
+	case ns.Resolved:
+		return slackColorResolved
+	}
+	return slackColorDanger
+}
+
+func (ns *NotificationServerBundle) sendSlack(message string) error {
+	n := ns.Notification
+	payload := slackPayload{
+		Channel: n.Channel,
+		Text:    message,
+		Attachments: []slackAttachment{{
+			Color:    ns.slackColor(),
+			Fallback: message,
+			Text:     message,
+			Blocks: []slackBlock{{
+				Type: "section",
+				Text: &slackText{Type: "mrkdwn", Text: message},
+			}},
+			Ts: time.Now().Unix(),
+		}},
+	}
+	if ns.Server != nil {
+		payload.Attachments[0].Blocks = append(payload.Attachments[0].Blocks, slackBlock{
+			Type:     "context",
+			Elements: []slackText{{Type: "mrkdwn", Text: ns.Server.Name}},
+		})
+	}
+
+	body, err := utils.Json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return retryOnRateLimit(func() (time.Duration, error) {
+		return doSlackRequest(n.httpClient(), n.URL, body)
+	})
+}
+
+// doSlackRequest 发送一次请求，被限流时返回需要等待的时长
+func doSlackRequest(client *http.Client, endpoint string, body []byte) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return 0, nil
+	}
+
+	// Slack 以纯文本返回错误码，如 invalid_payload、channel_not_found、channel_is_archived
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("%d@%s %s", resp.StatusCode, resp.Status, string(raw))
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return time.Duration(max(retryAfter, 1)) * time.Second, err
+	}
+	return 0, err
+}
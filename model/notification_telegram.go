@@ -0,0 +1,72 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const telegramAPIEndpoint = "https://api.telegram.org"
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code,omitempty"`
+	Description string `json:"description,omitempty"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after,omitempty"`
+	} `json:"parameters,omitempty"`
+}
+
+func (ns *NotificationServerBundle) sendTelegram(message string) error {
+	n := ns.Notification
+	body, err := utils.Json.Marshal(map[string]string{
+		"chat_id": n.ChatID,
+		"text":    message,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", telegramAPIEndpoint, n.BotToken)
+	return retryOnRateLimit(func() (time.Duration, error) {
+		return doTelegramRequest(n.httpClient(), endpoint, body)
+	})
+}
+
+// doTelegramRequest 发送一次请求，被限流时返回需要等待的时长
+func doTelegramRequest(client *http.Client, endpoint string, body []byte) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// 错误信息中包含请求地址，隐去其中的 Bot Token
+		return 0, errors.New(strings.ReplaceAll(err.Error(), endpoint, telegramAPIEndpoint))
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	raw, _ := io.ReadAll(resp.Body)
+	var tr telegramResponse
+	if err := utils.Json.Unmarshal(raw, &tr); err != nil {
+		return 0, fmt.Errorf("%d@%s %s", resp.StatusCode, resp.Status, string(raw))
+	}
+	if tr.OK {
+		return 0, nil
+	}
+
+	err = fmt.Errorf("telegram: %d %s", tr.ErrorCode, tr.Description)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return time.Duration(max(tr.Parameters.RetryAfter, 1)) * time.Second, err
+	}
+	return 0, err
+}
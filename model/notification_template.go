@@ -0,0 +1,272 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// 消息模板除 #NEZHA# 等占位符外还可以使用 Go text/template 语法，如
+//
+//	{{ if .Resolved }}已恢复{{ else }}{{ .Alert.Severity | upper }}{{ end }} {{ .Server.MemUsed | bytes }}
+//	{{ .Time | formatTime "2006-01-02 15:04" "Asia/Shanghai" }}
+//
+// 模板先按 text/template 执行，输出中的占位符随后照常替换。只能使用 notificationTemplateFuncs 中的函数，
+// 可访问的字段见 notificationTemplateData
+const notificationTemplateMaxOutput = 64 * 1024
+
+var errNotificationTemplateTooLong = errors.New("template output is too long")
+
+type notificationTemplateData struct {
+	Message  string
+	Time     time.Time
+	Resolved bool
+	Server   *notificationTemplateServer // 通知不属于某台服务器时为 nil
+	Alert    *notificationTemplateAlert  // 不是报警通知时为 nil
+}
+
+type notificationTemplateServer struct {
+	ID                            uint64
+	Name                          string
+	IP, IPv4, IPv6                string
+	CPU                           float64
+	MemUsed, MemTotal             uint64
+	SwapUsed, SwapTotal           uint64
+	DiskUsed, DiskTotal           uint64
+	NetInSpeed, NetOutSpeed       uint64
+	NetInTransfer, NetOutTransfer uint64
+	Load1, Load5, Load15          float64
+	TCPConnCount, UDPConnCount    uint64
+}
+
+type notificationTemplateAlert struct {
+	ID        uint64
+	Name      string
+	Severity  string
+	Metric    string
+	Threshold string
+	Rate      string
+	Incident  string        // 故障 ID，没有进行中的故障时为空
+	Duration  time.Duration // 故障已持续的时长
+	Peak      string
+}
+
+// notificationTemplateFuncs 模板可使用的函数，loc 为 formatTime 未指定时区时使用的时区
+func notificationTemplateFuncs(loc *time.Location) template.FuncMap {
+	return template.FuncMap{
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"trim":     strings.TrimSpace,
+		"contains": strings.Contains,
+		"replace":  strings.ReplaceAll,
+		"default":  templateDefault,
+		"bytes":    templateBytes,
+		"duration": func(d time.Duration) string { return d.Round(time.Second).String() },
+		"formatTime": func(layout, tz string, t time.Time) (string, error) {
+			l := loc
+			if l == nil {
+				l = time.Local
+			}
+			if tz != "" {
+				var err error
+				if l, err = time.LoadLocation(tz); err != nil {
+					return "", err
+				}
+			}
+			return t.In(l).Format(layout), nil
+		},
+	}
+}
+
+// templateDefault 值为空时返回 def，用于管道，如 {{ .Alert.Incident | default "-" }}
+func templateDefault(def, v any) any {
+	if v == nil || reflect.ValueOf(v).IsZero() {
+		return def
+	}
+	return v
+}
+
+// templateBytes 将字节数格式化为 1.5 GiB 的形式
+func templateBytes(v any) (string, error) {
+	rv := reflect.ValueOf(v)
+	var n float64
+	switch {
+	case rv.CanInt():
+		n = float64(rv.Int())
+	case rv.CanUint():
+		n = float64(rv.Uint())
+	case rv.CanFloat():
+		n = rv.Float()
+	default:
+		return "", fmt.Errorf("bytes: unsupported type %T", v)
+	}
+	units := [...]string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	var i int
+	for i < len(units)-1 && math.Abs(n) >= 1024 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i]), nil
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i]), nil
+}
+
+// limitedWriter 输出超过 limit 字节时返回错误，避免模板生成过长的消息
+type limitedWriter struct {
+	strings.Builder
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > w.limit {
+		return 0, errNotificationTemplateTooLong
+	}
+	return w.Builder.Write(p)
+}
+
+// executeNotificationTemplate 执行模板，模板中的函数 panic 时返回错误
+func executeNotificationTemplate(text string, loc *time.Location, data *notificationTemplateData, w io.Writer) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("template panic: %v", r)
+		}
+	}()
+	t, err := template.New("notification").Funcs(notificationTemplateFuncs(loc)).Parse(text)
+	if err != nil {
+		return err
+	}
+	for _, tt := range t.Templates() {
+		if tt.Tree == nil {
+			continue
+		}
+		if err := checkTemplateNode(tt.Tree.Root); err != nil {
+			return err
+		}
+	}
+	return t.Execute(w, data)
+}
+
+// checkTemplateNode 拒绝 range 与 template 动作。模板数据中没有可遍历的字段，range 只会被用来
+// 遍历整数放大执行时间，template 则可以递归调用自身，两者都不受输出长度的限制
+func checkTemplateNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			if err := checkTemplateNode(c); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkTemplateBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkTemplateBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		return errors.New("range is not allowed in templates")
+	case *parse.TemplateNode:
+		return errors.New("template is not allowed in templates")
+	}
+	return nil
+}
+
+func checkTemplateBranch(n *parse.BranchNode) error {
+	if err := checkTemplateNode(n.List); err != nil {
+		return err
+	}
+	return checkTemplateNode(n.ElseList)
+}
+
+// validateTemplate 检查模板语法，并以各字段均不为 nil 的示例数据执行一次，拒绝未知的函数与字段
+func validateTemplate(text string) error {
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+	data := &notificationTemplateData{
+		Time:   time.Now(),
+		Server: &notificationTemplateServer{},
+		Alert:  &notificationTemplateAlert{},
+	}
+	return executeNotificationTemplate(text, time.UTC, data, &limitedWriter{limit: notificationTemplateMaxOutput})
+}
+
+// validateTemplates 检查默认模板与各语言的模板
+func (n *Notification) validateTemplates() error {
+	if err := validateTemplate(n.Template); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	for lang, t := range n.Templates {
+		if err := validateTemplate(t); err != nil {
+			return fmt.Errorf("invalid template for %s: %w", lang, err)
+		}
+	}
+	return nil
+}
+
+// templateData 生成执行模板使用的数据
+func (ns *NotificationServerBundle) templateData(message string, now time.Time) *notificationTemplateData {
+	data := &notificationTemplateData{
+		Message:  message,
+		Time:     now,
+		Resolved: ns.Resolved,
+	}
+	if ns.Loc != nil {
+		data.Time = now.In(ns.Loc)
+	}
+	if s := ns.Server; s != nil {
+		ts := &notificationTemplateServer{ID: s.ID, Name: s.Name}
+		ts.IP, ts.IPv4, ts.IPv6 = serverIPs(s)
+		if st := s.State; st != nil {
+			ts.CPU = st.CPU
+			ts.MemUsed, ts.SwapUsed, ts.DiskUsed = st.MemUsed, st.SwapUsed, st.DiskUsed
+			ts.NetInSpeed, ts.NetOutSpeed = st.NetInSpeed, st.NetOutSpeed
+			ts.NetInTransfer, ts.NetOutTransfer = st.NetInTransfer, st.NetOutTransfer
+			ts.Load1, ts.Load5, ts.Load15 = st.Load1, st.Load5, st.Load15
+			ts.TCPConnCount, ts.UDPConnCount = st.TcpConnCount, st.UdpConnCount
+		}
+		if h := s.Host; h != nil {
+			ts.MemTotal, ts.SwapTotal, ts.DiskTotal = h.MemTotal, h.SwapTotal, h.DiskTotal
+		}
+		data.Server = ts
+	}
+	if a := ns.Alert; a != nil {
+		ta := &notificationTemplateAlert{ID: a.ID, Name: a.Name, Severity: a.GetSeverity(), Rate: a.Rate}
+		ta.Metric, ta.Threshold = a.Describe()
+		if inc := a.Incident; inc != nil {
+			ta.Incident, ta.Duration, ta.Peak = inc.ID, inc.Duration(now), inc.DescribePeak(a)
+		}
+		data.Alert = ta
+	}
+	return data
+}
+
+// executeTemplate 执行消息模板中的 text/template 语法，失败时返回错误
+func (ns *NotificationServerBundle) executeTemplate(text, message string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	w := &limitedWriter{limit: notificationTemplateMaxOutput}
+	if err := executeNotificationTemplate(text, ns.Loc, ns.templateData(message, time.Now()), w); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// renderTemplate 执行模板后替换占位符，模板执行失败时发送原始内容，以免通知丢失
+func (ns *NotificationServerBundle) renderTemplate(text, message string) string {
+	out, err := ns.executeTemplate(text, message)
+	if err != nil {
+		log.Printf("NEZHA>> 通知方式 %s 的消息模板执行失败，改为发送原始内容：%v", ns.Notification.Name, err)
+		return message
+	}
+	return ns.replaceParamsInString(out, message, nil)
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotificationTemplateFuncs(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	ns := NotificationServerBundle{
+		Notification: &Notification{Template: `{{ if .Resolved }}OK{{ else }}{{ .Alert.Severity | upper }}{{ end }} ` +
+			`{{ .Server.Name }} {{ .Server.MemUsed | bytes }}/{{ .Server.MemTotal | bytes }} ` +
+			`{{ .Alert.Incident | default "-" }} {{ .Time | formatTime "2006" "UTC" }} ` +
+			`{{ if contains .Message "down" }}#SERVER.NAME# is down{{ end }}`},
+		Server: &Server{Name: "s1", State: &HostState{MemUsed: 1536 * 1024 * 1024}, Host: &Host{MemTotal: 4 << 30}, GeoIP: &GeoIP{}},
+		Alert:  &AlertRule{Rules: []*Rule{{Type: "cpu", Max: 90}}, Severity: SeverityCritical},
+		Loc:    loc,
+	}
+	want := "CRITICAL s1 1.5 GiB/4.0 GiB - " + time.Now().UTC().Format("2006") + " s1 is down"
+	if got := ns.render("server down"); got != want {
+		t.Fatalf("unexpected render result: %q, want %q", got, want)
+	}
+
+	ns.Resolved = true
+	ns.Notification.Template = "{{ if .Resolved }}{{ .Message | trim | lower }}{{ end }}"
+	if got := ns.render(" Recovered "); got != "recovered" {
+		t.Fatalf("unexpected render result: %q", got)
+	}
+
+	for v, want := range map[any]string{0: "0 B", uint64(1023): "1023 B", 1024: "1.0 KiB", 5.5 * (1 << 40): "5.5 TiB"} {
+		if got, _ := templateBytes(v); got != want {
+			t.Errorf("bytes(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestNotificationTemplateFallback(t *testing.T) {
+	// 没有服务器时访问服务器字段会执行失败，改为发送原始内容
+	ns := NotificationServerBundle{
+		Notification: &Notification{Template: "{{ .Server.Name }}: #NEZHA#"},
+		Loc:          time.UTC,
+	}
+	if got := ns.render(msg); got != msg {
+		t.Fatalf("expected raw message on template error, got %q", got)
+	}
+
+	long := strings.Repeat("0123456789", notificationTemplateMaxOutput/10+1)
+	ns.Notification.Template = `{{ .Message }}{{ .Message }}`
+	if got := ns.render(long); got != long {
+		t.Fatal("expected raw message when the output is too long")
+	}
+
+	ns.Notification.Template = `{{ range 100000 }}0123456789{{ end }}`
+	if got := ns.render(msg); got != msg {
+		t.Fatal("expected raw message for a template with range")
+	}
+
+	ns.Notification.Template = `{{ .Time | formatTime "15:04" "Nowhere/City" }}`
+	if got := ns.render(msg); got != msg {
+		t.Fatalf("expected raw message for an unknown time zone, got %q", got)
+	}
+}
+
+func TestNotificationTemplateValidate(t *testing.T) {
+	n := &Notification{Type: NotificationTypeTelegram, BotToken: "t", ChatID: "c"}
+	for tmpl, valid := range map[string]bool{
+		"#NEZHA#":                               true,
+		"{{ .Server.Name | upper }} #NEZHA#":    true,
+		"{{ if .Alert }}{{ .Alert.Name }}{{ }}": false,
+		"{{ .Message | shell }}":                false,
+		"{{ .Server.Password }}":                false,
+		"{{ if .Resolved }}ok":                  false,
+		"{{ range 1000000000 }}{{ end }}":       false,
+		"{{ if .Resolved }}{{ else }}{{ range 10 }}{{ range 10 }}{{ end }}{{ end }}{{ end }}":   false,
+		`{{ $n := 1000000000 }}{{ range $n }}x{{ end }}`:                                        false,
+		`{{ define "a" }}{{ template "a" . }}{{ template "a" . }}{{ end }}{{ template "a" . }}`: false,
+	} {
+		n.Template = tmpl
+		if err := n.Validate(); (err == nil) != valid {
+			t.Errorf("template %q: unexpected validation result %v", tmpl, err)
+		}
+	}
+
+	n.Template = ""
+	n.Templates = map[string]string{"en_US": "{{ .Message | shell }}"}
+	if err := n.Validate(); err == nil || !strings.Contains(err.Error(), `function "shell" not defined`) {
+		t.Fatalf("expected unknown function error, got %v", err)
+	}
+}
@@ -1,7 +1,16 @@
 package model
 
 import (
+	"encoding/base64"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -210,3 +219,349 @@ func TestNotification(t *testing.T) {
 		execCase(t, c)
 	}
 }
+
+func TestTelegramRetryAfter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 3","parameters":{"retry_after":3}}`))
+	}))
+	defer ts.Close()
+
+	retryAfter, err := doTelegramRequest(ts.Client(), ts.URL, []byte("{}"))
+	if err == nil {
+		t.Fatal("expected error on 429")
+	}
+	if retryAfter != 3*time.Second {
+		t.Fatalf("expected retry after 3s, got %v", retryAfter)
+	}
+}
+
+func TestNotificationTemplate(t *testing.T) {
+	ns := NotificationServerBundle{
+		Notification: &Notification{Template: "#SERVER.NAME# #ALERT.METRIC# #ALERT.THRESHOLD#: #NEZHA#"},
+		Server:       &Server{Name: "ServerName", State: &HostState{}, Host: &Host{}, GeoIP: &GeoIP{}},
+		Alert:        &AlertRule{Rules: []*Rule{{Type: "cpu", Max: 90}}},
+		Loc:          time.UTC,
+	}
+	if got := ns.render(msg); got != "ServerName cpu cpu max 90: msg" {
+		t.Fatalf("unexpected render result: %s", got)
+	}
+}
+
+func TestNotificationSeverity(t *testing.T) {
+	ns := NotificationServerBundle{
+		Notification: &Notification{Template: "[#ALERT.SEVERITY#] #NEZHA#"},
+		Alert:        &AlertRule{},
+		Loc:          time.UTC,
+	}
+	if got := ns.render(msg); got != "[warning] msg" {
+		t.Fatalf("unexpected render result: %s", got)
+	}
+
+	n := &Notification{}
+	if !n.AcceptsSeverity(SeverityInfo) {
+		t.Fatal("notification without severities should accept all")
+	}
+	n.Severities = []string{SeverityCritical}
+	if n.AcceptsSeverity(SeverityWarning) || !n.AcceptsSeverity(SeverityCritical) {
+		t.Fatal("notification should only accept critical alerts")
+	}
+}
+
+func TestSlackRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("rate_limited"))
+	}))
+	defer ts.Close()
+
+	retryAfter, err := doSlackRequest(ts.Client(), ts.URL, []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "rate_limited") {
+		t.Fatalf("expected rate_limited error, got %v", err)
+	}
+	if retryAfter != 2*time.Second {
+		t.Fatalf("expected retry after 2s, got %v", retryAfter)
+	}
+
+	ns := NotificationServerBundle{Alert: &AlertRule{}, Resolved: true}
+	if ns.slackColor() != slackColorResolved {
+		t.Fatalf("unexpected color for resolved alert: %s", ns.slackColor())
+	}
+}
+
+func TestSignedWebhook(t *testing.T) {
+	if got := SignWebhook("secret", 1700000000, []byte(`{"event":"alert"}`)); got != "sha256=5d2cc75f723da376c50918e975bbface0485fe6968653e8ea7c75e72a0f3f302" {
+		t.Fatalf("unexpected signature: %s", got)
+	}
+
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(SignedWebhookTimestampHeader), 10, 64)
+		if r.Method != http.MethodPut || r.Header.Get(SignedWebhookSignatureHeader) != SignWebhook("secret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.Contains(string(body), `"id":"d1"`) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	ns := NotificationServerBundle{
+		Notification: &Notification{Type: NotificationTypeSignedWebhook, URL: ts.URL, Secret: "secret", RequestMethod: NotificationRequestMethodPUT},
+		Alert:        &AlertRule{Name: "cpu"},
+		Loc:          time.UTC,
+		DeliveryID:   "d1",
+	}
+	if err := ns.Send(msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 || ns.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected retry after 5xx, got %d calls and status %d", calls, ns.StatusCode)
+	}
+}
+
+func TestNotificationRetryPolicy(t *testing.T) {
+	n := &Notification{}
+	if n.MaxAttempts() != 1 || n.RetryDelay(1) != notificationDefaultBackoff {
+		t.Fatalf("unexpected default policy: %d attempts, %v", n.MaxAttempts(), n.RetryDelay(1))
+	}
+
+	n.RetryMaxAttempts, n.RetryBackoff = 5, 30
+	for attempt, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 20: notificationMaxBackoff} {
+		if got := n.RetryDelay(attempt); got != want {
+			t.Errorf("attempt %d: got %v, want %v", attempt, got, want)
+		}
+	}
+
+	n.Type, n.URL = NotificationTypeWebhook, "https://example.com"
+	n.RetryMaxAttempts = NotificationMaxAttemptsLimit + 1
+	if err := n.Validate(); err == nil {
+		t.Fatal("expected error for too many retry attempts")
+	}
+}
+
+func TestNotificationRecipient(t *testing.T) {
+	n := &Notification{Type: NotificationTypeTelegram, BotToken: "token", ChatID: "1",
+		Recipients: []NotificationRecipient{{Value: "2", Enabled: true}}}
+	r := n.WithRecipient("2")
+	if r.ChatID != "2" || r.BotToken != "token" || r.Recipients != nil || n.ChatID != "1" {
+		t.Fatalf("unexpected recipient copy: %+v", r)
+	}
+
+	n = &Notification{Type: NotificationTypeWebhook, URL: "https://a.example"}
+	if err := n.ValidateRecipient("ftp://b.example"); err == nil {
+		t.Fatal("expected non-http recipient to be rejected")
+	}
+	if err := n.ValidateRecipient("https://b.example/hook"); err != nil {
+		t.Fatal(err)
+	}
+	if r := n.WithRecipient("https://b.example/hook"); r.URL != "https://b.example/hook" {
+		t.Fatalf("unexpected url %s", r.URL)
+	}
+}
+
+// fakeSMTPServer 仅实现发送邮件所需命令的 SMTP 服务器，返回收到的 DATA 内容
+func fakeSMTPServer(t *testing.T, password string) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tc := textproto.NewConn(conn)
+		tc.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tc.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(cmd) {
+			case "EHLO":
+				tc.PrintfLine("250-localhost")
+				tc.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				creds, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+				if string(creds) != "\x00user\x00"+password {
+					tc.PrintfLine("535 authentication credentials invalid")
+					continue
+				}
+				tc.PrintfLine("235 ok")
+			case "DATA":
+				tc.PrintfLine("354 go ahead")
+				b, _ := tc.ReadDotBytes()
+				data <- string(b)
+				tc.PrintfLine("250 queued")
+			case "QUIT":
+				tc.PrintfLine("221 bye")
+				return
+			default:
+				tc.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), data
+}
+
+func TestEmail(t *testing.T) {
+	addr, data := fakeSMTPServer(t, "secret")
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+
+	n := &Notification{
+		Type:         NotificationTypeEmail,
+		SMTPHost:     host,
+		SMTPPort:     uint16(p),
+		SMTPUsername: "user",
+		SMTPPassword: "secret",
+		EmailFrom:    "Nezha <nezha@example.com>",
+		EmailTo:      "a@example.com, b@example.com",
+		EmailCc:      "c@example.com",
+	}
+	if err := n.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	ns := &NotificationServerBundle{Notification: n, Loc: time.UTC}
+	if err := ns.Send("服务器离线\n详细信息"); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "服务器离线" {
+		t.Fatalf("unexpected subject %q: %v", subject, err)
+	}
+	if cc := msg.Header.Get("Cc"); cc != "<c@example.com>" {
+		t.Fatalf("unexpected cc %q", cc)
+	}
+	body, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, msg.Body))
+	if string(body) != "服务器离线\r\n详细信息" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	addr, _ = fakeSMTPServer(t, "other")
+	_, port, _ = net.SplitHostPort(addr)
+	p, _ = strconv.Atoi(port)
+	n.SMTPPort = uint16(p)
+	if err := ns.Send("msg"); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Fatalf("expected authentication failure, got %v", err)
+	}
+}
+
+func TestNotificationLocalizedTemplate(t *testing.T) {
+	translations := map[string]map[string]string{
+		"zh_CN": {"Incident": "故障"},
+		"en_US": {"Server down": "Server #SERVER.NAME# is down!"},
+	}
+	translate := func(lang, msgid string) string {
+		if t, ok := translations[lang][msgid]; ok {
+			return t
+		}
+		return msgid
+	}
+	ns := NotificationServerBundle{
+		Notification: &Notification{
+			Template:  "#NEZHA#",
+			Language:  "zh_CN",
+			Templates: map[string]string{"zh_CN": "[#T:Incident#] #T:Server down#"},
+		},
+		Server:          &Server{Name: "ServerName", State: &HostState{}, Host: &Host{}, GeoIP: &GeoIP{}},
+		Loc:             time.UTC,
+		DefaultLanguage: "en_US",
+		Translate:       translate,
+	}
+	// 缺少中文翻译时回退到面板语言，译文中的占位符同样被替换
+	if got := ns.render(msg); got != "[故障] Server ServerName is down!" {
+		t.Fatalf("unexpected render result: %s", got)
+	}
+
+	ns.Notification.Language = "de_DE"
+	if got := ns.render(msg); got != msg {
+		t.Fatalf("expected fallback to default template, got %s", got)
+	}
+
+	ns.Notification.RequestBody = `{"text":"#T:Server down#"}`
+	ns.Notification.RequestMethod = NotificationRequestMethodPOST
+	ns.Notification.RequestType = NotificationRequestTypeJSON
+	ns.Server.Name = `"quoted"`
+	if got, _ := ns.reqBody(msg); got != `{"text":"Server \"quoted\" is down!"}` {
+		t.Fatalf("unexpected request body: %s", got)
+	}
+}
+
+func TestTwilio(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sid, token, _ := r.BasicAuth(); sid != "AC1" || token != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":20003,"message":"Authenticate","status":401}`))
+			return
+		}
+		r.ParseForm()
+		if r.PostForm.Get("To") == "+15005550001" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":21211,"message":"Invalid 'To' Phone Number","status":400}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid":"SM1","status":"queued"}`))
+	}))
+	defer ts.Close()
+
+	form := url.Values{"From": {"+15005550006"}, "To": {"+15005550009"}, "Body": {"msg"}}
+	status, _, err := doTwilioRequest(ts.Client(), ts.URL, "AC1", "token", form)
+	if err != nil || status != "queued (SM1)" {
+		t.Fatalf("unexpected result: %s, %v", status, err)
+	}
+	if _, _, err := doTwilioRequest(ts.Client(), ts.URL, "AC1", "wrong", form); err == nil || !strings.Contains(err.Error(), "20003") {
+		t.Fatalf("expected authentication error, got %v", err)
+	}
+	form.Set("To", "+15005550001")
+	if _, _, err := doTwilioRequest(ts.Client(), ts.URL, "AC1", "token", form); err == nil || !strings.Contains(err.Error(), "21211") {
+		t.Fatalf("expected invalid number error, got %v", err)
+	}
+
+	n := &Notification{Type: NotificationTypeTwilio, TwilioAccountSID: "AC1", TwilioAuthToken: "token", TwilioFrom: "+15005550006", TwilioTo: "+15005550009, 12345"}
+	if err := n.Validate(); err == nil {
+		t.Fatal("expected invalid phone number error")
+	}
+	n.RedactSecrets()
+	n.RestoreSecrets(&Notification{TwilioAuthToken: "token"})
+	if n.TwilioAuthToken != "token" {
+		t.Fatal("redacted auth token should be restored")
+	}
+}
+
+func TestTruncateSMS(t *testing.T) {
+	if s := truncateSMS("short"); s != "short" {
+		t.Fatalf("unexpected result: %s", s)
+	}
+	long := strings.Repeat("word ", 200)
+	s := truncateSMS(long)
+	if len(s) > smsGSMSegment*smsMaxSegments || !strings.HasSuffix(s, "word...") {
+		t.Fatalf("unexpected result: %q", s)
+	}
+	s = truncateSMS(strings.Repeat("服务器离线", 100))
+	if n := len([]rune(s)); n != smsUnicodeSegment*smsMaxSegments {
+		t.Fatalf("unexpected length %d", n)
+	}
+}
@@ -0,0 +1,165 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const twilioAPIEndpoint = "https://api.twilio.com"
+
+// 短信最多拆分的条数，超出的内容被截断。
+// 长短信每条最多 153 个 GSM 字符，含中文等字符时每条最多 67 个字符
+const (
+	smsMaxSegments    = 3
+	smsGSMSegment     = 153
+	smsUnicodeSegment = 67
+)
+
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+type twilioResponse struct {
+	SID      string `json:"sid,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Code     int    `json:"code,omitempty"`
+	Message  string `json:"message,omitempty"`
+	MoreInfo string `json:"more_info,omitempty"`
+}
+
+// twilioNumbers 解析逗号分隔的收件号码
+func twilioNumbers(s string) []string {
+	var numbers []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			numbers = append(numbers, v)
+		}
+	}
+	return numbers
+}
+
+func validatePhoneNumbers(s string) error {
+	numbers := twilioNumbers(s)
+	if len(numbers) == 0 {
+		return errors.New("at least one phone number is required")
+	}
+	for _, v := range numbers {
+		if !phoneNumberPattern.MatchString(v) {
+			return fmt.Errorf("invalid phone number %s, use E.164 format like +14155550100", v)
+		}
+	}
+	return nil
+}
+
+func (n *Notification) validateTwilio() error {
+	if n.TwilioAccountSID == "" || n.TwilioAuthToken == "" {
+		return errors.New("twilio account sid and auth token are required")
+	}
+	if !phoneNumberPattern.MatchString(n.TwilioFrom) {
+		return errors.New("twilio from number must be in E.164 format")
+	}
+	return validatePhoneNumbers(n.TwilioTo)
+}
+
+// truncateSMS 将短信截断到 smsMaxSegments 条以内，尽量在换行或空格处截断
+func truncateSMS(s string) string {
+	gsm := true
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			gsm = false
+			break
+		}
+	}
+	limit := utils.IfOr(gsm, smsGSMSegment, smsUnicodeSegment) * smsMaxSegments
+
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	const ellipsis = "..."
+	cut := limit - len(ellipsis)
+	// 在最后 1/5 的范围内寻找空白字符，避免截断单词
+	for i := cut; i > cut*4/5; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + ellipsis
+}
+
+// sendTwilio 向每个收件号码分别发送短信，DeliveryStatus 中记录各号码的短信状态。
+// 已发送成功的号码记入 Delivered，重试时不再发送
+func (ns *NotificationServerBundle) sendTwilio(message string) error {
+	n := ns.Notification
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", twilioAPIEndpoint, url.PathEscape(n.TwilioAccountSID))
+	body := truncateSMS(message)
+
+	var statuses []string
+	var errs []error
+	for _, to := range twilioNumbers(n.TwilioTo) {
+		if ns.Delivered[to] {
+			statuses = append(statuses, fmt.Sprintf("%s: sent", to))
+			continue
+		}
+		form := url.Values{"From": {n.TwilioFrom}, "To": {to}, "Body": {body}}
+		err := retryOnRateLimit(func() (time.Duration, error) {
+			status, retryAfter, err := doTwilioRequest(n.httpClient(), endpoint, n.TwilioAccountSID, n.TwilioAuthToken, form)
+			if err == nil {
+				statuses = append(statuses, fmt.Sprintf("%s: %s", to, status))
+				if ns.Delivered == nil {
+					ns.Delivered = make(map[string]bool)
+				}
+				ns.Delivered[to] = true
+			}
+			return retryAfter, err
+		})
+		if err != nil {
+			statuses = append(statuses, fmt.Sprintf("%s: failed", to))
+			errs = append(errs, fmt.Errorf("%s: %w", to, err))
+		}
+	}
+	ns.DeliveryStatus = strings.Join(statuses, ", ")
+	return errors.Join(errs...)
+}
+
+// doTwilioRequest 发送一条短信，返回 Twilio 的短信状态，被限流时返回需要等待的时长
+func doTwilioRequest(client *http.Client, endpoint, sid, token string, form url.Values) (string, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.SetBasicAuth(sid, token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	raw, _ := io.ReadAll(resp.Body)
+	var tr twilioResponse
+	if err := utils.Json.Unmarshal(raw, &tr); err != nil {
+		return "", 0, fmt.Errorf("%d@%s %s", resp.StatusCode, resp.Status, string(raw))
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return fmt.Sprintf("%s (%s)", tr.Status, tr.SID), 0, nil
+	}
+
+	// 错误码含义见 https://www.twilio.com/docs/api/errors
+	err = fmt.Errorf("twilio: %d %s", tr.Code, tr.Message)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return "", time.Second, err
+	}
+	return "", 0, err
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// RefreshToken 刷新令牌，每次刷新都会签发同一链（FamilyID）中的新令牌并使旧令牌失效
+type RefreshToken struct {
+	ID        uint64    `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+	UserID    uint64    `gorm:"index"`
+	FamilyID  string    `gorm:"index;type:char(32)"`
+	TokenHash string    `gorm:"uniqueIndex;type:char(64)"`
+	// 签发时的用户令牌版本，撤销用户会话后刷新令牌一并失效
+	TokenVersion uint64
+	ExpireAt     time.Time
+	UsedAt       *time.Time // 已被轮换
+	RevokedAt    *time.Time
+}
+
+// HashRefreshToken 数据库中只保存令牌的哈希
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
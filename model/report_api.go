@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type UptimeReportServer struct {
+	ServerID uint64 `json:"server_id"`
+	Name     string `json:"name"`
+	// 统计开始时间，期间新增的服务器从添加时开始统计
+	From            time.Time `json:"from"`
+	Uptime          float64   `json:"uptime"` // 在线率（百分比），没有状态记录时为 -1
+	OnlineSeconds   int64     `json:"online_seconds"`
+	DowntimeSeconds int64     `json:"downtime_seconds"`
+	Outages         int       `json:"outages"` // 离线次数，排除维护时不含维护期间开始的离线
+}
+
+type UptimeReport struct {
+	From               time.Time            `json:"from"`
+	To                 time.Time            `json:"to"`
+	ExcludeMaintenance bool                 `json:"exclude_maintenance"`
+	Servers            []UptimeReportServer `json:"servers"`
+	// 汇总在线率，按各服务器的统计时长加权
+	Uptime          float64 `json:"uptime"`
+	OnlineSeconds   int64   `json:"online_seconds"`
+	DowntimeSeconds int64   `json:"downtime_seconds"`
+	Outages         int     `json:"outages"`
+}
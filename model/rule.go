@@ -1,11 +1,14 @@
 package model
 
 import (
+	"fmt"
+	"math"
 	"slices"
 	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/nezhahq/nezha/pkg/utils"
 )
@@ -15,15 +18,43 @@ const (
 	RuleCoverIgnoreAll
 )
 
+var ruleTypes = map[string]bool{
+	"cpu": true, "gpu_max": true, "memory": true, "swap": true, "disk": true,
+	"net_in_speed": true, "net_out_speed": true, "net_all_speed": true,
+	"transfer_in": true, "transfer_out": true, "transfer_all": true, "offline": true,
+	"transfer_in_cycle": true, "transfer_out_cycle": true, "transfer_all_cycle": true,
+	"load1": true, "load5": true, "load15": true,
+	"tcp_conn_count": true, "udp_conn_count": true, "process_count": true, "temperature_max": true,
+	"custom": true,
+}
+
+const (
+	RuleConditionRate  = "rate"  // Window 分钟内变化的百分比
+	RuleConditionDelta = "delta" // Window 分钟内变化的绝对值
+)
+
+// MaxRuleWindow 变化条件比较窗口的上限 (分钟)，历史采样只保存在内存中
+const MaxRuleWindow = 360
+
+// 每个比较窗口内最多保留的采样数
+const ruleWindowSamples = 120
+
 type NResult struct {
 	N uint64
 }
 
+type ruleSample struct {
+	at    time.Time
+	value float64
+}
+
 type Rule struct {
-	// 指标类型，cpu、memory、swap、disk、net_in_speed、net_out_speed
+	// 指标类型，cpu、gpu_max、memory、swap、disk、net_in_speed、net_out_speed
 	// net_all_speed、transfer_in、transfer_out、transfer_all、offline
 	// transfer_in_cycle、transfer_out_cycle、transfer_all_cycle
+	// load1、load5、load15、tcp_conn_count、udp_conn_count、process_count、temperature_max、custom
 	Type          string          `json:"type"`
+	Target        string          `json:"target,omitempty" validate:"optional"`                                                     // 指标对象，disk 为挂载点（如 /data），net_*_speed 为网卡名，为空时使用汇总数据；custom 为自定义指标名称
 	Min           float64         `json:"min,omitempty" validate:"optional"`                                                        // 最小阈值 (百分比、字节 kb ÷ 1024)
 	Max           float64         `json:"max,omitempty" validate:"optional"`                                                        // 最大阈值 (百分比、字节 kb ÷ 1024)
 	CycleStart    *time.Time      `json:"cycle_start,omitempty" validate:"optional"`                                                // 流量统计的开始时间
@@ -32,10 +63,17 @@ type Rule struct {
 	Duration      uint64          `json:"duration,omitempty" validate:"optional"`                                                   // 持续时间 (秒)
 	Cover         uint64          `json:"cover"`                                                                                    // 覆盖范围 RuleCoverAll/IgnoreAll
 	Ignore        map[uint64]bool `json:"ignore,omitempty" validate:"optional"`                                                     // 覆盖范围的排除
+	// 为空时与阈值比较；rate、delta 时与 Window 分钟前的值比较，增加超过 Max 或减少超过 Min 时报警
+	Condition string `json:"condition,omitempty" enums:"rate,delta" validate:"optional"`
+	Window    uint64 `json:"window,omitempty" validate:"optional"` // 变化条件的比较窗口 (分钟)
 
 	// 只作为缓存使用，记录下次该检测的时间
 	NextTransferAt  map[uint64]time.Time `json:"-"`
 	LastCycleStatus map[uint64]bool      `json:"-"`
+
+	samples    map[uint64][]ruleSample // 变化条件的历史采样
+	lastChange map[uint64]float64      // 变化条件最近一次计算出的变化
+	lastValue  map[uint64]float64      // 最近一次与阈值比较的值，变化条件为计算出的变化
 }
 
 func percentage(used, total uint64) float64 {
@@ -73,13 +111,25 @@ func (u *Rule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server,
 	case "swap":
 		src = percentage(server.State.SwapUsed, server.Host.SwapTotal)
 	case "disk":
-		src = percentage(server.State.DiskUsed, server.Host.DiskTotal)
-	case "net_in_speed":
-		src = float64(server.State.NetInSpeed)
-	case "net_out_speed":
-		src = float64(server.State.NetOutSpeed)
-	case "net_all_speed":
-		src = float64(server.State.NetOutSpeed + server.State.NetOutSpeed)
+		if u.Target == "" {
+			src = percentage(server.State.DiskUsed, server.Host.DiskTotal)
+		} else if d, ok := server.State.Disk(u.Target); ok {
+			src = percentage(d.Used, d.Total)
+		}
+	case "net_in_speed", "net_out_speed", "net_all_speed":
+		in, out := server.State.NetInSpeed, server.State.NetOutSpeed
+		if u.Target != "" {
+			n, _ := server.State.NetInterface(u.Target)
+			in, out = n.InSpeed, n.OutSpeed
+		}
+		switch u.Type {
+		case "net_in_speed":
+			src = float64(in)
+		case "net_out_speed":
+			src = float64(out)
+		default:
+			src = float64(in + out)
+		}
 	case "transfer_in":
 		src = float64(server.State.NetInTransfer)
 	case "transfer_out":
@@ -96,21 +146,21 @@ func (u *Rule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server,
 		src = float64(utils.Uint64SubInt64(server.State.NetInTransfer, server.PrevTransferInSnapshot))
 		if u.CycleInterval != 0 {
 			var res NResult
-			db.Model(&Transfer{}).Select("SUM(`in`) AS n").Where("datetime(`created_at`) >= datetime(?) AND server_id = ?", u.GetTransferDurationStart().UTC(), server.ID).Scan(&res)
+			db.Model(&Transfer{}).Select("SUM(?) AS n", clause.Column{Name: "in"}).Where(TimeCondition(db, "created_at", ">=")+" AND server_id = ?", u.GetTransferDurationStart().UTC(), server.ID).Scan(&res)
 			src += float64(res.N)
 		}
 	case "transfer_out_cycle":
 		src = float64(utils.Uint64SubInt64(server.State.NetOutTransfer, server.PrevTransferOutSnapshot))
 		if u.CycleInterval != 0 {
 			var res NResult
-			db.Model(&Transfer{}).Select("SUM(`out`) AS n").Where("datetime(`created_at`) >= datetime(?) AND server_id = ?", u.GetTransferDurationStart().UTC(), server.ID).Scan(&res)
+			db.Model(&Transfer{}).Select("SUM(?) AS n", clause.Column{Name: "out"}).Where(TimeCondition(db, "created_at", ">=")+" AND server_id = ?", u.GetTransferDurationStart().UTC(), server.ID).Scan(&res)
 			src += float64(res.N)
 		}
 	case "transfer_all_cycle":
 		src = float64(utils.Uint64SubInt64(server.State.NetOutTransfer, server.PrevTransferOutSnapshot) + utils.Uint64SubInt64(server.State.NetInTransfer, server.PrevTransferInSnapshot))
 		if u.CycleInterval != 0 {
 			var res NResult
-			db.Model(&Transfer{}).Select("SUM(`in`+`out`) AS n").Where("datetime(`created_at`) >= datetime(?) AND server_id = ?", u.GetTransferDurationStart().UTC(), server.ID).Scan(&res)
+			db.Model(&Transfer{}).Select("SUM(? + ?) AS n", clause.Column{Name: "in"}, clause.Column{Name: "out"}).Where(TimeCondition(db, "created_at", ">=")+" AND server_id = ?", u.GetTransferDurationStart().UTC(), server.ID).Scan(&res)
 			src += float64(res.N)
 		}
 	case "load1":
@@ -135,6 +185,22 @@ func (u *Rule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server,
 			}
 			src = slices.Max(temp)
 		}
+	case "custom":
+		// 服务器未上报该指标时不报警
+		m, ok := server.State.CustomMetrics[u.Target]
+		if !ok {
+			return true
+		}
+		src = m.Value
+	}
+
+	if u.IsChangeRule() {
+		// 采样不足时不报警
+		change, ok := u.observeChange(server.ID, server.LastActive, src)
+		if ok {
+			u.observeValue(server.ID, change)
+		}
+		return !ok || !((u.Max > 0 && change > u.Max) || (u.Min > 0 && change < -u.Min))
 	}
 
 	// 循环区间流量检测 · 更新下次需要检测时间
@@ -165,6 +231,11 @@ func (u *Rule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server,
 		cycleTransferStats.To = u.GetTransferDurationEnd()
 	}
 
+	// 离线规则比较的是最后上报时间，不作为观测值
+	if u.Type != "offline" {
+		u.observeValue(server.ID, src)
+	}
+
 	if u.Type == "offline" && float64(time.Now().Unix())-src > 6 {
 		return false
 	} else if (u.Max > 0 && src > u.Max) || (u.Min > 0 && src < u.Min) {
@@ -174,6 +245,105 @@ func (u *Rule) Snapshot(cycleTransferStats *CycleTransferStats, server *Server,
 	return true
 }
 
+// KnownType 判断指标类型是否受支持
+func (u *Rule) KnownType() bool {
+	return ruleTypes[u.Type]
+}
+
+// SupportsTarget 判断该规则类型是否支持指定挂载点或网卡
+func (u *Rule) SupportsTarget() bool {
+	switch u.Type {
+	case "disk", "net_in_speed", "net_out_speed", "net_all_speed", "custom":
+		return true
+	}
+	return false
+}
+
+// IsChangeRule 判断该规则是否比较指标在窗口内的变化
+func (u *Rule) IsChangeRule() bool {
+	return u.Condition == RuleConditionRate || u.Condition == RuleConditionDelta
+}
+
+// SupportsChange 判断该规则类型是否可以比较变化
+func (u *Rule) SupportsChange() bool {
+	return u.Type != "offline" && !u.IsTransferDurationRule()
+}
+
+// observeChange 以服务器最近一次上报的时间记录采样，返回与窗口起点相比的变化。
+// 采样未覆盖整个窗口、窗口起点附近缺少数据或基准值为 0 无法计算百分比时 ok 为 false
+func (u *Rule) observeChange(serverID uint64, at time.Time, value float64) (change float64, ok bool) {
+	if u.lastChange == nil {
+		u.lastChange = make(map[uint64]float64)
+	}
+	delete(u.lastChange, serverID)
+	if at.IsZero() {
+		return 0, false
+	}
+
+	if u.samples == nil {
+		u.samples = make(map[uint64][]ruleSample)
+	}
+	window := time.Duration(u.Window) * time.Minute
+	samples := u.samples[serverID]
+	// 服务器未上报新数据时不重复记录，采样间隔不小于窗口的 1/ruleWindowSamples
+	if n := len(samples); n == 0 || at.Sub(samples[n-1].at) >= window/ruleWindowSamples && at.After(samples[n-1].at) {
+		samples = append(samples, ruleSample{at: at, value: value})
+	}
+	// 只保留窗口起点前最近的一个采样作为基准
+	start := at.Add(-window)
+	i := 0
+	for i+1 < len(samples) && !samples[i+1].at.After(start) {
+		i++
+	}
+	samples = samples[i:]
+	u.samples[serverID] = samples
+
+	base := samples[0]
+	if base.at.After(start) || start.Sub(base.at) > max(window/10, time.Minute) {
+		return 0, false
+	}
+	if u.Condition == RuleConditionDelta {
+		change = value - base.value
+	} else {
+		if base.value == 0 {
+			return 0, false
+		}
+		change = (value - base.value) / math.Abs(base.value) * 100
+	}
+	u.lastChange[serverID] = change
+	return change, true
+}
+
+func (u *Rule) observeValue(serverID uint64, value float64) {
+	if u.lastValue == nil {
+		u.lastValue = make(map[uint64]float64)
+	}
+	u.lastValue[serverID] = value
+}
+
+// ObservedValue 返回该服务器上最近一次与阈值比较的值
+func (u *Rule) ObservedValue(serverID uint64) (float64, bool) {
+	v, ok := u.lastValue[serverID]
+	return v, ok
+}
+
+// DescribeChange 返回该服务器上最近一次计算出的变化，如 memory +12.5% in 30m
+func (u *Rule) DescribeChange(serverID uint64) (string, bool) {
+	change, ok := u.lastChange[serverID]
+	if !ok {
+		return "", false
+	}
+	metric := u.Type
+	if u.Target != "" {
+		metric += "[" + u.Target + "]"
+	}
+	unit := ""
+	if u.Condition == RuleConditionRate {
+		unit = "%"
+	}
+	return fmt.Sprintf("%s %+.2f%s in %dm", metric, change, unit, u.Window), true
+}
+
 // IsTransferDurationRule 判断该规则是否属于周期流量规则 属于则返回true
 func (u *Rule) IsTransferDurationRule() bool {
 	return strings.HasSuffix(u.Type, "_cycle")
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuleTarget(t *testing.T) {
+	state := PB2State((&HostState{
+		DiskUsed:      10,
+		Disks:         []DiskState{{Mountpoint: "/", Total: 100, Used: 10}, {Mountpoint: "/data", Total: 100, Used: 95}},
+		NetInterfaces: []NetInterfaceState{{Name: "eth0", InSpeed: 2048, OutSpeed: 1024}},
+	}).PB())
+	server := &Server{Host: &Host{DiskTotal: 200}, State: &state}
+
+	cases := []struct {
+		rule Rule
+		pass bool
+	}{
+		{Rule{Type: "disk", Max: 90}, true},
+		{Rule{Type: "disk", Target: "/data", Max: 90}, false},
+		{Rule{Type: "disk", Target: "/missing", Max: 90}, true},
+		{Rule{Type: "net_all_speed", Target: "eth0", Max: 2048}, false},
+		{Rule{Type: "net_in_speed", Target: "eth1", Max: 1}, true},
+	}
+	for i, c := range cases {
+		if got := c.rule.Snapshot(nil, server, nil); got != c.pass {
+			t.Errorf("case %d: got %v, want %v", i, got, c.pass)
+		}
+	}
+	if s := state.Summary(); s.Disks != nil || s.DiskUsed != 10 || state.Disks == nil {
+		t.Error("summary should drop the breakdown without modifying the state")
+	}
+}
+
+func TestRuleLoadAndProcess(t *testing.T) {
+	server := &Server{Host: &Host{}, State: &HostState{Load1: 4.5, Load5: 2, Load15: 1, ProcessCount: 300}}
+	cases := []struct {
+		rule Rule
+		pass bool
+	}{
+		{Rule{Type: "load1", Max: 4}, false},
+		{Rule{Type: "load5", Max: 4}, true},
+		{Rule{Type: "load15", Min: 1.5}, false},
+		{Rule{Type: "process_count", Max: 500}, true},
+		{Rule{Type: "process_count", Max: 200}, false},
+	}
+	for i, c := range cases {
+		if !c.rule.KnownType() {
+			t.Fatalf("case %d: %s should be a known type", i, c.rule.Type)
+		}
+		if got := c.rule.Snapshot(nil, server, nil); got != c.pass {
+			t.Errorf("case %d: got %v, want %v", i, got, c.pass)
+		}
+	}
+}
+
+func TestAlertRuleDedupKey(t *testing.T) {
+	cpu90 := &AlertRule{Name: "cpu 90", NotificationGroupID: 1, Rules: []*Rule{{Type: "cpu", Max: 90}}}
+	cpu95 := &AlertRule{Name: "cpu 95", NotificationGroupID: 1, Rules: []*Rule{{Type: "cpu", Max: 95}, {Type: "cpu", Max: 99}}}
+	if cpu90.DedupKey(1, false) != cpu95.DedupKey(1, false) {
+		t.Error("rules on the same metric should share a key")
+	}
+
+	distinct := []string{
+		cpu90.DedupKey(2, false),
+		cpu90.DedupKey(1, true),
+		(&AlertRule{NotificationGroupID: 2, Rules: cpu90.Rules}).DedupKey(1, false),
+		(&AlertRule{NotificationGroupID: 1, Rules: []*Rule{{Type: "memory", Max: 90}}}).DedupKey(1, false),
+		(&AlertRule{NotificationGroupID: 1, Rules: []*Rule{{Type: "cpu", Max: 90}, {Type: "memory", Max: 90}}}).DedupKey(1, false),
+		(&AlertRule{NotificationGroupID: 1, Rules: []*Rule{{Type: "disk", Target: "/data", Max: 90}}}).DedupKey(1, false),
+		(&AlertRule{NotificationGroupID: 1, Rules: []*Rule{{Type: "disk", Max: 90}}}).DedupKey(1, false),
+	}
+	seen := map[string]bool{cpu90.DedupKey(1, false): true}
+	for i, key := range distinct {
+		if seen[key] {
+			t.Errorf("case %d: distinct incident shares key %s", i, key)
+		}
+		seen[key] = true
+	}
+}
+
+func TestRuleChange(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := &Server{Host: &Host{MemTotal: 100}, State: &HostState{}}
+	rule := &Rule{Type: "memory", Condition: RuleConditionRate, Window: 10, Max: 50}
+
+	observe := func(minute int, used uint64) bool {
+		server.LastActive = start.Add(time.Duration(minute) * time.Minute)
+		server.State.MemUsed = used
+		return rule.Snapshot(nil, server, nil)
+	}
+
+	// 采样未覆盖整个窗口
+	for m := 0; m < 10; m++ {
+		if !observe(m, 20) {
+			t.Fatalf("minute %d: should pass without a full window", m)
+		}
+	}
+	if _, ok := rule.DescribeChange(server.ID); ok {
+		t.Fatal("no change should be recorded without a full window")
+	}
+	if observe(10, 40) {
+		t.Fatal("memory doubled in 10 minutes, should fail")
+	}
+	if desc, _ := rule.DescribeChange(server.ID); desc != "memory +100.00% in 10m" {
+		t.Errorf("unexpected description %q", desc)
+	}
+	if !observe(11, 25) {
+		t.Fatal("memory grew 25%, should pass")
+	}
+
+	// 服务器长时间未上报后，窗口起点附近没有采样
+	if !observe(40, 80) {
+		t.Fatal("should pass after a gap in samples")
+	}
+
+	delta := &Rule{Type: "load1", Condition: RuleConditionDelta, Window: 5, Min: 1}
+	server.State.Load1 = 3
+	server.LastActive = start
+	delta.Snapshot(nil, server, nil)
+	server.State.Load1 = 1.5
+	server.LastActive = start.Add(5 * time.Minute)
+	if delta.Snapshot(nil, server, nil) {
+		t.Fatal("load1 dropped by 1.5, should fail")
+	}
+}
@@ -0,0 +1,29 @@
+package model
+
+const (
+	SearchTypeServer       = "server"
+	SearchTypeAlertRule    = "alert_rule"
+	SearchTypeNotification = "notification"
+	SearchTypeCron         = "cron"
+)
+
+type SearchResultItem struct {
+	Type string `json:"type" enums:"server,alert_rule,notification,cron"`
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+	// 匹配的字段，如 name、command
+	Field string `json:"field"`
+}
+
+type SearchCategory struct {
+	Items []SearchResultItem `json:"items"`
+	// 超出数量上限时为 true
+	More bool `json:"more,omitempty" validate:"optional"`
+}
+
+type SearchResponse struct {
+	Servers       SearchCategory `json:"servers"`
+	AlertRules    SearchCategory `json:"alert_rules"`
+	Notifications SearchCategory `json:"notifications"`
+	Crons         SearchCategory `json:"crons"`
+}
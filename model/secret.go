@@ -0,0 +1,79 @@
+package model
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"regexp"
+)
+
+// SecretsKeyEnv 指定主密钥的环境变量，优先于配置文件中的 secrets_key
+const SecretsKeyEnv = "NZ_SECRETS_KEY"
+
+// MaxSecretSize 单个密钥值的最大字节数
+const MaxSecretSize = 64 << 10
+
+// SecretNamePattern 密钥以同名环境变量提供给计划任务与终端
+var SecretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Secret 服务器密钥，值以 AES-GCM 加密保存，创建后不再通过接口返回
+type Secret struct {
+	Common
+	ServerID    uint64 `json:"server_id" gorm:"index"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Ciphertext  string `json:"-"`
+	KeyID       string `json:"-"` // 加密所用主密钥的指纹
+}
+
+// DeriveSecretKey 由主密钥派生 AES-256 密钥，并返回用于区分主密钥的指纹
+func DeriveSecretKey(master string) (key []byte, keyID string) {
+	sum := sha256.Sum256([]byte(master))
+	id := sha256.Sum256(sum[:])
+	return sum[:], hex.EncodeToString(id[:8])
+}
+
+// EncryptSecret 加密密钥值，结果为 base64 编码的 nonce 与密文
+func EncryptSecret(key []byte, plaintext string) (string, error) {
+	aead, err := newSecretAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+// DecryptSecret 解密 EncryptSecret 的结果
+func DecryptSecret(key []byte, ciphertext string) (string, error) {
+	aead, err := newSecretAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("secret: ciphertext too short")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("secret: decryption failed, the master key may have changed")
+	}
+	return string(plaintext), nil
+}
+
+func newSecretAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,14 @@
+package model
+
+type SecretForm struct {
+	ServerID    uint64 `json:"server_id,omitempty"`
+	Name        string `json:"name,omitempty" minLength:"1"` // 环境变量名
+	Description string `json:"description,omitempty" validate:"optional"`
+	// 创建时必填，更新时为空表示保持不变
+	Value string `json:"value,omitempty" validate:"optional"`
+}
+
+type SecretKeyRotateForm struct {
+	// 新的主密钥，为空时随机生成
+	Key string `json:"key,omitempty" validate:"optional"`
+}
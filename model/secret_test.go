@@ -0,0 +1,29 @@
+package model
+
+import "testing"
+
+func TestSecretEncryption(t *testing.T) {
+	key, id := DeriveSecretKey("master")
+	if _, id2 := DeriveSecretKey("master"); id != id2 {
+		t.Fatal("key id should be stable")
+	}
+
+	ciphertext, err := EncryptSecret(key, "ssh-ed25519 AAAA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := EncryptSecret(key, "ssh-ed25519 AAAA"); again == ciphertext {
+		t.Fatal("nonce should be random")
+	}
+	if plaintext, err := DecryptSecret(key, ciphertext); err != nil || plaintext != "ssh-ed25519 AAAA" {
+		t.Fatalf("unexpected result %q, %v", plaintext, err)
+	}
+
+	other, otherID := DeriveSecretKey("other")
+	if otherID == id {
+		t.Fatal("different keys should have different ids")
+	}
+	if _, err := DecryptSecret(other, ciphertext); err == nil {
+		t.Fatal("decryption with a wrong key should fail")
+	}
+}
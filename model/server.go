@@ -2,6 +2,8 @@ package model
 
 import (
 	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -10,12 +12,21 @@ import (
 	pb "github.com/nezhahq/nezha/proto"
 )
 
+// 默认超过该时间（秒）未上报视为离线，可在设置中修改，并按服务器单独覆盖
+const DefaultServerOfflineTimeout = 30
+
+// MaxServerNoteSize 服务器备注的最大字节数
+const MaxServerNoteSize = 16 * 1024
+
+// UnknownAgentVersion Agent 未上报版本号时展示的版本
+const UnknownAgentVersion = "unknown"
+
 type Server struct {
 	Common
 
 	Name            string `json:"name"`
 	UUID            string `json:"uuid,omitempty" gorm:"unique"`
-	Note            string `json:"note,omitempty"`           // 管理员可见备注
+	Note            string `json:"note,omitempty"`           // 备注，Markdown 格式，可查看该服务器的用户可见
 	PublicNote      string `json:"public_note,omitempty"`    // 公开备注
 	DisplayIndex    int    `json:"display_index"`            // 展示排序，越大越靠前
 	HideForGuest    bool   `json:"hide_for_guest,omitempty"` // 对游客隐藏
@@ -24,6 +35,24 @@ type Server struct {
 
 	DDNSProfiles []uint64 `gorm:"-" json:"ddns_profiles,omitempty" validate:"optional"` // DDNS配置
 
+	TagsRaw string   `gorm:"default:'[]'" json:"-"`
+	Tags    []string `gorm:"-" json:"tags,omitempty"` // 标签
+
+	MaintenanceUntil  *time.Time `json:"maintenance_until,omitempty"`  // 维护模式结束时间，期间不触发报警
+	MaintenanceReason string     `json:"maintenance_reason,omitempty"` // 维护原因
+
+	OwnerID uint64 `gorm:"-" json:"owner_id,omitempty"` // 所属用户，与 UserID 相同
+
+	AgentVersion string `gorm:"default:'unknown'" json:"agent_version"` // Agent 最近一次上报的版本
+
+	OfflineTimeout          uint64 `json:"offline_timeout,omitempty"`                    // 离线判定时间（秒），为 0 时使用全局设置
+	EffectiveOfflineTimeout uint64 `gorm:"-" json:"effective_offline_timeout,omitempty"` // 实际生效的离线判定时间（秒），仅服务器详情返回
+
+	Connection *ServerConnection `gorm:"-" json:"connection,omitempty"` // Agent 连接与重连状态
+
+	// 删除后进入回收站，超过保留天数后彻底删除
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string" validate:"optional"`
+
 	Host       *Host      `gorm:"-" json:"host,omitempty"`
 	State      *HostState `gorm:"-" json:"state,omitempty"`
 	GeoIP      *GeoIP     `gorm:"-" json:"geoip,omitempty"`
@@ -45,12 +74,64 @@ func (s *Server) CopyFromRunningServer(old *Server) {
 	s.PrevTransferOutSnapshot = old.PrevTransferOutSnapshot
 }
 
+// GetOfflineTimeout 返回服务器的离线判定时间，未单独设置时使用全局设置 global（秒）
+func (s *Server) GetOfflineTimeout(global int) time.Duration {
+	if s.OfflineTimeout > 0 {
+		return time.Duration(s.OfflineTimeout) * time.Second
+	}
+	if global > 0 {
+		return time.Duration(global) * time.Second
+	}
+	return DefaultServerOfflineTimeout * time.Second
+}
+
+// AgentOutdated 判断 Agent 版本是否低于 min，版本未知或无法解析时视为未过期
+func (s *Server) AgentOutdated(min string) bool {
+	if min == "" {
+		return false
+	}
+	c, ok := utils.CompareVersion(s.AgentVersion, min)
+	return ok && c < 0
+}
+
+func (s *Server) IsOnline(global int) bool {
+	return !s.LastActive.IsZero() && time.Since(s.LastActive) < s.GetOfflineTimeout(global)
+}
+
+// InMaintenance 判断服务器当前是否处于维护模式
+func (s *Server) InMaintenance(now time.Time) bool {
+	return s.MaintenanceUntil != nil && now.Before(*s.MaintenanceUntil)
+}
+
 func (s *Server) AfterFind(tx *gorm.DB) error {
+	s.OwnerID = s.UserID
 	if s.DDNSProfilesRaw != "" {
 		if err := utils.Json.Unmarshal([]byte(s.DDNSProfilesRaw), &s.DDNSProfiles); err != nil {
 			log.Println("NEZHA>> Server.AfterFind:", err)
 			return nil
 		}
 	}
+	if s.TagsRaw != "" {
+		if err := utils.Json.Unmarshal([]byte(s.TagsRaw), &s.Tags); err != nil {
+			log.Println("NEZHA>> Server.AfterFind:", err)
+			return nil
+		}
+	}
 	return nil
 }
+
+func (s *Server) HasTag(tag string) bool {
+	return slices.Contains(s.Tags, tag)
+}
+
+// NormalizeTags 去除空白与重复的标签并排序
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if t = strings.TrimSpace(t); t != "" {
+			normalized = append(normalized, t)
+		}
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
@@ -1,6 +1,11 @@
 package model
 
-import "time"
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type StreamServer struct {
 	ID           uint64 `json:"id,omitempty"`
@@ -12,6 +17,75 @@ type StreamServer struct {
 	State       *HostState `json:"state,omitempty"`
 	CountryCode string     `json:"country_code,omitempty"`
 	LastActive  time.Time  `json:"last_active,omitempty"`
+	Groups      []uint64   `json:"groups,omitempty"` // 所属分组
+	Tags        []string   `json:"tags,omitempty"`   // 标签，仅登录用户可见
+
+	InMaintenance     bool       `json:"in_maintenance,omitempty"`
+	MaintenanceUntil  *time.Time `json:"maintenance_until,omitempty"`
+	MaintenanceReason string     `json:"maintenance_reason,omitempty"` // 维护原因，仅登录用户可见
+
+	Connection *ServerConnection `json:"connection,omitempty"` // 连接状态，只在第一个数据包与状态变化后推送
+}
+
+// AgentVersionSummary 各 Agent 版本的服务器分布，用于规划升级
+type AgentVersionSummary struct {
+	MinVersion string              `json:"min_version,omitempty"` // 设置中的最低版本
+	Versions   []AgentVersionCount `json:"versions"`
+}
+
+type AgentVersionCount struct {
+	Version  string   `json:"version"`
+	Count    int      `json:"count"`
+	Outdated bool     `json:"outdated,omitempty"` // 低于最低版本
+	Servers  []uint64 `json:"servers"`
+}
+
+// StreamServerFilter 实时推送的订阅条件，连接建立后可随时发送新的条件更新订阅，发送空对象取消筛选
+type StreamServerFilter struct {
+	Groups []uint64 `json:"groups,omitempty"` // 属于其中任一分组
+	Tags   []string `json:"tags,omitempty"`   // 同时具有全部标签，仅登录用户可用
+}
+
+func (f *StreamServerFilter) Empty() bool {
+	return f == nil || (len(f.Groups) == 0 && len(f.Tags) == 0)
+}
+
+// Key 返回订阅条件的规范化表示，条件相同的连接可共用序列化结果
+func (f *StreamServerFilter) Key() string {
+	if f.Empty() {
+		return ""
+	}
+	groups := slices.Clone(f.Groups)
+	slices.Sort(groups)
+	var b strings.Builder
+	for _, g := range slices.Compact(groups) {
+		b.WriteString(strconv.FormatUint(g, 10))
+		b.WriteByte(',')
+	}
+	b.WriteByte('|')
+	for _, t := range NormalizeTags(f.Tags) {
+		b.WriteString(strconv.Quote(t))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// Match 判断服务器是否符合订阅条件，groups 为服务器所属的分组
+func (f *StreamServerFilter) Match(s *Server, groups []uint64) bool {
+	if f.Empty() {
+		return true
+	}
+	if len(f.Groups) > 0 && !slices.ContainsFunc(groups, func(g uint64) bool {
+		return slices.Contains(f.Groups, g)
+	}) {
+		return false
+	}
+	for _, t := range f.Tags {
+		if !s.HasTag(t) {
+			return false
+		}
+	}
+	return true
 }
 
 type StreamServerData struct {
@@ -22,12 +96,37 @@ type StreamServerData struct {
 
 type ServerForm struct {
 	Name         string   `json:"name,omitempty"`
-	Note         string   `json:"note,omitempty" validate:"optional"`                   // 管理员可见备注
+	Note         string   `json:"note,omitempty" validate:"optional"`                   // 备注，Markdown 格式
 	PublicNote   string   `json:"public_note,omitempty" validate:"optional"`            // 公开备注
 	DisplayIndex int      `json:"display_index,omitempty" default:"0"`                  // 展示排序，越大越靠前
 	HideForGuest bool     `json:"hide_for_guest,omitempty" validate:"optional"`         // 对游客隐藏
 	EnableDDNS   bool     `json:"enable_ddns,omitempty" validate:"optional"`            // 启用DDNS
 	DDNSProfiles []uint64 `gorm:"-" json:"ddns_profiles,omitempty" validate:"optional"` // DDNS配置
+	// 离线判定时间（秒），为 0 时使用全局设置
+	OfflineTimeout uint64 `json:"offline_timeout,omitempty" validate:"optional"`
+}
+
+type ServerNoteForm struct {
+	Note string `json:"note" validate:"optional"` // Markdown 格式，为空时清除
+}
+
+type ServerOwnerForm struct {
+	UserID uint64 `json:"user_id" minimum:"1"` // 新的所属用户
+}
+
+type ServerMaintenanceForm struct {
+	Duration uint64 `json:"duration" minimum:"1"`                 // 维护时长 (秒)
+	Reason   string `json:"reason,omitempty" validate:"optional"` // 维护原因
+}
+
+type ServerTagRenameForm struct {
+	From string `json:"from" minLength:"1"`
+	To   string `json:"to" minLength:"1"`
+}
+
+type ServerTagResponseItem struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
 }
 
 type ForceUpdateResponse struct {
@@ -0,0 +1,32 @@
+package model
+
+import "testing"
+
+func TestStreamServerFilter(t *testing.T) {
+	s := &Server{Tags: []string{"prod", "hk"}}
+	cases := []struct {
+		filter *StreamServerFilter
+		groups []uint64
+		match  bool
+	}{
+		{nil, nil, true},
+		{&StreamServerFilter{Groups: []uint64{2, 3}}, []uint64{1, 3}, true},
+		{&StreamServerFilter{Groups: []uint64{2}}, []uint64{1, 3}, false},
+		{&StreamServerFilter{Tags: []string{"prod", "hk"}}, nil, true},
+		{&StreamServerFilter{Groups: []uint64{1}, Tags: []string{"prod", "us"}}, []uint64{1}, false},
+	}
+	for i, c := range cases {
+		if got := c.filter.Match(s, c.groups); got != c.match {
+			t.Errorf("case %d: got %v, want %v", i, got, c.match)
+		}
+	}
+
+	a := &StreamServerFilter{Groups: []uint64{3, 1, 3}, Tags: []string{"b", " a"}}
+	b := &StreamServerFilter{Groups: []uint64{1, 3}, Tags: []string{"a", "b"}}
+	if a.Key() != b.Key() || a.Key() == (&StreamServerFilter{Groups: []uint64{1}}).Key() {
+		t.Errorf("unexpected filter keys %q and %q", a.Key(), b.Key())
+	}
+	if (&StreamServerFilter{}).Key() != "" {
+		t.Error("empty filter should have an empty key")
+	}
+}
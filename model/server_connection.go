@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+const (
+	ServerConnectionConnected    = "connected"
+	ServerConnectionReconnecting = "reconnecting"
+	ServerConnectionOffline      = "offline"
+)
+
+const (
+	// Agent 断线后按指数退避重连，间隔从 ReconnectBackoffBase 开始翻倍，最长 ReconnectBackoffMax
+	ReconnectBackoffBase = 5 * time.Second
+	ReconnectBackoffMax  = 5 * time.Minute
+	// 超过该重连次数仍未连上视为离线
+	ReconnectMaxAttempts = 8
+	// 连接保持该时长后视为重连成功，之前断开仍算作同一轮重连，用于识别反复断连的服务器
+	ReconnectStableAfter = time.Minute
+)
+
+// ServerConnection Agent 与面板的连接状态
+type ServerConnection struct {
+	State          string     `json:"state"`
+	Attempts       uint64     `json:"attempts,omitempty"`        // 正在进行第几次重连，连接稳定后清零
+	DisconnectedAt *time.Time `json:"disconnected_at,omitempty"` // 最近一次断开的时间
+	NextRetryAt    *time.Time `json:"next_retry_at,omitempty"`   // 按退避策略估算的下次重连时间
+	ChangedAt      time.Time  `json:"changed_at"`                // 状态或重连次数最近一次变化的时间
+}
+
+// ReconnectBackoff 返回第 attempt 次重连前的等待时间，attempt 从 1 开始
+func ReconnectBackoff(attempt uint64) time.Duration {
+	d := ReconnectBackoffBase
+	for i := uint64(1); i < attempt && d < ReconnectBackoffMax; i++ {
+		d *= 2
+	}
+	return min(d, ReconnectBackoffMax)
+}
+
+// EstimateReconnect 根据断开时间估算 now 时正在等待的重连次数及其时间，
+// carried 为断开前本轮已进行的重连次数
+func EstimateReconnect(carried uint64, disconnectedAt, now time.Time) (attempt uint64, next time.Time) {
+	attempt, next = carried+1, disconnectedAt.Add(ReconnectBackoff(carried+1))
+	for !next.After(now) && attempt <= ReconnectMaxAttempts {
+		attempt++
+		next = next.Add(ReconnectBackoff(attempt))
+	}
+	return attempt, next
+}
+
+// Equal 判断两次连接状态是否相同，忽略估算的时间
+func (c ServerConnection) Equal(o ServerConnection) bool {
+	return c.State == o.State && c.Attempts == o.Attempts
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	cases := map[uint64]time.Duration{
+		0:  5 * time.Second,
+		1:  5 * time.Second,
+		2:  10 * time.Second,
+		4:  40 * time.Second,
+		7:  5 * time.Minute,
+		64: 5 * time.Minute,
+	}
+	for attempt, want := range cases {
+		if got := ReconnectBackoff(attempt); got != want {
+			t.Errorf("ReconnectBackoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestEstimateReconnect(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	cases := []struct {
+		carried uint64
+		elapsed time.Duration
+		attempt uint64
+		next    time.Duration
+	}{
+		{0, 0, 1, 5 * time.Second},
+		{0, 4 * time.Second, 1, 5 * time.Second},
+		{0, 5 * time.Second, 2, 15 * time.Second},
+		{0, 20 * time.Second, 3, 35 * time.Second},
+		// 连接不稳定时继续上一轮的重连次数
+		{2, 0, 3, 20 * time.Second},
+		{0, time.Hour, ReconnectMaxAttempts + 1, 20*time.Minute + 15*time.Second},
+	}
+	for _, c := range cases {
+		attempt, next := EstimateReconnect(c.carried, start, start.Add(c.elapsed))
+		if attempt != c.attempt || !next.Equal(start.Add(c.next)) {
+			t.Errorf("EstimateReconnect(%d, +%s) = %d, +%s, want %d, +%s",
+				c.carried, c.elapsed, attempt, next.Sub(start), c.attempt, c.next)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const EnrollmentTokenPrefix = "nze_"
+
+const (
+	ServerEnrollmentPending  = "pending"
+	ServerEnrollmentApproved = "approved"
+	ServerEnrollmentRejected = "rejected"
+)
+
+// EnrollmentToken 供 Agent 自助注册的令牌，以此注册的服务器需审批后才加入服务器列表。
+// 删除令牌即撤销，已审批的服务器不受影响
+type EnrollmentToken struct {
+	Common
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex;type:char(64)"`
+	SingleUse  bool       `json:"single_use,omitempty"` // 只能注册一台服务器
+	UsedCount  uint64     `json:"used_count,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// ServerEnrollment Agent 以注册令牌发起的注册申请，归属于令牌的创建者
+type ServerEnrollment struct {
+	Common
+	UUID     string `json:"uuid" gorm:"uniqueIndex"`
+	TokenID  uint64 `json:"token_id" gorm:"index"`
+	IP       string `json:"ip,omitempty"`
+	Status   string `json:"status" enums:"pending,approved,rejected"`
+	ServerID uint64 `json:"server_id,omitempty"` // 审批通过后创建的服务器
+	// 注册时使用的令牌哈希，审批通过后 Agent 继续以该令牌连接
+	SecretHash string `json:"-" gorm:"type:char(64)"`
+
+	// 导入时预设的服务器信息，审批通过后据此创建服务器，未预设名称时随机生成
+	Name    string   `json:"name,omitempty"`
+	Note    string   `json:"note,omitempty"`
+	TagsRaw string   `gorm:"default:'[]'" json:"-"`
+	Tags    []string `gorm:"-" json:"tags,omitempty"`
+	GroupID uint64   `json:"group_id,omitempty"`
+}
+
+func (e *ServerEnrollment) AfterFind(tx *gorm.DB) error {
+	if e.TagsRaw != "" {
+		if err := utils.Json.Unmarshal([]byte(e.TagsRaw), &e.Tags); err != nil {
+			log.Println("NEZHA>> ServerEnrollment.AfterFind:", err)
+		}
+	}
+	return nil
+}
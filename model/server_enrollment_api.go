@@ -0,0 +1,12 @@
+package model
+
+type EnrollmentTokenForm struct {
+	Name      string `json:"name,omitempty" minLength:"1"`
+	SingleUse bool   `json:"single_use,omitempty" validate:"optional"`
+}
+
+type EnrollmentTokenResponse struct {
+	ID uint64 `json:"id,omitempty"`
+	// 令牌明文仅在创建时返回一次，作为 Agent 的 client_secret 使用
+	Token string `json:"token,omitempty"`
+}
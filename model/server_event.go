@@ -0,0 +1,100 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// ServerEvent 服务器上线或离线的状态变化，CreatedAt 为状态实际变化的时间
+type ServerEvent struct {
+	ID        uint64    `gorm:"primaryKey" json:"id,omitempty"`
+	CreatedAt time.Time `gorm:"index:idx_server_event_server_id_created_at,priority:2" json:"created_at,omitempty"`
+	ServerID  uint64    `gorm:"index:idx_server_event_server_id_created_at,priority:1" json:"server_id,omitempty"`
+	Online    bool      `json:"online"`
+}
+
+// TimeRange 左闭右开的时间段
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ServerUptime 计算 [from, to) 内的在线时间占比（百分比），没有可统计的时间时返回 -1
+func ServerUptime(prev *ServerEvent, events []ServerEvent, from, to time.Time) float64 {
+	return UptimePercent(ServerAvailability(prev, events, from, to, nil))
+}
+
+// UptimePercent 将在线时长与统计时长换算为百分比，统计时长为 0 时返回 -1
+func UptimePercent(online, measured time.Duration) float64 {
+	if measured <= 0 {
+		return -1
+	}
+	return float64(online) / float64(measured) * 100
+}
+
+// ServerAvailability 统计 [from, to) 内的在线时长与可统计的总时长。
+// prev 为 from 之前的最后一次状态变化，为空时首个事件之前的状态未知，不计入统计；
+// events 须按时间升序排列且均位于窗口内；excluded 中的时间段（如维护期间）不计入统计。
+func ServerAvailability(prev *ServerEvent, events []ServerEvent, from, to time.Time, excluded []TimeRange) (online, measured time.Duration) {
+	excluded = mergeTimeRanges(excluded)
+	cursor, state, hasState := from, false, prev != nil
+	if hasState {
+		state = prev.Online
+	}
+
+	accumulate := func(until time.Time) {
+		if !hasState || !until.After(cursor) {
+			return
+		}
+		d := until.Sub(cursor)
+		for _, r := range excluded {
+			d -= overlap(cursor, until, r)
+		}
+		measured += d
+		if state {
+			online += d
+		}
+	}
+	for _, e := range events {
+		accumulate(e.CreatedAt)
+		if e.CreatedAt.After(cursor) {
+			cursor = e.CreatedAt
+		}
+		state, hasState = e.Online, true
+	}
+	accumulate(to)
+	return online, measured
+}
+
+func mergeTimeRanges(ranges []TimeRange) []TimeRange {
+	if len(ranges) < 2 {
+		return ranges
+	}
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b TimeRange) int { return a.Start.Compare(b.Start) })
+	merged := sorted[:1]
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if !r.Start.After(last.End) {
+			if r.End.After(last.End) {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func overlap(start, end time.Time, r TimeRange) time.Duration {
+	if r.Start.After(start) {
+		start = r.Start
+	}
+	if r.End.Before(end) {
+		end = r.End
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
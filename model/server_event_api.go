@@ -0,0 +1,7 @@
+package model
+
+type ServerEventResponse struct {
+	Events []ServerEvent `json:"events"`
+	// 查询范围内的在线时间占比（百分比），没有可统计的状态记录时为 -1
+	Uptime float64 `json:"uptime"`
+}
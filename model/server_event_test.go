@@ -0,0 +1,37 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestServerUptime(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+	at := func(h int) time.Time { return from.Add(time.Duration(h) * time.Hour) }
+
+	cases := []struct {
+		prev   *ServerEvent
+		events []ServerEvent
+		want   float64
+	}{
+		{&ServerEvent{Online: true}, nil, 100},
+		{&ServerEvent{Online: true}, []ServerEvent{{CreatedAt: at(2)}, {CreatedAt: at(3), Online: true}}, 90},
+		// 首个事件之前的状态未知，仅统计之后的 8 小时
+		{nil, []ServerEvent{{CreatedAt: at(2), Online: true}, {CreatedAt: at(6)}}, 50},
+		{nil, nil, -1},
+	}
+	for i, c := range cases {
+		if got := ServerUptime(c.prev, c.events, from, to); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("case %d: got %v, want %v", i, got, c.want)
+		}
+	}
+
+	prev := &ServerEvent{}
+	maintenance := []TimeRange{{at(1), at(3)}, {at(2), at(4)}}
+	online, measured := ServerAvailability(prev, []ServerEvent{{CreatedAt: at(5), Online: true}}, from, to, maintenance)
+	if online != 5*time.Hour || measured != 7*time.Hour {
+		t.Errorf("excluding maintenance: got %v/%v", online, measured)
+	}
+}
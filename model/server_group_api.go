@@ -8,4 +8,16 @@ type ServerGroupForm struct {
 type ServerGroupResponseItem struct {
 	Group   ServerGroup `json:"group"`
 	Servers []uint64    `json:"servers"`
+	Online  int         `json:"online"`
+	Offline int         `json:"offline"`
+}
+
+type BatchServerGroupForm struct {
+	Servers []uint64 `json:"servers"`
+	GroupID *uint64  `json:"group_id,omitempty" validate:"optional"` // 为空时将服务器移出所有分组
+}
+
+type BatchServerGroupResponse struct {
+	Success []uint64 `json:"success,omitempty" validate:"optional"`
+	Failure []uint64 `json:"failure,omitempty" validate:"optional"` // 不存在或无权限的服务器
 }
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const (
+	// MaxServerImportSize 导入的 CSV 最大字节数
+	MaxServerImportSize = 1 << 20
+	// MaxServerImportRows 单次导入的最大行数，不含表头
+	MaxServerImportRows = 1000
+)
+
+// 与已有服务器重名时的处理方式
+const (
+	ServerImportSkip   = "skip"
+	ServerImportUpdate = "update"
+)
+
+const (
+	ServerImportCreated = "created" // 已创建服务器，Agent 以返回的 UUID 接入
+	ServerImportPending = "pending" // 已创建待审批的注册申请，Agent 以返回的 UUID 与注册令牌接入
+	ServerImportUpdated = "updated"
+	ServerImportSkipped = "skipped"
+	ServerImportFailed  = "failed"
+)
+
+// ServerImportColumns 导入文件可用的列，name 必填
+var ServerImportColumns = []string{"name", "group", "tags", "note", "enrollment_token"}
+
+// ServerImportRow CSV 中的一行，Err 不为空时该行格式错误
+type ServerImportRow struct {
+	Line            int
+	Name            string
+	Group           string   // 分组名称
+	Tags            []string // 以 ; 分隔
+	Note            string
+	EnrollmentToken string // 填写时创建待审批的注册申请，而不是直接创建服务器
+	Err             error
+}
+
+type ServerImportResult struct {
+	Line     int    `json:"line"` // 在文件中的行号，表头为第 1 行
+	Name     string `json:"name,omitempty"`
+	Status   string `json:"status" enums:"created,pending,updated,skipped,failed"`
+	ServerID uint64 `json:"server_id,omitempty"`
+	UUID     string `json:"uuid,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ParseServerImportCSV 解析导入文件，表头有误时返回错误，格式错误的行记录在该行的 Err 中，不影响其余行
+func ParseServerImportCSV(r io.Reader) ([]ServerImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("missing header")
+		}
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if !slices.Contains(ServerImportColumns, h) {
+			return nil, fmt.Errorf("unknown column: %s", h)
+		}
+		if _, ok := columns[h]; ok {
+			return nil, fmt.Errorf("duplicate column: %s", h)
+		}
+		columns[h] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("missing column: name")
+	}
+
+	var rows []ServerImportRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if !errors.As(err, &pe) {
+				return nil, err
+			}
+			// 引号不匹配等错误之后无法可靠地继续解析
+			rows = append(rows, ServerImportRow{Line: pe.StartLine, Err: pe.Err})
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		row := ServerImportRow{Line: line}
+		if len(rows) == MaxServerImportRows {
+			return nil, fmt.Errorf("too many rows, the limit is %d", MaxServerImportRows)
+		}
+		if len(record) != len(header) {
+			row.Err = fmt.Errorf("expected %d fields, got %d", len(header), len(record))
+			rows = append(rows, row)
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row.Name = field("name")
+		row.Group = field("group")
+		row.Tags = NormalizeTags(strings.Split(field("tags"), ";"))
+		row.Note = field("note")
+		row.EnrollmentToken = field("enrollment_token")
+		switch {
+		case row.Name == "":
+			row.Err = errors.New("name can't be empty")
+		case len(row.Note) > MaxServerNoteSize:
+			row.Err = fmt.Errorf("note exceeds the size limit of %d bytes", MaxServerNoteSize)
+		case row.EnrollmentToken != "" && !strings.HasPrefix(row.EnrollmentToken, EnrollmentTokenPrefix):
+			row.Err = errors.New("invalid enrollment token")
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseServerImportCSV(t *testing.T) {
+	rows, err := ParseServerImportCSV(strings.NewReader("\ufeffName, Tags,group,note,enrollment_token\n" +
+		"web-1, prod ; web;,Web,\"multi\nline\",\n" +
+		",,,,\n" +
+		"db-1,,,note,bad_token\n" +
+		"db-2,db\n" +
+		"db-3,,,,nze_abc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("expected 5 rows, got %d", len(rows))
+	}
+
+	web := rows[0]
+	if web.Err != nil || web.Line != 2 || web.Name != "web-1" || web.Group != "Web" || web.Note != "multi\nline" ||
+		!slices.Equal(web.Tags, []string{"prod", "web"}) {
+		t.Fatalf("unexpected row: %+v", web)
+	}
+	for i, line := range []int{4, 5, 6} {
+		if rows[i+1].Err == nil || rows[i+1].Line != line {
+			t.Errorf("row on line %d should be rejected: %+v", line, rows[i+1])
+		}
+	}
+	if rows[4].Err != nil || rows[4].EnrollmentToken != "nze_abc" || len(rows[4].Tags) != 0 {
+		t.Fatalf("unexpected row: %+v", rows[4])
+	}
+
+	rows, err = ParseServerImportCSV(strings.NewReader("name\nok\n\"broken\nnext\n"))
+	if err != nil || len(rows) != 2 || rows[0].Err != nil || rows[1].Err == nil {
+		t.Fatalf("unexpected result of unterminated quote: %+v, %v", rows, err)
+	}
+
+	for _, header := range []string{"", "group,note\n", "name,ip\n", "name,Name\n"} {
+		if _, err := ParseServerImportCSV(strings.NewReader(header)); err == nil {
+			t.Errorf("header %q should be rejected", header)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// ServerMaintenance 服务器维护模式的历史记录，提前结束维护时 EndAt 会更新为实际结束时间
+type ServerMaintenance struct {
+	ID       uint64    `gorm:"primaryKey" json:"id,omitempty"`
+	ServerID uint64    `gorm:"index" json:"server_id,omitempty"`
+	StartAt  time.Time `json:"start_at,omitempty"`
+	EndAt    time.Time `gorm:"index" json:"end_at,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
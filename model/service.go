@@ -3,6 +3,8 @@ package model
 import (
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
@@ -24,12 +26,46 @@ const (
 	TaskTypeNAT
 	TaskTypeReportHostInfoDeprecated
 	TaskTypeFM
+	TaskTypeGRPCHealth
+	TaskTypeDNS
+	TaskTypeTLSCert
+	TaskTypeCommandWithEnv
+	TaskTypeCommandOutput
+	TaskTypeCommandAck
 )
 
 type TerminalTask struct {
 	StreamID string
+	Env      map[string]string `json:",omitempty"` // 会话的环境变量，来自服务器密钥
 }
 
+// CommandTask 附带环境变量的计划任务，未使用密钥的计划任务仍以 TaskTypeCommand 下发
+type CommandTask struct {
+	Command string
+	Env     map[string]string
+}
+
+const (
+	CommandOutputStdout = "stdout"
+	CommandOutputStderr = "stderr"
+)
+
+// CommandOutput 计划任务执行过程中 Agent 以 TaskTypeCommandOutput 上报的实时输出，
+// 任务 ID 为计划任务 ID，退出码在最后一段输出中上报
+type CommandOutput struct {
+	Stream   string
+	Data     string
+	ExitCode *int `json:",omitempty"`
+}
+
+// Agent 在 RequestTask 的 metadata 中以 AgentFeaturesMetadataKey 声明支持的功能。
+// 声明了 AgentFeatureCommandAck 的 Agent 收到计划任务后以 TaskTypeCommandAck 上报确认，任务 ID 为计划任务 ID，
+// 未声明的 Agent 不跟踪投递状态
+const (
+	AgentFeaturesMetadataKey = "features"
+	AgentFeatureCommandAck   = "command_ack"
+)
+
 type TaskNAT struct {
 	StreamID string
 	Host     string
@@ -44,6 +80,11 @@ const (
 	ServiceCoverIgnoreAll
 )
 
+const (
+	ServiceAuthBasic  = "basic"
+	ServiceAuthBearer = "bearer"
+)
+
 type Service struct {
 	Common
 	Name                string `json:"name"`
@@ -67,6 +108,24 @@ type Service struct {
 	MaxLatency    float32 `json:"max_latency"`
 	LatencyNotify bool    `json:"latency_notify,omitempty"`
 
+	CertExpireDays uint64 `json:"cert_expire_days,omitempty"` // 证书在 N 天内过期时报警，为 0 时默认 7 天
+
+	// HTTP 监控的响应体断言
+	Keyword           string `json:"keyword,omitempty"`
+	KeywordRegex      bool   `json:"keyword_regex,omitempty"`       // 关键字为正则表达式
+	KeywordInvert     bool   `json:"keyword_invert,omitempty"`      // 响应体中不能出现关键字
+	KeywordIgnoreCase bool   `json:"keyword_ignore_case,omitempty"` // 忽略大小写
+
+	// HTTP 监控每次请求附带的请求头与认证
+	HeadersRaw   string            `gorm:"default:'{}'" json:"-"`
+	Headers      map[string]string `gorm:"-" json:"headers,omitempty"`
+	AuthType     string            `json:"auth_type,omitempty"` // basic、bearer，为空表示不认证
+	AuthUsername string            `json:"auth_username,omitempty"`
+	AuthSecret   string            `json:"auth_secret,omitempty"` // basic 认证的密码或 bearer 令牌
+
+	// 访问在线率徽章所需的令牌，为空时不开放徽章
+	BadgeToken string `json:"badge_token,omitempty"`
+
 	SkipServers map[uint64]bool `gorm:"-" json:"skip_servers"`
 	CronJobID   cron.EntryID    `gorm:"-" json:"-"`
 }
@@ -79,6 +138,37 @@ func (m *Service) PB() *pb.Task {
 	}
 }
 
+// CertExpireWindow 返回证书过期报警的提前时长
+func (m *Service) CertExpireWindow() time.Duration {
+	days := m.CertExpireDays
+	if days == 0 {
+		days = 7
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ParseServiceCert 解析监控上报的证书信息，格式为 签发者|过期时间[|标记]，无法解析时返回 nil
+func ParseServiceCert(data string, now time.Time) *ServiceCert {
+	parts := strings.Split(data, "|")
+	if len(parts) < 2 {
+		return nil
+	}
+	notAfter, err := time.Parse("2006-01-02 15:04:05 -0700 MST", parts[1])
+	if err != nil {
+		return nil
+	}
+	cert := &ServiceCert{
+		Issuer:    parts[0],
+		NotAfter:  notAfter,
+		DaysLeft:  int(notAfter.Sub(now).Hours() / 24),
+		CheckedAt: now,
+	}
+	if len(parts) > 2 {
+		cert.Flag = parts[2]
+	}
+	return cert
+}
+
 // CronSpec 返回服务监控请求间隔对应的 cron 表达式
 func (m *Service) CronSpec() string {
 	if m.Duration == 0 {
@@ -99,6 +189,11 @@ func (m *Service) BeforeSave(tx *gorm.DB) error {
 	} else {
 		m.FailTriggerTasksRaw = string(data)
 	}
+	if data, err := utils.Json.Marshal(m.Headers); err != nil {
+		return err
+	} else {
+		m.HeadersRaw = string(data)
+	}
 	if data, err := utils.Json.Marshal(m.RecoverTriggerTasks); err != nil {
 		return err
 	} else {
@@ -121,11 +216,77 @@ func (m *Service) AfterFind(tx *gorm.DB) error {
 	if err := utils.Json.Unmarshal([]byte(m.RecoverTriggerTasksRaw), &m.RecoverTriggerTasks); err != nil {
 		return err
 	}
+	if m.HeadersRaw != "" {
+		if err := utils.Json.Unmarshal([]byte(m.HeadersRaw), &m.Headers); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
 // IsServiceSentinelNeeded 判断该任务类型是否需要进行服务监控 需要则返回true
 func IsServiceSentinelNeeded(t uint64) bool {
-	return t != TaskTypeCommand && t != TaskTypeTerminalGRPC && t != TaskTypeUpgrade && t != TaskTypeKeepalive
+	return t != TaskTypeCommand && t != TaskTypeCommandWithEnv && t != TaskTypeCommandOutput && t != TaskTypeCommandAck && t != TaskTypeTerminalGRPC && t != TaskTypeUpgrade && t != TaskTypeKeepalive
+}
+
+// RecordsReporterLatency 判断该任务类型是否按监测点记录延迟，供延迟图表使用，面板探测的监测点 ID 为 0
+func RecordsReporterLatency(t uint64) bool {
+	return t == TaskTypeTCPPing || t == TaskTypeICMPPing || t == TaskTypeGRPCHealth || t == TaskTypeDNS
+}
+
+// ProbedByDashboard 判断该服务监控是否由面板直接探测，而不是下发给 Agent
+// Agent 不支持响应体断言与自定义请求，因此设置了关键字、请求头或认证的 HTTP 监控也由面板执行
+func (m *Service) ProbedByDashboard() bool {
+	switch m.Type {
+	case TaskTypeGRPCHealth, TaskTypeDNS, TaskTypeTLSCert:
+		return true
+	case TaskTypeHTTPGet:
+		return m.Keyword != "" || len(m.Headers) > 0 || m.AuthType != ""
+	}
+	return false
+}
+
+// IsSecretHeader 判断请求头是否可能携带凭据
+func IsSecretHeader(name string) bool {
+	name = strings.ToLower(name)
+	switch name {
+	case "authorization", "proxy-authorization", "cookie":
+		return true
+	}
+	for _, s := range []string{"token", "key", "secret", "password", "auth", "session"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactSecrets 隐去可能携带凭据的请求头、认证密钥与徽章令牌，用于返回给前端。
+// 徽章令牌只在生成时返回一次，隐去后的占位符表示已开放徽章
+func (m *Service) RedactSecrets() {
+	if len(m.Headers) > 0 {
+		headers := make(map[string]string, len(m.Headers))
+		for k, v := range m.Headers {
+			headers[k] = utils.IfOr(IsSecretHeader(k), redact(v), v)
+		}
+		m.Headers = headers
+	}
+	m.AuthSecret = redact(m.AuthSecret)
+	m.BadgeToken = redact(m.BadgeToken)
+}
+
+// RestoreSecrets 提交的值仍为隐去后的占位符时沿用 old 中的原值
+func (m *Service) RestoreSecrets(old *Service) {
+	for k, v := range m.Headers {
+		if v == RedactedValue {
+			m.Headers[k] = old.Headers[k]
+		}
+	}
+	if m.AuthSecret == RedactedValue {
+		m.AuthSecret = old.AuthSecret
+	}
+	if m.BadgeToken == RedactedValue {
+		m.BadgeToken = old.BadgeToken
+	}
 }
@@ -1,23 +1,90 @@
 package model
 
-import "time"
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
 
 type ServiceForm struct {
-	Name                string          `json:"name,omitempty" minLength:"1"`
-	Target              string          `json:"target,omitempty"`
-	Type                uint8           `json:"type,omitempty"`
-	Cover               uint8           `json:"cover,omitempty"`
-	Notify              bool            `json:"notify,omitempty" validate:"optional"`
-	Duration            uint64          `json:"duration,omitempty"`
-	MinLatency          float32         `json:"min_latency,omitempty" default:"0.0"`
-	MaxLatency          float32         `json:"max_latency,omitempty" default:"0.0"`
-	LatencyNotify       bool            `json:"latency_notify,omitempty" validate:"optional"`
-	EnableTriggerTask   bool            `json:"enable_trigger_task,omitempty" validate:"optional"`
-	EnableShowInService bool            `json:"enable_show_in_service,omitempty" validate:"optional"`
-	FailTriggerTasks    []uint64        `json:"fail_trigger_tasks,omitempty"`
-	RecoverTriggerTasks []uint64        `json:"recover_trigger_tasks,omitempty"`
-	SkipServers         map[uint64]bool `json:"skip_servers,omitempty"`
-	NotificationGroupID uint64          `json:"notification_group_id,omitempty"`
+	Name                string            `json:"name,omitempty" minLength:"1"`
+	Target              string            `json:"target,omitempty"`
+	Type                uint8             `json:"type,omitempty"`
+	Cover               uint8             `json:"cover,omitempty"`
+	Notify              bool              `json:"notify,omitempty" validate:"optional"`
+	Duration            uint64            `json:"duration,omitempty"`
+	MinLatency          float32           `json:"min_latency,omitempty" default:"0.0"`
+	MaxLatency          float32           `json:"max_latency,omitempty" default:"0.0"`
+	LatencyNotify       bool              `json:"latency_notify,omitempty" validate:"optional"`
+	CertExpireDays      uint64            `json:"cert_expire_days,omitempty" validate:"optional"`
+	Keyword             string            `json:"keyword,omitempty" validate:"optional"`
+	KeywordRegex        bool              `json:"keyword_regex,omitempty" validate:"optional"`
+	KeywordInvert       bool              `json:"keyword_invert,omitempty" validate:"optional"`
+	KeywordIgnoreCase   bool              `json:"keyword_ignore_case,omitempty" validate:"optional"`
+	Headers             map[string]string `json:"headers,omitempty" validate:"optional"`
+	AuthType            string            `json:"auth_type,omitempty" validate:"optional"` // basic、bearer
+	AuthUsername        string            `json:"auth_username,omitempty" validate:"optional"`
+	AuthSecret          string            `json:"auth_secret,omitempty" validate:"optional"`
+	EnableTriggerTask   bool              `json:"enable_trigger_task,omitempty" validate:"optional"`
+	EnableShowInService bool              `json:"enable_show_in_service,omitempty" validate:"optional"`
+	FailTriggerTasks    []uint64          `json:"fail_trigger_tasks,omitempty"`
+	RecoverTriggerTasks []uint64          `json:"recover_trigger_tasks,omitempty"`
+	SkipServers         map[uint64]bool   `json:"skip_servers,omitempty"`
+	NotificationGroupID uint64            `json:"notification_group_id,omitempty"`
+}
+
+const (
+	// ServiceBatchTargetPlaceholder 批量创建时模板名称与目标中替换为各个目标的占位符
+	ServiceBatchTargetPlaceholder = "{{target}}"
+	// MaxServiceBatchTargets 单次批量创建的目标数上限
+	MaxServiceBatchTargets = 500
+)
+
+// ServiceBatchForm 按模板为多个目标批量创建服务监控
+type ServiceBatchForm struct {
+	Template ServiceForm `json:"template"`
+	Targets  []string    `json:"targets" minItems:"1"`
+	DryRun   bool        `json:"dry_run,omitempty" validate:"optional"` // 只返回将要创建的监控
+}
+
+// Expand 将 target 代入模板，模板名称不含占位符时在名称后追加目标，模板目标不含占位符时直接使用 target
+func (f *ServiceBatchForm) Expand(target string) ServiceForm {
+	mf := f.Template
+	mf.Headers = maps.Clone(f.Template.Headers)
+	mf.SkipServers = maps.Clone(f.Template.SkipServers)
+	mf.FailTriggerTasks = slices.Clone(f.Template.FailTriggerTasks)
+	mf.RecoverTriggerTasks = slices.Clone(f.Template.RecoverTriggerTasks)
+
+	if strings.Contains(mf.Name, ServiceBatchTargetPlaceholder) {
+		mf.Name = strings.ReplaceAll(mf.Name, ServiceBatchTargetPlaceholder, target)
+	} else {
+		mf.Name = strings.TrimSpace(mf.Name + " " + target)
+	}
+	if strings.Contains(mf.Target, ServiceBatchTargetPlaceholder) {
+		mf.Target = strings.ReplaceAll(mf.Target, ServiceBatchTargetPlaceholder, target)
+	} else {
+		mf.Target = target
+	}
+	return mf
+}
+
+type ServiceBatchItem struct {
+	ID     uint64 `json:"id,omitempty"` // 预览时为空
+	Target string `json:"target"`
+	Name   string `json:"name"`
+	URL    string `json:"url"` // 代入模板后的监控目标
+}
+
+type ServiceBatchFailure struct {
+	Target string `json:"target"`
+	Error  string `json:"error"`
+}
+
+type ServiceBatchResult struct {
+	DryRun  bool                  `json:"dry_run,omitempty"`
+	Created []ServiceBatchItem    `json:"created"`
+	Failed  []ServiceBatchFailure `json:"failed,omitempty"`
 }
 
 type ServiceResponseItem struct {
@@ -29,6 +96,17 @@ type ServiceResponseItem struct {
 	Delay       *[30]float32 `json:"delay,omitempty"`
 	Up          *[30]int     `json:"up,omitempty"`
 	Down        *[30]int     `json:"down,omitempty"`
+	Cert        *ServiceCert `json:"cert,omitempty"`
+}
+
+// ServiceCert 服务监控最近一次获取到的 TLS 证书
+type ServiceCert struct {
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+	DaysLeft int       `json:"days_left"`
+	// 证书链校验标记，如 self_signed、incomplete_chain，为空表示可信或未校验
+	Flag      string    `json:"flag,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
 }
 
 func (r ServiceResponseItem) TotalUptime() float32 {
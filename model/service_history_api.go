@@ -1,5 +1,12 @@
 package model
 
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type ServiceInfos struct {
 	ServiceID   uint64    `json:"monitor_id"`
 	ServerID    uint64    `json:"server_id"`
@@ -7,4 +14,115 @@ type ServiceInfos struct {
 	ServerName  string    `json:"server_name"`
 	CreatedAt   []int64   `json:"created_at"`
 	AvgDelay    []float32 `json:"avg_delay"`
+	// 按区间聚合时每个区间的最小与最大延迟，CreatedAt 为区间起点
+	MinDelay []float32 `json:"min_delay,omitempty" validate:"optional"`
+	MaxDelay []float32 `json:"max_delay,omitempty" validate:"optional"`
+	// 查询时间范围内全部数据点的延迟分位数，没有测得延迟时为空
+	Latency *LatencyPercentiles `json:"latency,omitempty" validate:"optional"`
+}
+
+const day = 24 * time.Hour
+
+// ParseHistoryInterval 解析聚合区间，支持 time.ParseDuration 的格式与按天的 Nd，最小为 1 分钟
+func ParseHistoryInterval(s string) (time.Duration, error) {
+	var interval time.Duration
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, err
+		}
+		interval = time.Duration(days) * day
+	} else {
+		var err error
+		if interval, err = time.ParseDuration(s); err != nil {
+			return 0, err
+		}
+	}
+	if interval < time.Minute {
+		return 0, errors.New("interval must be at least 1m")
+	}
+	return interval, nil
+}
+
+// HistoryBucketStart 返回 t 所在聚合区间的起点，区间按 loc 的本地时间对齐。
+// 整天的区间对齐到当地零点，夏令时切换当天的区间为 23 或 25 小时。
+func HistoryBucketStart(t time.Time, interval time.Duration, loc *time.Location) time.Time {
+	t = t.In(loc)
+	if interval%day == 0 {
+		y, m, d := t.Date()
+		days := int64(interval / day)
+		n := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / int64(day/time.Second)
+		n -= n % days
+		y, m, d = time.Unix(n*int64(day/time.Second), 0).UTC().Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+
+	_, offset := t.Zone()
+	secs := int64(interval / time.Second)
+	local := t.Unix() + int64(offset)
+	return time.Unix(local-local%secs-int64(offset), 0).In(loc)
+}
+
+// HistoryAggregate 聚合区间内数据点的平均、最小与最大值
+type HistoryAggregate struct {
+	Count int     `json:"count"`
+	Avg   float64 `json:"avg"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+func (a *HistoryAggregate) Add(v float64) {
+	if a.Count == 0 {
+		a.Min, a.Max = v, v
+	}
+	a.Count++
+	a.Avg += (v - a.Avg) / float64(a.Count)
+	a.Min = min(a.Min, v)
+	a.Max = max(a.Max, v)
+}
+
+// ServiceHistoryExportItem 服务监控历史导出的一行记录
+type ServiceHistoryExportItem struct {
+	Timestamp int64   `json:"timestamp"`
+	ServerID  uint64  `json:"server_id"` // 为 0 时表示所有监测点的汇总记录
+	Latency   float32 `json:"latency"`   // 平均延迟，毫秒
+	Up        uint64  `json:"up"`
+	Down      uint64  `json:"down"`
+	Status    string  `json:"status,omitempty"` // up、down、degraded，单个监测点的延迟记录不含状态
+	Data      string  `json:"data,omitempty"`   // 最近一次的监测结果，单个监测点的延迟记录中有失败时为失败原因
+}
+
+var ServiceHistoryExportHeader = []string{"timestamp", "server_id", "latency", "up", "down", "status", "data"}
+
+func NewServiceHistoryExportItem(h *ServiceHistory) ServiceHistoryExportItem {
+	item := ServiceHistoryExportItem{
+		Timestamp: h.CreatedAt.Unix(),
+		ServerID:  h.ServerID,
+		Latency:   h.AvgDelay,
+		Up:        h.Up,
+		Down:      h.Down,
+		Data:      h.Data,
+	}
+	switch {
+	case h.Up+h.Down == 0:
+	case h.Down == 0:
+		item.Status = "up"
+	case h.Up == 0:
+		item.Status = "down"
+	default:
+		item.Status = "degraded"
+	}
+	return item
+}
+
+func (i ServiceHistoryExportItem) CSVRecord() []string {
+	return []string{
+		strconv.FormatInt(i.Timestamp, 10),
+		strconv.FormatUint(i.ServerID, 10),
+		strconv.FormatFloat(float64(i.Latency), 'f', 2, 32),
+		strconv.FormatUint(i.Up, 10),
+		strconv.FormatUint(i.Down, 10),
+		i.Status,
+		i.Data,
+	}
 }
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryBucketStart(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	cases := []struct {
+		at       time.Time
+		interval time.Duration
+		loc      *time.Location
+		want     time.Time
+	}{
+		{time.Date(2024, 1, 1, 10, 47, 0, 0, time.UTC), time.Hour, time.UTC, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		// 半小时时区按本地整点对齐
+		{time.Date(2024, 1, 1, 10, 47, 0, 0, kolkata), time.Hour, kolkata, time.Date(2024, 1, 1, 10, 0, 0, 0, kolkata)},
+		// 夏令时开始当天只有 23 小时，区间仍从当地零点开始
+		{time.Date(2024, 3, 10, 23, 30, 0, 0, ny), day, ny, time.Date(2024, 3, 10, 0, 0, 0, 0, ny)},
+		{time.Date(2024, 3, 10, 1, 30, 0, 0, ny), day, ny, time.Date(2024, 3, 10, 0, 0, 0, 0, ny)},
+		// 夏令时开始后按新的偏移对齐到本地整点
+		{time.Date(2024, 3, 10, 3, 30, 0, 0, ny), time.Hour, ny, time.Date(2024, 3, 10, 3, 0, 0, 0, ny)},
+		{time.Date(2024, 11, 3, 23, 59, 0, 0, ny), day, ny, time.Date(2024, 11, 3, 0, 0, 0, 0, ny)},
+	}
+
+	for _, c := range cases {
+		if got := HistoryBucketStart(c.at, c.interval, c.loc); !got.Equal(c.want) {
+			t.Errorf("HistoryBucketStart(%v, %v) = %v, want %v", c.at, c.interval, got, c.want)
+		}
+	}
+}
+
+func TestParseHistoryInterval(t *testing.T) {
+	for s, want := range map[string]time.Duration{"1h": time.Hour, "30m": 30 * time.Minute, "7d": 7 * day} {
+		if got, err := ParseHistoryInterval(s); err != nil || got != want {
+			t.Errorf("ParseHistoryInterval(%q) = %v, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"", "10s", "xd", "-1h"} {
+		if _, err := ParseHistoryInterval(s); err == nil {
+			t.Errorf("expected %q to fail", s)
+		}
+	}
+}
+
+func TestCompareMetricSeries(t *testing.T) {
+	aFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bFrom := aFrom.Add(7 * day)
+	duration, truncated, err := MetricCompareRange(aFrom, aFrom.Add(3*time.Hour), bFrom, bFrom.Add(2*time.Hour))
+	if err != nil || duration != 2*time.Hour || !truncated {
+		t.Fatalf("unexpected range: %v %v %v", duration, truncated, err)
+	}
+
+	a := []MetricPoint{{aFrom, 1}, {aFrom.Add(30 * time.Minute), 3}, {aFrom.Add(150 * time.Minute), 9}}
+	b := []MetricPoint{{bFrom.Add(90 * time.Minute), 5}}
+	buckets, err := CompareMetricSeries(a, b, aFrom, bFrom, duration, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 2 || buckets[1].Offset != 3600 {
+		t.Fatalf("unexpected buckets: %+v", buckets)
+	}
+	if got := buckets[0].A; got == nil || got.Avg != 2 || got.Min != 1 || got.Max != 3 || buckets[0].B != nil {
+		t.Fatalf("unexpected first bucket: %+v", buckets[0])
+	}
+	if buckets[1].A != nil || buckets[1].B == nil || buckets[1].B.Avg != 5 {
+		t.Fatalf("points beyond the truncated range should be dropped: %+v", buckets[1])
+	}
+
+	if _, err := CompareMetricSeries(nil, nil, aFrom, bFrom, duration, time.Second); err == nil {
+		t.Fatal("expected too many buckets error")
+	}
+}
@@ -0,0 +1,46 @@
+package model
+
+import "testing"
+
+func TestServiceRedactSecrets(t *testing.T) {
+	headers := map[string]string{"X-Api-Key": "k", "User-Agent": "probe"}
+	s := &Service{Headers: headers, AuthType: ServiceAuthBearer, AuthSecret: "token", BadgeToken: "badge"}
+	s.RedactSecrets()
+	if s.Headers["X-Api-Key"] != RedactedValue || s.Headers["User-Agent"] != "probe" || s.AuthSecret != RedactedValue || s.BadgeToken != RedactedValue {
+		t.Fatalf("unexpected redacted service: %+v", s)
+	}
+	if headers["X-Api-Key"] != "k" {
+		t.Fatal("redacting must not modify the original headers")
+	}
+
+	old := &Service{Headers: headers, AuthSecret: "token", BadgeToken: "badge"}
+	s.Headers["Cookie"] = "new"
+	s.RestoreSecrets(old)
+	if s.Headers["X-Api-Key"] != "k" || s.Headers["Cookie"] != "new" || s.AuthSecret != "token" || s.BadgeToken != "badge" {
+		t.Fatalf("unexpected restored service: %+v", s)
+	}
+}
+
+func TestServiceBatchFormExpand(t *testing.T) {
+	f := &ServiceBatchForm{Template: ServiceForm{
+		Name:        "API {{target}}",
+		Target:      "https://{{target}}/health",
+		Headers:     map[string]string{"X-Env": "prod"},
+		SkipServers: map[uint64]bool{1: true},
+	}}
+	mf := f.Expand("a.example.com")
+	if mf.Name != "API a.example.com" || mf.Target != "https://a.example.com/health" {
+		t.Fatalf("unexpected expansion: %q %q", mf.Name, mf.Target)
+	}
+	mf.Headers["X-Env"] = "dev"
+	mf.SkipServers[2] = true
+	if f.Template.Headers["X-Env"] != "prod" || len(f.Template.SkipServers) != 1 {
+		t.Fatal("expanded form should not share maps with the template")
+	}
+
+	f.Template.Name, f.Template.Target = "ping", ""
+	mf = f.Expand("10.0.0.1")
+	if mf.Name != "ping 10.0.0.1" || mf.Target != "10.0.0.1" {
+		t.Fatalf("unexpected expansion without placeholder: %q %q", mf.Name, mf.Target)
+	}
+}
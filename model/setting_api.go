@@ -10,9 +10,46 @@ type SettingForm struct {
 	InstallHost                 string `json:"install_host,omitempty" validate:"optional"`
 	CustomCode                  string `json:"custom_code,omitempty" validate:"optional"`
 	CustomCodeDashboard         string `json:"custom_code_dashboard,omitempty" validate:"optional"`
-	RealIPHeader                string `json:"real_ip_header,omitempty" validate:"optional"` // 真实IP
+	RealIPHeader                string `json:"real_ip_header,omitempty" validate:"optional"`  // 真实IP
+	TrustedProxies              string `json:"trusted_proxies,omitempty" validate:"optional"` // 可信代理，逗号分隔的 IP 或 CIDR
 	UserTemplate                string `json:"user_template,omitempty" validate:"optional"`
 
+	LoginLockoutThreshold int `json:"login_lockout_threshold,omitempty" validate:"optional"`
+	LoginLockoutWindow    int `json:"login_lockout_window,omitempty" validate:"optional"` // 秒
+
+	SessionIdleTimeout *int `json:"session_idle_timeout,omitempty" validate:"optional"` // 秒，0 表示不限制
+
+	CronOutputLimit      int `json:"cron_output_limit,omitempty" validate:"optional"`      // 字节
+	CronHistoryRetention int `json:"cron_history_retention,omitempty" validate:"optional"` // 天
+	CommandAckTimeout    int `json:"command_ack_timeout,omitempty" validate:"optional"`    // 秒
+	ServerTrashRetention int `json:"server_trash_retention,omitempty" validate:"optional"` // 天
+	ServerOfflineTimeout int `json:"server_offline_timeout,omitempty" validate:"optional"` // 秒
+
+	NotificationDedupWindow int `json:"notification_dedup_window,omitempty" validate:"optional"` // 秒，负数表示不合并
+	ServerListCacheTTL      int `json:"server_list_cache_ttl,omitempty" validate:"optional"`     // 秒，负数表示不缓存
+
+	MinAgentVersion                  string `json:"min_agent_version,omitempty" validate:"optional"`                    // 为空时不检查
+	OutdatedAgentNotificationGroupID uint64 `json:"outdated_agent_notification_group_id,omitempty" validate:"optional"` // Agent 版本过低提醒的通知组
+	NewServerNotificationGroupID     uint64 `json:"new_server_notification_group_id,omitempty" validate:"optional"`     // 新服务器加入提醒的通知组，为 0 时不提醒
+
+	FMMaxFileSize int64 `json:"fm_max_file_size,omitempty" validate:"optional"` // 字节
+
+	TerminalRecordingLimit     int `json:"terminal_recording_limit,omitempty" validate:"optional"`     // 字节
+	TerminalRecordingRetention int `json:"terminal_recording_retention,omitempty" validate:"optional"` // 天
+
+	ServiceHistoryRetention       int  `json:"service_history_retention,omitempty" validate:"optional"`        // 天
+	ServiceHistoryDetailRetention int  `json:"service_history_detail_retention,omitempty" validate:"optional"` // 天
+	TransferRetention             *int `json:"transfer_retention,omitempty" validate:"optional"`               // 天，0 表示仅保留报警规则所需
+
+	MaxCustomMetrics      int `json:"max_custom_metrics,omitempty" validate:"optional"`      // 每台服务器
+	CustomMetricRetention int `json:"custom_metric_retention,omitempty" validate:"optional"` // 天
+
+	PasswordPolicy *PasswordPolicy `json:"password_policy,omitempty" validate:"optional"`
+	CORS           *CORS           `json:"cors,omitempty" validate:"optional"`
+
+	GeoIPCityDatabase string `json:"geoip_city_database,omitempty" validate:"optional"` // mmdb 文件路径
+	GeoIPASNDatabase  string `json:"geoip_asn_database,omitempty" validate:"optional"`  // mmdb 文件路径
+
 	TLS                         bool `json:"tls,omitempty" validate:"optional"`
 	EnableIPChangeNotification  bool `json:"enable_ip_change_notification,omitempty" validate:"optional"`
 	EnablePlainIPInNotification bool `json:"enable_plain_ip_in_notification,omitempty" validate:"optional"`
@@ -32,5 +69,17 @@ type SettingResponse struct {
 	Config
 
 	Version           string             `json:"version,omitempty"`
+	OIDCLogin         bool               `json:"oidc_login,omitempty"` // 是否可使用 OIDC 单点登录
 	FrontendTemplates []FrontendTemplate `json:"frontend_templates,omitempty"`
 }
+
+type NotificationMuteForm struct {
+	Enabled  bool   `json:"enabled"`
+	Duration uint64 `json:"duration,omitempty" validate:"optional"` // 秒，到期自动解除，为 0 表示需手动解除
+	Reason   string `json:"reason,omitempty" validate:"optional"`
+}
+
+type NotificationMuteResponse struct {
+	NotificationMute
+	Active bool `json:"active"` // 当前是否生效，到期后 Enabled 仍为 true 但不再生效
+}
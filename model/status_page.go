@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+const (
+	IncidentStatusInvestigating = "investigating"
+	IncidentStatusIdentified    = "identified"
+	IncidentStatusMonitoring    = "monitoring"
+	IncidentStatusResolved      = "resolved"
+)
+
+// ValidIncidentStatus 判断是否为支持的事件状态
+func ValidIncidentStatus(s string) bool {
+	switch s {
+	case IncidentStatusInvestigating, IncidentStatusIdentified, IncidentStatusMonitoring, IncidentStatusResolved:
+		return true
+	}
+	return false
+}
+
+// StatusPage 无需登录即可访问的状态页，只展示选中的服务器与服务监控
+type StatusPage struct {
+	Enabled     bool     `mapstructure:"enabled" json:"enabled"`
+	Title       string   `mapstructure:"title" json:"title,omitempty"`
+	Description string   `mapstructure:"description" json:"description,omitempty"`
+	Servers     []uint64 `mapstructure:"servers" json:"servers"`
+	Services    []uint64 `mapstructure:"services" json:"services"`
+}
+
+// StatusIncident 发布在状态页上的事件，每次进展记录为一条 StatusIncidentUpdate
+type StatusIncident struct {
+	Common
+	Title      string     `json:"title"`
+	Status     string     `json:"status"` // 最新一条进展的状态
+	ResolvedAt *time.Time `gorm:"index" json:"resolved_at,omitempty"`
+
+	Updates []*StatusIncidentUpdate `gorm:"-" json:"updates,omitempty"` // 按时间倒序
+}
+
+type StatusIncidentUpdate struct {
+	ID         uint64    `gorm:"primaryKey" json:"id,omitempty"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at,omitempty"`
+	IncidentID uint64    `gorm:"index" json:"incident_id,omitempty"`
+	UserID     uint64    `json:"-"`
+	Status     string    `json:"status"`
+	Message    string    `gorm:"type:text" json:"message"`
+}
@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+type StatusPageForm struct {
+	Enabled     bool     `json:"enabled,omitempty" validate:"optional"`
+	Title       string   `json:"title,omitempty" validate:"optional"`
+	Description string   `json:"description,omitempty" validate:"optional"`
+	Servers     []uint64 `json:"servers,omitempty" validate:"optional"`
+	Services    []uint64 `json:"services,omitempty" validate:"optional"`
+}
+
+type StatusIncidentForm struct {
+	Title   string `json:"title,omitempty" minLength:"1"`
+	Status  string `json:"status,omitempty"` // investigating、identified、monitoring、resolved
+	Message string `json:"message,omitempty" minLength:"1"`
+}
+
+type StatusIncidentUpdateForm struct {
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty" minLength:"1"`
+}
+
+const (
+	StatusPageOperational = "operational"
+	StatusPageDegraded    = "degraded"
+	StatusPageOutage      = "outage"
+)
+
+const (
+	ComponentUp       = "up"
+	ComponentDegraded = "degraded"
+	ComponentDown     = "down"
+	ComponentNoData   = "no_data"
+)
+
+// StatusPageServer 状态页中的服务器，不包含地址等主机信息
+type StatusPageServer struct {
+	ID            uint64    `json:"id"`
+	Name          string    `json:"name"`
+	Status        string    `json:"status"` // up、down
+	InMaintenance bool      `json:"in_maintenance,omitempty"`
+	LastActive    time.Time `json:"last_active"`
+}
+
+// StatusPageService 状态页中的服务监控，不包含监控目标
+type StatusPageService struct {
+	ID     uint64  `json:"id"`
+	Name   string  `json:"name"`
+	Status string  `json:"status"` // up、degraded、down、no_data
+	Uptime float32 `json:"uptime"` // 30 天在线率
+}
+
+type StatusPageResponse struct {
+	Title       string               `json:"title"`
+	Description string               `json:"description,omitempty"`
+	Status      string               `json:"status"` // operational、degraded、outage
+	Servers     []*StatusPageServer  `json:"servers"`
+	Services    []*StatusPageService `json:"services"`
+	Incidents   []*StatusIncident    `json:"incidents"` // 未解决与最近解决的事件
+	GeneratedAt time.Time            `json:"generated_at"`
+}
@@ -0,0 +1,21 @@
+package model
+
+// Tenant 租户（工作区），服务器、报警规则等数据按所属用户的租户隔离。
+// 不属于任何租户（TenantID 为 0）的管理员为超级管理员，可以管理全部租户
+type Tenant struct {
+	Common
+	Name string `json:"name" gorm:"uniqueIndex"`
+}
+
+// 租户内的用户（包括租户管理员）不能修改影响全部租户的设置与 WAF
+const TenantPermissions = PermissionAll &^ (PermissionSetting | PermissionWAF)
+
+// TenantScope 当前用户所在租户的全部用户，由中间件根据登录用户注入，超级管理员没有范围限制
+type TenantScope struct {
+	TenantID uint64
+	Users    map[uint64]bool
+}
+
+func (s *TenantScope) Contains(uid uint64) bool {
+	return s == nil || s.Users[uid]
+}
@@ -0,0 +1,11 @@
+package model
+
+type TenantForm struct {
+	Name string `json:"name,omitempty" minLength:"1"`
+}
+
+type UserTenantForm struct {
+	TenantID uint64 `json:"tenant_id,omitempty" validate:"optional"` // 为 0 时移出租户
+	// 设为该租户的管理员，移出租户时不能为管理员，以免成为超级管理员
+	Admin bool `json:"admin,omitempty" validate:"optional"`
+}
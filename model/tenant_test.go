@@ -0,0 +1,49 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCommonHasPermissionTenant(t *testing.T) {
+	owned := &Common{UserID: 2}
+	cases := []struct {
+		name  string
+		user  User
+		scope *TenantScope
+		want  bool
+	}{
+		{"super admin", User{Common: Common{ID: 1}, Role: RoleAdmin}, nil, true},
+		{"tenant admin", User{Common: Common{ID: 3}, Role: RoleAdmin, TenantID: 1}, &TenantScope{TenantID: 1, Users: map[uint64]bool{2: true, 3: true}}, true},
+		{"other tenant admin", User{Common: Common{ID: 4}, Role: RoleAdmin, TenantID: 2}, &TenantScope{TenantID: 2, Users: map[uint64]bool{4: true}}, false},
+		{"tenant admin without scope", User{Common: Common{ID: 3}, Role: RoleAdmin, TenantID: 1}, nil, false},
+		{"owner", User{Common: Common{ID: 2}, Role: RoleMember, TenantID: 1}, &TenantScope{TenantID: 1, Users: map[uint64]bool{2: true}}, true},
+		{"member", User{Common: Common{ID: 5}, Role: RoleMember, TenantID: 1}, &TenantScope{TenantID: 1, Users: map[uint64]bool{2: true, 5: true}}, false},
+	}
+	for _, c := range cases {
+		ctx := &gin.Context{}
+		ctx.Set(CtxKeyAuthorizedUser, &c.user)
+		if c.scope != nil {
+			ctx.Set(CtxKeyTenantScope, c.scope)
+		}
+		if got := owned.HasPermission(ctx); got != c.want {
+			t.Errorf("%s: HasPermission = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestTenantAdminPermissions(t *testing.T) {
+	admin := User{Role: RoleAdmin}
+	if admin.EffectivePermissions() != PermissionAll || !admin.IsSuperAdmin() {
+		t.Fatal("admin without tenant should be a super admin")
+	}
+	admin.TenantID = 1
+	if admin.IsSuperAdmin() || admin.Can(PermissionSetting) || admin.Can(PermissionWAF) || !admin.Can(PermissionUser) {
+		t.Fatalf("unexpected tenant admin permissions %b", admin.EffectivePermissions())
+	}
+	member := User{Role: RoleMember, TenantID: 1, Permissions: PermissionAll}
+	if member.Can(PermissionSetting) || !member.Can(PermissionCron) {
+		t.Fatalf("unexpected tenant member permissions %b", member.EffectivePermissions())
+	}
+}
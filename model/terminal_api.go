@@ -3,6 +3,10 @@ package model
 type TerminalForm struct {
 	Protocol string `json:"protocol,omitempty"`
 	ServerID uint64 `json:"server_id,omitempty"`
+	// 以环境变量提供给会话的服务器密钥
+	SecretIDs []uint64 `json:"secret_ids,omitempty" validate:"optional"`
+	// 不录制本次会话，用于输入敏感信息的操作
+	NoRecord bool `json:"no_record,omitempty" validate:"optional"`
 }
 
 type CreateTerminalResponse struct {
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// TerminalSession 网页终端会话，记录发起人、目标服务器与起止时间
+type TerminalSession struct {
+	ID         uint64     `gorm:"primaryKey" json:"id,omitempty"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at,omitempty"`
+	UserID     uint64     `gorm:"index" json:"user_id,omitempty"` // 发起人
+	SessionID  string     `json:"session_id" gorm:"uniqueIndex"`
+	ServerID   uint64     `json:"server_id" gorm:"index"`
+	ServerName string     `json:"server_name,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	Recorded   bool       `json:"recorded"` // 发起时选择不录制的会话只保留会话信息
+	StartedAt  time.Time  `json:"started_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	Size       int64      `json:"size,omitempty"`      // 录制保存的字节数
+	Truncated  bool       `json:"truncated,omitempty"` // 超过大小限制后不再录制
+	Width      uint32     `json:"width,omitempty"`     // 会话开始时的窗口大小
+	Height     uint32     `json:"height,omitempty"`
+}
+
+// TerminalRecordingChunk 终端录制的一段内容，每行一个 asciicast v2 事件，按 ID 顺序拼接
+type TerminalRecordingChunk struct {
+	ID        uint64 `gorm:"primaryKey"`
+	SessionID uint64 `gorm:"index"` // TerminalSession.ID
+	Data      string `gorm:"type:longtext"`
+}
+
+// TerminalWindowSize 终端窗口大小，与 Agent 接收的调整窗口消息一致
+type TerminalWindowSize struct {
+	Cols uint32
+	Rows uint32
+}
+
+// 用户发往终端的消息类型，由首个字节区分
+const (
+	TerminalMsgInput  = 0
+	TerminalMsgResize = 1
+)
+
+// CastHeader 生成 asciicast v2 的首行
+func CastHeader(s *TerminalSession) []byte {
+	width, height := s.Width, s.Height
+	if width == 0 || height == 0 {
+		width, height = 80, 24
+	}
+	b, _ := utils.Json.Marshal(map[string]any{
+		"version":   2,
+		"width":     width,
+		"height":    height,
+		"timestamp": s.StartedAt.Unix(),
+		"title":     s.ServerName,
+	})
+	return append(b, '\n')
+}
+
+// CastEvent 生成一行 asciicast v2 事件，elapsed 为距会话开始的时长
+func CastEvent(elapsed time.Duration, kind, data string) []byte {
+	b := []byte{'['}
+	b = strconv.AppendFloat(b, elapsed.Seconds(), 'f', 6, 64)
+	b = append(b, ',')
+	b = strconv.AppendQuote(b, kind)
+	b = append(b, ',')
+	d, _ := utils.Json.Marshal(data)
+	b = append(b, d...)
+	return append(b, ']', '\n')
+}
+
+// SplitIncompleteUTF8 分离末尾不完整的 UTF-8 字符，留待与下一段输出拼接
+func SplitIncompleteUTF8(p []byte) (complete, rest []byte) {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(p); i++ {
+		c := p[len(p)-i]
+		if !utf8.RuneStart(c) {
+			continue
+		}
+		if !utf8.FullRune(p[len(p)-i:]) {
+			return p[:len(p)-i], p[len(p)-i:]
+		}
+		break
+	}
+	return p, nil
+}
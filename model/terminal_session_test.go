@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCastEvent(t *testing.T) {
+	got := string(CastEvent(1500*time.Millisecond, "o", "ls\r\n\x1b[0m\"é\""))
+	want := "[1.500000,\"o\",\"ls\\r\\n\\u001b[0m\\\"é\\\"\"]\n"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestSplitIncompleteUTF8(t *testing.T) {
+	cases := []struct {
+		in       string
+		complete string
+		rest     string
+	}{
+		{"abc", "abc", ""},
+		{"", "", ""},
+		{"a中", "a中", ""},
+		{"a中"[:2], "a", "\xe4"},
+		{"a中"[:3], "a", "\xe4\xb8"},
+		{"\x80", "\x80", ""},
+	}
+	for _, c := range cases {
+		complete, rest := SplitIncompleteUTF8([]byte(c.in))
+		if string(complete) != c.complete || string(rest) != c.rest {
+			t.Errorf("%q: expected %q %q, got %q %q", c.in, c.complete, c.rest, complete, rest)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package model
+
+import "strconv"
+
+// TransferExportItem 服务器流量记录导出的一行记录
+type TransferExportItem struct {
+	Timestamp int64  `json:"timestamp"`
+	In        uint64 `json:"in"`
+	Out       uint64 `json:"out"`
+}
+
+var TransferExportHeader = []string{"timestamp", "in", "out"}
+
+func NewTransferExportItem(t *Transfer) TransferExportItem {
+	return TransferExportItem{
+		Timestamp: t.CreatedAt.Unix(),
+		In:        t.In,
+		Out:       t.Out,
+	}
+}
+
+func (i TransferExportItem) CSVRecord() []string {
+	return []string{
+		strconv.FormatInt(i.Timestamp, 10),
+		strconv.FormatUint(i.In, 10),
+		strconv.FormatUint(i.Out, 10),
+	}
+}
@@ -1,11 +1,16 @@
 package model
 
 import (
+	"encoding/json"
+	"slices"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/nezhahq/nezha/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/pkg/geoip"
+	"github.com/nezhahq/nezha/pkg/utils"
 )
 
 const (
@@ -13,20 +18,94 @@ const (
 	RoleMember
 )
 
+// 用户权限位，管理员始终拥有全部权限
+const (
+	PermissionServerRead uint64 = 1 << iota
+	PermissionServerWrite
+	PermissionService
+	PermissionNotification
+	PermissionAlertRule
+	PermissionCron
+	PermissionDDNS
+	PermissionNAT
+	PermissionTerminal
+	PermissionUser
+	PermissionWAF
+	PermissionSetting
+
+	PermissionAll = PermissionServerRead | PermissionServerWrite | PermissionService | PermissionNotification |
+		PermissionAlertRule | PermissionCron | PermissionDDNS | PermissionNAT | PermissionTerminal |
+		PermissionUser | PermissionWAF | PermissionSetting
+	// 与原有普通用户的权限保持一致
+	DefaultMemberPermissions = PermissionServerRead | PermissionServerWrite | PermissionService | PermissionNotification |
+		PermissionAlertRule | PermissionCron | PermissionDDNS | PermissionNAT | PermissionTerminal
+)
+
 type User struct {
 	Common
 	Username    string `json:"username,omitempty" gorm:"uniqueIndex"`
 	Password    string `json:"password,omitempty" gorm:"type:char(72)"`
 	Role        uint8  `json:"role,omitempty"`
+	TenantID    uint64 `json:"tenant_id,omitempty" gorm:"index"`
 	AgentSecret string `json:"agent_secret,omitempty" gorm:"type:char(32)"`
+	// 与 DefaultMemberPermissions 保持一致
+	Permissions uint64 `json:"permissions,omitempty" gorm:"default:511"`
+	// 递增后该用户已签发的 JWT 全部失效
+	TokenVersion uint64 `json:"-"`
+
+	// 关联的 OIDC 用户标识（sub 声明），为空表示本地用户
+	OIDCSubject string `json:"oidc_subject,omitempty" gorm:"column:oidc_subject;index"`
+
+	// 前端界面偏好（排序、主题、显示的列等），内容由前端定义
+	Settings    json.RawMessage `json:"settings,omitempty" gorm:"-" swaggertype:"object"`
+	SettingsRaw string          `json:"-"`
+
+	LastLoginAt time.Time `json:"last_login_at,omitempty"`
+	LastLoginIP string    `json:"last_login_ip,omitempty"`
+
+	TwoFactor bool `json:"two_factor,omitempty"`
+	// 未启用时为待确认的密钥
+	TwoFactorSecret           string   `json:"-"`
+	TwoFactorLastStep         uint64   `json:"-"`
+	TwoFactorRecoveryCodes    []string `json:"-" gorm:"-"`
+	TwoFactorRecoveryCodesRaw string   `json:"-"`
+
+	// 管理员以该用户身份访问时的模拟登录信息，由令牌解析得到
+	Impersonation *Impersonation `json:"-" gorm:"-"`
+	// 访问令牌所属的登录会话，与刷新令牌链的 FamilyID 相同，用于闲置超时
+	SessionID string `json:"-" gorm:"-"`
+}
+
+// Impersonation 模拟登录的发起人与到期时间
+type Impersonation struct {
+	UserID   uint64    `json:"user_id"`
+	Username string    `json:"username"`
+	ExpireAt time.Time `json:"expire_at"`
+	// 发起人签发令牌时的令牌版本，发起人的会话被撤销后模拟登录随之失效
+	TokenVersion uint64 `json:"-"`
 }
 
+// 单次模拟登录的默认与最长时间，最长不超过访问令牌的有效期
+const (
+	DefaultImpersonationDuration = 30 * time.Minute
+	MaxImpersonationDuration     = time.Hour
+)
+
 type UserInfo struct {
-	Role        uint8
-	AgentSecret string
+	Role         uint8
+	TenantID     uint64
+	AgentSecret  string
+	TokenVersion uint64
 }
 
 func (u *User) BeforeSave(tx *gorm.DB) error {
+	if data, err := utils.Json.Marshal(u.TwoFactorRecoveryCodes); err != nil {
+		return err
+	} else {
+		u.TwoFactorRecoveryCodesRaw = string(data)
+	}
+	u.SettingsRaw = string(u.Settings)
+
 	if u.AgentSecret != "" {
 		return nil
 	}
@@ -40,15 +119,112 @@ func (u *User) BeforeSave(tx *gorm.DB) error {
 	return nil
 }
 
+func (u *User) AfterFind(tx *gorm.DB) error {
+	if u.SettingsRaw != "" {
+		u.Settings = json.RawMessage(u.SettingsRaw)
+	}
+	if u.TwoFactorRecoveryCodesRaw == "" {
+		return nil
+	}
+	return utils.Json.Unmarshal([]byte(u.TwoFactorRecoveryCodesRaw), &u.TwoFactorRecoveryCodes)
+}
+
+// EffectivePermissions 返回用户实际拥有的权限
+func (u *User) EffectivePermissions() uint64 {
+	all := PermissionAll
+	if u.TenantID != 0 {
+		all = TenantPermissions
+	}
+	if u.Role == RoleAdmin {
+		return all
+	}
+	return u.Permissions & all
+}
+
+// IsSuperAdmin 不属于任何租户的管理员
+func (u *User) IsSuperAdmin() bool {
+	return u.Role == RoleAdmin && u.TenantID == 0
+}
+
+func (u *User) Can(p uint64) bool {
+	return u.EffectivePermissions()&p == p
+}
+
+// VerifyTwoFactor 校验 TOTP 验证码或恢复码，通过后状态有变化，调用方需保存用户
+func (u *User) VerifyTwoFactor(code string) bool {
+	if u.TwoFactorSecret == "" || code == "" {
+		return false
+	}
+
+	if step, ok := utils.ValidateTOTP(u.TwoFactorSecret, code, time.Now()); ok {
+		// 同一时间步内的验证码只能使用一次
+		if step <= u.TwoFactorLastStep {
+			return false
+		}
+		u.TwoFactorLastStep = step
+		return true
+	}
+
+	for i, hash := range u.TwoFactorRecoveryCodes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+			u.TwoFactorRecoveryCodes = slices.Delete(u.TwoFactorRecoveryCodes, i, i+1)
+			return true
+		}
+	}
+
+	return false
+}
+
 type Profile struct {
 	User
 	LoginIP string `json:"login_ip,omitempty"`
+	// 模拟登录时为发起模拟的管理员
+	ImpersonatedBy *Impersonation `json:"impersonated_by,omitempty"`
+	// 可访问的模块与登录后默认打开的页面，由角色配置与用户权限决定
+	Modules []string `json:"modules"`
+	Landing string   `json:"landing,omitempty"`
+}
+
+// LoginHistory 每个用户仅保留最近 MaxLoginHistory 条登录记录
+type LoginHistory struct {
+	Common
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
 }
 
+const MaxLoginHistory = 50
+
 type OnlineUser struct {
+	ConnID      string    `json:"-"`
 	UserID      uint64    `json:"user_id,omitempty"`
+	Username    string    `json:"username,omitempty"`
+	Anonymous   bool      `json:"anonymous"` // 未登录的公开页面访客
 	ConnectedAt time.Time `json:"connected_at,omitempty"`
 	IP          string    `json:"ip,omitempty"`
 
+	Geo *geoip.Location `json:"geo"` // 未配置 GeoIP 数据库或查询不到时为 null
+
 	Conn *websocket.Conn `json:"-"`
+	// 服务器实时推送的订阅条件
+	Filter *StreamServerFilter `json:"-"`
+}
+
+// OnlineUserFilter 在线用户的筛选条件，字段为 nil 时不筛选
+type OnlineUserFilter struct {
+	UserID    *uint64
+	Anonymous *bool
+	Tenant    *TenantScope // 仅本租户的用户，不含匿名访客
+}
+
+func (f *OnlineUserFilter) Match(u *OnlineUser) bool {
+	if f == nil {
+		return true
+	}
+	if f.UserID != nil && u.UserID != *f.UserID {
+		return false
+	}
+	if f.Tenant != nil && !f.Tenant.Contains(u.UserID) {
+		return false
+	}
+	return f.Anonymous == nil || u.Anonymous == *f.Anonymous
 }
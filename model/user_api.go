@@ -3,10 +3,39 @@ package model
 type UserForm struct {
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty" gorm:"type:char(72)"`
+	// 不填时使用 DefaultMemberPermissions
+	Permissions *uint64 `json:"permissions,omitempty"`
+	// 仅超级管理员可指定，其他用户创建的用户属于创建者的租户
+	TenantID *uint64 `json:"tenant_id,omitempty" validate:"optional"`
+}
+
+// 界面偏好 JSON 的大小上限
+const MaxUserSettingsSize = 16 << 10
+
+type UserPermissionForm struct {
+	Permissions uint64 `json:"permissions"`
+}
+
+type ImpersonateForm struct {
+	// 模拟登录的时长（秒），默认 30 分钟，最长 1 小时
+	Duration int `json:"duration,omitempty" validate:"optional"`
 }
 
 type ProfileForm struct {
 	OriginalPassword string `json:"original_password,omitempty"`
 	NewUsername      string `json:"new_username,omitempty"`
 	NewPassword      string `json:"new_password,omitempty"`
+	// 关闭两步验证时需要提供当前验证码
+	DisableTwoFactor bool   `json:"disable_two_factor,omitempty"`
+	TwoFactorCode    string `json:"two_factor_code,omitempty"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret string `json:"secret,omitempty"`
+	// otpauth:// URI，同时作为二维码内容
+	URI string `json:"uri,omitempty"`
+}
+
+type TwoFactorVerifyForm struct {
+	Code string `json:"code,omitempty"`
 }
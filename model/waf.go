@@ -38,12 +38,89 @@ type WAF struct {
 	BlockReason     uint8  `json:"block_reason,omitempty"`
 	BlockTimestamp  uint64 `gorm:"index" json:"block_timestamp,omitempty"`
 	Count           uint64 `json:"count,omitempty"`
+	ExpireAt        uint64 `gorm:"index;default:0" json:"expire_at,omitempty"` // 到期时间戳，0 表示不过期
 }
 
 func (w *WAF) TableName() string {
 	return "nz_waf"
 }
 
+// WAFRange 按 CIDR 封禁的 IP 段
+type WAFRange struct {
+	ID              uint64    `gorm:"primaryKey" json:"id,omitempty"`
+	CIDR            string    `gorm:"column:cidr;uniqueIndex" json:"cidr,omitempty"`
+	BlockReason     uint8     `json:"block_reason,omitempty"`
+	BlockIdentifier int64     `json:"block_identifier,omitempty"`
+	ExpireAt        uint64    `gorm:"index;default:0" json:"expire_at,omitempty"` // 到期时间戳，0 表示不过期
+	CreatedAt       time.Time `json:"created_at,omitempty"`
+}
+
+func (w *WAFRange) TableName() string {
+	return "nz_waf_range"
+}
+
+// BlockSource 封禁来源，手动添加或由系统自动封禁
+func BlockSource(reason uint8) string {
+	if reason == WAFBlockReasonTypeManual {
+		return WAFBlockSourceManual
+	}
+	return WAFBlockSourceAutomatic
+}
+
+const (
+	WAFBlockSourceManual    = "manual"
+	WAFBlockSourceAutomatic = "automatic"
+)
+
+const (
+	WAFAuditActionBlock   = "block"
+	WAFAuditActionUnblock = "unblock"
+)
+
+// WAFAudit 手动封禁与解封的审计记录，封禁到期删除后仍保留
+type WAFAudit struct {
+	ID        uint64    `gorm:"primaryKey" json:"id,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at,omitempty"`
+	UserID    uint64    `gorm:"index" json:"user_id,omitempty"` // 操作人，0 表示系统
+	Action    string    `json:"action,omitempty" enums:"block,unblock"`
+	Address   string    `json:"address,omitempty"` // IP、CIDR 或 country:xx / asn:xx
+	ExpireAt  uint64    `json:"expire_at,omitempty"`
+}
+
+func (w *WAFAudit) TableName() string {
+	return "nz_waf_audit"
+}
+
+// ExpiredAt 封禁是否已到期
+func ExpiredAt(expireAt uint64, now time.Time) bool {
+	return expireAt > 0 && expireAt <= uint64(now.Unix())
+}
+
+const (
+	_ uint8 = iota
+	WAFGeoTypeCountry
+	WAFGeoTypeASN
+)
+
+// WAFGeo 按国家或自治系统封禁，需配置 GeoIP 数据库
+type WAFGeo struct {
+	ID        uint64    `gorm:"primaryKey" json:"id,omitempty"`
+	Type      uint8     `gorm:"uniqueIndex:idx_waf_geo" json:"type,omitempty"`
+	Value     string    `gorm:"uniqueIndex:idx_waf_geo" json:"value,omitempty"` // 小写的国家代码或 ASN
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+func (w *WAFGeo) TableName() string {
+	return "nz_waf_geo"
+}
+
+func (w *WAFGeo) String() string {
+	if w.Type == WAFGeoTypeASN {
+		return "asn:" + w.Value
+	}
+	return "country:" + w.Value
+}
+
 func CheckIP(db *gorm.DB, ip string) error {
 	if ip == "" {
 		return nil
@@ -53,8 +130,11 @@ func CheckIP(db *gorm.DB, ip string) error {
 		return err
 	}
 
+	// 忽略已到期但尚未被清理的记录
+	now := time.Now().Unix()
+
 	var blockTimestamp uint64
-	result := db.Model(&WAF{}).Order("block_timestamp desc").Select("block_timestamp").Where("ip = ?", ipBinary).Limit(1).Find(&blockTimestamp)
+	result := db.Model(&WAF{}).Order("block_timestamp desc").Select("block_timestamp").Where("ip = ? AND (expire_at = 0 OR expire_at > ?)", ipBinary, now).Limit(1).Find(&blockTimestamp)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -65,17 +145,52 @@ func CheckIP(db *gorm.DB, ip string) error {
 	}
 
 	var count uint64
-	if err := db.Model(&WAF{}).Select("SUM(count)").Where("ip = ?", ipBinary).Scan(&count).Error; err != nil {
+	if err := db.Model(&WAF{}).Select("SUM(count)").Where("ip = ? AND (expire_at = 0 OR expire_at > ?)", ipBinary, now).Scan(&count).Error; err != nil {
 		return err
 	}
 
-	now := time.Now().Unix()
 	if powAdd(count, 4, blockTimestamp) > uint64(now) {
 		return errors.New("you are blocked by nezha WAF")
 	}
 	return nil
 }
 
+// ActiveIPBlocks 返回当前仍在封禁期内的 IP，与 CheckIP 的判定方式一致
+func ActiveIPBlocks(db *gorm.DB) ([]*WAFBlock, error) {
+	now := uint64(time.Now().Unix())
+	var rows []WAF
+	if err := db.Where("expire_at = 0 OR expire_at > ?", now).Order("block_timestamp").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	blocks := make(map[string]*WAFBlock)
+	var order []string
+	for _, w := range rows {
+		ip := utils.BinaryToIPString(w.IP)
+		b, ok := blocks[ip]
+		if !ok {
+			b = &WAFBlock{Address: ip, Source: WAFBlockSourceAutomatic}
+			blocks[ip] = b
+			order = append(order, ip)
+		}
+		b.Count += w.Count
+		b.BlockReason = w.BlockReason
+		b.BlockTimestamp = max(b.BlockTimestamp, w.BlockTimestamp)
+		if w.BlockReason == WAFBlockReasonTypeManual {
+			b.Source = WAFBlockSourceManual
+			b.SetExpireAt(w.ExpireAt, now)
+		}
+	}
+
+	list := make([]*WAFBlock, 0, len(order))
+	for _, ip := range order {
+		if b := blocks[ip]; powAdd(b.Count, 4, b.BlockTimestamp) > now {
+			list = append(list, b)
+		}
+	}
+	return list, nil
+}
+
 func ClearIP(db *gorm.DB, ip string, uid int64) error {
 	if ip == "" {
 		return nil
@@ -103,6 +218,11 @@ func BatchClearIP(db *gorm.DB, ip []string) error {
 }
 
 func BlockIP(db *gorm.DB, ip string, reason uint8, uid int64) error {
+	return BlockIPUntil(db, ip, reason, uid, 0)
+}
+
+// BlockIPUntil 封禁 IP 至指定时间戳，expireAt 为 0 时不过期
+func BlockIPUntil(db *gorm.DB, ip string, reason uint8, uid int64, expireAt uint64) error {
 	if ip == "" {
 		return nil
 	}
@@ -119,10 +239,12 @@ func BlockIP(db *gorm.DB, ip string, reason uint8, uid int64) error {
 		if err := tx.Where(&w).Attrs(WAF{
 			BlockReason:    reason,
 			BlockTimestamp: now,
+			ExpireAt:       expireAt,
 		}).FirstOrCreate(&w).Error; err != nil {
 			return err
 		}
-		return tx.Exec("UPDATE nz_waf SET count = count + 1, block_reason = ?, block_timestamp = ? WHERE ip = ? and block_identifier = ?", reason, now, ipBinary, uid).Error
+		// 已有的永久封禁不会被再次的临时封禁改为到期解除
+		return tx.Exec("UPDATE nz_waf SET count = count + 1, block_reason = ?, block_timestamp = ?, expire_at = CASE WHEN expire_at = 0 THEN 0 ELSE ? END WHERE ip = ? and block_identifier = ?", reason, now, expireAt, ipBinary, uid).Error
 	})
 }
 
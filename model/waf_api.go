@@ -0,0 +1,27 @@
+package model
+
+// WAFBlock 当前生效的 IP 或 IP 段封禁
+type WAFBlock struct {
+	Address        string `json:"address"` // IP 或 CIDR
+	Range          bool   `json:"range,omitempty"`
+	Source         string `json:"source" enums:"manual,automatic"`
+	BlockReason    uint8  `json:"block_reason,omitempty"`
+	BlockTimestamp uint64 `json:"block_timestamp,omitempty"`
+	Count          uint64 `json:"count,omitempty"`
+	ExpireAt       uint64 `json:"expire_at,omitempty"` // 0 表示不过期
+	TTL            uint64 `json:"ttl,omitempty"`       // 剩余秒数
+}
+
+func (b *WAFBlock) SetExpireAt(expireAt, now uint64) {
+	b.ExpireAt = expireAt
+	if expireAt > now {
+		b.TTL = expireAt - now
+	} else {
+		b.TTL = 0
+	}
+}
+
+type WAFGeoForm struct {
+	Type  uint8  `json:"type"`  // 1: 国家 2: ASN
+	Value string `json:"value"` // 国家代码或 ASN
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// WebAuthnCredential 用户注册的通行密钥或安全密钥
+type WebAuthnCredential struct {
+	Common
+	Name string `json:"name"`
+	// base64url 编码的凭据 ID
+	CredentialID string     `json:"credential_id" gorm:"uniqueIndex"`
+	PublicKey    []byte     `json:"-"` // COSE 编码的公钥
+	SignCount    uint32     `json:"-"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
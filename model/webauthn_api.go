@@ -0,0 +1,78 @@
+package model
+
+// 以下结构与浏览器 navigator.credentials 接口的参数对应，二进制字段均为 base64url 编码
+
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type WebAuthnUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type WebAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type WebAuthnCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+type WebAuthnCreationOptions struct {
+	Challenge              string                         `json:"challenge"`
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity             `json:"user"`
+	PubKeyCredParams       []WebAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"` // 毫秒
+	Attestation            string                         `json:"attestation"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+}
+
+type WebAuthnRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	RPID             string                         `json:"rpId"`
+	Timeout          int64                          `json:"timeout"` // 毫秒
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+type WebAuthnLoginBeginForm struct {
+	// 为空时使用可发现凭据（通行密钥），由浏览器选择账户
+	Username string `json:"username,omitempty" validate:"optional"`
+}
+
+type WebAuthnLoginBeginResponse struct {
+	Session string                 `json:"session"`
+	Options WebAuthnRequestOptions `json:"options"`
+}
+
+type WebAuthnRegisterForm struct {
+	Name     string `json:"name" minLength:"1"`
+	ID       string `json:"id"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+	} `json:"response"`
+}
+
+type WebAuthnLoginForm struct {
+	Session  string `json:"session"`
+	ID       string `json:"id"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle,omitempty" validate:"optional"`
+	} `json:"response"`
+}
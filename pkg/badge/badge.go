@@ -0,0 +1,81 @@
+package badge
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// 与 shields.io 一致的命名颜色
+var namedColors = map[string]string{
+	"brightgreen": "#4c1",
+	"green":       "#97ca00",
+	"yellow":      "#dfb317",
+	"orange":      "#fe7d37",
+	"red":         "#e05d44",
+	"blue":        "#007ec6",
+	"lightgrey":   "#9f9f9f",
+	"grey":        "#555",
+}
+
+var hexColor = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Color 解析命名颜色或十六进制颜色，无法解析时返回 fallback
+func Color(s, fallback string) string {
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c
+	}
+	if hexColor.MatchString(s) {
+		return "#" + strings.TrimPrefix(s, "#")
+	}
+	return fallback
+}
+
+// Badge 左侧为标签、右侧为内容的 SVG 徽章，样式与 shields.io 的 flat 风格一致
+type Badge struct {
+	Label   string
+	Message string
+	Color   string // 内容背景色，命名颜色或十六进制颜色
+}
+
+// 11px Verdana 下的近似字宽，未列出的字符按 7 计算
+var narrowChars = map[rune]int{
+	'i': 3, 'l': 3, 'j': 3, '.': 4, ',': 4, ':': 4, ';': 4, '|': 4, '!': 4, '\'': 3,
+	'f': 4, 't': 4, 'r': 5, ' ': 4, '(': 5, ')': 5, '[': 5, ']': 5, '-': 5,
+	'm': 11, 'w': 9, 'M': 10, 'W': 11, '%': 12,
+}
+
+func textWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		if cw, ok := narrowChars[r]; ok {
+			w += cw
+		} else if r > 0x2e80 {
+			// 中日韩文字按全角计算
+			w += 11
+		} else {
+			w += 7
+		}
+	}
+	return w
+}
+
+// SVG 渲染徽章
+func (b *Badge) SVG() []byte {
+	const padding = 10
+	lw := textWidth(b.Label) + padding
+	mw := textWidth(b.Message) + padding
+	label, message := html.EscapeString(b.Label), html.EscapeString(b.Message)
+	color := Color(b.Color, namedColors["lightgrey"])
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]d" y="14">%[4]s</text>`+
+		`<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]d" y="14">%[5]s</text></g></svg>`,
+		lw+mw, lw, mw, label, message, color, lw/2, lw+mw/2))
+}
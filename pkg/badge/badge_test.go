@@ -0,0 +1,40 @@
+package badge
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestColor(t *testing.T) {
+	cases := map[string]string{
+		"brightgreen": "#4c1",
+		"Red":         "#e05d44",
+		"ff0000":      "#ff0000",
+		"#abc":        "#abc",
+		"#abcd":       "#000",
+		"url(x)":      "#000",
+	}
+	for in, want := range cases {
+		if got := Color(in, "#000"); got != want {
+			t.Errorf("Color(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBadgeSVG(t *testing.T) {
+	b := &Badge{Label: `api <"prod">`, Message: "up 99.95%", Color: "brightgreen"}
+	svg := string(b.SVG())
+
+	if err := xml.Unmarshal([]byte(svg), new(struct{})); err != nil {
+		t.Fatalf("invalid svg: %v\n%s", err, svg)
+	}
+	for _, s := range []string{"api &lt;&#34;prod&#34;&gt;", "up 99.95%", `fill="#4c1"`} {
+		if !strings.Contains(svg, s) {
+			t.Errorf("svg does not contain %q:\n%s", s, svg)
+		}
+	}
+	if w := textWidth("up 99.95%"); w <= textWidth("up") {
+		t.Errorf("unexpected text width %d", w)
+	}
+}
@@ -0,0 +1,142 @@
+package geoip
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	maxminddb "github.com/oschwald/maxminddb-golang"
+)
+
+// 查询结果缓存的最大条目数，超出后整体清空
+const locationCacheSize = 4096
+
+// Location IP 的地理位置与所属自治系统
+type Location struct {
+	CountryCode string `json:"country_code,omitempty"`
+	Country     string `json:"country,omitempty"`
+	City        string `json:"city,omitempty"`
+	ASN         uint   `json:"asn,omitempty"`
+	ASOrg       string `json:"as_org,omitempty"`
+}
+
+type cityRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+var (
+	externalLock sync.RWMutex
+	cityDB       *maxminddb.Reader
+	asnDB        *maxminddb.Reader
+	cityDBPath   string
+	asnDBPath    string
+
+	locationCache     = make(map[string]*Location)
+	locationCacheLock sync.Mutex
+)
+
+// SetDatabases 加载 MaxMind 格式的城市库与 ASN 库，路径为空时不使用对应数据库
+func SetDatabases(cityPath, asnPath string) error {
+	externalLock.Lock()
+	defer externalLock.Unlock()
+
+	var cityErr, asnErr error
+	// 加载失败时不记录路径，以便下次设置相同路径时重试
+	if cityPath != cityDBPath {
+		if cityDB, cityErr = reopen(cityDB, cityPath); cityErr == nil {
+			cityDBPath = cityPath
+		} else {
+			cityDBPath = ""
+		}
+	}
+	if asnPath != asnDBPath {
+		if asnDB, asnErr = reopen(asnDB, asnPath); asnErr == nil {
+			asnDBPath = asnPath
+		} else {
+			asnDBPath = ""
+		}
+	}
+
+	locationCacheLock.Lock()
+	clear(locationCache)
+	locationCacheLock.Unlock()
+	return errors.Join(cityErr, asnErr)
+}
+
+func reopen(old *maxminddb.Reader, path string) (*maxminddb.Reader, error) {
+	if old != nil {
+		old.Close()
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return maxminddb.Open(path)
+}
+
+// LookupLocation 查询 IP 的地理位置，没有任何可用数据时返回 nil
+func LookupLocation(ip string) *Location {
+	netIP := net.ParseIP(ip)
+	if netIP == nil {
+		return nil
+	}
+
+	locationCacheLock.Lock()
+	loc, ok := locationCache[ip]
+	locationCacheLock.Unlock()
+	if ok {
+		return loc
+	}
+
+	loc = lookupLocation(netIP)
+
+	locationCacheLock.Lock()
+	if len(locationCache) >= locationCacheSize {
+		clear(locationCache)
+	}
+	locationCache[ip] = loc
+	locationCacheLock.Unlock()
+	return loc
+}
+
+func lookupLocation(ip net.IP) *Location {
+	externalLock.RLock()
+	defer externalLock.RUnlock()
+
+	var loc Location
+	if cityDB != nil {
+		var record cityRecord
+		if err := cityDB.Lookup(ip, &record); err == nil {
+			loc.CountryCode = strings.ToLower(record.Country.ISOCode)
+			loc.Country = record.Country.Names["en"]
+			loc.City = record.City.Names["en"]
+		}
+	}
+	if asnDB != nil {
+		var record asnRecord
+		if err := asnDB.Lookup(ip, &record); err == nil {
+			loc.ASN = record.Number
+			loc.ASOrg = record.Organization
+		}
+	}
+	// 未配置城市库时使用内置的国家数据库
+	if loc.CountryCode == "" {
+		loc.CountryCode, _ = Lookup(ip)
+	}
+
+	if loc == (Location{}) {
+		return nil
+	}
+	return &loc
+}
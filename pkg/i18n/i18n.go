@@ -76,6 +76,20 @@ func (l *Localizer) T(orig string) string {
 	return intl.PGettext("", orig)
 }
 
+// TL translates a string into the given language instead of the
+// current one. The original string is returned if the language is
+// not loaded or the translation is missing.
+func (l *Localizer) TL(lang, orig string) string {
+	l.mu.RLock()
+	intl, ok := l.intlMap[lang]
+	l.mu.RUnlock()
+	if !ok {
+		return orig
+	}
+
+	return intl.PGettext("", orig)
+}
+
 // N translates a string, possibly substituting arguments into it along
 // the way. If len(args) is > 0, args1 is assumed to be the plural value
 // and plural translation is used.
@@ -0,0 +1,367 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// 验证 ID Token 时允许的时钟偏差
+	clockSkew = time.Minute
+	// 遇到未知 kid 时重新获取 JWKS 的最小间隔
+	jwksRefreshInterval = time.Minute
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid id token")
+
+	validMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256", "PS384", "PS512"}
+)
+
+// Provider 使用授权码流程（PKCE）登录的 OpenID Connect 提供方
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	Client *http.Client
+
+	mu          sync.Mutex
+	metadata    *metadata
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims ID Token 中的声明
+type Claims jwt.MapClaims
+
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// EmailVerified 兼容部分提供方以字符串形式返回 email_verified
+func (c Claims) EmailVerified() bool {
+	switch v := c["email_verified"].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// Strings 读取字符串或字符串数组类型的声明，如分组
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// RandomString 生成用于 state、nonce 与 PKCE verifier 的随机字符串
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeURL 返回跳转到提供方登录页面的地址
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return md.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange 使用授权码换取并验证 ID Token
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.doJSON(req, &token); err != nil {
+		if token.Error != "" {
+			return nil, fmt.Errorf("token endpoint: %s %s", token.Error, token.ErrorDescription)
+		}
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token endpoint returned no id_token")
+	}
+
+	return p.Verify(ctx, token.IDToken, nonce)
+}
+
+// Verify 校验 ID Token 的签名、签发方、受众、有效期与 nonce
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (Claims, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := jwt.NewParser(jwt.WithValidMethods(validMethods), jwt.WithoutClaimsValidation())
+	claims := jwt.MapClaims{}
+	if _, err := parser.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, md, kid)
+	}); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	now := time.Now()
+	switch {
+	case !claims.VerifyIssuer(md.Issuer, true):
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidIDToken)
+	case !claims.VerifyAudience(p.ClientID, true):
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	case !claims.VerifyExpiresAt(now.Add(-clockSkew).Unix(), true):
+		return nil, fmt.Errorf("%w: token is expired", ErrInvalidIDToken)
+	case !claims.VerifyIssuedAt(now.Add(clockSkew).Unix(), false):
+		return nil, fmt.Errorf("%w: token used before issued", ErrInvalidIDToken)
+	case Claims(claims).String("nonce") != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	case Claims(claims).String("sub") == "":
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	return Claims(claims), nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var md metadata
+	if err := p.doJSON(req, &md); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(md.Issuer, "/") != strings.TrimSuffix(p.Issuer, "/") {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", md.Issuer, p.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("discovery: missing endpoints")
+	}
+
+	p.metadata = &md
+	return p.metadata, nil
+}
+
+// key 返回 kid 对应的公钥，未找到时按最小间隔重新获取 JWKS 以支持密钥轮换
+func (p *Provider) key(ctx context.Context, md *metadata, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k := p.lookupKey(kid); k != nil {
+		return k, nil
+	}
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, md.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if k := p.lookupKey(kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// lookupKey 令牌未携带 kid 且 JWKS 中只有一个密钥时使用该密钥
+func (p *Provider) lookupKey(kid string) crypto.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k
+		}
+	}
+	return p.keys[kid]
+}
+
+func (p *Provider) doJSON(req *http.Request, v any) error {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	// 出错时仍尝试解析，以便读取 error 字段
+	jsonErr := json.Unmarshal(body, v)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: %s", req.URL.Path, resp.Status)
+	}
+	return jsonErr
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,121 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var srv *httptest.Server
+	var idToken string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"jwks_uri":               srv.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good" || r.FormValue("code_verifier") != "verifier" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	sign := func(claims jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = "k1"
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":    srv.URL,
+			"aud":    "nezha",
+			"sub":    "user-1",
+			"email":  "a@example.com",
+			"nonce":  "n",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"iat":    time.Now().Unix(),
+			"groups": []string{"ops", "admins"},
+		}
+	}
+
+	p := &Provider{Issuer: srv.URL, ClientID: "nezha", RedirectURL: "https://nezha.example/callback", Scopes: []string{"openid", "email"}}
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "s", "n", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	if u.Path != "/authorize" || u.Query().Get("code_challenge_method") != "S256" || u.Query().Get("scope") != "openid email" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+
+	idToken = sign(claims())
+	c, err := p.Exchange(ctx, "good", "verifier", "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.String("sub") != "user-1" || len(c.Strings("groups")) != 2 {
+		t.Fatalf("unexpected claims %v", c)
+	}
+	if _, err := p.Exchange(ctx, "bad", "verifier", "n"); err == nil {
+		t.Fatal("exchange with invalid code should fail")
+	}
+
+	cases := map[string]func(jwt.MapClaims){
+		"nonce":    func(c jwt.MapClaims) { c["nonce"] = "other" },
+		"audience": func(c jwt.MapClaims) { c["aud"] = "other" },
+		"issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example" },
+		"expired":  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+	}
+	for name, mutate := range cases {
+		c := claims()
+		mutate(c)
+		if _, err := p.Verify(ctx, sign(c), "n"); err == nil {
+			t.Fatalf("token with wrong %s should be rejected", name)
+		}
+	}
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, claims()).SignedString(other)
+	if _, err := p.Verify(ctx, forged, "n"); err == nil {
+		t.Fatal("token signed by an unknown key should be rejected")
+	}
+}
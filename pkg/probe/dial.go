@@ -0,0 +1,87 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateTarget 面板侧探测默认不能访问内网、本机与链路本地地址，避免用户借助服务监控探测面板所在的网络
+var ErrPrivateTarget = errors.New("private, loopback or link-local address is not allowed")
+
+type ctxKeyAllowPrivate struct{}
+
+// AllowPrivateTargets 返回允许探测内网地址的 context，只用于超级管理员创建的服务监控
+func AllowPrivateTargets(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyAllowPrivate{}, true)
+}
+
+func privateAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(ctxKeyAllowPrivate{}).(bool)
+	return allowed
+}
+
+// 运营商级 NAT 使用的共享地址段，netip 不将其视为私有地址
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsGlobalUnicast() || addr.IsPrivate() || sharedAddressSpace.Contains(addr)
+}
+
+// checkDialAddress 在 DNS 解析之后、建立连接之前检查地址，域名解析到内网地址时同样会被拒绝
+func checkDialAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if isPrivateAddr(addr) {
+		return ErrPrivateTarget
+	}
+	return nil
+}
+
+// dialer 返回探测使用的 Dialer，未允许内网地址时拒绝连接内网目标
+func dialer(ctx context.Context) *net.Dialer {
+	d := &net.Dialer{Timeout: defaultTimeout}
+	if !privateAllowed(ctx) {
+		d.Control = checkDialAddress
+	}
+	return d
+}
+
+var (
+	// 不使用环境变量中的代理，否则检查的是代理的地址
+	publicHTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext:     dialer(context.Background()).DialContext,
+			TLSClientConfig: &tls.Config{},
+		},
+		Timeout: defaultTimeout + time.Second,
+	}
+	privateHTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext:     dialer(AllowPrivateTargets(context.Background())).DialContext,
+			TLSClientConfig: &tls.Config{},
+			Proxy:           http.ProxyFromEnvironment,
+		},
+		Timeout: defaultTimeout + time.Second,
+	}
+)
+
+// httpClient 返回探测使用的 HTTP 客户端，重定向后的地址同样受到检查
+func httpClient(ctx context.Context) *http.Client {
+	if privateAllowed(ctx) {
+		return privateHTTPClient
+	}
+	return publicHTTPClient
+}
@@ -0,0 +1,182 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// DNSTarget DNS 查询监控的目标
+type DNSTarget struct {
+	// UDP 时为 host:port，DoH 时为完整的查询地址
+	Resolver string
+	DoH      bool
+	Name     string
+	Type     uint16
+	// 期望的解析结果，需全部出现在应答中
+	Expect []string
+}
+
+// ParseDNSTarget 解析监控目标，支持以下格式：
+//
+//	udp://1.1.1.1:53/example.com?type=A&expect=1.2.3.4,5.6.7.8
+//	https://dns.google/dns-query?name=example.com&type=AAAA
+//
+// 省略 UDP 解析服务器时使用内置的公共 DNS，省略 type 时查询 A 记录
+func ParseDNSTarget(target string) (*DNSTarget, error) {
+	u, err := url.Parse(strings.TrimSpace(target))
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	t := &DNSTarget{Type: dns.TypeA}
+	if typ := q.Get("type"); typ != "" {
+		var ok bool
+		if t.Type, ok = dns.StringToType[strings.ToUpper(typ)]; !ok {
+			return nil, fmt.Errorf("unsupported record type: %s", typ)
+		}
+	}
+	if expect := q.Get("expect"); expect != "" {
+		for _, v := range strings.Split(expect, ",") {
+			t.Expect = append(t.Expect, normalizeAnswer(v))
+		}
+	}
+
+	switch u.Scheme {
+	case "udp", "dns":
+		t.Name = strings.TrimPrefix(u.Path, "/")
+		t.Resolver = u.Host
+		if t.Resolver == "" {
+			t.Resolver = utils.DNSServers[0]
+		} else if _, _, err := net.SplitHostPort(t.Resolver); err != nil {
+			t.Resolver = net.JoinHostPort(strings.Trim(t.Resolver, "[]"), "53")
+		}
+	case "https":
+		t.DoH = true
+		t.Name = q.Get("name")
+		// 其余查询参数原样保留给 DoH 服务
+		q.Del("name")
+		q.Del("type")
+		q.Del("expect")
+		u.RawQuery = q.Encode()
+		t.Resolver = u.String()
+	default:
+		return nil, fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+
+	if t.Name == "" {
+		return nil, fmt.Errorf("query name is required")
+	}
+	t.Name = dns.Fqdn(t.Name)
+	return t, nil
+}
+
+// DNSQuery 查询指定记录，应答为空、返回码非 NOERROR 或与期望结果不符时视为离线
+func DNSQuery(ctx context.Context, target string) Result {
+	t, err := ParseDNSTarget(target)
+	if err != nil {
+		return Result{Data: fmt.Sprintf("invalid target: %v", err)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	m := new(dns.Msg)
+	m.SetQuestion(t.Name, t.Type)
+
+	start := time.Now()
+	var resp *dns.Msg
+	if t.DoH {
+		resp, err = exchangeDoH(ctx, t.Resolver, m)
+	} else {
+		c := &dns.Client{Timeout: defaultTimeout, Dialer: dialer(ctx)}
+		resp, _, err = c.ExchangeContext(ctx, m, t.Resolver)
+	}
+	delay := elapsed(start)
+	if err != nil {
+		return Result{Data: fmt.Sprintf("query failed: %v", err)}
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return Result{Data: dns.RcodeToString[resp.Rcode]}
+	}
+
+	var answers []string
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype == t.Type {
+			answers = append(answers, answerValue(rr))
+		}
+	}
+	if len(answers) == 0 {
+		return Result{Data: fmt.Sprintf("no %s record for %s", dns.TypeToString[t.Type], t.Name)}
+	}
+	for _, want := range t.Expect {
+		if !slices.Contains(answers, want) {
+			return Result{Data: fmt.Sprintf("assertion failed: expected %s, got %s", strings.Join(t.Expect, ","), strings.Join(answers, ","))}
+		}
+	}
+	return Result{Successful: true, Delay: delay}
+}
+
+// exchangeDoH 按 RFC 8484 以 POST 方式发送 DNS 报文
+func exchangeDoH(ctx context.Context, endpoint string, m *dns.Msg) (*dns.Msg, error) {
+	// DoH 要求报文 ID 为 0，以便于缓存
+	m.Id = 0
+	packed, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := httpClient(ctx).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%d@%s", resp.StatusCode, resp.Status)
+	}
+
+	r := new(dns.Msg)
+	if err := r.Unpack(body); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func answerValue(rr dns.RR) string {
+	if txt, ok := rr.(*dns.TXT); ok {
+		return normalizeAnswer(strings.Join(txt.Txt, ""))
+	}
+	return normalizeAnswer(strings.TrimPrefix(rr.String(), rr.Header().String()))
+}
+
+func normalizeAnswer(v string) string {
+	v = strings.TrimSpace(v)
+	if ip := net.ParseIP(v); ip != nil {
+		return ip.String()
+	}
+	return strings.TrimSuffix(strings.ToLower(v), ".")
+}
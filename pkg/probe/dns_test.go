@@ -0,0 +1,91 @@
+package probe
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func dnsHandler(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if r.Question[0].Name == "example.com." && r.Question[0].Qtype == dns.TypeA {
+		rr, _ := dns.NewRR("example.com. 60 IN A 192.0.2.1")
+		m.Answer = append(m.Answer, rr)
+	} else if r.Question[0].Name != "example.com." {
+		m.Rcode = dns.RcodeNameError
+	}
+	w.WriteMsg(m)
+}
+
+func TestDNSQuery(t *testing.T) {
+	// 测试服务监听在本机
+	ctx := AllowPrivateTargets(context.Background())
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(dnsHandler)}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+	resolver := "udp://" + pc.LocalAddr().String()
+
+	cases := []struct {
+		target     string
+		successful bool
+		data       string
+	}{
+		{target: resolver + "/example.com", successful: true},
+		{target: resolver + "/example.com?type=a&expect=192.0.2.1", successful: true},
+		{target: resolver + "/example.com?expect=192.0.2.2", data: "assertion failed"},
+		{target: resolver + "/example.com?type=AAAA", data: "no AAAA record"},
+		{target: resolver + "/missing.com", data: "NXDOMAIN"},
+		{target: resolver + "/example.com?type=BOGUS", data: "invalid target"},
+	}
+	for _, c := range cases {
+		r := DNSQuery(ctx, c.target)
+		if r.Successful != c.successful || !strings.HasPrefix(r.Data, c.data) {
+			t.Errorf("DNSQuery(%q) = %+v", c.target, r)
+		}
+	}
+}
+
+func TestDNSQueryDoH(t *testing.T) {
+	// 测试服务监听在本机
+	ctx := AllowPrivateTargets(context.Background())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := new(dns.Msg)
+		if r.Header.Get("Content-Type") != "application/dns-message" || req.Unpack(body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m := new(dns.Msg)
+		m.SetReply(req)
+		rr, _ := dns.NewRR("example.com. 60 IN A 192.0.2.1")
+		m.Answer = append(m.Answer, rr)
+		packed, _ := m.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	defer ts.Close()
+
+	target, err := ParseDNSTarget("https://dns.example/dns-query?name=example.com&type=A&expect=192.0.2.1&ct=1")
+	if err != nil || !target.DoH || target.Resolver != "https://dns.example/dns-query?ct=1" || target.Name != "example.com." {
+		t.Fatalf("ParseDNSTarget = %+v, %v", target, err)
+	}
+
+	// httptest 使用 HTTP，这里直接验证报文交换，避免依赖证书
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	resp, err := exchangeDoH(ctx, ts.URL+"/dns-query", m)
+	if err != nil || len(resp.Answer) != 1 || answerValue(resp.Answer[0]) != "192.0.2.1" {
+		t.Errorf("exchangeDoH = %+v, %v", resp, err)
+	}
+}
@@ -0,0 +1,114 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCTarget gRPC 健康检查的目标
+type GRPCTarget struct {
+	Address string
+	Service string
+	TLS     bool
+	// 使用 TLS 时跳过证书校验
+	SkipVerify bool
+}
+
+// ParseGRPCTarget 解析监控目标，支持以下格式：
+//
+//	host:port
+//	grpc://host:port/service.Name
+//	grpcs://host:port/service.Name?insecure=true
+func ParseGRPCTarget(target string) (*GRPCTarget, error) {
+	target = strings.TrimSpace(target)
+	if !strings.Contains(target, "://") {
+		target = "grpc://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &GRPCTarget{
+		Address: u.Host,
+		Service: strings.TrimPrefix(u.Path, "/"),
+	}
+	switch u.Scheme {
+	case "grpc":
+	case "grpcs":
+		t.TLS = true
+		t.SkipVerify = u.Query().Get("insecure") == "true"
+	default:
+		return nil, fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+	if _, _, err := net.SplitHostPort(t.Address); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// GRPCHealth 调用 grpc.health.v1.Health/Check，仅当返回 SERVING 时视为在线
+func GRPCHealth(ctx context.Context, target string) Result {
+	t, err := ParseGRPCTarget(target)
+	if err != nil {
+		return Result{Data: fmt.Sprintf("invalid target: %v", err)}
+	}
+
+	creds := insecure.NewCredentials()
+	if t.TLS {
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: t.SkipVerify})
+	}
+	d := dialer(ctx)
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", addr)
+		}),
+	}
+	if !privateAllowed(ctx) {
+		// 经过代理时检查的是代理的地址
+		opts = append(opts, grpc.WithNoProxy())
+	}
+	conn, err := grpc.NewClient(t.Address, opts...)
+	if err != nil {
+		return Result{Data: fmt.Sprintf("unreachable: %v", err)}
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: t.Service})
+	delay := elapsed(start)
+	if err != nil {
+		st := status.Convert(err)
+		switch st.Code() {
+		case codes.Unimplemented:
+			// 目标可达，但没有实现健康检查协议
+			return Result{Data: "UNIMPLEMENTED: grpc.health.v1.Health is not implemented by target"}
+		case codes.NotFound:
+			return Result{Data: fmt.Sprintf("SERVICE_UNKNOWN: %s", t.Service)}
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return Result{Data: fmt.Sprintf("unreachable: %s", st.Message())}
+		}
+		return Result{Data: fmt.Sprintf("%s: %s", st.Code(), st.Message())}
+	}
+
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return Result{Data: resp.GetStatus().String()}
+	}
+	return Result{Successful: true, Delay: delay}
+}
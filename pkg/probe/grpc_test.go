@@ -0,0 +1,83 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestParseGRPCTarget(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    GRPCTarget
+		wantErr bool
+	}{
+		{in: "127.0.0.1:50051", want: GRPCTarget{Address: "127.0.0.1:50051"}},
+		{in: "grpc://example.com:443/foo.Bar", want: GRPCTarget{Address: "example.com:443", Service: "foo.Bar"}},
+		{in: "grpcs://example.com:443?insecure=true", want: GRPCTarget{Address: "example.com:443", TLS: true, SkipVerify: true}},
+		{in: "http://example.com:443", wantErr: true},
+		{in: "example.com", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseGRPCTarget(c.in)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("ParseGRPCTarget(%q) expected error", c.in)
+			}
+			continue
+		}
+		if err != nil || *got != c.want {
+			t.Errorf("ParseGRPCTarget(%q) = %+v, %v, want %+v", c.in, got, err, c.want)
+		}
+	}
+}
+
+func serveGRPC(t *testing.T, register func(*grpc.Server)) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	register(s)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func TestGRPCHealth(t *testing.T) {
+	// 测试服务监听在本机
+	ctx := AllowPrivateTargets(context.Background())
+	hs := health.NewServer()
+	hs.SetServingStatus("down.Service", healthpb.HealthCheckResponse_NOT_SERVING)
+	addr := serveGRPC(t, func(s *grpc.Server) {
+		healthpb.RegisterHealthServer(s, hs)
+	})
+	bare := serveGRPC(t, func(*grpc.Server) {})
+
+	if r := GRPCHealth(ctx, addr); !r.Successful || r.Delay <= 0 {
+		t.Errorf("expected serving, got %+v", r)
+	}
+	if r := GRPCHealth(ctx, "grpc://"+addr+"/down.Service"); r.Successful || r.Data != "NOT_SERVING" {
+		t.Errorf("expected NOT_SERVING, got %+v", r)
+	}
+	if r := GRPCHealth(ctx, "grpc://"+addr+"/missing.Service"); r.Successful || !strings.HasPrefix(r.Data, "SERVICE_UNKNOWN") {
+		t.Errorf("expected SERVICE_UNKNOWN, got %+v", r)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := lis.Addr().String()
+	lis.Close()
+	if r := GRPCHealth(ctx, closed); r.Successful || !strings.HasPrefix(r.Data, "unreachable") {
+		t.Errorf("expected unreachable, got %+v", r)
+	}
+	if r := GRPCHealth(ctx, bare); r.Successful || !strings.HasPrefix(r.Data, "UNIMPLEMENTED") {
+		t.Errorf("expected UNIMPLEMENTED, got %+v", r)
+	}
+}
@@ -0,0 +1,109 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 响应体最多读取的字节数，超出部分不参与关键字匹配
+const httpMaxBodySize = 1 << 20
+
+// HTTPAssertion 对响应体内容的断言
+type HTTPAssertion struct {
+	Keyword string
+	// Keyword 为正则表达式
+	Regex bool
+	// 为 true 时要求响应体中不包含 Keyword
+	Invert     bool
+	IgnoreCase bool
+}
+
+// Matcher 编译断言，返回判断响应体是否包含关键字的函数
+func (a *HTTPAssertion) Matcher() (func([]byte) bool, error) {
+	if a.Regex {
+		expr := a.Keyword
+		if a.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		return re.Match, nil
+	}
+	if a.IgnoreCase {
+		keyword := strings.ToLower(a.Keyword)
+		return func(b []byte) bool {
+			return strings.Contains(strings.ToLower(string(b)), keyword)
+		}, nil
+	}
+	return func(b []byte) bool {
+		return strings.Contains(string(b), a.Keyword)
+	}, nil
+}
+
+// HTTPGet 附带 header 请求目标并检查状态码与响应体断言，成功时 Data 为证书信息，与 Agent 上报的格式一致
+func HTTPGet(ctx context.Context, target string, header http.Header, assertion *HTTPAssertion) Result {
+	var match func([]byte) bool
+	if assertion != nil && assertion.Keyword != "" {
+		var err error
+		if match, err = assertion.Matcher(); err != nil {
+			return Result{Data: fmt.Sprintf("invalid assertion: %v", err)}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return Result{Data: fmt.Sprintf("invalid target: %v", err)}
+	}
+	if header != nil {
+		req.Header = header.Clone()
+		if host := header.Get("Host"); host != "" {
+			req.Host = host
+		}
+	}
+
+	start := time.Now()
+	resp, err := httpClient(ctx).Do(req)
+	if err != nil {
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			return Result{Data: "SSL证书错误：" + err.Error()}
+		}
+		return Result{Data: fmt.Sprintf("unreachable: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httpMaxBodySize))
+	delay := elapsed(start)
+	if err != nil {
+		return Result{Data: fmt.Sprintf("read body failed: %v", err)}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 399 {
+		return Result{Data: fmt.Sprintf("unexpected status: %s", resp.Status)}
+	}
+
+	if match != nil && match(body) == assertion.Invert {
+		if assertion.Invert {
+			return Result{Data: fmt.Sprintf("assertion failed: response contains %q", assertion.Keyword)}
+		}
+		return Result{Data: fmt.Sprintf("assertion failed: response does not contain %q", assertion.Keyword)}
+	}
+
+	var data string
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		c := resp.TLS.PeerCertificates[0]
+		data = c.Issuer.CommonName + "|" + c.NotAfter.String()
+	}
+	return Result{Successful: true, Delay: delay, Data: data}
+}
@@ -0,0 +1,88 @@
+package probe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestHTTPGet(t *testing.T) {
+	// 测试服务监听在本机
+	ctx := AllowPrivateTargets(context.Background())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/auth" && (r.Header.Get("X-Api-Key") != "k" || r.UserAgent() != "probe") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("<html>Status: OK, version 1.2.3</html>"))
+	}))
+	defer ts.Close()
+
+	cases := []struct {
+		path       string
+		header     http.Header
+		assertion  *HTTPAssertion
+		successful bool
+		data       string
+	}{
+		{path: "/", successful: true},
+		{path: "/", assertion: &HTTPAssertion{Keyword: "Status: OK"}, successful: true},
+		{path: "/", assertion: &HTTPAssertion{Keyword: "status: ok"}, data: "assertion failed: response does not contain"},
+		{path: "/", assertion: &HTTPAssertion{Keyword: "status: ok", IgnoreCase: true}, successful: true},
+		{path: "/", assertion: &HTTPAssertion{Keyword: `version \d+\.\d+`, Regex: true}, successful: true},
+		{path: "/", assertion: &HTTPAssertion{Keyword: "Error", Invert: true}, successful: true},
+		{path: "/", assertion: &HTTPAssertion{Keyword: "ok", Invert: true, IgnoreCase: true}, data: "assertion failed: response contains"},
+		{path: "/", assertion: &HTTPAssertion{Keyword: "(", Regex: true}, data: "invalid assertion"},
+		{path: "/error", data: "unexpected status: 503"},
+		{path: "/auth", data: "unexpected status: 401"},
+		{path: "/auth", header: http.Header{"X-Api-Key": {"k"}, "User-Agent": {"probe"}}, successful: true},
+	}
+	for _, c := range cases {
+		r := HTTPGet(ctx, ts.URL+c.path, c.header, c.assertion)
+		if r.Successful != c.successful || !strings.HasPrefix(r.Data, c.data) {
+			t.Errorf("HTTPGet(%q, %+v) = %+v", c.path, c.assertion, r)
+		}
+	}
+
+	ts.Close()
+	if r := HTTPGet(ctx, ts.URL, nil, nil); r.Successful || !strings.HasPrefix(r.Data, "unreachable") {
+		t.Errorf("expected unreachable, got %+v", r)
+	}
+}
+
+func TestPrivateTargetRefused(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	for name, r := range map[string]Result{
+		"http":     HTTPGet(ctx, ts.URL, nil, &HTTPAssertion{Keyword: "secret"}),
+		"tls":      TLSCert(ctx, ts.Listener.Addr().String()),
+		"grpc":     GRPCHealth(ctx, ts.Listener.Addr().String()),
+		"dns":      DNSQuery(ctx, "udp://"+ts.Listener.Addr().String()+"/example.com"),
+		"metadata": HTTPGet(ctx, "http://169.254.169.254/latest/meta-data/", nil, nil),
+	} {
+		if r.Successful || !strings.Contains(r.Data, ErrPrivateTarget.Error()) {
+			t.Errorf("%s: expected private target to be refused, got %+v", name, r)
+		}
+	}
+
+	for addr, private := range map[string]bool{
+		"127.0.0.1": true, "10.1.2.3": true, "192.168.1.1": true, "169.254.169.254": true, "100.64.0.1": true,
+		"::1": true, "fe80::1": true, "fd00::1": true, "::ffff:127.0.0.1": true, "0.0.0.0": true,
+		"1.1.1.1": false, "2606:4700:4700::1111": false,
+	} {
+		if got := isPrivateAddr(netip.MustParseAddr(addr)); got != private {
+			t.Errorf("isPrivateAddr(%s) = %v, want %v", addr, got, private)
+		}
+	}
+}
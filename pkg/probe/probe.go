@@ -0,0 +1,18 @@
+// Package probe 实现由面板直接发起的服务监控探测
+package probe
+
+import "time"
+
+// 面板侧探测的默认超时时间
+const defaultTimeout = 10 * time.Second
+
+// Result 一次探测的结果，Delay 单位为毫秒，与 Agent 上报的 TaskResult 保持一致
+type Result struct {
+	Successful bool
+	Delay      float32
+	Data       string
+}
+
+func elapsed(start time.Time) float32 {
+	return float32(time.Since(start).Microseconds()) / 1000
+}
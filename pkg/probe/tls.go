@@ -0,0 +1,119 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 证书链校验结果的标记，为空表示证书链可信
+const (
+	CertFlagSelfSigned       = "self_signed"
+	CertFlagIncompleteChain  = "incomplete_chain"
+	CertFlagHostnameMismatch = "hostname_mismatch"
+	CertFlagExpired          = "expired"
+	CertFlagNotYetValid      = "not_yet_valid"
+	CertFlagInvalid          = "invalid_chain"
+)
+
+// parseTLSTarget 支持 host:port、host 与 https:// 地址，省略端口时使用 443
+func parseTLSTarget(target string) (addr, host string, err error) {
+	target = strings.TrimSpace(target)
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", "", err
+		}
+		target = u.Host
+	}
+	if target == "" {
+		return "", "", errors.New("empty target")
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = strings.Trim(target, "[]"), "443"
+	}
+	return net.JoinHostPort(host, port), host, nil
+}
+
+// TLSCert 获取目标的证书并校验证书链，Data 格式为 签发者|过期时间|标记，
+// 与 Agent 上报的 HTTP 监控证书信息兼容。证书链不可信时仅做标记，过期或尚未生效时视为离线
+func TLSCert(ctx context.Context, target string) Result {
+	addr, host, err := parseTLSTarget(target)
+	if err != nil {
+		return Result{Data: fmt.Sprintf("invalid target: %v", err)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := dialer(ctx).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return Result{Data: fmt.Sprintf("unreachable: %v", err)}
+	}
+	defer conn.Close()
+
+	// 先跳过校验完成握手，以便拿到自签名或证书链不完整的证书
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return Result{Data: "SSL证书错误：" + err.Error()}
+	}
+	delay := elapsed(start)
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return Result{Data: "SSL证书错误：no peer certificate"}
+	}
+	leaf := certs[0]
+	flag := verifyChain(certs, host, time.Now())
+	data := fmt.Sprintf("%s|%s|%s", leaf.Issuer.CommonName, leaf.NotAfter.String(), flag)
+
+	if flag == CertFlagExpired || flag == CertFlagNotYetValid {
+		return Result{Data: data}
+	}
+	return Result{Successful: true, Delay: delay, Data: data}
+}
+
+// verifyChain 校验证书链并返回对应的标记
+func verifyChain(certs []*x509.Certificate, host string, now time.Time) string {
+	leaf := certs[0]
+	if now.After(leaf.NotAfter) {
+		return CertFlagExpired
+	}
+	if now.Before(leaf.NotBefore) {
+		return CertFlagNotYetValid
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	if err == nil {
+		return ""
+	}
+
+	var hostErr x509.HostnameError
+	var authErr x509.UnknownAuthorityError
+	switch {
+	case errors.As(err, &hostErr):
+		return CertFlagHostnameMismatch
+	case errors.As(err, &authErr):
+		if len(certs) == 1 && leaf.CheckSignatureFrom(leaf) == nil {
+			return CertFlagSelfSigned
+		}
+		return CertFlagIncompleteChain
+	}
+	return CertFlagInvalid
+}
@@ -0,0 +1,41 @@
+package probe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTLSCert(t *testing.T) {
+	// 测试服务监听在本机
+	ctx := AllowPrivateTargets(context.Background())
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	// httptest 使用自签名证书
+	r := TLSCert(ctx, ts.URL)
+	parts := strings.Split(r.Data, "|")
+	if !r.Successful || len(parts) != 3 || parts[2] != CertFlagSelfSigned {
+		t.Errorf("TLSCert(%q) = %+v", ts.URL, r)
+	}
+
+	if r := TLSCert(ctx, "127.0.0.1:1"); r.Successful || !strings.HasPrefix(r.Data, "unreachable") {
+		t.Errorf("expected unreachable, got %+v", r)
+	}
+}
+
+func TestParseTLSTarget(t *testing.T) {
+	cases := map[string]string{
+		"example.com":              "example.com:443",
+		"example.com:8443":         "example.com:8443",
+		"https://example.com/path": "example.com:443",
+		"[::1]":                    "[::1]:443",
+	}
+	for in, want := range cases {
+		if addr, _, err := parseTLSTarget(in); err != nil || addr != want {
+			t.Errorf("parseTLSTarget(%q) = %q, %v, want %q", in, addr, err, want)
+		}
+	}
+}
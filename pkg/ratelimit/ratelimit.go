@@ -0,0 +1,82 @@
+// Package ratelimit 提供按键（通常为客户端 IP）独立计数的令牌桶限流器
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// 每隔该时长清理一次已回满的令牌桶，避免长期占用内存
+const cleanupInterval = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter 令牌桶限流器，每个键以 rate 个/秒的速度补充令牌，最多积累 burst 个
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu          sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+}
+
+// New 创建每分钟补充 perMinute 个令牌、容量为 burst 的限流器
+func New(perMinute, burst int) *Limiter {
+	return &Limiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(max(burst, 1)),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow 尝试为 key 消耗一个令牌，被限流时返回距下一个令牌可用的等待时长
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cleanup(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if l.rate <= 0 {
+		return false, time.Duration(math.MaxInt64)
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return b.tokens
+	}
+	return min(l.burst, b.tokens+elapsed*l.rate)
+}
+
+// cleanup 删除已回满的令牌桶，它们与新建的桶等价
+func (l *Limiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < cleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+	for k, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+}
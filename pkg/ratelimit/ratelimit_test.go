@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := New(60, 2)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a", now); !ok {
+			t.Fatalf("request %d should be allowed within burst", i+1)
+		}
+	}
+	ok, wait := l.Allow("a", now)
+	if ok {
+		t.Fatal("request beyond burst should be limited")
+	}
+	if wait != time.Second {
+		t.Fatalf("expected to wait 1s for the next token, got %v", wait)
+	}
+	if ok, _ := l.Allow("b", now); !ok {
+		t.Fatal("keys should be limited independently")
+	}
+	if ok, _ := l.Allow("a", now.Add(time.Second)); !ok {
+		t.Fatal("token should be refilled after 1s")
+	}
+
+	l.Allow("a", now.Add(time.Hour))
+	if len(l.buckets) != 1 {
+		t.Fatalf("expected refilled buckets to be cleaned up, got %d", len(l.buckets))
+	}
+}
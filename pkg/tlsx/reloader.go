@@ -0,0 +1,106 @@
+package tlsx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// 两次检查证书文件是否更新的最小间隔
+const reloadCheckInterval = time.Second
+
+// Reloader 为每个 TLS 握手提供服务端证书与客户端 CA，文件修改后自动重新加载，
+// 加载失败时继续使用旧的证书，轮换证书无需重启
+type Reloader struct {
+	certFile, keyFile, caFile string
+	clientAuth                tls.ClientAuthType
+
+	mu        sync.Mutex
+	config    *tls.Config
+	modTimes  [3]time.Time
+	checkedAt time.Time
+}
+
+// NewReloader 加载证书与客户端 CA，首次加载失败时返回错误
+func NewReloader(certFile, keyFile, caFile string, clientAuth tls.ClientAuthType) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile, clientAuth: clientAuth}
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTimes); err != nil {
+		return nil, err
+	}
+	r.checkedAt = time.Now()
+	return r, nil
+}
+
+// TLSConfig 返回用于监听的配置，每个连接使用最新加载的证书
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		NextProtos:         []string{"h2", "http/1.1"},
+		GetConfigForClient: r.GetConfigForClient,
+	}
+}
+
+func (r *Reloader) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := time.Now(); now.Sub(r.checkedAt) >= reloadCheckInterval {
+		r.checkedAt = now
+		modTimes, err := r.stat()
+		if err == nil && modTimes != r.modTimes {
+			err = r.load(modTimes)
+		}
+		if err != nil {
+			log.Printf("NEZHA>> 重新加载 TLS 证书失败，继续使用旧证书: %v", err)
+		}
+	}
+	return r.config, nil
+}
+
+func (r *Reloader) stat() ([3]time.Time, error) {
+	var modTimes [3]time.Time
+	for i, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = fi.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (r *Reloader) load(modTimes [3]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	ca, err := os.ReadFile(r.caFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("%s: %w", r.caFile, errNoCertificates)
+	}
+
+	r.config = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   r.clientAuth,
+	}
+	r.modTimes = modTimes
+	return nil
+}
+
+var errNoCertificates = errors.New("no certificates found")
@@ -0,0 +1,90 @@
+package tlsx
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSelfSigned(t *testing.T, certFile, keyFile, cn string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	der := writeSelfSigned(t, certFile, keyFile, "dashboard")
+
+	if _, err := NewReloader(certFile, keyFile, keyFile, tls.RequireAndVerifyClientCert); err == nil {
+		t.Fatal("a CA file without certificates should be rejected")
+	}
+	r, err := NewReloader(certFile, keyFile, certFile, tls.RequireAndVerifyClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := func() []byte {
+		config, _ := r.GetConfigForClient(nil)
+		return config.Certificates[0].Certificate[0]
+	}
+	if !bytes.Equal(current(), der) {
+		t.Fatal("unexpected certificate")
+	}
+
+	// 轮换证书
+	rotated := writeSelfSigned(t, certFile, keyFile, "dashboard")
+	future := time.Now().Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		os.Chtimes(f, future, future)
+	}
+	r.checkedAt = time.Time{}
+	if !bytes.Equal(current(), rotated) {
+		t.Fatal("certificate should be reloaded after rotation")
+	}
+
+	// 写入了无效的证书时继续使用旧证书
+	os.WriteFile(certFile, []byte("invalid"), 0o600)
+	future = future.Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	r.checkedAt = time.Time{}
+	if !bytes.Equal(current(), rotated) {
+		t.Fatal("previous certificate should be kept when reloading fails")
+	}
+}
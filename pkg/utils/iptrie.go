@@ -0,0 +1,71 @@
+package utils
+
+import "net/netip"
+
+// IPTrie 基于二叉前缀树的 IP 段集合，IPv4 按 IPv4-mapped IPv6 地址存储
+type IPTrie struct {
+	root ipTrieNode
+}
+
+type ipTrieNode struct {
+	child    [2]*ipTrieNode
+	terminal bool
+}
+
+// To16Prefix 将 IPv4 段转换为等价的 IPv4-mapped IPv6 段
+func To16Prefix(p netip.Prefix) netip.Prefix {
+	p = p.Masked()
+	if p.Addr().Is4() {
+		return netip.PrefixFrom(netip.AddrFrom16(p.Addr().As16()), p.Bits()+96)
+	}
+	return p
+}
+
+func bitAt(b [16]byte, i int) int {
+	return int(b[i/8]>>(7-i%8)) & 1
+}
+
+// Insert 添加 IP 段，已被现有段覆盖时返回 false；新段会吸收其覆盖的更小的段
+func (t *IPTrie) Insert(p netip.Prefix) bool {
+	p = To16Prefix(p)
+	addr := p.Addr().As16()
+	node := &t.root
+	for i := 0; i < p.Bits(); i++ {
+		if node.terminal {
+			return false
+		}
+		b := bitAt(addr, i)
+		if node.child[b] == nil {
+			node.child[b] = &ipTrieNode{}
+		}
+		node = node.child[b]
+	}
+	if node.terminal {
+		return false
+	}
+	node.terminal = true
+	node.child = [2]*ipTrieNode{}
+	return true
+}
+
+// Contains 判断 IP 是否落在任一 IP 段内
+func (t *IPTrie) Contains(ip netip.Addr) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	addr := ip.As16()
+	node := &t.root
+	for i := 0; i <= 128; i++ {
+		if node.terminal {
+			return true
+		}
+		if i == 128 {
+			break
+		}
+		node = node.child[bitAt(addr, i)]
+		if node == nil {
+			return false
+		}
+	}
+	return false
+}
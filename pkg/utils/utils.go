@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"cmp"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"maps"
 	"math/big"
 	"net/netip"
@@ -58,18 +60,86 @@ func BinaryToIPString(b []byte) string {
 }
 
 func GetIPFromHeader(headerValue string) (string, error) {
-	a := strings.Split(headerValue, ",")
-	h := strings.TrimSpace(a[len(a)-1])
-	ip, err := netip.ParseAddr(h)
-	if err != nil {
-		return "", err
+	return GetClientIP(headerValue, netip.Addr{}, nil)
+}
+
+// GetClientIP 从代理请求头中取出客户端 IP。
+// 未配置可信代理时取最后一项；否则仅当直连的 peer 为可信代理时才使用请求头，
+// 并从右向左跳过可信代理，第一个不可信的地址即为客户端，更左侧的内容可能由客户端伪造。
+func GetClientIP(headerValue string, peer netip.Addr, trusted []netip.Prefix) (string, error) {
+	if len(trusted) > 0 && peer.IsValid() && !prefixesContain(trusted, peer) {
+		return peer.Unmap().String(), nil
 	}
-	if !ip.IsValid() {
-		return "", errors.New("invalid ip")
+
+	entries := strings.Split(headerValue, ",")
+	if len(trusted) == 0 {
+		entries = entries[len(entries)-1:]
+	}
+	var ip netip.Addr
+	for i := len(entries) - 1; i >= 0; i-- {
+		addr, err := ParseHeaderIP(entries[i])
+		if err != nil {
+			return "", err
+		}
+		ip = addr
+		if !prefixesContain(trusted, addr) {
+			break
+		}
 	}
 	return ip.String(), nil
 }
 
+// ParseHeaderIP 解析请求头中的单个地址，支持带端口与方括号的形式，如 [2001:db8::1]:443
+func ParseHeaderIP(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		addrPort, perr := netip.ParseAddrPort(s)
+		if perr == nil {
+			addr, err = addrPort.Addr(), nil
+		} else if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+			addr, err = netip.ParseAddr(s[1 : len(s)-1])
+		}
+	}
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if !addr.IsValid() {
+		return netip.Addr{}, errors.New("invalid ip")
+	}
+	return addr.WithZone("").Unmap(), nil
+}
+
+// ParsePrefixList 解析逗号分隔的 IP 或 CIDR 列表
+func ParsePrefixList(s string) ([]netip.Prefix, error) {
+	var list []netip.Prefix
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		p, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ip or cidr %q", item)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		list = append(list, p.Masked())
+	}
+	return list, nil
+}
+
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // SplitIPAddr 传入/分割的v4v6混合地址，返回v4和v6地址与有效地址
 func SplitIPAddr(v4v6Bundle string) (string, string, string) {
 	ipList := strings.Split(v4v6Bundle, "/")
@@ -152,3 +222,49 @@ func MapValuesToSlice[Map ~map[K]V, K comparable, V any](m Map) []V {
 	s := make([]V, 0, len(m))
 	return slices.AppendSeq(s, maps.Values(m))
 }
+
+// ParseVersion 解析形如 v1.2.3 的版本号，忽略 - 或 + 之后的预发布与构建信息
+func ParseVersion(v string) ([]uint64, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil, false
+	}
+	parts := strings.Split(v, ".")
+	nums := make([]uint64, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		nums[i] = n
+	}
+	return nums, true
+}
+
+// CompareVersion 比较两个版本号，缺少的段视为 0，任一版本号无法解析时 ok 为 false
+func CompareVersion(a, b string) (result int, ok bool) {
+	va, ok := ParseVersion(a)
+	if !ok {
+		return 0, false
+	}
+	vb, ok := ParseVersion(b)
+	if !ok {
+		return 0, false
+	}
+	for i := 0; i < max(len(va), len(vb)); i++ {
+		var x, y uint64
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x != y {
+			return cmp.Compare(x, y), true
+		}
+	}
+	return 0, true
+}
@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"net/netip"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type testSt struct {
@@ -134,3 +136,149 @@ func TestBinaryToIPString(t *testing.T) {
 		}
 	}
 }
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 附录 B 测试向量（取后 6 位）
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, c := range cases {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(c.unix, 0)))
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if code != c.code {
+			t.Fatalf("Expected %s at %d, but got %s", c.code, c.unix, code)
+		}
+	}
+
+	now := time.Unix(1234567890, 0)
+	for _, offset := range []int64{-30, 0, 30} {
+		if _, ok := ValidateTOTP(secret, "005924", now.Add(time.Duration(offset)*time.Second)); !ok {
+			t.Fatalf("Expected code to be valid with offset %d", offset)
+		}
+	}
+	if _, ok := ValidateTOTP(secret, "005924", now.Add(90*time.Second)); ok {
+		t.Fatalf("Expected code to be rejected outside skew window")
+	}
+}
+
+func TestIPTrie(t *testing.T) {
+	var trie IPTrie
+	if !trie.Insert(netip.MustParsePrefix("10.0.0.0/24")) {
+		t.Fatal("expected insert of 10.0.0.0/24")
+	}
+	if trie.Insert(netip.MustParsePrefix("10.0.0.128/25")) {
+		t.Fatal("10.0.0.128/25 is covered by 10.0.0.0/24")
+	}
+	if !trie.Insert(netip.MustParsePrefix("2001:db8::/32")) {
+		t.Fatal("expected insert of 2001:db8::/32")
+	}
+
+	cases := map[string]bool{
+		"10.0.0.1":        true,
+		"10.0.0.255":      true,
+		"10.0.1.1":        false,
+		"::ffff:10.0.0.9": true,
+		"2001:db8::1":     true,
+		"2001:db9::1":     false,
+	}
+	for ip, expected := range cases {
+		if got := trie.Contains(netip.MustParseAddr(ip)); got != expected {
+			t.Errorf("Contains(%s) = %v, expected %v", ip, got, expected)
+		}
+	}
+
+	if !trie.Insert(netip.MustParsePrefix("10.0.0.0/8")) || !trie.Contains(netip.MustParseAddr("10.1.2.3")) {
+		t.Fatal("expected 10.0.0.0/8 to absorb 10.0.0.0/24")
+	}
+}
+
+func TestGetClientIP(t *testing.T) {
+	trusted, err := ParsePrefixList("10.0.0.0/8, 2001:db8::/32, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := netip.MustParseAddr("10.0.0.2")
+
+	cases := []struct {
+		name    string
+		header  string
+		peer    netip.Addr
+		trusted []netip.Prefix
+		want    string
+	}{
+		{"legacy takes last entry", "1.1.1.1, 10.0.0.1", proxy, nil, "10.0.0.1"},
+		{"two proxies", "203.0.113.7, 10.0.0.1", proxy, trusted, "203.0.113.7"},
+		{"spoofed entries left of client", "6.6.6.6, 7.7.7.7, 203.0.113.7, 10.0.0.1", proxy, trusted, "203.0.113.7"},
+		{"spoofed trusted entry from client", "10.9.9.9, 203.0.113.7, 192.0.2.1", proxy, trusted, "203.0.113.7"},
+		{"untrusted peer ignores header", "6.6.6.6", netip.MustParseAddr("198.51.100.1"), trusted, "198.51.100.1"},
+		{"untrusted peer without header", "", netip.MustParseAddr("198.51.100.1"), trusted, "198.51.100.1"},
+		{"all hops trusted", "10.0.0.5, 10.0.0.1", proxy, trusted, "10.0.0.5"},
+		{"ipv6 client", "2400:cb00::1, 2001:db8::10", netip.MustParseAddr("2001:db8::1"), trusted, "2400:cb00::1"},
+		{"bracketed ipv6 with port", "[2400:cb00::1]:51234, 10.0.0.1", proxy, trusted, "2400:cb00::1"},
+		{"bracketed ipv6", "[2400:cb00::1]", proxy, trusted, "2400:cb00::1"},
+		{"ipv4 with port", "203.0.113.7:8080", proxy, trusted, "203.0.113.7"},
+		{"ipv4-mapped ipv6", "::ffff:203.0.113.7", proxy, trusted, "203.0.113.7"},
+		{"mapped trusted peer", "203.0.113.7", netip.MustParseAddr("::ffff:10.0.0.2"), trusted, "203.0.113.7"},
+	}
+	for _, c := range cases {
+		got, err := GetClientIP(c.header, c.peer, c.trusted)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, got)
+		}
+	}
+
+	for _, header := range []string{"", "not-an-ip, 10.0.0.1", "203.0.113.7, unknown"} {
+		if ip, err := GetClientIP(header, proxy, trusted); err == nil {
+			t.Errorf("%q: expected error, got %s", header, ip)
+		}
+	}
+}
+
+func TestParsePrefixList(t *testing.T) {
+	if _, err := ParsePrefixList("10.0.0.0/8,bogus"); err == nil {
+		t.Fatal("expected error for invalid entry")
+	}
+	list, err := ParsePrefixList(" 10.1.2.3/8 ,, ::1 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	if !reflect.DeepEqual(list, want) {
+		t.Fatalf("expected %v, got %v", want, list)
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+		ok   bool
+	}{
+		{"v1.2.3", "1.2.3", 0, true},
+		{"1.10.0", "1.9.9", 1, true},
+		{"1.2", "1.2.1", -1, true},
+		{"v1.2.0-beta+abc", "1.2", 0, true},
+		{"", "1.0.0", 0, false},
+		{"dev", "1.0.0", 0, false},
+	}
+	for _, c := range cases {
+		got, ok := CompareVersion(c.a, c.b)
+		if got != c.want || ok != c.ok {
+			t.Errorf("CompareVersion(%q, %q) = %d, %v, want %d, %v", c.a, c.b, got, ok, c.want, c.ok)
+		}
+	}
+}
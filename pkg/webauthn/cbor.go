@@ -0,0 +1,147 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 仅实现 WebAuthn 用到的 CBOR 子集：整数、字节串、文本串、数组、映射与简单值
+
+const cborMaxDepth = 16
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// decodeCBOR 解码一个数据项，返回值与剩余字节
+// 映射解码为 map[any]any，键为 int64 或 string
+func decodeCBOR(data []byte) (any, []byte, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, data[d.pos:], nil
+}
+
+func (d *cborDecoder) head() (major byte, arg uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, errCBORTruncated
+	}
+	b := d.data[d.pos]
+	d.pos++
+	major, info := b>>5, b&0x1f
+
+	var n int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		n = 1
+	case info == 25:
+		n = 2
+	case info == 26:
+		n = 4
+	case info == 27:
+		n = 8
+	default:
+		return 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+	}
+	if len(d.data)-d.pos < n {
+		return 0, 0, errCBORTruncated
+	}
+	buf := make([]byte, 8)
+	copy(buf[8-n:], d.data[d.pos:d.pos+n])
+	d.pos += n
+	return major, binary.BigEndian.Uint64(buf), nil
+}
+
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	if uint64(len(d.data)-d.pos) < n {
+		return nil, errCBORTruncated
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *cborDecoder) value(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, errors.New("cbor: nesting too deep")
+	}
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		return d.bytes(arg)
+	case 3:
+		b, err := d.bytes(arg)
+		return string(b), err
+	case 4:
+		// 每个元素至少占 1 字节，避免按恶意长度分配内存
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errCBORTruncated
+		}
+		list := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case 5:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errCBORTruncated
+		}
+		m := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, errors.New("cbor: unsupported map key type")
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	case 6:
+		// 忽略标签，直接返回被标记的值
+		return d.value(depth + 1)
+	case 7:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("cbor: unsupported major type %d", major)
+}
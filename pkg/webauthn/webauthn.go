@@ -0,0 +1,258 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE 算法标识
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagAttestedData = 0x40
+)
+
+var (
+	ErrChallengeMismatch = errors.New("webauthn: challenge mismatch")
+	ErrOriginMismatch    = errors.New("webauthn: origin mismatch")
+	ErrRPIDMismatch      = errors.New("webauthn: relying party id mismatch")
+	ErrUserNotPresent    = errors.New("webauthn: user not present")
+	ErrInvalidSignature  = errors.New("webauthn: invalid signature")
+	// 签名计数未递增，凭据可能已被复制
+	ErrSignCount = errors.New("webauthn: signature counter did not increase")
+)
+
+// SupportedAlgorithms 注册时提供给浏览器的算法，按优先级排列
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// RelyingParty 依赖方，ID 为站点域名，Origin 为浏览器地址栏中的源（含协议与端口）
+type RelyingParty struct {
+	ID     string
+	Origin string
+}
+
+// Credential 注册成功的凭据，PublicKey 为 COSE 编码的公钥
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	// 以下字段仅在注册时存在
+	credentialID []byte
+	publicKey    []byte
+}
+
+// NewChallenge 生成 base64url 编码的随机挑战
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// VerifyRegistration 校验 navigator.credentials.create() 的结果。
+// 仅校验客户端数据与认证器数据，不校验证明声明，等同于 attestation 为 none。
+func (rp *RelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	obj, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := obj.(map[any]any)
+	if !ok {
+		return nil, errors.New("webauthn: invalid attestation object")
+	}
+	raw, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("webauthn: missing authenticator data")
+	}
+
+	ad, err := rp.parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+	if ad.flags&flagAttestedData == 0 {
+		return nil, errors.New("webauthn: missing attested credential data")
+	}
+	if _, err := parsePublicKey(ad.publicKey); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        ad.credentialID,
+		PublicKey: ad.publicKey,
+		SignCount: ad.signCount,
+	}, nil
+}
+
+// VerifyAssertion 校验 navigator.credentials.get() 的结果，返回新的签名计数
+func (rp *RelyingParty) VerifyAssertion(challenge string, cred *Credential, clientDataJSON, authData, signature []byte) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	ad, err := rp.parseAuthenticatorData(authData)
+	if err != nil {
+		return 0, err
+	}
+
+	pub, err := parsePublicKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(bytes.Clone(authData), clientDataHash[:]...)
+	if !verifySignature(pub, signed, signature) {
+		return 0, ErrInvalidSignature
+	}
+
+	// 不支持计数的认证器（如部分同步的通行密钥）始终返回 0
+	if (ad.signCount != 0 || cred.SignCount != 0) && ad.signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+	return ad.signCount, nil
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, typ, challenge string) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	if cd.Type != typ {
+		return fmt.Errorf("webauthn: unexpected client data type %q", cd.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return ErrChallengeMismatch
+	}
+	if cd.Origin != rp.Origin {
+		return ErrOriginMismatch
+	}
+	return nil
+}
+
+func (rp *RelyingParty) parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, errors.New("webauthn: authenticator data too short")
+	}
+	ad := &authenticatorData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(ad.rpIDHash, rpIDHash[:]) != 1 {
+		return nil, ErrRPIDMismatch
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return nil, ErrUserNotPresent
+	}
+
+	if ad.flags&flagAttestedData != 0 {
+		rest := raw[37:]
+		// AAGUID(16) + 凭据 ID 长度(2)
+		if len(rest) < 18 {
+			return nil, errors.New("webauthn: attested credential data too short")
+		}
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < n {
+			return nil, errors.New("webauthn: credential id truncated")
+		}
+		ad.credentialID = rest[:n]
+		rest = rest[n:]
+
+		_, remain, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, err
+		}
+		ad.publicKey = rest[:len(rest)-len(remain)]
+	}
+	return ad, nil
+}
+
+// parsePublicKey 解析 COSE_Key，支持 ES256、EdDSA（Ed25519）与 RS256
+func parsePublicKey(raw []byte) (crypto.PublicKey, error) {
+	v, _, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, errors.New("webauthn: invalid public key")
+	}
+	alg, _ := m[int64(3)].(int64)
+	switch alg {
+	case AlgES256:
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv, _ := m[int64(-1)].(int64); crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("webauthn: invalid EC2 public key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("webauthn: EC2 public key is not on curve")
+		}
+		return pub, nil
+	case AlgEdDSA:
+		x, _ := m[int64(-2)].([]byte)
+		if crv, _ := m[int64(-1)].(int64); crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("webauthn: invalid OKP public key")
+		}
+		return ed25519.PublicKey(x), nil
+	case AlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("webauthn: invalid RSA public key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return nil, fmt.Errorf("webauthn: unsupported algorithm %d", alg)
+}
+
+func verifySignature(pub crypto.PublicKey, data, sig []byte) bool {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, data, sig)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// cborHead 测试中用于构造认证器数据的最小编码
+func cborHead(major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	}
+}
+
+func cborInt(n int) []byte {
+	if n < 0 {
+		return cborHead(1, -1-n)
+	}
+	return cborHead(0, n)
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, len(b)), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, len(s)), s...)
+}
+
+type testAuthenticator struct {
+	key   *ecdsa.PrivateKey
+	id    []byte
+	count uint32
+}
+
+func (a *testAuthenticator) coseKey() []byte {
+	x, y := make([]byte, 32), make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	b := cborHead(5, 5)
+	b = append(b, cborInt(1)...)
+	b = append(b, cborInt(2)...) // kty: EC2
+	b = append(b, cborInt(3)...)
+	b = append(b, cborInt(AlgES256)...)
+	b = append(b, cborInt(-1)...)
+	b = append(b, cborInt(1)...) // crv: P-256
+	b = append(b, cborInt(-2)...)
+	b = append(b, cborBytes(x)...)
+	b = append(b, cborInt(-3)...)
+	b = append(b, cborBytes(y)...)
+	return b
+}
+
+func (a *testAuthenticator) authData(rpID string, attested bool) []byte {
+	h := sha256.Sum256([]byte(rpID))
+	flags := byte(flagUserPresent)
+	if attested {
+		flags |= flagAttestedData
+	}
+	b := append(h[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[33:], a.count)
+	if attested {
+		b = append(b, make([]byte, 16)...)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(a.id)))
+		b = append(b, a.id...)
+		b = append(b, a.coseKey()...)
+	}
+	return b
+}
+
+func clientDataJSON(typ, challenge, origin string) []byte {
+	b, _ := json.Marshal(clientData{Type: typ, Challenge: challenge, Origin: origin})
+	return b
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := &testAuthenticator{key: key, id: []byte("credential-1")}
+	rp := &RelyingParty{ID: "nezha.example", Origin: "https://nezha.example"}
+
+	challenge, _ := NewChallenge()
+	att := cborHead(5, 3)
+	att = append(att, cborText("fmt")...)
+	att = append(att, cborText("none")...)
+	att = append(att, cborText("attStmt")...)
+	att = append(att, cborHead(5, 0)...)
+	att = append(att, cborText("authData")...)
+	att = append(att, cborBytes(auth.authData(rp.ID, true))...)
+
+	cred, err := rp.VerifyRegistration(challenge, clientDataJSON("webauthn.create", challenge, rp.Origin), att)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cred.ID) != "credential-1" {
+		t.Fatalf("unexpected credential id %q", cred.ID)
+	}
+
+	if _, err := rp.VerifyRegistration(challenge, clientDataJSON("webauthn.create", challenge, "https://evil.example"), att); !errors.Is(err, ErrOriginMismatch) {
+		t.Fatalf("expected origin mismatch, got %v", err)
+	}
+	other := &RelyingParty{ID: "evil.example", Origin: rp.Origin}
+	if _, err := other.VerifyRegistration(challenge, clientDataJSON("webauthn.create", challenge, rp.Origin), att); !errors.Is(err, ErrRPIDMismatch) {
+		t.Fatalf("expected rp id mismatch, got %v", err)
+	}
+
+	assert := func(challenge string) (uint32, error) {
+		auth.count++
+		ad := auth.authData(rp.ID, false)
+		cd := clientDataJSON("webauthn.get", challenge, rp.Origin)
+		h := sha256.Sum256(cd)
+		digest := sha256.Sum256(append(ad, h[:]...))
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rp.VerifyAssertion(challenge, cred, cd, ad, sig)
+	}
+
+	challenge, _ = NewChallenge()
+	count, err := assert(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred.SignCount = count
+
+	cd := clientDataJSON("webauthn.get", challenge, rp.Origin)
+	if _, err := rp.VerifyAssertion(challenge, cred, cd, auth.authData(rp.ID, false), []byte{0x30, 0x00}); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+
+	auth.count = 0
+	if _, err := assert(challenge); !errors.Is(err, ErrSignCount) {
+		t.Fatalf("expected replayed counter to be rejected, got %v", err)
+	}
+}
+
+func TestDecodeCBORTruncated(t *testing.T) {
+	for _, data := range [][]byte{{0x5a, 0xff, 0xff, 0xff, 0xff}, {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, {0xa1}} {
+		if _, _, err := decodeCBOR(data); err == nil {
+			t.Fatalf("expected %x to fail", data)
+		}
+	}
+}
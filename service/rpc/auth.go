@@ -2,12 +2,16 @@ package rpc
 
 import (
 	"context"
+	"crypto/x509"
+	"log"
 	"strings"
 
 	petname "github.com/dustinkirkland/golang-petname"
 	"github.com/hashicorp/go-uuid"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	"github.com/nezhahq/nezha/model"
@@ -30,28 +34,62 @@ func (a *authHandler) Check(ctx context.Context) (uint64, error) {
 		clientSecret = strings.TrimSpace(value[0])
 	}
 
-	if clientSecret == "" {
-		return 0, status.Error(codes.Unauthenticated, "客户端认证失败")
+	var clientUUID string
+	if value, ok := md["client_uuid"]; ok {
+		clientUUID = value[0]
 	}
 
 	ip, _ := ctx.Value(model.CtxKeyRealIP{}).(string)
 
-	singleton.UserLock.RLock()
-	userId, ok := singleton.AgentSecretToUserId[clientSecret]
-	if !ok && clientSecret != singleton.Conf.AgentSecretKey {
-		singleton.UserLock.RUnlock()
+	// 出示了客户端证书时以证书认证，证书须与 Agent 上报的 UUID 对应
+	cert := peerCertificate(ctx)
+	if cert == nil && !singleton.Conf.AgentTLS.SecretAllowed() {
+		log.Printf("NEZHA>> Agent %s 认证失败：未出示客户端证书", ip)
+		return 0, status.Error(codes.Unauthenticated, "客户端认证失败")
+	}
+	if cert != nil && !strings.EqualFold(cert.Subject.CommonName, clientUUID) {
+		log.Printf("NEZHA>> Agent %s 认证失败：客户端证书 %s 与 UUID %s 不符", ip, cert.Subject.CommonName, clientUUID)
 		model.BlockIP(singleton.DB, ip, model.WAFBlockReasonTypeAgentAuthFail, model.BlockIDgRPC)
 		return 0, status.Error(codes.Unauthenticated, "客户端认证失败")
 	}
-	singleton.UserLock.RUnlock()
 
-	model.ClearIP(singleton.DB, ip, model.BlockIDgRPC)
+	if cert == nil && clientSecret == "" {
+		return 0, status.Error(codes.Unauthenticated, "客户端认证失败")
+	}
 
-	var clientUUID string
-	if value, ok := md["client_uuid"]; ok {
-		clientUUID = value[0]
+	singleton.UserLock.RLock()
+	userId, secretValid := singleton.AgentSecretToUserId[clientSecret]
+	secretValid = clientSecret != "" && (secretValid || clientSecret == singleton.Conf.AgentSecretKey)
+	singleton.UserLock.RUnlock()
+
+	// 以注册令牌连接的 Agent 在审批通过前不会加入服务器列表
+	var enrolled bool
+	if cert == nil && !secretValid && strings.HasPrefix(clientSecret, model.EnrollmentTokenPrefix) {
+		if _, err := uuid.ParseUUID(clientUUID); err != nil {
+			return 0, status.Error(codes.Unauthenticated, "客户端 UUID 不合法")
+		}
+		state, err := singleton.EnrollServer(clientUUID, clientSecret, ip)
+		if err != nil {
+			log.Printf("NEZHA>> Agent %s 注册失败：%v", ip, err)
+			return 0, status.Error(codes.Internal, "服务器注册失败")
+		}
+		switch state {
+		case model.ServerEnrollmentApproved:
+			enrolled = true
+		case model.ServerEnrollmentPending:
+			model.ClearIP(singleton.DB, ip, model.BlockIDgRPC)
+			return 0, status.Error(codes.PermissionDenied, "服务器正在等待管理员审批")
+		case model.ServerEnrollmentRejected:
+			return 0, status.Error(codes.PermissionDenied, "服务器注册申请已被拒绝")
+		}
+	}
+	if cert == nil && !secretValid && !enrolled {
+		model.BlockIP(singleton.DB, ip, model.WAFBlockReasonTypeAgentAuthFail, model.BlockIDgRPC)
+		return 0, status.Error(codes.Unauthenticated, "客户端认证失败")
 	}
 
+	model.ClearIP(singleton.DB, ip, model.BlockIDgRPC)
+
 	if _, err := uuid.ParseUUID(clientUUID); err != nil {
 		return 0, status.Error(codes.Unauthenticated, "客户端 UUID 不合法")
 	}
@@ -61,7 +99,16 @@ func (a *authHandler) Check(ctx context.Context) (uint64, error) {
 	singleton.ServerLock.RUnlock()
 
 	if !hasID {
-		s := model.Server{UUID: clientUUID, Name: petname.Generate(2, "-"), Common: model.Common{
+		// 回收站中的服务器需由管理员恢复，不随 Agent 重连自动恢复
+		if singleton.IsServerTrashed(clientUUID) {
+			return 0, status.Error(codes.PermissionDenied, "服务器已被删除，请先从回收站恢复")
+		}
+		// 证书不包含所属用户，新服务器需使用密钥完成首次连接
+		if !secretValid {
+			return 0, status.Error(codes.Unauthenticated, "新服务器需使用密钥完成首次连接")
+		}
+
+		s := model.Server{UUID: clientUUID, Name: petname.Generate(2, "-"), OwnerID: userId, Common: model.Common{
 			UserID: userId,
 		}}
 		if err := singleton.DB.Create(&s).Error; err != nil {
@@ -76,9 +123,23 @@ func (a *authHandler) Check(ctx context.Context) (uint64, error) {
 		singleton.ServerUUIDToID[clientUUID] = s.ID
 		singleton.ServerLock.Unlock()
 		singleton.ReSortServer()
+		singleton.NotifyNewServer(&s, ip, "")
 
 		clientID = s.ID
 	}
 
 	return clientID, nil
 }
+
+// peerCertificate 返回 Agent 在 TLS 握手中出示并已通过 CA 校验的客户端证书，未出示时返回 nil
+func peerCertificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return nil
+	}
+	return info.State.VerifiedChains[0][0]
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +13,8 @@ import (
 	"github.com/nezhahq/nezha/pkg/ddns"
 	geoipx "github.com/nezhahq/nezha/pkg/geoip"
 	"github.com/nezhahq/nezha/pkg/grpcx"
+	"github.com/nezhahq/nezha/pkg/utils"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/nezhahq/nezha/model"
 	pb "github.com/nezhahq/nezha/proto"
@@ -46,15 +49,32 @@ func (s *NezhaHandler) RequestTask(stream pb.NezhaService_RequestTaskServer) err
 	singleton.ServerLock.RLock()
 	singleton.ServerList[clientID].TaskStream = stream
 	singleton.ServerLock.RUnlock()
+	singleton.SetCommandAckSupport(clientID, agentHasFeature(stream.Context(), model.AgentFeatureCommandAck))
+	singleton.RedeliverCommands(clientID)
 
 	var result *pb.TaskResult
 	for {
 		result, err = stream.Recv()
 		if err != nil {
 			log.Printf("NEZHA>> RequestTask error: %v, clientID: %d\n", err, clientID)
+			singleton.AbortTaskOutputs(clientID)
 			return nil
 		}
-		if result.GetType() == model.TaskTypeCommand {
+		switch result.GetType() {
+		case model.TaskTypeCommandAck, model.TaskTypeCommandOutput, model.TaskTypeCommand, model.TaskTypeCommandWithEnv:
+			// 收到输出或结果同样说明已送达
+			singleton.AckCommand(clientID, result.GetId())
+		}
+		if result.GetType() == model.TaskTypeCommandAck {
+			continue
+		}
+		if result.GetType() == model.TaskTypeCommandOutput {
+			// 计划任务的实时输出
+			var out model.CommandOutput
+			if err := utils.Json.Unmarshal([]byte(result.GetData()), &out); err == nil {
+				singleton.PublishTaskOutput(clientID, result.GetId(), &out)
+			}
+		} else if result.GetType() == model.TaskTypeCommand || result.GetType() == model.TaskTypeCommandWithEnv {
 			// 处理上报的计划任务
 			singleton.CronLock.RLock()
 			cr := singleton.Crons[result.GetId()]
@@ -77,6 +97,7 @@ func (s *NezhaHandler) RequestTask(stream pb.NezhaService_RequestTaskServer) err
 					LastExecutedAt: time.Now().Add(time.Second * -1 * time.Duration(result.GetDelay())),
 					LastResult:     result.GetSuccessful(),
 				})
+				singleton.FinishCronRun(cr.ID, clientID, result.GetSuccessful(), result.GetData())
 			}
 		} else if model.IsServiceSentinelNeeded(result.GetType()) {
 			singleton.ServiceSentinelShared.Dispatch(singleton.ReportData{
@@ -87,12 +108,30 @@ func (s *NezhaHandler) RequestTask(stream pb.NezhaService_RequestTaskServer) err
 	}
 }
 
+// agentHasFeature 判断 Agent 是否在 metadata 中声明支持该功能，多个功能以逗号分隔
+func agentHasFeature(ctx context.Context, feature string) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	for _, value := range md.Get(model.AgentFeaturesMetadataKey) {
+		for _, f := range strings.Split(value, ",") {
+			if strings.TrimSpace(f) == feature {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (s *NezhaHandler) ReportSystemState(stream pb.NezhaService_ReportSystemStateServer) error {
 	var err error
 	var clientID uint64
 	if clientID, err = s.Auth.Check(stream.Context()); err != nil {
 		return err
 	}
+	singleton.OnAgentConnect(clientID)
+	defer singleton.OnAgentDisconnect(clientID)
 	var state *pb.State
 	for {
 		state, err = stream.Recv()
@@ -100,6 +139,7 @@ func (s *NezhaHandler) ReportSystemState(stream pb.NezhaService_ReportSystemStat
 			log.Printf("NEZHA>> ReportSystemState eror: %v, clientID: %d\n", err, clientID)
 			return nil
 		}
+		reported := state.GetCustomMetrics()
 		state := model.PB2State(state)
 
 		singleton.ServerLock.RLock()
@@ -109,13 +149,21 @@ func (s *NezhaHandler) ReportSystemState(stream pb.NezhaService_ReportSystemStat
 			return nil
 		}
 
-		singleton.ServerList[clientID].LastActive = time.Now()
+		now := time.Now()
+		// 自定义指标不必每次都上报，未上报的沿用上一次的值
+		var prevMetrics map[string]model.CustomMetric
+		if prev := singleton.ServerList[clientID].State; prev != nil {
+			prevMetrics = prev.CustomMetrics
+		}
+		state.CustomMetrics = model.MergeCustomMetrics(prevMetrics, reported, singleton.Conf.MaxCustomMetrics, now)
+		singleton.ServerList[clientID].LastActive = now
 		singleton.ServerList[clientID].State = &state
 		// 应对 dashboard 重启的情况，如果从未记录过，先打点，等到小时时间点时入库
 		if singleton.ServerList[clientID].PrevTransferInSnapshot == 0 || singleton.ServerList[clientID].PrevTransferOutSnapshot == 0 {
 			singleton.ServerList[clientID].PrevTransferInSnapshot = int64(state.NetInTransfer)
 			singleton.ServerList[clientID].PrevTransferOutSnapshot = int64(state.NetOutTransfer)
 		}
+		// 状态变化依靠缓存的有效期刷新，只有连接状态等结构性变更才使缓存失效
 		singleton.ServerLock.RUnlock()
 
 		stream.Send(&pb.Receipt{Proced: true})
@@ -143,6 +191,7 @@ func (s *NezhaHandler) onReportSystemInfo(c context.Context, r *pb.Host) error {
 	}
 
 	singleton.ServerList[clientID].Host = &host
+	singleton.OnAgentVersionReport(singleton.ServerList[clientID], host.Version)
 	return nil
 }
 
@@ -264,6 +313,7 @@ func (s *NezhaHandler) ReportGeoIP(c context.Context, r *pb.GeoIP) (*pb.GeoIP, e
 		log.Printf("NEZHA>> geoip.Lookup: %v", err)
 	}
 	geoip.CountryCode = location
+	geoip.Geo = geoipx.LookupLocation(ip)
 
 	// 将地区码写入到 Host
 	singleton.ServerLock.Lock()
@@ -0,0 +1,136 @@
+package singleton
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+type agentConnection struct {
+	streams        int // 当前的上报连接数
+	connectedAt    time.Time
+	disconnectedAt time.Time
+	carried        uint64 // 本轮已进行的重连次数，连接稳定后清零
+	conn           model.ServerConnection
+}
+
+var (
+	agentConnections     = make(map[uint64]*agentConnection)
+	agentConnectionsLock sync.Mutex
+)
+
+// OnAgentConnect Agent 建立状态上报连接时调用
+func OnAgentConnect(id uint64) {
+	now := time.Now()
+	agentConnectionsLock.Lock()
+	ac, ok := agentConnections[id]
+	if !ok {
+		ac = &agentConnection{}
+		agentConnections[id] = ac
+	}
+	ac.streams++
+	if ac.streams == 1 {
+		if !ac.disconnectedAt.IsZero() {
+			ac.carried, _ = model.EstimateReconnect(ac.carried, ac.disconnectedAt, now)
+		}
+		ac.connectedAt = now
+	}
+	changed := ac.update(now)
+	agentConnectionsLock.Unlock()
+
+	if changed {
+		InvalidateServerListCache()
+	}
+}
+
+// OnAgentDisconnect Agent 的状态上报连接断开时调用
+func OnAgentDisconnect(id uint64) {
+	now := time.Now()
+	agentConnectionsLock.Lock()
+	ac, ok := agentConnections[id]
+	if !ok || ac.streams == 0 {
+		agentConnectionsLock.Unlock()
+		return
+	}
+	ac.streams--
+	if ac.streams == 0 {
+		if now.Sub(ac.connectedAt) >= model.ReconnectStableAfter {
+			ac.carried = 0
+		}
+		ac.disconnectedAt = now
+	}
+	changed := ac.update(now)
+	agentConnectionsLock.Unlock()
+
+	if changed {
+		InvalidateServerListCache()
+	}
+}
+
+// CheckAgentConnections 按退避策略推进断开服务器的重连次数，并清零已稳定连接的状态
+func CheckAgentConnections() {
+	now := time.Now()
+	ServerLock.RLock()
+	agentConnectionsLock.Lock()
+	for id := range agentConnections {
+		if _, ok := ServerList[id]; !ok {
+			delete(agentConnections, id)
+		}
+	}
+	ServerLock.RUnlock()
+
+	var changed bool
+	for _, ac := range agentConnections {
+		if ac.update(now) {
+			changed = true
+		}
+	}
+	agentConnectionsLock.Unlock()
+
+	if changed {
+		InvalidateServerListCache()
+	}
+}
+
+// ServerConnectionOf 返回服务器的连接状态，面板启动后未连接过时返回 nil
+func ServerConnectionOf(id uint64) *model.ServerConnection {
+	agentConnectionsLock.Lock()
+	defer agentConnectionsLock.Unlock()
+
+	ac, ok := agentConnections[id]
+	if !ok {
+		return nil
+	}
+	conn := ac.conn
+	return &conn
+}
+
+// update 重新计算连接状态，返回状态或重连次数是否发生变化
+func (ac *agentConnection) update(now time.Time) bool {
+	var conn model.ServerConnection
+	if ac.streams > 0 {
+		if now.Sub(ac.connectedAt) >= model.ReconnectStableAfter {
+			ac.carried, ac.disconnectedAt = 0, time.Time{}
+		}
+		conn.State, conn.Attempts = model.ServerConnectionConnected, ac.carried
+	} else {
+		attempt, next := model.EstimateReconnect(ac.carried, ac.disconnectedAt, now)
+		disconnectedAt := ac.disconnectedAt
+		conn.Attempts, conn.DisconnectedAt = attempt, &disconnectedAt
+		if attempt > model.ReconnectMaxAttempts {
+			conn.State = model.ServerConnectionOffline
+		} else {
+			conn.State, conn.NextRetryAt = model.ServerConnectionReconnecting, &next
+		}
+	}
+
+	if conn.Equal(ac.conn) {
+		conn.ChangedAt = ac.conn.ChangedAt
+		ac.conn = conn
+		return false
+	}
+	conn.ChangedAt = now
+	ac.conn = conn
+	return true
+}
@@ -1,14 +1,18 @@
 package singleton
 
 import (
+	"cmp"
 	"fmt"
 	"log"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jinzhu/copier"
 
 	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
 )
 
 const (
@@ -26,11 +30,94 @@ type NotificationHistory struct {
 var (
 	AlertsLock                    sync.RWMutex
 	Alerts                        []*model.AlertRule
-	alertsStore                   map[uint64]map[uint64][][]bool       // [alert_id][server_id] -> 对应报警规则的检查结果
-	alertsPrevState               map[uint64]map[uint64]uint8          // [alert_id][server_id] -> 对应报警规则的上一次报警状态
-	AlertsCycleTransferStatsStore map[uint64]*model.CycleTransferStats // [alert_id] -> 对应报警规则的周期流量统计
+	alertsStore                   map[uint64]map[uint64][][]bool             // [alert_id][server_id] -> 对应报警规则的检查结果
+	alertsPrevState               map[uint64]map[uint64]uint8                // [alert_id][server_id] -> 对应报警规则的上一次报警状态
+	alertsSuppression             map[uint64]map[uint64]*alertSuppress       // [alert_id][server_id] -> 对应报警规则的防抖与冷却状态
+	alertsIncident                map[uint64]map[uint64]*model.AlertIncident // [alert_id][server_id] -> 对应报警规则未恢复的报警事件
+	AlertsCycleTransferStatsStore map[uint64]*model.CycleTransferStats       // [alert_id] -> 对应报警规则的周期流量统计
 )
 
+// alertSuppress 记录报警规则在单台服务器上的防抖与冷却状态
+type alertSuppress struct {
+	state        uint8     // 最近一次检查的结果
+	cycles       uint64    // 该结果已连续出现的检查次数
+	lastNotifyAt time.Time // 最近一次发送通知的时间
+	byParent     bool      // 报警是否正被父规则抑制
+}
+
+// observe 记录本次检查结果，返回该结果是否已持续足够的检查次数
+func (s *alertSuppress) observe(state uint8, debounce uint64) bool {
+	if s.state != state {
+		s.state = state
+		s.cycles = 0
+	}
+	s.cycles++
+	return s.cycles >= debounce
+}
+
+// coolingDown 距上次通知是否未满冷却时间
+func (s *alertSuppress) coolingDown(cooldown uint64, now time.Time) bool {
+	return cooldown > 0 && now.Before(s.lastNotifyAt.Add(time.Duration(cooldown)*time.Second))
+}
+
+func getAlertSuppress(alertID, serverID uint64) *alertSuppress {
+	if alertsSuppression[alertID] == nil {
+		alertsSuppression[alertID] = make(map[uint64]*alertSuppress)
+	}
+	s, ok := alertsSuppression[alertID][serverID]
+	if !ok {
+		s = &alertSuppress{}
+		alertsSuppression[alertID][serverID] = s
+	}
+	return s
+}
+
+// 防抖与冷却状态由报警器在持有 AlertsLock 读锁时修改，接口不能直接读取，
+// 只读取每轮检查后与报警规则变化后生成的快照
+var alertSuppressionSnapshot atomic.Pointer[[]*model.AlertSuppression]
+
+// GetAlertSuppressions 返回最近一轮检查时处于防抖等待、冷却中或被父规则抑制的报警规则
+func GetAlertSuppressions() []*model.AlertSuppression {
+	if list := alertSuppressionSnapshot.Load(); list != nil {
+		return *list
+	}
+	return nil
+}
+
+// publishAlertSuppressions 生成防抖与冷却状态的快照，只能由报警器或持有 AlertsLock 写锁时调用
+func publishAlertSuppressions(now time.Time) {
+	var list []*model.AlertSuppression
+	for _, alert := range Alerts {
+		for sid, s := range alertsSuppression[alert.ID] {
+			item := &model.AlertSuppression{
+				AlertID:        alert.ID,
+				ServerID:       sid,
+				Failing:        s.state == _RuleCheckFail,
+				PendingCycles:  s.cycles,
+				RequiredCycles: alert.Debounce,
+			}
+			prevFailed := alertsPrevState[alert.ID][sid] == _RuleCheckFail
+			switch {
+			case s.byParent:
+				item.State = model.AlertSuppressionParent
+				item.ParentID = alert.ParentID
+			case item.Failing != prevFailed && s.cycles < alert.Debounce:
+				item.State = model.AlertSuppressionPending
+			case item.Failing != prevFailed && s.coolingDown(alert.Cooldown, now):
+				item.State = model.AlertSuppressionCooldown
+				item.CooldownUntil = s.lastNotifyAt.Add(time.Duration(alert.Cooldown) * time.Second)
+			default:
+				continue
+			}
+			list = append(list, item)
+		}
+	}
+	slices.SortFunc(list, func(a, b *model.AlertSuppression) int {
+		return cmp.Or(cmp.Compare(a.AlertID, b.AlertID), cmp.Compare(a.ServerID, b.ServerID))
+	})
+	alertSuppressionSnapshot.Store(&list)
+}
+
 // addCycleTransferStatsInfo 向AlertsCycleTransferStatsStore中添加周期流量报警统计信息
 func addCycleTransferStatsInfo(alert *model.AlertRule) {
 	if !alert.Enabled() {
@@ -59,8 +146,12 @@ func addCycleTransferStatsInfo(alert *model.AlertRule) {
 
 // AlertSentinelStart 报警器启动
 func AlertSentinelStart() {
+	defer close(alertSentinelDone)
 	alertsStore = make(map[uint64]map[uint64][][]bool)
 	alertsPrevState = make(map[uint64]map[uint64]uint8)
+	alertsSuppression = make(map[uint64]map[uint64]*alertSuppress)
+	alertsIncident = make(map[uint64]map[uint64]*model.AlertIncident)
+	alertsEscalation = make(map[uint64]map[uint64]*alertEscalation)
 	AlertsCycleTransferStatsStore = make(map[uint64]*model.CycleTransferStats)
 	AlertsLock.Lock()
 	if err := DB.Find(&Alerts).Error; err != nil {
@@ -73,7 +164,11 @@ func AlertSentinelStart() {
 	}
 	AlertsLock.Unlock()
 
-	time.Sleep(time.Second * 10)
+	select {
+	case <-time.After(time.Second * 10):
+	case <-shuttingDown:
+		return
+	}
 	var lastPrint time.Time
 	var checkCount uint64
 	for {
@@ -87,22 +182,48 @@ func AlertSentinelStart() {
 			checkCount = 0
 			lastPrint = startedAt
 		}
-		time.Sleep(time.Until(startedAt.Add(time.Second * 3))) // 3秒钟检查一次
+		// 3秒钟检查一次，面板退出时在本轮检测完成后结束
+		select {
+		case <-time.After(time.Until(startedAt.Add(time.Second * 3))):
+		case <-shuttingDown:
+			return
+		}
 	}
 }
 
 func OnRefreshOrAddAlert(alert *model.AlertRule) {
 	AlertsLock.Lock()
 	defer AlertsLock.Unlock()
-	delete(alertsStore, alert.ID)
-	delete(alertsPrevState, alert.ID)
+	refreshOrAddAlert(alert)
+	publishAlertSuppressions(time.Now())
+}
+
+// OnRefreshOrAddAlerts 批量刷新报警规则，报警器只需等待一次锁
+func OnRefreshOrAddAlerts(alerts []*model.AlertRule) {
+	AlertsLock.Lock()
+	defer AlertsLock.Unlock()
+	for _, alert := range alerts {
+		refreshOrAddAlert(alert)
+	}
+	publishAlertSuppressions(time.Now())
+}
+
+func refreshOrAddAlert(alert *model.AlertRule) {
 	var isEdit bool
 	for i := 0; i < len(Alerts); i++ {
 		if Alerts[i].ID == alert.ID {
+			// 报警状态随规则修改重置，未恢复的报警在此结束
+			// 只有停用时发送恢复通知，修改后仍未恢复的报警会重新触发
+			reason := utils.IfOr(alert.Enabled(), "alert rule updated", "alert rule disabled")
+			closeAlertIncidents(Alerts[i], !alert.Enabled() && alert.NotifiesResolved(), Localizer.T(reason))
 			Alerts[i] = alert
 			isEdit = true
 		}
 	}
+	delete(alertsStore, alert.ID)
+	delete(alertsPrevState, alert.ID)
+	delete(alertsSuppression, alert.ID)
+	delete(alertsEscalation, alert.ID)
 	if !isEdit {
 		Alerts = append(Alerts, alert)
 	}
@@ -116,8 +237,15 @@ func OnDeleteAlert(id []uint64) {
 	AlertsLock.Lock()
 	defer AlertsLock.Unlock()
 	for _, i := range id {
+		for _, alert := range Alerts {
+			if alert.ID == i {
+				closeAlertIncidents(alert, alert.NotifiesResolved(), Localizer.T("alert rule deleted"))
+			}
+		}
 		delete(alertsStore, i)
 		delete(alertsPrevState, i)
+		delete(alertsSuppression, i)
+		delete(alertsEscalation, i)
 		currentAlerts := Alerts[:0]
 		for _, alert := range Alerts {
 			if alert.ID != i {
@@ -127,6 +255,87 @@ func OnDeleteAlert(id []uint64) {
 		Alerts = currentAlerts
 		delete(AlertsCycleTransferStatsStore, i)
 	}
+	publishAlertSuppressions(time.Now())
+}
+
+// closeAlertIncidents 结束报警规则所有未恢复的报警，notify 为 true 时发送恢复通知
+func closeAlertIncidents(alert *model.AlertRule, notify bool, reason string) {
+	incidents := alertsIncident[alert.ID]
+	delete(alertsIncident, alert.ID)
+	if len(incidents) == 0 {
+		return
+	}
+	now := time.Now()
+	ServerLock.RLock()
+	defer ServerLock.RUnlock()
+	for sid, incident := range incidents {
+		UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServerIncident(sid, alert.ID))
+		if !notify {
+			continue
+		}
+		server, ok := ServerList[sid]
+		if !ok {
+			resolveEscalation(alert.ID, sid)
+			continue
+		}
+		incident.ResolvedAt = now
+		var curServer model.Server
+		copier.Copy(&curServer, server)
+		sendResolvedNotification(alert, &curServer, incident, reason)
+	}
+}
+
+// onServerDeleteAlerts 服务器删除后丢弃其报警状态，未恢复的报警不再发送恢复通知
+func onServerDeleteAlerts(sid []uint64) {
+	AlertsLock.Lock()
+	defer AlertsLock.Unlock()
+	for _, id := range sid {
+		for alertID, incidents := range alertsIncident {
+			if _, ok := incidents[id]; ok {
+				for _, alert := range Alerts {
+					if alert.ID == alertID {
+						UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServerIncident(id, alertID))
+					}
+				}
+				delete(incidents, id)
+			}
+		}
+		for _, m := range alertsEscalation {
+			delete(m, id)
+		}
+		for _, m := range alertsStore {
+			delete(m, id)
+		}
+		for _, m := range alertsPrevState {
+			delete(m, id)
+		}
+		for _, m := range alertsSuppression {
+			delete(m, id)
+		}
+	}
+	publishAlertSuppressions(time.Now())
+}
+
+// sendResolvedNotification 发送报警恢复通知，附带报警持续时长与峰值，已升级通知过的通知组同样收到恢复通知
+func sendResolvedNotification(alert *model.AlertRule, server *model.Server, incident *model.AlertIncident, reason string) {
+	var ip string
+	if server.GeoIP != nil {
+		ip = server.GeoIP.IP.Join()
+	}
+	message := fmt.Sprintf("[%s] %s(%s) %s [%s] %s: %s", Localizer.T("Resolved"),
+		server.Name, IPDesensitize(ip), alert.Name, incident.ID,
+		Localizer.T("Duration"), incident.Duration(incident.ResolvedAt))
+	if peak := incident.DescribePeak(alert); peak != "" {
+		message += fmt.Sprintf(", %s: %s", Localizer.T("Peak"), peak)
+	}
+	if reason != "" {
+		message += fmt.Sprintf(" (%s)", reason)
+	}
+	alert = alert.WithRate(server.ID).WithIncident(incident)
+	go SendAlertNotification(alert, message, NotificationMuteLabel.ServerIncidentResolved(server.ID, alert.ID), server, true)
+	for _, gid := range resolveEscalation(alert.ID, server.ID) {
+		go sendNotification(gid, message, nil, server, alert, true)
+	}
 }
 
 // checkStatus 检查报警规则并发送报警
@@ -136,12 +345,19 @@ func checkStatus() {
 	ServerLock.RLock()
 	defer ServerLock.RUnlock()
 
-	for _, alert := range Alerts {
+	now := time.Now()
+	// 父规则先于子规则检查，记录本轮检查未通过的父规则供子规则判断是否抑制
+	failing := make(map[uint64]map[uint64]bool) // [alert_id][server_id] -> 本轮检查未通过
+	for _, alert := range model.SortAlertRulesByDependency(Alerts) {
 		// 跳过未启用
 		if !alert.Enabled() {
 			continue
 		}
 		for _, server := range ServerList {
+			// 跳过不在标签范围内、处于维护模式或报警规则所属用户无权访问的服务器
+			if !alert.Targets(server) || server.InMaintenance(now) || !ServerAccessibleBy(alert.UserID, server) {
+				continue
+			}
 			// 监测点
 			UserLock.RLock()
 			var role uint8
@@ -153,35 +369,86 @@ func checkStatus() {
 			UserLock.RUnlock()
 			alertsStore[alert.ID][server.ID] = append(alertsStore[alert.
 				ID][server.ID], alert.Snapshot(AlertsCycleTransferStatsStore[alert.ID], server, DB, role))
+			alertEvaluations.Add(1)
 			// 发送通知，分为触发报警和恢复通知
 			max, passed := alert.Check(alertsStore[alert.ID][server.ID])
+			incident := alertsIncident[alert.ID][server.ID]
+			if incident != nil {
+				incident.Observe(alert, server.ID)
+			}
 			// 保存当前服务器状态信息
 			curServer := model.Server{}
 			copier.Copy(&curServer, server)
 
+			// 防抖：状态变化需持续 Debounce 次检查；冷却：距上次通知需满 Cooldown 秒
+			// 被抑制时不更新上一次报警状态，以便持续的状态变化在抑制结束后立即通知
+			suppress := getAlertSuppress(alert.ID, server.ID)
+			var state uint8 = _RuleCheckPass
+			if !passed {
+				state = _RuleCheckFail
+			}
+			confirmed := suppress.observe(state, alert.Debounce) && !suppress.coolingDown(alert.Cooldown, time.Now())
+
+			// 父规则检查未通过时不发送报警与升级通知，也不更新上一次报警状态，父规则恢复后子规则仍未通过则立即报警
+			suppressed := !passed && alert.ParentID != 0 && failing[alert.ParentID][server.ID]
+			if suppressed && !suppress.byParent {
+				log.Printf("NEZHA>> 报警规则 %d(%s) 在服务器 %s 上的报警被父规则 %d 抑制", alert.ID, alert.Name, server.Name, alert.ParentID)
+			}
+			suppress.byParent = suppressed
+
 			// 本次未通过检查
 			if !passed {
-				// 始终触发模式或上次检查不为失败时触发报警（跳过单次触发+上次失败的情况）
-				if alert.TriggerMode == model.ModeAlwaysTrigger || alertsPrevState[alert.ID][server.ID] != _RuleCheckFail {
-					alertsPrevState[alert.ID][server.ID] = _RuleCheckFail
-					message := fmt.Sprintf("[%s] %s(%s) %s", Localizer.T("Incident"),
-						server.Name, IPDesensitize(server.GeoIP.IP.Join()), alert.Name)
-					go SendTriggerTasks(alert.FailTriggerTasks, curServer.ID)
-					go SendNotification(alert.NotificationGroupID, message, NotificationMuteLabel.ServerIncident(server.ID, alert.ID), &curServer)
-					// 清除恢复通知的静音缓存
-					UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServerIncidentResolved(server.ID, alert.ID))
+				if failing[alert.ID] == nil {
+					failing[alert.ID] = make(map[uint64]bool)
+				}
+				failing[alert.ID][server.ID] = true
+				if !suppressed {
+					// 始终触发模式或上次检查不为失败时触发报警（跳过单次触发+上次失败的情况）
+					if confirmed && (alert.TriggerMode == model.ModeAlwaysTrigger || alertsPrevState[alert.ID][server.ID] != _RuleCheckFail) {
+						alertsPrevState[alert.ID][server.ID] = _RuleCheckFail
+						suppress.lastNotifyAt = time.Now()
+						// 始终触发模式下持续报警沿用同一报警事件
+						if incident == nil {
+							incident = model.NewAlertIncident(alert.ID, server.ID, now)
+							incident.Observe(alert, server.ID)
+							if alertsIncident[alert.ID] == nil {
+								alertsIncident[alert.ID] = make(map[uint64]*model.AlertIncident)
+							}
+							alertsIncident[alert.ID][server.ID] = incident
+						}
+						message := fmt.Sprintf("[%s] %s(%s) %s [%s]", Localizer.T("Incident"),
+							server.Name, IPDesensitize(server.GeoIP.IP.Join()), alert.Name, incident.ID)
+						go SendTriggerTasks(alert.FailTriggerTasks, curServer.ID)
+						go SendAlertNotification(alert.WithRate(server.ID).WithIncident(incident), message, NotificationMuteLabel.ServerIncident(server.ID, alert.ID), &curServer, false)
+						// 清除恢复通知的静音缓存
+						UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServerIncidentResolved(server.ID, alert.ID))
+						startEscalation(alert, server.ID, now)
+					}
+					if alertsPrevState[alert.ID][server.ID] == _RuleCheckFail {
+						escalate(alert, &curServer, now)
+					}
 				}
 			} else {
 				// 本次通过检查但上一次的状态为失败，则发送恢复通知
-				if alertsPrevState[alert.ID][server.ID] == _RuleCheckFail {
-					message := fmt.Sprintf("[%s] %s(%s) %s", Localizer.T("Resolved"),
-						server.Name, IPDesensitize(server.GeoIP.IP.Join()), alert.Name)
+				if alertsPrevState[alert.ID][server.ID] == _RuleCheckFail && confirmed {
+					suppress.lastNotifyAt = time.Now()
 					go SendTriggerTasks(alert.RecoverTriggerTasks, curServer.ID)
-					go SendNotification(alert.NotificationGroupID, message, NotificationMuteLabel.ServerIncidentResolved(server.ID, alert.ID), &curServer)
+					delete(alertsIncident[alert.ID], server.ID)
+					if incident == nil {
+						incident = model.NewAlertIncident(alert.ID, server.ID, now)
+					}
+					incident.ResolvedAt = now
+					if alert.NotifiesResolved() {
+						sendResolvedNotification(alert, &curServer, incident, "")
+					} else {
+						resolveEscalation(alert.ID, server.ID)
+					}
 					// 清除失败通知的静音缓存
 					UnMuteNotification(alert.NotificationGroupID, NotificationMuteLabel.ServerIncident(server.ID, alert.ID))
 				}
-				alertsPrevState[alert.ID][server.ID] = _RuleCheckPass
+				if alertsPrevState[alert.ID][server.ID] != _RuleCheckFail || confirmed {
+					alertsPrevState[alert.ID][server.ID] = _RuleCheckPass
+				}
 			}
 			// 清理旧数据
 			if max > 0 && max < len(alertsStore[alert.ID][server.ID]) {
@@ -189,4 +456,5 @@ func checkStatus() {
 			}
 		}
 	}
+	publishAlertSuppressions(time.Now())
 }
@@ -0,0 +1,83 @@
+package singleton
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+var (
+	// auditLogLock 保证哈希链按写入顺序串联
+	auditLogLock sync.Mutex
+
+	errAuditLogBroken = errors.New("audit log hash chain broken")
+)
+
+// RecordAuditLog 记录一条管理操作，before 与 after 会序列化为 JSON 摘要，为 nil 时留空
+func RecordAuditLog(ctx context.Context, uid, impersonatedUID uint64, ip, action, target string, before, after any) {
+	entry := model.AuditLog{
+		UserID:             uid,
+		ImpersonatedUserID: impersonatedUID,
+		IP:                 ip,
+		Action:             action,
+		Target:             target,
+		Before:             auditSummary(before),
+		After:              auditSummary(after),
+	}
+
+	auditLogLock.Lock()
+	defer auditLogLock.Unlock()
+
+	var last model.AuditLog
+	if err := DB.Select("hash").Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+		Logger(ctx).Error("failed to save audit log", "action", action, "target", target, "error", err)
+		return
+	}
+	entry.PrevHash = last.Hash
+	entry.CreatedAt = time.Now().Truncate(time.Millisecond)
+	entry.Hash = entry.ComputeHash()
+
+	if err := DB.Create(&entry).Error; err != nil {
+		Logger(ctx).Error("failed to save audit log", "action", action, "target", target, "error", err)
+	}
+}
+
+// VerifyAuditLog 按顺序校验审计日志的哈希链，返回第一条校验失败的记录 ID，全部通过时返回 0
+func VerifyAuditLog() (uint64, error) {
+	var (
+		prev   string
+		broken uint64
+		logs   []model.AuditLog
+	)
+	err := DB.FindInBatches(&logs, 500, func(_ *gorm.DB, _ int) error {
+		for _, l := range logs {
+			if l.PrevHash != prev || l.Hash != l.ComputeHash() {
+				broken = l.ID
+				return errAuditLogBroken
+			}
+			prev = l.Hash
+		}
+		return nil
+	}).Error
+	if broken != 0 {
+		return broken, nil
+	}
+	return 0, err
+}
+
+func auditSummary(v any) string {
+	if v == nil {
+		return ""
+	}
+	b, err := utils.Json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,189 @@
+package singleton
+
+import (
+	"cmp"
+	"log"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+// 同一次执行最多下发的次数，超出后不再重新下发，等待超时
+const commandMaxDeliveryTries = 3
+
+const commandLostOutput = "command not acknowledged by agent"
+
+// pendingCommand 已下发、尚未确认的一次计划任务执行
+type pendingCommand struct {
+	runID, cronID, serverID uint64
+	tries                   uint8
+	requestID               string // 手动执行时所属请求的关联 ID
+	timer                   *time.Timer
+}
+
+var (
+	pendingCommands    = make(map[uint64]*pendingCommand) // [RunID] -> *pendingCommand
+	commandAckAgents   = make(map[uint64]bool)            // [ServerID] -> 当前连接的 Agent 是否支持确认
+	pendingCommandLock sync.Mutex
+)
+
+func commandAckTimeout() time.Duration {
+	return time.Duration(Conf.CommandAckTimeout) * time.Second
+}
+
+// SetCommandAckSupport 记录 Agent 本次连接是否声明支持计划任务确认，只跟踪支持确认的 Agent 的投递状态
+func SetCommandAckSupport(serverID uint64, supported bool) {
+	pendingCommandLock.Lock()
+	defer pendingCommandLock.Unlock()
+	if supported {
+		commandAckAgents[serverID] = true
+	} else {
+		delete(commandAckAgents, serverID)
+	}
+}
+
+// CommandAckSupported 判断服务器当前连接的 Agent 是否支持计划任务确认
+func CommandAckSupported(serverID uint64) bool {
+	pendingCommandLock.Lock()
+	defer pendingCommandLock.Unlock()
+	return commandAckAgents[serverID]
+}
+
+// trackCommand 等待 Agent 确认，超时仍未确认时标记为丢失，调用方需持有 pendingCommandLock
+func trackCommand(runID, cronID, serverID uint64, tries uint8, requestID string) *pendingCommand {
+	if old, ok := pendingCommands[runID]; ok {
+		old.timer.Stop()
+	}
+	pc := &pendingCommand{runID: runID, cronID: cronID, serverID: serverID, tries: tries, requestID: requestID}
+	pc.timer = time.AfterFunc(commandAckTimeout(), func() {
+		expireCommand(pc)
+	})
+	pendingCommands[runID] = pc
+	return pc
+}
+
+// logger 返回记录该次执行及其所属请求关联 ID 的 logger
+func (pc *pendingCommand) logger() *slog.Logger {
+	l := slog.Default().With("run_id", pc.runID, "cron_id", pc.cronID, "server_id", pc.serverID)
+	if pc.requestID != "" {
+		l = l.With("request_id", pc.requestID)
+	}
+	return l
+}
+
+// loadPendingCommands 面板重启后继续等待重启前下发的执行，Agent 重连后会重新下发
+func loadPendingCommands() {
+	var runs []model.CronHistory
+	if err := DB.Select("id", "cron_id", "server_id", "delivery_tries", "request_id").
+		Where("delivery = ?", model.CommandDeliveryPending).Find(&runs).Error; err != nil {
+		log.Printf("NEZHA>> failed to load pending commands: %v", err)
+		return
+	}
+	pendingCommandLock.Lock()
+	defer pendingCommandLock.Unlock()
+	for _, h := range runs {
+		trackCommand(h.ID, h.CronID, h.ServerID, h.DeliveryTries, h.RequestID)
+	}
+}
+
+// expireCommand 超时未确认，执行标记为失败
+func expireCommand(pc *pendingCommand) {
+	pendingCommandLock.Lock()
+	// 已确认或已重新下发
+	if pendingCommands[pc.runID] != pc {
+		pendingCommandLock.Unlock()
+		return
+	}
+	delete(pendingCommands, pc.runID)
+	pendingCommandLock.Unlock()
+	pc.logger().Warn("cron task not acknowledged by agent", "tries", pc.tries)
+
+	now := time.Now()
+	if err := DB.Model(&model.CronHistory{}).Where("id = ? AND delivery = ?", pc.runID, model.CommandDeliveryPending).Updates(map[string]any{
+		"delivery": model.CommandDeliveryLost,
+		"status":   model.CronRunStatusFailure,
+		"ended_at": now,
+		"output":   commandLostOutput,
+	}).Error; err != nil {
+		log.Printf("NEZHA>> failed to save cron run %d: %v", pc.runID, err)
+	}
+	finishTaskOutput(pc.runID, &model.TaskOutputEvent{Status: model.CronRunStatusFailure, Error: commandLostOutput})
+}
+
+// AckCommand 服务器确认收到计划任务，对应最早一条尚未确认的执行
+func AckCommand(serverID, cronID uint64) {
+	pendingCommandLock.Lock()
+	var found *pendingCommand
+	for _, pc := range pendingCommands {
+		if pc.cronID == cronID && pc.serverID == serverID && (found == nil || pc.runID < found.runID) {
+			found = pc
+		}
+	}
+	if found == nil {
+		pendingCommandLock.Unlock()
+		return
+	}
+	found.timer.Stop()
+	delete(pendingCommands, found.runID)
+	pendingCommandLock.Unlock()
+
+	if err := DB.Model(&model.CronHistory{}).Where("id = ? AND delivery = ?", found.runID, model.CommandDeliveryPending).Updates(map[string]any{
+		"delivery":     model.CommandDeliveryDelivered,
+		"delivered_at": time.Now(),
+	}).Error; err != nil {
+		log.Printf("NEZHA>> failed to save cron run %d: %v", found.runID, err)
+	}
+}
+
+// RedeliverCommands Agent 重连后重新下发开启了重新下发的计划任务尚未确认的执行，并重新计算超时。
+// Agent 可能已收到任务但确认未送达，此时任务会被重复执行，未开启的执行等待超时
+func RedeliverCommands(serverID uint64) {
+	crons := make(map[uint64]*model.Cron)
+	CronLock.RLock()
+	for id, cr := range Crons {
+		if cr.RedeliverOnReconnect {
+			crons[id] = cr
+		}
+	}
+	CronLock.RUnlock()
+
+	pendingCommandLock.Lock()
+	var list []*pendingCommand
+	for _, pc := range pendingCommands {
+		// 计划任务已删除时不再下发
+		if _, ok := crons[pc.cronID]; ok && pc.serverID == serverID && pc.tries < commandMaxDeliveryTries {
+			list = append(list, pc)
+		}
+	}
+	for i, pc := range list {
+		list[i] = trackCommand(pc.runID, pc.cronID, pc.serverID, pc.tries+1, pc.requestID)
+	}
+	pendingCommandLock.Unlock()
+	if len(list) == 0 {
+		return
+	}
+	slices.SortFunc(list, func(a, b *pendingCommand) int {
+		return cmp.Compare(a.runID, b.runID)
+	})
+
+	ServerLock.RLock()
+	var stream pb.NezhaService_RequestTaskServer
+	if s, ok := ServerList[serverID]; ok {
+		stream = s.TaskStream
+	}
+	ServerLock.RUnlock()
+	if stream == nil {
+		return
+	}
+	for _, pc := range list {
+		if err := stream.Send(cronTask(crons[pc.cronID], serverID)); err != nil {
+			pc.logger().Warn("failed to redeliver cron task", "error", err)
+			continue
+		}
+		DB.Model(&model.CronHistory{}).Where("id = ?", pc.runID).Update("delivery_tries", pc.tries)
+	}
+}
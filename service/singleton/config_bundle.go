@@ -0,0 +1,444 @@
+package singleton
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+var errConfigDryRun = errors.New("config import dry run")
+
+// ExportConfigBundle 导出用户可见的服务器、服务监控、报警规则、通知与定时任务
+func ExportConfigBundle(user *model.User, redact bool) (*model.ConfigBundle, error) {
+	b := &model.ConfigBundle{
+		Version:    model.ConfigBundleVersion,
+		ExportedAt: time.Now(),
+	}
+	scope := UserScope(user)
+
+	if err := DB.Scopes(scope).Order("id").Find(&b.Servers).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range b.Servers {
+		// DDNS 配置不在导出范围内
+		s.DDNSProfiles = nil
+	}
+	if err := DB.Scopes(scope).Order("id").Find(&b.Services).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Scopes(scope).Order("id").Find(&b.AlertRules).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Scopes(scope).Order("id").Find(&b.Notifications).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Scopes(scope).Order("id").Find(&b.Crons).Error; err != nil {
+		return nil, err
+	}
+	for _, cr := range b.Crons {
+		// 服务器密钥不在导出范围内
+		cr.SecretIDs = nil
+	}
+
+	var groups []model.NotificationGroup
+	if err := DB.Scopes(scope).Order("id").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	var members []model.NotificationGroupNotification
+	if err := DB.Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	groupMembers := make(map[uint64][]uint64)
+	for _, m := range members {
+		groupMembers[m.NotificationGroupID] = append(groupMembers[m.NotificationGroupID], m.NotificationID)
+	}
+	for _, g := range groups {
+		b.NotificationGroups = append(b.NotificationGroups, &model.NotificationGroupResponseItem{
+			Group:         g,
+			Notifications: groupMembers[g.ID],
+		})
+	}
+
+	if redact {
+		b.Redact()
+	}
+	return b, nil
+}
+
+// configImporter 在同一事务中导入配置包，并记录配置包 ID 到新 ID 的映射
+type configImporter struct {
+	ctx    context.Context
+	tx     *gorm.DB
+	user   *model.User
+	bundle *model.ConfigBundle
+	result *model.ConfigImportResult
+
+	servers       map[uint64]uint64
+	notifications map[uint64]uint64
+	groups        map[uint64]uint64
+	crons         map[uint64]uint64
+}
+
+// ImportConfigBundle 导入配置包，同名（服务器按 UUID）的对象会被更新，其余对象新建
+// 任一对象导入失败时整体回滚，dryRun 为 true 时只返回将要进行的变更
+func ImportConfigBundle(ctx context.Context, b *model.ConfigBundle, user *model.User, dryRun bool) (*model.ConfigImportResult, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	im := &configImporter{
+		ctx:           ctx,
+		user:          user,
+		bundle:        b,
+		result:        &model.ConfigImportResult{DryRun: dryRun},
+		servers:       make(map[uint64]uint64),
+		notifications: make(map[uint64]uint64),
+		groups:        make(map[uint64]uint64),
+		crons:         make(map[uint64]uint64),
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		im.tx = tx
+		for _, step := range []func() error{
+			im.importNotifications,
+			im.importNotificationGroups,
+			im.importServers,
+			im.importCrons,
+			im.importServices,
+			im.importAlertRules,
+		} {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+		if dryRun {
+			return errConfigDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errConfigDryRun) {
+		return nil, err
+	}
+
+	if !dryRun {
+		im.refresh()
+	}
+	return im.result, nil
+}
+
+// findExisting 在用户可见范围内查找已存在的对象
+func findExisting[T any](im *configImporter, dest *T, query string, args ...any) (bool, error) {
+	res := im.tx.Scopes(UserScope(im.user)).Where(query, args...).Limit(1).Find(dest)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (im *configImporter) record(kind, name string, updated bool, oldID, newID uint64) {
+	action := "create"
+	if updated {
+		action = "update"
+	}
+	im.result.Changes = append(im.result.Changes, &model.ConfigImportChange{
+		Kind:   kind,
+		Name:   name,
+		Action: action,
+		OldID:  oldID,
+		NewID:  newID,
+	})
+}
+
+// adopt 导入对象时沿用已有对象的 ID 与所有者，新对象归属于当前用户
+func (im *configImporter) adopt(c *model.Common, existing *model.Common, found bool) {
+	if found {
+		c.ID, c.UserID, c.CreatedAt = existing.ID, existing.UserID, existing.CreatedAt
+	} else {
+		c.ID, c.UserID, c.CreatedAt = 0, im.user.ID, time.Time{}
+	}
+}
+
+func remapID(m map[uint64]uint64, id uint64) uint64 {
+	if id == 0 {
+		return 0
+	}
+	return m[id]
+}
+
+func remapIDs(m map[uint64]uint64, ids []uint64) []uint64 {
+	out := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, remapID(m, id))
+	}
+	return out
+}
+
+func remapIDSet(m map[uint64]uint64, set map[uint64]bool) map[uint64]bool {
+	out := make(map[uint64]bool, len(set))
+	for id, v := range set {
+		out[remapID(m, id)] = v
+	}
+	return out
+}
+
+func (im *configImporter) importNotifications() error {
+	for _, n := range im.bundle.Notifications {
+		oldID := n.ID
+		var existing model.Notification
+		found, err := findExisting(im, &existing, "name = ?", n.Name)
+		if err != nil {
+			return err
+		}
+
+		// 被隐去的敏感字段沿用已有的值，新建时置空
+		restore := func(v *string, old string) {
+			if *v == model.RedactedValue {
+				*v = old
+			}
+		}
+		restore(&n.URL, existing.URL)
+		restore(&n.BotToken, existing.BotToken)
+		restore(&n.RequestHeader, existing.RequestHeader)
+		restore(&n.Secret, existing.Secret)
+		n.RestoreSecrets(&existing)
+		if !found && im.bundle.Redacted {
+			im.result.Warnings = append(im.result.Warnings, fmt.Sprintf("notification %s was exported with secrets redacted, please fill them in", n.Name))
+		}
+
+		im.adopt(&n.Common, &existing.Common, found)
+		if err := im.tx.Save(n).Error; err != nil {
+			return err
+		}
+		im.notifications[oldID] = n.ID
+		im.record("notification", n.Name, found, oldID, n.ID)
+	}
+	return nil
+}
+
+func (im *configImporter) importNotificationGroups() error {
+	for _, g := range im.bundle.NotificationGroups {
+		ng := &g.Group
+		oldID := ng.ID
+		var existing model.NotificationGroup
+		found, err := findExisting(im, &existing, "name = ?", ng.Name)
+		if err != nil {
+			return err
+		}
+
+		im.adopt(&ng.Common, &existing.Common, found)
+		if err := im.tx.Save(ng).Error; err != nil {
+			return err
+		}
+		if err := im.tx.Where("notification_group_id = ?", ng.ID).Delete(&model.NotificationGroupNotification{}).Error; err != nil {
+			return err
+		}
+		g.Notifications = remapIDs(im.notifications, g.Notifications)
+		slices.Sort(g.Notifications)
+		g.Notifications = slices.Compact(g.Notifications)
+		for _, nid := range g.Notifications {
+			if err := im.tx.Create(&model.NotificationGroupNotification{
+				Common:              model.Common{UserID: ng.UserID},
+				NotificationGroupID: ng.ID,
+				NotificationID:      nid,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		im.groups[oldID] = ng.ID
+		im.record("notification group", ng.Name, found, oldID, ng.ID)
+	}
+	return nil
+}
+
+func (im *configImporter) importServers() error {
+	for _, s := range im.bundle.Servers {
+		oldID := s.ID
+		var existing model.Server
+		// 回收站中的同一服务器随导入恢复
+		res := im.tx.Unscoped().Where("uuid = ?", s.UUID).Limit(1).Find(&existing)
+		if res.Error != nil {
+			return res.Error
+		}
+		found := res.RowsAffected > 0
+		if found && existing.UserID != im.user.ID && !UserAdministers(im.user.ID, existing.UserID) {
+			return fmt.Errorf("server %s: uuid %s belongs to another user", s.Name, s.UUID)
+		}
+
+		im.adopt(&s.Common, &existing.Common, found)
+		// DDNS 配置不随配置包迁移，更新时保留原有配置
+		s.EnableDDNS, s.DDNSProfiles = existing.EnableDDNS, existing.DDNSProfiles
+		s.DDNSProfilesRaw = existing.DDNSProfilesRaw
+		if s.DDNSProfilesRaw == "" {
+			s.DDNSProfilesRaw = "[]"
+		}
+		// Agent 版本以服务器实际上报为准
+		s.AgentVersion = utils.IfOr(found, existing.AgentVersion, model.UnknownAgentVersion)
+		if len(s.Note) > model.MaxServerNoteSize {
+			im.result.Warnings = append(im.result.Warnings, fmt.Sprintf("server %s: note exceeds the size limit and was truncated", s.Name))
+			s.Note = strings.ToValidUTF8(s.Note[:model.MaxServerNoteSize], "")
+		}
+		s.Tags = model.NormalizeTags(s.Tags)
+		tags, err := utils.Json.Marshal(s.Tags)
+		if err != nil {
+			return err
+		}
+		s.TagsRaw = string(tags)
+		s.DeletedAt = gorm.DeletedAt{}
+		if err := im.tx.Unscoped().Save(s).Error; err != nil {
+			return err
+		}
+		im.servers[oldID] = s.ID
+		im.record("server", s.Name, found, oldID, s.ID)
+	}
+	return nil
+}
+
+func (im *configImporter) importCrons() error {
+	for _, cr := range im.bundle.Crons {
+		oldID := cr.ID
+		var existing model.Cron
+		found, err := findExisting(im, &existing, "name = ?", cr.Name)
+		if err != nil {
+			return err
+		}
+
+		im.adopt(&cr.Common, &existing.Common, found)
+		cr.Servers = remapIDs(im.servers, cr.Servers)
+		// 服务器分组不在配置包内，仅保留目标面板中存在的分组
+		if len(cr.ServerGroups) > 0 {
+			var groups []uint64
+			if err := im.tx.Model(&model.ServerGroup{}).Where("id in (?)", cr.ServerGroups).Pluck("id", &groups).Error; err != nil {
+				return err
+			}
+			if len(groups) < len(cr.ServerGroups) {
+				im.result.Warnings = append(im.result.Warnings, fmt.Sprintf("cron %s references server groups that do not exist, they were removed", cr.Name))
+			}
+			cr.ServerGroups = groups
+		}
+		// 服务器密钥不在配置包内，无法对应到目标面板
+		if len(cr.SecretIDs) > 0 {
+			im.result.Warnings = append(im.result.Warnings, fmt.Sprintf("cron %s references server secrets, they were removed", cr.Name))
+			cr.SecretIDs = nil
+		}
+		cr.NotificationGroupID = remapID(im.groups, cr.NotificationGroupID)
+		cr.CronJobID = 0
+		if err := im.tx.Save(cr).Error; err != nil {
+			return err
+		}
+		im.crons[oldID] = cr.ID
+		im.record("cron", cr.Name, found, oldID, cr.ID)
+	}
+	return nil
+}
+
+func (im *configImporter) importServices() error {
+	for _, s := range im.bundle.Services {
+		oldID := s.ID
+		var existing model.Service
+		found, err := findExisting(im, &existing, "name = ?", s.Name)
+		if err != nil {
+			return err
+		}
+
+		// 被隐去的凭据沿用已有的值
+		s.RestoreSecrets(&existing)
+		im.adopt(&s.Common, &existing.Common, found)
+		s.SkipServers = remapIDSet(im.servers, s.SkipServers)
+		s.NotificationGroupID = remapID(im.groups, s.NotificationGroupID)
+		s.FailTriggerTasks = remapIDs(im.crons, s.FailTriggerTasks)
+		s.RecoverTriggerTasks = remapIDs(im.crons, s.RecoverTriggerTasks)
+		s.CronJobID = 0
+		if err := im.tx.Save(s).Error; err != nil {
+			return err
+		}
+		im.record("service", s.Name, found, oldID, s.ID)
+	}
+	return nil
+}
+
+func (im *configImporter) importAlertRules() error {
+	for _, r := range im.bundle.AlertRules {
+		oldID := r.ID
+		var existing model.AlertRule
+		found, err := findExisting(im, &existing, "name = ?", r.Name)
+		if err != nil {
+			return err
+		}
+
+		im.adopt(&r.Common, &existing.Common, found)
+		for _, rule := range r.Rules {
+			rule.Ignore = remapIDSet(im.servers, rule.Ignore)
+		}
+		r.NotificationGroupID = remapID(im.groups, r.NotificationGroupID)
+		r.FailTriggerTasks = remapIDs(im.crons, r.FailTriggerTasks)
+		r.RecoverTriggerTasks = remapIDs(im.crons, r.RecoverTriggerTasks)
+		// 升级策略不在配置包内，目标面板中不存在时取消关联
+		if r.EscalationPolicyID != 0 {
+			var count int64
+			if err := im.tx.Model(&model.EscalationPolicy{}).Where("id = ?", r.EscalationPolicyID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				im.result.Warnings = append(im.result.Warnings, fmt.Sprintf("alert rule %s references an escalation policy that does not exist, it was removed", r.Name))
+				r.EscalationPolicyID = 0
+			}
+		}
+		if err := im.tx.Save(r).Error; err != nil {
+			return err
+		}
+		im.record("alert rule", r.Name, found, oldID, r.ID)
+	}
+	return nil
+}
+
+// refresh 将导入的对象同步到内存
+func (im *configImporter) refresh() {
+	for _, n := range im.bundle.Notifications {
+		OnRefreshOrAddNotification(n)
+	}
+	for _, g := range im.bundle.NotificationGroups {
+		OnRefreshOrAddNotificationGroup(&g.Group, g.Notifications)
+	}
+
+	ServerLock.Lock()
+	for _, s := range im.bundle.Servers {
+		if old, ok := ServerList[s.ID]; ok {
+			s.CopyFromRunningServer(old)
+		} else {
+			s.Host = &model.Host{}
+			s.State = &model.HostState{}
+			s.GeoIP = new(model.GeoIP)
+		}
+		ServerList[s.ID] = s
+		ServerUUIDToID[s.UUID] = s.ID
+	}
+	ServerLock.Unlock()
+	ReSortServer()
+
+	for _, cr := range im.bundle.Crons {
+		if cr.TaskType == model.CronTypeCronTask {
+			var err error
+			if cr.CronJobID, err = Cron.AddFunc(cr.Spec(), CronTrigger(cr)); err != nil {
+				Logger(im.ctx).Error("failed to schedule imported cron", "cron", cr.Name, "error", err)
+			}
+		}
+		OnRefreshOrAddCron(cr)
+	}
+	UpdateCronList()
+
+	for _, s := range im.bundle.Services {
+		if err := ServiceSentinelShared.OnServiceUpdate(*s); err != nil {
+			Logger(im.ctx).Error("failed to schedule imported service", "service", s.Name, "error", err)
+		}
+	}
+	ServiceSentinelShared.UpdateServiceList()
+
+	for _, r := range im.bundle.AlertRules {
+		OnRefreshOrAddAlert(r)
+	}
+}
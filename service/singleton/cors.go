@@ -0,0 +1,31 @@
+package singleton
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+var corsPolicy atomic.Pointer[model.CORSPolicy]
+
+// OnCORSUpdate 解析并替换跨域策略，格式错误时保持原策略不变
+func OnCORSUpdate(c model.CORS) error {
+	p, err := c.Policy()
+	if err != nil {
+		return err
+	}
+	corsPolicy.Store(p)
+	return nil
+}
+
+func loadCORS() {
+	if err := OnCORSUpdate(Conf.CORS); err != nil {
+		log.Printf("NEZHA>> invalid cors: %v", err)
+	}
+}
+
+// CORSPolicy 当前生效的跨域策略，未加载时为空，此时仅允许同源访问
+func CORSPolicy() *model.CORSPolicy {
+	return corsPolicy.Load()
+}
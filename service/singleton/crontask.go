@@ -2,10 +2,13 @@ package singleton
 
 import (
 	"cmp"
+	"context"
 	"fmt"
+	"log"
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/jinzhu/copier"
 
@@ -24,6 +27,9 @@ var (
 	CronList []*model.Cron
 )
 
+// 超过该时长仍未上报结果的执行记录不再参与重叠判断
+const cronRunTimeout = 24 * time.Hour
+
 func InitCronTask() {
 	Cron = cron.New(cron.WithSeconds(), cron.WithLocation(Loc))
 	Crons = make(map[uint64]*model.Cron)
@@ -43,7 +49,7 @@ func loadCronTasks() {
 			continue
 		}
 		// 注册计划任务
-		cron.CronJobID, err = Cron.AddFunc(cron.Scheduler, CronTrigger(cron))
+		cron.CronJobID, err = Cron.AddFunc(cron.Spec(), CronTrigger(cron))
 		if err == nil {
 			Crons[cron.ID] = cron
 		} else {
@@ -98,8 +104,9 @@ func OnDeleteCron(id []uint64) {
 	}
 }
 
-func ManualTrigger(c *model.Cron) {
-	CronTrigger(c)()
+// ManualTrigger 立即执行一次计划任务，返回本次生成的执行记录 ID，ctx 为发起执行的请求
+func ManualTrigger(ctx context.Context, c *model.Cron) []uint64 {
+	return runCron(ctx, c, true)
 }
 
 func SendTriggerTasks(taskIDs []uint64, triggerServer uint64) {
@@ -119,55 +126,164 @@ func SendTriggerTasks(taskIDs []uint64, triggerServer uint64) {
 }
 
 func CronTrigger(cr *model.Cron, triggerServer ...uint64) func() {
-	crIgnoreMap := make(map[uint64]bool)
-	for j := 0; j < len(cr.Servers); j++ {
-		crIgnoreMap[cr.Servers[j]] = true
-	}
 	return func() {
-		if cr.Cover == model.CronCoverAlertTrigger {
-			if len(triggerServer) == 0 {
-				return
-			}
-			ServerLock.RLock()
-			defer ServerLock.RUnlock()
-			if s, ok := ServerList[triggerServer[0]]; ok {
-				if s.TaskStream != nil {
-					s.TaskStream.Send(&pb.Task{
-						Id:   cr.ID,
-						Data: cr.Command,
-						Type: model.TaskTypeCommand,
-					})
-				} else {
-					// 保存当前服务器状态信息
-					curServer := model.Server{}
-					copier.Copy(&curServer, s)
-					SendNotification(cr.NotificationGroupID, Localizer.Tf("[Task failed] %s: server %s is offline and cannot execute the task", cr.Name, s.Name), nil, &curServer)
-				}
+		runCron(context.Background(), cr, false, triggerServer...)
+	}
+}
+
+// runCron 向覆盖范围内的服务器下发计划任务，并为每台服务器记录一次执行
+func runCron(ctx context.Context, cr *model.Cron, manual bool, triggerServer ...uint64) []uint64 {
+	var targets []*model.Server
+	ServerLock.RLock()
+	if cr.Cover == model.CronCoverAlertTrigger {
+		if len(triggerServer) > 0 {
+			if s, ok := ServerList[triggerServer[0]]; ok && ServerAccessibleBy(cr.UserID, s) {
+				targets = append(targets, s)
 			}
-			return
 		}
-
-		ServerLock.RLock()
-		defer ServerLock.RUnlock()
+	} else {
 		for _, s := range ServerList {
-			if cr.Cover == model.CronCoverAll && crIgnoreMap[s.ID] {
+			// 成员的计划任务只在其可访问的服务器上执行
+			if !ServerAccessibleBy(cr.UserID, s) {
 				continue
 			}
-			if cr.Cover == model.CronCoverIgnoreAll && !crIgnoreMap[s.ID] {
+			listed := cronListsServer(cr, s.ID)
+			if cr.Cover == model.CronCoverAll && listed {
 				continue
 			}
-			if s.TaskStream != nil {
-				s.TaskStream.Send(&pb.Task{
-					Id:   cr.ID,
-					Data: cr.Command,
-					Type: model.TaskTypeCommand,
-				})
+			if cr.Cover == model.CronCoverIgnoreAll && !listed {
+				continue
+			}
+			targets = append(targets, s)
+		}
+	}
+
+	var runs []uint64
+	for _, s := range targets {
+		online := s.TaskStream != nil
+		tracked := online && CommandAckSupported(s.ID)
+		// 先记录执行再下发，以便接收任务开始后立即上报的输出
+		id := recordCronRun(ctx, cr, s.ID, online, tracked, manual)
+		if id != 0 {
+			runs = append(runs, id)
+		}
+		if online {
+			if id != 0 {
+				openTaskOutput(id, cr.ID, s.ID)
+			}
+			if id != 0 && tracked {
+				pendingCommandLock.Lock()
+				trackCommand(id, cr.ID, s.ID, 1, RequestID(ctx))
+				pendingCommandLock.Unlock()
+			}
+			// 发送失败时连接已断开，等待 Agent 重连后重新下发
+			if err := s.TaskStream.Send(cronTask(cr, s.ID)); err != nil {
+				Logger(ctx).Warn("failed to send cron task", "cron_id", cr.ID, "server_id", s.ID, "run_id", id, "error", err)
 			} else {
-				// 保存当前服务器状态信息
-				curServer := model.Server{}
-				copier.Copy(&curServer, s)
-				SendNotification(cr.NotificationGroupID, Localizer.Tf("[Task failed] %s: server %s is offline and cannot execute the task", cr.Name, s.Name), nil, &curServer)
+				Logger(ctx).Debug("cron task sent", "cron_id", cr.ID, "server_id", s.ID, "run_id", id)
 			}
+		} else {
+			// 保存当前服务器状态信息
+			curServer := model.Server{}
+			copier.Copy(&curServer, s)
+			SendNotification(cr.NotificationGroupID, Localizer.Tf("[Task failed] %s: server %s is offline and cannot execute the task", cr.Name, s.Name), nil, &curServer)
+		}
+	}
+	ServerLock.RUnlock()
+	return runs
+}
+
+// cronTask 生成下发给服务器的计划任务，仅包含属于该服务器的密钥
+func cronTask(cr *model.Cron, serverID uint64) *pb.Task {
+	task := &pb.Task{
+		Id:   cr.ID,
+		Data: cr.Command,
+		Type: model.TaskTypeCommand,
+	}
+	env, err := ResolveSecrets(serverID, cr.SecretIDs)
+	if err != nil {
+		log.Printf("NEZHA>> cron %d: failed to resolve secrets for server %d: %v", cr.ID, serverID, err)
+	}
+	if len(env) == 0 {
+		return task
+	}
+	data, _ := utils.Json.Marshal(&model.CommandTask{
+		Command: cr.Command,
+		Env:     env,
+	})
+	task.Data = string(data)
+	task.Type = model.TaskTypeCommandWithEnv
+	return task
+}
+
+// cronListsServer 服务器是否被计划任务直接指定，或属于其指定的分组
+func cronListsServer(cr *model.Cron, sid uint64) bool {
+	if slices.Contains(cr.Servers, sid) {
+		return true
+	}
+	return slices.ContainsFunc(cr.ServerGroups, func(gid uint64) bool {
+		return ServerInGroup(sid, gid)
+	})
+}
+
+// recordCronRun 记录一次计划任务执行，上一次执行尚未结束时标记为重叠，tracked 为 true 时等待 Agent 确认
+func recordCronRun(ctx context.Context, cr *model.Cron, serverID uint64, online, tracked, manual bool) uint64 {
+	now := time.Now()
+	h := model.CronHistory{
+		CronID:    cr.ID,
+		ServerID:  serverID,
+		Manual:    manual,
+		StartedAt: now,
+		RequestID: RequestID(ctx),
+	}
+	h.UserID = cr.UserID
+	if online {
+		var running int64
+		DB.Model(&model.CronHistory{}).Where("cron_id = ? AND server_id = ? AND status = ? AND started_at > ?",
+			cr.ID, serverID, model.CronRunStatusRunning, now.Add(-cronRunTimeout)).Count(&running)
+		h.Overlapped = running > 0
+		if tracked {
+			h.Delivery = model.CommandDeliveryPending
+			h.DeliveryTries = 1
 		}
+	} else {
+		h.Status = model.CronRunStatusOffline
+		h.EndedAt = &now
+	}
+	if err := DB.Create(&h).Error; err != nil {
+		Logger(ctx).Error("failed to record cron run", "cron_id", cr.ID, "server_id", serverID, "error", err)
+		return 0
+	}
+	return h.ID
+}
+
+// FinishCronRun 保存服务器上报的计划任务执行结果，对应最早一条仍在执行中的记录。
+// 没有执行中的记录时对应最早一条超时未确认的记录
+func FinishCronRun(cronID, serverID uint64, successful bool, output string) {
+	now := time.Now()
+	var h model.CronHistory
+	if err := DB.Where("cron_id = ? AND server_id = ? AND status = ?", cronID, serverID, model.CronRunStatusRunning).
+		Order("id").Limit(1).Find(&h).Error; err != nil {
+		return
+	}
+	if h.ID == 0 {
+		if err := DB.Where("cron_id = ? AND server_id = ? AND delivery = ? AND started_at > ?", cronID, serverID, model.CommandDeliveryLost, now.Add(-cronRunTimeout)).
+			Order("id").Limit(1).Find(&h).Error; err != nil || h.ID == 0 {
+			return
+		}
+	}
+	if h.Delivery != 0 && h.Delivery != model.CommandDeliveryDelivered {
+		h.Delivery = model.CommandDeliveryDelivered
+		h.DeliveredAt = &now
+	}
+	h.EndedAt = &now
+	h.Status = model.CronRunStatusFailure
+	if successful {
+		h.Status = model.CronRunStatusSuccess
+	}
+	h.SetOutput(output, Conf.CronOutputLimit)
+	if err := DB.Save(&h).Error; err != nil {
+		log.Printf("NEZHA>> failed to save cron run %d: %v", h.ID, err)
 	}
+	finishTaskOutput(h.ID, &model.TaskOutputEvent{Status: h.Status})
 }
@@ -0,0 +1,89 @@
+package singleton
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// customMetricsRecordedAt 最近一次记录自定义指标历史的时间，之后更新的指标才会被记录
+var customMetricsRecordedAt time.Time
+
+// loadCustomMetrics 以历史中最近的取值恢复自定义指标，面板重启后指标不会清空
+func loadCustomMetrics() {
+	var histories []model.CustomMetricHistory
+	if err := DB.Where("id IN (?)", DB.Model(&model.CustomMetricHistory{}).Select("MAX(id)").
+		Where("created_at > ?", time.Now().Add(-model.CustomMetricTTL)).Group("server_id, name")).
+		Find(&histories).Error; err != nil {
+		log.Printf("NEZHA>> 加载自定义指标失败: %v", err)
+		return
+	}
+
+	ServerLock.Lock()
+	defer ServerLock.Unlock()
+	for _, h := range histories {
+		server, ok := ServerList[h.ServerID]
+		if !ok {
+			continue
+		}
+		if server.State.CustomMetrics == nil {
+			server.State.CustomMetrics = make(map[string]model.CustomMetric)
+		}
+		if len(server.State.CustomMetrics) < Conf.MaxCustomMetrics {
+			server.State.CustomMetrics[h.Name] = model.CustomMetric{Value: h.Value, UpdatedAt: h.CreatedAt}
+		}
+	}
+	customMetricsRecordedAt = time.Now()
+}
+
+// RecordCustomMetrics 记录上次记录以来更新过的自定义指标
+func RecordCustomMetrics() {
+	now := time.Now()
+	var histories []model.CustomMetricHistory
+	ServerLock.RLock()
+	for id, server := range ServerList {
+		if server.State == nil {
+			continue
+		}
+		for name, m := range server.State.CustomMetrics {
+			if m.UpdatedAt.After(customMetricsRecordedAt) {
+				histories = append(histories, model.CustomMetricHistory{
+					CreatedAt: m.UpdatedAt,
+					ServerID:  id,
+					Name:      name,
+					Value:     m.Value,
+				})
+			}
+		}
+	}
+	ServerLock.RUnlock()
+	customMetricsRecordedAt = now
+
+	if len(histories) == 0 {
+		return
+	}
+	if err := DB.Create(&histories).Error; err != nil {
+		log.Printf("NEZHA>> 记录自定义指标失败: %v", err)
+	}
+}
+
+// CustomMetricNames 返回服务器当前的与保留期内记录过的自定义指标名称
+func CustomMetricNames(server *model.Server) ([]string, error) {
+	var names []string
+	if err := DB.Model(&model.CustomMetricHistory{}).Where("server_id = ?", server.ID).
+		Distinct().Pluck("name", &names).Error; err != nil {
+		return nil, err
+	}
+
+	ServerLock.RLock()
+	if server.State != nil {
+		names = append(names, slices.Collect(maps.Keys(server.State.CustomMetrics))...)
+	}
+	ServerLock.RUnlock()
+
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
@@ -0,0 +1,123 @@
+package singleton
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const (
+	pruneBatchSize = 1000
+	// 批次之间让出写锁，避免长时间阻塞 Agent 上报的写入
+	pruneBatchInterval = 50 * time.Millisecond
+)
+
+// historyTables 随时间增长、需要定期清理的表
+var historyTables = []any{
+	&model.ServiceHistory{}, &model.Transfer{}, &model.NotificationLog{}, &model.CronHistory{},
+	&model.LoginHistory{}, &model.AuditLog{}, &model.WAFAudit{}, &model.Annotation{},
+	&model.CustomMetricHistory{}, &model.NotificationDeadLetter{},
+}
+
+var (
+	lastPruned     map[string]int64 // [Table] -> 最近一次清理删除的行数
+	lastPrunedAt   time.Time
+	lastPrunedLock sync.RWMutex
+)
+
+// openDatabase 按配置返回数据库驱动，未配置时使用 path 处的 SQLite 数据库
+func openDatabase(path string) gorm.Dialector {
+	if Conf.Database.Type == model.DatabasePostgres {
+		return postgres.Open(Conf.Database.DSN)
+	}
+	return sqlite.Open(path)
+}
+
+// pruneInBatches 分批删除满足条件的记录，返回删除的行数
+func pruneInBatches(m any, query string, args ...any) int64 {
+	var total int64
+	for {
+		batch := DB.Model(m).Select("id").Where(query, args...).Limit(pruneBatchSize)
+		res := DB.Unscoped().Where("id IN (?)", batch).Delete(m)
+		if res.Error != nil {
+			log.Printf("NEZHA>> 清理 %s 失败: %v", tableName(m), res.Error)
+			return total
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < pruneBatchSize {
+			return total
+		}
+		time.Sleep(pruneBatchInterval)
+	}
+}
+
+func tableName(m any) string {
+	stmt := &gorm.Statement{DB: DB}
+	if err := stmt.Parse(m); err != nil {
+		return ""
+	}
+	return stmt.Schema.Table
+}
+
+func recordPruned(pruned map[string]int64) {
+	lastPrunedLock.Lock()
+	defer lastPrunedLock.Unlock()
+	lastPruned = pruned
+	lastPrunedAt = time.Now()
+}
+
+// GetDatabaseStats 返回数据库大小与各历史记录表的行数
+func GetDatabaseStats() (*model.DatabaseStats, error) {
+	size, freeSize, err := databaseSize()
+	if err != nil {
+		return nil, err
+	}
+
+	lastPrunedLock.RLock()
+	defer lastPrunedLock.RUnlock()
+
+	stats := &model.DatabaseStats{
+		Size:     size,
+		FreeSize: freeSize,
+		Tables:   make([]model.DatabaseTableStats, 0, len(historyTables)),
+	}
+	if !lastPrunedAt.IsZero() {
+		t := lastPrunedAt
+		stats.LastPrunedAt = &t
+	}
+	for _, m := range historyTables {
+		ts := model.DatabaseTableStats{Table: tableName(m)}
+		if err := DB.Model(m).Count(&ts.Rows).Error; err != nil {
+			return nil, err
+		}
+		ts.Pruned = lastPruned[ts.Table]
+		stats.Tables = append(stats.Tables, ts)
+	}
+	return stats, nil
+}
+
+// databaseSize 返回数据库占用的字节与其中空闲页的字节，PostgreSQL 不统计空闲页
+func databaseSize() (size, freeSize int64, err error) {
+	if DB.Dialector.Name() == model.DatabasePostgres {
+		err = DB.Raw("SELECT pg_database_size(current_database())").Scan(&size).Error
+		return size, 0, err
+	}
+
+	var pageCount, pageSize, freePages int64
+	if err := DB.Raw("PRAGMA page_count").Scan(&pageCount).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := DB.Raw("PRAGMA page_size").Scan(&pageSize).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := DB.Raw("PRAGMA freelist_count").Scan(&freePages).Error; err != nil {
+		return 0, 0, err
+	}
+	return pageCount * pageSize, freePages * pageSize, nil
+}
@@ -0,0 +1,60 @@
+package singleton
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// GetDebugStats 返回面板的运行状态，只读取运行时与连接池的计数，开销较小
+func GetDebugStats() (*model.DebugStats, error) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	stats := &model.DebugStats{
+		GoVersion:  runtime.Version(),
+		NumCPU:     runtime.NumCPU(),
+		Goroutines: runtime.NumGoroutine(),
+		Memory: model.DebugMemoryStats{
+			Sys:          m.Sys,
+			HeapAlloc:    m.HeapAlloc,
+			HeapInuse:    m.HeapInuse,
+			HeapIdle:     m.HeapIdle,
+			HeapReleased: m.HeapReleased,
+			HeapObjects:  m.HeapObjects,
+			StackInuse:   m.StackInuse,
+			TotalAlloc:   m.TotalAlloc,
+		},
+		GC: model.DebugGCStats{
+			NumGC:       m.NumGC,
+			NextGC:      m.NextGC,
+			PauseTotal:  float64(m.PauseTotalNs) / float64(time.Millisecond),
+			CPUFraction: m.GCCPUFraction,
+		},
+		WebSockets: GetWebSocketConnCount(),
+	}
+	if m.NumGC > 0 {
+		lastGC := time.Unix(0, int64(m.LastGC))
+		stats.GC.LastGC = &lastGC
+		// PauseNs 为环形缓冲区，最近一次 GC 位于 (NumGC+255)%256
+		stats.GC.LastPause = float64(m.PauseNs[(m.NumGC+255)%256]) / float64(time.Millisecond)
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	db := sqlDB.Stats()
+	stats.DB = model.DebugDBStats{
+		MaxOpenConnections: db.MaxOpenConnections,
+		OpenConnections:    db.OpenConnections,
+		InUse:              db.InUse,
+		Idle:               db.Idle,
+		WaitCount:          db.WaitCount,
+		WaitDuration:       db.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      db.MaxIdleClosed,
+		MaxLifetimeClosed:  db.MaxLifetimeClosed,
+	}
+	return stats, nil
+}
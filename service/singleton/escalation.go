@@ -0,0 +1,126 @@
+package singleton
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+var (
+	EscalationPolicyMap        map[uint64]*model.EscalationPolicy
+	EscalationPolicyListSorted []*model.EscalationPolicy
+	EscalationPolicyLock       sync.RWMutex
+
+	alertsEscalation map[uint64]map[uint64]*alertEscalation // [alert_id][server_id] -> 对应报警的升级进度，由 AlertsLock 保护
+)
+
+// alertEscalation 记录一次持续中的报警的升级进度，报警恢复后清除
+type alertEscalation struct {
+	firedAt  time.Time // 本次报警首次触发的时间
+	next     int       // 下一个待执行的升级级别
+	notified []uint64  // 已追加通知的通知组，恢复时一并通知
+}
+
+func loadEscalationPolicies() {
+	var policies []*model.EscalationPolicy
+	if err := DB.Find(&policies).Error; err != nil {
+		panic(err)
+	}
+
+	EscalationPolicyMap = make(map[uint64]*model.EscalationPolicy, len(policies))
+	for _, p := range policies {
+		EscalationPolicyMap[p.ID] = p
+	}
+	updateEscalationPolicyList()
+}
+
+func OnEscalationPolicyUpdate(p *model.EscalationPolicy) {
+	EscalationPolicyLock.Lock()
+	defer EscalationPolicyLock.Unlock()
+
+	EscalationPolicyMap[p.ID] = p
+	updateEscalationPolicyList()
+}
+
+func OnEscalationPolicyDelete(id []uint64) {
+	EscalationPolicyLock.Lock()
+	defer EscalationPolicyLock.Unlock()
+
+	for _, i := range id {
+		delete(EscalationPolicyMap, i)
+	}
+	updateEscalationPolicyList()
+}
+
+func updateEscalationPolicyList() {
+	EscalationPolicyListSorted = utils.MapValuesToSlice(EscalationPolicyMap)
+	slices.SortFunc(EscalationPolicyListSorted, func(a, b *model.EscalationPolicy) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+func getEscalationPolicy(id uint64) *model.EscalationPolicy {
+	EscalationPolicyLock.RLock()
+	defer EscalationPolicyLock.RUnlock()
+
+	return EscalationPolicyMap[id]
+}
+
+// startEscalation 报警触发时开始计时，持续报警期间重复触发不会重置进度
+func startEscalation(alert *model.AlertRule, serverID uint64, now time.Time) {
+	if alert.EscalationPolicyID == 0 {
+		return
+	}
+	if alertsEscalation[alert.ID] == nil {
+		alertsEscalation[alert.ID] = make(map[uint64]*alertEscalation)
+	}
+	if _, ok := alertsEscalation[alert.ID][serverID]; !ok {
+		alertsEscalation[alert.ID][serverID] = &alertEscalation{firedAt: now}
+	}
+}
+
+// escalate 报警持续期间按策略逐级追加通知
+func escalate(alert *model.AlertRule, server *model.Server, now time.Time) {
+	e := alertsEscalation[alert.ID][server.ID]
+	if e == nil {
+		return
+	}
+	policy := getEscalationPolicy(alert.EscalationPolicyID)
+	if policy == nil {
+		return
+	}
+	for e.next < len(policy.Steps) {
+		step := policy.Steps[e.next]
+		if now.Before(e.firedAt.Add(time.Duration(step.After) * time.Minute)) {
+			return
+		}
+		e.next++
+		if step.NotificationGroupID == alert.NotificationGroupID || slices.Contains(e.notified, step.NotificationGroupID) {
+			continue
+		}
+		e.notified = append(e.notified, step.NotificationGroupID)
+		message := fmt.Sprintf("[%s] %s(%s) %s, %s", Localizer.T("Escalated"),
+			server.Name, IPDesensitize(server.GeoIP.IP.Join()), alert.Name,
+			Localizer.Tf("still firing after %d minutes", step.After))
+		if Conf.Debug {
+			log.Printf("NEZHA>> 报警 %d 升级至通知组 %d", alert.ID, step.NotificationGroupID)
+		}
+		go sendNotification(step.NotificationGroupID, message, nil, server, alert, false)
+	}
+}
+
+// resolveEscalation 报警恢复时清除升级进度，返回已追加通知的通知组
+func resolveEscalation(alertID, serverID uint64) []uint64 {
+	e := alertsEscalation[alertID][serverID]
+	if e == nil {
+		return nil
+	}
+	delete(alertsEscalation[alertID], serverID)
+	return e.notified
+}
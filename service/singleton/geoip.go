@@ -0,0 +1,149 @@
+package singleton
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/geoip"
+)
+
+var (
+	WAFGeoRules map[uint8]map[string]bool // [Type][Value]
+	WAFGeoLock  sync.RWMutex
+)
+
+func initGeoIP() {
+	if err := geoip.SetDatabases(Conf.GeoIPCityDatabase, Conf.GeoIPASNDatabase); err != nil {
+		log.Printf("NEZHA>> 加载 GeoIP 数据库失败: %v", err)
+	}
+
+	var rules []model.WAFGeo
+	if err := DB.Find(&rules).Error; err != nil {
+		panic(err)
+	}
+	WAFGeoLock.Lock()
+	defer WAFGeoLock.Unlock()
+	WAFGeoRules = make(map[uint8]map[string]bool)
+	for _, r := range rules {
+		addWAFGeoRule(r.Type, r.Value)
+	}
+}
+
+func addWAFGeoRule(t uint8, value string) {
+	if WAFGeoRules[t] == nil {
+		WAFGeoRules[t] = make(map[string]bool)
+	}
+	WAFGeoRules[t][value] = true
+}
+
+// NormalizeWAFGeoValue 校验并规范化封禁规则的值
+func NormalizeWAFGeoValue(t uint8, value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch t {
+	case model.WAFGeoTypeCountry:
+		if len(value) != 2 {
+			return "", Localizer.ErrorT("invalid country code: %s", value)
+		}
+	case model.WAFGeoTypeASN:
+		value = strings.TrimPrefix(value, "as")
+		if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+			return "", Localizer.ErrorT("invalid ASN: %s", value)
+		}
+	default:
+		return "", Localizer.ErrorT("invalid block type")
+	}
+	return value, nil
+}
+
+// GeoValueOf 返回 IP 所在的国家代码或 ASN，查询不到时返回空字符串
+func GeoValueOf(t uint8, ip string) string {
+	loc := geoip.LookupLocation(ip)
+	if loc == nil {
+		return ""
+	}
+	switch t {
+	case model.WAFGeoTypeCountry:
+		return loc.CountryCode
+	case model.WAFGeoTypeASN:
+		if loc.ASN != 0 {
+			return strconv.FormatUint(uint64(loc.ASN), 10)
+		}
+	}
+	return ""
+}
+
+// CheckGeoBlock 检查 IP 所在的国家或 ASN 是否被封禁
+func CheckGeoBlock(ip string) error {
+	WAFGeoLock.RLock()
+	defer WAFGeoLock.RUnlock()
+
+	if ip == "" || len(WAFGeoRules) == 0 {
+		return nil
+	}
+	for t, values := range WAFGeoRules {
+		if v := GeoValueOf(t, ip); v != "" && values[v] {
+			return errors.New("you are blocked by nezha WAF")
+		}
+	}
+	return nil
+}
+
+// AddWAFGeoRules 添加国家或 ASN 封禁规则，并断开匹配的在线用户
+func AddWAFGeoRules(t uint8, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	rules := make([]model.WAFGeo, 0, len(values))
+	for _, v := range values {
+		rules = append(rules, model.WAFGeo{Type: t, Value: v})
+	}
+	if err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rules).Error; err != nil {
+		return err
+	}
+
+	WAFGeoLock.Lock()
+	for _, v := range values {
+		addWAFGeoRule(t, v)
+	}
+	WAFGeoLock.Unlock()
+
+	OnlineUserMapLock.Lock()
+	defer OnlineUserMapLock.Unlock()
+	for _, user := range OnlineUserMap {
+		if user.Conn != nil && CheckGeoBlock(user.IP) != nil {
+			user.Conn.Close()
+		}
+	}
+	return nil
+}
+
+// DeleteWAFGeoRules 删除国家或 ASN 封禁规则，返回被删除的规则
+func DeleteWAFGeoRules(ids []uint64) ([]model.WAFGeo, error) {
+	var rules []model.WAFGeo
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Find(&rules, "id in (?)", ids).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.WAFGeo{}, "id in (?)", ids).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	WAFGeoLock.Lock()
+	defer WAFGeoLock.Unlock()
+	for _, r := range rules {
+		delete(WAFGeoRules[r.Type], r.Value)
+		if len(WAFGeoRules[r.Type]) == 0 {
+			delete(WAFGeoRules, r.Type)
+		}
+	}
+	return rules, nil
+}
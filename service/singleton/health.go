@@ -0,0 +1,44 @@
+package singleton
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+var (
+	dashboardReady  atomic.Bool
+	agentListenerUp atomic.Bool
+)
+
+// SetReady 在启动完成后标记就绪，开始关闭时取消就绪，使负载均衡不再转发新请求
+func SetReady(ready bool) {
+	dashboardReady.Store(ready)
+}
+
+func SetAgentListenerUp(up bool) {
+	agentListenerUp.Store(up)
+}
+
+// CheckHealth 检查数据库连接并汇总就绪状态，Status 由调用方根据探针类型填写
+func CheckHealth(ctx context.Context) model.HealthStatus {
+	h := model.HealthStatus{
+		Listener: agentListenerUp.Load(),
+		Ready:    dashboardReady.Load(),
+	}
+	if DB != nil {
+		if sqlDB, err := DB.DB(); err == nil {
+			h.Database = sqlDB.PingContext(ctx) == nil
+		}
+	}
+
+	ServerLock.RLock()
+	for _, s := range ServerList {
+		if s.IsOnline(Conf.ServerOfflineTimeout) {
+			h.Agents++
+		}
+	}
+	ServerLock.RUnlock()
+	return h
+}
@@ -38,8 +38,16 @@ func loadTranslation() error {
 
 func OnUpdateLang(lang string) error {
 	lang = strings.Replace(lang, "-", "_", 1)
+	if err := loadLanguage(lang); err != nil {
+		return err
+	}
+	Localizer.SetLanguage(lang)
+	return nil
+}
+
+// loadLanguage 按需加载语言，不改变当前语言
+func loadLanguage(lang string) error {
 	if Localizer.Exists(lang) {
-		Localizer.SetLanguage(lang)
 		return nil
 	}
 
@@ -49,10 +57,21 @@ func OnUpdateLang(lang string) error {
 	}
 
 	Localizer.AppendIntl(lang, domain, domain+".zip", data)
-	Localizer.SetLanguage(lang)
 	return nil
 }
 
+// TranslateFor 将 msgid 翻译为指定语言，语言不可用时返回原文
+func TranslateFor(lang, msgid string) string {
+	lang = strings.Replace(lang, "-", "_", 1)
+	if _, ok := i18n.Languages[lang]; !ok {
+		return msgid
+	}
+	if err := loadLanguage(lang); err != nil {
+		return msgid
+	}
+	return Localizer.TL(lang, msgid)
+}
+
 func getTranslationArchive(lang string) ([]byte, error) {
 	files := [...]string{
 		fmt.Sprintf("translations/%s/LC_MESSAGES/%s.po", lang, domain),
@@ -0,0 +1,210 @@
+package singleton
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+type jwtKeySet struct {
+	current *model.JWTKey
+	keys    map[string]*model.JWTKey // 当前密钥与停用后仍在保留期内的密钥
+}
+
+var (
+	jwtKeys    atomic.Pointer[jwtKeySet]
+	jwtKeyLock sync.Mutex
+)
+
+func loadJWTKeys() {
+	if err := reloadJWTKeys(false); err != nil {
+		panic(err)
+	}
+}
+
+// RotateJWTKey 停用当前签名密钥并生成新密钥，停用的密钥签发的令牌在到期前仍然有效
+func RotateJWTKey() (*model.JWTKey, error) {
+	if err := reloadJWTKeys(true); err != nil {
+		return nil, err
+	}
+	return jwtKeys.Load().current, nil
+}
+
+// reloadJWTKeys 从数据库加载密钥，配置的算法与当前密钥不一致或要求轮换时生成新密钥。
+// 从未轮换过时使用配置文件中的 jwt_secret_key，与旧版本行为一致
+func reloadJWTKeys(rotate bool) error {
+	jwtKeyLock.Lock()
+	defer jwtKeyLock.Unlock()
+
+	if !model.ValidJWTAlgorithm(Conf.JWTAlgorithm) {
+		return fmt.Errorf("unsupported jwt_algorithm %q", Conf.JWTAlgorithm)
+	}
+
+	now := time.Now()
+	if err := DB.Where("kid <> ? AND retired_at < ?", model.JWTConfigKeyID, now.Add(-model.JWTKeyRetention)).
+		Delete(&model.JWTKey{}).Error; err != nil {
+		return err
+	}
+	var stored []*model.JWTKey
+	if err := DB.Find(&stored).Error; err != nil {
+		return err
+	}
+
+	// 配置文件中的密钥停用后才会入库，仅记录停用时间
+	configKey := &model.JWTKey{KID: model.JWTConfigKeyID, Algorithm: model.JWTAlgorithmHS256}
+	keys := []*model.JWTKey{configKey}
+	secretKey, secretKeyID := model.DeriveSecretKey(secretMasterKey())
+	for _, k := range stored {
+		if k.KID == model.JWTConfigKeyID {
+			configKey.Common, configKey.RetiredAt = k.Common, k.RetiredAt
+			continue
+		}
+		// 无法解密的密钥不再使用，停用后由新密钥代替
+		if err := openJWTKey(k, secretKey, secretKeyID); err != nil {
+			log.Printf("NEZHA>> failed to decrypt jwt key %s: %v", k.KID, err)
+			if k.RetiredAt == nil {
+				if err := DB.Model(&model.JWTKey{}).Where("id = ?", k.ID).Update("retired_at", now).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		keys = append(keys, k)
+	}
+	configKey.PrivateKey = Conf.JWTSecretKey
+
+	var current *model.JWTKey
+	for _, k := range keys {
+		if k.RetiredAt == nil {
+			current = k
+		}
+	}
+	if rotate || current == nil || current.Algorithm != Conf.JWTAlgorithm {
+		next, err := model.GenerateJWTKey(Conf.JWTAlgorithm)
+		if err != nil {
+			return err
+		}
+		ciphertext, err := model.EncryptSecret(secretKey, next.PrivateKey)
+		if err != nil {
+			return err
+		}
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			for _, k := range keys {
+				if k.RetiredAt != nil {
+					continue
+				}
+				k.RetiredAt = &now
+				if k == configKey {
+					row := model.JWTKey{Common: k.Common, KID: k.KID, Algorithm: k.Algorithm, RetiredAt: k.RetiredAt}
+					if err := tx.Save(&row).Error; err != nil {
+						return err
+					}
+					k.Common = row.Common
+					continue
+				}
+				if err := tx.Model(k).Update("retired_at", now).Error; err != nil {
+					return err
+				}
+			}
+			row := model.JWTKey{KID: next.KID, Algorithm: next.Algorithm, PrivateKey: ciphertext, KeyID: secretKeyID}
+			if err := tx.Create(&row).Error; err != nil {
+				return err
+			}
+			next.Common, next.KeyID = row.Common, row.KeyID
+			return nil
+		}); err != nil {
+			return err
+		}
+		keys, current = append(keys, next), next
+	}
+
+	set := &jwtKeySet{current: current, keys: make(map[string]*model.JWTKey)}
+	for _, k := range keys {
+		if k.RetiredAt != nil && now.Sub(*k.RetiredAt) > model.JWTKeyRetention {
+			continue
+		}
+		if err := k.Init(); err != nil {
+			return err
+		}
+		set.keys[k.KID] = k
+	}
+	jwtKeys.Store(set)
+	return nil
+}
+
+// openJWTKey 解密从数据库读取的私钥，旧版本以明文保存的私钥加密后写回
+func openJWTKey(k *model.JWTKey, key []byte, keyID string) error {
+	if k.KeyID == "" {
+		ciphertext, err := model.EncryptSecret(key, k.PrivateKey)
+		if err != nil {
+			return err
+		}
+		if err := DB.Model(&model.JWTKey{}).Where("id = ?", k.ID).Updates(map[string]any{"private_key": ciphertext, "key_id": keyID}).Error; err != nil {
+			log.Printf("NEZHA>> failed to encrypt jwt key %s: %v", k.KID, err)
+			return nil
+		}
+		k.KeyID = keyID
+		return nil
+	}
+	if k.KeyID != keyID {
+		return errors.New("encrypted with a different secrets key")
+	}
+	plaintext, err := model.DecryptSecret(key, k.PrivateKey)
+	if err != nil {
+		return err
+	}
+	k.PrivateKey = plaintext
+	return nil
+}
+
+// SignJWT 使用当前密钥签发令牌
+func SignJWT(claims jwt.MapClaims) (string, error) {
+	return jwtKeys.Load().current.Sign(claims)
+}
+
+// JWTVerifyKey 按令牌头部的 kid 查找校验密钥，不带 kid 的令牌由配置文件中的密钥签发
+func JWTVerifyKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = model.JWTConfigKeyID
+	}
+	k, ok := jwtKeys.Load().keys[kid]
+	if !ok || k.RetiredAt != nil && time.Since(*k.RetiredAt) > model.JWTKeyRetention {
+		return nil, fmt.Errorf("unknown or expired signing key %q", kid)
+	}
+	return k.VerifyKey(token.Method.Alg())
+}
+
+// JWTKeys 返回当前与保留期内的密钥
+func JWTKeys() []*model.JWTKey {
+	set := jwtKeys.Load()
+	keys := make([]*model.JWTKey, 0, len(set.keys))
+	for _, k := range set.keys {
+		keys = append(keys, k)
+	}
+	// 新密钥总是最后创建，未入库的配置文件密钥排在最后
+	slices.SortFunc(keys, func(a, b *model.JWTKey) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return keys
+}
+
+// JWKS 返回用于校验令牌的公钥集合，对称密钥不公开
+func JWKS() model.JWKSet {
+	set := model.JWKSet{Keys: []model.JWK{}}
+	for _, k := range JWTKeys() {
+		if jwk := k.JWK(); jwk != nil {
+			set.Keys = append(set.Keys, *jwk)
+		}
+	}
+	return set
+}
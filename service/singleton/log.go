@@ -0,0 +1,37 @@
+package singleton
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// InitLogger 按配置设置日志的级别与格式。
+// json 格式下标准库 log 的输出同样以 json 输出，级别为 info，配置的级别高于 info 时使用配置的级别，
+// 避免尚未迁移到 slog 的错误日志被全部丢弃；text 格式下保持原有的输出
+func InitLogger() {
+	level, _ := Conf.SlogLevel()
+	if Conf.LogFormat == model.LogFormatJSON {
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+		// SetDefault 之后此级别为标准库 log 输出转交给 slog 时使用的级别
+		slog.SetLogLoggerLevel(max(level, slog.LevelInfo))
+		return
+	}
+	slog.SetLogLoggerLevel(level)
+}
+
+// RequestID 返回 ctx 所属请求的关联 ID，不属于某个请求时返回空
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(model.CtxKeyRequestID{}).(string)
+	return id
+}
+
+// Logger 返回记录 ctx 中请求关联 ID 的 logger
+func Logger(ctx context.Context) *slog.Logger {
+	if id := RequestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
@@ -0,0 +1,77 @@
+package singleton
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 登录失败次数只记录在内存缓存中，避免每次尝试都写入数据库
+type loginFailure struct {
+	Count    int
+	ExpireAt time.Time
+}
+
+var loginFailureLock sync.Mutex
+
+func loginFailureKeys(username, ip string) []string {
+	keys := []string{"login-fail:user:" + username}
+	if ip != "" {
+		keys = append(keys, "login-fail:ip:"+ip)
+	}
+	return keys
+}
+
+// CheckLoginLocked 返回账户或来源 IP 剩余的锁定时间，未锁定时返回 0
+func CheckLoginLocked(username, ip string) time.Duration {
+	var remaining time.Duration
+	for _, key := range loginFailureKeys(username, ip) {
+		if v, ok := Cache.Get(key + ":locked"); ok {
+			if d := time.Until(v.(time.Time)); d > remaining {
+				remaining = d
+			}
+		}
+	}
+	return remaining
+}
+
+// RecordLoginFailure 记录一次登录失败，在窗口期内达到阈值后临时锁定账户与来源 IP
+func RecordLoginFailure(ctx context.Context, username, ip string) {
+	loginFailureLock.Lock()
+	defer loginFailureLock.Unlock()
+
+	now := time.Now()
+	window := time.Duration(Conf.LoginLockoutWindow) * time.Second
+	var ipLocked bool
+	for i, key := range loginFailureKeys(username, ip) {
+		f := loginFailure{ExpireAt: now.Add(window)}
+		if v, ok := Cache.Get(key); ok {
+			f = v.(loginFailure)
+		}
+		f.Count++
+
+		if f.Count < Conf.LoginLockoutThreshold {
+			Cache.Set(key, f, time.Until(f.ExpireAt))
+			continue
+		}
+
+		Cache.Delete(key)
+		Cache.Set(key+":locked", now.Add(window), window)
+		ipLocked = ipLocked || i > 0
+	}
+
+	if ipLocked {
+		// 同时交由 WAF 记录，锁定状态不会随内存缓存一起丢失
+		BlockByIPs(ctx, []string{ip}, window, 0)
+	}
+}
+
+// ResetLoginFailure 登录成功后清除失败记录
+func ResetLoginFailure(username, ip string) {
+	loginFailureLock.Lock()
+	defer loginFailureLock.Unlock()
+
+	for _, key := range loginFailureKeys(username, ip) {
+		Cache.Delete(key)
+	}
+}
@@ -0,0 +1,181 @@
+package singleton
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// 面板内部计数器，供 /metrics 输出
+var (
+	alertEvaluations     atomic.Uint64
+	notificationsSent    atomic.Uint64
+	notificationsFailed  atomic.Uint64
+	metricsBufferPool    = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	metricsScratchBuffer = sync.Pool{New: func() any { b := make([]byte, 0, 64); return &b }}
+)
+
+type serverMetric struct {
+	name  string
+	help  string
+	value func(*model.Server) float64
+}
+
+// serverMetrics 每台服务器输出的指标，服务器未上报过状态时跳过状态类指标
+var serverMetrics = []serverMetric{
+	{"nezha_server_cpu_usage_percent", "CPU usage of the server in percent.", func(s *model.Server) float64 { return s.State.CPU }},
+	{"nezha_server_memory_used_bytes", "Memory used by the server.", func(s *model.Server) float64 { return float64(s.State.MemUsed) }},
+	{"nezha_server_memory_total_bytes", "Total memory of the server.", func(s *model.Server) float64 { return float64(s.Host.MemTotal) }},
+	{"nezha_server_swap_used_bytes", "Swap used by the server.", func(s *model.Server) float64 { return float64(s.State.SwapUsed) }},
+	{"nezha_server_swap_total_bytes", "Total swap of the server.", func(s *model.Server) float64 { return float64(s.Host.SwapTotal) }},
+	{"nezha_server_disk_used_bytes", "Disk space used by the server.", func(s *model.Server) float64 { return float64(s.State.DiskUsed) }},
+	{"nezha_server_disk_total_bytes", "Total disk space of the server.", func(s *model.Server) float64 { return float64(s.Host.DiskTotal) }},
+	{"nezha_server_network_receive_bytes_per_second", "Inbound network speed of the server.", func(s *model.Server) float64 { return float64(s.State.NetInSpeed) }},
+	{"nezha_server_network_transmit_bytes_per_second", "Outbound network speed of the server.", func(s *model.Server) float64 { return float64(s.State.NetOutSpeed) }},
+	{"nezha_server_network_receive_bytes", "Inbound traffic reported by the server since boot.", func(s *model.Server) float64 { return float64(s.State.NetInTransfer) }},
+	{"nezha_server_network_transmit_bytes", "Outbound traffic reported by the server since boot.", func(s *model.Server) float64 { return float64(s.State.NetOutTransfer) }},
+	{"nezha_server_load1", "1 minute load average of the server.", func(s *model.Server) float64 { return s.State.Load1 }},
+	{"nezha_server_uptime_seconds", "Uptime of the server.", func(s *model.Server) float64 { return float64(s.State.Uptime) }},
+}
+
+// WriteMetrics 以 Prometheus 文本格式输出服务器状态与面板内部计数
+func WriteMetrics(buf *bytes.Buffer) {
+	scratch := metricsScratchBuffer.Get().(*[]byte)
+	defer metricsScratchBuffer.Put(scratch)
+
+	// 先复制服务器列表再读取分组，不同时持有两把锁
+	SortedServerLock.RLock()
+	servers := slices.Clone(SortedServerList)
+	SortedServerLock.RUnlock()
+
+	ServerGroupLock.RLock()
+	labels := make([]string, len(servers))
+	for i, s := range servers {
+		labels[i] = serverMetricLabels(s)
+	}
+	ServerGroupLock.RUnlock()
+
+	ServerLock.RLock()
+	writeMetricHeader(buf, "nezha_server_online", "Whether the server is reporting to the dashboard.", "gauge")
+	for i, s := range servers {
+		var online float64
+		if s.IsOnline(Conf.ServerOfflineTimeout) {
+			online = 1
+		}
+		writeMetricSample(buf, scratch, "nezha_server_online", labels[i], online)
+	}
+	for _, m := range serverMetrics {
+		writeMetricHeader(buf, m.name, m.help, "gauge")
+		for i, s := range servers {
+			if s.State == nil || s.Host == nil {
+				continue
+			}
+			writeMetricSample(buf, scratch, m.name, labels[i], m.value(s))
+		}
+	}
+	ServerLock.RUnlock()
+
+	writeMetricHeader(buf, "nezha_servers", "Number of servers registered in the dashboard.", "gauge")
+	writeMetricSample(buf, scratch, "nezha_servers", "", float64(len(servers)))
+
+	OnlineUserMapLock.Lock()
+	connections := len(OnlineUserMap)
+	OnlineUserMapLock.Unlock()
+	writeMetricHeader(buf, "nezha_websocket_connections", "Active websocket connections of the server status stream.", "gauge")
+	writeMetricSample(buf, scratch, "nezha_websocket_connections", "", float64(connections))
+
+	writeMetricHeader(buf, "nezha_websocket_active_connections", "Active websocket connections of all kinds, including terminals and file managers.", "gauge")
+	writeMetricSample(buf, scratch, "nezha_websocket_active_connections", "", float64(GetWebSocketConnCount()))
+
+	writeMetricHeader(buf, "nezha_websocket_rejected_total", "Websocket connections rejected by the connection limit.", "counter")
+	writeMetricSample(buf, scratch, "nezha_websocket_rejected_total", "", float64(wsRejected.Load()))
+
+	writeMetricHeader(buf, "nezha_alert_evaluations_total", "Alert rule evaluations, one per rule and server.", "counter")
+	writeMetricSample(buf, scratch, "nezha_alert_evaluations_total", "", float64(alertEvaluations.Load()))
+
+	writeMetricHeader(buf, "nezha_notifications_total", "Notifications sent by the dashboard.", "counter")
+	writeMetricSample(buf, scratch, "nezha_notifications_total", `result="success"`, float64(notificationsSent.Load()))
+	writeMetricSample(buf, scratch, "nezha_notifications_total", `result="failure"`, float64(notificationsFailed.Load()))
+
+	writeMetricHeader(buf, "nezha_server_list_cache_total", "Lookups of the cached server status stream payload.", "counter")
+	writeMetricSample(buf, scratch, "nezha_server_list_cache_total", `result="hit"`, float64(serverListCacheHits.Load()))
+	writeMetricSample(buf, scratch, "nezha_server_list_cache_total", `result="miss"`, float64(serverListCacheMisses.Load()))
+}
+
+// GetMetricsBuffer 从池中取出用于输出指标的缓冲区，使用后需调用 PutMetricsBuffer 归还
+func GetMetricsBuffer() *bytes.Buffer {
+	buf := metricsBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func PutMetricsBuffer(buf *bytes.Buffer) {
+	metricsBufferPool.Put(buf)
+}
+
+// serverMetricLabels 生成服务器的标签，多个分组名称按字典序以逗号连接
+func serverMetricLabels(s *model.Server) string {
+	var groups []string
+	for _, gid := range ServerGroupMembership[s.ID] {
+		if name, ok := ServerGroupNames[gid]; ok {
+			groups = append(groups, name)
+		}
+	}
+	slices.Sort(groups)
+
+	var sb strings.Builder
+	sb.WriteString(`server_id="`)
+	sb.WriteString(strconv.FormatUint(s.ID, 10))
+	sb.WriteString(`",server_name="`)
+	writeMetricLabelValue(&sb, s.Name)
+	sb.WriteString(`",group="`)
+	writeMetricLabelValue(&sb, strings.Join(groups, ","))
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// writeMetricLabelValue 按文本格式的要求转义反斜杠、双引号与换行
+func writeMetricLabelValue(sb *strings.Builder, v string) {
+	for _, r := range v {
+		switch r {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\n':
+			sb.WriteString(`\n`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
+
+func writeMetricHeader(buf *bytes.Buffer, name, help, typ string) {
+	buf.WriteString("# HELP ")
+	buf.WriteString(name)
+	buf.WriteByte(' ')
+	buf.WriteString(help)
+	buf.WriteString("\n# TYPE ")
+	buf.WriteString(name)
+	buf.WriteByte(' ')
+	buf.WriteString(typ)
+	buf.WriteByte('\n')
+}
+
+func writeMetricSample(buf *bytes.Buffer, scratch *[]byte, name, labels string, value float64) {
+	buf.WriteString(name)
+	if labels != "" {
+		buf.WriteByte('{')
+		buf.WriteString(labels)
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	*scratch = strconv.AppendFloat((*scratch)[:0], value, 'g', -1, 64)
+	buf.Write(*scratch)
+	buf.WriteByte('\n')
+}
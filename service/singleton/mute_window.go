@@ -0,0 +1,102 @@
+package singleton
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+var (
+	MuteWindowMap        map[uint64]*model.MuteWindow
+	MuteWindowListSorted []*model.MuteWindow
+	MuteWindowLock       sync.RWMutex
+)
+
+func loadMuteWindows() {
+	var windows []*model.MuteWindow
+	if err := DB.Find(&windows).Error; err != nil {
+		panic(err)
+	}
+
+	MuteWindowMap = make(map[uint64]*model.MuteWindow, len(windows))
+	for _, w := range windows {
+		if err := w.Validate(); err != nil {
+			log.Printf("NEZHA>> invalid mute window %d: %v", w.ID, err)
+		}
+		MuteWindowMap[w.ID] = w
+	}
+	updateMuteWindowList()
+}
+
+func OnMuteWindowUpdate(w *model.MuteWindow) {
+	MuteWindowLock.Lock()
+	defer MuteWindowLock.Unlock()
+
+	MuteWindowMap[w.ID] = w
+	updateMuteWindowList()
+}
+
+func OnMuteWindowDelete(id []uint64) {
+	MuteWindowLock.Lock()
+	defer MuteWindowLock.Unlock()
+
+	for _, i := range id {
+		delete(MuteWindowMap, i)
+	}
+	updateMuteWindowList()
+}
+
+func updateMuteWindowList() {
+	MuteWindowListSorted = utils.MapValuesToSlice(MuteWindowMap)
+	slices.SortFunc(MuteWindowListSorted, func(a, b *model.MuteWindow) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+// GetActiveMuteWindows 返回当前生效的静音窗口
+func GetActiveMuteWindows() []*model.MuteWindow {
+	MuteWindowLock.RLock()
+	defer MuteWindowLock.RUnlock()
+
+	now := time.Now()
+	var active []*model.MuteWindow
+	for _, w := range MuteWindowListSorted {
+		if w.Active(now) {
+			active = append(active, w)
+		}
+	}
+	return active
+}
+
+// findActiveMuteWindow 返回覆盖该通知组与服务器的生效中的静音窗口，
+// 只有通知组所有者本人或其管理员创建的窗口才会生效
+func findActiveMuteWindow(notificationGroupID, serverID uint64) *model.MuteWindow {
+	MuteWindowLock.RLock()
+	now := time.Now()
+	var matched []*model.MuteWindow
+	for _, w := range MuteWindowListSorted {
+		if w.Matches(notificationGroupID, serverID) && w.Active(now) {
+			matched = append(matched, w)
+		}
+	}
+	MuteWindowLock.RUnlock()
+	if len(matched) == 0 {
+		return nil
+	}
+
+	var ng model.NotificationGroup
+	if err := DB.Select("user_id").First(&ng, notificationGroupID).Error; err != nil {
+		return nil
+	}
+	for _, w := range matched {
+		if w.UserID == ng.UserID || UserAdministers(w.UserID, ng.UserID) {
+			return w
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"cmp"
 	"fmt"
 	"log"
+	"maps"
 	"slices"
 	"sync"
 	"time"
@@ -59,6 +60,16 @@ func loadNotifications() {
 		NotificationMap[NotificationListSorted[i].ID] = NotificationListSorted[i]
 	}
 
+	var recipients []model.NotificationRecipient
+	if err := DB.Order("id").Find(&recipients).Error; err != nil {
+		panic(err)
+	}
+	for _, r := range recipients {
+		if n, ok := NotificationMap[r.NotificationID]; ok {
+			n.Recipients = append(n.Recipients, r)
+		}
+	}
+
 	for gid, nids := range groupNotifications {
 		NotificationList[gid] = make(map[uint64]*model.Notification)
 		for _, nid := range nids {
@@ -161,8 +172,13 @@ func OnDeleteNotificationGroup(gids []uint64) {
 	}
 }
 
-// OnRefreshOrAddNotification 刷新通知方式相关参数
+// OnRefreshOrAddNotification 刷新通知方式相关参数，同时从数据库加载其接收方
 func OnRefreshOrAddNotification(n *model.Notification) {
+	n.Recipients = nil
+	if err := DB.Where("notification_id = ?", n.ID).Order("id").Find(&n.Recipients).Error; err != nil {
+		log.Printf("NEZHA>> 加载通知接收方失败：%v", err)
+	}
+
 	NotificationsLock.Lock()
 	defer NotificationsLock.Unlock()
 
@@ -218,6 +234,44 @@ func UnMuteNotification(notificationGroupID uint64, muteLabel *string) {
 
 // SendNotification 向指定的通知方式组的所有通知方式发送通知
 func SendNotification(notificationGroupID uint64, desc string, muteLabel *string, ext ...*model.Server) {
+	var server *model.Server
+	if len(ext) > 0 {
+		server = ext[0]
+	}
+	sendNotification(notificationGroupID, desc, muteLabel, server, nil, false)
+}
+
+// SendAlertNotification 发送报警规则触发或恢复的通知，通知模板中可使用报警规则相关的占位符。
+// 时间窗口内同一事件的通知会被合并为一条，见 AlertRule.DedupKey。
+func SendAlertNotification(alert *model.AlertRule, desc string, muteLabel *string, server *model.Server, resolved bool) {
+	if Conf.NotificationDedupWindow <= 0 || server == nil {
+		sendNotification(alert.NotificationGroupID, desc, muteLabel, server, alert, resolved)
+		return
+	}
+	dedupAlertNotification(&pendingNotification{
+		desc:      desc,
+		muteLabel: muteLabel,
+		server:    server,
+		alert:     alert,
+		resolved:  resolved,
+	}, time.Duration(Conf.NotificationDedupWindow)*time.Second)
+}
+
+func sendNotification(notificationGroupID uint64, desc string, muteLabel *string, server *model.Server, alert *model.AlertRule, resolved bool) {
+	if notificationMuted() {
+		log.Println("NEZHA>> 通知被全局静音抑制：", desc)
+		return
+	}
+
+	var serverID uint64
+	if server != nil {
+		serverID = server.ID
+	}
+	if w := findActiveMuteWindow(notificationGroupID, serverID); w != nil {
+		log.Printf("NEZHA>> 通知被静音窗口 %s 抑制：%s", w.Name, desc)
+		return
+	}
+
 	if muteLabel != nil {
 		// 将通知方式组名称加入静音标志
 		muteLabel := *NotificationMuteLabel.AppendNotificationGroupName(muteLabel, notificationGroupID)
@@ -254,25 +308,154 @@ func SendNotification(notificationGroupID uint64, desc string, muteLabel *string
 	}
 	// 向该通知方式组的所有通知方式发出通知
 	NotificationsLock.RLock()
-	defer NotificationsLock.RUnlock()
+	var deliveries []*notificationDelivery
 	for _, n := range NotificationList[notificationGroupID] {
+		// 报警通知只发送给接收该严重程度的通知方式
+		if alert != nil && !n.AcceptsSeverity(alert.GetSeverity()) {
+			continue
+		}
 		log.Println("NEZHA>> 尝试通知", n.Name)
+		deliveries = append(deliveries, newNotificationDelivery(n, 0, desc, server, alert, resolved))
+		// 每个接收方单独发送与重试，单个接收方发送失败不影响其余接收方
+		for _, r := range n.Recipients {
+			if r.Enabled {
+				deliveries = append(deliveries, newNotificationDelivery(n.WithRecipient(r.Value), r.ID, desc, server, alert, resolved))
+			}
+		}
 	}
-	for _, n := range NotificationList[notificationGroupID] {
-		ns := model.NotificationServerBundle{
-			Notification: n,
-			Server:       nil,
-			Loc:          Loc,
+	NotificationsLock.RUnlock()
+
+	for _, d := range deliveries {
+		enqueueNotification(d)
+	}
+}
+
+// NotificationBundle 创建使用面板时区与语言的通知发送上下文
+func NotificationBundle(n *model.Notification) model.NotificationServerBundle {
+	return model.NotificationServerBundle{
+		Notification:    n,
+		Loc:             Loc,
+		DefaultLanguage: Conf.Language,
+		Translate:       TranslateFor,
+	}
+}
+
+// notificationDelivery 向通知方式的一个接收方发送的一条通知
+type notificationDelivery struct {
+	ns          model.NotificationServerBundle
+	recipientID uint64 // 为 0 时表示主接收方
+	desc        string
+	attempt     int // 已尝试的次数
+}
+
+// newNotificationDelivery 创建一条通知，各次重试共用同一个 DeliveryID
+func newNotificationDelivery(n *model.Notification, recipientID uint64, desc string, server *model.Server, alert *model.AlertRule, resolved bool) *notificationDelivery {
+	ns := NotificationBundle(n)
+	ns.Server = server
+	ns.Alert = alert
+	ns.Resolved = resolved
+	ns.DeliveryID, _ = utils.GenerateRandomString(16)
+	return &notificationDelivery{ns: ns, recipientID: recipientID, desc: desc}
+}
+
+// send 尝试发送一次并记录结果
+func (d *notificationDelivery) send() model.NotificationDeliveryResult {
+	n := d.ns.Notification
+	d.attempt++
+	d.ns.StatusCode = 0
+	d.ns.DeliveryStatus = ""
+	name := n.Name
+	if d.recipientID != 0 {
+		name = fmt.Sprintf("%s#%d", n.Name, d.recipientID)
+	}
+	err := sendRecovered(&d.ns, d.desc)
+	if err != nil {
+		notificationsFailed.Add(1)
+		log.Println("NEZHA>> 向 ", name, " 发送通知失败：", err)
+	} else {
+		notificationsSent.Add(1)
+		log.Println("NEZHA>> 向 ", name, " 发送通知成功：")
+	}
+	recordNotificationLog(d, err)
+
+	result := model.NotificationDeliveryResult{
+		NotificationID:   n.ID,
+		NotificationName: n.Name,
+		RecipientID:      d.recipientID,
+		Success:          err == nil,
+		StatusCode:       d.ns.StatusCode,
+		DeliveryStatus:   d.ns.DeliveryStatus,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// sendRecovered 发送通知，发送过程中 panic 时视为发送失败，以免中断队列中其余通知的发送
+func sendRecovered(ns *model.NotificationServerBundle, message string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
 		}
-		if len(ext) > 0 {
-			ns.Server = ext[0]
+	}()
+	return ns.Send(message)
+}
+
+// TestAlertNotification 以测试标记向报警规则的通知组发送一条模拟通知，
+// 跳过静音窗口、防骚扰与合并策略，返回每个接收方的发送结果
+func TestAlertNotification(alert *model.AlertRule, server *model.Server, resolved bool) []model.NotificationDeliveryResult {
+	status := Localizer.T("Incident")
+	if resolved {
+		status = Localizer.T("Resolved")
+	}
+	var desc string
+	if server != nil {
+		desc = fmt.Sprintf("[%s][%s] %s(%s) %s", Localizer.T("Test"), status,
+			server.Name, IPDesensitize(server.GeoIP.IP.Join()), alert.Name)
+	} else {
+		desc = fmt.Sprintf("[%s][%s] %s", Localizer.T("Test"), status, alert.Name)
+	}
+
+	NotificationsLock.RLock()
+	defer NotificationsLock.RUnlock()
+	notifications := slices.SortedFunc(maps.Values(NotificationList[alert.NotificationGroupID]), func(a, b *model.Notification) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	results := make([]model.NotificationDeliveryResult, 0)
+	for _, n := range notifications {
+		if !n.AcceptsSeverity(alert.GetSeverity()) {
+			continue
 		}
-		if err := ns.Send(desc); err != nil {
-			log.Println("NEZHA>> 向 ", n.Name, " 发送通知失败：", err)
-		} else {
-			log.Println("NEZHA>> 向 ", n.Name, " 发送通知成功：")
+		results = append(results, newNotificationDelivery(n, 0, desc, server, alert, resolved).send())
+		for _, r := range n.Recipients {
+			if r.Enabled {
+				results = append(results, newNotificationDelivery(n.WithRecipient(r.Value), r.ID, desc, server, alert, resolved).send())
+			}
 		}
 	}
+	return results
+}
+
+// recordNotificationLog 记录通知每次尝试发送的结果
+func recordNotificationLog(d *notificationDelivery, sendErr error) {
+	nl := model.NotificationLog{
+		NotificationID: d.ns.Notification.ID,
+		RecipientID:    d.recipientID,
+		Success:        sendErr == nil,
+		StatusCode:     d.ns.StatusCode,
+		DeliveryStatus: d.ns.DeliveryStatus,
+		Message:        d.desc,
+		DeliveryID:     d.ns.DeliveryID,
+		Attempt:        d.attempt,
+	}
+	nl.UserID = d.ns.Notification.UserID
+	if sendErr != nil {
+		nl.Error = sendErr.Error()
+	}
+	if err := DB.Create(&nl).Error; err != nil {
+		log.Printf("NEZHA>> 保存通知记录失败：%v", err)
+	}
 }
 
 type _NotificationMuteLabel struct{}
@@ -284,6 +467,11 @@ func (_NotificationMuteLabel) IPChanged(serverId uint64) *string {
 	return &label
 }
 
+func (_NotificationMuteLabel) AgentOutdated(serverId uint64) *string {
+	label := fmt.Sprintf("bf::ao-%d", serverId)
+	return &label
+}
+
 func (_NotificationMuteLabel) ServerIncident(alertId uint64, serverId uint64) *string {
 	label := fmt.Sprintf("bf::sei-%d-%d", alertId, serverId)
 	return &label
@@ -0,0 +1,55 @@
+package singleton
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// pendingNotification 等待合并窗口结束后发送的报警通知
+type pendingNotification struct {
+	desc      string
+	muteLabel *string
+	server    *model.Server
+	alert     *model.AlertRule
+	resolved  bool
+
+	merged []string // 被合并的通知所属的报警规则
+}
+
+var (
+	pendingNotifications     = make(map[string]*pendingNotification)
+	pendingNotificationsLock sync.Mutex
+)
+
+// dedupAlertNotification 窗口内首条通知延后 window 发送，期间键相同的通知只计数
+func dedupAlertNotification(n *pendingNotification, window time.Duration) {
+	key := n.alert.DedupKey(n.server.ID, n.resolved)
+
+	pendingNotificationsLock.Lock()
+	if p, ok := pendingNotifications[key]; ok {
+		p.merged = append(p.merged, n.alert.Name)
+		pendingNotificationsLock.Unlock()
+		if Conf.Debug {
+			log.Printf("NEZHA>> 合并重复的报警通知：%s", n.desc)
+		}
+		return
+	}
+	pendingNotifications[key] = n
+	pendingNotificationsLock.Unlock()
+
+	time.AfterFunc(window, func() {
+		pendingNotificationsLock.Lock()
+		delete(pendingNotifications, key)
+		pendingNotificationsLock.Unlock()
+
+		desc := n.desc
+		if len(n.merged) > 0 {
+			desc += "\n" + Localizer.Tf("%d similar notifications were merged into this one: %s", len(n.merged), strings.Join(n.merged, ", "))
+		}
+		sendNotification(n.alert.NotificationGroupID, desc, n.muteLabel, n.server, n.alert, n.resolved)
+	})
+}
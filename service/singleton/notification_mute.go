@@ -0,0 +1,32 @@
+package singleton
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+var notificationMuteLock sync.RWMutex
+
+// GetNotificationMute 返回全局静音的状态
+func GetNotificationMute() model.NotificationMute {
+	notificationMuteLock.RLock()
+	defer notificationMuteLock.RUnlock()
+	return Conf.NotificationMute
+}
+
+// SetNotificationMute 开启或解除全局静音并保存到配置文件
+func SetNotificationMute(m model.NotificationMute) error {
+	return UpdateConf(func(conf *model.Config) error {
+		conf.NotificationMute = m
+		return nil
+	})
+}
+
+// notificationMuted 判断全局静音当前是否生效
+func notificationMuted() bool {
+	notificationMuteLock.RLock()
+	defer notificationMuteLock.RUnlock()
+	return Conf.NotificationMute.Active(time.Now())
+}
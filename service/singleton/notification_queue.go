@@ -0,0 +1,107 @@
+package singleton
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const (
+	notificationWorkers   = 4
+	notificationQueueSize = 256
+)
+
+// 通知由固定数量的 worker 从队列中取出发送，等待重试的通知在等待结束后重新排队，不占用 worker。
+// 等待重试的通知只保存在内存中，面板退出时记为死信
+var (
+	notificationQueue       = make(chan *notificationDelivery, notificationQueueSize)
+	notificationWorkersOnce sync.Once
+
+	notificationRetries     = make(map[string]*notificationDelivery) // [DeliveryID] -> 等待重试的通知
+	notificationRetriesLock sync.Mutex
+)
+
+// enqueueNotification 将通知加入发送队列，首次调用时启动 worker。
+// 队列已满时不阻塞调用方，直接记为死信
+func enqueueNotification(d *notificationDelivery) {
+	notificationWorkersOnce.Do(func() {
+		for range notificationWorkers {
+			go notificationWorker()
+		}
+	})
+	select {
+	case notificationQueue <- d:
+	default:
+		recordNotificationDeadLetter(d, "notification queue is full")
+	}
+}
+
+func notificationWorker() {
+	for d := range notificationQueue {
+		result := d.send()
+		if result.Success {
+			continue
+		}
+		n := d.ns.Notification
+		if d.attempt >= n.MaxAttempts() {
+			recordNotificationDeadLetter(d, result.Error)
+			continue
+		}
+
+		notificationRetriesLock.Lock()
+		notificationRetries[d.ns.DeliveryID] = d
+		notificationRetriesLock.Unlock()
+		time.AfterFunc(n.RetryDelay(d.attempt), func() { retryNotification(d) })
+	}
+}
+
+// retryNotification 等待结束后重新排队，通知方式已被删除或面板正在退出时不再发送
+func retryNotification(d *notificationDelivery) {
+	notificationRetriesLock.Lock()
+	_, ok := notificationRetries[d.ns.DeliveryID]
+	delete(notificationRetries, d.ns.DeliveryID)
+	notificationRetriesLock.Unlock()
+	if !ok {
+		return
+	}
+
+	NotificationsLock.RLock()
+	_, ok = NotificationMap[d.ns.Notification.ID]
+	NotificationsLock.RUnlock()
+	if !ok {
+		log.Printf("NEZHA>> 通知方式 %s 已被删除，放弃重试通知 %s", d.ns.Notification.Name, d.ns.DeliveryID)
+		return
+	}
+	enqueueNotification(d)
+}
+
+// flushNotificationRetries 面板退出时将仍在等待重试的通知记为死信
+func flushNotificationRetries() {
+	notificationRetriesLock.Lock()
+	pending := notificationRetries
+	notificationRetries = make(map[string]*notificationDelivery)
+	notificationRetriesLock.Unlock()
+
+	for _, d := range pending {
+		recordNotificationDeadLetter(d, "dashboard shut down before retrying")
+	}
+}
+
+// recordNotificationDeadLetter 记录重试次数用尽仍未发送成功的通知
+func recordNotificationDeadLetter(d *notificationDelivery, lastErr string) {
+	log.Printf("NEZHA>> 通知 %s 尝试 %d 次后仍发送失败，记为死信", d.ns.DeliveryID, d.attempt)
+	dl := model.NotificationDeadLetter{
+		NotificationID: d.ns.Notification.ID,
+		RecipientID:    d.recipientID,
+		DeliveryID:     d.ns.DeliveryID,
+		Attempts:       d.attempt,
+		Message:        d.desc,
+		Error:          lastErr,
+	}
+	dl.UserID = d.ns.Notification.UserID
+	if err := DB.Create(&dl).Error; err != nil {
+		log.Printf("NEZHA>> 保存通知死信失败：%v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package singleton
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/oidc"
+)
+
+var (
+	oidcProvider     *oidc.Provider
+	oidcProviderConf model.OIDC
+	oidcProviderLock sync.Mutex
+)
+
+// OIDCProvider 返回按当前配置创建的 OIDC 提供方，未配置时返回 nil。
+// redirectURL 在配置未指定回调地址时使用。
+func OIDCProvider(redirectURL string) *oidc.Provider {
+	conf := Conf.OIDC
+	if !conf.Enabled() {
+		return nil
+	}
+	if conf.RedirectURL != "" {
+		redirectURL = conf.RedirectURL
+	}
+
+	oidcProviderLock.Lock()
+	defer oidcProviderLock.Unlock()
+
+	// 配置变化后重新发现，同时复用已缓存的元数据与密钥
+	if oidcProvider == nil || oidcProviderConf != conf || oidcProvider.RedirectURL != redirectURL {
+		oidcProvider = &oidc.Provider{
+			Issuer:       conf.Issuer,
+			ClientID:     conf.ClientID,
+			ClientSecret: conf.ClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       splitList(conf.Scopes),
+		}
+		oidcProviderConf = conf
+	}
+	return oidcProvider
+}
+
+// OIDCLogin 根据已验证的 ID Token 查找或创建用户。
+//
+// 依次按 sub 声明、已验证的邮箱（与用户名相同）匹配已有用户，未找到时以邮箱为用户名创建用户。
+// 配置了管理员分组时，每次登录都会按分组声明更新用户角色。
+func OIDCLogin(claims oidc.Claims) (*model.User, error) {
+	conf := Conf.OIDC
+	subject := claims.String("sub")
+	email := claims.String("email")
+	if !claims.EmailVerified() {
+		email = ""
+	}
+
+	role := model.RoleMember
+	if conf.DefaultRole == "admin" {
+		role = model.RoleAdmin
+	}
+	adminGroups := splitList(conf.AdminGroups)
+	syncRole := len(adminGroups) > 0
+	if syncRole && slices.ContainsFunc(claims.Strings(conf.GroupsClaim), func(g string) bool {
+		return slices.Contains(adminGroups, g)
+	}) {
+		role = model.RoleAdmin
+	}
+
+	var user model.User
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("oidc_subject = ?", subject).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) && email != "" {
+			err = tx.Where("username = ? AND oidc_subject = ?", email, "").First(&user).Error
+		}
+		switch {
+		case err == nil:
+			updates := map[string]any{"oidc_subject": subject}
+			if syncRole {
+				updates["role"] = role
+			}
+			return tx.Model(&user).Updates(updates).Error
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		username := email
+		if username == "" {
+			username = claims.String("preferred_username")
+		}
+		if username == "" {
+			username = subject
+		}
+		var count int64
+		if err := tx.Model(&model.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return Localizer.ErrorT("username %s already exists", username)
+		}
+
+		user = model.User{
+			Username:    username,
+			Role:        role,
+			Permissions: model.DefaultMemberPermissions,
+			OIDCSubject: subject,
+		}
+		return tx.Create(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	OnUserUpdate(&user)
+	return &user, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -1,8 +1,12 @@
 package singleton
 
 import (
+	"cmp"
+	"context"
 	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/nezhahq/nezha/model"
 )
@@ -15,6 +19,7 @@ var (
 func AddOnlineUser(connId string, user *model.OnlineUser) {
 	OnlineUserMapLock.Lock()
 	defer OnlineUserMapLock.Unlock()
+	user.ConnID = connId
 	OnlineUserMap[connId] = user
 }
 
@@ -24,12 +29,48 @@ func RemoveOnlineUser(connId string) {
 	delete(OnlineUserMap, connId)
 }
 
-func BlockByIPs(ipList []string) error {
+// SetOnlineUserFilter 更新连接的服务器推送订阅条件
+func SetOnlineUserFilter(connId string, filter *model.StreamServerFilter) {
+	OnlineUserMapLock.Lock()
+	defer OnlineUserMapLock.Unlock()
+	if user, ok := OnlineUserMap[connId]; ok {
+		user.Filter = filter
+	}
+}
+
+// GetOnlineUserFilter 返回连接的服务器推送订阅条件，未订阅时返回 nil
+func GetOnlineUserFilter(connId string) *model.StreamServerFilter {
+	OnlineUserMapLock.Lock()
+	defer OnlineUserMapLock.Unlock()
+	if user, ok := OnlineUserMap[connId]; ok {
+		return user.Filter
+	}
+	return nil
+}
+
+// BlockByIPs 封禁 IP 并断开对应的在线用户，列表中可包含 CIDR 格式的 IP 段
+// ttl 为 0 时永久封禁，operator 为操作人 ID，0 表示系统
+func BlockByIPs(ctx context.Context, list []string, ttl time.Duration, operator uint64) error {
+	ipList, prefixes, err := SplitIPAndRanges(list)
+	if err != nil {
+		return err
+	}
+
+	var expireAt uint64
+	if ttl > 0 {
+		expireAt = uint64(time.Now().Add(ttl).Unix())
+	}
+	if len(prefixes) > 0 {
+		if err := BlockRanges(prefixes, model.WAFBlockReasonTypeManual, model.BlockIDManual, expireAt); err != nil {
+			return err
+		}
+	}
+
 	OnlineUserMapLock.Lock()
 	defer OnlineUserMapLock.Unlock()
 
 	for _, ip := range ipList {
-		if err := model.BlockIP(DB, ip, model.WAFBlockReasonTypeManual, model.BlockIDManual); err != nil {
+		if err := model.BlockIPUntil(DB, ip, model.WAFBlockReasonTypeManual, model.BlockIDManual, expireAt); err != nil {
 			return err
 		}
 		for _, user := range OnlineUserMap {
@@ -39,26 +80,61 @@ func BlockByIPs(ipList []string) error {
 		}
 	}
 
+	RecordWAFAudit(ctx, operator, model.WAFAuditActionBlock, list, expireAt)
 	return nil
 }
 
-func GetOnlineUsers(limit, offset int) []*model.OnlineUser {
+// sortedOnlineUsers 返回符合条件的在线用户，按连接时间排序，连接 ID 保证顺序稳定
+func sortedOnlineUsers(filter *model.OnlineUserFilter) []*model.OnlineUser {
 	OnlineUserMapLock.Lock()
 	defer OnlineUserMapLock.Unlock()
 	var users []*model.OnlineUser
 	for _, user := range OnlineUserMap {
-		users = append(users, user)
+		if filter.Match(user) {
+			users = append(users, user)
+		}
 	}
-	slices.SortFunc(users, func(i, j *model.OnlineUser) int {
-		return i.ConnectedAt.Compare(j.ConnectedAt)
-	})
+	slices.SortFunc(users, compareOnlineUser)
+	return users
+}
+
+func compareOnlineUser(i, j *model.OnlineUser) int {
+	return cmp.Or(cmp.Compare(i.ConnectedAt.UnixNano(), j.ConnectedAt.UnixNano()), strings.Compare(i.ConnID, j.ConnID))
+}
+
+// GetOnlineUsers 按连接时间返回符合条件的在线用户的一页，以及符合条件的总数
+func GetOnlineUsers(limit, offset int, filter *model.OnlineUserFilter) ([]*model.OnlineUser, int) {
+	users := sortedOnlineUsers(filter)
 	if offset > len(users) {
-		return nil
+		return nil, len(users)
 	}
 	if offset+limit > len(users) {
-		return users[offset:]
+		return users[offset:], len(users)
+	}
+	return users[offset : offset+limit], len(users)
+}
+
+// GetOnlineUsersAfter 游标分页，返回 cursor 之后的一页、下一页的游标与符合条件的总数，
+// 翻页期间有连接断开或新建也不会导致重复或遗漏
+func GetOnlineUsersAfter(cursor *model.Cursor, limit int, filter *model.OnlineUserFilter) ([]*model.OnlineUser, *model.Cursor, int) {
+	users := sortedOnlineUsers(filter)
+	start := 0
+	if cursor != nil {
+		pos := &model.OnlineUser{ConnectedAt: time.Unix(0, cursor.Key), ConnID: cursor.ID}
+		start, _ = slices.BinarySearchFunc(users, pos, compareOnlineUser)
+		if start < len(users) && compareOnlineUser(users[start], pos) == 0 {
+			start++
+		}
+	}
+	end := min(start+limit, len(users))
+	page := users[start:end]
+
+	var next *model.Cursor
+	if end < len(users) && len(page) > 0 {
+		last := page[len(page)-1]
+		next = &model.Cursor{Key: last.ConnectedAt.UnixNano(), ID: last.ConnID}
 	}
-	return users[offset : offset+limit]
+	return page, next, len(users)
 }
 
 func GetOnlineUserCount() int {
@@ -0,0 +1,68 @@
+package singleton
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 常见弱密码，始终禁止使用
+var commonPasswords = []string{
+	"123456", "1234567", "12345678", "123456789", "1234567890",
+	"111111", "000000", "123123", "654321", "666666", "888888",
+	"password", "password1", "password123", "passw0rd", "p@ssw0rd",
+	"qwerty", "qwerty123", "qwertyuiop", "abc123", "abcd1234",
+	"iloveyou", "admin", "admin123", "administrator", "root",
+	"letmein", "welcome", "monkey", "dragon", "football", "nezha",
+}
+
+// ValidatePassword 按当前密码策略校验密码，返回第一条未满足的规则
+func ValidatePassword(password string) error {
+	policy := Conf.PasswordPolicy
+
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		return Localizer.ErrorT("password must be at least %d characters", policy.MinLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	if policy.RequireUppercase && !upper {
+		return Localizer.ErrorT("password must contain an uppercase letter")
+	}
+	if policy.RequireLowercase && !lower {
+		return Localizer.ErrorT("password must contain a lowercase letter")
+	}
+	if policy.RequireDigit && !digit {
+		return Localizer.ErrorT("password must contain a digit")
+	}
+	if policy.RequireSymbol && !symbol {
+		return Localizer.ErrorT("password must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	denylist := commonPasswords
+	for _, p := range strings.Split(policy.Denylist, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			denylist = append(denylist, strings.ToLower(p))
+		}
+	}
+	for _, p := range denylist {
+		if lowered == p {
+			return Localizer.ErrorT("password is too common")
+		}
+	}
+
+	return nil
+}
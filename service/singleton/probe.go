@@ -0,0 +1,86 @@
+package singleton
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/probe"
+	pb "github.com/nezhahq/nezha/proto"
+)
+
+// Probe 在面板上执行不依赖 Agent 的服务监控，结果与 Agent 上报的数据走同一流程，Reporter 为 0。
+// 只有超级管理员的服务监控可以从面板访问内网地址
+func (ss *ServiceSentinel) Probe(task model.Service) {
+	ctx := context.Background()
+	if IsSuperAdminID(task.UserID) {
+		ctx = probe.AllowPrivateTargets(ctx)
+	}
+
+	var r probe.Result
+	switch task.Type {
+	case model.TaskTypeHTTPGet:
+		r = probe.HTTPGet(ctx, task.Target, HTTPHeaderOf(&task), HTTPAssertionOf(&task))
+	case model.TaskTypeGRPCHealth:
+		r = probe.GRPCHealth(ctx, task.Target)
+	case model.TaskTypeDNS:
+		r = probe.DNSQuery(ctx, task.Target)
+	case model.TaskTypeTLSCert:
+		r = probe.TLSCert(ctx, task.Target)
+	default:
+		return
+	}
+
+	ss.Dispatch(ReportData{
+		Data: &pb.TaskResult{
+			Id:         task.ID,
+			Type:       uint64(task.Type),
+			Delay:      r.Delay,
+			Data:       r.Data,
+			Successful: r.Successful,
+		},
+	})
+}
+
+// HTTPAssertionOf 返回服务监控配置的响应体断言，未设置关键字时返回 nil
+func HTTPAssertionOf(m *model.Service) *probe.HTTPAssertion {
+	if m.Keyword == "" {
+		return nil
+	}
+	return &probe.HTTPAssertion{
+		Keyword:    m.Keyword,
+		Regex:      m.KeywordRegex,
+		Invert:     m.KeywordInvert,
+		IgnoreCase: m.KeywordIgnoreCase,
+	}
+}
+
+// HTTPHeaderOf 返回服务监控配置的请求头，认证信息以 Authorization 请求头发送
+func HTTPHeaderOf(m *model.Service) http.Header {
+	if len(m.Headers) == 0 && m.AuthType == "" {
+		return nil
+	}
+	header := make(http.Header, len(m.Headers)+1)
+	for k, v := range m.Headers {
+		header.Set(k, v)
+	}
+	switch m.AuthType {
+	case model.ServiceAuthBasic:
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(m.AuthUsername+":"+m.AuthSecret)))
+	case model.ServiceAuthBearer:
+		header.Set("Authorization", "Bearer "+m.AuthSecret)
+	}
+	return header
+}
+
+// ReporterName 返回上报服务监控结果的服务器名称，面板探测时为面板，调用方需持有 ServerLock
+func ReporterName(id uint64) string {
+	if id == 0 {
+		return Localizer.T("Dashboard")
+	}
+	if server, ok := ServerList[id]; ok {
+		return server.Name
+	}
+	return ""
+}
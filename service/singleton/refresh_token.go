@@ -0,0 +1,120 @@
+package singleton
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// RefreshTokenTTL 刷新令牌的有效期，每次轮换后重新计算
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// IssueRefreshToken 为用户签发刷新令牌，familyID 为空时开始新的令牌链
+func IssueRefreshToken(tx *gorm.DB, user *model.User, familyID string) (string, time.Time, error) {
+	if familyID == "" {
+		var err error
+		if familyID, err = utils.GenerateRandomString(32); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	token, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expire := time.Now().Add(RefreshTokenTTL)
+	if err := tx.Create(&model.RefreshToken{
+		UserID:       user.ID,
+		FamilyID:     familyID,
+		TokenHash:    model.HashRefreshToken(token),
+		TokenVersion: user.TokenVersion,
+		ExpireAt:     expire,
+	}).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expire, nil
+}
+
+// RotateRefreshToken 使用刷新令牌换取同一链中的新令牌，返回的用户以令牌链作为会话 ID。
+// 已被轮换的令牌再次出现说明可能已泄露，此时撤销整条令牌链；会话闲置超时时同样撤销整条令牌链。
+func RotateRefreshToken(ctx context.Context, token string) (*model.User, string, time.Time, error) {
+	var (
+		user     model.User
+		newToken string
+		expire   time.Time
+		reused   *model.RefreshToken
+		idle     *model.RefreshToken
+	)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var rt model.RefreshToken
+		if err := tx.Where("token_hash = ?", model.HashRefreshToken(token)).First(&rt).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return Localizer.ErrorT("invalid refresh token")
+			}
+			return err
+		}
+		if rt.RevokedAt != nil || time.Now().After(rt.ExpireAt) || !CheckTokenVersion(rt.UserID, rt.TokenVersion) {
+			return Localizer.ErrorT("invalid refresh token")
+		}
+		if SessionIdleExpired(rt.FamilyID, rt.CreatedAt) {
+			idle = &rt
+			return nil
+		}
+
+		now := time.Now()
+		// 以条件更新标记已使用，并发的重复刷新只有一个能成功
+		result := tx.Model(&model.RefreshToken{}).Where("id = ? AND used_at IS NULL", rt.ID).Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = &rt
+			return nil
+		}
+
+		if err := tx.First(&user, rt.UserID).Error; err != nil {
+			return err
+		}
+		user.SessionID = rt.FamilyID
+		var err error
+		newToken, expire, err = IssueRefreshToken(tx, &user, rt.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	if idle != nil {
+		if err := RevokeRefreshTokenFamily(idle.FamilyID); err != nil {
+			return nil, "", time.Time{}, err
+		}
+		return nil, "", time.Time{}, Localizer.ErrorT("session expired due to inactivity")
+	}
+	if reused != nil {
+		Logger(ctx).Warn("rotated refresh token reused, revoking token family", "user_id", reused.UserID, "family_id", reused.FamilyID)
+		if err := RevokeRefreshTokenFamily(reused.FamilyID); err != nil {
+			return nil, "", time.Time{}, err
+		}
+		return nil, "", time.Time{}, Localizer.ErrorT("refresh token reused, please login again")
+	}
+	return &user, newToken, expire, nil
+}
+
+// RevokeRefreshTokenFamily 撤销整条令牌链
+func RevokeRefreshTokenFamily(familyID string) error {
+	return DB.Model(&model.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// CleanExpiredRefreshTokens 删除已过期的刷新令牌
+func CleanExpiredRefreshTokens() {
+	if err := DB.Where("expire_at < ?", time.Now()).Delete(&model.RefreshToken{}).Error; err != nil {
+		log.Printf("NEZHA>> 清理过期的刷新令牌失败: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package singleton
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// secretMasterKey 返回当前主密钥，环境变量优先于配置文件
+func secretMasterKey() string {
+	if key := os.Getenv(model.SecretsKeyEnv); key != "" {
+		return key
+	}
+	return Conf.SecretsKey
+}
+
+// SecretsKeyFromEnv 主密钥是否由环境变量指定，此时无法在面板中轮换
+func SecretsKeyFromEnv() bool {
+	return os.Getenv(model.SecretsKeyEnv) != ""
+}
+
+// SealSecret 使用当前主密钥加密密钥值
+func SealSecret(s *model.Secret, value string) error {
+	key, keyID := model.DeriveSecretKey(secretMasterKey())
+	ciphertext, err := model.EncryptSecret(key, value)
+	if err != nil {
+		return err
+	}
+	s.Ciphertext, s.KeyID = ciphertext, keyID
+	return nil
+}
+
+// ResolveSecrets 解密属于该服务器的密钥，以名称为键返回；不属于该服务器的 ID 会被忽略
+func ResolveSecrets(serverID uint64, ids []uint64) (map[string]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var secrets []model.Secret
+	if err := DB.Where("server_id = ? AND id in (?)", serverID, ids).Find(&secrets).Error; err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		return nil, nil
+	}
+
+	key, _ := model.DeriveSecretKey(secretMasterKey())
+	env := make(map[string]string, len(secrets))
+	for _, s := range secrets {
+		value, err := model.DecryptSecret(key, s.Ciphertext)
+		if err != nil {
+			// 只记录 ID，不输出任何密钥内容
+			log.Printf("NEZHA>> failed to decrypt secret %d: %v", s.ID, err)
+			return nil, Localizer.ErrorT("failed to decrypt secret %d", s.ID)
+		}
+		env[s.Name] = value
+	}
+	return env, nil
+}
+
+// RotateSecretsKey 使用新的主密钥重新加密全部密钥与入库的 JWT 签名密钥，成功后写入配置文件
+func RotateSecretsKey(ctx context.Context, newKey string) error {
+	if SecretsKeyFromEnv() {
+		return Localizer.ErrorT("the secrets key is set by %s and cannot be rotated here", model.SecretsKeyEnv)
+	}
+	if newKey == "" {
+		var err error
+		if newKey, err = utils.GenerateRandomString(64); err != nil {
+			return err
+		}
+	}
+
+	// 轮换期间持有配置写锁，避免其他配置写入覆盖新密钥或并发轮换使用过期的旧密钥
+	confLock.Lock()
+	defer confLock.Unlock()
+	// 同时避免轮换期间生成的 JWT 签名密钥以旧密钥加密
+	jwtKeyLock.Lock()
+	defer jwtKeyLock.Unlock()
+	oldKey, oldKeyID := model.DeriveSecretKey(Conf.SecretsKey)
+	key, keyID := model.DeriveSecretKey(newKey)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var secrets []model.Secret
+		if err := tx.Find(&secrets).Error; err != nil {
+			return err
+		}
+		for _, s := range secrets {
+			value, err := model.DecryptSecret(oldKey, s.Ciphertext)
+			if err != nil {
+				Logger(ctx).Error("failed to decrypt secret", "secret_id", s.ID, "error", err)
+				return Localizer.ErrorT("failed to decrypt secret %d", s.ID)
+			}
+			ciphertext, err := model.EncryptSecret(key, value)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&s).Updates(map[string]any{"ciphertext": ciphertext, "key_id": keyID}).Error; err != nil {
+				return err
+			}
+		}
+
+		// 以其他主密钥加密的 JWT 签名密钥已在加载时停用，不再重新加密
+		var jwtKeys []model.JWTKey
+		if err := tx.Where("kid <> ? AND key_id IN (?)", model.JWTConfigKeyID, []string{"", oldKeyID}).Find(&jwtKeys).Error; err != nil {
+			return err
+		}
+		for _, k := range jwtKeys {
+			value := k.PrivateKey
+			if k.KeyID != "" {
+				var err error
+				if value, err = model.DecryptSecret(oldKey, k.PrivateKey); err != nil {
+					Logger(ctx).Error("failed to decrypt jwt key", "kid", k.KID, "error", err)
+					return Localizer.ErrorT("failed to decrypt jwt key %s", k.KID)
+				}
+			}
+			ciphertext, err := model.EncryptSecret(key, value)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&k).Updates(map[string]any{"private_key": ciphertext, "key_id": keyID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 数据库已使用新密钥加密，即使写入配置文件失败也保留内存中的新密钥
+	Conf.SecretsKey = newKey
+	return Conf.Save()
+}
+
+// checkSecretsKey 启动时检查是否存在以其他主密钥加密的密钥
+func checkSecretsKey() {
+	_, keyID := model.DeriveSecretKey(secretMasterKey())
+	var stale int64
+	DB.Model(&model.Secret{}).Where("key_id <> ?", keyID).Count(&stale)
+	if stale > 0 {
+		log.Printf("NEZHA>> %d secrets were encrypted with a different master key and cannot be decrypted", stale)
+	}
+}
@@ -2,13 +2,20 @@ package singleton
 
 import (
 	"cmp"
+	"log"
 	"slices"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"gorm.io/gorm"
 
 	"github.com/nezhahq/nezha/model"
 	"github.com/nezhahq/nezha/pkg/utils"
 )
 
+// 需要同时持有时按 ServerLock、SortedServerLock、ServerGroupLock 的顺序加锁
 var (
 	ServerList     map[uint64]*model.Server // [ServerID] -> model.Server
 	ServerUUIDToID map[string]uint64        // [ServerUUID] -> ServerID
@@ -16,6 +23,7 @@ var (
 
 	SortedServerList         []*model.Server // 用于存储服务器列表的 slice，按照服务器 ID 排序
 	SortedServerListForGuest []*model.Server
+	ServerTagIndex           map[string]map[uint64]bool // [Tag] -> ServerID set
 	SortedServerLock         sync.RWMutex
 )
 
@@ -38,10 +46,12 @@ func loadServers() {
 		ServerUUIDToID[innerS.UUID] = innerS.ID
 	}
 	ReSortServer()
+	UpdateServerGroupMembership()
 }
 
 // ReSortServer 根据服务器ID 对服务器列表进行排序（ID越大越靠前）
 func ReSortServer() {
+	defer InvalidateServerListCache()
 	ServerLock.RLock()
 	defer ServerLock.RUnlock()
 	SortedServerLock.Lock()
@@ -57,19 +67,370 @@ func ReSortServer() {
 	})
 
 	SortedServerListForGuest = make([]*model.Server, 0, len(SortedServerList))
+	ServerTagIndex = make(map[string]map[uint64]bool)
 	for _, s := range SortedServerList {
 		if !s.HideForGuest {
 			SortedServerListForGuest = append(SortedServerListForGuest, s)
 		}
+		for _, tag := range s.Tags {
+			if ServerTagIndex[tag] == nil {
+				ServerTagIndex[tag] = make(map[uint64]bool)
+			}
+			ServerTagIndex[tag][s.ID] = true
+		}
+	}
+}
+
+// ServerHasTags 判断服务器是否带有全部指定标签，调用方需持有 SortedServerLock
+func ServerHasTags(sid uint64, tags []string) bool {
+	for _, tag := range tags {
+		if !ServerTagIndex[tag][sid] {
+			return false
+		}
 	}
+	return true
 }
 
 func OnServerDelete(sid []uint64) {
+	defer InvalidateServerListCache()
+	// 报警器先持有 AlertsLock 再持有 ServerLock，需在持有 ServerLock 之前清理
+	onServerDeleteAlerts(sid)
+
 	ServerLock.Lock()
 	defer ServerLock.Unlock()
 	for _, id := range sid {
-		serverUUID := ServerList[id].UUID
-		delete(ServerUUIDToID, serverUUID)
-		delete(ServerList, id)
+		if s, ok := ServerList[id]; ok {
+			delete(ServerUUIDToID, s.UUID)
+			delete(ServerList, id)
+		}
+	}
+
+	ServerGroupLock.Lock()
+	defer ServerGroupLock.Unlock()
+	for _, id := range sid {
+		delete(ServerGroupMembership, id)
+	}
+}
+
+// TrashedServers 返回回收站中的服务器，按删除时间倒序
+func TrashedServers() ([]*model.Server, error) {
+	var servers []*model.Server
+	if err := DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at desc").Find(&servers).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range servers {
+		s.Host = &model.Host{}
+		s.State = &model.HostState{}
+		s.GeoIP = new(model.GeoIP)
+	}
+	return servers, nil
+}
+
+// IsServerTrashed 判断 UUID 对应的服务器是否在回收站中
+func IsServerTrashed(uuid string) bool {
+	var count int64
+	DB.Unscoped().Model(&model.Server{}).Where("uuid = ? AND deleted_at IS NOT NULL", uuid).Count(&count)
+	return count > 0
+}
+
+// RestoreServer 将回收站中的服务器恢复到服务器列表，删除时已移出的分组不会恢复
+func RestoreServer(s *model.Server) error {
+	if err := DB.Unscoped().Model(s).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	s.DeletedAt = gorm.DeletedAt{}
+	s.Host = &model.Host{}
+	s.State = &model.HostState{}
+	s.GeoIP = new(model.GeoIP)
+
+	ServerLock.Lock()
+	ServerList[s.ID] = s
+	ServerUUIDToID[s.UUID] = s.ID
+	ServerLock.Unlock()
+
+	ReSortServer()
+	return nil
+}
+
+// PurgeServers 彻底删除服务器及其分组关系、流量记录、状态事件、维护记录与注册申请
+func PurgeServers(tx *gorm.DB, ids []uint64) error {
+	if err := tx.Unscoped().Delete(&model.Server{}, "id in (?)", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Delete(&model.ServerGroupServer{}, "server_id in (?)", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Delete(&model.Transfer{}, "server_id in (?)", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(&model.ServerEvent{}, "server_id in (?)", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(&model.ServerEnrollment{}, "server_id in (?)", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Delete(&model.Secret{}, "server_id in (?)", ids).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&model.ServerMaintenance{}, "server_id in (?)", ids).Error
+}
+
+// PurgeTrashedServers 彻底删除在回收站中超过保留天数的服务器
+func PurgeTrashedServers() {
+	var ids []uint64
+	if err := DB.Unscoped().Model(&model.Server{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().AddDate(0, 0, -max(Conf.ServerTrashRetention, 1))).
+		Pluck("id", &ids).Error; err != nil {
+		log.Printf("NEZHA>> 查询回收站中的服务器失败: %v", err)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		return PurgeServers(tx, ids)
+	}); err != nil {
+		log.Printf("NEZHA>> 清理回收站中的服务器失败: %v", err)
+	}
+}
+
+// UpdateServerTags 批量替换服务器标签并同步到内存
+func UpdateServerTags(tags map[uint64][]string) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for sid, t := range tags {
+			data, err := utils.Json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Server{}).Where("id = ?", sid).Update("tags_raw", string(data)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 其他协程可能正在无锁读取原有的服务器，替换为修改后的副本
+	ServerLock.Lock()
+	for sid, t := range tags {
+		if s, ok := ServerList[sid]; ok {
+			ns := *s
+			ns.Tags = t
+			ServerList[sid] = &ns
+		}
+	}
+	ServerLock.Unlock()
+
+	ReSortServer()
+	return nil
+}
+
+// UpdateServerNote 更新服务器备注
+func UpdateServerNote(sid uint64, note string) error {
+	if err := DB.Model(&model.Server{}).Where("id = ?", sid).Update("note", note).Error; err != nil {
+		return err
+	}
+
+	ServerLock.Lock()
+	if s, ok := ServerList[sid]; ok {
+		ns := *s
+		ns.Note = note
+		ServerList[sid] = &ns
+	}
+	ServerLock.Unlock()
+
+	ReSortServer()
+	return nil
+}
+
+// SetServerMaintenance 设置服务器的维护模式，until 为 nil 时结束维护
+// 同时记录维护历史，用于统计在线率时排除维护期间
+func SetServerMaintenance(ids []uint64, until *time.Time, reason string) error {
+	now := time.Now()
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Server{}).Where("id in (?)", ids).Updates(map[string]any{
+			"maintenance_until":  until,
+			"maintenance_reason": reason,
+		}).Error; err != nil {
+			return err
+		}
+		// 结束尚未到期的维护记录
+		if err := tx.Model(&model.ServerMaintenance{}).Where("server_id in (?) AND end_at > ?", ids, now).
+			Update("end_at", now).Error; err != nil {
+			return err
+		}
+		if until == nil {
+			return nil
+		}
+		records := make([]model.ServerMaintenance, 0, len(ids))
+		for _, sid := range ids {
+			records = append(records, model.ServerMaintenance{ServerID: sid, StartAt: now, EndAt: *until, Reason: reason})
+		}
+		return tx.Create(&records).Error
+	}); err != nil {
+		return err
+	}
+
+	ServerLock.Lock()
+	for _, sid := range ids {
+		if s, ok := ServerList[sid]; ok {
+			ns := *s
+			ns.MaintenanceUntil = until
+			ns.MaintenanceReason = reason
+			ServerList[sid] = &ns
+		}
+	}
+	ServerLock.Unlock()
+
+	ReSortServer()
+	InvalidateServerListCache()
+	return nil
+}
+
+// ClearExpiredMaintenance 结束已到期的维护模式
+func ClearExpiredMaintenance() {
+	now := time.Now()
+	var ids []uint64
+	ServerLock.RLock()
+	for _, s := range ServerList {
+		if s.MaintenanceUntil != nil && !s.InMaintenance(now) {
+			ids = append(ids, s.ID)
+		}
+	}
+	ServerLock.RUnlock()
+
+	if len(ids) == 0 {
+		return
+	}
+	if err := SetServerMaintenance(ids, nil, ""); err != nil {
+		log.Printf("NEZHA>> 结束服务器维护模式失败: %v", err)
+	}
+}
+
+// SortServerList 按指定字段使用内存中的服务器状态排序，值相同时按 ID 排序以保持稳定
+func SortServerList(servers []*model.Server, by string, desc bool) error {
+	var compare func(a, b *model.Server) int
+	switch by {
+	case "name":
+		compare = func(a, b *model.Server) int {
+			return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		}
+	case "last_active":
+		compare = func(a, b *model.Server) int {
+			return a.LastActive.Compare(b.LastActive)
+		}
+	case "cpu":
+		compare = func(a, b *model.Server) int {
+			return cmp.Compare(serverCPU(a), serverCPU(b))
+		}
+	case "memory":
+		compare = func(a, b *model.Server) int {
+			return cmp.Compare(serverMemoryUsage(a), serverMemoryUsage(b))
+		}
+	default:
+		return Localizer.ErrorT("unsupported sort field: %s", by)
+	}
+
+	slices.SortStableFunc(servers, func(a, b *model.Server) int {
+		r := compare(a, b)
+		if r == 0 {
+			return cmp.Compare(a.ID, b.ID)
+		}
+		if desc {
+			return -r
+		}
+		return r
+	})
+	return nil
+}
+
+func serverCPU(s *model.Server) float64 {
+	if s.State == nil {
+		return 0
+	}
+	return s.State.CPU
+}
+
+func serverMemoryUsage(s *model.Server) float64 {
+	if s.State == nil || s.Host == nil || s.Host.MemTotal == 0 {
+		return 0
+	}
+	return float64(s.State.MemUsed) / float64(s.Host.MemTotal)
+}
+
+// SetServerOwner 将服务器转移给其他用户，并将服务器移出原所属用户创建的分组，原所属用户不再能通过分组访问
+func SetServerOwner(sid, uid uint64) error {
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		var old model.Server
+		if err := tx.Select("id", "user_id").First(&old, sid).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Server{}).Where("id = ?", sid).Update("user_id", uid).Error; err != nil {
+			return err
+		}
+		if old.UserID == uid {
+			return nil
+		}
+		return tx.Unscoped().Where("server_id = ? AND server_group_id IN (?)", sid,
+			tx.Model(&model.ServerGroup{}).Select("id").Where("user_id = ?", old.UserID)).
+			Delete(&model.ServerGroupServer{}).Error
+	}); err != nil {
+		return err
+	}
+
+	ServerLock.Lock()
+	if s, ok := ServerList[sid]; ok {
+		ns := *s
+		ns.UserID, ns.OwnerID = uid, uid
+		ServerList[sid] = &ns
+	}
+	ServerLock.Unlock()
+
+	UpdateServerGroupMembership()
+	ReSortServer()
+	return nil
+}
+
+// NotifyNewServer 新服务器首次加入服务器列表时提醒，ip 为 Agent 的来源 IP，token 为注册时使用的注册令牌名称。
+// 从回收站恢复的服务器不经过此流程
+func NotifyNewServer(s *model.Server, ip, token string) {
+	if Conf.NewServerNotificationGroupID == 0 {
+		return
+	}
+	if ip == "" {
+		ip = Localizer.T("Unknown")
+	} else {
+		ip = IPDesensitize(ip)
+	}
+	desc := Localizer.Tf("[New Server] %s joined from %s", s.Name, ip)
+	if token != "" {
+		desc += "\n" + Localizer.Tf("Enrollment token: %s", token)
+	}
+	curServer := model.Server{}
+	copier.Copy(&curServer, s)
+	go SendNotification(Conf.NewServerNotificationGroupID, desc, nil, &curServer)
+}
+
+// OnAgentVersionReport 记录 Agent 上报的版本，低于设置中的最低版本时提醒，调用方需持有 ServerLock
+func OnAgentVersionReport(s *model.Server, version string) {
+	if version == "" {
+		version = model.UnknownAgentVersion
+	}
+	if s.AgentVersion != version {
+		if err := DB.Model(&model.Server{}).Where("id = ?", s.ID).Update("agent_version", version).Error; err != nil {
+			log.Printf("NEZHA>> 保存 Agent 版本失败: %v", err)
+		}
+		s.AgentVersion = version
+	}
+
+	if Conf.OutdatedAgentNotificationGroupID == 0 || !s.AgentOutdated(Conf.MinAgentVersion) {
+		return
 	}
+	curServer := model.Server{}
+	copier.Copy(&curServer, s)
+	go SendNotification(Conf.OutdatedAgentNotificationGroupID,
+		Localizer.Tf("[Agent Outdated] %s is running agent %s, below the minimum version %s", s.Name, version, Conf.MinAgentVersion),
+		NotificationMuteLabel.AgentOutdated(s.ID), &curServer)
 }
@@ -0,0 +1,185 @@
+package singleton
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	petname "github.com/dustinkirkland/golang-petname"
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+var (
+	ErrEnrollmentTokenUsed    = errors.New("enrollment token has been used")
+	ErrEnrollmentTokenRevoked = errors.New("enrollment token has been revoked")
+)
+
+// EnrollServer 处理以注册令牌连接的 Agent，返回其注册申请的状态。
+// 首次连接时创建待审批的申请，令牌无效或已撤销、单次令牌已被使用或与申请时的令牌不符时返回空状态
+func EnrollServer(uuid, secret, ip string) (string, error) {
+	ServerLock.RLock()
+	_, exists := ServerUUIDToID[uuid]
+	ServerLock.RUnlock()
+
+	hash := model.HashApiToken(secret)
+	var e model.ServerEnrollment
+	if err := DB.Where("uuid = ?", uuid).Limit(1).Find(&e).Error; err != nil {
+		return "", err
+	}
+	if e.ID != 0 {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(e.SecretHash)) != 1 {
+			return "", nil
+		}
+		// 令牌撤销后，以该令牌注册的服务器不能再连接
+		if e.Status != model.ServerEnrollmentRejected {
+			revoked, err := enrollmentTokenRevoked(DB, e.TokenID)
+			if err != nil {
+				return "", err
+			}
+			if revoked {
+				return "", nil
+			}
+		}
+		// 导入时创建的申请在 Agent 首次连接时记录来源 IP
+		if e.IP == "" && ip != "" {
+			if err := DB.Model(&e).Update("ip", ip).Error; err != nil {
+				return "", err
+			}
+		}
+		return e.Status, nil
+	}
+	// 已以用户密钥接入的服务器不能再发起注册
+	if exists {
+		return "", nil
+	}
+
+	var t model.EnrollmentToken
+	if err := DB.Where("token_hash = ?", hash).Limit(1).Find(&t).Error; err != nil {
+		return "", err
+	}
+	if t.ID == 0 {
+		return "", nil
+	}
+
+	e = model.ServerEnrollment{
+		UUID:       uuid,
+		TokenID:    t.ID,
+		IP:         ip,
+		Status:     model.ServerEnrollmentPending,
+		SecretHash: hash,
+	}
+	e.UserID = t.UserID
+	err := CreateServerEnrollment(&e, &t)
+	if errors.Is(err, ErrEnrollmentTokenUsed) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return e.Status, nil
+}
+
+// enrollmentTokenRevoked 判断注册令牌是否已被撤销，撤销时令牌被删除
+func enrollmentTokenRevoked(tx *gorm.DB, tokenID uint64) (bool, error) {
+	var count int64
+	if err := tx.Model(&model.EnrollmentToken{}).Where("id = ?", tokenID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// RevokeEnrollmentTokens 撤销注册令牌，以这些令牌发起且尚未审批的注册申请一并拒绝
+func RevokeEnrollmentTokens(ids []uint64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&model.EnrollmentToken{}, "id in (?)", ids).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.ServerEnrollment{}).Where("token_id in (?) AND status = ?", ids, model.ServerEnrollmentPending).
+			Update("status", model.ServerEnrollmentRejected).Error
+	})
+}
+
+// CreateServerEnrollment 记录注册令牌的使用并创建注册申请，单次令牌已被使用时返回 ErrEnrollmentTokenUsed
+func CreateServerEnrollment(e *model.ServerEnrollment, t *model.EnrollmentToken) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		q := tx.Model(&model.EnrollmentToken{}).Where("id = ?", t.ID)
+		if t.SingleUse {
+			q = q.Where("used_count = 0")
+		}
+		result := q.Updates(map[string]any{"used_count": gorm.Expr("used_count + 1"), "last_used_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEnrollmentTokenUsed
+		}
+		return tx.Create(e).Error
+	})
+}
+
+// ApproveServerEnrollment 审批通过注册申请，按导入时预设的信息为其创建服务器并加入服务器列表，
+// 注册令牌已被撤销时返回 ErrEnrollmentTokenRevoked
+func ApproveServerEnrollment(ctx context.Context, e *model.ServerEnrollment) (*model.Server, error) {
+	s := model.Server{UUID: e.UUID, Name: e.Name, Note: e.Note, TagsRaw: e.TagsRaw, Tags: e.Tags, OwnerID: e.UserID, Common: model.Common{
+		UserID: e.UserID,
+	}}
+	if s.Name == "" {
+		s.Name = petname.Generate(2, "-")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		revoked, err := enrollmentTokenRevoked(tx, e.TokenID)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrEnrollmentTokenRevoked
+		}
+		if err := tx.Create(&s).Error; err != nil {
+			return err
+		}
+		if e.GroupID != 0 {
+			// 分组在审批前已被删除时不再加入
+			var count int64
+			if err := tx.Model(&model.ServerGroup{}).Where("id = ?", e.GroupID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				if err := tx.Create(&model.ServerGroupServer{
+					Common:        model.Common{UserID: e.UserID},
+					ServerGroupId: e.GroupID,
+					ServerId:      s.ID,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(e).Updates(map[string]any{"status": model.ServerEnrollmentApproved, "server_id": s.ID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.Status = model.ServerEnrollmentApproved
+	e.ServerID = s.ID
+	s.Host = &model.Host{}
+	s.State = &model.HostState{}
+	s.GeoIP = &model.GeoIP{}
+
+	ServerLock.Lock()
+	ServerList[s.ID] = &s
+	ServerUUIDToID[s.UUID] = s.ID
+	ServerLock.Unlock()
+	ReSortServer()
+	if e.GroupID != 0 {
+		UpdateServerGroupMembership()
+	}
+
+	var token model.EnrollmentToken
+	if err := DB.Where("id = ?", e.TokenID).Limit(1).Find(&token).Error; err != nil {
+		Logger(ctx).Error("failed to query enrollment token", "token_id", e.TokenID, "error", err)
+	}
+	NotifyNewServer(&s, e.IP, token.Name)
+	return &s, nil
+}
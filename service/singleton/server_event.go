@@ -0,0 +1,180 @@
+package singleton
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// serverStateDebounce 状态变化需持续的时间，避免网络抖动产生大量事件
+const serverStateDebounce = time.Minute
+
+type serverState struct {
+	online bool
+	known  bool // 是否已有事件记录
+	// 观察到的与 online 不同的状态及其开始时间
+	pending       bool
+	pendingOnline bool
+	pendingSince  time.Time
+}
+
+var (
+	serverStates     map[uint64]*serverState
+	serverStatesLock sync.Mutex
+)
+
+// loadServerStates 从每台服务器最近一次的事件恢复状态
+func loadServerStates() {
+	serverStatesLock.Lock()
+	defer serverStatesLock.Unlock()
+
+	serverStates = make(map[uint64]*serverState)
+	var events []model.ServerEvent
+	if err := DB.Where("id IN (?)", DB.Model(&model.ServerEvent{}).Select("MAX(id)").Group("server_id")).
+		Find(&events).Error; err != nil {
+		log.Printf("NEZHA>> 加载服务器状态事件失败: %v", err)
+		return
+	}
+	for _, e := range events {
+		serverStates[e.ServerID] = &serverState{online: e.Online, known: true}
+	}
+}
+
+// CheckServerStates 检查服务器的在线状态，状态变化持续超过 serverStateDebounce 后记录事件。
+// 与告警规则无关，所有服务器都会记录。
+func CheckServerStates() {
+	now := time.Now()
+
+	type observation struct {
+		online bool
+		since  time.Time
+		seen   bool // 曾经上报过数据
+	}
+	ServerLock.RLock()
+	observed := make(map[uint64]observation, len(ServerList))
+	for id, s := range ServerList {
+		o := observation{online: s.IsOnline(Conf.ServerOfflineTimeout), since: now, seen: !s.LastActive.IsZero()}
+		if !o.online && o.seen {
+			// 离线时间以最后一次上报为准
+			o.since = s.LastActive
+		}
+		observed[id] = o
+	}
+	ServerLock.RUnlock()
+
+	serverStatesLock.Lock()
+	var events []model.ServerEvent
+	for id := range serverStates {
+		if _, ok := observed[id]; !ok {
+			delete(serverStates, id)
+		}
+	}
+	for id, o := range observed {
+		st, ok := serverStates[id]
+		if !ok {
+			st = &serverState{}
+			serverStates[id] = st
+		}
+		// 从未上线过的服务器无需记录离线事件
+		if (st.known && st.online == o.online) || (!st.known && !o.online && !o.seen) {
+			st.pending = false
+			continue
+		}
+		if !st.pending || st.pendingOnline != o.online {
+			st.pending, st.pendingOnline, st.pendingSince = true, o.online, o.since
+		}
+		if now.Sub(st.pendingSince) < serverStateDebounce {
+			continue
+		}
+		events = append(events, model.ServerEvent{ServerID: id, Online: o.online, CreatedAt: st.pendingSince})
+		st.online, st.known, st.pending = o.online, true, false
+	}
+	serverStatesLock.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	if err := DB.Create(&events).Error; err != nil {
+		log.Printf("NEZHA>> 记录服务器状态事件失败: %v", err)
+	}
+}
+
+// ServerUptimeReport 根据状态事件统计 [from, to) 内各服务器的在线率。
+// excludeMaintenance 为真时维护期间不计入统计。
+func ServerUptimeReport(servers []*model.Server, from, to time.Time, excludeMaintenance bool) (*model.UptimeReport, error) {
+	report := &model.UptimeReport{
+		From:               from,
+		To:                 to,
+		ExcludeMaintenance: excludeMaintenance,
+		Servers:            make([]model.UptimeReportServer, 0, len(servers)),
+	}
+	ids := make([]uint64, 0, len(servers))
+	for _, s := range servers {
+		ids = append(ids, s.ID)
+	}
+
+	var prevEvents, events []model.ServerEvent
+	if err := DB.Where("id IN (?)", DB.Model(&model.ServerEvent{}).Select("MAX(id)").
+		Where("server_id IN (?) AND created_at < ?", ids, from).Group("server_id")).
+		Find(&prevEvents).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("server_id IN (?) AND created_at >= ? AND created_at < ?", ids, from, to).
+		Order("created_at").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	prev := make(map[uint64]*model.ServerEvent, len(prevEvents))
+	for i := range prevEvents {
+		prev[prevEvents[i].ServerID] = &prevEvents[i]
+	}
+	eventsByServer := make(map[uint64][]model.ServerEvent)
+	for _, e := range events {
+		eventsByServer[e.ServerID] = append(eventsByServer[e.ServerID], e)
+	}
+
+	maintenance := make(map[uint64][]model.TimeRange)
+	if excludeMaintenance {
+		var records []model.ServerMaintenance
+		if err := DB.Where("server_id IN (?) AND start_at < ? AND end_at > ?", ids, to, from).
+			Find(&records).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			maintenance[r.ServerID] = append(maintenance[r.ServerID], model.TimeRange{Start: r.StartAt, End: r.EndAt})
+		}
+	}
+
+	var totalOnline, totalMeasured time.Duration
+	for _, s := range servers {
+		start := from
+		if s.CreatedAt.After(start) {
+			start = s.CreatedAt
+		}
+		item := model.UptimeReportServer{ServerID: s.ID, Name: s.Name, From: start, Uptime: -1}
+		if start.Before(to) {
+			online, measured := model.ServerAvailability(prev[s.ID], eventsByServer[s.ID], start, to, maintenance[s.ID])
+			item.Uptime = model.UptimePercent(online, measured)
+			item.OnlineSeconds = int64(online / time.Second)
+			item.DowntimeSeconds = int64((measured - online) / time.Second)
+			totalOnline += online
+			totalMeasured += measured
+		}
+		for _, e := range eventsByServer[s.ID] {
+			if !e.Online && !slices.ContainsFunc(maintenance[s.ID], func(r model.TimeRange) bool {
+				return !e.CreatedAt.Before(r.Start) && e.CreatedAt.Before(r.End)
+			}) {
+				item.Outages++
+			}
+		}
+		report.Outages += item.Outages
+		report.Servers = append(report.Servers, item)
+	}
+
+	report.Uptime = model.UptimePercent(totalOnline, totalMeasured)
+	report.OnlineSeconds = int64(totalOnline / time.Second)
+	report.DowntimeSeconds = int64((totalMeasured - totalOnline) / time.Second)
+	return report, nil
+}
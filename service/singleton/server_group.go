@@ -0,0 +1,66 @@
+package singleton
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+var (
+	ServerGroupMembership map[uint64][]uint64 // [ServerID] -> []ServerGroupID
+	ServerGroupNames      map[uint64]string   // [ServerGroupID] -> Name
+	ServerGroupOwners     map[uint64]uint64   // [ServerGroupID] -> UserID
+	ServerGroupLock       sync.RWMutex
+)
+
+// UpdateServerGroupMembership 从数据库重新加载服务器与分组的关联关系
+func UpdateServerGroupMembership() {
+	var sgs []model.ServerGroupServer
+	DB.Find(&sgs)
+	var groups []model.ServerGroup
+	DB.Find(&groups)
+
+	membership := make(map[uint64][]uint64)
+	for _, s := range sgs {
+		membership[s.ServerId] = append(membership[s.ServerId], s.ServerGroupId)
+	}
+	names := make(map[uint64]string, len(groups))
+	owners := make(map[uint64]uint64, len(groups))
+	for _, g := range groups {
+		names[g.ID] = g.Name
+		owners[g.ID] = g.UserID
+	}
+
+	ServerGroupLock.Lock()
+	defer ServerGroupLock.Unlock()
+	ServerGroupMembership = membership
+	ServerGroupNames = names
+	ServerGroupOwners = owners
+	InvalidateServerListCache()
+}
+
+// GetServerGroups 返回服务器所属的分组 ID
+func GetServerGroups(sid uint64) []uint64 {
+	ServerGroupLock.RLock()
+	defer ServerGroupLock.RUnlock()
+
+	return slices.Clone(ServerGroupMembership[sid])
+}
+
+func ServerInGroup(sid, gid uint64) bool {
+	ServerGroupLock.RLock()
+	defer ServerGroupLock.RUnlock()
+
+	return slices.Contains(ServerGroupMembership[sid], gid)
+}
+
+// ServerSharedWith 服务器是否属于该用户创建的分组，管理员将服务器加入成员的分组即可共享给该成员
+func ServerSharedWith(sid, uid uint64) bool {
+	ServerGroupLock.RLock()
+	defer ServerGroupLock.RUnlock()
+
+	return slices.ContainsFunc(ServerGroupMembership[sid], func(gid uint64) bool {
+		return ServerGroupOwners[gid] == uid
+	})
+}
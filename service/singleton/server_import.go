@@ -0,0 +1,51 @@
+package singleton
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+// ImportServer 创建导入的服务器并加入服务器列表，groupID 不为 0 时同时加入该分组。
+// 批量导入时由调用方在全部完成后调用 ReSortServer 与 UpdateServerGroupMembership
+func ImportServer(s *model.Server, groupID uint64) error {
+	tags, err := utils.Json.Marshal(s.Tags)
+	if err != nil {
+		return err
+	}
+	s.TagsRaw = string(tags)
+	s.OwnerID = s.UserID
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(s).Error; err != nil {
+			return err
+		}
+		if groupID == 0 {
+			return nil
+		}
+		return tx.Create(&model.ServerGroupServer{
+			Common:        model.Common{UserID: s.UserID},
+			ServerGroupId: groupID,
+			ServerId:      s.ID,
+		}).Error
+	}); err != nil {
+		return err
+	}
+	s.Host = &model.Host{}
+	s.State = &model.HostState{}
+	s.GeoIP = &model.GeoIP{}
+
+	ServerLock.Lock()
+	ServerList[s.ID] = s
+	ServerUUIDToID[s.UUID] = s.ID
+	ServerLock.Unlock()
+	return nil
+}
+
+// AddServerToGroup 将服务器加入分组，已在分组中时不做处理
+func AddServerToGroup(sid, groupID, uid uint64) error {
+	return DB.Where(model.ServerGroupServer{ServerGroupId: groupID, ServerId: sid}).
+		Attrs(model.ServerGroupServer{Common: model.Common{UserID: uid}}).
+		FirstOrCreate(&model.ServerGroupServer{}).Error
+}
@@ -0,0 +1,65 @@
+package singleton
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 缓存的条目数上限，超出时丢弃全部缓存，避免订阅条件过多时无限增长
+const serverListCacheMaxEntries = 1024
+
+type serverListCacheEntry struct {
+	data       []byte
+	generation uint64
+	expireAt   time.Time
+}
+
+var (
+	serverListCache      = make(map[string]*serverListCacheEntry)
+	serverListCacheLock  sync.RWMutex
+	serverListGeneration atomic.Uint64
+
+	serverListCacheHits   atomic.Uint64
+	serverListCacheMisses atomic.Uint64
+)
+
+// InvalidateServerListCache 服务器增删、分组、连接状态或配置变更后使已缓存的服务器列表失效，
+// Agent 上报的状态变化依靠缓存的有效期刷新
+func InvalidateServerListCache() {
+	serverListGeneration.Add(1)
+}
+
+// CachedServerList 返回 key 对应的已序列化服务器列表，缓存失效或过期时调用 build 重新生成。
+// key 需包含访问者身份与筛选条件，不同用户可见的服务器不会共用缓存
+func CachedServerList(key string, build func() ([]byte, error)) ([]byte, error) {
+	ttl := time.Duration(Conf.ServerListCacheTTL) * time.Second
+	if ttl <= 0 {
+		return build()
+	}
+
+	// 先取版本号再生成，生成期间发生的变更会使本次结果直接失效
+	generation := serverListGeneration.Load()
+	now := time.Now()
+	serverListCacheLock.RLock()
+	entry := serverListCache[key]
+	serverListCacheLock.RUnlock()
+	if entry != nil && entry.generation == generation && now.Before(entry.expireAt) {
+		serverListCacheHits.Add(1)
+		return entry.data, nil
+	}
+
+	serverListCacheMisses.Add(1)
+	data, err := build()
+	if err != nil {
+		return nil, err
+	}
+
+	serverListCacheLock.Lock()
+	defer serverListCacheLock.Unlock()
+	if len(serverListCache) >= serverListCacheMaxEntries {
+		clear(serverListCache)
+	}
+	serverListCache[key] = &serverListCacheEntry{data: data, generation: generation, expireAt: now.Add(ttl)}
+	return data, nil
+}
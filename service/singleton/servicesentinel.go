@@ -53,6 +53,7 @@ func NewServiceSentinel(serviceSentinelDispatchBus chan<- model.Service) {
 		serviceResponsePing:                     make(map[uint64]map[uint64]*pingStore),
 		Services:                                make(map[uint64]*model.Service),
 		tlsCertCache:                            make(map[uint64]string),
+		serviceCerts:                            make(map[uint64]*model.ServiceCert),
 		// 30天数据缓存
 		monthlyStatus: make(map[uint64]*serviceResponseItem),
 		dispatchBus:   serviceSentinelDispatchBus,
@@ -112,6 +113,7 @@ type ServiceSentinel struct {
 	serviceResponsePing                     map[uint64]map[uint64]*pingStore // [service_id] -> ClientID -> delay
 	lastStatus                              map[uint64]int
 	tlsCertCache                            map[uint64]string
+	serviceCerts                            map[uint64]*model.ServiceCert // [service_id] -> 最近一次获取到的证书
 
 	ServicesLock    sync.RWMutex
 	ServiceListLock sync.RWMutex
@@ -131,6 +133,7 @@ type indexStore struct {
 type pingStore struct {
 	count int
 	ping  float32
+	data  string // 本轮最近一次失败的原因，如 DNS 应答与预期不符
 }
 
 func (ss *ServiceSentinel) refreshMonthlyServiceStatus() {
@@ -289,6 +292,7 @@ func (ss *ServiceSentinel) OnServiceDelete(ids []uint64) {
 		delete(ss.serviceResponseDataStoreCurrentDown, id)
 		delete(ss.serviceResponseDataStoreCurrentAvgDelay, id)
 		delete(ss.tlsCertCache, id)
+		delete(ss.serviceCerts, id)
 		delete(ss.serviceStatusToday, id)
 
 		// 停掉定时任务
@@ -323,6 +327,7 @@ func (ss *ServiceSentinel) LoadStats() map[uint64]*serviceResponseItem {
 		ss.monthlyStatus[k].Up[29] = v.Up
 		ss.monthlyStatus[k].Down[29] = v.Down
 		ss.monthlyStatus[k].Delay[29] = v.Delay
+		ss.monthlyStatus[k].Cert = ss.serviceCerts[k]
 	}
 
 	// 最后 5 分钟的状态 与 service 对象填充
@@ -354,6 +359,54 @@ func (ss *ServiceSentinel) CopyStats() map[uint64]model.ServiceResponseItem {
 	return sri
 }
 
+// ServiceStats 返回单个服务监控最近的状态与 30 天统计，不存在时 ok 为 false
+func (ss *ServiceSentinel) ServiceStats(id uint64) (item model.ServiceResponseItem, ok bool) {
+	stats := ss.LoadStats()
+	ss.monthlyStatusLock.Lock()
+	defer ss.monthlyStatusLock.Unlock()
+	s, ok := stats[id]
+	if !ok {
+		return item, false
+	}
+	item = s.ServiceResponseItem
+	item.ServiceName = s.service.Name
+	return item, true
+}
+
+// FlushPingHistory 将尚未凑满 AvgPingCount 次的监测点延迟均值写入数据库，面板退出时调用
+func (ss *ServiceSentinel) FlushPingHistory() {
+	ss.serviceResponseDataStoreLock.Lock()
+	var histories []model.ServiceHistory
+	for serviceID, stores := range ss.serviceResponsePing {
+		for serverID, ts := range stores {
+			if ts.count == 0 {
+				continue
+			}
+			histories = append(histories, model.ServiceHistory{
+				ServiceID: serviceID,
+				AvgDelay:  ts.ping,
+				Data:      ts.data,
+				ServerID:  serverID,
+			})
+			ts.count = 0
+			ts.data = ""
+		}
+	}
+	ss.serviceResponseDataStoreLock.Unlock()
+
+	saveServiceHistories(histories)
+}
+
+// saveServiceHistories 写入服务监控历史，调用时不应持有 serviceResponseDataStoreLock
+func saveServiceHistories(histories []model.ServiceHistory) {
+	if len(histories) == 0 {
+		return
+	}
+	if err := DB.Create(&histories).Error; err != nil {
+		log.Println("NEZHA>> 服务监控数据持久化失败：", err)
+	}
+}
+
 // worker 服务监控的实际工作流程
 func (ss *ServiceSentinel) worker() {
 	// 从服务状态汇报管道获取汇报的服务数据
@@ -363,7 +416,10 @@ func (ss *ServiceSentinel) worker() {
 			continue
 		}
 		mh := r.Data
-		if mh.Type == model.TaskTypeTCPPing || mh.Type == model.TaskTypeICMPPing {
+		// 需要持久化的数据在释放锁后写入
+		var histories []model.ServiceHistory
+		ss.serviceResponseDataStoreLock.Lock()
+		if model.RecordsReporterLatency(mh.Type) {
 			serviceTcpMap, ok := ss.serviceResponsePing[mh.GetId()]
 			if !ok {
 				serviceTcpMap = make(map[uint64]*pingStore)
@@ -375,20 +431,25 @@ func (ss *ServiceSentinel) worker() {
 			}
 			ts.count++
 			ts.ping = (ts.ping*float32(ts.count-1) + mh.Delay) / float32(ts.count)
+			if !mh.Successful {
+				ts.data = mh.Data
+			}
 			if ts.count == Conf.AvgPingCount {
 				ts.count = 0
-				if err := DB.Create(&model.ServiceHistory{
+				// 本轮有失败时记录失败原因
+				data := mh.Data
+				if ts.data != "" {
+					data, ts.data = ts.data, ""
+				}
+				histories = append(histories, model.ServiceHistory{
 					ServiceID: mh.GetId(),
 					AvgDelay:  ts.ping,
-					Data:      mh.Data,
+					Data:      data,
 					ServerID:  r.Reporter,
-				}).Error; err != nil {
-					log.Println("NEZHA>> 服务监控数据持久化失败：", err)
-				}
+				})
 			}
 			serviceTcpMap[r.Reporter] = ts
 		}
-		ss.serviceResponseDataStoreLock.Lock()
 		// 写入当天状态
 		if mh.Successful {
 			ss.serviceStatusToday[mh.GetId()].Delay = (ss.serviceStatusToday[mh.
@@ -443,15 +504,13 @@ func (ss *ServiceSentinel) worker() {
 				index: 0,
 				t:     currentTime,
 			}
-			if err := DB.Create(&model.ServiceHistory{
+			histories = append(histories, model.ServiceHistory{
 				ServiceID: mh.GetId(),
 				AvgDelay:  ss.serviceResponseDataStoreCurrentAvgDelay[mh.GetId()],
 				Data:      mh.Data,
 				Up:        ss.serviceResponseDataStoreCurrentUp[mh.GetId()],
 				Down:      ss.serviceResponseDataStoreCurrentDown[mh.GetId()],
-			}).Error; err != nil {
-				log.Println("NEZHA>> 服务监控数据持久化失败：", err)
-			}
+			})
 		}
 
 		// 延迟报警
@@ -464,15 +523,13 @@ func (ss *ServiceSentinel) worker() {
 				if mh.Delay > ss.Services[mh.GetId()].MaxLatency {
 					// 延迟超过最大值
 					ServerLock.RLock()
-					reporterServer := ServerList[r.Reporter]
-					msg := Localizer.Tf("[Latency] %s %2f > %2f, Reporter: %s", ss.Services[mh.GetId()].Name, mh.Delay, ss.Services[mh.GetId()].MaxLatency, reporterServer.Name)
+					msg := Localizer.Tf("[Latency] %s %2f > %2f, Reporter: %s", ss.Services[mh.GetId()].Name, mh.Delay, ss.Services[mh.GetId()].MaxLatency, ReporterName(r.Reporter))
 					go SendNotification(notificationGroupID, msg, minMuteLabel)
 					ServerLock.RUnlock()
 				} else if mh.Delay < ss.Services[mh.GetId()].MinLatency {
 					// 延迟低于最小值
 					ServerLock.RLock()
-					reporterServer := ServerList[r.Reporter]
-					msg := Localizer.Tf("[Latency] %s %2f < %2f, Reporter: %s", ss.Services[mh.GetId()].Name, mh.Delay, ss.Services[mh.GetId()].MinLatency, reporterServer.Name)
+					msg := Localizer.Tf("[Latency] %s %2f < %2f, Reporter: %s", ss.Services[mh.GetId()].Name, mh.Delay, ss.Services[mh.GetId()].MinLatency, ReporterName(r.Reporter))
 					go SendNotification(notificationGroupID, msg, maxMuteLabel)
 					ServerLock.RUnlock()
 				} else {
@@ -496,9 +553,8 @@ func (ss *ServiceSentinel) worker() {
 			if isNeedSendNotification {
 				ServerLock.RLock()
 
-				reporterServer := ServerList[r.Reporter]
 				notificationGroupID := ss.Services[mh.GetId()].NotificationGroupID
-				notificationMsg := Localizer.Tf("[%s] %s Reporter: %s, Error: %s", StatusCodeToString(stateCode), ss.Services[mh.GetId()].Name, reporterServer.Name, mh.Data)
+				notificationMsg := Localizer.Tf("[%s] %s Reporter: %s, Error: %s", StatusCodeToString(stateCode), ss.Services[mh.GetId()].Name, ReporterName(r.Reporter), mh.Data)
 				muteLabel := NotificationMuteLabel.ServiceStateChanged(mh.GetId())
 
 				// 状态变更时，清除静音缓存
@@ -513,22 +569,19 @@ func (ss *ServiceSentinel) worker() {
 			// 判断是否需要触发任务
 			isNeedTriggerTask := ss.Services[mh.GetId()].EnableTriggerTask && lastStatus != 0
 			if isNeedTriggerTask {
-				ServerLock.RLock()
-				reporterServer := ServerList[r.Reporter]
-				ServerLock.RUnlock()
-
 				if stateCode == StatusGood && lastStatus != stateCode {
 					// 当前状态正常 前序状态非正常时 触发恢复任务
-					go SendTriggerTasks(ss.Services[mh.GetId()].RecoverTriggerTasks, reporterServer.ID)
+					go SendTriggerTasks(ss.Services[mh.GetId()].RecoverTriggerTasks, r.Reporter)
 				} else if lastStatus == StatusGood && lastStatus != stateCode {
 					// 前序状态正常 当前状态非正常时 触发失败任务
-					go SendTriggerTasks(ss.Services[mh.GetId()].FailTriggerTasks, reporterServer.ID)
+					go SendTriggerTasks(ss.Services[mh.GetId()].FailTriggerTasks, r.Reporter)
 				}
 			}
 
 			ss.ServicesLock.Unlock()
 		}
 		ss.serviceResponseDataStoreLock.Unlock()
+		saveServiceHistories(histories)
 
 		// TLS 证书报警
 		var errMsg string
@@ -554,6 +607,11 @@ func (ss *ServiceSentinel) worker() {
 			if len(newCert) > 1 {
 				ss.ServicesLock.Lock()
 				enableNotify := ss.Services[mh.GetId()].Notify
+				certExpireWindow := ss.Services[mh.GetId()].CertExpireWindow()
+				cert := model.ParseServiceCert(mh.Data, time.Now())
+				if cert != nil {
+					ss.serviceCerts[mh.GetId()] = cert
+				}
 
 				// 首次获取证书信息时，缓存证书信息
 				if ss.tlsCertCache[mh.GetId()] == "" {
@@ -578,11 +636,11 @@ func (ss *ServiceSentinel) worker() {
 				// 需要发送提醒
 				if enableNotify {
 					// 证书过期提醒
-					if expiresNew.Before(time.Now().AddDate(0, 0, 7)) {
+					if expiresNew.Before(time.Now().Add(certExpireWindow)) {
 						expiresTimeStr := expiresNew.Format("2006-01-02 15:04:05")
 						errMsg = Localizer.Tf(
-							"The TLS certificate will expire within seven days. Expiration time: %s",
-							expiresTimeStr,
+							"The TLS certificate will expire within %d days. Expiration time: %s",
+							int(certExpireWindow.Hours()/24), expiresTimeStr,
 						)
 
 						// 静音规则： 服务id+证书过期时间
@@ -591,6 +649,12 @@ func (ss *ServiceSentinel) worker() {
 						go SendNotification(notificationGroupID, fmt.Sprintf("[TLS] %s %s", serviceName, errMsg), muteLabel)
 					}
 
+					// 证书链不可信（自签名、证书链不完整等）提醒
+					if cert != nil && cert.Flag != "" {
+						muteLabel := NotificationMuteLabel.ServiceTLS(mh.GetId(), "flag_"+cert.Flag)
+						go SendNotification(notificationGroupID, fmt.Sprintf("[TLS] %s %s", serviceName, Localizer.Tf("TLS certificate flagged: %s", cert.Flag)), muteLabel)
+					}
+
 					// 证书变更提醒
 					if isCertChanged {
 						errMsg = Localizer.Tf(
@@ -0,0 +1,89 @@
+package singleton
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// AccessTokenTTL 访问令牌的有效期
+const AccessTokenTTL = time.Hour
+
+// 登录会话最近一次请求的时间，只保存在内存中。面板重启后内存中没有记录的会话以令牌的签发时间作为最近一次请求的时间，
+// 重启前已闲置的会话不会因此重新计时
+var (
+	sessionActivity     = make(map[string]time.Time) // [SessionID] -> 最近一次请求的时间
+	sessionActivityLock sync.Mutex
+)
+
+func sessionIdleTimeout() time.Duration {
+	return time.Duration(Conf.SessionIdleTimeout) * time.Second
+}
+
+// sessionLastActive 返回会话最近一次请求的时间，内存中没有记录时返回令牌的签发时间 issuedAt。
+// 调用时需持有 sessionActivityLock
+func sessionLastActive(sid string, issuedAt time.Time) time.Time {
+	if last, ok := sessionActivity[sid]; ok {
+		return last
+	}
+	return issuedAt
+}
+
+// TouchSession 记录会话的一次请求，会话已闲置超时返回 false 且不再续期，issuedAt 为访问令牌的签发时间。
+// 未设置闲置超时或令牌不属于任何会话时总是返回 true
+func TouchSession(sid string, issuedAt time.Time) bool {
+	timeout := sessionIdleTimeout()
+	if timeout <= 0 || sid == "" {
+		return true
+	}
+	now := time.Now()
+	sessionActivityLock.Lock()
+	defer sessionActivityLock.Unlock()
+	if now.Sub(sessionLastActive(sid, issuedAt)) > timeout {
+		return false
+	}
+	sessionActivity[sid] = now
+	return true
+}
+
+// SessionIdleExpired 判断会话是否已闲置超时，不记录为一次请求，刷新令牌时使用，issuedAt 为所出示令牌的签发时间
+func SessionIdleExpired(sid string, issuedAt time.Time) bool {
+	timeout := sessionIdleTimeout()
+	if timeout <= 0 || sid == "" {
+		return false
+	}
+	sessionActivityLock.Lock()
+	defer sessionActivityLock.Unlock()
+	return time.Since(sessionLastActive(sid, issuedAt)) > timeout
+}
+
+// CleanIdleSessions 撤销闲置超时会话的刷新令牌，并在其访问令牌全部过期后不再记录该会话
+func CleanIdleSessions() {
+	timeout := sessionIdleTimeout()
+	now := time.Now()
+
+	var expired []string
+	sessionActivityLock.Lock()
+	for sid, last := range sessionActivity {
+		if timeout <= 0 {
+			delete(sessionActivity, sid)
+			continue
+		}
+		idle := now.Sub(last)
+		if idle <= timeout {
+			continue
+		}
+		expired = append(expired, sid)
+		// 闲置超时前刷新的访问令牌可能仍在有效期内，需保留记录以拒绝这些令牌
+		if idle > timeout+AccessTokenTTL {
+			delete(sessionActivity, sid)
+		}
+	}
+	sessionActivityLock.Unlock()
+
+	for _, sid := range expired {
+		if err := RevokeRefreshTokenFamily(sid); err != nil {
+			log.Printf("NEZHA>> 撤销闲置会话的刷新令牌失败: %v", err)
+		}
+	}
+}
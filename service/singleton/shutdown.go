@@ -0,0 +1,45 @@
+package singleton
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+var (
+	shutdownOnce      sync.Once
+	shuttingDown      = make(chan struct{})
+	alertSentinelDone = make(chan struct{})
+)
+
+// ShuttingDown 返回面板开始退出时关闭的 channel
+func ShuttingDown() <-chan struct{} {
+	return shuttingDown
+}
+
+// Shutdown 停止报警检测与计划任务调度，等待报警器完成当前一轮检测与正在执行的定时任务，
+// 然后写入内存中尚未持久化的数据并将等待重试的通知记为死信。ctx 到期后不再等待
+func Shutdown(ctx context.Context) {
+	shutdownOnce.Do(func() {
+		close(shuttingDown)
+	})
+
+	cronDone := Cron.Stop()
+	select {
+	case <-alertSentinelDone:
+	case <-ctx.Done():
+		log.Println("NEZHA>> 等待报警检测结束超时")
+	}
+	select {
+	case <-cronDone.Done():
+	case <-ctx.Done():
+		log.Println("NEZHA>> 等待定时任务结束超时")
+	}
+
+	flushNotificationRetries()
+	RecordTransferHourlyUsage()
+	RecordCustomMetrics()
+	if ServiceSentinelShared != nil {
+		ServiceSentinelShared.FlushPingHistory()
+	}
+}
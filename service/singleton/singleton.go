@@ -3,11 +3,11 @@ package singleton
 import (
 	_ "embed"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
 	"gopkg.in/yaml.v3"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
 	"github.com/nezhahq/nezha/model"
@@ -25,6 +25,9 @@ var (
 	DashboardBootTime = uint64(time.Now().Unix())
 )
 
+// confLock 串行化所有配置写入，避免并发保存时相互覆盖
+var confLock sync.Mutex
+
 //go:embed frontend-templates.yaml
 var frontendTemplatesYAML []byte
 
@@ -44,9 +47,20 @@ func LoadSingleton() {
 	initI18n()          // 加载本地化服务
 	loadNotifications() // 加载通知服务
 	loadServers()       // 加载服务器列表
+	loadServerStates()  // 加载服务器在线状态
 	loadCronTasks()     // 加载定时任务
+	loadPendingCommands()
+	loadCustomMetrics()
 	initNAT()
 	initDDNS()
+	loadMuteWindows()
+	loadEscalationPolicies()
+	initGeoIP()
+	loadWAFRanges()
+	loadTrustedProxies()
+	loadCORS()
+	loadJWTKeys()
+	checkSecretsKey()
 }
 
 // InitFrontendTemplates 从内置文件中加载FrontendTemplates
@@ -64,12 +78,33 @@ func InitConfigFromPath(path string) {
 	if err != nil {
 		panic(err)
 	}
+	InitLogger()
+}
+
+// UpdateConf 在当前配置的副本上修改并保存，保存成功才替换当前配置。所有修改配置的地方都应通过此函数，
+// 否则基于旧配置的副本保存时会覆盖其他请求刚写入的修改
+func UpdateConf(update func(conf *model.Config) error) error {
+	confLock.Lock()
+	defer confLock.Unlock()
+	conf := *Conf
+	if err := update(&conf); err != nil {
+		return err
+	}
+	if err := conf.Save(); err != nil {
+		return err
+	}
+	notificationMuteLock.Lock()
+	statusPageLock.Lock()
+	*Conf = conf
+	statusPageLock.Unlock()
+	notificationMuteLock.Unlock()
+	return nil
 }
 
-// InitDBFromPath 从给出的文件路径中加载数据库
+// InitDBFromPath 按配置加载数据库，path 为 SQLite 数据库文件路径，使用 PostgreSQL 时忽略
 func InitDBFromPath(path string) {
 	var err error
-	DB, err = gorm.Open(sqlite.Open(path), &gorm.Config{
+	DB, err = gorm.Open(openDatabase(path), &gorm.Config{
 		CreateBatchSize: 200,
 	})
 	if err != nil {
@@ -78,11 +113,7 @@ func InitDBFromPath(path string) {
 	if Conf.Debug {
 		DB = DB.Debug()
 	}
-	err = DB.AutoMigrate(model.Server{}, model.User{}, model.ServerGroup{}, model.NotificationGroup{},
-		model.Notification{}, model.AlertRule{}, model.Service{}, model.NotificationGroupNotification{},
-		model.ServiceHistory{}, model.Cron{}, model.Transfer{}, model.ServerGroupServer{},
-		model.NAT{}, model.DDNSProfile{}, model.NotificationGroupNotification{},
-		model.WAF{})
+	err = model.MigrateDatabase(DB)
 	if err != nil {
 		panic(err)
 	}
@@ -115,15 +146,34 @@ func RecordTransferHourlyUsage() {
 	log.Println("NEZHA>> Cron 流量统计入库", len(txs), DB.Create(txs).Error)
 }
 
-// CleanServiceHistory 清理无效或过时的 监控记录 和 流量记录
+// CleanServiceHistory 清理无效或过时的 监控记录 和 流量记录，分批删除以免长时间占用数据库
 func CleanServiceHistory() {
+	now := time.Now()
+	serviceHistory, transfer := tableName(&model.ServiceHistory{}), tableName(&model.Transfer{})
+	pruned := make(map[string]int64)
 	// 清理已被删除的服务器的监控记录与流量记录
-	DB.Unscoped().Delete(&model.ServiceHistory{}, "created_at < ? OR service_id NOT IN (SELECT `id` FROM services)", time.Now().AddDate(0, 0, -30))
+	pruned[serviceHistory] += pruneInBatches(&model.ServiceHistory{}, "created_at < ? OR service_id NOT IN (SELECT id FROM services)", now.AddDate(0, 0, -max(Conf.ServiceHistoryRetention, 1)))
 	// 由于网络监控记录的数据较多，并且前端仅使用了 1 天的数据
-	// 考虑到 sqlite 数据量问题，仅保留一天数据，
+	// 考虑到 sqlite 数据量问题，默认仅保留一天数据，
 	// server_id = 0 的数据会用于/service页面的可用性展示
-	DB.Unscoped().Delete(&model.ServiceHistory{}, "(created_at < ? AND server_id != 0) OR service_id NOT IN (SELECT `id` FROM services)", time.Now().AddDate(0, 0, -1))
-	DB.Unscoped().Delete(&model.Transfer{}, "server_id NOT IN (SELECT `id` FROM servers)")
+	pruned[serviceHistory] += pruneInBatches(&model.ServiceHistory{}, "(created_at < ? AND server_id != 0) OR service_id NOT IN (SELECT id FROM services)", now.AddDate(0, 0, -max(Conf.ServiceHistoryDetailRetention, 1)))
+	pruned[transfer] += pruneInBatches(&model.Transfer{}, "server_id NOT IN (SELECT id FROM servers)")
+	// 通知发送记录保留一周
+	pruned[tableName(&model.NotificationLog{})] = pruneInBatches(&model.NotificationLog{}, "created_at < ? OR notification_id NOT IN (SELECT id FROM notifications)", now.AddDate(0, 0, -7))
+	// 通知死信保留一个月
+	pruned[tableName(&model.NotificationDeadLetter{})] = pruneInBatches(&model.NotificationDeadLetter{}, "created_at < ? OR notification_id NOT IN (SELECT id FROM notifications)", now.AddDate(0, 0, -30))
+	// 计划任务执行记录按配置的天数保留
+	pruned[tableName(&model.CronHistory{})] = pruneInBatches(&model.CronHistory{}, "created_at < ? OR cron_id NOT IN (SELECT id FROM crons)", now.AddDate(0, 0, -max(Conf.CronHistoryRetention, 1)))
+	// 终端会话及其录制按配置的天数保留
+	pruned[tableName(&model.TerminalSession{})] = pruneInBatches(&model.TerminalSession{}, "created_at < ?", now.AddDate(0, 0, -max(Conf.TerminalRecordingRetention, 1)))
+	pruned[tableName(&model.TerminalRecordingChunk{})] = pruneInBatches(&model.TerminalRecordingChunk{}, "session_id NOT IN (SELECT id FROM terminal_sessions)")
+	// 外部推送的注解与监控记录保留相同的天数
+	pruned[tableName(&model.Annotation{})] = pruneInBatches(&model.Annotation{}, "time < ?", now.AddDate(0, 0, -max(Conf.ServiceHistoryRetention, 1)))
+	// 自定义指标历史按配置的天数保留
+	pruned[tableName(&model.CustomMetricHistory{})] = pruneInBatches(&model.CustomMetricHistory{}, "created_at < ? OR server_id NOT IN (SELECT id FROM servers)", now.AddDate(0, 0, -max(Conf.CustomMetricRetention, 1)))
+	// 长时间未上报结果的执行记录视为失败，避免后续执行一直被标记为重叠
+	DB.Model(&model.CronHistory{}).Where("status = ? AND started_at < ?", model.CronRunStatusRunning, now.Add(-cronRunTimeout)).
+		Updates(map[string]any{"status": model.CronRunStatusFailure, "output": "no result reported"})
 	// 计算可清理流量记录的时长
 	var allServerKeep time.Time
 	specialServerKeep := make(map[uint64]time.Time)
@@ -154,14 +204,26 @@ func CleanServiceHistory() {
 			}
 		}
 	}
+	// 配置了流量记录保留天数时，可清理的时间点不晚于该天数之前
+	keepTransfer := func(before time.Time) time.Time {
+		if Conf.TransferRetention <= 0 {
+			return before
+		}
+		if keep := now.AddDate(0, 0, -Conf.TransferRetention).UTC(); before.IsZero() || keep.Before(before) {
+			return keep
+		}
+		return before
+	}
 	for id, couldRemove := range specialServerKeep {
-		DB.Unscoped().Delete(&model.Transfer{}, "server_id = ? AND datetime(`created_at`) < datetime(?)", id, couldRemove)
+		pruned[transfer] += pruneInBatches(&model.Transfer{}, "server_id = ? AND "+model.TimeCondition(DB, "created_at", "<"), id, keepTransfer(couldRemove))
 	}
-	if allServerKeep.IsZero() {
-		DB.Unscoped().Delete(&model.Transfer{}, "server_id NOT IN (?)", specialServerIDs)
+	if keep := keepTransfer(allServerKeep); keep.IsZero() {
+		pruned[transfer] += pruneInBatches(&model.Transfer{}, "server_id NOT IN (?)", specialServerIDs)
 	} else {
-		DB.Unscoped().Delete(&model.Transfer{}, "server_id NOT IN (?) AND datetime(`created_at`) < datetime(?)", specialServerIDs, allServerKeep)
+		pruned[transfer] += pruneInBatches(&model.Transfer{}, "server_id NOT IN (?) AND "+model.TimeCondition(DB, "created_at", "<"), specialServerIDs, keep)
 	}
+
+	recordPruned(pruned)
 }
 
 // IPDesensitize 根据设置选择是否对IP进行打码处理 返回处理后的IP(关闭打码则返回原IP)
@@ -0,0 +1,180 @@
+package singleton
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const (
+	statusPageCacheKey = "statusPage"
+	// 状态页数据的缓存时间，访问量突增时避免反复统计
+	statusPageCacheTTL = 10 * time.Second
+	// 已解决的事件在状态页上展示的时长
+	statusPageResolvedWindow = 7 * 24 * time.Hour
+	statusPageMaxIncidents   = 20
+)
+
+var (
+	statusPageLock  sync.RWMutex
+	statusPageGroup singleflight.Group
+)
+
+// GetStatusPage 返回状态页配置
+func GetStatusPage() model.StatusPage {
+	statusPageLock.RLock()
+	defer statusPageLock.RUnlock()
+	return Conf.StatusPage
+}
+
+// SetStatusPage 更新状态页配置并保存到配置文件
+func SetStatusPage(p model.StatusPage) error {
+	if err := UpdateConf(func(conf *model.Config) error {
+		conf.StatusPage = p
+		return nil
+	}); err != nil {
+		return err
+	}
+	InvalidateStatusPage()
+	return nil
+}
+
+// InvalidateStatusPage 清除缓存的状态页数据，配置或事件变更后调用
+func InvalidateStatusPage() {
+	Cache.Delete(statusPageCacheKey)
+}
+
+// StatusPageData 返回状态页数据，只包含选中的服务器与服务监控，结果会缓存一段时间
+func StatusPageData() (*model.StatusPageResponse, error) {
+	if v, ok := Cache.Get(statusPageCacheKey); ok {
+		return v.(*model.StatusPageResponse), nil
+	}
+	v, err, _ := statusPageGroup.Do(statusPageCacheKey, func() (any, error) {
+		data, err := buildStatusPage(GetStatusPage(), time.Now())
+		if err != nil {
+			return nil, err
+		}
+		Cache.Set(statusPageCacheKey, data, statusPageCacheTTL)
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*model.StatusPageResponse), nil
+}
+
+func buildStatusPage(page model.StatusPage, now time.Time) (*model.StatusPageResponse, error) {
+	incidents, err := LoadStatusIncidents(true, statusPageMaxIncidents, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &model.StatusPageResponse{
+		Title:       page.Title,
+		Description: page.Description,
+		Status:      model.StatusPageOperational,
+		Servers:     make([]*model.StatusPageServer, 0, len(page.Servers)),
+		Services:    make([]*model.StatusPageService, 0, len(page.Services)),
+		Incidents:   incidents,
+		GeneratedAt: now,
+	}
+	if data.Title == "" {
+		data.Title = Conf.SiteName
+	}
+
+	var down, degraded bool
+	ServerLock.RLock()
+	for _, id := range page.Servers {
+		s, ok := ServerList[id]
+		if !ok {
+			continue
+		}
+		ps := &model.StatusPageServer{
+			ID:            s.ID,
+			Name:          s.Name,
+			Status:        model.ComponentUp,
+			InMaintenance: s.InMaintenance(now),
+			LastActive:    s.LastActive,
+		}
+		if !s.IsOnline(Conf.ServerOfflineTimeout) {
+			ps.Status = model.ComponentDown
+			down = down || !ps.InMaintenance
+		}
+		data.Servers = append(data.Servers, ps)
+	}
+	ServerLock.RUnlock()
+
+	for _, id := range page.Services {
+		stats, ok := ServiceSentinelShared.ServiceStats(id)
+		if !ok {
+			continue
+		}
+		ps := &model.StatusPageService{
+			ID:     id,
+			Name:   stats.ServiceName,
+			Status: model.ComponentNoData,
+			Uptime: stats.TotalUptime(),
+		}
+		if current := stats.CurrentUp + stats.CurrentDown; current > 0 {
+			// 全部失败时在线率为 0，GetStatusCode 会视为无数据
+			switch p := stats.CurrentUp * 100 / current; {
+			case p == 0 || GetStatusCode(p) == StatusDown:
+				ps.Status = model.ComponentDown
+				down = true
+			case GetStatusCode(p) == StatusLowAvailability:
+				ps.Status = model.ComponentDegraded
+				degraded = true
+			default:
+				ps.Status = model.ComponentUp
+			}
+		}
+		data.Services = append(data.Services, ps)
+	}
+
+	degraded = degraded || slices.ContainsFunc(incidents, func(i *model.StatusIncident) bool {
+		return i.ResolvedAt == nil
+	})
+	switch {
+	case down:
+		data.Status = model.StatusPageOutage
+	case degraded:
+		data.Status = model.StatusPageDegraded
+	}
+	return data, nil
+}
+
+// LoadStatusIncidents 按时间倒序返回事件及其进展，recent 为 true 时只返回未解决与最近解决的事件
+func LoadStatusIncidents(recent bool, limit, offset int) ([]*model.StatusIncident, error) {
+	query := DB.Model(&model.StatusIncident{})
+	if recent {
+		query = query.Where("resolved_at IS NULL OR resolved_at > ?", time.Now().Add(-statusPageResolvedWindow))
+	}
+	incidents := make([]*model.StatusIncident, 0)
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&incidents).Error; err != nil {
+		return nil, err
+	}
+	if len(incidents) == 0 {
+		return incidents, nil
+	}
+
+	ids := make([]uint64, 0, len(incidents))
+	byID := make(map[uint64]*model.StatusIncident, len(incidents))
+	for _, i := range incidents {
+		ids = append(ids, i.ID)
+		byID[i.ID] = i
+	}
+	var updates []*model.StatusIncidentUpdate
+	if err := DB.Where("incident_id IN ?", ids).Order("id DESC").Find(&updates).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range updates {
+		if i, ok := byID[u.IncidentID]; ok {
+			i.Updates = append(i.Updates, u)
+		}
+	}
+	return incidents, nil
+}
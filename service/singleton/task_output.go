@@ -0,0 +1,167 @@
+package singleton
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+const (
+	taskOutputBacklogSize   = 64 * 1024 // 为晚加入的订阅者保留的最近输出字节数
+	taskOutputRetention     = time.Minute
+	taskOutputSubscriberBuf = 256
+)
+
+// taskOutput 一次计划任务执行的实时输出
+type taskOutput struct {
+	runID, cronID, serverID uint64
+
+	backlog     []*model.TaskOutputEvent
+	backlogSize int
+	subscribers map[chan *model.TaskOutputEvent]struct{}
+	exitCode    *int
+	done        bool
+}
+
+var (
+	taskOutputs    = make(map[uint64]*taskOutput)
+	taskOutputLock sync.Mutex
+)
+
+// openTaskOutput 为下发到在线服务器的执行创建实时输出，超时仍未结束时自动关闭
+func openTaskOutput(runID, cronID, serverID uint64) {
+	taskOutputLock.Lock()
+	taskOutputs[runID] = &taskOutput{
+		runID:       runID,
+		cronID:      cronID,
+		serverID:    serverID,
+		subscribers: make(map[chan *model.TaskOutputEvent]struct{}),
+	}
+	taskOutputLock.Unlock()
+
+	time.AfterFunc(cronRunTimeout, func() {
+		finishTaskOutput(runID, &model.TaskOutputEvent{Status: model.CronRunStatusFailure, Error: "no result reported"})
+	})
+}
+
+// pendingTaskOutput 与 FinishCronRun 一致，对应最早一条仍在执行中的记录
+func pendingTaskOutput(cronID, serverID uint64) *taskOutput {
+	var found *taskOutput
+	for _, o := range taskOutputs {
+		if o.cronID == cronID && o.serverID == serverID && !o.done && (found == nil || o.runID < found.runID) {
+			found = o
+		}
+	}
+	return found
+}
+
+// publish 推送输出并写入缓冲，跟不上的订阅者会被断开
+func (o *taskOutput) publish(e *model.TaskOutputEvent) {
+	o.backlog = append(o.backlog, e)
+	o.backlogSize += len(e.Data)
+	for len(o.backlog) > 1 && o.backlogSize > taskOutputBacklogSize {
+		o.backlogSize -= len(o.backlog[0].Data)
+		o.backlog = o.backlog[1:]
+	}
+
+	for ch := range o.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(o.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// PublishTaskOutput 保存服务器上报的计划任务实时输出
+func PublishTaskOutput(serverID, cronID uint64, out *model.CommandOutput) {
+	taskOutputLock.Lock()
+	defer taskOutputLock.Unlock()
+
+	o := pendingTaskOutput(cronID, serverID)
+	if o == nil {
+		return
+	}
+	if out.ExitCode != nil {
+		o.exitCode = out.ExitCode
+	}
+	if out.Data == "" {
+		return
+	}
+	stream := out.Stream
+	if stream != model.CommandOutputStderr {
+		stream = model.CommandOutputStdout
+	}
+	o.publish(&model.TaskOutputEvent{Stream: stream, Data: out.Data})
+}
+
+// finishTaskOutput 推送结束事件并断开所有订阅者，保留一段时间供晚加入的订阅者查看
+func finishTaskOutput(runID uint64, e *model.TaskOutputEvent) {
+	taskOutputLock.Lock()
+	defer taskOutputLock.Unlock()
+
+	o, ok := taskOutputs[runID]
+	if !ok || o.done {
+		return
+	}
+	o.done = true
+	e.Done = true
+	e.ExitCode = o.exitCode
+	o.publish(e)
+	for ch := range o.subscribers {
+		delete(o.subscribers, ch)
+		close(ch)
+	}
+
+	time.AfterFunc(taskOutputRetention, func() {
+		taskOutputLock.Lock()
+		delete(taskOutputs, runID)
+		taskOutputLock.Unlock()
+	})
+}
+
+// AbortTaskOutputs 服务器断开连接后结束其所有执行中的实时输出
+func AbortTaskOutputs(serverID uint64) {
+	taskOutputLock.Lock()
+	var runs []uint64
+	for id, o := range taskOutputs {
+		if o.serverID == serverID && !o.done {
+			runs = append(runs, id)
+		}
+	}
+	taskOutputLock.Unlock()
+
+	for _, id := range runs {
+		finishTaskOutput(id, &model.TaskOutputEvent{Error: "agent disconnected"})
+	}
+}
+
+// SubscribeTaskOutput 订阅执行的实时输出，返回缓冲的最近输出与后续输出的 channel，
+// channel 在执行结束或订阅者跟不上时关闭。执行不在内存中时 ok 为 false
+func SubscribeTaskOutput(runID uint64) (backlog []*model.TaskOutputEvent, ch <-chan *model.TaskOutputEvent, cancel func(), ok bool) {
+	taskOutputLock.Lock()
+	defer taskOutputLock.Unlock()
+
+	o, ok := taskOutputs[runID]
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	c := make(chan *model.TaskOutputEvent, taskOutputSubscriberBuf)
+	backlog = append(backlog, o.backlog...)
+	if o.done {
+		close(c)
+		return backlog, c, func() {}, true
+	}
+	o.subscribers[c] = struct{}{}
+	return backlog, c, func() {
+		taskOutputLock.Lock()
+		defer taskOutputLock.Unlock()
+		if _, ok := o.subscribers[c]; ok {
+			delete(o.subscribers, c)
+			close(c)
+		}
+	}, true
+}
@@ -0,0 +1,76 @@
+package singleton
+
+import (
+	"maps"
+	"slices"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+)
+
+// UserAdministers admin 是否为可以管理 uid 数据的管理员：超级管理员可管理全部用户，租户管理员仅可管理本租户的用户
+func UserAdministers(admin, uid uint64) bool {
+	UserLock.RLock()
+	defer UserLock.RUnlock()
+
+	info, ok := UserInfoMap[admin]
+	if !ok || info.Role != model.RoleAdmin {
+		return false
+	}
+	if info.TenantID == 0 {
+		return true
+	}
+	u, ok := UserInfoMap[uid]
+	return ok && u.TenantID == info.TenantID
+}
+
+// IsSuperAdminID uid 是否为超级管理员
+func IsSuperAdminID(uid uint64) bool {
+	UserLock.RLock()
+	defer UserLock.RUnlock()
+
+	info, ok := UserInfoMap[uid]
+	return ok && info.Role == model.RoleAdmin && info.TenantID == 0
+}
+
+// TenantScopeOf 返回用户所在租户的范围，超级管理员返回 nil
+func TenantScopeOf(u *model.User) *model.TenantScope {
+	if u.IsSuperAdmin() {
+		return nil
+	}
+
+	UserLock.RLock()
+	defer UserLock.RUnlock()
+
+	scope := &model.TenantScope{TenantID: u.TenantID, Users: make(map[uint64]bool)}
+	for id, info := range UserInfoMap {
+		if info.TenantID == u.TenantID {
+			scope.Users[id] = true
+		}
+	}
+	return scope
+}
+
+// UserScope 限定查询范围为用户可管理的数据：超级管理员不限制，租户管理员为本租户用户的数据，成员为自己的数据
+func UserScope(user *model.User) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if user.IsSuperAdmin() {
+			return tx
+		}
+		if user.Role == model.RoleAdmin {
+			return tx.Where("user_id IN (?)", slices.Collect(maps.Keys(TenantScopeOf(user).Users)))
+		}
+		return tx.Where("user_id = ?", user.ID)
+	}
+}
+
+// TenantExists 租户是否存在，0 表示不属于任何租户
+func TenantExists(id uint64) bool {
+	if id == 0 {
+		return true
+	}
+	var count int64
+	DB.Model(&model.Tenant{}).Where("id = ?", id).Count(&count)
+	return count > 0
+}
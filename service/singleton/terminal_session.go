@@ -0,0 +1,141 @@
+package singleton
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+const (
+	// 录制内容达到该大小或距上次写入超过该时长时写入数据库
+	terminalRecordingFlushSize     = 32 * 1024
+	terminalRecordingFlushInterval = 5 * time.Second
+)
+
+// terminalRecorder 包装用户端连接，将发往用户的终端输出按 asciicast v2 格式录制。
+// 用户的输入不会被录制，只记录其中调整窗口大小的消息。
+type terminalRecorder struct {
+	io.ReadWriteCloser
+
+	session *model.TerminalSession
+	limit   int64
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	partial   []byte // 尚不完整的 UTF-8 字符
+	lastFlush time.Time
+	closeOnce sync.Once
+}
+
+// RecordTerminalSession 返回用于该会话的用户端连接，会话开启录制时写入的输出会被保存。
+// 返回的连接关闭时记录会话结束时间。
+func RecordTerminalSession(session *model.TerminalSession, conn io.ReadWriteCloser) io.ReadWriteCloser {
+	return &terminalRecorder{
+		ReadWriteCloser: conn,
+		session:         session,
+		limit:           int64(Conf.TerminalRecordingLimit),
+		lastFlush:       time.Now(),
+	}
+}
+
+func (r *terminalRecorder) Write(p []byte) (int, error) {
+	if r.session.Recorded {
+		r.mu.Lock()
+		r.record(p)
+		r.mu.Unlock()
+	}
+	return r.ReadWriteCloser.Write(p)
+}
+
+func (r *terminalRecorder) Read(p []byte) (int, error) {
+	n, err := r.ReadWriteCloser.Read(p)
+	if n > 1 && p[0] == model.TerminalMsgResize {
+		var size model.TerminalWindowSize
+		if utils.Json.Unmarshal(p[1:n], &size) == nil && size.Cols > 0 && size.Rows > 0 {
+			r.mu.Lock()
+			r.resize(size)
+			r.mu.Unlock()
+		}
+	}
+	return n, err
+}
+
+func (r *terminalRecorder) Close() error {
+	r.closeOnce.Do(r.finish)
+	return r.ReadWriteCloser.Close()
+}
+
+func (r *terminalRecorder) record(p []byte) {
+	if r.session.Truncated {
+		return
+	}
+	data, partial := model.SplitIncompleteUTF8(append(r.partial, p...))
+	r.partial = bytes.Clone(partial)
+	if len(data) == 0 {
+		return
+	}
+	r.append(model.CastEvent(time.Since(r.session.StartedAt), "o", string(data)))
+}
+
+func (r *terminalRecorder) resize(size model.TerminalWindowSize) {
+	// 首个窗口大小作为录制的初始大小写入文件头
+	if r.session.Width == 0 {
+		r.session.Width, r.session.Height = size.Cols, size.Rows
+		DB.Model(r.session).Updates(map[string]any{"width": size.Cols, "height": size.Rows})
+		return
+	}
+	if r.session.Recorded && !r.session.Truncated {
+		r.append(model.CastEvent(time.Since(r.session.StartedAt), "r", fmt.Sprintf("%dx%d", size.Cols, size.Rows)))
+	}
+}
+
+func (r *terminalRecorder) append(event []byte) {
+	if r.limit > 0 && r.session.Size+int64(r.buf.Len()+len(event)) > r.limit {
+		r.session.Truncated = true
+		r.flush()
+		return
+	}
+	r.buf.Write(event)
+	if r.buf.Len() >= terminalRecordingFlushSize || time.Since(r.lastFlush) >= terminalRecordingFlushInterval {
+		r.flush()
+	}
+}
+
+func (r *terminalRecorder) flush() {
+	r.lastFlush = time.Now()
+	if r.buf.Len() == 0 {
+		return
+	}
+	chunk := model.TerminalRecordingChunk{
+		SessionID: r.session.ID,
+		Data:      r.buf.String(),
+	}
+	r.buf.Reset()
+	if err := DB.Create(&chunk).Error; err != nil {
+		log.Printf("NEZHA>> failed to save terminal recording %s: %v", r.session.SessionID, err)
+		return
+	}
+	r.session.Size += int64(len(chunk.Data))
+}
+
+func (r *terminalRecorder) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.flush()
+	now := time.Now()
+	r.session.EndedAt = &now
+	if err := DB.Model(r.session).Updates(map[string]any{
+		"ended_at":  now,
+		"size":      r.session.Size,
+		"truncated": r.session.Truncated,
+	}).Error; err != nil {
+		log.Printf("NEZHA>> failed to save terminal session %s: %v", r.session.SessionID, err)
+	}
+}
@@ -1,7 +1,9 @@
 package singleton
 
 import (
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/nezhahq/nezha/model"
 	"gorm.io/gorm"
@@ -23,8 +25,10 @@ func initUser() {
 
 	for _, u := range users {
 		UserInfoMap[u.ID] = model.UserInfo{
-			Role:        u.Role,
-			AgentSecret: u.AgentSecret,
+			Role:         u.Role,
+			TenantID:     u.TenantID,
+			AgentSecret:  u.AgentSecret,
+			TokenVersion: u.TokenVersion,
 		}
 		AgentSecretToUserId[u.AgentSecret] = u.ID
 	}
@@ -39,12 +43,72 @@ func OnUserUpdate(u *model.User) {
 	}
 
 	UserInfoMap[u.ID] = model.UserInfo{
-		Role:        u.Role,
-		AgentSecret: u.AgentSecret,
+		Role:         u.Role,
+		TenantID:     u.TenantID,
+		AgentSecret:  u.AgentSecret,
+		TokenVersion: u.TokenVersion,
 	}
 	AgentSecretToUserId[u.AgentSecret] = u.ID
 }
 
+// ServerAccessibleBy 用户是否可以访问服务器：管理员可访问所管理租户的全部服务器，成员仅可访问自己所属或共享给自己的服务器
+func ServerAccessibleBy(uid uint64, s *model.Server) bool {
+	if s.UserID == uid || UserAdministers(uid, s.UserID) {
+		return true
+	}
+	return ServerSharedWith(s.ID, uid)
+}
+
+// RecordLogin 更新用户最后登录信息并追加登录历史
+func RecordLogin(uid uint64, ip, userAgent string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", uid).Updates(map[string]any{
+			"last_login_at": time.Now(),
+			"last_login_ip": ip,
+		}).Error; err != nil {
+			return err
+		}
+
+		h := model.LoginHistory{IP: ip, UserAgent: userAgent}
+		h.UserID = uid
+		if err := tx.Create(&h).Error; err != nil {
+			return err
+		}
+
+		// 滚动清理超出保留条数的旧记录
+		return tx.Where("user_id = ? AND id NOT IN (?)", uid,
+			tx.Model(&model.LoginHistory{}).Select("id").Where("user_id = ?", uid).Order("id desc").Limit(model.MaxLoginHistory),
+		).Delete(&model.LoginHistory{}).Error
+	})
+}
+
+// CheckTokenVersion 校验 JWT 中的令牌版本，用户已删除或会话已被撤销时返回 false
+func CheckTokenVersion(uid, version uint64) bool {
+	UserLock.RLock()
+	defer UserLock.RUnlock()
+
+	info, ok := UserInfoMap[uid]
+	return ok && info.TokenVersion == version
+}
+
+// RevokeUserSessions 递增用户的令牌版本，使其所有已签发的 JWT 立即失效
+func RevokeUserSessions(uid uint64) (uint64, error) {
+	UserLock.Lock()
+	defer UserLock.Unlock()
+
+	info, ok := UserInfoMap[uid]
+	if !ok {
+		return 0, Localizer.ErrorT("user id %d does not exist", uid)
+	}
+
+	info.TokenVersion++
+	if err := DB.Model(&model.User{}).Where("id = ?", uid).Update("token_version", info.TokenVersion).Error; err != nil {
+		return 0, err
+	}
+	UserInfoMap[uid] = info
+	return info.TokenVersion, nil
+}
+
 func OnUserDelete(id []uint64, errorFunc func(string, ...interface{}) error) error {
 	UserLock.Lock()
 	defer UserLock.Unlock()
@@ -76,16 +140,29 @@ func OnUserDelete(id []uint64, errorFunc func(string, ...interface{}) error) err
 			SortedServerLock.RUnlock()
 
 			server = len(servers) > 0
-			if server {
-				if err := tx.Unscoped().Delete(&model.Server{}, "id in (?)", servers).Error; err != nil {
-					return err
-				}
-				if err := tx.Unscoped().Delete(&model.ServerGroupServer{}, "server_id in (?)", servers).Error; err != nil {
-					return err
-				}
+
+			// 回收站中的服务器一并彻底删除
+			var trashed []uint64
+			if err := tx.Unscoped().Model(&model.Server{}).Where("user_id = ? AND deleted_at IS NOT NULL", uid).Pluck("id", &trashed).Error; err != nil {
+				return err
+			}
+			if err := PurgeServers(tx, append(slices.Clone(servers), trashed...)); err != nil {
+				return err
+			}
+
+			if err := tx.Unscoped().Delete(&model.ApiToken{}, "user_id = ?", uid).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Unscoped().Delete(&model.LoginHistory{}, "user_id = ?", uid).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Unscoped().Delete(&model.WebAuthnCredential{}, "user_id = ?", uid).Error; err != nil {
+				return err
 			}
 
-			if err := tx.Unscoped().Delete(&model.Transfer{}, "server_id in (?)", servers).Error; err != nil {
+			if err := tx.Delete(&model.RefreshToken{}, "user_id = ?", uid).Error; err != nil {
 				return err
 			}
 
@@ -0,0 +1,285 @@
+package singleton
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nezhahq/nezha/model"
+	"github.com/nezhahq/nezha/pkg/utils"
+)
+
+var (
+	WAFRangeList []*model.WAFRange
+	WAFRangeLock sync.RWMutex
+
+	wafRangeTrie *utils.IPTrie
+
+	trustedProxies atomic.Pointer[[]netip.Prefix]
+)
+
+// OnTrustedProxiesUpdate 解析并替换可信代理列表，格式错误时保持原列表不变
+func OnTrustedProxiesUpdate(list string) error {
+	prefixes, err := utils.ParsePrefixList(list)
+	if err != nil {
+		return err
+	}
+	trustedProxies.Store(&prefixes)
+	return nil
+}
+
+func loadTrustedProxies() {
+	if err := OnTrustedProxiesUpdate(Conf.TrustedProxies); err != nil {
+		log.Printf("NEZHA>> invalid trusted_proxies: %v", err)
+	}
+}
+
+// ClientIP 根据真实 IP 请求头与直连地址确定客户端 IP
+func ClientIP(headerValue, peer string) (string, error) {
+	var trusted []netip.Prefix
+	if p := trustedProxies.Load(); p != nil {
+		trusted = *p
+	}
+	peerAddr, _ := utils.ParseHeaderIP(peer)
+	return utils.GetClientIP(headerValue, peerAddr, trusted)
+}
+
+func loadWAFRanges() {
+	WAFRangeLock.Lock()
+	defer WAFRangeLock.Unlock()
+	if err := DB.Order("id").Find(&WAFRangeList).Error; err != nil {
+		panic(err)
+	}
+	rebuildWAFRangeTrie()
+}
+
+// rebuildWAFRangeTrie 调用方需持有 WAFRangeLock
+func rebuildWAFRangeTrie() {
+	trie := new(utils.IPTrie)
+	for _, r := range WAFRangeList {
+		if p, err := netip.ParsePrefix(r.CIDR); err == nil {
+			trie.Insert(p)
+		}
+	}
+	wafRangeTrie = trie
+}
+
+// CheckIPBlocked 检查 IP 是否处于被封禁的 IP 段、国家或 ASN 中
+func CheckIPBlocked(ip string) error {
+	if ip == "" {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		WAFRangeLock.RLock()
+		blocked := wafRangeTrie != nil && wafRangeTrie.Contains(addr)
+		WAFRangeLock.RUnlock()
+		if blocked {
+			return errors.New("you are blocked by nezha WAF")
+		}
+	}
+	return CheckGeoBlock(ip)
+}
+
+// covers 判断 IP 段 a 是否包含 b
+func covers(a, b netip.Prefix) bool {
+	a, b = utils.To16Prefix(a), utils.To16Prefix(b)
+	return a.Bits() <= b.Bits() && a.Contains(b.Addr())
+}
+
+// outlives 到期时间 a 是否不早于 b，0 表示不过期
+func outlives(a, b uint64) bool {
+	return a == 0 || (b != 0 && a >= b)
+}
+
+// BlockRanges 封禁 IP 段：已被现有段覆盖且不会更早到期的段将被忽略，被新段覆盖的现有段会被合并删除
+func BlockRanges(prefixes []netip.Prefix, reason uint8, uid int64, expireAt uint64) error {
+	WAFRangeLock.Lock()
+	defer WAFRangeLock.Unlock()
+
+	type entry struct {
+		prefix   netip.Prefix
+		expireAt uint64
+		id       uint64 // 为 0 表示本次新增
+	}
+	current := make([]entry, 0, len(WAFRangeList)+len(prefixes))
+	for _, r := range WAFRangeList {
+		if p, err := netip.ParsePrefix(r.CIDR); err == nil {
+			current = append(current, entry{prefix: p, expireAt: r.ExpireAt, id: r.ID})
+		}
+	}
+
+	var absorbed []uint64
+	for _, p := range prefixes {
+		p = p.Masked()
+		if slices.ContainsFunc(current, func(e entry) bool {
+			return covers(e.prefix, p) && outlives(e.expireAt, expireAt)
+		}) {
+			continue
+		}
+		current = slices.DeleteFunc(current, func(e entry) bool {
+			if !covers(p, e.prefix) || !outlives(expireAt, e.expireAt) {
+				return false
+			}
+			if e.id != 0 {
+				absorbed = append(absorbed, e.id)
+			}
+			return true
+		})
+		current = append(current, entry{prefix: p, expireAt: expireAt})
+	}
+
+	var added []*model.WAFRange
+	for _, e := range current {
+		if e.id == 0 {
+			added = append(added, &model.WAFRange{
+				CIDR:            e.prefix.String(),
+				BlockReason:     reason,
+				BlockIdentifier: uid,
+				ExpireAt:        expireAt,
+			})
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if len(absorbed) > 0 {
+			if err := tx.Delete(&model.WAFRange{}, "id in (?)", absorbed).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&added).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	WAFRangeList = slices.DeleteFunc(WAFRangeList, func(r *model.WAFRange) bool {
+		return slices.Contains(absorbed, r.ID)
+	})
+	WAFRangeList = append(WAFRangeList, added...)
+	rebuildWAFRangeTrie()
+
+	OnlineUserMapLock.Lock()
+	defer OnlineUserMapLock.Unlock()
+	for _, user := range OnlineUserMap {
+		if addr, err := netip.ParseAddr(user.IP); err == nil && user.Conn != nil && wafRangeTrie.Contains(addr) {
+			user.Conn.Close()
+		}
+	}
+	return nil
+}
+
+// ClearRanges 解除 IP 段封禁
+func ClearRanges(prefixes []netip.Prefix) error {
+	cidrs := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		cidrs = append(cidrs, p.Masked().String())
+	}
+
+	WAFRangeLock.Lock()
+	defer WAFRangeLock.Unlock()
+	if err := DB.Delete(&model.WAFRange{}, "cidr in (?)", cidrs).Error; err != nil {
+		return err
+	}
+	WAFRangeList = slices.DeleteFunc(WAFRangeList, func(r *model.WAFRange) bool {
+		return slices.Contains(cidrs, r.CIDR)
+	})
+	rebuildWAFRangeTrie()
+	return nil
+}
+
+// ActiveBlocks 返回当前生效的 IP 与 IP 段封禁
+func ActiveBlocks() ([]*model.WAFBlock, error) {
+	list, err := model.ActiveIPBlocks(DB)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	WAFRangeLock.RLock()
+	defer WAFRangeLock.RUnlock()
+	for _, r := range WAFRangeList {
+		if model.ExpiredAt(r.ExpireAt, now) {
+			continue
+		}
+		b := &model.WAFBlock{
+			Address:        r.CIDR,
+			Range:          true,
+			Source:         model.BlockSource(r.BlockReason),
+			BlockReason:    r.BlockReason,
+			BlockTimestamp: uint64(r.CreatedAt.Unix()),
+		}
+		b.SetExpireAt(r.ExpireAt, uint64(now.Unix()))
+		list = append(list, b)
+	}
+	return list, nil
+}
+
+// CleanExpiredBlocks 清理已到期的 IP 与 IP 段封禁
+func CleanExpiredBlocks() {
+	now := time.Now()
+	if err := DB.Delete(&model.WAF{}, "expire_at > 0 AND expire_at <= ?", now.Unix()).Error; err != nil {
+		log.Printf("NEZHA>> 清理到期的 IP 封禁失败: %v", err)
+	}
+
+	WAFRangeLock.Lock()
+	defer WAFRangeLock.Unlock()
+	if !slices.ContainsFunc(WAFRangeList, func(r *model.WAFRange) bool { return model.ExpiredAt(r.ExpireAt, now) }) {
+		return
+	}
+	if err := DB.Delete(&model.WAFRange{}, "expire_at > 0 AND expire_at <= ?", now.Unix()).Error; err != nil {
+		log.Printf("NEZHA>> 清理到期的 IP 段封禁失败: %v", err)
+		return
+	}
+	WAFRangeList = slices.DeleteFunc(WAFRangeList, func(r *model.WAFRange) bool {
+		return model.ExpiredAt(r.ExpireAt, now)
+	})
+	rebuildWAFRangeTrie()
+}
+
+// RecordWAFAudit 记录手动封禁与解封操作
+func RecordWAFAudit(ctx context.Context, uid uint64, action string, addresses []string, expireAt uint64) {
+	if len(addresses) == 0 {
+		return
+	}
+	logs := make([]model.WAFAudit, 0, len(addresses))
+	for _, addr := range addresses {
+		logs = append(logs, model.WAFAudit{
+			UserID:   uid,
+			Action:   action,
+			Address:  addr,
+			ExpireAt: expireAt,
+		})
+	}
+	if err := DB.Create(&logs).Error; err != nil {
+		Logger(ctx).Error("failed to save waf audit", "action", action, "error", err)
+	}
+}
+
+// SplitIPAndRanges 将封禁列表拆分为单个 IP 与 CIDR
+func SplitIPAndRanges(list []string) ([]string, []netip.Prefix, error) {
+	var ips []string
+	var prefixes []netip.Prefix
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ips = append(ips, s)
+			continue
+		}
+		p, err := netip.ParsePrefix(strings.TrimSpace(s))
+		if err != nil {
+			return nil, nil, Localizer.ErrorT("invalid CIDR: %s", s)
+		}
+		prefixes = append(prefixes, p)
+	}
+	return ips, prefixes, nil
+}
@@ -0,0 +1,49 @@
+package singleton
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// WebSocket 连接计数，包括服务器状态推送、终端、文件管理与计划任务输出
+var (
+	wsConnectionsByIP = make(map[string]int)
+	wsConnections     int
+	wsConnectionsLock sync.Mutex
+	wsRejected        atomic.Uint64
+)
+
+// AcquireWebSocketConn 占用一个连接名额，超出限制时返回 false；exempt 为 true 时不检查单 IP 限制。
+// 连接关闭后需调用返回的 release 归还名额
+func AcquireWebSocketConn(ip string, exempt bool) (release func(), ok bool) {
+	limit := Conf.WebSocketLimit
+
+	wsConnectionsLock.Lock()
+	defer wsConnectionsLock.Unlock()
+	if (limit.Total > 0 && wsConnections >= limit.Total) ||
+		(!exempt && limit.PerIP > 0 && wsConnectionsByIP[ip] >= limit.PerIP) {
+		wsRejected.Add(1)
+		return nil, false
+	}
+	wsConnections++
+	wsConnectionsByIP[ip]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			wsConnectionsLock.Lock()
+			defer wsConnectionsLock.Unlock()
+			wsConnections--
+			if wsConnectionsByIP[ip]--; wsConnectionsByIP[ip] <= 0 {
+				delete(wsConnectionsByIP, ip)
+			}
+		})
+	}, true
+}
+
+// GetWebSocketConnCount 返回当前的 WebSocket 连接数
+func GetWebSocketConnCount() int {
+	wsConnectionsLock.Lock()
+	defer wsConnectionsLock.Unlock()
+	return wsConnections
+}